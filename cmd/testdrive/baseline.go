@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/baseline"
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/spf13/cobra"
+)
+
+func newSnapshotCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "snapshot",
+		Short: "Record the parsed pipeline in " + baseline.Path + " for diff",
+		Long: `Snapshot writes a normalized description of every discovered workflow:
+its jobs, their step names and commands, and the keys of each env block.
+Values and anything else that varies between machines are left out, so the
+file can be committed and checked with testdrive diff.`,
+		Args: cobra.NoArgs,
+		RunE: runSnapshot,
+	}
+}
+
+func newDiffCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff",
+		Short: "Report how the workflows changed since " + baseline.Path,
+		Long: `Diff parses the workflows again and lists workflows, jobs, and steps that
+were added, removed, renamed, or changed since testdrive snapshot, with
+changed run scripts as unified diffs. It exits non-zero when anything
+changed, for use as a pre-merge check.`,
+		Args: cobra.NoArgs,
+		RunE: runDiff,
+	}
+}
+
+func runSnapshot(cmd *cobra.Command, _ []string) error {
+	cfg, root, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	data, err := loadPipeline(root, cfg, debugLog(cmd))
+	if err != nil {
+		return err
+	}
+	snap := baseline.Build(data.workflows)
+	if err := baseline.Write(root, snap); err != nil {
+		return err
+	}
+	var jobs, steps int
+	for _, wf := range snap.Workflows {
+		jobs += len(wf.Jobs)
+		for _, job := range wf.Jobs {
+			steps += len(job.Steps)
+		}
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s: %d workflow(s), %d job(s), %d step(s)\n", baseline.Path, len(snap.Workflows), jobs, steps)
+	return nil
+}
+
+func runDiff(cmd *cobra.Command, _ []string) error {
+	cfg, root, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	base, err := baseline.Load(root)
+	if errors.Is(err, baseline.ErrNoBaseline) {
+		return fmt.Errorf("%w at %s; run testdrive snapshot first", err, baseline.Path)
+	}
+	if err != nil {
+		return err
+	}
+	data, err := loadPipeline(root, cfg, debugLog(cmd))
+	if err != nil {
+		return err
+	}
+	changes := baseline.Diff(base, baseline.Build(data.workflows))
+
+	switch strings.ToLower(cfg.Format) {
+	case config.FormatPretty:
+		if err := newPretty(cmd, cfg).RenderBaselineDiff(changes); err != nil {
+			return err
+		}
+	case config.FormatJSON:
+		renderer := output.NewJSON(cmd.OutOrStdout())
+		renderer.Compact = cfg.Compact
+		if err := renderer.Encode(changes); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+
+	if len(changes) > 0 {
+		return fmt.Errorf("%d change(s) from the baseline", len(changes))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/baseline"
+)
+
+const baselineWorkflow = `name: CI
+jobs:
+  test:
+    steps:
+      - name: Specs
+        run: |
+          bundle exec rspec
+      - name: Lint
+        run: bin/lint
+`
+
+func TestSnapshotAndDiff(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, ".github", "workflows")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeWorkflow := func(contents string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "ci.yml"), []byte(contents), 0o644); err != nil {
+			t.Fatalf("write workflow: %v", err)
+		}
+	}
+	writeWorkflow(baselineWorkflow)
+	chdir(t, root)
+
+	execute := func(args ...string) (string, error) {
+		cmd := newRootCmd()
+		cmd.SetArgs(args)
+		buf := &bytes.Buffer{}
+		cmd.SetOut(buf)
+		cmd.SetErr(&bytes.Buffer{})
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+
+	if _, err := execute("diff"); err == nil || !strings.Contains(err.Error(), "run testdrive snapshot first") {
+		t.Fatalf("expected a missing baseline error, got %v", err)
+	}
+
+	out, err := execute("snapshot")
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if out != "Wrote .testdrive/baseline.json: 1 workflow(s), 1 job(s), 2 step(s)\n" {
+		t.Fatalf("unexpected snapshot output %q", out)
+	}
+
+	// A whitespace-only edit is not drift.
+	writeWorkflow(strings.Replace(baselineWorkflow, "run: bin/lint", "run: \"bin/lint  \"", 1))
+	if out, err := execute("diff"); err != nil || out != "DIFF: no changes from .testdrive/baseline.json\n" {
+		t.Fatalf("expected no changes, got %v:\n%s", err, out)
+	}
+
+	writeWorkflow(strings.Replace(baselineWorkflow, "bundle exec rspec", "bundle exec rspec --fail-fast", 1) + `  deploy:
+    steps:
+      - run: bin/deploy
+`)
+	out, err = execute("diff")
+	if err == nil || err.Error() != "2 change(s) from the baseline" {
+		t.Fatalf("expected the diff to fail, got %v", err)
+	}
+	want := `CHANGES:
+  + job .github/workflows/ci.yml / deploy
+  ~ step .github/workflows/ci.yml / test / Specs: run changed
+      --- baseline
+      +++ current
+      @@ -1,1 +1,1 @@
+      -bundle exec rspec
+      +bundle exec rspec --fail-fast
+DIFF: 2 change(s) from .testdrive/baseline.json
+`
+	if diff := diffStrings(want, out); diff != "" {
+		t.Fatalf("unexpected diff output:\n%s", diff)
+	}
+
+	out, _ = execute("diff", "--format", "json")
+	var changes []baseline.Change
+	if err := json.Unmarshal([]byte(out), &changes); err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Kind != baseline.ChangeAdded || changes[1].Field != "run" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+}
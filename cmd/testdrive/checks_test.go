@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/version"
+)
+
+// countVersionProbes stubs the version detector like stubVersionDetector and
+// returns how many tool processes it has been asked to start.
+func countVersionProbes(t *testing.T) *atomic.Int32 {
+	t.Helper()
+	prev := versionDetector
+	var probes atomic.Int32
+	versionDetector = version.NewExecDetector(func(name string, args ...string) (string, error) {
+		probes.Add(1)
+		return stubVersionOutput(name, args...)
+	})
+	t.Cleanup(func() { versionDetector = prev })
+	return &probes
+}
+
+func TestChecksFlags(t *testing.T) {
+	dir := versionReportRepo(t)
+	probes := countVersionProbes(t)
+	useGit(t, dirtyDetached)
+	chdir(t, dir)
+
+	execute := func(args ...string) string {
+		t.Helper()
+		cmd := newRootCmd()
+		cmd.SetArgs(args)
+		buf := &bytes.Buffer{}
+		cmd.SetOut(buf)
+		cmd.SetErr(buf)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%s: %v\n%s", strings.Join(args, " "), err, buf)
+		}
+		return buf.String()
+	}
+
+	for _, args := range [][]string{
+		{"list"},
+		{"list", "--no-check"},
+		{"list", "--details", "--no-check"},
+		{"run", "--dry-run", "--no-check"},
+	} {
+		probes.Store(0)
+		out := execute(args...)
+		if n := probes.Load(); n != 0 {
+			t.Fatalf("%s started %d tool processes", strings.Join(args, " "), n)
+		}
+		if strings.Contains(out, "ruby version mismatch") || strings.Contains(out, "uncommitted") {
+			t.Fatalf("%s reported checks:\n%s", strings.Join(args, " "), out)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"list", "--check"},
+		{"run", "--dry-run"},
+	} {
+		probes.Store(0)
+		out := execute(args...)
+		if probes.Load() == 0 || !strings.Contains(out, "ruby version mismatch") {
+			t.Fatalf("%s did not check versions:\n%s", strings.Join(args, " "), out)
+		}
+	}
+	if out := execute("list", "--check"); !strings.Contains(out, "3 uncommitted") {
+		t.Fatalf("list --check did not warn about git state:\n%s", out)
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"list", "--check", "--no-check"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Fatalf("expected --check with --no-check to be refused, got %v", err)
+	}
+}
+
+// countingGit records the git commands it is asked to run and answers
+// them from git.
+type countingGit struct {
+	git   scriptedGit
+	calls []string
+}
+
+func (c *countingGit) Run(dir string, args ...string) (string, error) {
+	c.calls = append(c.calls, strings.Join(args, " "))
+	return c.git.Run(dir, args...)
+}
+
+func TestListWithoutChecksStartsNoGit(t *testing.T) {
+	dir := versionReportRepo(t)
+	countVersionProbes(t)
+	git := &countingGit{git: goldenCheckout}
+	useGit(t, git)
+	chdir(t, dir)
+
+	for _, args := range [][]string{
+		{"list"},
+		{"list", "--no-check"},
+		{"list", "--format", "json", "--no-check"},
+		{"list", "--format", "markdown", "--no-check"},
+		{"list", "--format", "completion"},
+		{"list", "--format", "completion", "--check"},
+	} {
+		git.calls = nil
+		cmd := newRootCmd()
+		cmd.SetArgs(args)
+		buf := &bytes.Buffer{}
+		cmd.SetOut(buf)
+		cmd.SetErr(buf)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%s: %v\n%s", strings.Join(args, " "), err, buf)
+		}
+		if len(git.calls) > 0 {
+			t.Fatalf("%s ran git %q", strings.Join(args, " "), git.calls)
+		}
+	}
+
+	// Markdown names the checkout in its header unless checks are off.
+	git.calls = nil
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"list", "--format", "markdown"})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if len(git.calls) == 0 || !strings.Contains(buf.String(), "Checkout: `feature/login@a1b2c3d (dirty)`") {
+		t.Fatalf("expected markdown to name the checkout, ran %q:\n%s", git.calls, buf)
+	}
+}
+
+func TestChecksConfig(t *testing.T) {
+	dir := versionReportRepo(t)
+	probes := countVersionProbes(t)
+	chdir(t, dir)
+
+	write := func(enabled string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, ".testdrive.yml"), []byte("checks:\n  enabled: "+enabled+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	execute := func(args ...string) error {
+		cmd := newRootCmd()
+		cmd.SetArgs(args)
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		return cmd.Execute()
+	}
+
+	write("true")
+	if err := execute("list"); err != nil || probes.Load() == 0 {
+		t.Fatalf("checks.enabled: true should check on list (err %v)", err)
+	}
+	write("false")
+	probes.Store(0)
+	if err := execute("run", "--dry-run"); err != nil || probes.Load() != 0 {
+		t.Fatalf("checks.enabled: false should skip checks on run (err %v, %d probes)", err, probes.Load())
+	}
+	if err := execute("run", "--dry-run", "--check"); err != nil || probes.Load() == 0 {
+		t.Fatalf("--check should override checks.enabled: false (err %v)", err)
+	}
+	write("sometimes")
+	if err := execute("run", "--dry-run"); err == nil || !strings.Contains(err.Error(), `unsupported checks.enabled "sometimes"`) {
+		t.Fatalf("expected an unknown value to be refused, got %v", err)
+	}
+}
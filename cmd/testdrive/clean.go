@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/cleanup"
+	"github.com/spf13/cobra"
+)
+
+func newCleanCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove temp files, worktrees, and state testdrive left behind",
+		Long: fmt.Sprintf(`Clean removes what testdrive leaves behind. --logs, the default, removes
+the %s* temp files and --worktree sandboxes in the system temp directory
+that a killed run never got to remove; entries younger than %s are kept,
+since they may belong to a run still in progress. --history removes the
+run history under %s/history, and --all removes both and everything else
+under %s, such as the baseline.
+
+With --dry-run, clean lists what it would remove and removes nothing. It
+never removes anything outside %s or the system temp directory's %s*
+entries, and refuses a %s that is a link to elsewhere.`,
+			cleanup.TempPrefix, cleanup.MinTempAge, cleanup.StateDir, cleanup.StateDir, cleanup.StateDir, cleanup.TempPrefix, cleanup.StateDir),
+		Args: cobra.NoArgs,
+		RunE: runClean,
+	}
+	cmd.Flags().Bool("logs", false, "remove stale temp files and worktrees (the default)")
+	cmd.Flags().Bool("history", false, "remove the run history")
+	cmd.Flags().Bool("all", false, "remove temp files, worktrees, history, and all other state")
+	cmd.Flags().Bool("no-lock", false, "clean even while a testdrive run holds .testdrive/lock in this repository")
+	return cmd
+}
+
+func runClean(cmd *cobra.Command, _ []string) error {
+	cfg, root, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	logs, err := cmd.Flags().GetBool("logs")
+	if err != nil {
+		return fmt.Errorf("parse --logs: %w", err)
+	}
+	hist, err := cmd.Flags().GetBool("history")
+	if err != nil {
+		return fmt.Errorf("parse --history: %w", err)
+	}
+	all, err := cmd.Flags().GetBool("all")
+	if err != nil {
+		return fmt.Errorf("parse --all: %w", err)
+	}
+	opts := cleanup.Options{
+		Root:    root,
+		Temp:    logs || all || !hist,
+		History: hist || all,
+		State:   all,
+		Now:     time.Now(),
+	}
+
+	// Holding the run lock keeps a run in this repository from writing its
+	// history while it is removed.
+	if opts.History || opts.State {
+		release, err := acquireRunLock(cmd, cfg, root)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	artifacts, err := cleanup.Find(opts)
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	if len(artifacts) == 0 {
+		fmt.Fprintln(out, "Nothing to clean")
+		return nil
+	}
+
+	verb := "removed"
+	if cfg.DryRun {
+		verb = "would remove"
+	}
+	var total int64
+	worktrees := false
+	for _, a := range artifacts {
+		if cfg.DryRun {
+			err = cleanup.Check(opts, a.Path)
+		} else {
+			err = cleanup.Remove(opts, []cleanup.Artifact{a})
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s %s (%s, %s)\n", verb, displayCleanPath(root, a.Path), a.Kind, formatSize(a.Bytes))
+		total += a.Bytes
+		worktrees = worktrees || a.Kind == cleanup.KindWorktree
+	}
+	if worktrees && !cfg.DryRun {
+		// Git still lists the worktrees just removed until they are pruned.
+		if inside, err := gitProbe.Run(root, "rev-parse", "--is-inside-work-tree"); err == nil && inside == "true" {
+			if _, err := gitProbe.Run(root, "worktree", "prune"); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: %v\n", err)
+			}
+		}
+	}
+	if cfg.DryRun {
+		fmt.Fprintf(out, "Would free %s in %d path(s)\n", formatSize(total), len(artifacts))
+	} else {
+		fmt.Fprintf(out, "Freed %s in %d path(s)\n", formatSize(total), len(artifacts))
+	}
+	return nil
+}
+
+// displayCleanPath shows paths under root relative to it.
+func displayCleanPath(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil && filepath.IsLocal(rel) {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// formatSize renders n bytes with a binary unit, e.g. "1.5 MiB".
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
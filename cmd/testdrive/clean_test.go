@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cleanFixture lays out the state and stale temp files of earlier runs in
+// a fresh project and temp directory, and returns both.
+func cleanFixture(t *testing.T) (root, temp string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("sets TMPDIR")
+	}
+	root, temp = t.TempDir(), t.TempDir()
+	t.Setenv("TMPDIR", temp)
+	old := time.Now().Add(-2 * time.Hour)
+	for path, age := range map[string]time.Time{
+		filepath.Join(root, ".testdrive", "history", "runs.jsonl"):      time.Now(),
+		filepath.Join(root, ".testdrive", "baseline.json"):              time.Now(),
+		filepath.Join(temp, "testdrive-run-1", "testdrive-script-2.sh"): old,
+		filepath.Join(temp, "testdrive-step-summary-3.md"):              old,
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, age, age); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(filepath.Join(temp, "testdrive-run-1"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, root)
+	return root, temp
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func TestCleanHistoryDryRun(t *testing.T) {
+	root, _ := cleanFixture(t)
+
+	out, _, err := executeRoot(t, "clean", "--history", "--dry-run")
+	if err != nil {
+		t.Fatalf("clean: %v", err)
+	}
+	want := "would remove .testdrive/history (history, 4 B)\nWould free 4 B in 1 path(s)\n"
+	if out != want {
+		t.Fatalf("output = %q, want %q", out, want)
+	}
+	if !exists(filepath.Join(root, ".testdrive", "history")) {
+		t.Fatal("--dry-run removed the history")
+	}
+
+	if _, _, err := executeRoot(t, "clean", "--history"); err != nil {
+		t.Fatalf("clean: %v", err)
+	}
+	if exists(filepath.Join(root, ".testdrive", "history")) {
+		t.Fatal("history was not removed")
+	}
+	if !exists(filepath.Join(root, ".testdrive", "baseline.json")) {
+		t.Fatal("--history removed the baseline")
+	}
+}
+
+func TestCleanLogsByDefault(t *testing.T) {
+	root, temp := cleanFixture(t)
+	fresh := filepath.Join(temp, "testdrive-run-9")
+	if err := os.Mkdir(fresh, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	out, _, err := executeRoot(t, "clean")
+	if err != nil {
+		t.Fatalf("clean: %v", err)
+	}
+	for _, removed := range []string{"testdrive-run-1", "testdrive-step-summary-3.md"} {
+		if exists(filepath.Join(temp, removed)) {
+			t.Fatalf("%s was not removed", removed)
+		}
+		if !strings.Contains(out, removed+" (temp, ") {
+			t.Fatalf("output does not list %s:\n%s", removed, out)
+		}
+	}
+	if !exists(fresh) {
+		t.Fatal("a temp directory that may belong to a running run was removed")
+	}
+	if !exists(filepath.Join(root, ".testdrive", "history")) {
+		t.Fatal("clean without --history removed the history")
+	}
+
+	if out, _, err := executeRoot(t, "clean"); err != nil || out != "Nothing to clean\n" {
+		t.Fatalf("second clean: out = %q, err = %v", out, err)
+	}
+}
+
+func TestCleanAll(t *testing.T) {
+	root, temp := cleanFixture(t)
+
+	if _, _, err := executeRoot(t, "clean", "--all"); err != nil {
+		t.Fatalf("clean: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(root, ".testdrive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the lock clean held is left.
+	if len(entries) != 1 || entries[0].Name() != "lock" {
+		t.Fatalf(".testdrive holds %v after --all", entries)
+	}
+	if left, _ := os.ReadDir(temp); len(left) != 0 {
+		t.Fatalf("temp directory holds %v after --all", left)
+	}
+}
+
+func TestCleanRefusesLinkedStateDir(t *testing.T) {
+	root, _ := cleanFixture(t)
+	elsewhere := t.TempDir()
+	precious := filepath.Join(elsewhere, "precious.txt")
+	if err := os.WriteFile(precious, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state := filepath.Join(root, ".testdrive")
+	if err := os.RemoveAll(state); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(elsewhere, state); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := executeRoot(t, "clean", "--all", "--no-lock")
+	if err == nil || !strings.Contains(err.Error(), "refusing to remove") {
+		t.Fatalf("err = %v, want a refusal", err)
+	}
+	if !exists(precious) {
+		t.Fatal("a file outside the project was removed")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/compare"
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/bgricker/testdrive/internal/runner"
+	"github.com/spf13/cobra"
+)
+
+func newCompareCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compare",
+		Short: "Compare local step results with a GitHub Actions run",
+		RunE:  runCompare,
+	}
+	cmd.Flags().Int64("run-id", 0, "GitHub Actions run to fetch (uses GITHUB_TOKEN)")
+	cmd.Flags().String("repo", "", "repository as owner/name (default: GITHUB_REPOSITORY or the origin remote)")
+	cmd.Flags().String("from-file", "", "read a saved jobs API payload instead of calling the API")
+	cmd.Flags().String("save", "", "write the fetched jobs payload to a file for later --from-file use")
+	cmd.Flags().String("local", "", "read local results from a saved `run --format json` report instead of running")
+	return cmd
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	cfg, root, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	remote, err := loadRemoteJobs(cmd, root)
+	if err != nil {
+		return err
+	}
+
+	localPath, err := cmd.Flags().GetString("local")
+	if err != nil {
+		return fmt.Errorf("parse --local: %w", err)
+	}
+	var results []report.StepResult
+	if localPath != "" {
+		results, err = readLocalResults(localPath)
+	} else {
+		results, err = runLocalResults(cmd, cfg, root)
+	}
+	if err != nil {
+		return err
+	}
+
+	result := compare.Steps(results, remote)
+
+	switch strings.ToLower(cfg.Format) {
+	case config.FormatPretty:
+		if err := newPretty(cmd, cfg).RenderComparison(result); err != nil {
+			return err
+		}
+	case config.FormatJSON:
+		renderer := output.NewJSON(cmd.OutOrStdout())
+		renderer.Compact = cfg.Compact
+		if err := renderer.Encode(result); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+
+	if len(result.Divergences) > 0 {
+		return fmt.Errorf("%d step(s) diverge from CI", len(result.Divergences))
+	}
+	return nil
+}
+
+// loadRemoteJobs reads the CI run from --from-file or fetches it by --run-id.
+func loadRemoteJobs(cmd *cobra.Command, root string) ([]compare.RemoteJob, error) {
+	fromFile, err := cmd.Flags().GetString("from-file")
+	if err != nil {
+		return nil, fmt.Errorf("parse --from-file: %w", err)
+	}
+	runID, err := cmd.Flags().GetInt64("run-id")
+	if err != nil {
+		return nil, fmt.Errorf("parse --run-id: %w", err)
+	}
+	savePath, err := cmd.Flags().GetString("save")
+	if err != nil {
+		return nil, fmt.Errorf("parse --save: %w", err)
+	}
+
+	switch {
+	case fromFile != "" && runID != 0:
+		return nil, fmt.Errorf("--from-file and --run-id are mutually exclusive")
+	case fromFile != "":
+		data, err := os.ReadFile(fromFile)
+		if err != nil {
+			return nil, fmt.Errorf("read %q: %w", fromFile, err)
+		}
+		return compare.ParseJobs(data)
+	case runID == 0:
+		return nil, fmt.Errorf("specify --run-id or --from-file")
+	}
+
+	repo, err := cmd.Flags().GetString("repo")
+	if err != nil {
+		return nil, fmt.Errorf("parse --repo: %w", err)
+	}
+	if repo == "" {
+		repo, err = detectRepo(root)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client := &compare.Client{BaseURL: os.Getenv("GITHUB_API_URL"), Token: os.Getenv("GITHUB_TOKEN")}
+	jobs, raw, err := client.FetchJobs(context.Background(), repo, runID)
+	if err != nil {
+		return nil, err
+	}
+	if savePath != "" {
+		if err := os.WriteFile(savePath, raw, 0o644); err != nil {
+			return nil, fmt.Errorf("write %q: %w", savePath, err)
+		}
+	}
+	return jobs, nil
+}
+
+func detectRepo(root string) (string, error) {
+	if repo := os.Getenv("GITHUB_REPOSITORY"); repo != "" {
+		return repo, nil
+	}
+	git := exec.Command("git", "remote", "get-url", "origin")
+	git.Dir = root
+	out, err := git.Output()
+	if err != nil {
+		return "", fmt.Errorf("determine repository: pass --repo owner/name")
+	}
+	return compare.RepoFromRemote(string(out))
+}
+
+func readLocalResults(path string) ([]report.StepResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", path, err)
+	}
+	var saved output.Report
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("decode %q: %w", path, err)
+	}
+	return saved.Steps, nil
+}
+
+// runLocalResults executes the filtered pipeline in batch mode. Step output
+// goes to stderr so stdout carries only the comparison.
+func runLocalResults(cmd *cobra.Command, cfg config.Config, root string) ([]report.StepResult, error) {
+	data, err := loadPipeline(root, cfg, debugLog(cmd))
+	if err != nil {
+		return nil, err
+	}
+	filtered, err := applyFilters(data, cfg, debugLog(cmd))
+	if err != nil {
+		return nil, err
+	}
+	checks, err := checksEnabled(cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	if checks {
+		if err := checkRequiredEnv(cfg, filtered); err != nil {
+			return nil, err
+		}
+	}
+	opts := runnerOptions(cmd, cfg, root, filtered.env)
+	opts.Stdout = cmd.ErrOrStderr()
+	results, _, err := runner.New(opts).Run(cmd.Context(), filtered.workflows)
+	if err == nil && cmd.Context().Err() != nil {
+		err = fmt.Errorf("run interrupted: %w", cmd.Context().Err())
+	}
+	return results, err
+}
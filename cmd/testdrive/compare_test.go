@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/compare"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func writeLocalReport(t *testing.T, steps []report.StepResult) string {
+	t.Helper()
+	data, err := json.Marshal(output.Report{Provider: "github", Steps: steps})
+	if err != nil {
+		t.Fatalf("encode report: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "local.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+	return path
+}
+
+func TestCompareCommandOffline(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+	local := writeLocalReport(t, []report.StepResult{
+		{WorkflowName: "CI", JobName: "lint", StepName: "Lint", Status: "passed"},
+		{WorkflowName: "CI", JobName: "integration", StepName: "Integration suite", Status: "passed"},
+	})
+	remote := filepath.Join("testdata", "actions", "run_jobs.json")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"compare", "--from-file", remote, "--local", local})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	err := cmd.Execute()
+	if err == nil || err.Error() != "1 step(s) diverge from CI" {
+		t.Fatalf("expected divergence error, got %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"DIVERGENCES:", "passed-locally-failed-on-ci", "CI / lint", "local passed, CI failure", "COMPARE: 2 step(s) compared, 1 divergence(s)"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"compare", "--from-file", remote, "--local", local, "--format", "json"})
+	buf = &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+	_ = cmd.Execute()
+	var result compare.Result
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("decode output: %v\n%s", err, buf.String())
+	}
+	if result.Compared != 2 || len(result.Divergences) != 1 || result.Divergences[0].Kind != compare.KindPassedLocally {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestCompareCommandRequiresRemote(t *testing.T) {
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"compare", "--local", "report.json"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--run-id or --from-file") {
+		t.Fatalf("expected missing remote error, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/discovery"
+	"github.com/spf13/cobra"
+)
+
+// completeWorkflowFiles suggests the discovered workflow files that are not
+// already on the command line. When none match, the shell falls back to
+// completing any YAML file so workflows outside the workflow directories
+// still work.
+func completeWorkflowFiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	fallback := []string{"yml", "yaml"}
+	root, err := os.Getwd()
+	if err != nil {
+		return fallback, cobra.ShellCompDirectiveFilterFileExt
+	}
+	// A broken config file should not break completion; fall back to the
+	// default directories.
+	cfg, _ := config.Load(root)
+	paths, err := discovery.WorkflowsIn(root, cfg.WorkflowDirs)
+	if err != nil {
+		return fallback, cobra.ShellCompDirectiveFilterFileExt
+	}
+
+	used := make(map[string]bool, len(args))
+	for _, arg := range args {
+		used[filepath.Clean(arg)] = true
+	}
+	var suggestions []string
+	for _, path := range paths {
+		slashed := filepath.ToSlash(path)
+		if used[filepath.Clean(path)] || !strings.HasPrefix(slashed, toComplete) {
+			continue
+		}
+		suggestions = append(suggestions, slashed)
+	}
+	if len(suggestions) == 0 {
+		return fallback, cobra.ShellCompDirectiveFilterFileExt
+	}
+	return suggestions, cobra.ShellCompDirectiveNoFileComp
+}
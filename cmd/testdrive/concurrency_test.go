@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunCommandMaxParallelSerializesConcurrencyGroups(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	root := t.TempDir()
+	deploy := `name: %s
+concurrency:
+  group: deploy-${{ github.ref }}
+  cancel-in-progress: true
+jobs:
+  deploy:
+    steps:
+      - name: Deploy
+        run: mkdir deploy.lock && sleep 0.2 && rmdir deploy.lock
+`
+	files := map[string]string{
+		"deploy_a.yml": strings.Replace(deploy, "%s", "Deploy A", 1),
+		"deploy_b.yml": strings.Replace(deploy, "%s", "Deploy B", 1),
+		"lint.yml":     "name: Lint\njobs:\n  lint:\n    steps:\n      - run: echo lint\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(contents), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	chdir(t, root)
+	t.Setenv("GITHUB_REF", "refs/heads/main")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--workflow", "deploy_a.yml", "--workflow", "deploy_b.yml", "--workflow", "lint.yml", "--max-parallel", "3", "--verbose"})
+	out := &bytes.Buffer{}
+	errBuf := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(errBuf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "SUMMARY: 3 passed, 0 failed, 0 skipped") {
+		t.Fatalf("expected all jobs to pass without overlapping:\n%s", out.String())
+	}
+	want := "info: cancel-in-progress is ignored locally for concurrency group(s): deploy-refs/heads/main"
+	if !strings.Contains(errBuf.String(), want) {
+		t.Fatalf("expected cancel-in-progress note, got:\n%s", errBuf.String())
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Print the effective merged configuration",
+		RunE:  runConfig,
+	}
+	cmd.Flags().Bool("origin", false, "annotate each value with the source that set it")
+	return cmd
+}
+
+func runConfig(cmd *cobra.Command, args []string) error {
+	cfg, _, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	showOrigin, err := cmd.Flags().GetBool("origin")
+	if err != nil {
+		return fmt.Errorf("parse --origin: %w", err)
+	}
+
+	node, err := configNode(cfg)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(cfg.Format) {
+	case config.FormatPretty:
+		if showOrigin {
+			annotateOrigins(node, cfg.Origins)
+		}
+		return writeConfigYAML(cmd.OutOrStdout(), node)
+	case config.FormatJSON:
+		var payload interface{} = cfg
+		if showOrigin {
+			payload = struct {
+				Config  config.Config            `json:"config"`
+				Origins map[string]config.Source `json:"origins"`
+			}{Config: cfg, Origins: leafOrigins(node, cfg.Origins)}
+		}
+		renderer := output.NewJSON(cmd.OutOrStdout())
+		renderer.Compact = cfg.Compact
+		return renderer.Encode(payload)
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+}
+
+func configNode(cfg config.Config) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(cfg); err != nil {
+		return nil, fmt.Errorf("encode config: %w", err)
+	}
+	return &node, nil
+}
+
+func writeConfigYAML(out io.Writer, node *yaml.Node) error {
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+	return enc.Close()
+}
+
+// walkConfigLeaves calls fn for every key whose value is a scalar or sequence,
+// passing the dotted key path alongside the key and value nodes.
+func walkConfigLeaves(node *yaml.Node, prefix string, fn func(path string, key, value *yaml.Node)) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		path := key.Value
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		if value.Kind == yaml.MappingNode {
+			walkConfigLeaves(value, path, fn)
+			continue
+		}
+		fn(path, key, value)
+	}
+}
+
+func annotateOrigins(node *yaml.Node, origins config.Origins) {
+	walkConfigLeaves(node, "", func(path string, key, value *yaml.Node) {
+		comment := string(origins.Of(path))
+		// Block sequences render their comment after the key; scalars and
+		// empty flow sequences carry it after the value.
+		if value.Kind == yaml.SequenceNode && len(value.Content) > 0 {
+			key.LineComment = comment
+			return
+		}
+		value.LineComment = comment
+	})
+}
+
+func leafOrigins(node *yaml.Node, origins config.Origins) map[string]config.Source {
+	out := make(map[string]config.Source)
+	walkConfigLeaves(node, "", func(path string, _, _ *yaml.Node) {
+		out[path] = origins.Of(path)
+	})
+	return out
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigCommandOrigin(t *testing.T) {
+	root := projectRoot(t)
+	tmp := t.TempDir()
+	writeConfigFixture(t, tmp)
+	chdir(t, tmp)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"config", "--origin", "--job", "lint"})
+
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+
+	want := readGolden(t, filepath.Join(root, "testdata", "golden", "config_origin.txt"))
+	if diff := diffStrings(want, buf.String()); diff != "" {
+		t.Fatalf("unexpected output:\n%s", diff)
+	}
+}
+
+func TestConfigCommandOriginJSON(t *testing.T) {
+	root := projectRoot(t)
+	tmp := t.TempDir()
+	writeConfigFixture(t, tmp)
+	chdir(t, tmp)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"config", "--origin", "--format", "json"})
+
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+
+	want := readGolden(t, filepath.Join(root, "testdata", "golden", "config_origin.json"))
+	if diff := diffStrings(want, buf.String()); diff != "" {
+		t.Fatalf("unexpected output:\n%s", diff)
+	}
+}
+
+func writeConfigFixture(t *testing.T, dir string) {
+	t.Helper()
+	configYAML := []byte(`provider: github
+jobs:
+  - test
+skip_step:
+  - Upload artifact
+warn:
+  version_mismatch: false
+`)
+	if err := os.WriteFile(filepath.Join(dir, ".testdrive.yml"), configYAML, 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
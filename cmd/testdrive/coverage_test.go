@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestRunCommandCoverageCounts(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{
+		"run",
+		"--workflow", "testdata/workflows/ci_skips.yml",
+		"--job", "test",
+		"--skip-step", "coverage",
+		"--dry-run",
+		"--format", "json",
+	})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+
+	var decoded output.Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if decoded.Coverage == nil {
+		t.Fatalf("expected coverage section in report")
+	}
+
+	want := map[string]int{
+		report.ReasonUsesStep:     2,
+		report.ReasonFilteredJob:  1,
+		report.ReasonFilteredSkip: 1,
+		report.ReasonPrivileged:   1,
+		report.ReasonDryRun:       2,
+	}
+	if len(decoded.Coverage.Counts) != len(want) {
+		t.Fatalf("unexpected reasons: %v", decoded.Coverage.Counts)
+	}
+	for reason, count := range want {
+		if got := decoded.Coverage.Counts[reason]; got != count {
+			t.Fatalf("count for %s = %d, want %d (all: %v)", reason, got, count, decoded.Coverage.Counts)
+		}
+	}
+	if decoded.Coverage.Executed != 0 || len(decoded.Coverage.Skipped) != 7 {
+		t.Fatalf("expected all 7 steps accounted as skipped, got %+v", decoded.Coverage)
+	}
+}
+
+func TestRunCommandExplainSkips(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{
+		"run",
+		"--workflow", "testdata/workflows/ci_skips.yml",
+		"--only-step", "/Unit|Install/",
+		"--dry-run",
+		"--explain-skips",
+	})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"SKIPPED STEPS: 7 of 7 steps did not run",
+		"  filtered_only (3)",
+		"    Skip Coverage / test / Lint: did not match --only-step",
+		"  privileged (1)",
+		"  uses_step (2)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
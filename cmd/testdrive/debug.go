@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// loggerKey stores the --debug logger in the command's context.
+type loggerKey struct{}
+
+// newDebugLogger returns a logger writing debug events to w as text or JSON
+// lines.
+func newDebugLogger(w io.Writer, format string) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unsupported debug format %q; use text or json", format)
+	}
+}
+
+// setupDebugLogger attaches a logger to cmd's context when --debug is set.
+// Debug lines always go to stderr so they never mix with results on stdout.
+func setupDebugLogger(cmd *cobra.Command, _ []string) error {
+	enabled, err := cmd.Flags().GetBool("debug")
+	if err != nil {
+		return fmt.Errorf("parse --debug: %w", err)
+	}
+	format, err := cmd.Flags().GetString("debug-format")
+	if err != nil {
+		return fmt.Errorf("parse --debug-format: %w", err)
+	}
+	logger, err := newDebugLogger(cmd.ErrOrStderr(), format)
+	if err != nil || !enabled {
+		return err
+	}
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cmd.SetContext(context.WithValue(ctx, loggerKey{}, logger))
+	return nil
+}
+
+// debugLog returns the --debug logger, or one that discards everything.
+func debugLog(cmd *cobra.Command) *slog.Logger {
+	if ctx := cmd.Context(); ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+// debugEnabled reports whether --debug is on.
+func debugEnabled(cmd *cobra.Command) bool {
+	return debugLog(cmd).Enabled(context.Background(), slog.LevelDebug)
+}
+
+// logConfigOrigins records which source supplied each configured key.
+func logConfigOrigins(log *slog.Logger, cfg config.Config) {
+	keys := make([]string, 0, len(cfg.Origins))
+	for key := range cfg.Origins {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		log.Debug("config value", "key", key, "source", string(cfg.Origins.Of(key)))
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// debugFiles is a workflow with a step for each of the decisions --debug
+// logs: one filtered out, one skipped as privileged, and one run.
+var debugFiles = map[string]string{
+	".github/workflows/ci.yml": `name: CI
+jobs:
+  test:
+    steps:
+      - uses: actions/checkout@v4
+      - name: Lint
+        run: echo lint
+      - name: Install
+        run: sudo apt-get install -y jq
+      - name: Test
+        run: echo test
+`,
+	".testdrive.yml": "tail_lines: 5\n",
+}
+
+func TestRunCommandDebugLogsDecisions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	chdir(t, writeFiles(t, debugFiles))
+
+	out, stderr, err := executeRoot(t, "run", "--skip-step", "Lint", "--debug")
+	if err != nil {
+		t.Fatalf("command execute: %v\n%s", err, stderr)
+	}
+	for _, want := range []string{
+		`msg="config value" key=tail_lines source=config`,
+		`msg="config value" key=skip_step source=flag`,
+		`msg="workflow discovered" path=.github/workflows/ci.yml explicit=false`,
+		`msg="filter dropped step" workflow=.github/workflows/ci.yml job=test step=Lint reason=filtered_skip detail="matched --skip-step \"Lint\""`,
+		`msg="filter kept step" workflow=.github/workflows/ci.yml job=test step=Test`,
+		`msg="step skipped" workflow=.github/workflows/ci.yml job=test step=Install reason=privileged`,
+		`msg="step command resolved" workflow=.github/workflows/ci.yml job=test step=Test shell=bash`,
+		`msg="renderer selected" format=pretty streaming=false`,
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("expected debug output to contain %s, got:\n%s", want, stderr)
+		}
+	}
+	if strings.Contains(out, "msg=") {
+		t.Fatalf("expected debug lines on stderr only, got stdout:\n%s", out)
+	}
+
+	_, stderr, err = executeRoot(t, "run", "--skip-step", "Lint")
+	if err != nil {
+		t.Fatalf("command execute: %v\n%s", err, stderr)
+	}
+	if strings.Contains(stderr, "msg=") {
+		t.Fatalf("expected no debug output without --debug, got:\n%s", stderr)
+	}
+}
+
+func TestRunCommandDebugJSON(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	chdir(t, writeFiles(t, debugFiles))
+
+	_, stderr, err := executeRoot(t, "run", "--skip-step", "Lint", "--debug", "--debug-format", "json", "--format", "json")
+	if err != nil {
+		t.Fatalf("command execute: %v\n%s", err, stderr)
+	}
+	events := map[string]int{}
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		var event struct {
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("expected a JSON debug line, got %q: %v", line, err)
+		}
+		if event.Level != "DEBUG" {
+			t.Fatalf("expected debug level, got %q", line)
+		}
+		events[event.Msg]++
+	}
+	if events["workflow discovered"] != 1 || events["filter dropped step"] != 2 || events["step command resolved"] != 1 {
+		t.Fatalf("unexpected debug events: %v", events)
+	}
+
+	if _, _, err := executeRoot(t, "run", "--debug", "--debug-format", "yaml"); err == nil || err.Error() != `unsupported debug format "yaml"; use text or json` {
+		t.Fatalf("expected a debug format error, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunCommandSkipsDestructiveSteps(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	chdir(t, writeFiles(t, map[string]string{
+		".github/workflows/ops.yml": "name: Ops\njobs:\n  ops:\n    steps:\n      - name: Wipe\n        run: echo wipe\n",
+		".testdrive.yml":            "destructive_command_patterns:\n  - '\\bwipe\\b'\n",
+	}))
+
+	out, stderr, err := executeRoot(t, "run", "--format", "json")
+	if err != nil {
+		t.Fatalf("command execute: %v\n%s", err, stderr)
+	}
+	if !strings.Contains(out, `"skip_reason": "destructive"`) || strings.Contains(out, `"status": "passed"`) {
+		t.Fatalf("expected the step to be skipped as destructive:\n%s", out)
+	}
+
+	out, stderr, err = executeRoot(t, "run", "--format", "json", "--allow-destructive")
+	if err != nil {
+		t.Fatalf("command execute: %v\n%s", err, stderr)
+	}
+	if !strings.Contains(out, `"status": "passed"`) {
+		t.Fatalf("expected --allow-destructive to run the step:\n%s", out)
+	}
+}
+
+func TestRunCommandConfirmsDestructiveStepsInteractively(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	chdir(t, writeFiles(t, map[string]string{
+		".github/workflows/ops.yml": "name: Ops\njobs:\n  ops:\n    steps:\n      - name: Wipe\n        run: echo wipe\n",
+		".testdrive.yml":            "destructive_command_patterns:\n  - '\\bwipe\\b'\n",
+	}))
+
+	usePrompter(t, "", "y")
+	out, stderr, err := executeRoot(t, "run", "--format", "json", "-i")
+	if err != nil {
+		t.Fatalf("command execute: %v\n%s", err, stderr)
+	}
+	if !strings.Contains(out, `"status": "passed"`) {
+		t.Fatalf("expected the confirmed step to run:\n%s", out)
+	}
+	if !strings.Contains(stderr, ".github/workflows/ops.yml / ops / Wipe: skipped destructive command matching pattern") {
+		t.Fatalf("expected the step to be named before asking, got:\n%s", stderr)
+	}
+
+	usePrompter(t, "", "")
+	out, stderr, err = executeRoot(t, "run", "--format", "json", "-i")
+	if err != nil {
+		t.Fatalf("command execute: %v\n%s", err, stderr)
+	}
+	if !strings.Contains(out, `"skip_reason": "destructive"`) {
+		t.Fatalf("expected the step to stay skipped without a yes:\n%s", out)
+	}
+}
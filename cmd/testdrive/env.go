@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/envcheck"
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// loadEnvFile reads cfg.EnvFile relative to root. It returns nil when no
+// file is configured.
+func loadEnvFile(root string, cfg config.Config) (map[string]string, error) {
+	path := strings.TrimSpace(cfg.EnvFile)
+	if path == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	values, err := envcheck.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("env_file: %w", err)
+	}
+	return values, nil
+}
+
+// loadVars returns the repository variables `${{ vars.NAME }}` resolves
+// to: the vars config map with cfg.VarsFile, read relative to root, layered
+// on top.
+func loadVars(root string, cfg config.Config) (map[string]string, error) {
+	vars := make(map[string]string, len(cfg.Vars))
+	for name, value := range cfg.Vars {
+		vars[name] = value
+	}
+	path := strings.TrimSpace(cfg.VarsFile)
+	if path == "" {
+		return vars, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	values, err := envcheck.ReadVarsFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("vars_file: %w", err)
+	}
+	for name, value := range values {
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// varsWarning reports the repository variables that resolved to an empty
+// string because nothing set them. It returns nil when none are missing.
+func varsWarning(missing []string) []provider.Warning {
+	if len(missing) == 0 {
+		return nil
+	}
+	return []provider.Warning{{
+		Kind:     provider.WarnVarsMissing,
+		Workflow: config.FileName,
+		Message:  fmt.Sprintf("vars not set, resolved to empty: %s; add them to vars or --vars-file", strings.Join(missing, ", ")),
+	}}
+}
+
+// envLookup resolves variables from the env file first and the process
+// environment second, matching what steps will see.
+func envLookup(fileEnv map[string]string) envcheck.Lookup {
+	return func(key string) (string, bool) {
+		if value, ok := fileEnv[key]; ok {
+			return value, true
+		}
+		return os.LookupEnv(key)
+	}
+}
+
+// stepEnvironment is the base environment for steps: the process
+// environment with the env file layered on top. It returns nil without an
+// env file so the runner falls back to os.Environ itself.
+func stepEnvironment(fileEnv map[string]string) []string {
+	if len(fileEnv) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(fileEnv))
+	for key := range fileEnv {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	env := os.Environ()
+	for _, key := range keys {
+		env = append(env, key+"="+fileEnv[key])
+	}
+	return env
+}
+
+// checkRequiredEnv fails with every missing required_env variable at once so
+// a run never starts only to die minutes later on an unset secret.
+func checkRequiredEnv(cfg config.Config, data pipelineData) error {
+	missing, err := envcheck.CheckRequired(cfg.RequiredEnv, data.workflows, envLookup(data.env))
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d required environment variable(s) not set:\n", len(missing))
+	for _, m := range missing {
+		if len(m.Jobs) > 0 {
+			fmt.Fprintf(&b, "  %s (job: %s)\n", m.Key, strings.Join(m.Jobs, ", "))
+			continue
+		}
+		fmt.Fprintf(&b, "  %s\n", m.Key)
+	}
+	b.WriteString("export them or pass --env-file <path> with KEY=VALUE lines")
+	return fmt.Errorf("%s", b.String())
+}
+
+// envWarnings turns the heuristic script scan into warnings, one per job.
+func envWarnings(workflows []provider.Workflow, fileEnv map[string]string) []provider.Warning {
+	var warnings []provider.Warning
+	for _, f := range envcheck.Scan(workflows, envLookup(fileEnv)) {
+		warnings = append(warnings, provider.Warning{
+			Kind:     provider.WarnEnvPossiblyMissing,
+			Workflow: f.Workflow,
+			Job:      f.Job,
+			Message:  fmt.Sprintf("possibly missing env: %s", strings.Join(f.Names, ", ")),
+		})
+	}
+	return warnings
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const requiredEnvWorkflow = `name: Deploy
+jobs:
+  deploy:
+    steps:
+      - name: Charge
+        run: echo "$TESTDRIVE_FIXTURE_STRIPE_KEY" > charged.txt
+      - name: Publish
+        run: echo "${{ secrets.TESTDRIVE_FIXTURE_NPM_TOKEN }} $TESTDRIVE_FIXTURE_REGISTRY"
+`
+
+func writeEnvFixture(t *testing.T, config string) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"ci.yml":         requiredEnvWorkflow,
+		".testdrive.yml": config,
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(contents), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	chdir(t, root)
+	return root
+}
+
+func TestRunCommandRequiredEnvFailsFast(t *testing.T) {
+	root := writeEnvFixture(t, `required_env:
+  - job: deploy
+    keys: [TESTDRIVE_FIXTURE_STRIPE_KEY]
+  - TESTDRIVE_FIXTURE_DB_URL
+  - job: lint
+    keys: [TESTDRIVE_FIXTURE_UNUSED]
+`)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--workflow", "ci.yml"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	err := cmd.Execute()
+	if err == nil {
+		t.Fatalf("expected missing env error")
+	}
+	want := "2 required environment variable(s) not set:\n" +
+		"  TESTDRIVE_FIXTURE_STRIPE_KEY (job: deploy)\n" +
+		"  TESTDRIVE_FIXTURE_DB_URL\n" +
+		"export them or pass --env-file <path> with KEY=VALUE lines"
+	if err.Error() != want {
+		t.Fatalf("unexpected error:\n%s\nwant:\n%s", err, want)
+	}
+	if _, statErr := os.Stat(filepath.Join(root, "charged.txt")); !os.IsNotExist(statErr) {
+		t.Fatalf("no step should run before the env check passes")
+	}
+}
+
+func TestRunCommandEnvFileSatisfiesRequiredEnv(t *testing.T) {
+	root := writeEnvFixture(t, `required_env:
+  - TESTDRIVE_FIXTURE_STRIPE_KEY
+`)
+	if err := os.WriteFile(filepath.Join(root, "local.env"), []byte("TESTDRIVE_FIXTURE_STRIPE_KEY=sk_test_42\n"), 0o644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--workflow", "ci.yml", "--env-file", "local.env", "--only-step", "Charge"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "charged.txt"))
+	if err != nil {
+		t.Fatalf("read step output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "sk_test_42" {
+		t.Fatalf("env file value not passed to step, got %q", data)
+	}
+}
+
+func TestListCommandCheckEnvWarnings(t *testing.T) {
+	writeEnvFixture(t, "")
+
+	run := func(args ...string) string {
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"list", "--workflow", "ci.yml"}, args...))
+		errBuf := &bytes.Buffer{}
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(errBuf)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("command execute: %v", err)
+		}
+		return errBuf.String()
+	}
+
+	if got := run("--check"); strings.Contains(got, "possibly missing env") {
+		t.Fatalf("scan should only run with --check-env:\n%s", got)
+	}
+	want := "possibly missing env: $TESTDRIVE_FIXTURE_REGISTRY, $TESTDRIVE_FIXTURE_STRIPE_KEY, secrets.TESTDRIVE_FIXTURE_NPM_TOKEN"
+	if got := run("--check", "--check-env"); !strings.Contains(got, want) {
+		t.Fatalf("expected env warning %q, got:\n%s", want, got)
+	}
+	if got := run("--check-env"); strings.Contains(got, "possibly missing env") {
+		t.Fatalf("list should only scan with --check:\n%s", got)
+	}
+	if got := run("--check", "--check-env", "--suppress", "env_possibly_missing"); strings.Contains(got, "possibly missing env") {
+		t.Fatalf("warning should be suppressible:\n%s", got)
+	}
+}
+
+func TestRunCommandResolvesVars(t *testing.T) {
+	root := writeEnvFixture(t, `vars:
+  THREADS: "2"
+  HOST: from-config
+vars_file: vars.yml
+`)
+	files := map[string]string{
+		"vars.yml": "HOST: from-file\n",
+		"vars-ci.yml": `name: Vars
+jobs:
+  test:
+    env:
+      POOL: ${{ vars.THREADS }}
+    steps:
+      - name: Write
+        run: echo "$POOL-${{ vars.HOST }}-${{vars.UNSET}}${{ vars.UNSET }}" > vars.txt
+`,
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(contents), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--workflow", "vars-ci.yml"})
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "vars.txt"))
+	if err != nil {
+		t.Fatalf("read step output: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "2-from-file-" {
+		t.Fatalf("vars resolved to %q, want %q", got, "2-from-file-")
+	}
+	if got := strings.Count(out.String(), "UNSET"); got != 1 {
+		t.Fatalf("expected UNSET named once in the output, got %d:\n%s", got, out)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/patterns"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/provider/filter"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/bgricker/testdrive/internal/resolve"
+	"github.com/bgricker/testdrive/internal/runner"
+	"github.com/spf13/cobra"
+)
+
+func newExplainCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain [step-pattern...]",
+		Short: "Show how matching steps would run and which rules would skip them",
+		Long: `Explain prints, for each matching run step, the script, the shell argv, the
+working directory, the environment changes relative to the host, and the
+first rule (filter, override, environment, or privileged pattern) that would
+keep it from running.
+
+Step patterns select steps by name or script (substring or /regex/); the
+configured filters are then reported as skip rules. Without patterns, steps
+are selected by --job and --only-step.`,
+		RunE: runExplain,
+	}
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	cfg, root, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	data, err := loadPipeline(root, cfg, debugLog(cmd))
+	if err != nil {
+		return err
+	}
+	// Filtering supplies the warnings and env file; explain itself walks the
+	// unfiltered workflows so dropped steps can be attributed.
+	filtered, err := applyFilters(data, cfg, debugLog(cmd))
+	if err != nil {
+		return err
+	}
+	checks, err := checksEnabled(cfg, true)
+	if err != nil {
+		return err
+	}
+	if checks {
+		if filtered, err = applyChecks(cfg, filtered); err != nil {
+			return err
+		}
+	}
+
+	explanations, err := explainSteps(cfg, root, data.workflows, filtered, args, os.Environ())
+	if err != nil {
+		return err
+	}
+	if len(explanations) == 0 {
+		return fmt.Errorf("no run steps match")
+	}
+
+	switch strings.ToLower(cfg.Format) {
+	case config.FormatPretty:
+		return newPretty(cmd, cfg).RenderExplanations(explanations)
+	case config.FormatJSON:
+		renderer := output.NewJSON(cmd.OutOrStdout())
+		renderer.Compact = cfg.Compact
+		return renderer.Encode(explanations)
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+}
+
+// explainSteps builds an explanation for every selected run step in
+// workflows. Step patterns in args select steps directly and leave every
+// configured filter to be reported as a skip rule; without them the job and
+// only-step filters do the selecting.
+func explainSteps(cfg config.Config, root string, workflows []provider.Workflow, filtered pipelineData, args []string, host []string) ([]report.Explanation, error) {
+	jobPatterns, err := filter.Compile(cfg.Jobs)
+	if err != nil {
+		return nil, err
+	}
+	onlyPatterns, err := filter.Compile(cfg.OnlySteps)
+	if err != nil {
+		return nil, err
+	}
+	skipPatterns, err := filter.Compile(cfg.SkipSteps)
+	if err != nil {
+		return nil, err
+	}
+	selectPatterns, err := filter.Compile(args)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := compileOverrides(cfg.Overrides)
+	if err != nil {
+		return nil, err
+	}
+	shells, err := newShellFlags(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := resolve.NewPathMap(cfg.PathMappings, root)
+
+	skipOpts := resolve.SkipOptions{
+		AllowPrivileged:     os.Getenv("TESTDRIVE_ALLOW_PRIVILEGED") == "1",
+		PrivilegedPatterns:  patterns.Exprs(effectivePatterns(cfg, patterns.Privileged)),
+		AllowedEnvironments: cfg.AllowedEnvironments,
+		AllowDestructive:    cfg.AllowDestructive,
+		DestructivePatterns: patterns.Exprs(effectivePatterns(cfg, patterns.Destructive)),
+	}
+
+	var explanations []report.Explanation
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			for _, step := range job.Steps {
+				if step.Run == "" {
+					continue
+				}
+				reason, detail, dropped := filter.DropReason(job, step, jobPatterns, onlyPatterns, skipPatterns)
+				if len(selectPatterns) > 0 {
+					if !filter.MatchStep(step, selectPatterns) {
+						continue
+					}
+				} else if dropped && (reason == report.ReasonFilteredJob || reason == report.ReasonFilteredOnly) {
+					continue
+				}
+
+				effective, labels := filter.ApplyToStep(job, step, overrides)
+				if shells.matches(effective) {
+					if effective, err = shells.apply(wf, job, effective); err != nil {
+						return nil, err
+					}
+					labels = append(labels, shells.labels()...)
+				}
+				exp := explainStep(root, paths, cfg.AutoPath, wf, job, step, effective, filtered.env, host)
+				exp.Overrides = labels
+				if dropped {
+					exp.Skip = &report.SkipRule{Reason: reason, Detail: detail}
+				} else if reason, detail, skip := resolve.Skip(job, effective, withStepEnv(skipOpts, root, wf, job, effective, filtered.env, host)); skip {
+					exp.Skip = &report.SkipRule{Reason: reason, Detail: detail}
+				}
+				exp.Warnings = stepWarnings(filtered.warnings, wf, job)
+				explanations = append(explanations, exp)
+			}
+		}
+	}
+	return explanations, nil
+}
+
+// explainStep resolves the command, directory, and environment for step as
+// the runner would. original is the step before overrides so their env and
+// shell can be attributed separately.
+func explainStep(root string, paths resolve.PathMap, autoPath bool, wf provider.Workflow, job provider.Job, original, step provider.Step, fileEnv map[string]string, host []string) report.Explanation {
+	exp := report.Explanation{
+		WorkflowPath: wf.Path,
+		WorkflowName: wf.Name,
+		JobName:      job.Name,
+		StepName:     original.Name,
+		Run:          step.Run,
+	}
+
+	overrideEnv := make(map[string]string)
+	for key, value := range step.Env {
+		if prev, ok := original.Env[key]; !ok || prev != value {
+			overrideEnv[key] = value
+		}
+	}
+	runnerEnv := map[string]string{
+		"GITHUB_WORKSPACE":    root,
+		runner.StepSummaryEnv: "(temporary file per job)",
+	}
+	layers := []resolve.EnvLayer{
+		{Level: resolve.LevelWorkflow, Vars: wf.Env},
+		{Level: resolve.LevelJob, Vars: job.Env},
+		{Level: resolve.LevelStep, Vars: original.Env},
+		{Level: resolve.LevelOverride, Vars: overrideEnv},
+	}
+	for i, layer := range layers {
+		vars, mapped := paths.MapVars(layer.Vars)
+		for _, key := range mapped {
+			exp.Mappings = append(exp.Mappings, fmt.Sprintf("env %s: %s -> %s", key, layer.Vars[key], vars[key]))
+		}
+		layers[i].Vars = vars
+	}
+	exp.Env = resolve.EnvDiff(host, append([]resolve.EnvLayer{
+		{Level: resolve.LevelEnvFile, Vars: fileEnv},
+		{Level: resolve.LevelRunner, Vars: runnerEnv},
+	}, layers...)...)
+
+	shell, shellLevel := resolve.Shell(wf, job, step)
+	if step.Shell != original.Shell {
+		shellLevel = resolve.LevelOverride
+	}
+	exp.Shell, exp.ShellSource = shell, string(shellLevel)
+	resolver := resolve.NewResolver(nil)
+	resolver.Paths = paths
+	env := resolve.MergeEnv(host, fileEnv, runnerEnv, layers[0].Vars, layers[1].Vars, layers[2].Vars, layers[3].Vars)
+	argv, err := resolver.Command(wf, job, step, env)
+	if err != nil {
+		exp.Error = err.Error()
+	}
+	exp.Argv = argv
+
+	if raw, _ := resolve.RawWorkingDirectory(wf, job, step); raw != "" {
+		if mapped, _, ok := paths.Apply(raw); ok {
+			exp.Mappings = append(exp.Mappings, fmt.Sprintf("working-directory: %s -> %s", raw, mapped))
+		}
+	}
+	dir, dirLevel, err := resolver.WorkingDirectory(root, wf, job, step)
+	exp.WorkingDir, exp.WorkingDirSource = dir, string(dirLevel)
+	if err != nil && exp.Error == "" {
+		exp.Error = err.Error()
+	}
+	if autoPath && err == nil {
+		_, exp.AutoPath = resolve.PrependPath(env, resolver.ProjectBinDirs(root, dir))
+		if export := resolve.PathExport(shell, exp.AutoPath); export != "" && exp.Error == "" {
+			withPath := step
+			withPath.Run = export + step.Run
+			exp.Argv, _ = resolver.Command(wf, job, withPath, env)
+		}
+	}
+	return exp
+}
+
+// withStepEnv sets opts.Env to the environment step would run with.
+func withStepEnv(opts resolve.SkipOptions, root string, wf provider.Workflow, job provider.Job, step provider.Step, fileEnv map[string]string, host []string) resolve.SkipOptions {
+	opts.Env = resolve.MergeEnv(host, fileEnv, map[string]string{"GITHUB_WORKSPACE": root}, wf.Env, job.Env, step.Env)
+	return opts
+}
+
+// stepWarnings returns the messages of warnings raised for the step's
+// workflow as a whole or for its job.
+func stepWarnings(warnings []provider.Warning, wf provider.Workflow, job provider.Job) []string {
+	var out []string
+	for _, w := range warnings {
+		if w.Workflow != wf.Path || (w.Job != "" && w.Job != job.RawID) {
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s: %s", w.Kind, w.Message))
+	}
+	return out
+}
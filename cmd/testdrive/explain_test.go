@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+const explainWorkflow = `name: CI
+env:
+  RAILS_ENV: test
+jobs:
+  test:
+    name: Test
+    env:
+      DATABASE_URL: postgres://localhost/test
+    steps:
+      - name: Install packages
+        run: sudo apt-get install -y libpq-dev
+      - name: Run specs
+        run: bundle exec rspec
+        env:
+          RAILS_ENV: ci
+      - name: Upload coverage
+        run: codecov
+      - name: Lint
+        run: bundle exec rubocop
+  deploy:
+    name: Deploy
+    environment: production
+    steps:
+      - name: Ship it
+        run: ./deploy.sh
+`
+
+func explainFixture(t *testing.T, config string) {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"ci.yml":         explainWorkflow,
+		".testdrive.yml": config,
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(contents), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	chdir(t, root)
+	// Keep a locally installed asdf out of the printed argv.
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("ASDF_DIR", "")
+	t.Setenv("TESTDRIVE_ALLOW_PRIVILEGED", "")
+}
+
+func explainJSON(t *testing.T, args ...string) []report.Explanation {
+	t.Helper()
+	cmd := newRootCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs(append([]string{"explain", "--workflow", "ci.yml", "--format", "json"}, args...))
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	var exps []report.Explanation
+	if err := json.Unmarshal(out.Bytes(), &exps); err != nil {
+		t.Fatalf("decode: %v\n%s", err, out.String())
+	}
+	return exps
+}
+
+func TestExplainAttributesSkips(t *testing.T) {
+	explainFixture(t, `skip_step:
+  - Upload
+overrides:
+  - step: rubocop
+    skip: true
+`)
+	exps := explainJSON(t)
+
+	want := map[string]string{
+		"Install packages": report.ReasonPrivileged,
+		"Run specs":        "",
+		"Upload coverage":  report.ReasonFilteredSkip,
+		"Lint":             report.ReasonOverride,
+		"Ship it":          report.ReasonEnvironment,
+	}
+	if len(exps) != len(want) {
+		t.Fatalf("expected %d explanations, got %d", len(want), len(exps))
+	}
+	for _, exp := range exps {
+		got := ""
+		if exp.Skip != nil {
+			got = exp.Skip.Reason
+		}
+		if got != want[exp.StepName] {
+			t.Errorf("%s: skip reason %q, want %q", exp.StepName, got, want[exp.StepName])
+		}
+	}
+}
+
+func TestExplainReportsEnvAndCommand(t *testing.T) {
+	explainFixture(t, `overrides:
+  - step: rspec
+    env:
+      COVERAGE: "1"
+`)
+	exps := explainJSON(t, "rspec")
+	if len(exps) != 1 {
+		t.Fatalf("expected only the selected step, got %d", len(exps))
+	}
+	exp := exps[0]
+
+	levels := make(map[string]string)
+	for _, change := range exp.Env {
+		levels[change.Key+"="+change.Value] = change.Level
+	}
+	for kv, level := range map[string]string{
+		"RAILS_ENV=ci":                           "step",
+		"DATABASE_URL=postgres://localhost/test": "job",
+		"COVERAGE=1":                             "override",
+	} {
+		if levels[kv] != level {
+			t.Errorf("%s: level %q, want %q (env %+v)", kv, levels[kv], level, exp.Env)
+		}
+	}
+	if len(exp.Overrides) != 1 {
+		t.Errorf("expected one override label, got %q", exp.Overrides)
+	}
+	if len(exp.Argv) == 0 || !strings.HasSuffix(exp.Argv[len(exp.Argv)-1], "bundle exec rspec") {
+		t.Errorf("unexpected argv %q", exp.Argv)
+	}
+	if exp.ShellSource != "default" || exp.WorkingDirSource != "default" {
+		t.Errorf("unexpected sources: shell %q, cwd %q", exp.ShellSource, exp.WorkingDirSource)
+	}
+}
+
+func TestExplainSelectsByOnlyStepWithoutPatterns(t *testing.T) {
+	explainFixture(t, "")
+	exps := explainJSON(t, "--job", "test", "--only-step", "Lint")
+	if len(exps) != 1 || exps[0].StepName != "Lint" {
+		t.Fatalf("expected only Lint, got %+v", exps)
+	}
+
+	// A positional pattern reports the --only-step filter instead of hiding
+	// the step behind it.
+	exps = explainJSON(t, "--only-step", "Lint", "rspec")
+	if len(exps) != 1 || exps[0].Skip == nil || exps[0].Skip.Reason != report.ReasonFilteredOnly {
+		t.Fatalf("expected rspec reported as filtered_only, got %+v", exps)
+	}
+}
+
+func TestExplainPrettyOutput(t *testing.T) {
+	explainFixture(t, "")
+	cmd := newRootCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"explain", "--workflow", "ci.yml", "Ship it"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	for _, want := range []string{
+		"CI (ci.yml) / Deploy / Ship it",
+		"  shell: platform default (default)",
+		"  skip: targets environment 'production'; pass --allow-environment production to run (environment)",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestExplainNoMatch(t *testing.T) {
+	explainFixture(t, "")
+	cmd := newRootCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"explain", "--workflow", "ci.yml", "nothing-like-this"})
+	if err := cmd.Execute(); err == nil || err.Error() != "no run steps match" {
+		t.Fatalf("expected no-match error, got %v", err)
+	}
+}
+
+func TestExplainReportsPathMappings(t *testing.T) {
+	explainFixture(t, `path_mappings:
+  /home/runner/work/app/app: .
+overrides:
+  - job: test
+    shell: sh
+`)
+	workflow := `name: CI
+jobs:
+  test:
+    defaults:
+      run:
+        shell: bash
+        working-directory: /home/runner/work/app/app/backend
+    steps:
+      - name: Build
+        run: make
+        env:
+          APP_DIR: /home/runner/work/app/app/backend/tmp
+      - name: Elsewhere
+        run: make
+        working-directory: /opt/app
+`
+	if err := os.WriteFile("ci.yml", []byte(workflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	if err := os.Mkdir("backend", 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	exps := explainJSON(t)
+	if len(exps) != 2 {
+		t.Fatalf("expected two explanations, got %+v", exps)
+	}
+
+	build := exps[0]
+	backend := filepath.Join(root, "backend")
+	if build.WorkingDir != backend || build.Error != "" {
+		t.Fatalf("expected the mapped directory %s, got %q (%s)", backend, build.WorkingDir, build.Error)
+	}
+	if build.Shell != "sh" || build.ShellSource != "override" {
+		t.Errorf("expected the override's shell, got %q (%s)", build.Shell, build.ShellSource)
+	}
+	wantMappings := []string{
+		"env APP_DIR: /home/runner/work/app/app/backend/tmp -> " + filepath.Join(backend, "tmp"),
+		"working-directory: /home/runner/work/app/app/backend -> " + backend,
+	}
+	if strings.Join(build.Mappings, "\n") != strings.Join(wantMappings, "\n") {
+		t.Errorf("mappings = %q, want %q", build.Mappings, wantMappings)
+	}
+
+	// Absolute paths no mapping covers still fail as before.
+	if other := exps[1]; other.Error != `working directory "/opt/app" not found` || len(other.Mappings) != 0 {
+		t.Errorf("expected the unmapped directory to fail, got %+v", other)
+	}
+}
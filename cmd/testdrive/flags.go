@@ -27,12 +27,39 @@ func gatherFlags(cmd *cobra.Command) (config.FlagValues, error) {
 		values.Workflows = config.SliceFlag{Values: append([]string{}, v...)}
 	}
 
-	if flags.Changed("job") {
-		v, err := flags.GetStringArray("job")
+	if flags.Changed("skip-workflow") {
+		v, err := flags.GetStringArray("skip-workflow")
 		if err != nil {
-			return values, fmt.Errorf("parse --job: %w", err)
+			return values, fmt.Errorf("parse --skip-workflow: %w", err)
 		}
-		values.Jobs = config.SliceFlag{Values: append([]string{}, v...)}
+		values.ExcludeWorkflows = config.SliceFlag{Values: append([]string{}, v...)}
+	}
+
+	if flags.Changed("workflow-ref") {
+		v, err := flags.GetStringArray("workflow-ref")
+		if err != nil {
+			return values, fmt.Errorf("parse --workflow-ref: %w", err)
+		}
+		values.WorkflowRefs = config.SliceFlag{Values: append([]string{}, v...)}
+	}
+
+	if flags.Changed("workflow-url") {
+		v, err := flags.GetStringArray("workflow-url")
+		if err != nil {
+			return values, fmt.Errorf("parse --workflow-url: %w", err)
+		}
+		values.WorkflowURLs = config.SliceFlag{Values: append([]string{}, v...)}
+	}
+
+	for _, name := range []string{"job", "only-job"} {
+		if !flags.Changed(name) {
+			continue
+		}
+		v, err := flags.GetStringArray(name)
+		if err != nil {
+			return values, fmt.Errorf("parse --%s: %w", name, err)
+		}
+		values.Jobs = config.SliceFlag{Values: append(values.Jobs.Values, v...)}
 	}
 
 	if flags.Changed("only-step") {
@@ -51,6 +78,22 @@ func gatherFlags(cmd *cobra.Command) (config.FlagValues, error) {
 		values.SkipSteps = config.SliceFlag{Values: append([]string{}, v...)}
 	}
 
+	if flags.Changed("allow-environment") {
+		v, err := flags.GetStringArray("allow-environment")
+		if err != nil {
+			return values, fmt.Errorf("parse --allow-environment: %w", err)
+		}
+		values.AllowedEnvironments = config.SliceFlag{Values: append([]string{}, v...)}
+	}
+
+	if flags.Changed("suppress") {
+		v, err := flags.GetStringArray("suppress")
+		if err != nil {
+			return values, fmt.Errorf("parse --suppress: %w", err)
+		}
+		values.SuppressWarnings = config.SliceFlag{Values: append([]string{}, v...)}
+	}
+
 	if flags.Changed("format") {
 		v, err := flags.GetString("format")
 		if err != nil {
@@ -59,6 +102,103 @@ func gatherFlags(cmd *cobra.Command) (config.FlagValues, error) {
 		values.Format = config.StringFlag{Value: v, Set: true}
 	}
 
+	if flags.Changed("pager") {
+		v, err := flags.GetBool("pager")
+		if err != nil {
+			return values, fmt.Errorf("parse --pager: %w", err)
+		}
+		values.Pager = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("streaming") && flags.Changed("no-streaming") {
+		return values, fmt.Errorf("--streaming and --no-streaming are mutually exclusive")
+	}
+	for _, name := range []string{"streaming", "no-streaming"} {
+		if !flags.Changed(name) {
+			continue
+		}
+		v, err := flags.GetBool(name)
+		if err != nil {
+			return values, fmt.Errorf("parse --%s: %w", name, err)
+		}
+		if name == "no-streaming" {
+			v = !v
+		}
+		values.Streaming = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("check") && flags.Changed("no-check") {
+		return values, fmt.Errorf("--check and --no-check are mutually exclusive")
+	}
+	for _, name := range []string{"check", "no-check"} {
+		if !flags.Changed(name) {
+			continue
+		}
+		v, err := flags.GetBool(name)
+		if err != nil {
+			return values, fmt.Errorf("parse --%s: %w", name, err)
+		}
+		if name == "no-check" {
+			v = !v
+		}
+		values.Checks = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("trace-shell") {
+		v, err := flags.GetBool("trace-shell")
+		if err != nil {
+			return values, fmt.Errorf("parse --trace-shell: %w", err)
+		}
+		values.TraceShell = config.BoolFlag{Value: v, Set: true}
+	}
+	if flags.Changed("override-shell") {
+		v, err := flags.GetString("override-shell")
+		if err != nil {
+			return values, fmt.Errorf("parse --override-shell: %w", err)
+		}
+		values.OverrideShell = config.StringFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("compact") {
+		v, err := flags.GetBool("compact")
+		if err != nil {
+			return values, fmt.Errorf("parse --compact: %w", err)
+		}
+		values.Compact = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("env-file") {
+		v, err := flags.GetString("env-file")
+		if err != nil {
+			return values, fmt.Errorf("parse --env-file: %w", err)
+		}
+		values.EnvFile = config.StringFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("vars-file") {
+		v, err := flags.GetString("vars-file")
+		if err != nil {
+			return values, fmt.Errorf("parse --vars-file: %w", err)
+		}
+		values.VarsFile = config.StringFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("check-env") {
+		v, err := flags.GetBool("check-env")
+		if err != nil {
+			return values, fmt.Errorf("parse --check-env: %w", err)
+		}
+		values.CheckEnv = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("show-info") {
+		v, err := flags.GetBool("show-info")
+		if err != nil {
+			return values, fmt.Errorf("parse --show-info: %w", err)
+		}
+		values.ShowInfo = config.BoolFlag{Value: v, Set: true}
+	}
+
 	if flags.Changed("dry-run") {
 		v, err := flags.GetBool("dry-run")
 		if err != nil {
@@ -75,5 +215,101 @@ func gatherFlags(cmd *cobra.Command) (config.FlagValues, error) {
 		values.Verbose = config.BoolFlag{Value: v, Set: true}
 	}
 
+	if flags.Changed("combine-output") {
+		v, err := flags.GetBool("combine-output")
+		if err != nil {
+			return values, fmt.Errorf("parse --combine-output: %w", err)
+		}
+		values.CombineOutput = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("no-cache") {
+		v, err := flags.GetBool("no-cache")
+		if err != nil {
+			return values, fmt.Errorf("parse --no-cache: %w", err)
+		}
+		values.NoCache = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("dedupe") {
+		v, err := flags.GetBool("dedupe")
+		if err != nil {
+			return values, fmt.Errorf("parse --dedupe: %w", err)
+		}
+		values.Dedupe = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("require-match") {
+		v, err := flags.GetBool("require-match")
+		if err != nil {
+			return values, fmt.Errorf("parse --require-match: %w", err)
+		}
+		values.RequireMatch = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("max-parallel") {
+		v, err := flags.GetInt("max-parallel")
+		if err != nil {
+			return values, fmt.Errorf("parse --max-parallel: %w", err)
+		}
+		values.MaxParallel = config.IntFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("schedule") {
+		v, err := flags.GetString("schedule")
+		if err != nil {
+			return values, fmt.Errorf("parse --schedule: %w", err)
+		}
+		values.Schedule = config.StringFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("follow") {
+		v, err := flags.GetString("follow")
+		if err != nil {
+			return values, fmt.Errorf("parse --follow: %w", err)
+		}
+		values.Follow = config.StringFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("strict-git") {
+		v, err := flags.GetBool("strict-git")
+		if err != nil {
+			return values, fmt.Errorf("parse --strict-git: %w", err)
+		}
+		values.StrictGit = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("allow-destructive") {
+		v, err := flags.GetBool("allow-destructive")
+		if err != nil {
+			return values, fmt.Errorf("parse --allow-destructive: %w", err)
+		}
+		values.AllowDestructive = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("allow-unresolved-expressions") {
+		v, err := flags.GetBool("allow-unresolved-expressions")
+		if err != nil {
+			return values, fmt.Errorf("parse --allow-unresolved-expressions: %w", err)
+		}
+		values.AllowUnresolvedExpressions = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("no-version-check") {
+		v, err := flags.GetBool("no-version-check")
+		if err != nil {
+			return values, fmt.Errorf("parse --no-version-check: %w", err)
+		}
+		values.NoVersionCheck = config.BoolFlag{Value: v, Set: true}
+	}
+
+	if flags.Changed("no-auto-path") {
+		v, err := flags.GetBool("no-auto-path")
+		if err != nil {
+			return values, fmt.Errorf("parse --no-auto-path: %w", err)
+		}
+		values.NoAutoPath = config.BoolFlag{Value: v, Set: true}
+	}
+
 	return values, nil
 }
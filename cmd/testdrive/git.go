@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/gitstate"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// gitProbe inspects the checkout before a run; tests replace it with a
+// scripted fake.
+var gitProbe gitstate.Git = gitstate.ExecGit{}
+
+// checkGitState compares the checkout at root with what CI would build. The
+// differences are added to data's warnings, or returned as an error with
+// --strict-git. A --worktree run builds HEAD, so uncommitted changes do not
+// count there.
+func checkGitState(cfg config.Config, root string, data pipelineData, worktree bool) (pipelineData, error) {
+	if !cfg.Warn.DirtyWorktree && !cfg.StrictGit {
+		return data, nil
+	}
+	state, ok, err := gitstate.Probe(gitProbe, root)
+	if !ok {
+		return data, nil
+	}
+	if worktree {
+		state.Uncommitted = 0
+	}
+	problems := state.Problems()
+	if err != nil {
+		// Git's errors can run to several lines of advice; the first says
+		// what went wrong.
+		msg, _, _ := strings.Cut(err.Error(), "\n")
+		problems = []string{fmt.Sprintf("could not inspect git state: %s", msg)}
+	}
+	if len(problems) == 0 {
+		return data, nil
+	}
+	if cfg.StrictGit {
+		return data, fmt.Errorf("checkout differs from what CI would build (--strict-git):\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	warnings := make([]provider.Warning, 0, len(problems))
+	for _, problem := range problems {
+		warnings = append(warnings, provider.Warning{Kind: provider.WarnGitState, Message: problem})
+	}
+	suppressed, err := provider.ParseWarningKinds(cfg.SuppressWarnings)
+	if err != nil {
+		return data, fmt.Errorf("suppress_warnings: %w", err)
+	}
+	data.warnings = append(append([]provider.Warning{}, data.warnings...), provider.SuppressWarnings(warnings, suppressed)...)
+	return data, nil
+}
+
+// describeCheckout names the commit checked out at root for reports. It is
+// nil outside a git repository.
+func describeCheckout(root string) *report.Meta {
+	head, ok := gitstate.ProbeHead(gitProbe, root)
+	if !ok {
+		return nil
+	}
+	return &report.Meta{Branch: head.Branch, SHA: head.SHA, Dirty: head.Dirty}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/gitstate"
+)
+
+func init() {
+	// Runs in the repository would otherwise report on its own checkout.
+	gitProbe = scriptedGit{"rev-parse --is-inside-work-tree": "false"}
+}
+
+// scriptedGit answers git commands from a table keyed by the joined args.
+type scriptedGit map[string]string
+
+func (s scriptedGit) Run(dir string, args ...string) (string, error) {
+	out, ok := s[strings.Join(args, " ")]
+	if !ok {
+		return "", errors.New("fatal: no upstream configured")
+	}
+	return out, nil
+}
+
+func useGit(t *testing.T, git gitstate.Git) {
+	t.Helper()
+	prev := gitProbe
+	gitProbe = git
+	t.Cleanup(func() { gitProbe = prev })
+}
+
+// dirtyDetached scripts a checkout with three uncommitted files and a
+// detached HEAD.
+var dirtyDetached = scriptedGit{
+	"rev-parse --is-inside-work-tree":            "true",
+	"status --porcelain -- :(exclude).testdrive": " M a.go\n M b.go\n?? c.go",
+	"rev-parse --abbrev-ref HEAD":                "HEAD",
+}
+
+// goldenCheckout scripts the dirty feature branch the golden files were
+// recorded on.
+var goldenCheckout = scriptedGit{
+	"rev-parse --is-inside-work-tree":            "true",
+	"rev-parse --short HEAD":                     "a1b2c3d",
+	"rev-parse --abbrev-ref HEAD":                "feature/login",
+	"status --porcelain -- :(exclude).testdrive": " M README.md",
+}
+
+func gitFixture(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	workflow := "name: CI\njobs:\n  test:\n    steps:\n      - run: echo ok\n"
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(workflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	chdir(t, dir)
+}
+
+func TestRunCommandWarnsAboutGitState(t *testing.T) {
+	gitFixture(t)
+	useGit(t, dirtyDetached)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--format", "json"})
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	var payload struct {
+		Warnings []string `json:"warnings"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+		t.Fatalf("decode: %v\n%s", err, out.String())
+	}
+	want := []string{
+		"working tree has 3 uncommitted change(s); CI will build the committed state",
+		"HEAD is detached; CI builds a branch or pull request",
+	}
+	if strings.Join(payload.Warnings, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("warnings = %q, want %q", payload.Warnings, want)
+	}
+
+	// The streaming view prints them before the run starts.
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"run"})
+	errBuf := &bytes.Buffer{}
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(errBuf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if !strings.Contains(errBuf.String(), "warning: HEAD is detached") {
+		t.Fatalf("expected the warning on stderr, got %q", errBuf.String())
+	}
+
+	for _, args := range [][]string{
+		{"run", "--format", "json", "--suppress", "git_state"},
+		{"run", "--format", "json", "--dry-run"},
+	} {
+		cmd = newRootCmd()
+		cmd.SetArgs(args)
+		out.Reset()
+		cmd.SetOut(out)
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("command execute: %v", err)
+		}
+		if strings.Contains(out.String(), "HEAD is detached") {
+			t.Fatalf("%v: expected no git warnings, got %s", args, out.String())
+		}
+	}
+}
+
+func TestRunCommandStrictGit(t *testing.T) {
+	gitFixture(t)
+	useGit(t, dirtyDetached)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--strict-git"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--strict-git") || !strings.Contains(err.Error(), "3 uncommitted change(s)") {
+		t.Fatalf("expected a strict git error, got %v", err)
+	}
+
+	// A clean, pushed branch passes.
+	useGit(t, scriptedGit{
+		"rev-parse --is-inside-work-tree":                         "true",
+		"status --porcelain -- :(exclude).testdrive":              "",
+		"rev-parse --abbrev-ref HEAD":                             "main",
+		"rev-parse --abbrev-ref --symbolic-full-name @{upstream}": "origin/main",
+		"rev-list --left-right --count @{upstream}...HEAD":        "0\t0",
+	})
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"run", "--strict-git"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("expected a clean checkout to pass, got %v", err)
+	}
+}
+
+func TestRunCommandKeepsCleanRepoClean(t *testing.T) {
+	gitFixture(t)
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	useGit(t, gitstate.ExecGit{})
+	recordRuns = true
+	t.Cleanup(func() { recordRuns = false })
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	// The second run finds the lock and history the first left behind.
+	for i := 0; i < 2; i++ {
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"run", "--format", "json"})
+		out := &bytes.Buffer{}
+		cmd.SetOut(out)
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("command execute: %v", err)
+		}
+		var payload struct {
+			Warnings []string `json:"warnings"`
+			Meta     struct {
+				Dirty bool `json:"dirty"`
+			} `json:"meta"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+			t.Fatalf("decode: %v\n%s", err, out.String())
+		}
+		if payload.Meta.Dirty || strings.Contains(strings.Join(payload.Warnings, "\n"), "uncommitted") {
+			t.Fatalf("run %d: expected a clean checkout, got %s", i+1, out.String())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(".testdrive", "history")); err != nil {
+		t.Fatalf("expected the runs to write history: %v", err)
+	}
+	status, err := exec.Command("git", "status", "--porcelain").Output()
+	if err != nil || len(status) != 0 {
+		t.Fatalf("expected the state dir to stay out of git status, got %q (%v)", status, err)
+	}
+}
+
+func TestRunCommandWarnsAboutRepoWithoutCommits(t *testing.T) {
+	gitFixture(t)
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	useGit(t, gitstate.ExecGit{})
+	if out, err := exec.Command("git", "init", "-q", "-b", "main").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	_, stderr, err := executeRoot(t, "run")
+	if err != nil {
+		t.Fatalf("command execute: %v\n%s", err, stderr)
+	}
+	if !strings.Contains(stderr, "warning: branch main has no commits yet; CI only sees pushed commits\n") {
+		t.Fatalf("expected a no-commits warning, got:\n%s", stderr)
+	}
+	if strings.Contains(stderr, "fatal:") || strings.Contains(stderr, "could not inspect") {
+		t.Fatalf("expected git's own error to stay out of the warnings, got:\n%s", stderr)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/output"
+)
+
+func TestGroupByPrefixGoldens(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cases := []struct {
+		name   string
+		args   []string
+		golden string
+	}{
+		{"list", []string{"list", "--workflow", "testdata/workflows/ci_grouped.yml", "--group-by-prefix"}, "list_grouped.txt"},
+		{"run dry", []string{"run", "--workflow", "testdata/workflows/ci_grouped.yml", "--dry-run", "--group-by-prefix"}, "run_grouped_dry.txt"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newRootCmd()
+			cmd.SetArgs(tc.args)
+			buf := &bytes.Buffer{}
+			cmd.SetOut(buf)
+			cmd.SetErr(&bytes.Buffer{})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("command execute: %v", err)
+			}
+			want := readGolden(t, filepath.Join(root, "testdata", "golden", tc.golden))
+			if diff := diffStrings(want, buf.String()); diff != "" {
+				t.Fatalf("unexpected output:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGroupByPrefixLeavesJSONUnchanged(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	render := func(extra ...string) output.Report {
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"run", "--workflow", "testdata/workflows/ci_grouped.yml", "--dry-run", "--format", "json"}, extra...))
+		buf := &bytes.Buffer{}
+		cmd.SetOut(buf)
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("command execute: %v", err)
+		}
+		var rep output.Report
+		if err := json.Unmarshal(buf.Bytes(), &rep); err != nil {
+			t.Fatalf("decode output: %v", err)
+		}
+		return rep
+	}
+
+	plain, grouped := render(), render("--group-by-prefix")
+	if len(grouped.Steps) != 8 || grouped.Summary.TotalSteps != 8 {
+		t.Fatalf("expected 8 individual steps, got %d (summary %d)", len(grouped.Steps), grouped.Summary.TotalSteps)
+	}
+	if grouped.Steps[0].StepName != "Setup: install deps" || len(plain.Steps) != len(grouped.Steps) {
+		t.Fatalf("JSON step names must be untouched, got %+v", grouped.Steps[0])
+	}
+}
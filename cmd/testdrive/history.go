@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/history"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/spf13/cobra"
+)
+
+func newHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Inspect the runs recorded under .testdrive/history",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "flaky",
+		Short: "List steps that recently failed and then passed, most flaky first",
+		Long: fmt.Sprintf(`Flaky scores each step over its last %d recorded runs: every run in which
+the step passed right after failing counts as a recovery. Steps with %d or
+more recoveries are marked with ~ here and in run results. Steps renamed
+since earlier runs are matched by their step ID, or by their command in runs
+recorded before step IDs.`, history.FlakyWindow, history.FlakyMinRecoveries),
+		Args: cobra.NoArgs,
+		RunE: runHistoryFlaky,
+	})
+	envDiff := &cobra.Command{
+		Use:   "env-diff <run-a> <run-b>",
+		Short: "Compare the environments a step failed in across two runs",
+		Long: `Env-diff compares the environment snapshots recorded for steps that failed
+in both runs: the shell, the tool versions detected before the run, and the
+environment variables. Steps are matched by step ID, so a step renamed
+between the runs still pairs up, and by name when a run has no IDs. Most
+values are masked when recorded, so for those only a variable's presence can
+differ.
+
+A run is a recorded run counted back from the latest (1 is the latest, 2 the
+one before), or the path of a saved ` + "`run --format json`" + ` report, such as one
+a teammate shared.`,
+		Args: cobra.ExactArgs(2),
+		RunE: runHistoryEnvDiff,
+	}
+	envDiff.Flags().String("job", "", "only compare steps of this job")
+	envDiff.Flags().String("step", "", "only compare steps with this name")
+	cmd.AddCommand(envDiff)
+	return cmd
+}
+
+// snapshotKey names a step within a run.
+type snapshotKey struct {
+	workflow, job, step string
+}
+
+func runHistoryEnvDiff(cmd *cobra.Command, args []string) error {
+	cfg, root, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	jobFilter, _ := cmd.Flags().GetString("job")
+	stepFilter, _ := cmd.Flags().GetString("step")
+
+	var runs []history.Run
+	snapshots := make([]map[snapshotKey]report.EnvSnapshot, 2)
+	orders := make([][]snapshotKey, 2)
+	// byID maps each side's step IDs to the step's key, and stepIDs back.
+	byID := make([]map[string]snapshotKey, 2)
+	stepIDs := make([]map[snapshotKey]string, 2)
+	for i, ref := range args {
+		var steps []report.StepResult
+		if n, convErr := strconv.Atoi(ref); convErr == nil {
+			if runs == nil {
+				if runs, err = history.Open(root).Load(); err != nil {
+					return err
+				}
+			}
+			if steps, err = historySnapshots(runs, n); err != nil {
+				return err
+			}
+		} else if steps, err = reportSnapshots(ref); err != nil {
+			return err
+		}
+		snapshots[i] = make(map[snapshotKey]report.EnvSnapshot)
+		byID[i], stepIDs[i] = make(map[string]snapshotKey), make(map[snapshotKey]string)
+		for _, step := range steps {
+			if step.EnvSnapshot == nil || (jobFilter != "" && step.JobName != jobFilter) || (stepFilter != "" && step.StepName != stepFilter) {
+				continue
+			}
+			key := snapshotKey{workflow: filepath.ToSlash(step.WorkflowPath), job: step.JobName, step: step.StepName}
+			if _, seen := snapshots[i][key]; !seen {
+				orders[i] = append(orders[i], key)
+			}
+			snapshots[i][key] = *step.EnvSnapshot
+			if step.StepID != "" {
+				byID[i][step.StepID], stepIDs[i][key] = key, step.StepID
+			}
+		}
+	}
+
+	diffs := []report.SnapshotDiff{}
+	for _, key := range orders[0] {
+		other, ok := byID[1][stepIDs[0][key]]
+		if !ok {
+			other = key
+		}
+		b, ok := snapshots[1][other]
+		if !ok {
+			continue
+		}
+		diffs = append(diffs, report.SnapshotDiff{
+			WorkflowPath: key.workflow,
+			JobName:      key.job,
+			StepName:     key.step,
+			Changes:      report.DiffSnapshots(snapshots[0][key], b),
+		})
+	}
+	if len(diffs) == 0 {
+		return fmt.Errorf("no step failed with a recorded environment in both %s and %s", args[0], args[1])
+	}
+
+	switch strings.ToLower(cfg.Format) {
+	case config.FormatPretty:
+		return newPretty(cmd, cfg).RenderSnapshotDiffs(diffs)
+	case config.FormatJSON:
+		renderer := output.NewJSON(cmd.OutOrStdout())
+		renderer.Compact = cfg.Compact
+		return renderer.Encode(diffs)
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+}
+
+// historySnapshots returns the steps of the recorded run n places back from
+// the latest, counting the latest as 1.
+func historySnapshots(runs []history.Run, n int) ([]report.StepResult, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid run %d: runs are counted back from the latest, which is 1", n)
+	}
+	if n > len(runs) {
+		return nil, fmt.Errorf("run %d not found: %d run(s) recorded under %s", n, len(runs), history.Dir)
+	}
+	run := runs[len(runs)-n]
+	steps := make([]report.StepResult, 0, len(run.Steps))
+	for _, step := range run.Steps {
+		steps = append(steps, report.StepResult{WorkflowPath: step.Workflow, JobName: step.Job, StepName: step.Name, StepID: step.StepID, EnvSnapshot: step.EnvSnapshot})
+	}
+	return steps, nil
+}
+
+// reportSnapshots returns the steps of a saved `run --format json` report.
+func reportSnapshots(path string) ([]report.StepResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", path, err)
+	}
+	var saved output.Report
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("decode %q: %w", path, err)
+	}
+	steps := saved.Steps
+	for _, repo := range saved.Repos {
+		steps = append(steps, repo.Steps...)
+	}
+	return steps, nil
+}
+
+func runHistoryFlaky(cmd *cobra.Command, _ []string) error {
+	cfg, root, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	runs, err := history.Open(root).Load()
+	if err != nil {
+		return err
+	}
+	steps := []report.FlakyStep{}
+	for _, f := range history.FlakySteps(runs) {
+		steps = append(steps, report.FlakyStep{
+			WorkflowPath: f.Workflow,
+			JobName:      f.Job,
+			StepName:     f.Step,
+			Recoveries:   f.Recoveries,
+			Runs:         f.Runs,
+			FlakyScore:   f.Score(),
+			Flaky:        f.Flaky(),
+		})
+	}
+
+	switch strings.ToLower(cfg.Format) {
+	case config.FormatPretty:
+		return newPretty(cmd, cfg).RenderFlaky(steps)
+	case config.FormatJSON:
+		renderer := output.NewJSON(cmd.OutOrStdout())
+		renderer.Compact = cfg.Compact
+		return renderer.Encode(steps)
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+}
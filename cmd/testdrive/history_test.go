@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/history"
+)
+
+func init() {
+	// Most tests run against the repository's own fixtures; keep them from
+	// writing history into the working tree.
+	recordRuns = false
+}
+
+const scheduleWorkflow = `name: CI
+jobs:
+  fast:
+    steps:
+      - run: echo fast
+  slow:
+    steps:
+      - run: sleep 0.3
+`
+
+func scheduleFixture(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(scheduleWorkflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	recordRuns = true
+	t.Cleanup(func() { recordRuns = false })
+	return dir
+}
+
+func TestRunCommandSchedulesLongestFirst(t *testing.T) {
+	dir := scheduleFixture(t)
+	chdir(t, dir)
+
+	// Without history there is nothing to reorder by.
+	verbose := []string{"run", "--verbose", "--max-parallel", "2"}
+	_, stderr, err := executeRoot(t, verbose...)
+	if err != nil {
+		t.Fatalf("command execute: %v\n%s", err, stderr)
+	}
+	if strings.Contains(stderr, "longest-first") {
+		t.Fatalf("expected declared order without history, got:\n%s", stderr)
+	}
+	runs, err := history.Open(dir).Load()
+	if err != nil || len(runs) != 1 || len(runs[0].Jobs) != 2 {
+		t.Fatalf("expected the run to be recorded, got %+v, %v", runs, err)
+	}
+
+	if _, stderr, err = executeRoot(t, verbose...); err != nil {
+		t.Fatalf("command execute: %v\n%s", err, stderr)
+	}
+	line := ""
+	for _, l := range strings.Split(stderr, "\n") {
+		if strings.HasPrefix(l, "info: starting jobs longest-first: ") {
+			line = l
+		}
+	}
+	slow := strings.Index(line, ".github/workflows/ci.yml/slow (")
+	fast := strings.Index(line, ".github/workflows/ci.yml/fast (")
+	if slow < 0 || fast < 0 || slow > fast {
+		t.Fatalf("expected slow to be scheduled before fast, got:\n%s", stderr)
+	}
+
+	if _, stderr, err = executeRoot(t, append(verbose, "--schedule", "declared")...); err != nil {
+		t.Fatalf("command execute: %v\n%s", err, stderr)
+	}
+	if strings.Contains(stderr, "longest-first") {
+		t.Fatalf("expected --schedule=declared to keep file order, got:\n%s", stderr)
+	}
+
+	if _, _, err := executeRoot(t, "run", "--schedule", "random"); err == nil || !strings.Contains(err.Error(), `unsupported schedule "random"`) {
+		t.Fatalf("expected a schedule error, got %v", err)
+	}
+}
+
+func TestRunCommandHistoryDisabled(t *testing.T) {
+	dir := scheduleFixture(t)
+	chdir(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, ".testdrive.yml"), []byte("history: false\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if _, stderr, err := executeRoot(t, "run", "--verbose", "--max-parallel", "2"); err != nil {
+		t.Fatalf("command execute: %v\n%s", err, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(history.Dir))); !os.IsNotExist(err) {
+		t.Fatalf("expected no history with history: false, got %v", err)
+	}
+}
+
+// flakyWorkflow's System specs step fails and passes on alternate runs.
+const flakyWorkflow = `name: CI
+jobs:
+  test:
+    steps:
+      - name: System specs
+        run: "if [ -f flip ]; then rm flip; else touch flip; echo spec failed >&2; exit 1; fi"
+      - name: Lint
+        run: echo lint
+`
+
+func TestRunCommandMarksFlakySteps(t *testing.T) {
+	dir := scheduleFixture(t)
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(flakyWorkflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	chdir(t, dir)
+
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := newRootCmd()
+		// Batch output is only used off the streaming path.
+		cmd.SetArgs(append([]string{"run", "--max-parallel", "2"}, args...))
+		out := &bytes.Buffer{}
+		cmd.SetOut(out)
+		cmd.SetErr(&bytes.Buffer{})
+		_ = cmd.Execute()
+		return out.String()
+	}
+	// Failed, passed, failed: one recovery is not yet flaky.
+	for i := 0; i < 3; i++ {
+		run()
+	}
+	if out := run(); strings.Contains(out, "flaky") {
+		t.Fatalf("expected a single recovery not to count as flaky, got:\n%s", out)
+	}
+
+	out := run()
+	if !strings.Contains(out, "✗ ~ System specs (") || !strings.Contains(out, ") (flaky: 2/4 recent runs)\n") {
+		t.Fatalf("expected System specs to be marked flaky, got:\n%s", out)
+	}
+	if strings.Contains(out, "~ Lint") {
+		t.Fatalf("expected Lint not to be marked, got:\n%s", out)
+	}
+	if out := run("--format", "json"); !strings.Contains(out, `"flaky_score": 0.4`) {
+		t.Fatalf("expected a flaky_score in JSON, got:\n%s", out)
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"history", "flaky"})
+	listing := &bytes.Buffer{}
+	cmd.SetOut(listing)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("history flaky: %v", err)
+	}
+	want := "FLAKY STEPS:\n  ~ .github/workflows/ci.yml / test / System specs  3/6 recent runs  0.50\n"
+	if listing.String() != want {
+		t.Fatalf("unexpected listing:\n%s\nwant:\n%s", listing.String(), want)
+	}
+}
+
+const envDiffWorkflow = `name: CI
+jobs:
+  test:
+    steps:
+      - name: Specs
+        run: exit 1
+`
+
+func TestHistoryEnvDiff(t *testing.T) {
+	dir := scheduleFixture(t)
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(envDiffWorkflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	chdir(t, dir)
+
+	execute := func(args ...string) (string, error) {
+		t.Helper()
+		cmd := newRootCmd()
+		cmd.SetArgs(args)
+		out := &bytes.Buffer{}
+		cmd.SetOut(out)
+		cmd.SetErr(&bytes.Buffer{})
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	t.Setenv("API_TOKEN", "hunter2")
+	t.Setenv("RAILS_ENV", "test")
+	saved, _ := execute("run", "--format", "json")
+	if !strings.Contains(saved, `"env_snapshot"`) || !strings.Contains(saved, `"API_TOKEN": "***"`) {
+		t.Fatalf("expected a masked snapshot in JSON, got:\n%s", saved)
+	}
+	if strings.Contains(saved, "hunter2") {
+		t.Fatalf("expected the token to be masked, got:\n%s", saved)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "teammate.json"), []byte(saved), 0o644); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(history.Dir), "runs.jsonl"))
+	if err != nil || strings.Contains(string(data), "hunter2") {
+		t.Fatalf("expected history without the token, got %v:\n%s", err, data)
+	}
+
+	t.Setenv("RAILS_ENV", "development")
+	execute("run")
+
+	want := ".github/workflows/ci.yml / test / Specs\n  env RAILS_ENV  test -> development\n"
+	for _, args := range [][]string{{"2", "1"}, {"teammate.json", "1"}} {
+		out, err := execute(append([]string{"history", "env-diff"}, args...)...)
+		if err != nil {
+			t.Fatalf("env-diff %v: %v", args, err)
+		}
+		if out != want {
+			t.Fatalf("env-diff %v:\n%s\nwant:\n%s", args, out, want)
+		}
+	}
+
+	if _, err := execute("history", "env-diff", "1", "5"); err == nil || !strings.Contains(err.Error(), "run 5 not found: 2 run(s) recorded") {
+		t.Fatalf("expected a missing run error, got %v", err)
+	}
+	if _, err := execute("history", "env-diff", "1", "2", "--step", "Lint"); err == nil || !strings.Contains(err.Error(), "no step failed with a recorded environment") {
+		t.Fatalf("expected no matching steps, got %v", err)
+	}
+
+	// A renamed step keeps its step ID and still pairs up.
+	renamed := strings.Replace(envDiffWorkflow, "name: Specs", "name: System specs", 1)
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(renamed), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	t.Setenv("RAILS_ENV", "production")
+	execute("run")
+	out, err := execute("history", "env-diff", "2", "1")
+	if want := ".github/workflows/ci.yml / test / Specs\n  env RAILS_ENV  development -> production\n"; err != nil || out != want {
+		t.Fatalf("env-diff across a rename: %v\n%s\nwant:\n%s", err, out, want)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/runner"
+	"github.com/spf13/cobra"
+)
+
+func newInitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Scaffold a .testdrive.yml from the discovered workflows",
+		RunE:  runInit,
+	}
+	cmd.Flags().Bool("force", false, "overwrite an existing .testdrive.yml")
+	cmd.Flags().Bool("stdout", false, "print the generated config instead of writing it")
+	return cmd
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	root, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("determine working directory: %w", err)
+	}
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("parse --force: %w", err)
+	}
+	toStdout, err := cmd.Flags().GetBool("stdout")
+	if err != nil {
+		return fmt.Errorf("parse --stdout: %w", err)
+	}
+
+	path := filepath.Join(root, config.FileName)
+	if !toStdout && !force {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists; pass --force to overwrite", config.FileName)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("stat %q: %w", path, err)
+		}
+	}
+
+	// Start from defaults rather than the existing file so a broken config can
+	// be regenerated with --force.
+	cfg := config.Default()
+	cfg.Warn.VersionMismatch = false
+	flags, err := gatherFlags(cmd)
+	if err != nil {
+		return err
+	}
+	config.ApplyFlags(&cfg, flags)
+
+	data, err := loadPipeline(root, cfg, debugLog(cmd))
+	if err != nil {
+		return err
+	}
+
+	contents := scaffoldConfig(data, runner.DefaultPrivilegedPatterns())
+
+	if toStdout {
+		_, err := io.WriteString(cmd.OutOrStdout(), contents)
+		return err
+	}
+
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		return fmt.Errorf("write %q: %w", path, err)
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Wrote %s\n\n", config.FileName)
+	fmt.Fprintln(out, "Next steps:")
+	fmt.Fprintln(out, "  1. Uncomment the jobs/skip_step examples that fit your local setup")
+	fmt.Fprintln(out, "  2. Run `testdrive list` to preview what will execute")
+	fmt.Fprintln(out, "  3. Run `testdrive run` to execute the steps locally")
+	return nil
+}
+
+// scaffoldConfig renders a commented .testdrive.yml using the real workflow,
+// job, and step names from the parsed pipeline.
+func scaffoldConfig(data pipelineData, privileged []string) string {
+	var b strings.Builder
+
+	b.WriteString("# testdrive configuration generated by `testdrive init`.\n")
+	b.WriteString("# Command-line flags and TESTDRIVE_* environment variables override these values.\n\n")
+
+	fmt.Fprintf(&b, "provider: %s\n\n", data.provider)
+
+	b.WriteString("# Workflows are discovered automatically. Uncomment to restrict runs to specific files:\n")
+	b.WriteString("# workflows:\n")
+	for _, wf := range data.workflows {
+		fmt.Fprintf(&b, "#   - %s\n", yamlString(filepath.ToSlash(wf.Path)))
+	}
+	b.WriteString("\n")
+
+	b.WriteString("# Limit runs to matching jobs (substring or /regex/). Jobs found in this repository:\n")
+	b.WriteString("# jobs:\n")
+	for _, id := range scaffoldJobIDs(data.workflows) {
+		fmt.Fprintf(&b, "#   - %s\n", yamlString(id))
+	}
+	b.WriteString("\n")
+
+	b.WriteString("# Skip steps that only make sense on CI (substring or /regex/):\n")
+	b.WriteString("# skip_step:\n")
+	fmt.Fprintf(&b, "#   - %s\n\n", yamlString(scaffoldStepExample(data.workflows)))
+
+	b.WriteString("format: pretty\n")
+	b.WriteString("tail_lines: 20\n")
+	b.WriteString("warn:\n")
+	b.WriteString("  version_mismatch: true\n\n")
+
+	b.WriteString("# Commands matching these patterns are skipped unless TESTDRIVE_ALLOW_PRIVILEGED=1.\n")
+	b.WriteString("# Uncomment to replace the built-in list:\n")
+	b.WriteString("# privileged_command_patterns:\n")
+	for _, pattern := range privileged {
+		fmt.Fprintf(&b, "#   - %s\n", yamlString(pattern))
+	}
+
+	return b.String()
+}
+
+func scaffoldJobIDs(workflows []provider.Workflow) []string {
+	seen := make(map[string]struct{})
+	var ids []string
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			if _, ok := seen[job.RawID]; ok {
+				continue
+			}
+			seen[job.RawID] = struct{}{}
+			ids = append(ids, job.RawID)
+		}
+	}
+	return ids
+}
+
+func scaffoldStepExample(workflows []provider.Workflow) string {
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			for _, step := range job.Steps {
+				if step.Run != "" {
+					return step.Name
+				}
+			}
+		}
+	}
+	return "Upload artifact"
+}
+
+// yamlString quotes s as a single-quoted YAML scalar so regex backslashes,
+// colons, and boolean-looking names survive without escaping.
+func yamlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/config"
+)
+
+func TestInitCommandWritesLoadableConfig(t *testing.T) {
+	tmp := initFixtureRepo(t)
+	chdir(t, tmp)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"init"})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Next steps:") {
+		t.Fatalf("expected next steps message, got %q", buf.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmp, config.FileName))
+	if err != nil {
+		t.Fatalf("read generated config: %v", err)
+	}
+	contents := string(data)
+	for _, want := range []string{"provider: github", "#   - 'lint'", "#   - 'test'", "#   - 'Run rubocop'", `'(?i)^sudo\b'`} {
+		if !strings.Contains(contents, want) {
+			t.Fatalf("expected generated config to contain %q:\n%s", want, contents)
+		}
+	}
+
+	cfg, err := config.Load(tmp)
+	if err != nil {
+		t.Fatalf("generated config does not load: %v", err)
+	}
+	if cfg.Provider != config.ProviderGitHub || cfg.Format != config.FormatPretty {
+		t.Fatalf("unexpected loaded config: %+v", cfg)
+	}
+
+	// Uncommenting every example must still produce a valid config.
+	if err := os.WriteFile(filepath.Join(tmp, config.FileName), []byte(uncommentExamples(contents)), 0o644); err != nil {
+		t.Fatalf("write uncommented config: %v", err)
+	}
+	cfg, err = config.Load(tmp)
+	if err != nil {
+		t.Fatalf("uncommented config does not load: %v", err)
+	}
+	if len(cfg.Jobs) != 2 || len(cfg.PrivilegedCommandPatterns) == 0 {
+		t.Fatalf("expected examples to load, got %+v", cfg)
+	}
+}
+
+func TestInitCommandRefusesOverwrite(t *testing.T) {
+	tmp := initFixtureRepo(t)
+	chdir(t, tmp)
+	path := filepath.Join(tmp, config.FileName)
+	if err := os.WriteFile(path, []byte("format: json\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"init"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("expected overwrite refusal, got %v", err)
+	}
+
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"init", "--force"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("init --force: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !strings.Contains(string(data), "generated by `testdrive init`") {
+		t.Fatalf("expected config to be regenerated, got %q", data)
+	}
+}
+
+func TestInitCommandStdout(t *testing.T) {
+	tmp := initFixtureRepo(t)
+	chdir(t, tmp)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"init", "--stdout"})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "# testdrive configuration") {
+		t.Fatalf("expected config on stdout, got %q", buf.String())
+	}
+	if _, err := os.Stat(filepath.Join(tmp, config.FileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no file written with --stdout, stat err=%v", err)
+	}
+}
+
+func initFixtureRepo(t *testing.T) string {
+	t.Helper()
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, ".github", "workflows")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	workflow := []byte(`name: CI
+jobs:
+  lint:
+    steps:
+      - uses: actions/checkout@v4
+      - name: Run rubocop
+        run: bundle exec rubocop
+  test:
+    steps:
+      - name: Run specs
+        run: bundle exec rspec
+`)
+	if err := os.WriteFile(filepath.Join(dir, "ci.yml"), workflow, 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	return tmp
+}
+
+// uncommentExamples enables the commented key/list examples and drops the
+// explanatory prose around them.
+func uncommentExamples(s string) string {
+	var kept []string
+	for _, line := range strings.Split(s, "\n") {
+		switch {
+		case strings.HasPrefix(line, "#   - "):
+			kept = append(kept, strings.TrimPrefix(line, "# "))
+		case strings.HasPrefix(line, "# ") && strings.HasSuffix(line, ":") && !strings.Contains(line[2:], " "):
+			kept = append(kept, strings.TrimPrefix(line, "# "))
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/bgricker/testdrive/internal/runner"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// newInvocation describes how cmd was started, for the invocation block of
+// its JSON report. stream is whether the report ends a --stream.
+func newInvocation(cmd *cobra.Command, cfg config.Config, stream bool) *report.Invocation {
+	return &report.Invocation{
+		Version: testdriveVersion(),
+		Command: cmd.Name(),
+		Args:    invocationArgs(cmd),
+		Filters: report.InvocationFilters{
+			Workflows: cfg.Workflows,
+			Jobs:      cfg.Jobs,
+			OnlySteps: cfg.OnlySteps,
+			SkipSteps: cfg.SkipSteps,
+		},
+		AllowPrivileged:  os.Getenv("TESTDRIVE_ALLOW_PRIVILEGED") == "1",
+		AllowDestructive: cfg.AllowDestructive,
+		DryRun:           cfg.DryRun,
+		TailLines:        cfg.TailLines,
+		Stream:           stream,
+		Config:           redactConfig(cfg),
+	}
+}
+
+// testdriveVersion returns the module version testdrive was built as, or
+// "(devel)" for a build from a working copy.
+func testdriveVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// invocationArgs lists the flags given to cmd as --name=value, one per value
+// of a repeated flag, followed by its positional arguments. --from-report
+// itself is left out, so a replayed run records what it ran with.
+func invocationArgs(cmd *cobra.Command) []string {
+	args := []string{}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if f.Name == "from-report" {
+			return
+		}
+		values := []string{f.Value.String()}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			values = slice.GetSlice()
+		}
+		for _, value := range values {
+			if f.Name == "workflow-url" {
+				value = redactURL(value)
+			}
+			args = append(args, "--"+f.Name+"="+value)
+		}
+	})
+	return append(args, cmd.Flags().Args()...)
+}
+
+// redactConfig returns cfg with the values a report must not carry masked:
+// override env values, vars whose names match redact_env_patterns, and
+// credentials in workflow URLs. Env and vars files are named, never read.
+func redactConfig(cfg config.Config) config.Config {
+	if cfg.Overrides != nil {
+		overrides := make([]config.Override, len(cfg.Overrides))
+		for i, o := range cfg.Overrides {
+			if o.Env != nil {
+				env := make(map[string]string, len(o.Env))
+				for name := range o.Env {
+					env[name] = report.MaskedValue
+				}
+				o.Env = env
+			}
+			overrides[i] = o
+		}
+		cfg.Overrides = overrides
+	}
+	if cfg.Vars != nil {
+		vars := make(map[string]string, len(cfg.Vars))
+		for name, value := range cfg.Vars {
+			if runner.RedactName(cfg.RedactEnvPatterns, name) {
+				value = report.MaskedValue
+			}
+			vars[name] = value
+		}
+		cfg.Vars = vars
+	}
+	if cfg.WorkflowURLs != nil {
+		urls := make([]string, len(cfg.WorkflowURLs))
+		for i, raw := range cfg.WorkflowURLs {
+			urls[i] = redactURL(raw)
+		}
+		cfg.WorkflowURLs = urls
+	}
+	return cfg
+}
+
+// redactURL masks the user info and query of raw, where tokens for private
+// downloads usually go.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.User == nil && u.RawQuery == "") {
+		return raw
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = report.MaskedValue
+	}
+	if u.User == nil {
+		return u.String()
+	}
+	// url.User would escape the mask.
+	u.User = nil
+	return strings.Replace(u.String(), "//", "//"+report.MaskedValue+"@", 1)
+}
+
+// applyFromReport sets the flags recorded in the invocation block of the
+// --from-report report, except those given on the command line. The
+// report's positional workflow paths are restored as --workflow unless args
+// or --workflow name workflows already. The config file and environment are
+// read as usual, so they should match the ones the report shows.
+func applyFromReport(cmd *cobra.Command, args []string) error {
+	path, err := cmd.Flags().GetString("from-report")
+	if err != nil {
+		return fmt.Errorf("parse --from-report: %w", err)
+	}
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %q: %w", path, err)
+	}
+	var saved output.Report
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("decode %q: %w", path, err)
+	}
+	inv := saved.Invocation
+	if inv == nil {
+		return fmt.Errorf("%s has no invocation block; save a report with `testdrive run --format json`", path)
+	}
+	if inv.Command != cmd.Name() {
+		return fmt.Errorf("%s records a %s, not a %s", path, inv.Command, cmd.Name())
+	}
+	stderr := cmd.ErrOrStderr()
+	if version := testdriveVersion(); inv.Version != version {
+		fmt.Fprintf(stderr, "warning: %s was written by testdrive %s; this is %s\n", path, inv.Version, version)
+	}
+
+	given := make(map[string]bool)
+	cmd.Flags().Visit(func(f *pflag.Flag) { given[f.Name] = true })
+	var positional []string
+	for _, arg := range inv.Args {
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !ok {
+			return fmt.Errorf("%s: malformed argument %q", path, arg)
+		}
+		if given[name] {
+			continue
+		}
+		if strings.Contains(value, report.MaskedValue) {
+			fmt.Fprintf(stderr, "warning: --%s was masked in %s and is not restored\n", name, path)
+			continue
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("%s: --%s: %w", path, name, err)
+		}
+	}
+	if len(args) > 0 || given["workflow"] {
+		return nil
+	}
+	for _, workflow := range positional {
+		if err := cmd.Flags().Set("workflow", workflow); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
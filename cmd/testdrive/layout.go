@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// prettyLayout turns output.layout into the pretty renderers' options.
+func prettyLayout(cfg config.Config) output.LayoutOptions {
+	layout := cfg.Output.Layout
+	return output.LayoutOptions{
+		Indent:              layout.Indent,
+		HidePaths:           !layout.WorkflowPaths,
+		Width:               layout.Width,
+		HidePassedDurations: !layout.PassedDurations,
+	}
+}
+
+// newPretty returns a PrettyRenderer for cmd's output in the configured
+// layout.
+func newPretty(cmd *cobra.Command, cfg config.Config) *output.PrettyRenderer {
+	return output.NewPrettyLayout(cmd.OutOrStdout(), prettyLayout(cfg))
+}
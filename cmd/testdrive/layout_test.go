@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// compactLayout is a profile for an 80-column side panel split in two.
+var compactLayout = map[string]string{
+	"TESTDRIVE_OUTPUT_LAYOUT_INDENT":           "1",
+	"TESTDRIVE_OUTPUT_LAYOUT_WORKFLOW_PATHS":   "false",
+	"TESTDRIVE_OUTPUT_LAYOUT_WIDTH":            "40",
+	"TESTDRIVE_OUTPUT_LAYOUT_PASSED_DURATIONS": "false",
+}
+
+func TestLayoutGoldens(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+	useGit(t, goldenCheckout)
+
+	cases := []struct {
+		name   string
+		env    map[string]string
+		args   []string
+		golden string
+	}{
+		{"plan default", nil, append([]string{"run", "--plan"}, planArgs...), "run_plan.txt"},
+		{"plan compact", compactLayout, append([]string{"run", "--plan"}, planArgs...), "run_plan_compact.txt"},
+		{"dry run default", nil, []string{"run", "--workflow", "testdata/workflows/ci_basic.yml", "--dry-run"}, "run_dry_pretty.txt"},
+		{"dry run compact", compactLayout, []string{"run", "--workflow", "testdata/workflows/ci_basic.yml", "--dry-run"}, "run_dry_pretty_compact.txt"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			cmd := newRootCmd()
+			cmd.SetArgs(tc.args)
+			buf := &bytes.Buffer{}
+			cmd.SetOut(buf)
+			cmd.SetErr(&bytes.Buffer{})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("command execute: %v", err)
+			}
+			want := readGolden(t, filepath.Join(root, "testdata", "golden", tc.golden))
+			if diff := diffStrings(want, buf.String()); diff != "" {
+				t.Fatalf("unexpected output:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLayoutRejectsZeroIndent(t *testing.T) {
+	chdir(t, projectRoot(t))
+	t.Setenv("TESTDRIVE_OUTPUT_LAYOUT_INDENT", "0")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"list", "--workflow", "testdata/workflows/ci_basic.yml"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "output.layout.indent must be at least 1") {
+		t.Fatalf("err = %v, want the indent rejected", err)
+	}
+}
@@ -2,44 +2,129 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
     "github.com/bgricker/testdrive/internal/config"
     "github.com/bgricker/testdrive/internal/output"
+    "github.com/bgricker/testdrive/internal/patterns"
     "github.com/bgricker/testdrive/internal/provider"
     "github.com/bgricker/testdrive/internal/report"
+    "github.com/bgricker/testdrive/internal/runner"
 	"github.com/spf13/cobra"
 )
 
 func newListCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "list",
-		Short: "List workflow jobs and steps",
-		RunE:  runList,
+	cmd := &cobra.Command{
+		Use:               "list [workflow...]",
+		Short:             "List workflow jobs and steps",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeWorkflowFiles,
+		RunE:              runList,
 	}
+	cmd.Flags().Bool("details", false, "also show detected tool versions against their pins and job containers")
+	cmd.Flags().Bool("toc", false, "add a linked table of contents to --format markdown output")
+	cmd.Flags().Bool("group-by-prefix", false, "fold consecutive steps sharing a \"Word:\" name prefix in pretty output")
+	cmd.Flags().Bool("explain-skips", false, "break local coverage down by workflow")
+	cmd.Flags().Bool("check", false, "also check tool versions, git state and the environment, as run does (checks.enabled: true)")
+	cmd.Flags().Bool("no-check", false, "skip those checks even with --details (checks.enabled: false)")
+	return cmd
 }
 
 func runList(cmd *cobra.Command, args []string) error {
-	cfg, root, err := loadConfig(cmd)
+	cfg, root, err := loadConfig(cmd, args...)
 	if err != nil {
 		return err
 	}
+	completion := strings.EqualFold(cfg.Format, config.FormatCompletion)
 
-	data, err := loadPipeline(root, cfg)
+	data, err := loadPipeline(root, cfg, debugLog(cmd))
 	if err != nil {
 		return err
 	}
+	reportExcluded(cmd.ErrOrStderr(), cfg, data)
 
-	filtered, err := applyFilters(data, cfg)
+	filtered, err := applyFilters(data, cfg, debugLog(cmd))
 	if err != nil {
 		return err
 	}
+	if err := requireMatch(cfg, data, filtered); err != nil {
+		return err
+	}
 
-	return renderList(cmd, cfg, data.provider, filtered.workflows, filtered.warnings)
+	details, err := cmd.Flags().GetBool("details")
+	if err != nil {
+		return fmt.Errorf("parse --details: %w", err)
+	}
+	toc, err := cmd.Flags().GetBool("toc")
+	if err != nil {
+		return fmt.Errorf("parse --toc: %w", err)
+	}
+	groupByPrefix, err := cmd.Flags().GetBool("group-by-prefix")
+	if err != nil {
+		return fmt.Errorf("parse --group-by-prefix: %w", err)
+	}
+	explainSkips, err := cmd.Flags().GetBool("explain-skips")
+	if err != nil {
+		return fmt.Errorf("parse --explain-skips: %w", err)
+	}
+
+	// A listing should be instant, so it probes the machine only when asked
+	// or when --details is there to show what the probes find. Completion
+	// runs on every TAB press and shows no warnings, so it never does.
+	checks, err := checksEnabled(cfg, details)
+	if err != nil {
+		return err
+	}
+	// Naming the checkout runs git too. Markdown shows it in its header, so
+	// there it is probed unless checks are turned off outright.
+	markdown := strings.EqualFold(cfg.Format, config.FormatMarkdown)
+	probeCheckout := checks
+	if markdown {
+		probeCheckout, _ = checksEnabled(cfg, true)
+	}
+	if probeCheckout && !completion {
+		filtered.meta = describeCheckout(root)
+	}
+	if checks && !completion {
+		if filtered, err = applyChecks(cfg, filtered); err != nil {
+			return err
+		}
+		// --strict-git stops runs; a listing only reports.
+		warnOnly := cfg
+		warnOnly.StrictGit = false
+		if filtered, err = checkGitState(warnOnly, root, filtered, false); err != nil {
+			return err
+		}
+	}
+
+	if markdown {
+		// Documentation should show uses: steps too, which filtering drops
+		// because they never run locally.
+		filtered.workflows = withUsesSteps(data.workflows, filtered.workflows)
+	}
+
+	if completion {
+		// Nothing but entries, not even when there are none.
+		renderer := output.NewCompletion(cmd.OutOrStdout())
+		renderer.Declared = data.workflows
+		return renderer.RenderList(filtered.workflows)
+	}
+
+	return renderList(cmd, cfg, filtered, listOptions{details: details, toc: toc, groupByPrefix: groupByPrefix, explainSkips: explainSkips})
 }
 
-func renderList(cmd *cobra.Command, cfg config.Config, providerName string, workflows []provider.Workflow, warnings []provider.Warning) error {
+// listOptions carries the list command's local presentation flags.
+type listOptions struct {
+	details       bool
+	toc           bool
+	groupByPrefix bool
+	explainSkips  bool
+}
+
+func renderList(cmd *cobra.Command, cfg config.Config, data pipelineData, opts listOptions) error {
+	workflows, warnings, versions := runner.RedactWorkflows(cfg.RedactEnvPatterns, data.workflows), data.warnings, data.versions
 	if len(workflows) == 0 {
 		fmt.Fprintln(cmd.OutOrStdout(), "No matching jobs or steps")
 		return nil
@@ -49,18 +134,42 @@ func renderList(cmd *cobra.Command, cfg config.Config, providerName string, work
 
 	switch strings.ToLower(cfg.Format) {
 	case config.FormatPretty:
-		renderer := output.NewPretty(cmd.OutOrStdout())
+		renderer := newPretty(cmd, cfg)
+		renderer.GroupByPrefix = opts.groupByPrefix
+		if err := renderer.RenderList(workflows); err != nil {
+			return err
+		}
+		if opts.details {
+			if err := renderer.RenderVersions(versions); err != nil {
+				return err
+			}
+			if err := renderer.RenderContainers(workflows); err != nil {
+				return err
+			}
+		}
+		if err := renderer.RenderLocalCoverage(data.localCoverage, opts.explainSkips); err != nil {
+			return err
+		}
+	case config.FormatMarkdown:
+		renderer := output.NewMarkdown(cmd.OutOrStdout())
+		renderer.TOC = opts.toc
+		renderer.Meta = data.meta
 		if err := renderer.RenderList(workflows); err != nil {
 			return err
 		}
 	case config.FormatJSON:
 		report := output.Report{
-			Provider:  providerName,
-			Workflows: workflows,
-			Summary:   computeListSummary(workflows),
-			Warnings:  warningsList,
+			Provider:      data.provider,
+			Workflows:     workflows,
+			Summary:       computeListSummary(data),
+			LocalCoverage: &data.localCoverage,
+			Versions:      versions,
+			Warnings:      warningsList,
+			Infos:         collapseWarnings(data.infos),
+			Meta:          data.meta,
 		}
 		renderer := output.NewJSON(cmd.OutOrStdout())
+		renderer.Compact = cfg.Compact
 		if err := renderer.Render(report); err != nil {
 			return err
 		}
@@ -68,62 +177,152 @@ func renderList(cmd *cobra.Command, cfg config.Config, providerName string, work
 		return fmt.Errorf("unsupported format %q", cfg.Format)
 	}
 
-	if len(warningsList) > 0 && cfg.Format == config.FormatPretty {
+	// Markdown is meant to be pasted into docs, so warnings stay on stderr.
+	if len(warningsList) > 0 && (cfg.Format == config.FormatPretty || cfg.Format == config.FormatMarkdown) {
 		for _, msg := range warningsList {
 			fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", msg)
 		}
+		printInfos(cmd.ErrOrStderr(), cfg, data.infos)
 	}
 
 	return nil
 }
 
-func computeListSummary(workflows []provider.Workflow) report.Summary {
-	var jobs, steps int
-	for _, wf := range workflows {
-		jobs += len(wf.Jobs)
+// withUsesSteps restores the uses: steps of the original workflows into the
+// filtered ones, keeping workflow order and any overrides already applied.
+func withUsesSteps(all, filtered []provider.Workflow) []provider.Workflow {
+	originals := make(map[string]provider.Job)
+	for _, wf := range all {
 		for _, job := range wf.Jobs {
-			steps += len(job.Steps)
+			originals[output.JobID(wf, job)] = job
 		}
 	}
-	return report.Summary{
-		TotalWorkflows: len(workflows),
-		TotalJobs:      jobs,
-		TotalSteps:     steps,
-		Duration:       0,
-		DurationMS:     0,
-		ExitCode:       0,
+
+	out := make([]provider.Workflow, len(filtered))
+	for i, wf := range filtered {
+		jobs := make([]provider.Job, len(wf.Jobs))
+		for j, job := range wf.Jobs {
+			original, ok := originals[output.JobID(wf, job)]
+			if !ok {
+				jobs[j] = job
+				continue
+			}
+			steps := make([]provider.Step, 0, len(original.Steps))
+			next := 0
+			for _, step := range original.Steps {
+				switch {
+				case step.Run == "":
+					steps = append(steps, step)
+				case next < len(job.Steps) && job.Steps[next].Name == step.Name:
+					steps = append(steps, job.Steps[next])
+					next++
+				}
+			}
+			job.Steps = steps
+			jobs[j] = job
+		}
+		wf.Jobs = jobs
+		out[i] = wf
 	}
+	return out
 }
 
+// computeListSummary counts the jobs and steps data selects against the
+// whole pipeline it was filtered from.
+func computeListSummary(data pipelineData) report.Summary {
+	selected := report.CountTotals(data.workflows)
+	return data.totals.Apply(report.Summary{
+		SelectedJobs:  selected.Jobs,
+		SelectedSteps: selected.Steps,
+	})
+}
+
+// collapseWarnings formats warnings as workflow:job: message, or
+// workflow: message when no job is involved, as for the config file.
+// Warnings about no workflow, such as those about the git checkout, are
+// just the message.
 func collapseWarnings(warnings []provider.Warning) []string {
 	if len(warnings) == 0 {
 		return nil
 	}
 	out := make([]string, 0, len(warnings))
 	for _, w := range warnings {
+		if w.Workflow == "" {
+			out = append(out, w.Message)
+			continue
+		}
+		if w.Job == "" {
+			out = append(out, fmt.Sprintf("%s: %s", w.Workflow, w.Message))
+			continue
+		}
 		out = append(out, fmt.Sprintf("%s:%s: %s", w.Workflow, w.Job, w.Message))
 	}
 	return out
 }
 
-func loadConfig(cmd *cobra.Command) (config.Config, string, error) {
+// printInfos writes info notices below the warnings, only when show_info
+// asks for them.
+func printInfos(w io.Writer, cfg config.Config, infos []provider.Warning) {
+	if !cfg.ShowInfo {
+		return
+	}
+	for _, msg := range collapseWarnings(infos) {
+		fmt.Fprintf(w, "info: %s\n", msg)
+	}
+}
+
+// loadConfig merges the config file, environment, and flags. Any
+// workflowArgs are positional workflow paths and join those given with
+// --workflow; discovery drops duplicates.
+func loadConfig(cmd *cobra.Command, workflowArgs ...string) (config.Config, string, error) {
 	root, err := os.Getwd()
 	if err != nil {
 		return config.Config{}, "", fmt.Errorf("determine working directory: %w", err)
 	}
+	cfg, err := loadConfigAt(cmd, root, func(flags *config.FlagValues) {
+		flags.Workflows.Values = append(flags.Workflows.Values, workflowArgs...)
+	})
+	if err != nil {
+		return config.Config{}, "", err
+	}
+	return cfg, root, nil
+}
 
+// loadConfigAt loads the effective config of the project at root. fill may
+// adjust the command-line flag values before they are applied.
+func loadConfigAt(cmd *cobra.Command, root string, fill func(*config.FlagValues)) (config.Config, error) {
 	cfg, err := config.Load(root)
 	if err != nil {
-		return config.Config{}, "", err
+		return config.Config{}, err
+	}
+	if err := config.ApplyEnv(&cfg, os.LookupEnv); err != nil {
+		return config.Config{}, err
 	}
 
 	flags, err := gatherFlags(cmd)
 	if err != nil {
-		return config.Config{}, "", err
+		return config.Config{}, err
 	}
+	fill(&flags)
 	config.ApplyFlags(&cfg, flags)
+	if err := config.CheckFormat(cmd.Name(), cfg.Format); err != nil {
+		return config.Config{}, err
+	}
+	if err := config.CheckLayout(cfg.Output.Layout); err != nil {
+		return config.Config{}, err
+	}
+	if _, _, err := config.StallDurations(cfg); err != nil {
+		return config.Config{}, err
+	}
+	if err := config.CheckRedactEnvPatterns(cfg.RedactEnvPatterns); err != nil {
+		return config.Config{}, err
+	}
+	logConfigOrigins(debugLog(cmd), cfg)
+	// Failure blocks are rendered by several commands, so the noise set is
+	// applied here once the config is known.
+	output.SetNoisePatterns(patterns.Exprs(effectivePatterns(cfg, patterns.Noise)))
 
-	return cfg, root, nil
+	return cfg, nil
 }
 
 func resolveProvider(input string) (string, error) {
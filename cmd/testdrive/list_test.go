@@ -2,9 +2,16 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/spf13/cobra"
 )
 
 func TestListCommandBasic(t *testing.T) {
@@ -54,9 +61,34 @@ func TestListCommandFilters(t *testing.T) {
 	}
 }
 
+func TestListAndRunMultiDocumentWorkflow(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	for _, args := range [][]string{{"list"}, {"run", "--dry-run"}} {
+		cmd := newRootCmd()
+		cmd.SetArgs(append(args, "--workflow", "testdata/workflows/ci_multi_doc.yml"))
+		buf := &bytes.Buffer{}
+		cmd.SetOut(buf)
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%s: %v", args[0], err)
+		}
+		for _, want := range []string{
+			"Workflow Generated Lint (testdata/workflows/ci_multi_doc.yml#1)",
+			"Workflow Generated Test (testdata/workflows/ci_multi_doc.yml#2)",
+		} {
+			if !strings.Contains(buf.String(), want) {
+				t.Fatalf("%s output lacks %q:\n%s", args[0], want, buf.String())
+			}
+		}
+	}
+}
+
 func TestListCommandJSON(t *testing.T) {
 	root := projectRoot(t)
 	chdir(t, root)
+	useGit(t, goldenCheckout)
 
 	cmd := newRootCmd()
 	cmd.SetArgs([]string{"list", "--workflow", "testdata/workflows/ci_basic.yml", "--format", "json"})
@@ -75,6 +107,150 @@ func TestListCommandJSON(t *testing.T) {
 	}
 }
 
+func TestListCommandJSONCompact(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+	useGit(t, goldenCheckout)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"list", "--workflow", "testdata/workflows/ci_basic.yml", "--format", "json", "--compact"})
+
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+
+	var indented bytes.Buffer
+	want := readGolden(t, filepath.Join(root, "testdata", "golden", "list_basic.json"))
+	if err := json.Indent(&indented, buf.Bytes(), "", "  "); err != nil {
+		t.Fatalf("indent compact output: %v", err)
+	}
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("expected single-line output, got:\n%s", buf.String())
+	}
+	if diff := diffStrings(want, indented.String()); diff != "" {
+		t.Fatalf("compact output differs from the indented golden:\n%s", diff)
+	}
+}
+
+func TestListCommandMarkdown(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+	useGit(t, goldenCheckout)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{
+		"list",
+		"--workflow", "testdata/workflows/ci_basic.yml",
+		"--workflow", "testdata/workflows/ci_skips.yml",
+		"--workflow", "testdata/workflows/ci_workdir.yml",
+		"--format", "markdown",
+		"--toc",
+	})
+
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+
+	want := readGolden(t, filepath.Join(root, "testdata", "golden", "list_markdown.md"))
+	if diff := diffStrings(want, buf.String()); diff != "" {
+		t.Fatalf("unexpected output:\n%s", diff)
+	}
+}
+
+func TestListCommandCompletion(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{
+		"list",
+		"--workflow", "testdata/workflows/ci_envs.yml",
+		"--workflow", "testdata/workflows/ci_grouped.yml",
+		"--format", "completion",
+	})
+
+	buf, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(errBuf)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	want := readGolden(t, filepath.Join(root, "testdata", "golden", "list_completion.txt"))
+	if diff := diffStrings(want, buf.String()); diff != "" {
+		t.Fatalf("unexpected output:\n%s", diff)
+	}
+	if errBuf.Len() != 0 {
+		t.Fatalf("completion output should print no warnings, got:\n%s", errBuf.String())
+	}
+}
+
+func TestListCommandCompletionKeepsStepNumbersUnderFilters(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"list", "--workflow", "testdata/workflows/ci_grouped.yml", "--only-step", "Lint", "--format", "completion"})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	want := "job\ttestdata/workflows/ci_grouped.yml\ttest\ttest\nstep\ttestdata/workflows/ci_grouped.yml\ttest\t6\tLint\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func BenchmarkListCompletion30Workflows(b *testing.B) {
+	dir := b.TempDir()
+	workflows := filepath.Join(dir, ".github", "workflows")
+	if err := os.MkdirAll(workflows, 0o755); err != nil {
+		b.Fatalf("mkdir: %v", err)
+	}
+	for i := 0; i < 30; i++ {
+		var doc strings.Builder
+		fmt.Fprintf(&doc, "name: Workflow %d\njobs:\n", i)
+		for j := 0; j < 5; j++ {
+			fmt.Fprintf(&doc, "  job%d:\n    runs-on: ubuntu-latest\n    steps:\n      - uses: actions/checkout@v4\n", j)
+			for k := 0; k < 10; k++ {
+				fmt.Fprintf(&doc, "      - name: Step %d\n        run: echo %d\n", k, k)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(workflows, fmt.Sprintf("wf%02d.yml", i)), []byte(doc.String()), 0o644); err != nil {
+			b.Fatalf("write workflow: %v", err)
+		}
+	}
+	prev, err := os.Getwd()
+	if err != nil {
+		b.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		b.Fatalf("chdir: %v", err)
+	}
+	b.Cleanup(func() { os.Chdir(prev) })
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"list", "--format", "completion", "--no-cache"})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		if err := cmd.Execute(); err != nil {
+			b.Fatalf("command execute: %v", err)
+		}
+	}
+}
+
 func TestListCommandConfig(t *testing.T) {
 	root := projectRoot(t)
 	tmp := t.TempDir()
@@ -140,6 +316,36 @@ func chdir(t *testing.T, dir string) {
 	})
 }
 
+// executeRoot runs a fresh root command with args and returns what it wrote
+// to stdout and stderr.
+func executeRoot(t *testing.T, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	cmd := newRootCmd()
+	cmd.SetArgs(args)
+	out, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(errBuf)
+	err = cmd.Execute()
+	return out.String(), errBuf.String(), err
+}
+
+// writeFiles creates a temp directory holding files, keyed by their
+// slash-separated path within it, and returns the directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir %q: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
 func readGolden(t *testing.T, path string) string {
 	t.Helper()
 	data, err := os.ReadFile(path)
@@ -181,3 +387,412 @@ func diffStrings(want, got string) string {
 	}
 	return "--- want\n" + want + "\n--- got\n" + got
 }
+
+func TestListCommandOverrides(t *testing.T) {
+	root := projectRoot(t)
+	tmp := t.TempDir()
+	copyDir(t, filepath.Join(root, "testdata"), filepath.Join(tmp, "testdata"))
+
+	configYAML := []byte(`workflows:
+  - testdata/workflows/ci_run.yml
+overrides:
+  - step: Failing
+    run: echo fixed
+  - job: deploy
+    skip: true
+`)
+	if err := os.WriteFile(filepath.Join(tmp, ".testdrive.yml"), configYAML, 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	chdir(t, tmp)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"list"})
+	out := &bytes.Buffer{}
+	errBuf := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(errBuf)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if !strings.Contains(out.String(), "• Failing Step (overridden)") {
+		t.Fatalf("expected overridden marker, got %q", out.String())
+	}
+	if !strings.Contains(errBuf.String(), "warning: .testdrive.yml: override for job \"deploy\" did not match any steps\n") {
+		t.Fatalf("expected unmatched override warning, got %q", errBuf.String())
+	}
+}
+
+func TestListCommandSkipWorkflow(t *testing.T) {
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, ".github", "workflows")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	ci := []byte("name: CI\njobs:\n  test:\n    steps:\n      - run: echo ci\n")
+	if err := os.WriteFile(filepath.Join(dir, "ci.yml"), ci, 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	// An unparseable file proves excluded workflows are never opened.
+	if err := os.WriteFile(filepath.Join(dir, "release.yml"), []byte("jobs: [\n"), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	chdir(t, tmp)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"list", "--skip-workflow", "release.yml", "--verbose"})
+	out := &bytes.Buffer{}
+	errBuf := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(errBuf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if !strings.Contains(out.String(), "Workflow CI") {
+		t.Fatalf("expected ci workflow listed, got %q", out.String())
+	}
+	if got := strings.Count(errBuf.String(), "info: excluded 1 workflow(s): .github/workflows/release.yml"); got != 1 {
+		t.Fatalf("expected exclusion reported once, got %q", errBuf.String())
+	}
+
+	// Explicit workflow paths bypass exclusions.
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"list", "--skip-workflow", "ci.yml", "--workflow", ".github/workflows/ci.yml"})
+	out.Reset()
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if !strings.Contains(out.String(), "Workflow CI") {
+		t.Fatalf("expected explicit workflow to bypass exclusion, got %q", out.String())
+	}
+}
+
+func TestListCommandWorkflowDirs(t *testing.T) {
+	tmp := t.TempDir()
+	for dir, name := range map[string]string{".github": "GitHub CI", ".forgejo": "Forgejo CI"} {
+		path := filepath.Join(tmp, dir, "workflows")
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		workflow := "name: " + name + "\njobs:\n  test:\n    steps:\n      - run: echo hi\n"
+		if err := os.WriteFile(filepath.Join(path, "ci.yml"), []byte(workflow), 0o644); err != nil {
+			t.Fatalf("write workflow: %v", err)
+		}
+	}
+	chdir(t, tmp)
+
+	list := func() string {
+		t.Helper()
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"list"})
+		out := &bytes.Buffer{}
+		cmd.SetOut(out)
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("command execute: %v", err)
+		}
+		return out.String()
+	}
+
+	out := list()
+	github, forgejo := strings.Index(out, "Workflow GitHub CI"), strings.Index(out, "Workflow Forgejo CI")
+	if github < 0 || forgejo < github {
+		t.Fatalf("expected both workflows, GitHub's first, got:\n%s", out)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmp, ".testdrive.yml"), []byte("workflow_dirs:\n  - .forgejo/workflows\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if out := list(); strings.Contains(out, "GitHub CI") || !strings.Contains(out, "Workflow Forgejo CI") {
+		t.Fatalf("expected only the configured directory, got:\n%s", out)
+	}
+}
+
+func TestListCommandPositionalWorkflows(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cases := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "positional only",
+			args: []string{"list", "testdata/workflows/ci_basic.yml", "testdata/workflows/ci_envs.yml"},
+			want: []string{"testdata/workflows/ci_basic.yml", "testdata/workflows/ci_envs.yml"},
+		},
+		{
+			name: "mixed with flag",
+			args: []string{"list", "testdata/workflows/ci_envs.yml", "--workflow", "testdata/workflows/ci_basic.yml"},
+			want: []string{"testdata/workflows/ci_basic.yml", "testdata/workflows/ci_envs.yml"},
+		},
+		{
+			name: "duplicates collapse",
+			args: []string{"list", "./testdata/workflows/ci_basic.yml", "--workflow", "testdata/workflows/ci_basic.yml"},
+			want: []string{"testdata/workflows/ci_basic.yml"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newRootCmd()
+			cmd.SetArgs(append(tc.args, "--format", "json"))
+			buf := &bytes.Buffer{}
+			cmd.SetOut(buf)
+			cmd.SetErr(&bytes.Buffer{})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("command execute: %v", err)
+			}
+			var decoded output.Report
+			if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+				t.Fatalf("decode output: %v", err)
+			}
+			var got []string
+			for _, wf := range decoded.Workflows {
+				got = append(got, wf.Path)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("workflows = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"list", "testdata/workflows/missing.yml"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), `workflow "testdata/workflows/missing.yml" not found`) {
+		t.Fatalf("expected missing workflow error, got %v", err)
+	}
+}
+
+func TestCompleteWorkflowFiles(t *testing.T) {
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, ".github", "workflows")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"backend.yaml", "ci.yml"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("jobs: {}\n"), 0o644); err != nil {
+			t.Fatalf("write workflow: %v", err)
+		}
+	}
+	chdir(t, tmp)
+
+	complete := func(args ...string) string {
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{cobra.ShellCompRequestCmd}, args...))
+		buf := &bytes.Buffer{}
+		cmd.SetOut(buf)
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("complete %v: %v", args, err)
+		}
+		return buf.String()
+	}
+
+	if got := complete("run", ""); got != ".github/workflows/backend.yaml\n.github/workflows/ci.yml\n:4\n" {
+		t.Fatalf("unexpected completions:\n%s", got)
+	}
+	if got := complete("list", ".github/workflows/ci.yml", ""); got != ".github/workflows/backend.yaml\n:4\n" {
+		t.Fatalf("expected named workflow excluded:\n%s", got)
+	}
+	if got := complete("run", "scripts/"); got != "yml\nyaml\n:8\n" {
+		t.Fatalf("expected YAML file fallback:\n%s", got)
+	}
+}
+
+func TestListCommandSuppressWarnings(t *testing.T) {
+	root := projectRoot(t)
+	tmp := t.TempDir()
+	copyDir(t, filepath.Join(root, "testdata"), filepath.Join(tmp, "testdata"))
+	configYAML := []byte("workflows:\n  - testdata/workflows/ci_services_matrix.yml\nsuppress_warnings:\n  - matrix_unsupported\n")
+	if err := os.WriteFile(filepath.Join(tmp, ".testdrive.yml"), configYAML, 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	chdir(t, tmp)
+
+	run := func(args ...string) (string, error) {
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"list", "--format", "json"}, args...))
+		buf := &bytes.Buffer{}
+		cmd.SetOut(buf)
+		cmd.SetErr(buf)
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+
+	out, err := run()
+	if err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if strings.Contains(out, "strategy.matrix") {
+		t.Fatalf("matrix warning should be suppressed:\n%s", out)
+	}
+	for _, want := range []string{"services are not supported", "job-level if condition", "unsupported if condition"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q to remain:\n%s", want, out)
+		}
+	}
+
+	out, err = run("--suppress", "services_unsupported")
+	if err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if strings.Contains(out, "services are not supported") || !strings.Contains(out, "strategy.matrix") {
+		t.Fatalf("--suppress should replace the configured kinds:\n%s", out)
+	}
+
+	_, err = run("--suppress", "matrix")
+	if err == nil || !strings.Contains(err.Error(), `unknown warning kind "matrix"`) || !strings.Contains(err.Error(), "matrix_unsupported") {
+		t.Fatalf("expected unknown kind error listing valid kinds, got %v", err)
+	}
+}
+
+func TestEnvironmentGoldens(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cases := []struct {
+		name   string
+		args   []string
+		golden string
+	}{
+		{"list", []string{"list", "--workflow", "testdata/workflows/ci_environments.yml"}, "list_environments.txt"},
+		{"run dry", []string{"run", "--workflow", "testdata/workflows/ci_environments.yml", "--dry-run", "--explain-skips", "--allow-environment", "staging"}, "run_environments_dry.txt"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newRootCmd()
+			cmd.SetArgs(tc.args)
+			buf := &bytes.Buffer{}
+			cmd.SetOut(buf)
+			cmd.SetErr(&bytes.Buffer{})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("command execute: %v", err)
+			}
+			want := readGolden(t, filepath.Join(root, "testdata", "golden", tc.golden))
+			if diff := diffStrings(want, buf.String()); diff != "" {
+				t.Fatalf("unexpected output:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListLocalCoverage(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"list", "--workflow", "testdata/workflows/ci_coverage.yml", "--explain-skips"})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	want := readGolden(t, filepath.Join(root, "testdata", "golden", "list_coverage.txt"))
+	if diff := diffStrings(want, buf.String()); diff != "" {
+		t.Fatalf("unexpected output:\n%s", diff)
+	}
+
+	// Filters and suppressed warnings do not change the estimate.
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"list", "--workflow", "testdata/workflows/ci_coverage.yml", "--job", "build", "--suppress", "container_unsupported", "--format", "json"})
+	buf = &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	var decoded output.Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	cov := decoded.LocalCoverage
+	if cov == nil {
+		t.Fatalf("expected local_coverage in report")
+	}
+	if cov.LocalSteps != 3 || cov.TotalSteps != 9 || cov.Unsupported["container_unsupported"] != 1 {
+		t.Fatalf("unexpected coverage: %+v", cov)
+	}
+}
+
+func TestListDetailsShowsContainers(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"list", "--workflow", "testdata/workflows/ci_coverage.yml", "--details", "--no-version-check"})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if !strings.Contains(buf.String(), "CONTAINERS:\n  Coverage CI / integration  ruby:3.3\n") {
+		t.Fatalf("expected the integration job's container, got:\n%s", buf.String())
+	}
+
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"list", "--workflow", "testdata/workflows/ci_coverage.yml", "--format", "json", "--no-version-check"})
+	buf.Reset()
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"container": {`) || !strings.Contains(buf.String(), `"image": "ruby:3.3"`) {
+		t.Fatalf("expected the container in JSON output, got:\n%s", buf.String())
+	}
+}
+
+func TestListInfoNotices(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	list := func(args ...string) (string, string) {
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"list", "--workflow", "testdata/workflows/ci_exotic_keys.yml", "--no-version-check"}, args...))
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		cmd.SetOut(stdout)
+		cmd.SetErr(stderr)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("command execute: %v", err)
+		}
+		return stdout.String(), stderr.String()
+	}
+
+	_, stderr := list()
+	if strings.Contains(stderr, "info: ") || !strings.Contains(stderr, "warning: ") {
+		t.Fatalf("expected warnings without info notices by default, got:\n%s", stderr)
+	}
+
+	_, stderr = list("--show-info")
+	for _, want := range []string{
+		`info: testdata/workflows/ci_exotic_keys.yml: "permissions" is not relevant for local execution`,
+		`info: testdata/workflows/ci_exotic_keys.yml:release: unknown key "retry" is ignored`,
+		`info: testdata/workflows/ci_exotic_keys.yml:release: step "Publish": unknown key "retries" is ignored`,
+	} {
+		if !strings.Contains(stderr, want+"\n") {
+			t.Fatalf("expected %q, got:\n%s", want, stderr)
+		}
+	}
+
+	stdout, _ := list("--format", "json")
+	var decoded output.Report
+	if err := json.Unmarshal([]byte(stdout), &decoded); err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if len(decoded.Infos) != 12 {
+		t.Fatalf("expected every notice in JSON output, got %q", decoded.Infos)
+	}
+	for _, w := range decoded.Warnings {
+		if strings.Contains(w, "not relevant for local execution") {
+			t.Fatalf("expected notices apart from warnings, got %q", decoded.Warnings)
+		}
+	}
+}
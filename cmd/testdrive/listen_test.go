@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/listen"
+	"github.com/bgricker/testdrive/internal/output"
+)
+
+const listenWorkflow = `name: CI
+jobs:
+  test:
+    steps:
+      - name: Wait
+        run: while [ ! -f release ]; do sleep 0.05; done
+      - name: Done
+        run: echo done
+`
+
+func TestRunCommandListen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a Unix socket and a POSIX shell")
+	}
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ci.yml"), []byte(listenWorkflow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, root)
+	sock := filepath.Join(t.TempDir(), "td.sock")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--workflow", "ci.yml", "--no-lock", "--listen", listen.UnixPrefix + sock})
+	stderr := &bytes.Buffer{}
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(stderr)
+	done := make(chan error, 1)
+	go func() { done <- cmd.Execute() }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	// Wait until the run is inside its first step.
+	deadline := time.Now().Add(10 * time.Second)
+	var status listen.Status
+	for {
+		if resp, err := client.Get("http://testdrive/status"); err == nil {
+			json.NewDecoder(resp.Body).Decode(&status)
+			resp.Body.Close()
+			if len(status.Jobs) == 1 && status.Jobs[0].Step == "Wait" {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run never reported its first step; last status %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status.State != listen.StateRunning || status.Jobs[0].Status != "running" || status.Jobs[0].ID != "ci.yml#test" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	resp, err := client.Get("http://testdrive/events")
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp.Body.Close()
+	if err := os.WriteFile(filepath.Join(root, "release"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var event output.StreamEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("decode event %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event.Event)
+	}
+	want := "start job_started step_started step_finished step_started step_finished job_finished summary"
+	if got := strings.Join(events, " "); got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("run: %v\n%s", err, stderr)
+	}
+	if !strings.Contains(stderr.String(), "listening on unix:"+sock+"\n") {
+		t.Fatalf("stderr does not name the address:\n%s", stderr)
+	}
+	if _, err := os.Lstat(sock); !os.IsNotExist(err) {
+		t.Fatalf("socket left behind after the run: %v", err)
+	}
+}
+
+func TestRunCommandListenRejectsRemoteAddress(t *testing.T) {
+	chdir(t, projectRoot(t))
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--workflow", "testdata/workflows/ci_basic.yml", "--dry-run", "--listen", "0.0.0.0:0"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "not a loopback address") {
+		t.Fatalf("expected a remote address to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/lockfile"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// lockPath is the run lock, relative to the repository root.
+const lockPath = ".testdrive/lock"
+
+// acquireRunLock keeps a second run in the same repository from starting
+// while this one writes its history, logs, and temp files. Dry runs and
+// --no-lock skip it. The returned function releases the lock.
+func acquireRunLock(cmd *cobra.Command, cfg config.Config, root string) (func(), error) {
+	noLock, err := cmd.Flags().GetBool("no-lock")
+	if err != nil {
+		return nil, fmt.Errorf("parse --no-lock: %w", err)
+	}
+	if noLock || cfg.DryRun {
+		return func() {}, nil
+	}
+	lock, err := lockfile.Acquire(filepath.Join(root, filepath.FromSlash(lockPath)), time.Now())
+	var locked *lockfile.LockedError
+	if errors.As(err, &locked) {
+		if locked.Holder.PID == 0 {
+			return nil, fmt.Errorf("another testdrive run is active; pass --no-lock to ignore")
+		}
+		age := output.FormatDuration(time.Since(locked.Holder.Started).Truncate(time.Second))
+		return nil, fmt.Errorf("another testdrive run (pid %d, started %s ago) is active; pass --no-lock to ignore", locked.Holder.PID, age)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() { lock.Release() }, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/lockfile"
+)
+
+const lockWorkflow = `name: CI
+jobs:
+  test:
+    steps:
+      - name: Touch
+        run: echo ran >> ran.txt
+`
+
+func TestRunCommandLock(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ci.yml"), []byte(lockWorkflow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, root)
+	run := func(args ...string) error {
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"run", "--workflow", "ci.yml"}, args...))
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		return cmd.Execute()
+	}
+
+	path := filepath.Join(root, ".testdrive", "lock")
+	lock, err := lockfile.Acquire(path, time.Now().Add(-90*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = run()
+	if err == nil {
+		t.Fatal("run succeeded while another run held the lock")
+	}
+	want := fmt.Sprintf("another testdrive run (pid %d, started 1m30s ago) is active; pass --no-lock to ignore", os.Getpid())
+	if err.Error() != want {
+		t.Fatalf("error = %q, want %q", err, want)
+	}
+	if _, statErr := os.Stat(filepath.Join(root, "ran.txt")); !os.IsNotExist(statErr) {
+		t.Fatal("locked run executed steps")
+	}
+	if err := run("--dry-run"); err != nil {
+		t.Fatalf("--dry-run while locked: %v", err)
+	}
+	if err := run("--no-lock"); err != nil {
+		t.Fatalf("--no-lock while locked: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "ran.txt")); err != nil {
+		t.Fatalf("--no-lock run did not execute steps: %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(); err != nil {
+		t.Fatalf("run after release: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Fatalf("lock file after run = %q, want it emptied", data)
+	}
+}
@@ -1,13 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	if err := newRootCmd().Execute(); err != nil {
+	// Ctrl-C or SIGTERM cancels the run; steps that have not finished are
+	// reported as cancelled.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := newRootCmd().ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		var usage *usageError
+		if errors.As(err, &usage) {
+			os.Exit(exitUsage)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/bgricker/testdrive/internal/runner"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// manifestRepo is one repository listed in a --manifest file. Workflows and
+// Jobs select within it as --workflow and --job would; flags given on the
+// command line take precedence.
+type manifestRepo struct {
+	Name      string   `yaml:"name"`
+	Path      string   `yaml:"path"`
+	Workflows []string `yaml:"workflows"`
+	Jobs      []string `yaml:"jobs"`
+}
+
+// loadManifest reads the repositories listed in the manifest at path.
+// Relative repository paths are resolved against the manifest's directory
+// and each name defaults to the last element of its path.
+func loadManifest(path string) ([]manifestRepo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	var doc struct {
+		Repos []manifestRepo `yaml:"repos"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse manifest %s: %w", path, err)
+	}
+	if len(doc.Repos) == 0 {
+		return nil, fmt.Errorf("manifest %s lists no repos", path)
+	}
+
+	dir := filepath.Dir(path)
+	seen := map[string]bool{}
+	for i := range doc.Repos {
+		repo := &doc.Repos[i]
+		if repo.Path == "" {
+			return nil, fmt.Errorf("manifest %s: repo %d has no path", path, i+1)
+		}
+		if !filepath.IsAbs(repo.Path) {
+			repo.Path = filepath.Join(dir, repo.Path)
+		}
+		repo.Path = filepath.Clean(repo.Path)
+		if repo.Name == "" {
+			repo.Name = filepath.Base(repo.Path)
+		}
+		if seen[repo.Name] {
+			return nil, fmt.Errorf("manifest %s: repo name %q is used twice; set name to tell them apart", path, repo.Name)
+		}
+		seen[repo.Name] = true
+	}
+	return doc.Repos, nil
+}
+
+// runManifest runs every repository in the manifest at path with its own
+// root and config, then reports them together. A repository that fails or
+// cannot be loaded does not stop the others unless --fail-fast is set.
+func runManifest(cmd *cobra.Command, path string, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("--manifest selects workflows per repository; drop the workflow arguments")
+	}
+	for _, name := range []string{"worktree", "interactive", "plan", "replay", "trace", "record"} {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s cannot be combined with --manifest", name)
+		}
+	}
+	failFast, err := cmd.Flags().GetBool("fail-fast")
+	if err != nil {
+		return fmt.Errorf("parse --fail-fast: %w", err)
+	}
+	repos, err := loadManifest(path)
+	if err != nil {
+		return err
+	}
+	// Rendering follows the settings where the command was started.
+	cfg, _, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	pretty := strings.ToLower(cfg.Format) == config.FormatPretty
+	out := cmd.OutOrStdout()
+
+	runs := make([]report.RepoRun, 0, len(repos))
+	var workflows []provider.Workflow
+	var warnings []string
+	stopped := false
+	for _, repo := range repos {
+		if stopped {
+			runs = append(runs, report.RepoRun{Name: repo.Name, Path: repo.Path, Status: report.RepoNotRun})
+			continue
+		}
+		if pretty {
+			fmt.Fprintf(out, "==> %s (%s)\n", repo.Name, repo.Path)
+		}
+		run, ran, err := runRepo(cmd, repo)
+		if err != nil {
+			run.Status, run.Error = report.RepoError, err.Error()
+			if pretty {
+				fmt.Fprintf(out, "error: %v\n\n", err)
+			}
+		} else if pretty {
+			renderer := output.NewPrettyLayout(out, prettyLayout(cfg))
+			renderer.TailLines = cfg.TailLines
+			if run.Summary.SelectedSteps == 0 {
+				fmt.Fprintln(out, "No matching jobs or steps")
+			} else if err := renderer.RenderResults(run.Steps, run.Summary); err != nil {
+				return err
+			}
+			for _, msg := range run.Warnings {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s: %s\n", repo.Name, msg)
+			}
+			fmt.Fprintln(out)
+		}
+		for _, wf := range ran {
+			wf.Path = repo.Name + "/" + wf.Path
+			workflows = append(workflows, wf)
+		}
+		for _, msg := range run.Warnings {
+			warnings = append(warnings, repo.Name+": "+msg)
+		}
+		runs = append(runs, run)
+		// An interrupt stops the remaining repositories as --fail-fast does.
+		if (run.Status != report.RepoPassed && failFast) || cmd.Context().Err() != nil {
+			stopped = true
+		}
+	}
+
+	summaries := make([]report.Summary, 0, len(runs))
+	failed := 0
+	for _, run := range runs {
+		summaries = append(summaries, run.Summary)
+		if run.Status != report.RepoPassed {
+			failed++
+		}
+	}
+	total := report.CombineSummaries(summaries...)
+
+	switch strings.ToLower(cfg.Format) {
+	case config.FormatPretty:
+		if err := output.NewPrettyLayout(out, prettyLayout(cfg)).RenderRepos(runs, total); err != nil {
+			return err
+		}
+	case config.FormatJSON:
+		providerName, err := resolveProvider(cfg.Provider)
+		if err != nil {
+			return err
+		}
+		renderer := output.NewJSON(out)
+		renderer.Compact = cfg.Compact
+		err = renderer.Render(output.Report{
+			Provider:  providerName,
+			Workflows: runner.RedactWorkflows(cfg.RedactEnvPatterns, workflows),
+			Summary:   total,
+			Warnings:  warnings,
+			Repos:     runs,
+
+			Invocation: newInvocation(cmd, cfg, false),
+		})
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+
+	if cmd.Context().Err() != nil {
+		return fmt.Errorf("run interrupted: %d step(s) cancelled", total.Cancelled)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d repositories did not pass", failed, len(repos))
+	}
+	return nil
+}
+
+// runRepo loads and runs one manifest repository, returning its results
+// with workflow paths prefixed by the repository name, and the workflows
+// it ran. Steps run in batch mode, since repositories report one by one.
+func runRepo(cmd *cobra.Command, repo manifestRepo) (report.RepoRun, []provider.Workflow, error) {
+	run := report.RepoRun{Name: repo.Name, Path: repo.Path}
+	if info, err := os.Stat(repo.Path); err != nil || !info.IsDir() {
+		return run, nil, fmt.Errorf("%s is not a directory", repo.Path)
+	}
+	cfg, err := loadConfigAt(cmd, repo.Path, func(flags *config.FlagValues) {
+		if len(flags.Workflows.Values) == 0 {
+			flags.Workflows.Values = repo.Workflows
+		}
+		if len(flags.Jobs.Values) == 0 {
+			flags.Jobs.Values = repo.Jobs
+		}
+	})
+	if err != nil {
+		return run, nil, err
+	}
+	log := debugLog(cmd).With("repo", repo.Name)
+	data, err := loadPipeline(repo.Path, cfg, log)
+	if err != nil {
+		return run, nil, err
+	}
+	filtered, err := applyFilters(data, cfg, log)
+	if err != nil {
+		return run, nil, err
+	}
+	checks, err := checksEnabled(cfg, true)
+	if err != nil {
+		return run, nil, err
+	}
+	if checks {
+		if filtered, err = applyChecks(cfg, filtered); err != nil {
+			return run, nil, err
+		}
+	}
+	release, err := acquireRunLock(cmd, cfg, repo.Path)
+	if err != nil {
+		return run, nil, err
+	}
+	defer release()
+	if !cfg.DryRun && checks {
+		if err := checkRequiredEnv(cfg, filtered); err != nil {
+			return run, nil, err
+		}
+		if filtered, err = checkGitState(cfg, repo.Path, filtered, false); err != nil {
+			return run, nil, err
+		}
+	}
+
+	runOpts := runnerOptions(cmd, cfg, repo.Path, filtered.env)
+	runOpts.Totals = filtered.totals
+	if runOpts.JobDurations, err = scheduleDurations(cfg, repo.Path); err != nil {
+		return run, nil, err
+	}
+	if runOpts.Flaky, err = flakyIndex(cfg, repo.Path); err != nil {
+		return run, nil, err
+	}
+	startedAt := time.Now()
+	results, summary, err := runner.New(runOpts).Run(cmd.Context(), filtered.workflows)
+	if err != nil {
+		return run, nil, err
+	}
+	if cmd.Context().Err() == nil {
+		recordHistory(cmd.ErrOrStderr(), cfg, repo.Path, startedAt, results, summary)
+	}
+
+	run.Steps, run.Summary = results, summary
+	run.Warnings = collapseWarnings(filtered.warnings)
+	xpasses, err := unexpectedPasses(cfg, results)
+	if err != nil {
+		return run, nil, err
+	}
+	run.Warnings = append(run.Warnings, xpasses...)
+	run.PrefixPaths()
+	run.Status = report.RepoPassed
+	if summary.ExitCode != 0 {
+		run.Status = report.RepoFailed
+	}
+	return run, filtered.workflows, nil
+}
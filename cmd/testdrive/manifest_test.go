@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// manifestFixture creates three repositories next to a manifest: api
+// passes, web has a failing job, and broken has an unreadable config.
+func manifestFixture(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"api/.github/workflows/ci.yml":    "name: CI\njobs:\n  test:\n    steps:\n      - run: echo api ok\n",
+		"web/.github/workflows/ci.yml":    "name: CI\njobs:\n  lint:\n    steps:\n      - run: echo lint ok\n  test:\n    steps:\n      - run: exit 3\n",
+		"web/.github/workflows/other.yml": "name: Other\njobs:\n  other:\n    steps:\n      - run: echo other\n",
+		"broken/.github/workflows/ci.yml": "name: CI\njobs:\n  test:\n    steps:\n      - run: echo never\n",
+		"broken/.testdrive.yml":           "format: [\n",
+		"repos.yml": `repos:
+  - path: api
+  - path: web
+    workflows: [.github/workflows/ci.yml]
+  - path: broken
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	chdir(t, dir)
+	return filepath.Join(dir, "repos.yml")
+}
+
+func TestRunManifestJSON(t *testing.T) {
+	manifest := manifestFixture(t)
+
+	out, stderr, err := executeRoot(t, "run", "--manifest", manifest, "--format", "json")
+	if err == nil || err.Error() != "2 of 3 repositories did not pass" {
+		t.Fatalf("expected two repositories to fail, got %v\n%s", err, stderr)
+	}
+	var got output.Report
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("decode report: %v\n%s", err, out)
+	}
+	if len(got.Repos) != 3 {
+		t.Fatalf("expected three repos, got %+v", got.Repos)
+	}
+	api, web, broken := got.Repos[0], got.Repos[1], got.Repos[2]
+	if api.Name != "api" || api.Status != report.RepoPassed || api.Summary.Passed != 1 {
+		t.Fatalf("unexpected api run: %+v", api)
+	}
+	if api.Steps[0].WorkflowPath != "api/.github/workflows/ci.yml" || api.Summary.Jobs[0].WorkflowPath != "api/.github/workflows/ci.yml" {
+		t.Fatalf("expected workflow paths prefixed with the repo name: %+v", api)
+	}
+	// The manifest's workflow list leaves other.yml out of web.
+	if web.Status != report.RepoFailed || web.Summary.Passed != 1 || web.Summary.Failed != 1 || len(web.Steps) != 2 {
+		t.Fatalf("unexpected web run: %+v", web)
+	}
+	if broken.Status != report.RepoError || !strings.Contains(broken.Error, ".testdrive.yml") || broken.Steps != nil {
+		t.Fatalf("unexpected broken run: %+v", broken)
+	}
+	if got.Summary.Passed != 2 || got.Summary.Failed != 1 || got.Summary.TotalJobs != 3 || len(got.Summary.Jobs) != 3 {
+		t.Fatalf("unexpected overall summary: %+v", got.Summary)
+	}
+	if len(got.Workflows) != 2 || got.Workflows[1].Path != "web/.github/workflows/ci.yml" {
+		t.Fatalf("unexpected workflows: %+v", got.Workflows)
+	}
+}
+
+func TestRunManifestPretty(t *testing.T) {
+	manifest := manifestFixture(t)
+
+	out, _, err := executeRoot(t, "run", "--manifest", manifest)
+	if err == nil {
+		t.Fatalf("expected the failing repositories to fail the run")
+	}
+	for _, want := range []string{
+		"==> api (",
+		"==> web (",
+		"✗ step 1",
+		"REPOSITORIES:\n  api     passed  1 passed, 0 failed, 0 skipped",
+		"  web     failed  1 passed, 1 failed, 0 skipped",
+		"  broken  error   ",
+		"SUMMARY: 2 passed, 1 failed, 0 skipped",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunManifestFailFast(t *testing.T) {
+	manifest := manifestFixture(t)
+
+	out, _, err := executeRoot(t, "run", "--manifest", manifest, "--fail-fast", "--format", "json")
+	if err == nil {
+		t.Fatalf("expected the run to fail")
+	}
+	var got output.Report
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("decode report: %v\n%s", err, out)
+	}
+	statuses := []string{got.Repos[0].Status, got.Repos[1].Status, got.Repos[2].Status}
+	if strings.Join(statuses, ",") != "passed,failed,not_run" {
+		t.Fatalf("unexpected statuses %v", statuses)
+	}
+}
+
+func TestRunManifestRejectsBadInput(t *testing.T) {
+	manifest := manifestFixture(t)
+	dup := filepath.Join(filepath.Dir(manifest), "dup.yml")
+	if err := os.WriteFile(dup, []byte("repos:\n  - path: api\n  - path: ./api\n"), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+
+	cases := map[string][]string{
+		"drop the workflow arguments":   {manifest, "ci.yml"},
+		"--plan cannot be combined":     {manifest, "--plan"},
+		`repo name "api" is used twice`: {dup},
+	}
+	for want, args := range cases {
+		if _, _, err := executeRoot(t, append([]string{"run", "--manifest"}, args...)...); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("args %v: expected error containing %q, got %v", args, want, err)
+		}
+	}
+	if _, _, err := executeRoot(t, "run", "--manifest", "", "--fail-fast"); err == nil || err.Error() != "--fail-fast requires --manifest" {
+		t.Fatalf("expected --fail-fast to need --manifest, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/provider/filter"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/bgricker/testdrive/internal/suggest"
+)
+
+// exitUsage is the exit code for a usageError.
+const exitUsage = 2
+
+// usageError reports a command line that cannot do what was asked, such as
+// filters that select nothing. main exits with exitUsage for it.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// requireMatch fails with require_match set when the filters left no run
+// step in filtered. The error names each --job and --only-step pattern that
+// matched nothing in data, with the names it was closest to, and lists the
+// jobs there are.
+func requireMatch(cfg config.Config, data, filtered pipelineData) error {
+	if !cfg.RequireMatch || report.CountTotals(filtered.workflows).Steps > 0 {
+		return nil
+	}
+	jobPatterns, err := filter.Compile(cfg.Jobs)
+	if err != nil {
+		return err
+	}
+	onlyPatterns, err := filter.Compile(cfg.OnlySteps)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, p := range jobPatterns {
+		matched := false
+		for _, wf := range data.workflows {
+			for _, job := range wf.Jobs {
+				matched = matched || filter.MatchJob(job, []filter.Pattern{p})
+			}
+		}
+		if !matched {
+			problems = append(problems, fmt.Sprintf("--job %q matches no job%s", p, didYouMean(p, jobNamesOf(data.workflows))))
+		}
+	}
+	for _, p := range onlyPatterns {
+		matched := false
+		for _, wf := range data.workflows {
+			for _, job := range wf.Jobs {
+				if !filter.MatchJob(job, jobPatterns) {
+					continue
+				}
+				for _, step := range job.Steps {
+					matched = matched || (step.Run != "" && filter.MatchStep(step, []filter.Pattern{p}))
+				}
+			}
+		}
+		if !matched {
+			where := ""
+			if len(jobPatterns) > 0 {
+				where = " in the selected jobs"
+			}
+			problems = append(problems, fmt.Sprintf("--only-step %q matches no run step%s%s", p, where, didYouMean(p, stepNamesOf(data.workflows, jobPatterns))))
+		}
+	}
+	if len(problems) == 0 {
+		problems = append(problems, "the filters together select no run steps: "+describeFilters(cfg))
+	}
+
+	msg := "no matching jobs or steps:\n  " + strings.Join(problems, "\n  ")
+	if names := jobNamesOf(data.workflows); len(names) > 0 {
+		msg += "\navailable jobs: " + strings.Join(names, ", ")
+	}
+	return &usageError{msg: msg}
+}
+
+// didYouMean returns the suggest.Hint for p among names, reading a /regex/
+// as the text between its slashes.
+func didYouMean(p filter.Pattern, names []string) string {
+	input := p.String()
+	if p.Regexp() {
+		input = strings.Trim(input, "/")
+	}
+	return suggest.Hint(input, names)
+}
+
+// jobNamesOf returns the distinct names and IDs of the jobs in workflows
+// that have a run step, in declared order.
+func jobNamesOf(workflows []provider.Workflow) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			if !hasRunStep(job) {
+				continue
+			}
+			for _, name := range []string{job.RawID, job.Name} {
+				if name != "" && !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// stepNamesOf returns the distinct names of the run steps in the jobs of
+// workflows that jobPatterns select.
+func stepNamesOf(workflows []provider.Workflow, jobPatterns []filter.Pattern) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			if !filter.MatchJob(job, jobPatterns) {
+				continue
+			}
+			for _, step := range job.Steps {
+				if step.Run != "" && step.Name != "" && !seen[step.Name] {
+					seen[step.Name] = true
+					names = append(names, step.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+func hasRunStep(job provider.Job) bool {
+	for _, step := range job.Steps {
+		if step.Run != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// describeFilters lists the job and step filters in effect.
+func describeFilters(cfg config.Config) string {
+	var parts []string
+	for _, f := range []struct {
+		flag     string
+		patterns []string
+	}{{"--job", cfg.Jobs}, {"--only-step", cfg.OnlySteps}, {"--skip-step", cfg.SkipSteps}} {
+		for _, p := range f.patterns {
+			parts = append(parts, fmt.Sprintf("%s %q", f.flag, p))
+		}
+	}
+	if len(parts) == 0 {
+		return "no filters are set, and the workflows have no run steps"
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const matchWorkflow = `name: CI
+jobs:
+  lint:
+    steps:
+      - name: Run linter
+        run: echo lint
+  integration-test:
+    name: Integration
+    steps:
+      - uses: actions/checkout@v4
+      - name: Run rspec
+        run: echo rspec
+      - name: Upload coverage
+        run: echo upload
+`
+
+func TestRequireMatchTypoJob(t *testing.T) {
+	chdir(t, writeFiles(t, map[string]string{"ci.yml": matchWorkflow}))
+
+	out, _, err := executeRoot(t, "list", "--workflow", "ci.yml", "--job", "tset")
+	if err != nil {
+		t.Fatalf("list without --require-match: %v", err)
+	}
+	if !strings.Contains(out, "No matching jobs or steps") {
+		t.Fatalf("list output = %q", out)
+	}
+
+	for _, command := range []string{"list", "run"} {
+		_, _, err := executeRoot(t, command, "--workflow", "ci.yml", "--job", "tset", "--require-match")
+		want := "no matching jobs or steps:\n" +
+			`  --job "tset" matches no job; did you mean "integration-test"?` + "\n" +
+			"available jobs: integration-test, Integration, lint"
+		if err == nil || err.Error() != want {
+			t.Fatalf("%s error = %v, want:\n%s", command, err, want)
+		}
+		var usage *usageError
+		if !errors.As(err, &usage) {
+			t.Fatalf("%s error is %T, want a usage error", command, err)
+		}
+	}
+}
+
+func TestRequireMatchTypoStep(t *testing.T) {
+	chdir(t, writeFiles(t, map[string]string{"ci.yml": matchWorkflow}))
+
+	_, _, err := executeRoot(t, "run", "--workflow", "ci.yml", "--job", "integration", "--only-step", "rpsec", "--require-match")
+	want := `--only-step "rpsec" matches no run step in the selected jobs; did you mean "Run rspec"?`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("error = %v, want it to contain %s", err, want)
+	}
+
+	// A step that exists only in another job is not suggested.
+	_, _, err = executeRoot(t, "run", "--workflow", "ci.yml", "--job", "lint", "--only-step", "rspec", "--require-match")
+	want = `--only-step "rspec" matches no run step in the selected jobs` + "\n"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("error = %v, want it to contain %q", err, want)
+	}
+}
+
+func TestRequireMatchFiltersTogether(t *testing.T) {
+	chdir(t, writeFiles(t, map[string]string{"ci.yml": matchWorkflow}))
+
+	_, _, err := executeRoot(t, "list", "--workflow", "ci.yml", "--job", "lint", "--skip-step", "linter", "--require-match")
+	want := `the filters together select no run steps: --job "lint" --skip-step "linter"`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("error = %v, want it to contain %s", err, want)
+	}
+}
+
+func TestRequireMatchTypoWorkflow(t *testing.T) {
+	chdir(t, writeFiles(t, map[string]string{".github/workflows/ci.yml": matchWorkflow}))
+
+	_, _, err := executeRoot(t, "list", "--workflow", ".github/workflows/cl.yml")
+	want := `workflow ".github/workflows/cl.yml" not found; did you mean ".github/workflows/ci.yml"?`
+	if err == nil || err.Error() != want {
+		t.Fatalf("error = %v, want %s", err, want)
+	}
+}
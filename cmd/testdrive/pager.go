@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// outputIsTerminal reports whether cmd's results go to a terminal. It is a
+// variable so tests can page without one.
+var outputIsTerminal = func(cmd *cobra.Command) bool {
+	f, ok := cmd.OutOrStdout().(*os.File)
+	return ok && isTerminal(f)
+}
+
+// newPager returns the pager for long failure blocks, or nil when
+// output.pager is never, results do not go to a terminal, or neither $PAGER
+// nor less is installed.
+func newPager(cmd *cobra.Command, cfg config.Config) (*output.Pager, error) {
+	switch cfg.Output.Pager {
+	case config.PagerNever:
+		return nil, nil
+	case config.PagerAuto:
+	default:
+		return nil, fmt.Errorf("unsupported output.pager %q; use %s or %s", cfg.Output.Pager, config.PagerAuto, config.PagerNever)
+	}
+	if !outputIsTerminal(cmd) {
+		return nil, nil
+	}
+	command := strings.Fields(os.Getenv("PAGER"))
+	if len(command) == 0 {
+		command = []string{"less", "-R"}
+	}
+	if _, err := exec.LookPath(command[0]); err != nil {
+		return nil, nil
+	}
+	return &output.Pager{
+		Command:  command,
+		MinLines: output.DefaultPagerLines,
+		Out:      cmd.OutOrStdout(),
+		Err:      cmd.ErrOrStderr(),
+	}, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// pagerWorkflow has a failing step that prints 60 error lines and one
+// that prints a single line.
+const pagerWorkflow = "name: CI\njobs:\n  test:\n    steps:\n      - name: Unit\n        run: \"for i in $(seq 1 60); do echo \\\"error $i\\\" >&2; done; exit 1\"\n      - name: Lint\n        run: \"echo lint error >&2; exit 1\"\n"
+
+// useFakePager sets PAGER to a script that appends its stdin to the
+// returned file.
+func useFakePager(t *testing.T) string {
+	t.Helper()
+	paged := filepath.Join(t.TempDir(), "paged")
+	script := filepath.Join(t.TempDir(), "fake-pager")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat >> "+paged+"\n"), 0o755); err != nil {
+		t.Fatalf("write pager: %v", err)
+	}
+	t.Setenv("PAGER", script)
+	return paged
+}
+
+// useTerminal makes the results writer count as a terminal.
+func useTerminal(t *testing.T) {
+	t.Helper()
+	prev := outputIsTerminal
+	outputIsTerminal = func(*cobra.Command) bool { return true }
+	t.Cleanup(func() { outputIsTerminal = prev })
+}
+
+func TestRunCommandPagesLongFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	chdir(t, writeFiles(t, map[string]string{
+		".github/workflows/ci.yml": pagerWorkflow,
+		".testdrive.yml":           "tail_lines: 100\n",
+	}))
+	paged := useFakePager(t)
+	useTerminal(t)
+
+	out, _, err := executeRoot(t, "run", "--pager")
+	if err == nil || !strings.Contains(err.Error(), "one or more steps failed") {
+		t.Fatalf("expected the failing steps to fail the run, got %v", err)
+	}
+	data, readErr := os.ReadFile(paged)
+	if readErr != nil {
+		t.Fatalf("expected the pager to run: %v", readErr)
+	}
+	block := string(data)
+	if !strings.HasPrefix(block, "✗ Unit\n") || !strings.Contains(block, "error 1\n") || !strings.Contains(block, "error 60\n") {
+		t.Fatalf("unexpected paged block:\n%s", block)
+	}
+	if strings.Contains(block, "lint error") {
+		t.Fatalf("expected the short failure to stay inline, pager got:\n%s", block)
+	}
+	if strings.Contains(out, "error 60") {
+		t.Fatalf("expected the long failure to be left out of the results:\n%s", out)
+	}
+	for _, want := range []string{"lines of failure output shown in the pager)\n", "lint error\n", "0 passed, 2 failed"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected results to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunCommandPagerFallsBackInline(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Run("not a terminal", func(t *testing.T) {
+		chdir(t, writeFiles(t, map[string]string{
+			".github/workflows/ci.yml": pagerWorkflow,
+			".testdrive.yml":           "tail_lines: 100\noutput:\n  pager: auto\n",
+		}))
+		paged := useFakePager(t)
+
+		out, _, _ := executeRoot(t, "run", "--max-parallel", "2")
+		if _, err := os.Stat(paged); !os.IsNotExist(err) {
+			t.Fatalf("expected no pager without a terminal, got %v", err)
+		}
+		if !strings.Contains(out, "error 60\n") {
+			t.Fatalf("expected the failure inline, got:\n%s", out)
+		}
+	})
+	t.Run("no pager installed", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			".github/workflows/ci.yml": pagerWorkflow,
+			".testdrive.yml":           "tail_lines: 100\n",
+		})
+		chdir(t, dir)
+		useTerminal(t)
+		t.Setenv("PAGER", filepath.Join(dir, "missing-pager"))
+
+		out, _, _ := executeRoot(t, "run", "--pager")
+		if !strings.Contains(out, "error 60\n") {
+			t.Fatalf("expected the failure inline, got:\n%s", out)
+		}
+	})
+	t.Run("unknown setting", func(t *testing.T) {
+		chdir(t, writeFiles(t, map[string]string{
+			".github/workflows/ci.yml": pagerWorkflow,
+			".testdrive.yml":           "output:\n  pager: always\n",
+		}))
+
+		if _, _, err := executeRoot(t, "run"); err == nil || err.Error() != `unsupported output.pager "always"; use auto or never` {
+			t.Fatalf("expected an output.pager error, got %v", err)
+		}
+	})
+}
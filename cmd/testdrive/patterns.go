@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/patterns"
+	"github.com/spf13/cobra"
+)
+
+func newPatternsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "patterns [privileged|destructive|noise]",
+		Short: "Show the effective privileged, destructive, and noise patterns",
+		Long: `Patterns prints each pattern set as it applies to this repository: the
+built-in patterns, less any removed with patterns.<set>.remove, followed by
+those added with patterns.<set>.add. Each pattern is labelled builtin or
+config. privileged_command_patterns and destructive_command_patterns replace
+their built-in set outright.
+
+With --test, it instead reports which pattern of each set the given command
+or output line would match.`,
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: patterns.Sets(),
+		RunE:      runPatterns,
+	}
+	cmd.Flags().String("test", "", "report which pattern of each set matches this command or output line")
+	return cmd
+}
+
+func runPatterns(cmd *cobra.Command, args []string) error {
+	cfg, _, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	sets := patterns.Sets()
+	if len(args) == 1 {
+		if !slices.Contains(sets, args[0]) {
+			return fmt.Errorf("unknown pattern set %q; use %s", args[0], strings.Join(sets, ", "))
+		}
+		sets = []string{args[0]}
+	}
+	test, err := cmd.Flags().GetString("test")
+	if err != nil {
+		return fmt.Errorf("parse --test: %w", err)
+	}
+
+	reports := make([]patterns.Report, 0, len(sets))
+	for _, set := range sets {
+		rep := patterns.Report{Set: set, Patterns: effectivePatterns(cfg, set), Test: test}
+		if test != "" {
+			if match, ok := patterns.Match(rep.Patterns, test); ok {
+				rep.Match = &match
+			}
+		}
+		reports = append(reports, rep)
+	}
+
+	switch strings.ToLower(cfg.Format) {
+	case config.FormatPretty:
+		return newPretty(cmd, cfg).RenderPatterns(reports)
+	case config.FormatJSON:
+		renderer := output.NewJSON(cmd.OutOrStdout())
+		renderer.Compact = cfg.Compact
+		return renderer.Encode(reports)
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+}
+
+// effectivePatterns returns set with the config's replacements, additions,
+// and removals applied.
+func effectivePatterns(cfg config.Config, set string) []patterns.Pattern {
+	var changes patterns.Changes
+	switch set {
+	case patterns.Privileged:
+		changes = patterns.Changes{Replace: cfg.PrivilegedCommandPatterns, Add: cfg.Patterns.Privileged.Add, Remove: cfg.Patterns.Privileged.Remove}
+	case patterns.Destructive:
+		changes = patterns.Changes{Replace: cfg.DestructiveCommandPatterns, Add: cfg.Patterns.Destructive.Add, Remove: cfg.Patterns.Destructive.Remove}
+	case patterns.Noise:
+		changes = patterns.Changes{Add: cfg.Patterns.Noise.Add, Remove: cfg.Patterns.Noise.Remove}
+	}
+	return patterns.Effective(set, changes)
+}
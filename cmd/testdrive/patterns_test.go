@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/patterns"
+)
+
+const patternsConfig = `patterns:
+  privileged:
+    add: ['\bsnap\s+install\b']
+    remove: ['(?i)\bbrew\b']
+  noise:
+    add: ['^Spring preloader']
+`
+
+func TestPatternsCommand(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".testdrive.yml"), []byte(patternsConfig), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	chdir(t, root)
+	t.Cleanup(func() { output.SetNoisePatterns(patterns.Exprs(patterns.Builtin(patterns.Noise))) })
+
+	execute := func(args ...string) (string, error) {
+		cmd := newRootCmd()
+		cmd.SetArgs(args)
+		buf := &bytes.Buffer{}
+		cmd.SetOut(buf)
+		cmd.SetErr(&bytes.Buffer{})
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+
+	out, err := execute("patterns", "privileged")
+	if err != nil {
+		t.Fatalf("patterns privileged: %v", err)
+	}
+	if !strings.HasPrefix(out, "PRIVILEGED (13)\n  builtin  (?i)^sudo\\b") || strings.Contains(out, "brew") || !strings.HasSuffix(out, "  config   \\bsnap\\s+install\\b\n") {
+		t.Fatalf("unexpected privileged set:\n%s", out)
+	}
+	if strings.Contains(out, "NOISE") {
+		t.Fatalf("expected only the privileged set, got:\n%s", out)
+	}
+
+	out, err = execute("patterns", "--test", "snap install go")
+	if err != nil {
+		t.Fatalf("patterns --test: %v", err)
+	}
+	if out != "privileged: matches \\bsnap\\s+install\\b (config)\ndestructive: no match\nnoise: no match\n" {
+		t.Fatalf("unexpected test output:\n%s", out)
+	}
+
+	out, err = execute("patterns", "noise", "--test", "Spring preloader in process 42", "--format", "json")
+	if err != nil {
+		t.Fatalf("patterns --format json: %v", err)
+	}
+	var reports []patterns.Report
+	if err := json.Unmarshal([]byte(out), &reports); err != nil {
+		t.Fatalf("decode json: %v\n%s", err, out)
+	}
+	if len(reports) != 1 || reports[0].Match == nil || reports[0].Match.Expr != "^Spring preloader" || reports[0].Match.Origin != patterns.OriginConfig {
+		t.Fatalf("unexpected json report: %+v", reports)
+	}
+
+	if _, err := execute("patterns", "bogus"); err == nil || !strings.Contains(err.Error(), "use privileged, destructive, noise") {
+		t.Fatalf("expected an unknown set error, got %v", err)
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/provider/filter"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// errPickCancelled is returned when the user quits the picker.
+var errPickCancelled = errors.New("interactive selection cancelled")
+
+// prompter shows a question and returns the next line of input. The
+// interactive picker talks to it so tests can script the answers.
+type prompter interface {
+	Prompt(question string) (string, error)
+}
+
+// linePrompter prompts on out and reads answers line by line from in.
+type linePrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *linePrompter) Prompt(question string) (string, error) {
+	fmt.Fprint(p.out, question)
+	line, err := p.in.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// newPrompter returns the prompter for --interactive. It is a variable so
+// tests can substitute a scripted one.
+var newPrompter = func(cmd *cobra.Command) (prompter, error) {
+	f, ok := cmd.InOrStdin().(*os.File)
+	if !ok || !isTerminal(f) {
+		return nil, fmt.Errorf("--interactive needs a terminal on stdin; use --job and --only-step instead")
+	}
+	return &linePrompter{in: bufio.NewReader(f), out: cmd.ErrOrStderr()}, nil
+}
+
+// isTerminal reports whether f is a character device other than the null
+// device, which is as close as the standard library gets to a TTY check.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(info, null) {
+		return false
+	}
+	return true
+}
+
+// pickItem is one numbered line of the picker: a whole job when step is
+// negative, otherwise a single step of that job.
+type pickItem struct {
+	wf, job, step int
+}
+
+// stepPicker holds the selection state over a filtered pipeline. Every run
+// step starts selected.
+type stepPicker struct {
+	workflows []provider.Workflow
+	items     []pickItem
+	selected  map[[3]int]bool
+}
+
+func newStepPicker(workflows []provider.Workflow) *stepPicker {
+	p := &stepPicker{workflows: workflows, selected: make(map[[3]int]bool)}
+	for w, wf := range workflows {
+		for j, job := range wf.Jobs {
+			p.items = append(p.items, pickItem{wf: w, job: j, step: -1})
+			for s, step := range job.Steps {
+				if step.Run == "" {
+					continue
+				}
+				p.items = append(p.items, pickItem{wf: w, job: j, step: s})
+				p.selected[[3]int{w, j, s}] = true
+			}
+		}
+	}
+	return p
+}
+
+// jobState returns how many of the job's run steps exist and are selected.
+func (p *stepPicker) jobState(w, j int) (total, selected int) {
+	for s, step := range p.workflows[w].Jobs[j].Steps {
+		if step.Run == "" {
+			continue
+		}
+		total++
+		if p.selected[[3]int{w, j, s}] {
+			selected++
+		}
+	}
+	return total, selected
+}
+
+// toggle flips item n (1-based). Toggling a job selects all of its steps
+// unless all of them are already selected, in which case it clears them.
+func (p *stepPicker) toggle(n int) {
+	item := p.items[n-1]
+	if item.step >= 0 {
+		key := [3]int{item.wf, item.job, item.step}
+		p.selected[key] = !p.selected[key]
+		return
+	}
+	total, selected := p.jobState(item.wf, item.job)
+	on := selected < total
+	for s, step := range p.workflows[item.wf].Jobs[item.job].Steps {
+		if step.Run != "" {
+			p.selected[[3]int{item.wf, item.job, s}] = on
+		}
+	}
+}
+
+func (p *stepPicker) setAll(on bool) {
+	for key := range p.selected {
+		p.selected[key] = on
+	}
+}
+
+func (p *stepPicker) render(out io.Writer) {
+	lastWorkflow := -1
+	for n, item := range p.items {
+		if item.wf != lastWorkflow {
+			wf := p.workflows[item.wf]
+			name := wf.Path
+			if wf.Name != "" && wf.Name != wf.Path {
+				name = fmt.Sprintf("%s (%s)", wf.Name, wf.Path)
+			}
+			fmt.Fprintf(out, "Workflow %s\n", name)
+			lastWorkflow = item.wf
+		}
+		job := p.workflows[item.wf].Jobs[item.job]
+		if item.step < 0 {
+			total, selected := p.jobState(item.wf, item.job)
+			box := "[ ]"
+			switch {
+			case selected == total:
+				box = "[x]"
+			case selected > 0:
+				box = "[~]"
+			}
+			fmt.Fprintf(out, "  %s %2d  Job %s\n", box, n+1, job.Name)
+			continue
+		}
+		box := "[ ]"
+		if p.selected[[3]int{item.wf, item.job, item.step}] {
+			box = "[x]"
+		}
+		fmt.Fprintf(out, "    %s %2d  %s\n", box, n+1, job.Steps[item.step].Name)
+	}
+}
+
+// apply parses one answer. It returns done when the answer confirms the
+// selection.
+func (p *stepPicker) apply(answer string) (done bool, err error) {
+	fields := strings.FieldsFunc(strings.ToLower(answer), func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	if len(fields) == 0 {
+		return true, nil
+	}
+	for _, field := range fields {
+		switch field {
+		case "a", "all":
+			p.setAll(true)
+			continue
+		case "n", "none":
+			p.setAll(false)
+			continue
+		case "q", "quit":
+			return false, errPickCancelled
+		}
+		lo, hi, ok := strings.Cut(field, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return false, fmt.Errorf("unrecognized input %q", field)
+		}
+		last := first
+		if ok {
+			if last, err = strconv.Atoi(hi); err != nil {
+				return false, fmt.Errorf("unrecognized input %q", field)
+			}
+		}
+		if first < 1 || last > len(p.items) || first > last {
+			return false, fmt.Errorf("%q is out of range 1-%d", field, len(p.items))
+		}
+		for n := first; n <= last; n++ {
+			p.toggle(n)
+		}
+	}
+	return false, nil
+}
+
+// selection returns the workflows cut down to the selected steps and the
+// steps that were deselected.
+func (p *stepPicker) selection() ([]provider.Workflow, []report.SkippedStep) {
+	var out []provider.Workflow
+	var dropped []report.SkippedStep
+	for w, wf := range p.workflows {
+		var jobs []provider.Job
+		for j, job := range wf.Jobs {
+			var steps []provider.Step
+			for s, step := range job.Steps {
+				if step.Run == "" || p.selected[[3]int{w, j, s}] {
+					steps = append(steps, step)
+					continue
+				}
+				dropped = append(dropped, report.SkippedStep{
+					WorkflowPath: wf.Path,
+					WorkflowName: wf.Name,
+					JobName:      job.Name,
+					StepName:     step.Name,
+					Reason:       report.ReasonFilteredOnly,
+					Detail:       "not selected with --interactive",
+				})
+			}
+			if total, selected := p.jobState(w, j); total > 0 && selected == 0 {
+				continue
+			}
+			job.Steps = steps
+			jobs = append(jobs, job)
+		}
+		if len(jobs) == 0 {
+			continue
+		}
+		wf.Jobs = jobs
+		out = append(out, wf)
+	}
+	return out, dropped
+}
+
+// pickSteps shows the numbered jobs and steps of workflows on out and lets
+// the user toggle them through pr until they confirm with an empty answer.
+func pickSteps(pr prompter, out io.Writer, workflows []provider.Workflow) ([]provider.Workflow, []report.SkippedStep, error) {
+	picker := newStepPicker(workflows)
+	for {
+		picker.render(out)
+		answer, err := pr.Prompt("Toggle numbers (e.g. 2 4-6), a=all, n=none, enter=run, q=quit: ")
+		if err != nil {
+			return nil, nil, fmt.Errorf("read selection: %w", err)
+		}
+		done, err := picker.apply(answer)
+		if errors.Is(err, errPickCancelled) {
+			return nil, nil, err
+		}
+		if err != nil {
+			fmt.Fprintf(out, "%s\n", err)
+			continue
+		}
+		if !done {
+			continue
+		}
+		selected, dropped := picker.selection()
+		if len(selected) == 0 {
+			fmt.Fprintln(out, "nothing selected; pick at least one step or q to quit")
+			continue
+		}
+		return selected, dropped, nil
+	}
+}
+
+// selectionFlags returns --workflow, --job, and --only-step flags that pick
+// selected out of all, and whether they select exactly that. Names become
+// anchored regexps; when those are ambiguous the flags are only the closest
+// match.
+func selectionFlags(all, selected []provider.Workflow) ([]string, bool) {
+	want := runStepIDs(selected)
+	if slices.Equal(want, runStepIDs(all)) {
+		return nil, true
+	}
+
+	var paths, jobs, steps []string
+	partial := false
+	for _, wf := range selected {
+		paths = appendUnique(paths, wf.Path)
+		for _, job := range wf.Jobs {
+			jobs = appendUnique(jobs, "/^"+regexp.QuoteMeta(job.RawID)+"$/")
+			for _, step := range job.Steps {
+				if step.Run != "" {
+					steps = appendUnique(steps, "/^"+regexp.QuoteMeta(step.Name)+"$/")
+				}
+			}
+			if len(job.Steps) != len(findJob(all, wf.Path, job.RawID).Steps) {
+				partial = true
+			}
+		}
+	}
+	scoped := all
+	if len(paths) == len(all) {
+		paths = nil
+	} else {
+		scoped = nil
+		for _, wf := range all {
+			if slices.Contains(paths, wf.Path) {
+				scoped = append(scoped, wf)
+			}
+		}
+	}
+	if !partial {
+		steps = nil
+	}
+
+	var flags []string
+	for _, path := range paths {
+		flags = append(flags, "--workflow", shellQuote(path))
+	}
+	for _, job := range jobs {
+		flags = append(flags, "--job", shellQuote(job))
+	}
+	for _, step := range steps {
+		flags = append(flags, "--only-step", shellQuote(step))
+	}
+
+	// Re-apply the flags to check they pick the same steps.
+	jobPatterns, err := filter.Compile(jobs)
+	if err != nil {
+		return flags, false
+	}
+	onlyPatterns, err := filter.Compile(steps)
+	if err != nil {
+		return flags, false
+	}
+	got := runStepIDs(filter.FilterWorkflows(scoped, jobPatterns, onlyPatterns, nil))
+	return flags, slices.Equal(got, want)
+}
+
+// runStepIDs identifies every run step of workflows by workflow path, job
+// ID, and step name, in order.
+func runStepIDs(workflows []provider.Workflow) []string {
+	var ids []string
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			for _, step := range job.Steps {
+				if step.Run != "" {
+					ids = append(ids, wf.Path+"\x00"+job.RawID+"\x00"+step.Name)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+func findJob(workflows []provider.Workflow, path, rawID string) provider.Job {
+	for _, wf := range workflows {
+		if wf.Path != path {
+			continue
+		}
+		for _, job := range wf.Jobs {
+			if job.RawID == rawID {
+				return job
+			}
+		}
+	}
+	return provider.Job{}
+}
+
+func appendUnique(list []string, value string) []string {
+	if slices.Contains(list, value) {
+		return list
+	}
+	return append(list, value)
+}
+
+// shellQuote wraps s in single quotes when it contains anything a POSIX
+// shell would interpret.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:@", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// pickInteractively narrows filtered to the steps chosen in the picker and
+// prints the flags that repeat the choice without prompting.
+func pickInteractively(cmd *cobra.Command, filtered pipelineData) (pipelineData, error) {
+	if len(filtered.workflows) == 0 {
+		return filtered, nil
+	}
+	pr, err := newPrompter(cmd)
+	if err != nil {
+		return pipelineData{}, err
+	}
+	out := cmd.ErrOrStderr()
+	selected, dropped, err := pickSteps(pr, out, filtered.workflows)
+	if err != nil {
+		return pipelineData{}, err
+	}
+
+	flags, exact := selectionFlags(filtered.workflows, selected)
+	switch {
+	case len(flags) == 0:
+		fmt.Fprintln(out, "Selection as flags: none needed, every step is selected")
+	case exact:
+		fmt.Fprintf(out, "Selection as flags: %s\n", strings.Join(flags, " "))
+	default:
+		fmt.Fprintf(out, "Selection as flags (approximate, no exact equivalent): %s\n", strings.Join(flags, " "))
+	}
+
+	filtered.workflows = selected
+	filtered.dropped = append(filtered.dropped, dropped...)
+	return filtered, nil
+}
+
+// destructiveConfirmation returns the runner callback that asks before
+// skipping a destructive step, or nil outside --interactive and when
+// --allow-destructive already runs them all.
+func destructiveConfirmation(cmd *cobra.Command, cfg config.Config) (func(provider.Workflow, provider.Job, provider.Step, string) bool, error) {
+	interactive, err := cmd.Flags().GetBool("interactive")
+	if err != nil {
+		return nil, fmt.Errorf("parse --interactive: %w", err)
+	}
+	if !interactive || cfg.AllowDestructive || cfg.DryRun {
+		return nil, nil
+	}
+	pr, err := newPrompter(cmd)
+	if err != nil {
+		return nil, err
+	}
+	out := cmd.ErrOrStderr()
+	return func(wf provider.Workflow, job provider.Job, step provider.Step, detail string) bool {
+		fmt.Fprintf(out, "\n%s / %s / %s: %s\n", wf.Path, job.Name, step.Name, detail)
+		answer, err := pr.Prompt("Run it anyway? [y/N] ")
+		if err != nil {
+			return false
+		}
+		answer = strings.ToLower(answer)
+		return answer == "y" || answer == "yes"
+	}, nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// scriptedPrompter answers prompts from a fixed list.
+type scriptedPrompter struct {
+	answers []string
+	asked   int
+}
+
+func (s *scriptedPrompter) Prompt(string) (string, error) {
+	if s.asked >= len(s.answers) {
+		return "", errors.New("no more scripted answers")
+	}
+	s.asked++
+	return s.answers[s.asked-1], nil
+}
+
+func usePrompter(t *testing.T, answers ...string) *scriptedPrompter {
+	t.Helper()
+	scripted := &scriptedPrompter{answers: answers}
+	prev := newPrompter
+	newPrompter = func(*cobra.Command) (prompter, error) { return scripted, nil }
+	t.Cleanup(func() { newPrompter = prev })
+	return scripted
+}
+
+func pickWorkflows() []provider.Workflow {
+	return []provider.Workflow{
+		{Path: "ci.yml", Name: "CI", Jobs: []provider.Job{
+			{Name: "Test", RawID: "test", Steps: []provider.Step{
+				{Name: "Unit", Run: "make unit"},
+				{Name: "Lint", Run: "make lint"},
+			}},
+			{Name: "Build", RawID: "build", Steps: []provider.Step{
+				{Name: "Compile", Run: "make"},
+			}},
+		}},
+		{Path: "docs.yml", Name: "Docs", Jobs: []provider.Job{
+			{Name: "Docs", RawID: "docs", Steps: []provider.Step{
+				{Name: "Lint", Run: "make docs-lint"},
+			}},
+		}},
+	}
+}
+
+func stepNames(workflows []provider.Workflow) string {
+	var names []string
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			for _, step := range job.Steps {
+				names = append(names, job.RawID+"/"+step.Name)
+			}
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func TestPickStepsToggles(t *testing.T) {
+	cases := []struct {
+		name    string
+		answers []string
+		want    string
+		dropped int
+	}{
+		{"default all", []string{""}, "test/Unit,test/Lint,build/Compile,docs/Lint", 0},
+		{"toggle step", []string{"3", ""}, "test/Unit,build/Compile,docs/Lint", 1},
+		{"toggle job off", []string{"1", ""}, "build/Compile,docs/Lint", 2},
+		{"partial job back on", []string{"2", "1", ""}, "test/Unit,test/Lint,build/Compile,docs/Lint", 0},
+		{"none then range", []string{"n", "5-7", ""}, "build/Compile", 3},
+		{"bad input is reported", []string{"x", "99", "n", "7", ""}, "docs/Lint", 3},
+		{"empty selection asks again", []string{"n", "", "a", ""}, "test/Unit,test/Lint,build/Compile,docs/Lint", 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			selected, dropped, err := pickSteps(&scriptedPrompter{answers: tc.answers}, out, pickWorkflows())
+			if err != nil {
+				t.Fatalf("pickSteps: %v\n%s", err, out.String())
+			}
+			if got := stepNames(selected); got != tc.want {
+				t.Fatalf("selected %s, want %s", got, tc.want)
+			}
+			if len(dropped) != tc.dropped {
+				t.Fatalf("dropped %d steps, want %d", len(dropped), tc.dropped)
+			}
+			for _, d := range dropped {
+				if d.Reason != report.ReasonFilteredOnly {
+					t.Fatalf("unexpected drop reason %q", d.Reason)
+				}
+			}
+		})
+	}
+
+	_, _, err := pickSteps(&scriptedPrompter{answers: []string{"q"}}, &bytes.Buffer{}, pickWorkflows())
+	if !errors.Is(err, errPickCancelled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+func TestPickStepsRendersCheckboxes(t *testing.T) {
+	out := &bytes.Buffer{}
+	if _, _, err := pickSteps(&scriptedPrompter{answers: []string{"2", ""}}, out, pickWorkflows()); err != nil {
+		t.Fatalf("pickSteps: %v", err)
+	}
+	for _, want := range []string{
+		"Workflow CI (ci.yml)\n  [x]  1  Job Test\n    [x]  2  Unit\n",
+		"  [~]  1  Job Test\n    [ ]  2  Unit\n    [x]  3  Lint\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestSelectionFlags(t *testing.T) {
+	all := pickWorkflows()
+	cases := []struct {
+		name    string
+		answers []string
+		want    string
+		exact   bool
+	}{
+		{"everything", []string{""}, "", true},
+		{"whole jobs", []string{"6", ""}, "--workflow ci.yml --job '/^test$/' --job '/^build$/'", true},
+		{"single step", []string{"n", "3", ""}, "--workflow ci.yml --job '/^test$/' --only-step '/^Lint$/'", true},
+		{"across workflows", []string{"2 4", ""}, "--job '/^test$/' --job '/^docs$/' --only-step '/^Lint$/'", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			selected, _, err := pickSteps(&scriptedPrompter{answers: tc.answers}, &bytes.Buffer{}, all)
+			if err != nil {
+				t.Fatalf("pickSteps: %v", err)
+			}
+			flags, exact := selectionFlags(all, selected)
+			if got := strings.Join(flags, " "); got != tc.want || exact != tc.exact {
+				t.Fatalf("flags %q (exact %v), want %q (exact %v)", got, exact, tc.want, tc.exact)
+			}
+		})
+	}
+
+	// Dropping one of two same-named steps in a job cannot be expressed
+	// with name patterns.
+	dup := []provider.Workflow{{Path: "ci.yml", Jobs: []provider.Job{
+		{RawID: "test", Steps: []provider.Step{{Name: "Run", Run: "a"}, {Name: "Run", Run: "b"}, {Name: "Other", Run: "c"}}},
+	}}}
+	selected, _, err := pickSteps(&scriptedPrompter{answers: []string{"3", ""}}, &bytes.Buffer{}, dup)
+	if err != nil {
+		t.Fatalf("pickSteps: %v", err)
+	}
+	if _, exact := selectionFlags(dup, selected); exact {
+		t.Fatalf("expected an approximate match for duplicate step names")
+	}
+}
+
+func TestRunCommandInteractive(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+	usePrompter(t, "n", "5", "")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "-i", "--workflow", "testdata/workflows/ci_skips.yml", "--dry-run"})
+	out := &bytes.Buffer{}
+	errBuf := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(errBuf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if !strings.Contains(out.String(), "command: echo unit") || strings.Contains(out.String(), "echo lint") {
+		t.Fatalf("expected only the picked step to run:\n%s", out.String())
+	}
+	if !strings.Contains(errBuf.String(), "Selection as flags: --job '/^test$/' --only-step '/^Unit tests$/'") {
+		t.Fatalf("expected equivalent flags, got:\n%s", errBuf.String())
+	}
+}
+
+func TestRunCommandInteractiveNeedsTerminal(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--interactive", "--workflow", "testdata/workflows/ci_skips.yml", "--dry-run"})
+	cmd.SetIn(strings.NewReader("\n"))
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--interactive needs a terminal") {
+		t.Fatalf("expected terminal error, got %v", err)
+	}
+}
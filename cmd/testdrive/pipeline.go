@@ -1,38 +1,79 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"os"
+	"io"
+	"log/slog"
+	"net/http"
 	"path/filepath"
 	"strings"
+	"time"
 
     "github.com/bgricker/testdrive/internal/config"
     "github.com/bgricker/testdrive/internal/discovery"
     "github.com/bgricker/testdrive/internal/provider"
     "github.com/bgricker/testdrive/internal/provider/filter"
+    "github.com/bgricker/testdrive/internal/report"
+    "github.com/bgricker/testdrive/internal/resolve"
     githubprovider "github.com/bgricker/testdrive/internal/provider/github"
     "github.com/bgricker/testdrive/internal/version"
 )
 
+// parseCache is shared across pipeline loads in the same process so repeated
+// loads (watch cycles, list-then-run flows) skip unchanged workflow files.
+var parseCache = githubprovider.NewCache()
+
+// remoteFetcher downloads --workflow-url workflows.
+var remoteFetcher = &discovery.Fetcher{HTTP: &http.Client{Timeout: 30 * time.Second}}
+
+// versionDetector memoizes tool version probes for the life of the process.
+var versionDetector version.Detector = version.NewCachedDetector(version.NewExecDetector(nil))
+
 // pipelineData bundles parsed workflows with warnings and metadata.
 type pipelineData struct {
+	root      string
 	provider  string
 	workflows []provider.Workflow
 	warnings  []provider.Warning
+	// infos holds notices that are not warnings, such as unread workflow keys.
+	infos []provider.Warning
+	// excluded lists discovered workflow files dropped by exclude_workflows.
+	excluded []string
+	// dropped lists steps removed by filtering, with the reason for each.
+	dropped []report.SkippedStep
+	// versions records each pinned tool version compared against the local install.
+	versions []report.VersionCheck
+	// env holds the entries read from env_file, if one is configured.
+	env map[string]string
+	// localCoverage estimates how much of the unfiltered pipeline runs locally.
+	localCoverage report.LocalCoverage
+	// totals counts the unfiltered pipeline, so summaries can tell what
+	// exists apart from what was selected.
+	totals report.Totals
+	// meta names the checkout being run, when it is a git repository.
+	meta *report.Meta
 }
 
-func loadPipeline(root string, cfg config.Config) (pipelineData, error) {
+// loadPipeline discovers and parses the configured workflows, logging each
+// discovery decision to log.
+func loadPipeline(root string, cfg config.Config, log *slog.Logger) (pipelineData, error) {
 	providerName, err := resolveProvider(cfg.Provider)
 	if err != nil {
 		return pipelineData{}, err
 	}
 
-	var paths []string
-	if len(cfg.Workflows) > 0 {
-		paths, err = discovery.Workflows(root, cfg.Workflows)
-	} else {
-		paths, err = discovery.Workflows(root, nil)
+	local, refs, urls := discovery.SplitSpecs(root, cfg.Workflows)
+	refs = append(refs, cfg.WorkflowRefs...)
+	urls = append(urls, cfg.WorkflowURLs...)
+
+	paths, excluded, err := localWorkflows(root, cfg)
+	for _, p := range paths {
+		log.Debug("workflow discovered", "path", p, "explicit", len(local) > 0)
+	}
+	for _, p := range excluded {
+		log.Debug("workflow excluded", "path", p, "patterns", cfg.ExcludeWorkflows)
 	}
 	if err != nil {
 		if errors.Is(err, discovery.ErrNoWorkflows) {
@@ -40,23 +81,92 @@ func loadPipeline(root string, cfg config.Config) (pipelineData, error) {
 		}
 		return pipelineData{}, err
 	}
+	remotes, err := fetchRemoteWorkflows(root, refs, urls)
+	if err != nil {
+		return pipelineData{}, err
+	}
+	for _, remote := range remotes {
+		log.Debug("workflow fetched", "path", remote.Display)
+	}
 
 	switch providerName {
 	case config.ProviderGitHub:
 		parser := githubprovider.NewParser(root)
+		parser.Limits = githubprovider.Limits{
+			MaxBytes: int64(cfg.Limits.WorkflowBytes),
+			MaxJobs:  cfg.Limits.Jobs,
+			MaxSteps: cfg.Limits.Steps,
+		}
+		if !cfg.NoCache {
+			parser.Cache = parseCache
+		}
 		pipeline, err := parser.Parse(paths)
 		if err != nil {
 			return pipelineData{}, err
 		}
-		versionWarnings := detectVersionWarnings(root, cfg)
-		warnings := append(pipeline.Warnings, versionWarnings...)
-		return pipelineData{provider: providerName, workflows: pipeline.Workflows, warnings: warnings}, nil
+		for _, remote := range remotes {
+			wfs, warnings, err := parser.ParseContent(remote.Display, remote.Content)
+			if err != nil {
+				return pipelineData{}, err
+			}
+			pipeline.Workflows = append(pipeline.Workflows, wfs...)
+			pipeline.Warnings = append(pipeline.Warnings, warnings...)
+		}
+		return pipelineData{root: root, provider: providerName, workflows: pipeline.Workflows, warnings: pipeline.Warnings, excluded: excluded}, nil
 	default:
 		return pipelineData{}, fmt.Errorf("provider %q not implemented", providerName)
 	}
 }
 
-func applyFilters(data pipelineData, cfg config.Config) (pipelineData, error) {
+// localWorkflows returns the workflow files under root that loadPipeline
+// parses, and the discovered ones exclude_workflows dropped. Workflows from
+// other refs and URLs are not files here, so they are left out, and without
+// an explicit --workflow they also turn discovery off.
+func localWorkflows(root string, cfg config.Config) (paths, excluded []string, err error) {
+	local, refs, urls := discovery.SplitSpecs(root, cfg.Workflows)
+	if len(local) > 0 {
+		paths, err = discovery.Workflows(root, local)
+		return paths, nil, err
+	}
+	if len(refs)+len(urls)+len(cfg.WorkflowRefs)+len(cfg.WorkflowURLs) > 0 {
+		return nil, nil, nil
+	}
+	if paths, err = discovery.WorkflowsIn(root, cfg.WorkflowDirs); err != nil {
+		return nil, nil, err
+	}
+	// Exclusions run before parsing so skipped files are never opened.
+	paths, excluded, err = discovery.Exclude(paths, cfg.ExcludeWorkflows)
+	if err == nil && len(paths) == 0 {
+		err = discovery.ErrNoWorkflows
+	}
+	return paths, excluded, err
+}
+
+// fetchRemoteWorkflows reads workflows from other git refs and URLs. They are
+// fetched on every load and bypass the parse cache, so a moved branch or an
+// updated URL is always picked up.
+func fetchRemoteWorkflows(root string, refs, urls []string) ([]discovery.Remote, error) {
+	var remotes []discovery.Remote
+	for _, spec := range refs {
+		remote, err := discovery.FromRef(root, spec)
+		if err != nil {
+			return nil, err
+		}
+		remotes = append(remotes, remote)
+	}
+	for _, u := range urls {
+		remote, err := remoteFetcher.FromURL(context.Background(), u)
+		if err != nil {
+			return nil, err
+		}
+		remotes = append(remotes, remote)
+	}
+	return remotes, nil
+}
+
+// applyFilters narrows data to the selected jobs and steps, logging why each
+// step was kept or dropped to log.
+func applyFilters(data pipelineData, cfg config.Config, log *slog.Logger) (pipelineData, error) {
 	jobPatterns, err := filter.Compile(cfg.Jobs)
 	if err != nil {
 		return pipelineData{}, err
@@ -69,54 +179,262 @@ func applyFilters(data pipelineData, cfg config.Config) (pipelineData, error) {
 	if err != nil {
 		return pipelineData{}, err
 	}
+	teardownPatterns, err := filter.Compile(cfg.TeardownSteps)
+	if err != nil {
+		return pipelineData{}, fmt.Errorf("teardown_steps: %w", err)
+	}
+	xfailPatterns, err := filter.Compile(cfg.ExpectedFailures)
+	if err != nil {
+		return pipelineData{}, fmt.Errorf("expected_failures: %w", err)
+	}
 
-	filtered := filter.FilterWorkflows(data.workflows, jobPatterns, onlyPatterns, skipPatterns)
-	return pipelineData{provider: data.provider, workflows: filtered, warnings: data.warnings}, nil
+	overrides, err := compileOverrides(cfg.Overrides)
+	if err != nil {
+		return pipelineData{}, err
+	}
+	suppressed, err := provider.ParseWarningKinds(cfg.SuppressWarnings)
+	if err != nil {
+		return pipelineData{}, fmt.Errorf("suppress_warnings: %w", err)
+	}
+
+	filtered, dropped := filter.FilterWorkflowsWithSkips(data.workflows, jobPatterns, onlyPatterns, skipPatterns)
+	filtered = filter.ApplyOverrides(filtered, overrides)
+	filtered = filter.MarkTeardown(filtered, teardownPatterns)
+	filtered = filter.MarkExpectedFailures(filtered, xfailPatterns)
+	vars, err := loadVars(data.root, cfg)
+	if err != nil {
+		return pipelineData{}, err
+	}
+	filtered, missingVars := resolve.ApplyVars(filtered, vars)
+	shells, err := newShellFlags(cfg)
+	if err != nil {
+		return pipelineData{}, err
+	}
+	if filtered, err = shells.applyAll(filtered); err != nil {
+		return pipelineData{}, err
+	}
+	for _, d := range dropped {
+		log.Debug("filter dropped step", "workflow", d.WorkflowPath, "job", d.JobName, "step", d.StepName, "reason", d.Reason, "detail", d.Detail)
+	}
+	for _, wf := range filtered {
+		for _, job := range wf.Jobs {
+			for _, step := range job.Steps {
+				log.Debug("filter kept step", "workflow", wf.Path, "job", job.Name, "step", step.Name, "overridden", step.Overridden)
+			}
+		}
+	}
+
+	warnings := append([]provider.Warning{}, data.warnings...)
+	warnings = append(warnings, varsWarning(missingVars)...)
+	// Validate against the unfiltered pipeline so --job/--only-step selections
+	// don't make every other override look stale.
+	for _, o := range filter.UnmatchedOverrides(data.workflows, overrides) {
+		warnings = append(warnings, provider.Warning{
+			Kind:     provider.WarnOverrideUnmatched,
+			Workflow: config.FileName,
+			Message:  fmt.Sprintf("override for %s did not match any steps", o.Label),
+		})
+	}
+
+	if cfg.ShellAudit.Enabled {
+		// The audit reads scripts only, so it runs with filtering rather
+		// than with the checks, on the steps as overrides left them.
+		findings, err := auditShells(cfg, filtered)
+		if err != nil {
+			return pipelineData{}, err
+		}
+		warnings = append(warnings, shellAuditWarnings(findings)...)
+	}
+
+	env, err := loadEnvFile(data.root, cfg)
+	if err != nil {
+		return pipelineData{}, err
+	}
+	warnings = provider.SuppressWarnings(warnings, suppressed)
+	warnings, infos := provider.SplitInfos(warnings)
+
+	// Coverage describes the whole pipeline, so it ignores filters and counts
+	// unsupported features even when their warnings are suppressed.
+	localCoverage := report.BuildLocalCoverage(data.workflows, data.warnings)
+
+	return pipelineData{root: data.root, provider: data.provider, workflows: filtered, warnings: warnings, infos: infos, excluded: data.excluded, dropped: dropped, env: env, localCoverage: localCoverage, totals: report.CountTotals(data.workflows)}, nil
 }
 
-func detectVersionWarnings(root string, cfg config.Config) []provider.Warning {
-	if !cfg.Warn.VersionMismatch {
-		return nil
+// checksEnabled reports whether a command should probe the machine, given
+// checks.enabled and whether the command does so by default.
+func checksEnabled(cfg config.Config, byDefault bool) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Checks.Enabled)) {
+	case config.ChecksAuto, "":
+		return byDefault, nil
+	case config.ChecksOn:
+		return true, nil
+	case config.ChecksOff:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported checks.enabled %q; use %s, %s or %s", cfg.Checks.Enabled, config.ChecksAuto, config.ChecksOn, config.ChecksOff)
 	}
+}
 
-	var warnings []provider.Warning
+// applyChecks adds what the filtered pipeline's checks find to its warnings:
+// tool versions against their pins and, with check_env, the env scan. Unlike
+// filtering they run tools, so commands call this only when checksEnabled
+// says so.
+func applyChecks(cfg config.Config, data pipelineData) (pipelineData, error) {
+	// Version checks follow the filtered jobs so only the working
+	// directories that will actually run are consulted.
+	versions, warnings := checkVersions(data.root, cfg, data.workflows, versionDetector)
+	if cfg.CheckEnv {
+		warnings = append(warnings, envWarnings(data.workflows, data.env)...)
+	}
+	suppressed, err := provider.ParseWarningKinds(cfg.SuppressWarnings)
+	if err != nil {
+		return data, fmt.Errorf("suppress_warnings: %w", err)
+	}
+	warnings, infos := provider.SplitInfos(provider.SuppressWarnings(warnings, suppressed))
+	data.versions = versions
+	data.warnings = append(append([]provider.Warning{}, data.warnings...), warnings...)
+	data.infos = append(append([]provider.Warning{}, data.infos...), infos...)
+	return data, nil
+}
+
+// reportExcluded prints a single informational line listing excluded
+// workflow files when running verbosely.
+func reportExcluded(w io.Writer, cfg config.Config, data pipelineData) {
+	if !cfg.Verbose || len(data.excluded) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "info: excluded %d workflow(s): %s\n", len(data.excluded), strings.Join(data.excluded, ", "))
+}
+
+func compileOverrides(raw []config.Override) ([]filter.Override, error) {
+	overrides := make([]filter.Override, 0, len(raw))
+	for i, entry := range raw {
+		o, err := filter.CompileOverride(entry.Job, entry.Step)
+		if err != nil {
+			return nil, fmt.Errorf("overrides[%d]: %w", i, err)
+		}
+		o.Skip = entry.Skip
+		o.Env = entry.Env
+		o.Run = entry.Run
+		o.Shell = entry.Shell
+		overrides = append(overrides, o)
+	}
+	return overrides, nil
+}
+
+// versionScope is a directory whose pin files govern at least one job,
+// remembered with the first workflow and job that runs there.
+type versionScope struct {
+	dir      string
+	workflow string
+	job      string
+}
 
-	rubyPath := filepath.Join(root, ".ruby-version")
-	if contents, err := os.ReadFile(rubyPath); err == nil {
-		required := strings.TrimSpace(string(contents))
-		if required != "" {
-			info, detectErr := version.DetectRuby()
-			warn := buildVersionWarning("ruby", required, info.Version, detectErr)
-			if warn != "" {
-				warnings = append(warnings, provider.Warning{Workflow: ".ruby-version", Message: warn})
+// versionScopes collects the distinct working directories set by the run
+// defaults of the given jobs. Jobs without a default run from root.
+func versionScopes(root string, workflows []provider.Workflow) []versionScope {
+	var scopes []versionScope
+	seen := make(map[string]bool)
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			dir := root
+			for _, candidate := range []string{job.Defaults.WorkingDirectory, wf.Defaults.WorkingDirectory} {
+				candidate = strings.TrimSpace(candidate)
+				if candidate == "" {
+					continue
+				}
+				if !filepath.IsAbs(candidate) {
+					candidate = filepath.Join(root, candidate)
+				}
+				dir = filepath.Clean(candidate)
+				break
 			}
+			if seen[dir] {
+				continue
+			}
+			seen[dir] = true
+			scopes = append(scopes, versionScope{dir: dir, workflow: wf.Path, job: job.RawID})
 		}
 	}
+	if len(scopes) == 0 {
+		scopes = append(scopes, versionScope{dir: root})
+	}
+	return scopes
+}
 
-	nodePath := filepath.Join(root, ".node-version")
-	if contents, err := os.ReadFile(nodePath); err == nil {
-		required := strings.TrimSpace(string(contents))
-		if required != "" {
-			info, detectErr := version.DetectNode()
-			warn := buildVersionWarning("node", required, info.Version, detectErr)
-			if warn != "" {
-				warnings = append(warnings, provider.Warning{Workflow: ".node-version", Message: warn})
+// checkVersions compares the tool versions pinned for each job's working
+// directory with the local install. Mismatches and detection failures are
+// also returned as warnings; pins found below the root are attributed to the
+// job that runs there.
+func checkVersions(root string, cfg config.Config, workflows []provider.Workflow, detector version.Detector) ([]report.VersionCheck, []provider.Warning) {
+	if !cfg.Warn.VersionMismatch || cfg.NoVersionCheck {
+		return nil, nil
+	}
+
+	type scopedRequirement struct {
+		req   version.Requirement
+		scope versionScope
+	}
+	var reqs []scopedRequirement
+	var tools []string
+	seenSource := make(map[string]bool)
+	seenTool := make(map[string]bool)
+	for _, scope := range versionScopes(root, workflows) {
+		for _, req := range version.RequirementsIn(root, scope.dir) {
+			// Jobs sharing a directory, or inheriting the same parent pin,
+			// produce a single check.
+			if seenSource[req.Source] {
+				continue
+			}
+			seenSource[req.Source] = true
+			reqs = append(reqs, scopedRequirement{req: req, scope: scope})
+			if !seenTool[req.Tool] {
+				seenTool[req.Tool] = true
+				tools = append(tools, req.Tool)
 			}
 		}
 	}
+	// Probes are independent and can be slow behind version manager shims.
+	version.Prefetch(detector, tools...)
 
-	return warnings
+	checks := make([]report.VersionCheck, 0, len(reqs))
+	var warnings []provider.Warning
+	for _, sr := range reqs {
+		req := sr.req
+		info, detectErr := detector.Detect(req.Tool)
+		check := report.VersionCheck{
+			Tool:           req.Tool,
+			Required:       req.Version,
+			RequiredSource: req.Source,
+			Detected:       info.Version,
+		}
+		if detectErr != nil {
+			check.Error = detectErr.Error()
+		} else {
+			check.Match = version.Matches(req.Tool, req.Version, info.Version)
+		}
+		checks = append(checks, check)
+
+		if kind, warn := buildVersionWarning(req, info.Version, detectErr); warn != "" {
+			warning := provider.Warning{Kind: kind, Workflow: req.Source, Message: warn}
+			if sr.scope.dir != filepath.Clean(root) {
+				warning.Workflow, warning.Job = sr.scope.workflow, sr.scope.job
+			}
+			warnings = append(warnings, warning)
+		}
+	}
+	return checks, warnings
 }
 
-func buildVersionWarning(name, required, actual string, detectErr error) string {
+func buildVersionWarning(req version.Requirement, actual string, detectErr error) (provider.WarningKind, string) {
 	if detectErr != nil {
 		if version.Missing(detectErr) {
-			return fmt.Sprintf("%s executable not found; required %s", name, required)
+			return provider.WarnToolNotFound, fmt.Sprintf("%s executable not found; required %s", req.Tool, req.Version)
 		}
-		return fmt.Sprintf("unable to detect %s version: %v", name, detectErr)
+		return provider.WarnVersionUndetected, fmt.Sprintf("unable to detect %s version: %v", req.Tool, detectErr)
 	}
-	if !version.CompareMajorMinor(required, actual) {
-		return fmt.Sprintf("%s version mismatch: required %s (from .%s-version) but found %s", name, required, name, actual)
+	if !version.Matches(req.Tool, req.Version, actual) {
+		return provider.WarnVersionMismatch, fmt.Sprintf("%s version mismatch: required %s (from %s) but found %s", req.Tool, req.Version, req.Source, actual)
 	}
-	return ""
+	return "", ""
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/version"
+)
+
+func versionRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		".ruby-version": "3.3.0\n",
+		".node-version": "20.11.1\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func fakeVersionRunner(calls map[string]int, mu *sync.Mutex) version.CommandRunner {
+	return func(name string, args ...string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[name]++
+		if name == "ruby" {
+			return "ruby 3.2.2 (2023-03-30 revision e51014f9c0) [x86_64-linux]", nil
+		}
+		return "v20.11.1", nil
+	}
+}
+
+func TestCheckVersionsProbesOnce(t *testing.T) {
+	dir := versionRepo(t)
+	calls := map[string]int{}
+	var mu sync.Mutex
+	detector := version.NewCachedDetector(version.NewExecDetector(fakeVersionRunner(calls, &mu)))
+	cfg := config.Default()
+
+	for i := 0; i < 3; i++ {
+		_, warnings := checkVersions(dir, cfg, nil, detector)
+		if len(warnings) != 1 || warnings[0].Workflow != ".ruby-version" {
+			t.Fatalf("expected a single ruby mismatch warning, got %+v", warnings)
+		}
+		if !strings.Contains(warnings[0].Message, "required 3.3.0 (from .ruby-version) but found 3.2.2") {
+			t.Fatalf("unexpected warning: %q", warnings[0].Message)
+		}
+	}
+	if calls["ruby"] != 1 || calls["node"] != 1 {
+		t.Fatalf("expected one probe per tool, got %v", calls)
+	}
+}
+
+func TestCheckVersionsNoVersionCheck(t *testing.T) {
+	dir := versionRepo(t)
+	calls := map[string]int{}
+	var mu sync.Mutex
+	detector := version.NewCachedDetector(version.NewExecDetector(fakeVersionRunner(calls, &mu)))
+	cfg := config.Default()
+	cfg.NoVersionCheck = true
+
+	if _, warnings := checkVersions(dir, cfg, nil, detector); len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %+v", warnings)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("expected no probes with --no-version-check, got %v", calls)
+	}
+}
+
+func monorepoWorkflows() []provider.Workflow {
+	return []provider.Workflow{{
+		Path:     ".github/workflows/ci.yml",
+		Defaults: provider.Defaults{WorkingDirectory: "backend/"},
+		Jobs: []provider.Job{
+			{RawID: "api"},
+			{RawID: "worker"},
+			{RawID: "docs", Defaults: provider.Defaults{WorkingDirectory: "."}},
+		},
+	}}
+}
+
+func TestCheckVersionsUsesJobWorkingDirectories(t *testing.T) {
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		".ruby-version":         "3.2.2\n",
+		"backend/.ruby-version": "3.3.0\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	calls := map[string]int{}
+	var mu sync.Mutex
+	detector := version.NewCachedDetector(version.NewExecDetector(fakeVersionRunner(calls, &mu)))
+	cfg := config.Default()
+
+	checks, warnings := checkVersions(dir, cfg, monorepoWorkflows(), detector)
+	if len(checks) != 2 {
+		t.Fatalf("expected backend and root ruby checks, got %+v", checks)
+	}
+	if checks[0].RequiredSource != "backend/.ruby-version" || checks[0].Match {
+		t.Fatalf("expected backend pin to mismatch, got %+v", checks[0])
+	}
+	if checks[1].RequiredSource != ".ruby-version" || !checks[1].Match {
+		t.Fatalf("expected root pin to match, got %+v", checks[1])
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected one warning for the shared backend directory, got %+v", warnings)
+	}
+	if warnings[0].Workflow != ".github/workflows/ci.yml" || warnings[0].Job != "api" {
+		t.Fatalf("expected warning attributed to ci.yml api, got %+v", warnings[0])
+	}
+	if !strings.Contains(warnings[0].Message, "required 3.3.0 (from backend/.ruby-version) but found 3.2.2") {
+		t.Fatalf("unexpected warning: %q", warnings[0].Message)
+	}
+
+	// Filtering down to the root job leaves only the root pin in play.
+	docsOnly := monorepoWorkflows()
+	docsOnly[0].Jobs = docsOnly[0].Jobs[2:]
+	checks, warnings = checkVersions(dir, cfg, docsOnly, detector)
+	if len(checks) != 1 || checks[0].RequiredSource != ".ruby-version" || len(warnings) != 0 {
+		t.Fatalf("expected only the matching root pin, got checks=%+v warnings=%+v", checks, warnings)
+	}
+	if calls["ruby"] != 1 {
+		t.Fatalf("expected one ruby probe, got %v", calls)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+var planArgs = []string{"--workflow", "testdata/workflows/ci_plan.yml", "--dedupe", "--skip-step", "Upload"}
+
+func TestRunPlanGoldens(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+	useGit(t, goldenCheckout)
+
+	cases := []struct {
+		name   string
+		args   []string
+		golden string
+	}{
+		{"pretty", nil, "run_plan.txt"},
+		{"json", []string{"--format", "json"}, "run_plan.json"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newRootCmd()
+			cmd.SetArgs(append(append([]string{"run", "--plan"}, planArgs...), tc.args...))
+			buf := &bytes.Buffer{}
+			cmd.SetOut(buf)
+			cmd.SetErr(&bytes.Buffer{})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("command execute: %v", err)
+			}
+			want := readGolden(t, filepath.Join(root, "testdata", "golden", tc.golden))
+			if diff := diffStrings(want, buf.String()); diff != "" {
+				t.Fatalf("unexpected output:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunPlanMatchesRun(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	decode := func(args ...string) output.Report {
+		t.Helper()
+		cmd := newRootCmd()
+		cmd.SetArgs(append(append([]string{"run", "--format", "json"}, planArgs...), args...))
+		buf := &bytes.Buffer{}
+		cmd.SetOut(buf)
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("command execute: %v", err)
+		}
+		var decoded output.Report
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("decode output: %v", err)
+		}
+		return decoded
+	}
+
+	planned := decode("--plan")
+	ran := decode()
+	if planned.Plan == nil {
+		t.Fatalf("expected plan in report")
+	}
+	if len(planned.Steps) != 0 {
+		t.Fatalf("plan must not record step results, got %d", len(planned.Steps))
+	}
+	if len(planned.Plan.Steps) != len(ran.Steps) {
+		t.Fatalf("plan has %d steps, run has %d", len(planned.Plan.Steps), len(ran.Steps))
+	}
+	for i, p := range planned.Plan.Steps {
+		res := ran.Steps[i]
+		if p.StepName != res.StepName {
+			t.Fatalf("step %d: plan %q, run %q", i, p.StepName, res.StepName)
+		}
+		if (p.Action == report.PlanSkip) != (res.Status == "skipped") || p.SkipReason != res.SkipReason {
+			t.Errorf("%s: plan %s/%s, run %s/%s", p.StepName, p.Action, p.SkipReason, res.Status, res.SkipReason)
+		}
+	}
+	if len(planned.Plan.Dropped) != len(ran.Coverage.Skipped)-planned.Plan.Skipped {
+		t.Errorf("plan dropped %d steps, run coverage lists %d skipped", len(planned.Plan.Dropped), len(ran.Coverage.Skipped))
+	}
+}
+
+func TestRunPlanExecutesNothing(t *testing.T) {
+	root := t.TempDir()
+	workflow := "jobs:\n  build:\n    steps:\n      - run: touch ran.txt\n"
+	if err := os.WriteFile(filepath.Join(root, "ci.yml"), []byte(workflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	config := "required_env:\n  - TESTDRIVE_FIXTURE_PLAN_TOKEN\n"
+	if err := os.WriteFile(filepath.Join(root, ".testdrive.yml"), []byte(config), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "ci.yml", "--plan"})
+	out := &bytes.Buffer{}
+	errBuf := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(errBuf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("plan should succeed even with missing env: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "ran.txt")); !os.IsNotExist(err) {
+		t.Fatalf("plan must not execute steps")
+	}
+	if !bytes.Contains(errBuf.Bytes(), []byte("warning: 1 required environment variable(s) not set")) {
+		t.Fatalf("expected missing env warning, got %q", errBuf.String())
+	}
+}
+
+func TestUnresolvableWorkingDirectory(t *testing.T) {
+	root := t.TempDir()
+	workflow := "jobs:\n  build:\n    steps:\n      - name: Build\n        working-directory: missing\n        run: touch ran.txt\n"
+	if err := os.WriteFile(filepath.Join(root, "ci.yml"), []byte(workflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	chdir(t, root)
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantErr := fmt.Sprintf("working directory %q not found", filepath.Join(resolved, "missing"))
+
+	execute := func(args ...string) (string, error) {
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"run", "ci.yml", "--no-lock"}, args...))
+		out := &bytes.Buffer{}
+		cmd.SetOut(out)
+		cmd.SetErr(&bytes.Buffer{})
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	for _, mode := range [][]string{{"--dry-run"}, {"--plan"}} {
+		out, err := execute(mode...)
+		var usage *usageError
+		if !errors.As(err, &usage) {
+			t.Fatalf("%s: err = %v, want a usage error", mode[0], err)
+		}
+		if !strings.Contains(out, wantErr) {
+			t.Fatalf("%s output does not report %q:\n%s", mode[0], wantErr, out)
+		}
+	}
+
+	out, err := execute()
+	var usage *usageError
+	if err == nil || errors.As(err, &usage) {
+		t.Fatalf("real run: err = %v, want a step failure", err)
+	}
+	if !strings.Contains(out, wantErr) {
+		t.Fatalf("real run output does not report %q:\n%s", wantErr, out)
+	}
+	if _, err := os.Stat(filepath.Join(root, "ran.txt")); !os.IsNotExist(err) {
+		t.Fatal("the step ran")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const redactWorkflow = `name: CI
+jobs:
+  test:
+    steps:
+      - name: Env
+        env:
+          PIN_KEY: abc
+        run: env | grep -e DEPLOY_TOKEN -e PIN_KEY; exit 1
+`
+
+func TestRunCommandRedactsEnvValues(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ci.yml"), []byte(redactWorkflow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, root)
+	t.Setenv("DEPLOY_TOKEN", "s3cr3t-deploy-token")
+
+	for _, args := range [][]string{
+		{"--streaming"},
+		{"--no-streaming"},
+		{"--verbose"},
+		{"--format", "json"},
+		{"--format", "json", "--stream"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			cmd := newRootCmd()
+			cmd.SetArgs(append([]string{"run", "--workflow", "ci.yml", "--no-lock"}, args...))
+			// The runner writes stdout and stderr from separate
+			// goroutines, so they cannot share a buffer.
+			var stdout, stderr bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			if err := cmd.Execute(); err == nil {
+				t.Fatalf("expected the step to fail")
+			}
+			out := stdout.String() + stderr.String()
+			if strings.Contains(out, "s3cr3t") {
+				t.Fatalf("output shows the token:\n%s", out)
+			}
+			if !strings.Contains(out, "DEPLOY_TOKEN=***") || !strings.Contains(out, "PIN_KEY=abc") {
+				t.Fatalf("output does not show the masked environment:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestReportsRedactWorkflowEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	root := t.TempDir()
+	workflow := `name: CI
+env:
+  API_TOKEN: supersecretvalue
+jobs:
+  test:
+    env:
+      DB_PASSWORD: hunter2hunter2
+    steps:
+      - name: Env
+        env:
+          SIGNING_KEY: signingkeyvalue
+          PIN_KEY: abc
+        run: echo ok
+`
+	if err := os.WriteFile(filepath.Join(root, "ci.yml"), []byte(workflow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, root)
+
+	for _, args := range [][]string{
+		{"run", "--no-lock", "--format", "json"},
+		{"run", "--no-lock", "--format", "json", "--stream"},
+		{"run", "--plan", "--format", "json"},
+		{"list", "--format", "json"},
+		{"list", "--format", "markdown"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			cmd := newRootCmd()
+			cmd.SetArgs(append(args, "--workflow", "ci.yml", "--no-check"))
+			var stdout, stderr bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("command execute: %v\n%s", err, stderr.String())
+			}
+			out := stdout.String()
+			for _, secret := range []string{"supersecretvalue", "hunter2hunter2", "signingkeyvalue"} {
+				if strings.Contains(out, secret) {
+					t.Fatalf("output shows %q:\n%s", secret, out)
+				}
+			}
+			compact := strings.ReplaceAll(out, `": "`, `":"`)
+			if strings.Contains(strings.Join(args, " "), "json") && (!strings.Contains(compact, `"API_TOKEN":"***"`) || !strings.Contains(compact, `"PIN_KEY":"abc"`)) {
+				t.Fatalf("expected masked env values in the report:\n%s", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCommandWorkflowRef(t *testing.T) {
+	dir := worktreeRepo(t)
+	chdir(t, dir)
+
+	// The branch keeps the committed workflow; the working tree drifts away.
+	if out, err := exec.Command("git", "-C", dir, "branch", "feature").CombinedOutput(); err != nil {
+		t.Fatalf("git branch: %v\n%s", err, out)
+	}
+	local := "name: Local\njobs:\n  other:\n    steps:\n      - run: echo local > local.txt\n"
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(local), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--workflow-ref", "feature:.github/workflows/ci.yml", "--job", "codegen", "--only-step", "Write artifact"})
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v\n%s", err, out.String())
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "artifact.txt")); err != nil {
+		t.Fatalf("expected the step from the ref to run against the checkout: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "local.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected the local workflow to be ignored, got %v", err)
+	}
+
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"list", "--workflow-ref", "feature:.github/workflows/ci.yml"})
+	out.Reset()
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if !strings.Contains(out.String(), "feature:.github/workflows/ci.yml") || strings.Contains(out.String(), "Local") {
+		t.Fatalf("expected only the workflow from the ref, got:\n%s", out.String())
+	}
+
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"list", "--workflow-ref", "missing:.github/workflows/ci.yml"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), `workflow ref "missing:.github/workflows/ci.yml": git show`) {
+		t.Fatalf("expected a git error, got %v", err)
+	}
+}
+
+func TestListCommandWorkflowURL(t *testing.T) {
+	root := projectRoot(t)
+	body, err := os.ReadFile(filepath.Join(root, "testdata", "workflows", "ci_basic.yml"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ci.yml" {
+			http.Error(w, "gone", http.StatusGone)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+	chdir(t, t.TempDir())
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"list", srv.URL + "/ci.yml"})
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if !strings.Contains(out.String(), srv.URL+"/ci.yml") {
+		t.Fatalf("expected the URL as the workflow path, got:\n%s", out.String())
+	}
+
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"list", "--workflow-url", srv.URL + "/old.yml"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	err = cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "fetch workflow "+srv.URL+"/old.yml: 410 Gone") {
+		t.Fatalf("expected an HTTP status error, got %v", err)
+	}
+}
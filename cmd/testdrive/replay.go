@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bgricker/testdrive/internal/history"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// replayOrder returns the job start order recorded in the --replay report,
+// or nil when the flag is unset.
+func replayOrder(cmd *cobra.Command) ([]history.JobKey, error) {
+	path, err := cmd.Flags().GetString("replay")
+	if err != nil {
+		return nil, fmt.Errorf("parse --replay: %w", err)
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return readJobOrder(path)
+}
+
+// readJobOrder reads a saved `run --format json` report and lists its jobs
+// in the order they started. Jobs that never started are left out.
+func readJobOrder(path string) ([]history.JobKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", path, err)
+	}
+	var saved output.Report
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("decode %q: %w", path, err)
+	}
+	if len(saved.Repos) > 0 {
+		return nil, fmt.Errorf("%s is a --manifest report; replay one repository's report instead", path)
+	}
+	var started []report.JobSummary
+	for _, job := range saved.Summary.Jobs {
+		if job.Sequence > 0 {
+			started = append(started, job)
+		}
+	}
+	if len(started) == 0 {
+		return nil, fmt.Errorf("%s records no job order; replay the report of a run that executed steps", path)
+	}
+	sort.SliceStable(started, func(a, b int) bool { return started[a].Sequence < started[b].Sequence })
+	order := make([]history.JobKey, len(started))
+	for i, job := range started {
+		order[i] = history.JobKey{Workflow: job.WorkflowPath, Job: job.JobName}
+	}
+	return order, nil
+}
+
+// warnUnreplayed reports replayed jobs that are not part of this run, since
+// their absence can change the order the rest observe.
+func warnUnreplayed(w io.Writer, order []history.JobKey, workflows []provider.Workflow) {
+	selected := make(map[history.JobKey]bool)
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			selected[history.JobKey{Workflow: filepath.ToSlash(wf.Path), Job: job.Name}] = true
+		}
+	}
+	for _, key := range order {
+		if !selected[key] {
+			fmt.Fprintf(w, "warning: replay: job %s/%s from the report is not part of this run\n", key.Workflow, key.Job)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/history"
+	"github.com/bgricker/testdrive/internal/output"
+)
+
+func runReport(t *testing.T, args ...string) (output.Report, string) {
+	t.Helper()
+	cmd := newRootCmd()
+	cmd.SetArgs(append([]string{"run", "--format", "json"}, args...))
+	out, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(errBuf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v\n%s", err, errBuf.String())
+	}
+	var rep output.Report
+	if err := json.Unmarshal(out.Bytes(), &rep); err != nil {
+		t.Fatalf("decode report: %v\n%s", err, out.String())
+	}
+	return rep, errBuf.String()
+}
+
+func TestRunCommandReplaysRecordedOrder(t *testing.T) {
+	dir := scheduleFixture(t)
+	chdir(t, dir)
+	source := filepath.Join(dir, "source.json")
+	seed := `{"summary": {"jobs": [
+  {"workflow_path": ".github/workflows/ci.yml", "job_name": "fast", "sequence": 2},
+  {"workflow_path": ".github/workflows/ci.yml", "job_name": "slow", "sequence": 1},
+  {"workflow_path": ".github/workflows/gone.yml", "job_name": "old", "sequence": 3}
+]}}`
+	if err := os.WriteFile(source, []byte(seed), 0o644); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+
+	first, stderr := runReport(t, "--max-parallel", "2", "--replay", source)
+	if !strings.Contains(stderr, "warning: replay: job .github/workflows/gone.yml/old from the report is not part of this run") {
+		t.Fatalf("expected a warning for the missing job, got:\n%s", stderr)
+	}
+	want := []history.JobKey{{Workflow: ".github/workflows/ci.yml", Job: "slow"}, {Workflow: ".github/workflows/ci.yml", Job: "fast"}}
+	replayed := filepath.Join(dir, "replayed.json")
+	data, err := json.Marshal(first)
+	if err != nil {
+		t.Fatalf("encode report: %v", err)
+	}
+	if err := os.WriteFile(replayed, data, 0o644); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+	got, err := readJobOrder(replayed)
+	if err != nil {
+		t.Fatalf("read replayed order: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("replayed run started %v, want %v", got, want)
+	}
+	for _, step := range first.Steps {
+		if step.Sequence == 0 || step.StartedAt.IsZero() {
+			t.Fatalf("step %s/%s has no recorded start: %+v", step.JobName, step.StepName, step)
+		}
+	}
+
+	// Replaying the replayed run's own report reproduces its order.
+	second, _ := runReport(t, "--replay", replayed)
+	data, err = json.Marshal(second)
+	if err != nil {
+		t.Fatalf("encode report: %v", err)
+	}
+	if err := os.WriteFile(replayed, data, 0o644); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+	if got, err = readJobOrder(replayed); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("second replay started %v (%v), want %v", got, err, want)
+	}
+}
+
+func TestReadJobOrderRejectsReportsWithoutOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dry.json")
+	if err := os.WriteFile(path, []byte(`{"summary": {"jobs": [{"job_name": "test"}]}}`), 0o644); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+	if _, err := readJobOrder(path); err == nil || !strings.Contains(err.Error(), "records no job order") {
+		t.Fatalf("expected a missing-order error, got %v", err)
+	}
+}
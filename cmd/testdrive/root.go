@@ -10,20 +10,61 @@ func newRootCmd() *cobra.Command {
         Short:         "Testdrive executes GitHub Actions steps locally",
 		SilenceErrors: true,
 		SilenceUsage:  true,
+		// Subcommands must not define their own persistent pre-run, or
+		// this one is skipped.
+		PersistentPreRunE: setupDebugLogger,
 	}
 
 	persistent := cmd.PersistentFlags()
 	persistent.String("provider", "", "workflow provider to use (auto|github)")
 	persistent.StringArray("workflow", nil, "workflow file to include")
+	persistent.StringArray("workflow-ref", nil, "workflow at another git ref as REF:PATH, e.g. origin/main:.github/workflows/ci.yml (repeatable)")
+	persistent.StringArray("workflow-url", nil, "workflow downloaded from an http(s) URL (repeatable)")
+	persistent.StringArray("skip-workflow", nil, "exclude discovered workflows matching a glob or /regex/ (repeatable)")
 	persistent.StringArray("job", nil, "job filter (repeatable)")
+	persistent.StringArray("only-job", nil, "include only matching jobs; same as --job")
 	persistent.StringArray("only-step", nil, "include only matching steps")
 	persistent.StringArray("skip-step", nil, "exclude matching steps")
+	persistent.Bool("trace-shell", false, "trace each command the --only-step steps run (set -x, or Set-PSDebug -Trace 1 in pwsh)")
+	persistent.String("override-shell", "", "run the --only-step steps under this shell spec instead, e.g. \"sh -e\"")
+	persistent.StringArray("allow-environment", nil, "run jobs that target this deployment environment (repeatable)")
 	persistent.Bool("dry-run", false, "print commands without executing them")
 	persistent.BoolP("verbose", "v", false, "stream command output in real time")
-	persistent.String("format", "pretty", "output format (pretty|json)")
+	persistent.Bool("combine-output", false, "capture each step's stdout and stderr as one stream, keeping their order")
+	persistent.String("format", "pretty", "output format (pretty|json; list also accepts markdown and completion)")
+	persistent.Bool("compact", false, "write JSON output on a single line")
+	persistent.Bool("no-cache", false, "re-parse every workflow instead of reusing cached results")
+	persistent.Bool("dedupe", false, "skip steps identical to one that already passed in this run")
+	persistent.Bool("require-match", false, "fail with exit code 2, suggesting close names, when the filters select no run steps")
+	persistent.Int("max-parallel", 1, "run up to N jobs at once; jobs sharing a concurrency group never overlap")
+	persistent.String("schedule", "longest-first", "order parallel jobs start in (declared|longest-first by recorded duration)")
+	persistent.String("follow", "", "with --verbose and --max-parallel, stream this job's output live and show the others as one block each")
+	persistent.Bool("strict-git", false, "fail before running when the checkout differs from what CI would build")
+	persistent.Bool("allow-destructive", false, "run steps that look destructive, such as terraform apply or rm -rf of an unset variable")
+	persistent.Bool("allow-unresolved-expressions", false, "run steps whose script, env, or working directory still holds a ${{ }} expression")
+	persistent.Bool("no-version-check", false, "skip probing installed tool versions")
+	persistent.Bool("no-auto-path", false, "do not put the nearest node_modules/.bin and bin directories on each step's PATH")
+	persistent.String("env-file", "", "add KEY=VALUE lines from this file to every step's environment")
+	persistent.String("vars-file", "", "resolve ${{ vars.NAME }} from this file of KEY=VALUE lines or YAML map")
+	persistent.Bool("check-env", false, "warn about variables and secrets that run scripts use but are not set locally")
+	persistent.Bool("show-info", false, "also print info notices, such as workflow keys that have no effect locally")
+	persistent.StringArray("suppress", nil, "hide warnings of the given kind, e.g. matrix_unsupported (repeatable)")
+	persistent.Bool("debug", false, "log testdrive's own decisions to stderr")
+	persistent.String("debug-format", "text", "format of --debug lines (text|json)")
 
 	cmd.AddCommand(newListCmd())
 	cmd.AddCommand(newRunCmd())
+	cmd.AddCommand(newConfigCmd())
+	cmd.AddCommand(newInitCmd())
+	cmd.AddCommand(newCompareCmd())
+	cmd.AddCommand(newExplainCmd())
+	cmd.AddCommand(newValidateCmd())
+	cmd.AddCommand(newHistoryCmd())
+	cmd.AddCommand(newStatsCmd())
+	cmd.AddCommand(newSnapshotCmd())
+	cmd.AddCommand(newDiffCmd())
+	cmd.AddCommand(newPatternsCmd())
+	cmd.AddCommand(newCleanCmd())
 
 	return cmd
 }
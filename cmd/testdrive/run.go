@@ -1,99 +1,864 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
     "github.com/bgricker/testdrive/internal/config"
+    "github.com/bgricker/testdrive/internal/history"
+    "github.com/bgricker/testdrive/internal/listen"
     "github.com/bgricker/testdrive/internal/output"
+    "github.com/bgricker/testdrive/internal/patterns"
+    "github.com/bgricker/testdrive/internal/provider"
+    "github.com/bgricker/testdrive/internal/provider/filter"
+    "github.com/bgricker/testdrive/internal/report"
+    "github.com/bgricker/testdrive/internal/resolve"
     "github.com/bgricker/testdrive/internal/runner"
+    "github.com/bgricker/testdrive/internal/worktree"
 	"github.com/spf13/cobra"
 )
 
 func newRunCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "run",
-		Short: "Execute workflow steps locally",
-		RunE:  runExecute,
+	cmd := &cobra.Command{
+		Use:               "run [workflow...]",
+		Short:             "Execute workflow steps locally",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeWorkflowFiles,
+		RunE:              runExecute,
 	}
+	cmd.Flags().Bool("explain-skips", false, "list every step that did not run and why")
+	cmd.Flags().Bool("worktree", false, "run steps in a temporary git worktree (or copy) of the project")
+	cmd.Flags().Bool("keep-worktree", false, "keep the --worktree directory after the run for inspection")
+	cmd.Flags().Bool("group-by-prefix", false, "fold consecutive steps sharing a \"Word:\" name prefix in the results")
+	cmd.Flags().Bool("show-stdout-on-failure", true, "print the tail of a failed step's stdout before its stderr in the results")
+	cmd.Flags().BoolP("interactive", "i", false, "pick the jobs and steps to run from a numbered list (needs a terminal)")
+	cmd.Flags().Bool("pager", false, "show long failure output through $PAGER (or less -R) when writing to a terminal")
+	cmd.Flags().Bool("streaming", false, "show pretty results live, even with --verbose or --max-parallel (output.stream: true)")
+	cmd.Flags().Bool("no-streaming", false, "show pretty results once the run ends instead of live (output.stream: false)")
+	cmd.Flags().Bool("check", false, "check tool versions, git state and the environment before running; the default (checks.enabled: true)")
+	cmd.Flags().Bool("no-check", false, "skip the tool version, git state and environment checks (checks.enabled: false)")
+	cmd.Flags().Bool("stream", false, "with --format json, write one JSON event per line as the run goes, ending with the full report")
+	cmd.Flags().String("listen", "", "serve live progress for editor integrations on unix:/path/to.sock or 127.0.0.1:port (:0 picks a port)")
+	cmd.Flags().Bool("plan", false, "print which steps would run or be skipped, and why, without running anything")
+	cmd.Flags().String("manifest", "", "run every repository listed in this YAML manifest and report them together")
+	cmd.Flags().Bool("no-lock", false, "run even while another testdrive run holds .testdrive/lock in this repository")
+	cmd.Flags().Bool("fail-fast", false, "with --manifest, leave the remaining repositories unrun once one fails")
+	cmd.Flags().String("from-report", "", "repeat the run a saved `run --format json` report records, taking flags given here over its own")
+	cmd.Flags().String("replay", "", "start jobs in the order recorded in a saved `run --format json` report")
+	cmd.Flags().String("record", "", "record the run's verbose output, with timing, to this file as an asciinema cast")
+	cmd.Flags().String("trace", "", "write the run's job and step timings to this file in Chrome trace format (for Perfetto or chrome://tracing)")
+	cmd.Flags().Bool("watch", false, "run again whenever a workflow file changes, holding reruns while one does not parse")
+	cmd.Flags().Duration("watch-interval", 500*time.Millisecond, "with --watch, how often to check the workflow files for changes")
+	return cmd
 }
 
 func runExecute(cmd *cobra.Command, args []string) error {
-	cfg, root, err := loadConfig(cmd)
+	if err := applyFromReport(cmd, args); err != nil {
+		return err
+	}
+	manifest, err := cmd.Flags().GetString("manifest")
+	if err != nil {
+		return fmt.Errorf("parse --manifest: %w", err)
+	}
+	stream, err := cmd.Flags().GetBool("stream")
+	if err != nil {
+		return fmt.Errorf("parse --stream: %w", err)
+	}
+	if manifest != "" {
+		if stream {
+			return fmt.Errorf("--stream cannot be combined with --manifest")
+		}
+		if cmd.Flags().Changed("listen") {
+			return fmt.Errorf("--listen cannot be combined with --manifest")
+		}
+		if cmd.Flags().Changed("watch") {
+			return fmt.Errorf("--watch cannot be combined with --manifest")
+		}
+		return runManifest(cmd, manifest, args)
+	}
+	if cmd.Flags().Changed("fail-fast") {
+		return fmt.Errorf("--fail-fast requires --manifest")
+	}
+
+	cfg, root, err := loadConfig(cmd, args...)
 	if err != nil {
 		return err
 	}
+	if stream && strings.ToLower(cfg.Format) != config.FormatJSON {
+		return fmt.Errorf("--stream requires --format json")
+	}
 
-	data, err := loadPipeline(root, cfg)
+	watch, err := cmd.Flags().GetBool("watch")
+	if err != nil {
+		return fmt.Errorf("parse --watch: %w", err)
+	}
+	if watch {
+		return watchPipeline(cmd, cfg, root)
+	}
+
+	filtered, checks, err := preparePipeline(cmd, cfg, root)
 	if err != nil {
 		return err
 	}
 
-	filtered, err := applyFilters(data, cfg)
+	interactive, err := cmd.Flags().GetBool("interactive")
+	if err != nil {
+		return fmt.Errorf("parse --interactive: %w", err)
+	}
+	if interactive {
+		if filtered, err = pickInteractively(cmd, filtered); err != nil {
+			return err
+		}
+	}
+
+	filtered.meta = describeCheckout(root)
+
+	showPlan, err := cmd.Flags().GetBool("plan")
+	if err != nil {
+		return fmt.Errorf("parse --plan: %w", err)
+	}
+	if showPlan {
+		return renderPlan(cmd, cfg, root, filtered)
+	}
+
+	useWorktree, err := cmd.Flags().GetBool("worktree")
+	if err != nil {
+		return fmt.Errorf("parse --worktree: %w", err)
+	}
+	if useWorktree && filtered.meta != nil {
+		// The worktree is a clean checkout of HEAD.
+		filtered.meta.Dirty = false
+	}
+	// A dry run executes nothing, so missing secrets or a checkout that
+	// differs from CI cannot hurt it. The checkout is inspected before the
+	// lock is written into it.
+	if !cfg.DryRun && checks {
+		if err := checkRequiredEnv(cfg, filtered); err != nil {
+			return err
+		}
+		if filtered, err = checkGitState(cfg, root, filtered, useWorktree); err != nil {
+			return err
+		}
+	}
+
+	// A worktree run keeps its history and temp files in its own tree, so
+	// it cannot trample another run's.
+	if !useWorktree {
+		release, err := acquireRunLock(cmd, cfg, root)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	keepWorktree, err := cmd.Flags().GetBool("keep-worktree")
+	if err != nil {
+		return fmt.Errorf("parse --keep-worktree: %w", err)
+	}
+	if !useWorktree {
+		if keepWorktree {
+			return fmt.Errorf("--keep-worktree requires --worktree")
+		}
+		return executePipeline(cmd, cfg, root, filtered)
+	}
+
+	wt, err := worktree.Create(root)
 	if err != nil {
 		return err
 	}
+	stderr := cmd.ErrOrStderr()
+	fmt.Fprintf(stderr, "info: running in worktree %s\n", wt.Root)
 
-    allowPrivileged := os.Getenv("TESTDRIVE_ALLOW_PRIVILEGED") == "1"
+	runErr := executePipeline(cmd, cfg, wt.Root, filtered)
+	if keepWorktree {
+		fmt.Fprintf(stderr, "info: kept worktree %s\n", wt.Root)
+		if wt.Git {
+			fmt.Fprintf(stderr, "info: remove it with `git worktree remove --force %s`\n", wt.Path)
+		}
+		return runErr
+	}
+	if err := wt.Remove(); err != nil {
+		if runErr != nil {
+			fmt.Fprintf(stderr, "warning: %v\n", err)
+			return runErr
+		}
+		return err
+	}
+	return runErr
+}
 
-	runOpts := runner.Options{
-		Root:               root,
-		Stdout:             cmd.OutOrStdout(),
-		Stderr:             cmd.ErrOrStderr(),
-		Verbose:            cfg.Verbose,
-		DryRun:             cfg.DryRun,
-		TailLines:          20,
-		AllowPrivileged:    allowPrivileged,
-		PrivilegedPatterns: append([]string{}, cfg.PrivilegedCommandPatterns...),
+// preparePipeline loads, filters and checks the workflows a run executes,
+// also reporting whether the checks are on.
+func preparePipeline(cmd *cobra.Command, cfg config.Config, root string) (pipelineData, bool, error) {
+	data, err := loadPipeline(root, cfg, debugLog(cmd))
+	if err != nil {
+		return pipelineData{}, false, err
 	}
+	reportExcluded(cmd.ErrOrStderr(), cfg, data)
 
-    	// Enable streaming for pretty format when not verbose and not dry-run
-        if strings.ToLower(cfg.Format) == config.FormatPretty && !cfg.Verbose && !cfg.DryRun {
-			runOpts.Streaming = true
-			runOpts.StreamingRenderer = output.NewStreamingPretty(cmd.OutOrStdout())
+	filtered, err := applyFilters(data, cfg, debugLog(cmd))
+	if err != nil {
+		return pipelineData{}, false, err
+	}
+	if err := requireMatch(cfg, data, filtered); err != nil {
+		return pipelineData{}, false, err
+	}
+	checks, err := checksEnabled(cfg, true)
+	if err != nil {
+		return pipelineData{}, false, err
+	}
+	if checks {
+		if filtered, err = applyChecks(cfg, filtered); err != nil {
+			return pipelineData{}, false, err
 		}
+	}
+	return filtered, checks, nil
+}
+
+// runnerOptions builds batch runner options for root from the effective
+// config and env file; callers opt into streaming themselves.
+func runnerOptions(cmd *cobra.Command, cfg config.Config, root string, fileEnv map[string]string) runner.Options {
+	// Load has already rejected durations that do not parse.
+	stallWarningAfter, stallTimeout, _ := config.StallDurations(cfg)
+	return runner.Options{
+		Root:                root,
+		Env:                 stepEnvironment(fileEnv),
+		Stdout:              cmd.OutOrStdout(),
+		Stderr:              cmd.ErrOrStderr(),
+		Verbose:             cfg.Verbose,
+		CombineOutput:       cfg.CombineOutput,
+		DryRun:              cfg.DryRun,
+		TailLines:           cfg.TailLines,
+		AllowPrivileged:     os.Getenv("TESTDRIVE_ALLOW_PRIVILEGED") == "1",
+		PrivilegedPatterns:  patterns.Exprs(effectivePatterns(cfg, patterns.Privileged)),
+		AllowDestructive:    cfg.AllowDestructive,
+		DestructivePatterns: patterns.Exprs(effectivePatterns(cfg, patterns.Destructive)),
+		PathMap:             resolve.NewPathMap(cfg.PathMappings, root),
+		AllowedEnvironments: append([]string{}, cfg.AllowedEnvironments...),
+		Dedupe:              cfg.Dedupe,
+		MaxParallel:         cfg.MaxParallel,
+		CheckPorts:          append([]int{}, cfg.CheckPorts...),
+		DetectPorts:         cfg.DetectPorts,
+		AutoPath:            cfg.AutoPath,
+		GitRef:              gitRef(root),
+		Logger:              debugLog(cmd),
+		FoldStart:           cfg.Output.FoldMarkers.Start,
+		FoldEnd:             cfg.Output.FoldMarkers.End,
+		StallWarningAfter:   stallWarningAfter,
+		StallTimeout:        stallTimeout,
+		RedactEnvPatterns:   append([]string{}, cfg.RedactEnvPatterns...),
 
+		AllowUnresolvedExpressions: cfg.AllowUnresolvedExpressions,
+	}
+}
+
+// renderPlan prints the runner's decision for every selected step without
+// executing any of them. Missing required env is reported as a warning since
+// the real run would stop on it. Steps that would fail to start make it return
+// a usageError after the plan is printed.
+func renderPlan(cmd *cobra.Command, cfg config.Config, root string, filtered pipelineData) error {
+	runOpts := runnerOptions(cmd, cfg, root, filtered.env)
+	plan := report.NewPlan(runner.New(runOpts).Plan(filtered.workflows), filtered.dropped)
+	warnings := collapseWarnings(filtered.warnings)
+	if err := checkRequiredEnv(cfg, filtered); err != nil {
+		warnings = append(warnings, err.Error())
+	}
+
+	switch strings.ToLower(cfg.Format) {
+	case config.FormatPretty:
+		if len(plan.Steps) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No matching jobs or steps")
+		} else if err := newPretty(cmd, cfg).RenderPlan(plan); err != nil {
+			return err
+		}
+		for _, msg := range warnings {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", msg)
+		}
+		printInfos(cmd.ErrOrStderr(), cfg, filtered.infos)
+	case config.FormatJSON:
+		jsonReport := output.Report{
+			Provider:  filtered.provider,
+			Workflows: runner.RedactWorkflows(cfg.RedactEnvPatterns, filtered.workflows),
+			Summary:   computeListSummary(filtered),
+			Plan:      &plan,
+			Versions:  filtered.versions,
+			Warnings:  warnings,
+			Infos:     collapseWarnings(filtered.infos),
+			Meta:      filtered.meta,
+
+			Invocation: newInvocation(cmd, cfg, false),
+		}
+		renderer := output.NewJSON(cmd.OutOrStdout())
+		renderer.Compact = cfg.Compact
+		if err := renderer.Render(jsonReport); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+	if plan.Failed > 0 {
+		return &usageError{msg: fmt.Sprintf("plan: %d step(s) would fail to start", plan.Failed)}
+	}
+	return nil
+}
+
+// gitRef returns the full ref of the checked-out branch for expanding
+// concurrency groups, preferring GITHUB_REF when set.
+func gitRef(root string) string {
+	if ref := os.Getenv("GITHUB_REF"); ref != "" {
+		return ref
+	}
+	git := exec.Command("git", "symbolic-ref", "-q", "HEAD")
+	git.Dir = root
+	out, err := git.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// reportCancelInProgress notes, when running verbosely, that
+// cancel-in-progress has no local effect.
+func reportCancelInProgress(w io.Writer, cfg config.Config, runOpts runner.Options, workflows []provider.Workflow) {
+	if !cfg.Verbose {
+		return
+	}
+	if groups := runner.CancelInProgressGroups(workflows, runOpts.GitRef); len(groups) > 0 {
+		fmt.Fprintf(w, "info: cancel-in-progress is ignored locally for concurrency group(s): %s\n", strings.Join(groups, ", "))
+	}
+}
+
+// scheduleDurations returns the last recorded duration of each job when
+// parallel runs schedule longest-first, and nil otherwise.
+func scheduleDurations(cfg config.Config, root string) (map[history.JobKey]time.Duration, error) {
+	switch cfg.Schedule {
+	case config.ScheduleDeclared:
+		return nil, nil
+	case config.ScheduleLongestFirst:
+	default:
+		return nil, fmt.Errorf("unsupported schedule %q; use %s or %s", cfg.Schedule, config.ScheduleDeclared, config.ScheduleLongestFirst)
+	}
+	if cfg.MaxParallel <= 1 || cfg.DryRun {
+		return nil, nil
+	}
+	runs, err := history.Open(root).Load()
+	if err != nil {
+		return nil, err
+	}
+	return history.LastJobDurations(runs), nil
+}
+
+// recordRuns is switched off by tests so runs against the repository's own
+// fixtures leave no history behind.
+var recordRuns = true
+
+// recordHistory appends a finished run to the project's history. Dry runs
+// time nothing and are not recorded; a history that cannot be written only
+// warns, since the run itself already happened.
+func recordHistory(w io.Writer, cfg config.Config, root string, startedAt time.Time, results []report.StepResult, summary report.Summary) {
+	if !recordRuns || !cfg.History || cfg.DryRun || summary.SelectedSteps == 0 {
+		return
+	}
+	if err := history.Open(root).Append(history.NewRun(startedAt, results, summary)); err != nil {
+		fmt.Fprintf(w, "warning: %v\n", err)
+	}
+}
+
+// followJob returns the matcher for the follow setting, or nil when it is
+// unset.
+func followJob(pattern string) (func(provider.Job) bool, error) {
+	patterns, err := filter.Compile([]string{pattern})
+	if err != nil {
+		return nil, fmt.Errorf("follow: %w", err)
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	return func(job provider.Job) bool {
+		return patterns[0].Match(job.Name) || patterns[0].Match(job.RawID)
+	}, nil
+}
+
+// flakyIndex scores steps against the recorded runs so flaky ones can be
+// marked in the results. It is nil when history is off.
+func flakyIndex(cfg config.Config, root string) (*history.FlakyIndex, error) {
+	if !cfg.History || cfg.DryRun {
+		return nil, nil
+	}
+	runs, err := history.Open(root).Load()
+	if err != nil {
+		return nil, err
+	}
+	return history.NewFlakyIndex(runs), nil
+}
+
+// writeTrace saves the run's timings to the --trace file, if one was given.
+func writeTrace(cmd *cobra.Command, results []report.StepResult, summary report.Summary) error {
+	path, err := cmd.Flags().GetString("trace")
+	if err != nil {
+		return fmt.Errorf("parse --trace: %w", err)
+	}
+	if path == "" {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("write trace: %w", err)
+	}
+	if err := output.NewJSON(f).RenderTrace(results, summary.Jobs); err != nil {
+		f.Close()
+		return fmt.Errorf("write trace %q: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write trace %q: %w", path, err)
+	}
+	return nil
+}
+
+// outputMode is how `run` shows its results.
+type outputMode int
+
+const (
+	// outputBatch renders every result once the run ends.
+	outputBatch outputMode = iota
+	// outputStreaming redraws a live block of job lines in place.
+	outputStreaming
+	// outputStreamingPlain prints job lines as jobs start and finish,
+	// without moving the cursor.
+	outputStreamingPlain
+)
+
+// chooseOutputMode picks how results are shown from cfg, with --streaming
+// and --no-streaming already applied as output.stream, and whether output
+// goes to a terminal, --debug is on, and a pager is in use.
+//
+// Only the pretty format streams. With output.stream auto, a run streams
+// unless it is verbose, since step output would land in the live redraw; a
+// dry run piped elsewhere, which keeps the plain plan listing; a parallel
+// run, since the streaming view follows one job at a time; a debug run,
+// whose log lines would break the redraw; or a paged run, since the pager
+// needs the finished failure block. output.stream true streams anyway, in
+// plain form when a redraw would break, but still not in parallel runs.
+func chooseOutputMode(cfg config.Config, terminal, debug, paged bool) (outputMode, error) {
+	if strings.ToLower(cfg.Format) != config.FormatPretty {
+		return outputBatch, nil
+	}
+	switch cfg.Output.Stream {
+	case config.StreamOff:
+		return outputBatch, nil
+	case config.StreamOn:
+		if cfg.MaxParallel > 1 {
+			return outputBatch, nil
+		}
+		if cfg.Verbose || debug || !terminal {
+			return outputStreamingPlain, nil
+		}
+		return outputStreaming, nil
+	case config.StreamAuto, "":
+		if !cfg.Verbose && (!cfg.DryRun || terminal) && cfg.MaxParallel <= 1 && !debug && !paged {
+			return outputStreaming, nil
+		}
+		return outputBatch, nil
+	default:
+		return outputBatch, fmt.Errorf("unsupported output.stream %q; use %s, %s or %s", cfg.Output.Stream, config.StreamAuto, config.StreamOn, config.StreamOff)
+	}
+}
+
+// jobBudgets collects the time limits of every job in workflows: its
+// timeout-minutes, and the first time_budgets entry, in pattern order, that
+// matches its name or ID.
+func jobBudgets(cfg config.Config, workflows []provider.Workflow) ([]report.JobBudget, error) {
+	type entry struct {
+		pattern filter.Pattern
+		budget  time.Duration
+	}
+	raw := make([]string, 0, len(cfg.TimeBudgets))
+	for pattern := range cfg.TimeBudgets {
+		raw = append(raw, pattern)
+	}
+	sort.Strings(raw)
+	entries := make([]entry, 0, len(raw))
+	for _, pattern := range raw {
+		budget, err := time.ParseDuration(strings.TrimSpace(cfg.TimeBudgets[pattern]))
+		if err != nil || budget <= 0 {
+			return nil, fmt.Errorf("time_budgets: %q: invalid duration %q (use a duration such as 20m or 1h30m)", pattern, cfg.TimeBudgets[pattern])
+		}
+		compiled, err := filter.Compile([]string{pattern})
+		if err != nil {
+			return nil, fmt.Errorf("time_budgets: %w", err)
+		}
+		if len(compiled) > 0 {
+			entries = append(entries, entry{pattern: compiled[0], budget: budget})
+		}
+	}
+
+	var budgets []report.JobBudget
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			b := report.JobBudget{
+				WorkflowPath: wf.Path,
+				JobName:      job.Name,
+				Timeout:      time.Duration(job.TimeoutMinutes * float64(time.Minute)),
+			}
+			for _, e := range entries {
+				if e.pattern.Match(job.Name) || e.pattern.Match(job.RawID) {
+					b.Budget = e.budget
+					break
+				}
+			}
+			if b.Timeout > 0 || b.Budget > 0 {
+				budgets = append(budgets, b)
+			}
+		}
+	}
+	return budgets, nil
+}
+
+// detectedVersions maps each tool the version check probed to the version
+// it found, for the environment snapshots of failed steps.
+func detectedVersions(checks []report.VersionCheck) map[string]string {
+	versions := make(map[string]string)
+	for _, check := range checks {
+		if check.Detected != "" {
+			versions[check.Tool] = check.Detected
+		}
+	}
+	return versions
+}
+
+// checkBudgets returns the jobs that ran over their limits, unless
+// time_budget warnings are suppressed.
+func checkBudgets(cfg config.Config, budgets []report.JobBudget, summary report.Summary) ([]report.BudgetOverrun, error) {
+	suppressed, err := provider.ParseWarningKinds(cfg.SuppressWarnings)
+	if err != nil {
+		return nil, fmt.Errorf("suppress_warnings: %w", err)
+	}
+	if suppressed[provider.WarnTimeBudget] || cfg.DryRun {
+		return nil, nil
+	}
+	return report.CheckBudgets(summary.Jobs, budgets), nil
+}
+
+// unexpectedPasses returns a warning for each step marked as an expected
+// failure that passed, unless unexpected_pass warnings are suppressed.
+func unexpectedPasses(cfg config.Config, results []report.StepResult) ([]string, error) {
+	suppressed, err := provider.ParseWarningKinds(cfg.SuppressWarnings)
+	if err != nil {
+		return nil, fmt.Errorf("suppress_warnings: %w", err)
+	}
+	if suppressed[provider.WarnUnexpectedPass] {
+		return nil, nil
+	}
+	var warnings []string
+	for _, res := range results {
+		if res.Status == "xpass" {
+			warnings = append(warnings, fmt.Sprintf("%s:%s: step %q passed but is marked as an expected failure; drop it from expected_failures or remove its # testdrive: xfail comment", res.WorkflowPath, res.JobName, res.StepName))
+		}
+	}
+	return warnings, nil
+}
+
+// executePipeline runs the filtered workflows with root as the working copy
+// and renders the results, recording them when --record is set.
+func executePipeline(cmd *cobra.Command, cfg config.Config, root string, filtered pipelineData) error {
+	path, err := cmd.Flags().GetString("record")
+	if err != nil {
+		return fmt.Errorf("parse --record: %w", err)
+	}
+	if path == "" {
+		return runPipeline(cmd, cfg, root, filtered)
+	}
+	stop, err := startRecording(cmd, path)
+	if err != nil {
+		return err
+	}
+	// The cast is for replaying the run, so it shows step output, and
+	// failure blocks stay inline rather than in a pager it cannot record.
+	cfg.Verbose = true
+	cfg.Output.Pager = config.PagerNever
+	runErr := runPipeline(cmd, cfg, root, filtered)
+	if err := stop(); err != nil && runErr == nil {
+		return err
+	}
+	return runErr
+}
+
+// startRecording tees cmd's output into an asciinema cast at path. The
+// returned function restores the output and closes the cast.
+func startRecording(cmd *cobra.Command, path string) (func() error, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("write recording: %w", err)
+	}
+	header := runner.CastHeader{Width: 80, Height: 24, Title: cmd.CommandPath()}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		header.Width = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 0 {
+		header.Height = n
+	}
+	for _, name := range []string{"SHELL", "TERM"} {
+		if v := os.Getenv(name); v != "" {
+			if header.Env == nil {
+				header.Env = map[string]string{}
+			}
+			header.Env[name] = v
+		}
+	}
+	rec, err := runner.NewCastRecorder(f, header, nil)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write recording %q: %w", path, err)
+	}
+	stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
+	cmd.SetOut(rec.Writer(stdout))
+	cmd.SetErr(rec.Writer(stderr))
+	return func() error {
+		cmd.SetOut(stdout)
+		cmd.SetErr(stderr)
+		err := rec.Close()
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("write recording %q: %w", path, err)
+		}
+		return nil
+	}, nil
+}
+
+// errStepsFailed is how a run that finished with failed steps fails.
+var errStepsFailed = errors.New("one or more steps failed")
+
+// runPipeline is executePipeline without the recording.
+func runPipeline(cmd *cobra.Command, cfg config.Config, root string, filtered pipelineData) error {
+	runOpts := runnerOptions(cmd, cfg, root, filtered.env)
+	durations, err := scheduleDurations(cfg, filtered.root)
+	if err != nil {
+		return err
+	}
+	runOpts.JobDurations = durations
+	runOpts.Totals = filtered.totals
+	runOpts.ToolVersions = detectedVersions(filtered.versions)
+	if runOpts.JobOrder, err = replayOrder(cmd); err != nil {
+		return err
+	}
+	warnUnreplayed(cmd.ErrOrStderr(), runOpts.JobOrder, filtered.workflows)
+	if runOpts.Flaky, err = flakyIndex(cfg, filtered.root); err != nil {
+		return err
+	}
+	if runOpts.FollowJob, err = followJob(cfg.Follow); err != nil {
+		return err
+	}
+	if confirm, err := destructiveConfirmation(cmd, cfg); err != nil {
+		return err
+	} else if confirm != nil {
+		runOpts.ConfirmDestructive = confirm
+	}
+
+	budgets, err := jobBudgets(cfg, filtered.workflows)
+	if err != nil {
+		return err
+	}
+
+	pager, err := newPager(cmd, cfg)
+	if err != nil {
+		return err
+	}
+
+	mode, err := chooseOutputMode(cfg, outputIsTerminal(cmd), debugEnabled(cmd), pager != nil)
+	if err != nil {
+		return err
+	}
+	// Every renderer that shows the run as it goes gets the runner's calls
+	// through one MultiRenderer.
+	var live []output.StreamingRenderer
+	switch mode {
+	case outputStreaming:
+		renderer := output.NewStreamingPrettyLayout(cmd.OutOrStdout(), prettyLayout(cfg))
+		renderer.Meta = filtered.meta
+		live = append(live, renderer)
+	case outputStreamingPlain:
+		renderer := output.NewPlainStreamingPrettyLayout(cmd.OutOrStdout(), prettyLayout(cfg))
+		renderer.Meta = filtered.meta
+		live = append(live, renderer)
+	}
+	stream, err := cmd.Flags().GetBool("stream")
+	if err != nil {
+		return fmt.Errorf("parse --stream: %w", err)
+	}
+	// A JSON stream reports each job as it runs, so jobs take turns as they
+	// do in the streaming view.
+	var jsonStream *output.JSONStreamRenderer
+	if stream {
+		jsonStream = output.NewJSONStream(cmd.OutOrStdout())
+		jsonStream.Provider = filtered.provider
+		live = append(live, jsonStream)
+	}
+	// streaming is whether the results themselves show as the run goes; a
+	// listening server is fed either way, and likewise has jobs take turns.
+	streaming := len(live) > 0
+	listenAddr, err := cmd.Flags().GetString("listen")
+	if err != nil {
+		return fmt.Errorf("parse --listen: %w", err)
+	}
+	if listenAddr != "" {
+		server, err := listen.Listen(listenAddr)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := server.Close(); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: stop listening: %v\n", err)
+			}
+		}()
+		server.SetProvider(filtered.provider)
+		fmt.Fprintf(cmd.ErrOrStderr(), "listening on %s\n", server.Addr())
+		live = append(live, server)
+	}
+	if len(live) > 0 {
+		multi := output.NewMultiRenderer(live...)
+		multi.Warn = cmd.ErrOrStderr()
+		runOpts.Streaming = true
+		runOpts.StreamingRenderer = multi
+	}
+	debugLog(cmd).Debug("renderer selected", "format", strings.ToLower(cfg.Format), "streaming", streaming, "listen", listenAddr, "max_parallel", cfg.MaxParallel)
+
+	reportCancelInProgress(cmd.ErrOrStderr(), cfg, runOpts, filtered.workflows)
+	if streaming {
+		// The streaming view prints no warnings, but these matter before
+		// anything runs.
+		for _, w := range filtered.warnings {
+			if w.Kind == provider.WarnGitState || w.Kind == provider.WarnVarsMissing {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", w.Message)
+			}
+		}
+		printInfos(cmd.ErrOrStderr(), cfg, filtered.infos)
+	}
+
+	startedAt := time.Now()
 	execRunner := runner.New(runOpts)
-	results, summary, err := execRunner.Run(filtered.workflows)
+	ctx := cmd.Context()
+	results, summary, err := execRunner.Run(ctx, filtered.workflows)
 	if err != nil {
 		return err
 	}
+	if ctx.Err() == nil {
+		// An interrupted run's durations would skew the schedule.
+		recordHistory(cmd.ErrOrStderr(), cfg, filtered.root, startedAt, results, summary)
+	}
 
-	if summary.TotalSteps == 0 {
+	if summary.SelectedSteps == 0 && jsonStream == nil {
 		// In streaming mode, the renderer already showed initial job lines; don't print this footer.
-		if !runOpts.Streaming {
+		if !streaming {
 			fmt.Fprintln(cmd.OutOrStdout(), "No matching jobs or steps")
 		}
 		return nil
 	}
 
+	if err := writeTrace(cmd, results, summary); err != nil {
+		return err
+	}
+
+	overruns, err := checkBudgets(cfg, budgets, summary)
+	if err != nil {
+		return err
+	}
 	warnings := collapseWarnings(filtered.warnings)
+	for _, o := range overruns {
+		warnings = append(warnings, fmt.Sprintf("%s:%s: %s", o.WorkflowPath, o.JobName, output.OverrunMessage(o)))
+	}
+	xpasses, err := unexpectedPasses(cfg, results)
+	if err != nil {
+		return err
+	}
+	warnings = append(warnings, xpasses...)
+	coverage := report.BuildCoverage(filtered.dropped, results)
+
+	explainSkips, err := cmd.Flags().GetBool("explain-skips")
+	if err != nil {
+		return fmt.Errorf("parse --explain-skips: %w", err)
+	}
+	groupByPrefix, err := cmd.Flags().GetBool("group-by-prefix")
+	if err != nil {
+		return fmt.Errorf("parse --group-by-prefix: %w", err)
+	}
+	showStdout, err := cmd.Flags().GetBool("show-stdout-on-failure")
+	if err != nil {
+		return fmt.Errorf("parse --show-stdout-on-failure: %w", err)
+	}
 
 	switch strings.ToLower(cfg.Format) {
 	case config.FormatPretty:
 		// Only use pretty renderer if not streaming
-		if !runOpts.Streaming {
-			renderer := output.NewPretty(cmd.OutOrStdout())
+		if !streaming {
+			renderer := newPretty(cmd, cfg)
+			renderer.GroupByPrefix = groupByPrefix
+			renderer.ShowStdoutOnFailure = showStdout
+			renderer.TailLines = cfg.TailLines
+			renderer.Pager = pager
+			renderer.Meta = filtered.meta
 			if err := renderer.RenderResults(results, summary); err != nil {
 				return err
 			}
 		}
+		if err := newPretty(cmd, cfg).RenderOverruns(overruns); err != nil {
+			return err
+		}
+		if err := newPretty(cmd, cfg).RenderLocalCoverage(filtered.localCoverage, explainSkips); err != nil {
+			return err
+		}
+		if err := newPretty(cmd, cfg).RenderStepSummaries(summary.Jobs); err != nil {
+			return err
+		}
+		if explainSkips {
+			if err := newPretty(cmd, cfg).RenderCoverage(coverage); err != nil {
+				return err
+			}
+		}
 		// Only show warnings for non-streaming mode
-		if !runOpts.Streaming && len(warnings) > 0 {
+		if !streaming {
 			for _, msg := range warnings {
 				fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", msg)
 			}
+			printInfos(cmd.ErrOrStderr(), cfg, filtered.infos)
+		} else {
+			// The live view shows no warnings, but a stale marker should
+			// not go unnoticed.
+			for _, msg := range xpasses {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", msg)
+			}
 		}
 	case config.FormatJSON:
 		jsonReport := output.Report{
-			Provider:  filtered.provider,
-			Workflows: filtered.workflows,
-			Steps:     results,
-			Summary:   summary,
-			Warnings:  warnings,
+			Provider:      filtered.provider,
+			Workflows:     runner.RedactWorkflows(cfg.RedactEnvPatterns, filtered.workflows),
+			Steps:         results,
+			Summary:       summary,
+			Coverage:      &coverage,
+			LocalCoverage: &filtered.localCoverage,
+			Versions:      filtered.versions,
+			Warnings:      warnings,
+			Infos:         collapseWarnings(filtered.infos),
+			Overruns:      overruns,
+			Meta:          filtered.meta,
+			Invocation:    newInvocation(cmd, cfg, jsonStream != nil),
+		}
+		if jsonStream != nil {
+			if err := jsonStream.RenderReport(jsonReport); err != nil {
+				return err
+			}
+			break
 		}
 		renderer := output.NewJSON(cmd.OutOrStdout())
+		renderer.Compact = cfg.Compact
 		if err := renderer.Render(jsonReport); err != nil {
 			return err
 		}
@@ -101,8 +866,16 @@ func runExecute(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("unsupported format %q", cfg.Format)
 	}
 
+	if ctx.Err() != nil {
+		return fmt.Errorf("run interrupted: %d step(s) cancelled", summary.Cancelled)
+	}
+	if summary.ExitCode != 0 && cfg.DryRun {
+		// Only steps that cannot start fail a dry run; that is a problem
+		// with the workflow or config rather than with the code.
+		return &usageError{msg: fmt.Sprintf("dry run: %d step(s) would fail to start", summary.Failed)}
+	}
 	if summary.ExitCode != 0 {
-		return fmt.Errorf("one or more steps failed")
+		return errStepsFailed
 	}
 
 	return nil
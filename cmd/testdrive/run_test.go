@@ -2,15 +2,26 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/history"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/report"
 )
 
 func TestRunCommandDryPretty(t *testing.T) {
 	root := projectRoot(t)
 	chdir(t, root)
+	useGit(t, goldenCheckout)
 
 	cmd := newRootCmd()
 	cmd.SetArgs([]string{"run", "--workflow", "testdata/workflows/ci_basic.yml", "--dry-run"})
@@ -32,6 +43,7 @@ func TestRunCommandDryPretty(t *testing.T) {
 func TestRunCommandDryJSON(t *testing.T) {
 	root := projectRoot(t)
 	chdir(t, root)
+	useGit(t, goldenCheckout)
 
 	cmd := newRootCmd()
 	cmd.SetArgs([]string{"run", "--workflow", "testdata/workflows/ci_basic.yml", "--dry-run", "--format", "json"})
@@ -85,3 +97,620 @@ func TestRunCommandExecuteFailure(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestRunCommandShowsStdoutOfFailedSteps(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	workflow := "name: CI\njobs:\n  test:\n    steps:\n      - name: Unit\n        run: \"echo 'add_test.go:9: got 3, want 4'; exit 1\"\n"
+	if err := os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(workflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	chdir(t, dir)
+
+	for _, tc := range []struct {
+		args []string
+		want bool
+	}{
+		{args: nil, want: true},
+		{args: []string{"--show-stdout-on-failure=false"}, want: false},
+	} {
+		cmd := newRootCmd()
+		// Batch output is only used off the streaming path.
+		cmd.SetArgs(append([]string{"run", "--max-parallel", "2"}, tc.args...))
+		out := &bytes.Buffer{}
+		cmd.SetOut(out)
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err == nil {
+			t.Fatalf("expected the failing step to fail the run")
+		}
+		if got := strings.Contains(out.String(), "  add_test.go:9: got 3, want 4\n"); got != tc.want {
+			t.Fatalf("%v: stdout shown = %v, want %v:\n%s", tc.args, got, tc.want, out.String())
+		}
+	}
+}
+
+func TestCommandsRejectUnknownFormatBeforeRunning(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	workflow := "name: CI\njobs:\n  test:\n    steps:\n      - run: touch ran\n"
+	if err := os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(workflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	chdir(t, dir)
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"run", "--format", "josn"}, want: `unsupported format "josn" for run; use pretty, json`},
+		{args: []string{"run", "--format", "markdown"}, want: `unsupported format "markdown" for run; use pretty, json`},
+		{args: []string{"list", "--format", "josn"}, want: `unsupported format "josn" for list; use pretty, json, markdown, completion`},
+	} {
+		cmd := newRootCmd()
+		cmd.SetArgs(tc.args)
+		out := &bytes.Buffer{}
+		cmd.SetOut(out)
+		cmd.SetErr(&bytes.Buffer{})
+		err := cmd.Execute()
+		if err == nil || err.Error() != tc.want {
+			t.Fatalf("%v: expected %q, got %v", tc.args, tc.want, err)
+		}
+		if out.Len() != 0 {
+			t.Fatalf("%v: expected no output, got:\n%s", tc.args, out.String())
+		}
+		if _, err := os.Stat(filepath.Join(dir, "ran")); !os.IsNotExist(err) {
+			t.Fatalf("%v: expected no step to run, got %v", tc.args, err)
+		}
+	}
+}
+
+func TestRunCommandReportsInterruptedRun(t *testing.T) {
+	dir := scheduleFixture(t)
+	chdir(t, dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--format", "json"})
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	err := cmd.ExecuteContext(ctx)
+	if err == nil || err.Error() != "run interrupted: 2 step(s) cancelled" {
+		t.Fatalf("expected an interrupted run, got %v", err)
+	}
+	if !strings.Contains(out.String(), `"cancelled": 2`) || !strings.Contains(out.String(), `"skip_detail": "cancelled: run interrupted"`) {
+		t.Fatalf("expected the partial results to be rendered, got:\n%s", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(history.Dir))); !os.IsNotExist(err) {
+		t.Fatalf("expected an interrupted run to stay out of history, got %v", err)
+	}
+}
+
+func TestRunCommandRunsTeardownStepsLast(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	workflow := `name: CI
+jobs:
+  test:
+    steps:
+      - name: Stop services
+        run: echo stop
+      - name: Test
+        run: exit 1
+`
+	if err := os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(workflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".testdrive.yml"), []byte("teardown_steps:\n  - Stop services\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	chdir(t, dir)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--format", "json"})
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil {
+		t.Fatalf("expected the failing step to fail the run")
+	}
+	test, stop := strings.Index(out.String(), `"step_name": "Test"`), strings.Index(out.String(), `"step_name": "Stop services"`)
+	if test < 0 || stop < test || !strings.Contains(out.String()[stop:], `"teardown": true`) {
+		t.Fatalf("expected the teardown step to run after Test and be flagged, got:\n%s", out.String())
+	}
+}
+
+func TestRunCommandReportsTimeBudgetOverruns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	workflow := `name: CI
+jobs:
+  test:
+    steps:
+      - run: sleep 0.05
+  lint:
+    steps:
+      - run: "true"
+`
+	if err := os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(workflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".testdrive.yml"), []byte("time_budgets:\n  test: 1ms\n  lint: 1h\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	chdir(t, dir)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--format", "json"})
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("an overrun should not fail the run: %v", err)
+	}
+	var got struct {
+		Warnings []string `json:"warnings"`
+		Overruns []struct {
+			JobName string `json:"job_name"`
+			LimitMS int64  `json:"limit_ms"`
+			Source  string `json:"source"`
+		} `json:"time_budget_overruns"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v\n%s", err, out.String())
+	}
+	if len(got.Overruns) != 1 || got.Overruns[0].JobName != "test" || got.Overruns[0].LimitMS != 1 || got.Overruns[0].Source != "budget" {
+		t.Fatalf("expected one overrun of the test budget, got %+v", got.Overruns)
+	}
+	found := false
+	for _, w := range got.Warnings {
+		found = found || strings.Contains(w, `job "test" took`) && strings.HasSuffix(w, "exceeds time budget of 1ms")
+	}
+	if !found {
+		t.Fatalf("expected a time budget warning, got %q", got.Warnings)
+	}
+
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"run", "--format", "json", "--suppress", "time_budget"})
+	out.Reset()
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if strings.Contains(out.String(), "time_budget_overruns") {
+		t.Fatalf("expected suppressed overruns to be left out, got:\n%s", out.String())
+	}
+}
+
+func TestRunCommandRejectsInvalidTimeBudget(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte("jobs:\n  test:\n    steps:\n      - run: echo test\n"), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".testdrive.yml"), []byte("time_budgets:\n  test: soon\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	chdir(t, dir)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), `time_budgets: "test": invalid duration "soon"`) {
+		t.Fatalf("expected an invalid duration error, got %v", err)
+	}
+}
+
+func TestRunCommandHonorsDirectiveComments(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	workflow := `name: CI
+jobs:
+  test:
+    steps:
+      - name: Deploy preview
+        run: |
+          # testdrive: skip: needs AWS credentials
+          exit 1
+      - name: Install jq # testdrive: allow-privileged
+        run: sudo -n true || true
+      - name: Test
+        run: echo test
+`
+	if err := os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(workflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	chdir(t, dir)
+
+	execute := func(args ...string) string {
+		t.Helper()
+		cmd := newRootCmd()
+		cmd.SetArgs(append(args, "--no-version-check"))
+		out := &bytes.Buffer{}
+		cmd.SetOut(out)
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v\n%s", args, err, out.String())
+		}
+		return out.String()
+	}
+
+	listing := execute("list")
+	for _, want := range []string{
+		"    • Deploy preview [testdrive: skip: needs AWS credentials]\n",
+		"    • Install jq [testdrive: allow-privileged]\n",
+		"    • Test\n",
+	} {
+		if !strings.Contains(listing, want) {
+			t.Fatalf("expected %q in list output, got:\n%s", want, listing)
+		}
+	}
+
+	out := execute("run", "--max-parallel", "2", "--verbose")
+	for _, want := range []string{
+		"skipped by # testdrive: skip comment: needs AWS credentials",
+		") (allow-privileged)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in run output, got:\n%s", want, out)
+		}
+	}
+
+	reasons := func(args ...string) map[string]string {
+		t.Helper()
+		var decoded output.Report
+		if err := json.Unmarshal([]byte(execute(append([]string{"run", "--format", "json"}, args...)...)), &decoded); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		got := make(map[string]string)
+		for _, step := range decoded.Steps {
+			got[step.StepName] = step.Status + "/" + step.SkipReason
+		}
+		for _, skipped := range decoded.Coverage.Skipped {
+			if _, ran := got[skipped.StepName]; !ran {
+				got[skipped.StepName] = "dropped/" + skipped.Reason
+			}
+		}
+		return got
+	}
+	// Selecting a commented step does not run it; filtering it out drops it
+	// before the comment is consulted.
+	if got := reasons("--only-step", "Deploy preview"); got["Deploy preview"] != "skipped/comment" {
+		t.Fatalf("expected --only-step to keep the comment skip, got %v", got)
+	}
+	if got := reasons("--skip-step", "Deploy preview"); got["Deploy preview"] != "dropped/filtered_skip" || got["Test"] != "passed/" {
+		t.Fatalf("expected --skip-step to drop the step, got %v", got)
+	}
+}
+
+func TestRunCommandPositionalWorkflows(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "testdata/workflows/ci_envs.yml", "--workflow", "testdata/workflows/ci_basic.yml", "--dry-run"})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+	// ci_envs.yml runs in ./app, which the project does not have.
+	var usage *usageError
+	if err := cmd.Execute(); !errors.As(err, &usage) {
+		t.Fatalf("command execute: %v, want the dry run to fail to start a step", err)
+	}
+	for _, want := range []string{"command: go test ./...", "command: echo step"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %q from both workflows, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestRunCommandWritesTrace(t *testing.T) {
+	dir := scheduleFixture(t)
+	chdir(t, dir)
+	path := filepath.Join(dir, "trace.json")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--max-parallel", "2", "--trace", path})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read trace: %v", err)
+	}
+	var trace output.Trace
+	if err := json.Unmarshal(data, &trace); err != nil {
+		t.Fatalf("decode trace: %v\n%s", err, data)
+	}
+	jobs, steps := map[string]bool{}, 0
+	for _, ev := range trace.TraceEvents {
+		switch {
+		case ev.Ph == "X" && ev.Cat == "job":
+			jobs[ev.Name] = true
+		case ev.Ph == "X" && ev.Cat == "step":
+			steps++
+		}
+	}
+	if !jobs["fast"] || !jobs["slow"] || steps != 2 {
+		t.Fatalf("expected job and step events, got %+v", trace.TraceEvents)
+	}
+}
+
+func TestChooseOutputMode(t *testing.T) {
+	type env struct{ terminal, debug, paged bool }
+	tty := env{terminal: true}
+	for _, tc := range []struct {
+		name    string
+		stream  string
+		format  string
+		verbose bool
+		dryRun  bool
+		workers int
+		env     env
+		want    outputMode
+	}{
+		{name: "auto terminal", stream: config.StreamAuto, env: tty, want: outputStreaming},
+		{name: "auto piped", stream: config.StreamAuto, want: outputStreaming},
+		{name: "auto verbose", stream: config.StreamAuto, verbose: true, env: tty, want: outputBatch},
+		{name: "auto dry run terminal", stream: config.StreamAuto, dryRun: true, env: tty, want: outputStreaming},
+		{name: "auto dry run piped", stream: config.StreamAuto, dryRun: true, want: outputBatch},
+		{name: "auto parallel", stream: config.StreamAuto, workers: 4, env: tty, want: outputBatch},
+		{name: "auto debug", stream: config.StreamAuto, env: env{terminal: true, debug: true}, want: outputBatch},
+		{name: "auto paged", stream: config.StreamAuto, env: env{terminal: true, paged: true}, want: outputBatch},
+		{name: "auto json", stream: config.StreamAuto, format: config.FormatJSON, env: tty, want: outputBatch},
+		{name: "off terminal", stream: config.StreamOff, env: tty, want: outputBatch},
+		{name: "off piped", stream: config.StreamOff, want: outputBatch},
+		{name: "on terminal", stream: config.StreamOn, env: tty, want: outputStreaming},
+		{name: "on paged", stream: config.StreamOn, env: env{terminal: true, paged: true}, want: outputStreaming},
+		{name: "on verbose", stream: config.StreamOn, verbose: true, env: tty, want: outputStreamingPlain},
+		{name: "on debug", stream: config.StreamOn, env: env{terminal: true, debug: true}, want: outputStreamingPlain},
+		{name: "on piped", stream: config.StreamOn, want: outputStreamingPlain},
+		{name: "on dry run piped", stream: config.StreamOn, dryRun: true, want: outputStreamingPlain},
+		{name: "on parallel", stream: config.StreamOn, workers: 2, env: tty, want: outputBatch},
+		{name: "on json", stream: config.StreamOn, format: config.FormatJSON, env: tty, want: outputBatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Output.Stream = tc.stream
+			if tc.format != "" {
+				cfg.Format = tc.format
+			}
+			cfg.Verbose, cfg.DryRun = tc.verbose, tc.dryRun
+			if tc.workers > 0 {
+				cfg.MaxParallel = tc.workers
+			}
+			got, err := chooseOutputMode(cfg, tc.env.terminal, tc.env.debug, tc.env.paged)
+			if err != nil || got != tc.want {
+				t.Fatalf("chooseOutputMode = %v, %v; want %v", got, err, tc.want)
+			}
+		})
+	}
+
+	cfg := config.Default()
+	cfg.Output.Stream = "sometimes"
+	if _, err := chooseOutputMode(cfg, true, false, false); err == nil || !strings.Contains(err.Error(), `unsupported output.stream "sometimes"`) {
+		t.Fatalf("expected an unsupported value error, got %v", err)
+	}
+}
+
+func TestRunCommandStreamingFlags(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	execute := func(args ...string) (string, error) {
+		t.Helper()
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"run", "--workflow", "testdata/workflows/ci_basic.yml", "--dry-run"}, args...))
+		buf := &bytes.Buffer{}
+		cmd.SetOut(buf)
+		cmd.SetErr(&bytes.Buffer{})
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+
+	// Piped, a dry run prints the batch listing unless streaming is forced,
+	// which then avoids cursor movement.
+	batch, err := execute()
+	if err != nil || !strings.Contains(batch, "Workflow Basic CI") {
+		t.Fatalf("expected batch output, got %v:\n%s", err, batch)
+	}
+	streamed, err := execute("--streaming", "--verbose")
+	if err != nil || strings.Contains(streamed, "Workflow Basic CI") || strings.Contains(streamed, "\033[") || !strings.Contains(streamed, "build (dry run)") {
+		t.Fatalf("expected plain streaming output, got %v:\n%q", err, streamed)
+	}
+	if _, err := execute("--streaming", "--no-streaming"); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Fatalf("expected conflicting flags to fail, got %v", err)
+	}
+}
+
+func TestRunCommandRecordsCast(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	root := projectRoot(t)
+	chdir(t, root)
+	t.Setenv("COLUMNS", "120")
+	castPath := filepath.Join(t.TempDir(), "out.cast")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--workflow", "testdata/workflows/ci_run.yml", "--record", castPath})
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(out)
+	if err := cmd.Execute(); err == nil {
+		t.Fatalf("expected the failing step to fail the run")
+	}
+
+	data, err := os.ReadFile(castPath)
+	if err != nil {
+		t.Fatalf("read cast: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	var header struct {
+		Version int    `json:"version"`
+		Width   int    `json:"width"`
+		Height  int    `json:"height"`
+		Title   string `json:"title"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil || header.Version != 2 || header.Width != 120 || header.Height != 24 || header.Title != "testdrive run" {
+		t.Fatalf("unexpected header %q: %v", lines[0], err)
+	}
+	var played strings.Builder
+	last := 0.0
+	for _, line := range lines[1:] {
+		var event []any
+		if err := json.Unmarshal([]byte(line), &event); err != nil || len(event) != 3 || event[1] != "o" {
+			t.Fatalf("unexpected event %q: %v", line, err)
+		}
+		at := event[0].(float64)
+		if at < last {
+			t.Fatalf("event at %v goes back before %v", at, last)
+		}
+		last = at
+		played.WriteString(event[2].(string))
+	}
+	// The run is verbose, so step output is recorded along with the results.
+	if got, want := played.String(), strings.ReplaceAll(out.String(), "\n", "\r\n"); got != want {
+		t.Fatalf("cast plays back %q, terminal got %q", got, want)
+	}
+	for _, want := range []string{"hello", "Failing Step", "SUMMARY: 1 passed, 1 failed"} {
+		if !strings.Contains(played.String(), want) {
+			t.Fatalf("expected %q in the cast, got %q", want, played.String())
+		}
+	}
+}
+
+const selectionWorkflow = `name: CI
+jobs:
+  build:
+    steps:
+      - run: echo build
+      - uses: actions/checkout@v4
+  lint:
+    steps:
+      - run: echo lint
+  test:
+    steps:
+      - run: echo unit
+      - run: echo system
+`
+
+func TestRunCommandOnlyJobCountsSelection(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ci.yml"), []byte(selectionWorkflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	chdir(t, dir)
+	execute := func(args ...string) string {
+		t.Helper()
+		cmd := newRootCmd()
+		cmd.SetArgs(append(args, "--workflow", "ci.yml", "--only-job", "test"))
+		out := &bytes.Buffer{}
+		cmd.SetOut(out)
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	for _, args := range [][]string{{"run", "--format", "json"}, {"list", "--format", "json"}} {
+		var got struct {
+			Summary report.Summary `json:"summary"`
+		}
+		if err := json.Unmarshal([]byte(execute(args...)), &got); err != nil {
+			t.Fatalf("%v: decode: %v", args, err)
+		}
+		s := got.Summary
+		if s.TotalWorkflows != 1 || s.TotalJobs != 3 || s.TotalSteps != 4 || s.SelectedJobs != 1 || s.SelectedSteps != 2 {
+			t.Fatalf("%v: summary = %+v, want 3 jobs and 4 steps with 1 job and 2 steps selected", args, s)
+		}
+	}
+
+	if out := execute("run"); !strings.Contains(out, "Ran 1 of 3 jobs (2 of 4 steps)\nSUMMARY: 2 passed") {
+		t.Fatalf("expected the selection above the summary, got:\n%s", out)
+	}
+}
+
+func TestRunCommandExpandsMatrix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	workflow := `name: CI
+jobs:
+  test:
+    strategy:
+      max-parallel: 1
+      matrix:
+        shard: [1, 2, 3]
+    steps:
+      - name: Shard
+        run: "echo shard ${{ matrix.shard }}; [ ${{ matrix.shard }} != 2 ]"
+`
+	if err := os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".github", "workflows", "ci.yml"), []byte(workflow), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	chdir(t, dir)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--format", "json"})
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil {
+		t.Fatalf("expected the failing shard to fail the run")
+	}
+	var rep output.Report
+	if err := json.Unmarshal(out.Bytes(), &rep); err != nil {
+		t.Fatalf("decode report: %v\n%s", err, out.String())
+	}
+	got := make(map[string]string)
+	for _, step := range rep.Steps {
+		got[step.JobName] = step.Status + " " + step.SkipReason + strings.TrimSpace(step.Stdout)
+	}
+	want := map[string]string{
+		"test (1)": "passed shard 1",
+		"test (2)": "failed shard 2",
+		"test (3)": "skipped " + report.ReasonCancelled,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("results = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/provider/filter"
+	"github.com/bgricker/testdrive/internal/resolve"
+)
+
+// shellFlags are --override-shell and --trace-shell, which change how the
+// steps --only-step selects are run.
+type shellFlags struct {
+	override string
+	trace    bool
+	steps    []filter.Pattern
+}
+
+// newShellFlags reads the shell flags from cfg. They need --only-step, so a
+// stray flag cannot change every step in the run.
+func newShellFlags(cfg config.Config) (shellFlags, error) {
+	f := shellFlags{override: cfg.OverrideShell, trace: cfg.TraceShell}
+	if f.override == "" && !f.trace {
+		return f, nil
+	}
+	steps, err := filter.Compile(cfg.OnlySteps)
+	if err != nil {
+		return f, err
+	}
+	if len(steps) == 0 {
+		return f, fmt.Errorf("--override-shell and --trace-shell apply to the steps --only-step selects; pass --only-step")
+	}
+	if resolve.Interpreter(f.override) {
+		return f, fmt.Errorf("--override-shell %q: run scripts are shell commands, so the override must be a shell", f.override)
+	}
+	f.steps = steps
+	return f, nil
+}
+
+// labels returns how explain names the flags that change step.
+func (f shellFlags) labels() []string {
+	var labels []string
+	if f.override != "" {
+		labels = append(labels, "--override-shell")
+	}
+	if f.trace {
+		labels = append(labels, "--trace-shell")
+	}
+	return labels
+}
+
+// matches reports whether the flags change step.
+func (f shellFlags) matches(step provider.Step) bool {
+	return len(f.steps) > 0 && step.Run != "" && filter.MatchStep(step, f.steps)
+}
+
+// apply returns step as the flags have it run. Steps whose shell is an
+// interpreter are refused: their script is not shell code.
+func (f shellFlags) apply(wf provider.Workflow, job provider.Job, step provider.Step) (provider.Step, error) {
+	if !f.matches(step) {
+		return step, nil
+	}
+	shell, _ := resolve.Shell(wf, job, step)
+	if resolve.Interpreter(shell) {
+		return step, fmt.Errorf("step %q runs under %s, which is not a shell; --override-shell and --trace-shell do not apply", step.Name, shell)
+	}
+	if f.override != "" {
+		step.Shell = f.override
+		shell = f.override
+	}
+	if f.trace {
+		prefix, err := resolve.TracePrefix(shell)
+		if err != nil {
+			return step, fmt.Errorf("--trace-shell on step %q: %w", step.Name, err)
+		}
+		step.Run = prefix + step.Run
+	}
+	step.Overridden = true
+	return step, nil
+}
+
+// applyAll returns a copy of workflows with the flags applied.
+func (f shellFlags) applyAll(workflows []provider.Workflow) ([]provider.Workflow, error) {
+	if len(f.steps) == 0 {
+		return workflows, nil
+	}
+	out := make([]provider.Workflow, len(workflows))
+	for i, wf := range workflows {
+		jobs := make([]provider.Job, len(wf.Jobs))
+		for j, job := range wf.Jobs {
+			steps := make([]provider.Step, len(job.Steps))
+			for k, step := range job.Steps {
+				applied, err := f.apply(wf, job, step)
+				if err != nil {
+					return nil, err
+				}
+				steps[k] = applied
+			}
+			job.Steps = steps
+			jobs[j] = job
+		}
+		wf.Jobs = jobs
+		out[i] = wf
+	}
+	return out, nil
+}
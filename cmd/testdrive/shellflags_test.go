@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/output"
+)
+
+const shellFlagsWorkflow = `name: CI
+jobs:
+  test:
+    steps:
+      - name: Greet
+        run: echo "hello $USER_NAME"
+        env:
+          USER_NAME: ci
+      - name: Other
+        run: echo other
+      - name: Script
+        shell: python
+        run: print("hi")
+`
+
+func TestExplainShellFlags(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ci.yml"), []byte(shellFlagsWorkflow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, root)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("ASDF_DIR", "")
+
+	exps := explainJSON(t, "--only-step", "Greet", "--trace-shell", "--override-shell", "sh -e")
+	if len(exps) != 1 {
+		t.Fatalf("explanations = %+v", exps)
+	}
+	exp := exps[0]
+	want := []string{"sh", "-e", "-c", " set -x\necho \"hello $USER_NAME\""}
+	if !slices.Equal(exp.Argv, want) {
+		t.Fatalf("argv = %q, want %q", exp.Argv, want)
+	}
+	if exp.Shell != "sh -e" || exp.ShellSource != "override" {
+		t.Fatalf("shell = %q from %q, want sh -e from override", exp.Shell, exp.ShellSource)
+	}
+	if !slices.Equal(exp.Overrides, []string{"--override-shell", "--trace-shell"}) {
+		t.Fatalf("overrides = %q", exp.Overrides)
+	}
+
+	exps = explainJSON(t, "--only-step", "/Greet|Other/", "--trace-shell")
+	for _, exp := range exps {
+		if !strings.HasPrefix(exp.Run, "set -x\n") || exp.Argv[0] != "bash" {
+			t.Fatalf("%s: run %q, argv %q", exp.StepName, exp.Run, exp.Argv)
+		}
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--trace-shell"}, "pass --only-step"},
+		{[]string{"--only-step", "Script", "--trace-shell"}, `step "Script" runs under python, which is not a shell`},
+		{[]string{"--only-step", "Greet", "--override-shell", "python3"}, `--override-shell "python3": run scripts are shell commands`},
+		{[]string{"--only-step", "Greet", "--override-shell", "fish", "--trace-shell"}, "fish has no command tracing"},
+	} {
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs(append([]string{"explain", "--workflow", "ci.yml"}, tc.args...))
+		err := cmd.Execute()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("explain %v: err = %v, want it to mention %q", tc.args, err, tc.want)
+		}
+	}
+}
+
+func TestRunCommandTraceShell(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ci.yml"), []byte(shellFlagsWorkflow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"run", "--workflow", "ci.yml", "--only-step", "Greet", "--trace-shell", "--format", "json"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	var rep output.Report
+	if err := json.Unmarshal(stdout.Bytes(), &rep); err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Steps) != 1 {
+		t.Fatalf("steps = %+v", rep.Steps)
+	}
+	step := rep.Steps[0]
+	if step.Status != "passed" || !step.Overridden {
+		t.Fatalf("step = %+v", step)
+	}
+	if strings.TrimSpace(step.Stdout) != "hello ci" {
+		t.Fatalf("stdout = %q, want the trace kept out of it", step.Stdout)
+	}
+	if !strings.Contains(step.Stderr, `+ echo 'hello ci'`) {
+		t.Fatalf("stderr = %q, want the traced command", step.Stderr)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/history"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/spf13/cobra"
+)
+
+func newStatsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Summarize local usage from the runs recorded under .testdrive/history",
+		Long: `Stats reads the recorded runs and reports how many there were and how many
+passed, the pass rate and average wall time per day (or per week across more
+than a month), the steps that fail most, and the jobs run most. Nothing leaves
+the machine.`,
+		Args: cobra.NoArgs,
+		RunE: runStats,
+	}
+	cmd.Flags().String("since", "", "only count runs started within this long ago (7d, 2w, 36h) or since a date (2006-01-02)")
+	return cmd
+}
+
+func runStats(cmd *cobra.Command, _ []string) error {
+	cfg, root, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	sinceFlag, _ := cmd.Flags().GetString("since")
+	var since time.Time
+	if sinceFlag != "" {
+		if since, err = parseSince(sinceFlag, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	runs, corrupt, err := history.Open(root).Scan()
+	if err != nil {
+		return err
+	}
+	if corrupt > 0 {
+		fmt.Fprintf(cmd.ErrOrStderr(), "warning: skipped %d unreadable history entry(s)\n", corrupt)
+	}
+	if len(runs) == 0 && corrupt == 0 {
+		fmt.Fprintf(cmd.ErrOrStderr(), "warning: no runs recorded under %s yet\n", history.Dir)
+	}
+	records, undated := statsRecords(runs, since)
+	if undated > 0 {
+		fmt.Fprintf(cmd.ErrOrStderr(), "warning: skipped %d history entry(s) without a start time\n", undated)
+	}
+	stats := report.ComputeStats(records)
+	stats.Since = since.UTC()
+
+	switch strings.ToLower(cfg.Format) {
+	case config.FormatPretty:
+		return newPretty(cmd, cfg).RenderStats(stats)
+	case config.FormatJSON:
+		renderer := output.NewJSON(cmd.OutOrStdout())
+		renderer.Compact = cfg.Compact
+		return renderer.Encode(stats)
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+}
+
+// statsRecords converts recorded runs started at or after since, and counts
+// the runs passed over for lacking a start time.
+func statsRecords(runs []history.Run, since time.Time) ([]report.RunRecord, int) {
+	records := make([]report.RunRecord, 0, len(runs))
+	undated := 0
+	for _, run := range runs {
+		if run.StartedAt.IsZero() {
+			undated++
+			continue
+		}
+		if run.StartedAt.Before(since) {
+			continue
+		}
+		record := report.RunRecord{
+			StartedAt: run.StartedAt,
+			Duration:  time.Duration(run.DurationMS) * time.Millisecond,
+			ExitCode:  run.ExitCode,
+		}
+		for _, job := range run.Jobs {
+			record.Jobs = append(record.Jobs, report.JobRecord{WorkflowPath: job.Workflow, JobName: job.Name, Status: job.Status})
+		}
+		for _, step := range run.Steps {
+			record.Steps = append(record.Steps, report.StepRecord{WorkflowPath: step.Workflow, JobName: step.Job, StepName: step.Name, StepID: step.StepID, Status: step.Status})
+		}
+		records = append(records, record)
+	}
+	return records, undated
+}
+
+// parseSince turns --since into a cutoff: a Go duration, a number of days or
+// weeks (7d, 2w), or a date.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if n := len(value); n > 1 && (value[n-1] == 'd' || value[n-1] == 'w') {
+		if count, err := strconv.Atoi(value[:n-1]); err == nil && count >= 0 {
+			days := count
+			if value[n-1] == 'w' {
+				days *= 7
+			}
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: use a duration such as 7d, 2w or 36h, or a date such as 2006-01-02", value)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/history"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// writeHistory records runs in dir's history store, followed by extra raw
+// lines.
+func writeHistory(t *testing.T, dir string, runs []history.Run, extra ...string) {
+	t.Helper()
+	store := history.Open(dir)
+	for _, run := range runs {
+		if err := store.Append(run); err != nil {
+			t.Fatalf("append history: %v", err)
+		}
+	}
+	f, err := os.OpenFile(filepath.Join(store.Dir, "runs.jsonl"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	defer f.Close()
+	for _, line := range extra {
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			t.Fatalf("write history: %v", err)
+		}
+	}
+}
+
+func statsRun(at time.Time, status string) history.Run {
+	exit := 0
+	if status == "failed" {
+		exit = 1
+	}
+	return history.Run{
+		StartedAt:  at,
+		DurationMS: 2000,
+		ExitCode:   exit,
+		Jobs:       []history.Job{{Workflow: ".github/workflows/ci.yml", Name: "test", Status: status}},
+		Steps:      []history.Step{{Workflow: ".github/workflows/ci.yml", Job: "test", Name: "rspec", Status: status}},
+	}
+}
+
+func TestStatsCommandJSON(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC()
+	writeHistory(t, dir, []history.Run{
+		statsRun(now.AddDate(0, 0, -30), "failed"),
+		statsRun(now.Add(-2*time.Hour), "failed"),
+		statsRun(now.Add(-time.Hour), "passed"),
+	}, `{"exit_code":0}`, `{"started_at":`)
+	chdir(t, dir)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"stats", "--format", "json", "--since", "7d"})
+	out, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(errBuf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+
+	var stats report.Stats
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatalf("decode stats: %v\n%s", err, out.String())
+	}
+	if stats.Runs != 2 || stats.Passed != 1 || stats.Since.IsZero() {
+		t.Fatalf("expected the two runs from the last week, got %+v", stats)
+	}
+	if len(stats.FailingSteps) != 1 || stats.FailingSteps[0].StepName != "rspec" || stats.FailingSteps[0].Failures != 1 {
+		t.Fatalf("failing steps = %+v", stats.FailingSteps)
+	}
+	for _, want := range []string{"skipped 1 unreadable history entry(s)", "skipped 1 history entry(s) without a start time"} {
+		if !strings.Contains(errBuf.String(), want) {
+			t.Fatalf("expected warning %q, got %q", want, errBuf.String())
+		}
+	}
+}
+
+func TestStatsCommandPretty(t *testing.T) {
+	dir := t.TempDir()
+	at := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	writeHistory(t, dir, []history.Run{statsRun(at, "failed"), statsRun(at.Add(time.Hour), "passed")})
+	chdir(t, dir)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"stats"})
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	for _, want := range []string{
+		"RUNS: 2, 1 passed (50%), average 2s, 2026-10-12 to 2026-10-12",
+		"TREND (by day):",
+		"2026-10-12  2 runs  50% passed  average 2s",
+		"MOST FAILING STEPS:",
+		".github/workflows/ci.yml / test / rspec  1 of 2 runs failed",
+		"MOST RUN JOBS:",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestStatsCommandWithoutHistory(t *testing.T) {
+	chdir(t, t.TempDir())
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"stats"})
+	out, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(errBuf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	if out.String() != "No runs recorded\n" || !strings.Contains(errBuf.String(), "no runs recorded under .testdrive/history yet") {
+		t.Fatalf("unexpected output %q / %q", out.String(), errBuf.String())
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"7d":  now.AddDate(0, 0, -7),
+		"2w":  now.AddDate(0, 0, -14),
+		"36h": now.Add(-36 * time.Hour),
+	}
+	for value, want := range cases {
+		got, err := parseSince(value, now)
+		if err != nil || !got.Equal(want) {
+			t.Fatalf("parseSince(%q) = %s, %v; want %s", value, got, err, want)
+		}
+	}
+	if got, err := parseSince("2026-10-01", now); err != nil || got.Format("2006-01-02 15:04") != "2026-10-01 00:00" {
+		t.Fatalf("parseSince(date) = %s, %v", got, err)
+	}
+	if _, err := parseSince("last week", now); err == nil || !strings.Contains(err.Error(), "invalid --since") {
+		t.Fatalf("expected an invalid --since error, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/output"
+)
+
+func TestRunCommandStepSummaryPretty(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--workflow", "testdata/workflows/ci_step_summary.yml", "--verbose"})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v\n%s", err, buf.String())
+	}
+
+	want := "STEP SUMMARIES:\n  Step Summary / sizes\n    Bundle sizes\n\n    Bundle  Size\n    app.js  120 kB\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Fatalf("expected step summaries section:\n%s", buf.String())
+	}
+	_, section, _ := strings.Cut(buf.String(), "STEP SUMMARIES:")
+	if strings.Contains(section, "quiet") {
+		t.Fatalf("job without a summary should be omitted:\n%s", buf.String())
+	}
+}
+
+func TestRunCommandStepSummaryJSON(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--workflow", "testdata/workflows/ci_step_summary.yml", "--format", "json"})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+
+	var decoded output.Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	summaries := map[string]string{}
+	for _, job := range decoded.Summary.Jobs {
+		summaries[job.JobName] = job.StepSummary
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("expected two jobs, got %+v", decoded.Summary.Jobs)
+	}
+	want := "### Bundle sizes\n\n| Bundle | Size |\n| --- | ---: |\n| app.js | 120 kB |\n"
+	if summaries["sizes"] != want {
+		t.Fatalf("step_summary = %q, want %q", summaries["sizes"], want)
+	}
+	if summaries["quiet"] != "" {
+		t.Fatalf("expected empty summary for quiet job, got %q", summaries["quiet"])
+	}
+	if !strings.Contains(buf.String(), `"step_summary"`) {
+		t.Fatalf("expected step_summary field in JSON output")
+	}
+}
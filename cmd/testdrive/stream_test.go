@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/output"
+)
+
+const streamWorkflow = `name: CI
+jobs:
+  test:
+    steps:
+      - name: Fast
+        run: echo fast
+      - name: Slow
+        run: sleep 5
+`
+
+// streamHelperEnv makes the test binary run `run --format json --stream`
+// in the directory it names instead of the tests, so a test can kill a real
+// run partway through.
+const streamHelperEnv = "TESTDRIVE_STREAM_HELPER_DIR"
+
+func TestRunCommandJSONStreamKilled(t *testing.T) {
+	if dir := os.Getenv(streamHelperEnv); dir != "" {
+		if err := os.Chdir(dir); err != nil {
+			os.Exit(2)
+		}
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"run", "--workflow", "ci.yml", "--format", "json", "--stream", "--no-lock"})
+		if err := cmd.Execute(); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ci.yml"), []byte(streamWorkflow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	proc := exec.Command(os.Args[0], "-test.run=^TestRunCommandJSONStreamKilled$")
+	proc.Env = append(os.Environ(), streamHelperEnv+"="+root)
+	stdout, err := proc.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proc.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		var event output.StreamEvent
+		if json.Unmarshal(scanner.Bytes(), &event) == nil && event.Event == output.EventStepStarted && event.Step == "Slow" {
+			break
+		}
+	}
+	if err := proc.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	proc.Wait()
+
+	var events []output.StreamEvent
+	for _, line := range lines {
+		var event output.StreamEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("line %q of the killed run does not parse: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Event+":"+e.Step)
+	}
+	want := "start:,job_started:,step_started:Fast,step_finished:Fast,step_started:Slow"
+	if got := strings.Join(names, ","); got != want {
+		t.Fatalf("events before the kill = %s, want %s", got, want)
+	}
+	if events[0].Provider != "github" || len(events[0].Workflows) != 1 {
+		t.Fatalf("start event = %+v", events[0])
+	}
+	if res := events[3].Result; res == nil || res.Status != "passed" || strings.TrimSpace(res.Stdout) != "fast" {
+		t.Fatalf("Fast result = %+v", res)
+	}
+}
+
+func TestRunCommandJSONStreamReport(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ci.yml"), []byte(lockWorkflow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	stdout := &bytes.Buffer{}
+	cmd.SetArgs([]string{"run", "--workflow", "ci.yml", "--format", "json", "--stream"})
+	cmd.SetOut(stdout)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	var last struct {
+		Event string `json:"event"`
+		output.Report
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatalf("last line: %v", err)
+	}
+	if last.Event != output.EventReport || len(last.Steps) != 1 || last.Summary.Passed != 1 {
+		t.Fatalf("last line = %s", lines[len(lines)-1])
+	}
+
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"run", "--workflow", "ci.yml", "--stream"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil || err.Error() != "--stream requires --format json" {
+		t.Fatalf("--stream with pretty output: err = %v", err)
+	}
+}
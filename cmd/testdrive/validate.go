@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/shellaudit"
+	"github.com/spf13/cobra"
+)
+
+func newValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate [workflow...]",
+		Short: "Check that workflows parse and, with --shell-audit, that their scripts suit their shells",
+		Long: `Validate loads the workflows as list and run do, failing on any that cannot
+be parsed, and prints the warnings they raise.
+
+With --shell-audit, or shell_audit.enabled in config, it also scans each run
+script for lines its shell is likely to get wrong: bashisms in steps run by
+sh or dash, shell commands in steps run by python, and unquoted variables
+next to globs. Findings fail the command. The rules are regular expressions,
+so they can be wrong; shell_audit.disable turns one off by ID and
+shell_audit.rules adds your own.`,
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeWorkflowFiles,
+		RunE:              runValidate,
+	}
+	cmd.Flags().Bool("shell-audit", false, "scan run scripts for constructs their shell does not support")
+	return cmd
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	cfg, root, err := loadConfig(cmd, args...)
+	if err != nil {
+		return err
+	}
+	audit, err := cmd.Flags().GetBool("shell-audit")
+	if err != nil {
+		return fmt.Errorf("parse --shell-audit: %w", err)
+	}
+	audit = audit || cfg.ShellAudit.Enabled
+
+	data, err := loadPipeline(root, cfg, debugLog(cmd))
+	if err != nil {
+		return err
+	}
+	reportExcluded(cmd.ErrOrStderr(), cfg, data)
+	// Findings are reported on their own here rather than as warnings.
+	filterCfg := cfg
+	filterCfg.ShellAudit.Enabled = false
+	filtered, err := applyFilters(data, filterCfg, debugLog(cmd))
+	if err != nil {
+		return err
+	}
+	var findings []shellaudit.Finding
+	if audit {
+		if findings, err = auditShells(cfg, filtered.workflows); err != nil {
+			return err
+		}
+	}
+
+	warnings := collapseWarnings(filtered.warnings)
+	switch strings.ToLower(cfg.Format) {
+	case config.FormatPretty:
+		for _, msg := range warnings {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", msg)
+		}
+		printInfos(cmd.ErrOrStderr(), cfg, filtered.infos)
+		if err := newPretty(cmd, cfg).RenderValidation(len(data.workflows), findings, audit); err != nil {
+			return err
+		}
+	case config.FormatJSON:
+		renderer := output.NewJSON(cmd.OutOrStdout())
+		renderer.Compact = cfg.Compact
+		err := renderer.Encode(validation{
+			Workflows:  len(data.workflows),
+			Warnings:   warnings,
+			Infos:      collapseWarnings(filtered.infos),
+			ShellAudit: findings,
+		})
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+
+	if len(findings) > 0 {
+		return fmt.Errorf("%d shell audit finding(s)", len(findings))
+	}
+	return nil
+}
+
+// validation is the JSON form of the validate command's results.
+// ShellAudit is null when the audit did not run.
+type validation struct {
+	Workflows  int                  `json:"workflows"`
+	Warnings   []string             `json:"warnings,omitempty"`
+	Infos      []string             `json:"infos,omitempty"`
+	ShellAudit []shellaudit.Finding `json:"shell_audit"`
+}
+
+// auditShells runs the shell audit over workflows with the rules the config
+// leaves enabled.
+func auditShells(cfg config.Config, workflows []provider.Workflow) ([]shellaudit.Finding, error) {
+	added := make([]shellaudit.Rule, 0, len(cfg.ShellAudit.Rules))
+	for _, r := range cfg.ShellAudit.Rules {
+		added = append(added, shellaudit.Rule{ID: r.ID, Shell: r.Shell, Pattern: r.Pattern, Message: r.Message})
+	}
+	rules, err := shellaudit.Effective(added, cfg.ShellAudit.Disable)
+	if err != nil {
+		return nil, fmt.Errorf("shell_audit: %w", err)
+	}
+	findings := shellaudit.Audit(workflows, rules)
+	if findings == nil {
+		findings = []shellaudit.Finding{}
+	}
+	return findings, nil
+}
+
+// shellAuditWarnings turns shell audit findings into warnings.
+func shellAuditWarnings(findings []shellaudit.Finding) []provider.Warning {
+	warnings := make([]provider.Warning, 0, len(findings))
+	for _, f := range findings {
+		warnings = append(warnings, provider.Warning{
+			Kind:     provider.WarnShellAudit,
+			Workflow: f.Workflow,
+			Job:      f.Job,
+			Step:     f.Step,
+			Message:  f.String(),
+		})
+	}
+	return warnings
+}
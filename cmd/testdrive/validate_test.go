@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const shellAuditWorkflow = `name: CI
+defaults:
+  run:
+    shell: sh
+jobs:
+  test:
+    steps:
+      - name: Lint
+        run: |
+          if [[ -n "$X" ]]; then
+            source .env
+          fi
+      - name: Build
+        shell: bash
+        run: "[[ -d out ]] || make"
+`
+
+func shellAuditRepo(t *testing.T, config string) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ci.yml"), []byte(shellAuditWorkflow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if config != "" {
+		if err := os.WriteFile(filepath.Join(root, ".testdrive.yml"), []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chdir(t, root)
+}
+
+func TestValidateShellAudit(t *testing.T) {
+	shellAuditRepo(t, "")
+
+	out, _, err := executeRoot(t, "validate", "--workflow", "ci.yml")
+	if err != nil {
+		t.Fatalf("validate without --shell-audit: %v", err)
+	}
+	if strings.Contains(out, "SHELL AUDIT") || !strings.Contains(out, "VALIDATE: 1 workflow(s) parsed\n") {
+		t.Fatalf("expected no audit without --shell-audit:\n%s", out)
+	}
+
+	out, _, err = executeRoot(t, "validate", "--workflow", "ci.yml", "--shell-audit")
+	if err == nil || err.Error() != "2 shell audit finding(s)" {
+		t.Fatalf("expected the findings to fail validate, got %v", err)
+	}
+	for _, want := range []string{
+		"ci.yml / test / Lint line 1 (double-brackets, sh): [[ ]] is a bash test",
+		`      if [[ -n "$X" ]]; then`,
+		"ci.yml / test / Lint line 2 (source, sh)",
+		"VALIDATE: 1 workflow(s) parsed, 2 shell audit finding(s)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Build") {
+		t.Fatalf("bash steps may use bashisms:\n%s", out)
+	}
+
+	out, _, err = executeRoot(t, "validate", "--workflow", "ci.yml", "--shell-audit", "--format", "json")
+	if err == nil {
+		t.Fatalf("expected the findings to fail validate")
+	}
+	var got struct {
+		Workflows  int `json:"workflows"`
+		ShellAudit []struct {
+			Step string `json:"step"`
+			Line int    `json:"line"`
+			Rule string `json:"rule"`
+		} `json:"shell_audit"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("decode: %v\n%s", err, out)
+	}
+	if got.Workflows != 1 || len(got.ShellAudit) != 2 || got.ShellAudit[1].Rule != "source" || got.ShellAudit[1].Line != 2 {
+		t.Fatalf("unexpected report: %+v", got)
+	}
+}
+
+func TestValidateShellAuditConfig(t *testing.T) {
+	shellAuditRepo(t, `shell_audit:
+  disable: [double-brackets]
+  rules:
+    - id: no-fi
+      shell: sh
+      pattern: '^fi$'
+      message: closes an if
+`)
+	out, _, err := executeRoot(t, "validate", "--workflow", "ci.yml", "--shell-audit")
+	if err == nil || err.Error() != "2 shell audit finding(s)" {
+		t.Fatalf("expected two findings, got %v\n%s", err, out)
+	}
+	if strings.Contains(out, "double-brackets") || !strings.Contains(out, "Lint line 3 (no-fi, sh): closes an if") {
+		t.Fatalf("expected the config rules to apply:\n%s", out)
+	}
+
+	shellAuditRepo(t, "shell_audit:\n  disable: [doublebrackets]\n")
+	if _, _, err := executeRoot(t, "validate", "--workflow", "ci.yml", "--shell-audit"); err == nil || !strings.Contains(err.Error(), `shell_audit: unknown rule "doublebrackets"`) {
+		t.Fatalf("expected an unknown rule error, got %v", err)
+	}
+}
+
+func TestShellAuditWarnsWhenEnabled(t *testing.T) {
+	shellAuditRepo(t, "shell_audit:\n  enabled: true\n")
+
+	_, stderr, err := executeRoot(t, "list", "--workflow", "ci.yml")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if want := `warning: ci.yml:test: step "Lint" line 2: source is a bash builtin; sh has only . (source): source .env`; !strings.Contains(stderr, want) {
+		t.Fatalf("expected %q in stderr:\n%s", want, stderr)
+	}
+
+	// validate reports the findings once, as findings.
+	_, stderr, err = executeRoot(t, "validate", "--workflow", "ci.yml")
+	if err == nil || strings.Contains(stderr, "warning:") {
+		t.Fatalf("expected validate to audit without warning twice, got %v\n%s", err, stderr)
+	}
+
+	t.Setenv("TESTDRIVE_SUPPRESS_WARNINGS", "shell_audit")
+	if _, stderr, err = executeRoot(t, "list", "--workflow", "ci.yml"); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if strings.Contains(stderr, "shell") {
+		t.Fatalf("expected the warnings to be suppressed:\n%s", stderr)
+	}
+}
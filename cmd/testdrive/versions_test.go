@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/version"
+)
+
+func versionReportRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		".ruby-version":   "3.3.0\n",
+		".python-version": "3.12\n",
+		".sdkmanrc":       "java=21.0.2-tem\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	workflows := filepath.Join(dir, ".github", "workflows")
+	if err := os.MkdirAll(workflows, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	src, err := os.ReadFile(filepath.Join(projectRoot(t), "testdata", "workflows", "ci_basic.yml"))
+	if err != nil {
+		t.Fatalf("read workflow: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workflows, "ci.yml"), src, 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	return dir
+}
+
+func stubVersionDetector(t *testing.T) {
+	t.Helper()
+	prev := versionDetector
+	versionDetector = version.NewExecDetector(stubVersionOutput)
+	t.Cleanup(func() { versionDetector = prev })
+}
+
+// stubVersionOutput answers version probes with ruby 3.2.2, python 3.12.1
+// and java 21.0.2.
+func stubVersionOutput(name string, args ...string) (string, error) {
+	switch name {
+	case "ruby":
+		return "ruby 3.2.2 (2023-03-30 revision e51014f9c0) [x86_64-linux]", nil
+	case "python3":
+		return "Python 3.12.1", nil
+	case "java":
+		return `openjdk version "21.0.2" 2024-01-16`, nil
+	}
+	return "", os.ErrNotExist
+}
+
+func TestListJSONIncludesVersions(t *testing.T) {
+	dir := versionReportRepo(t)
+	stubVersionDetector(t)
+	chdir(t, dir)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"list", "--format", "json", "--check"})
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+
+	var rep output.Report
+	if err := json.Unmarshal(buf.Bytes(), &rep); err != nil {
+		t.Fatalf("decode report: %v\n%s", err, buf.String())
+	}
+	got := map[string]bool{}
+	for _, v := range rep.Versions {
+		got[v.Tool] = v.Match
+	}
+	want := map[string]bool{"ruby": false, "python": true, "java": true}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected versions: %+v", rep.Versions)
+	}
+	for tool, match := range want {
+		if m, ok := got[tool]; !ok || m != match {
+			t.Fatalf("%s: expected match=%v, got %+v", tool, match, rep.Versions)
+		}
+	}
+	for _, v := range rep.Versions {
+		if v.Tool == "java" && (v.RequiredSource != ".sdkmanrc" || v.Detected != "21.0.2") {
+			t.Fatalf("unexpected java check: %+v", v)
+		}
+	}
+
+	var warned bool
+	for _, w := range rep.Warnings {
+		if strings.Contains(w, "ruby version mismatch") {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Fatalf("expected ruby mismatch warning, got %+v", rep.Warnings)
+	}
+}
+
+func TestListDetailsRendersVersions(t *testing.T) {
+	dir := versionReportRepo(t)
+	stubVersionDetector(t)
+	chdir(t, dir)
+
+	run := func(args ...string) string {
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"list"}, args...))
+		buf := &bytes.Buffer{}
+		cmd.SetOut(buf)
+		cmd.SetErr(buf)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("command execute: %v", err)
+		}
+		return buf.String()
+	}
+
+	if out := run(); strings.Contains(out, "VERSIONS:") {
+		t.Fatalf("versions table should require --details:\n%s", out)
+	}
+	out := run("--details")
+	for _, want := range []string{"VERSIONS:", "ruby", "3.3.0 (.ruby-version)", "mismatch", "java", "21.0.2-tem (.sdkmanrc)"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+// watchPoll waits interval before the workflow files are checked again,
+// reporting false once ctx is done. Tests replace it to edit the files
+// between checks.
+var watchPoll = func(ctx context.Context, interval time.Duration) bool {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// fileStamp is what a watch compares to tell that a workflow file changed.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchPipeline runs the pipeline as run does, then again each time a
+// workflow file changes, until it is interrupted. While a workflow does not
+// parse, its parse error is pinned above the last good run's results and no
+// run starts; the next change that parses runs again. Failed steps do not
+// end the watch, but errors that would stop a run before it starts do.
+func watchPipeline(cmd *cobra.Command, cfg config.Config, root string) error {
+	for _, name := range []string{"plan", "interactive", "worktree", "stream"} {
+		on, err := cmd.Flags().GetBool(name)
+		if err != nil {
+			return fmt.Errorf("parse --%s: %w", name, err)
+		}
+		if on {
+			return fmt.Errorf("--watch cannot be combined with --%s", name)
+		}
+	}
+	if strings.ToLower(cfg.Format) != config.FormatPretty {
+		return fmt.Errorf("--watch requires --format pretty")
+	}
+	interval, err := cmd.Flags().GetDuration("watch-interval")
+	if err != nil {
+		return fmt.Errorf("parse --watch-interval: %w", err)
+	}
+	if interval <= 0 {
+		return fmt.Errorf("--watch-interval must be positive")
+	}
+
+	release, err := acquireRunLock(cmd, cfg, root)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx := cmd.Context()
+	banner := output.NewBanner(cmd.OutOrStdout(), outputIsTerminal(cmd))
+	banner.Reset()
+	ran := false
+	for {
+		// Files are stamped before they are read, so an edit made during
+		// the run starts another.
+		stamps := workflowStamps(root, cfg)
+		filtered, checks, err := preparePipeline(cmd, cfg, root)
+		var parseErr *provider.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			if err := banner.ShowParseError(parseErr, ran); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			banner.Reset()
+			if err := watchRun(cmd, cfg, root, filtered, checks); err != nil {
+				return err
+			}
+			ran = true
+		}
+
+		for {
+			if ctx.Err() != nil || !watchPoll(ctx, interval) {
+				return nil
+			}
+			next := workflowStamps(root, cfg)
+			if changed := changedFiles(stamps, next); len(changed) > 0 {
+				invalidateWorkflows(root, changed)
+				fmt.Fprintf(cmd.ErrOrStderr(), "info: %s changed\n", strings.Join(changed, ", "))
+				break
+			}
+		}
+	}
+}
+
+// watchRun is one run of a watch: the checks run does after loading, then
+// the run itself. A run that fails its steps or is interrupted is a result
+// rather than an error.
+func watchRun(cmd *cobra.Command, cfg config.Config, root string, filtered pipelineData, checks bool) error {
+	filtered.meta = describeCheckout(root)
+	if !cfg.DryRun && checks {
+		if err := checkRequiredEnv(cfg, filtered); err != nil {
+			return err
+		}
+		var err error
+		if filtered, err = checkGitState(cfg, root, filtered, false); err != nil {
+			return err
+		}
+	}
+	err := executePipeline(cmd, cfg, root, filtered)
+	var usage *usageError
+	if err == nil || errors.Is(err, errStepsFailed) || cmd.Context().Err() != nil {
+		return nil
+	}
+	if cfg.DryRun && errors.As(err, &usage) {
+		// Steps that cannot start may be fixed by the next edit.
+		fmt.Fprintf(cmd.ErrOrStderr(), "error: %v\n", err)
+		return nil
+	}
+	return err
+}
+
+// workflowStamps stats the workflow files a load would read. Files that
+// cannot be found or stat'ed are left out, so they show up as changed.
+func workflowStamps(root string, cfg config.Config) map[string]fileStamp {
+	paths, _, _ := localWorkflows(root, cfg)
+	stamps := make(map[string]fileStamp, len(paths))
+	for _, p := range paths {
+		info, err := os.Stat(workflowFile(root, p))
+		if err != nil {
+			continue
+		}
+		stamps[p] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps
+}
+
+// changedFiles lists, sorted, the paths added, removed, or modified between
+// two sets of stamps.
+func changedFiles(prev, next map[string]fileStamp) []string {
+	var changed []string
+	for p, stamp := range next {
+		if old, ok := prev[p]; !ok || old.size != stamp.size || !old.modTime.Equal(stamp.modTime) {
+			changed = append(changed, p)
+		}
+	}
+	for p := range prev {
+		if _, ok := next[p]; !ok {
+			changed = append(changed, p)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// invalidateWorkflows drops changed files from the parse cache, since an
+// edit within the file system's timestamp resolution can keep its stamp.
+func invalidateWorkflows(root string, paths []string) {
+	full := make([]string, len(paths))
+	for i, p := range paths {
+		full[i] = workflowFile(root, p)
+	}
+	parseCache.Invalidate(full...)
+}
+
+func workflowFile(root, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(root, path)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// watchEdits makes each check of a watch write the next content to path,
+// ending the watch once there is none left.
+func watchEdits(t *testing.T, path string, edits ...string) {
+	t.Helper()
+	prev := watchPoll
+	watchPoll = func(context.Context, time.Duration) bool {
+		if len(edits) == 0 {
+			return false
+		}
+		if err := os.WriteFile(path, []byte(edits[0]), 0o644); err != nil {
+			t.Fatalf("write workflow: %v", err)
+		}
+		edits = edits[1:]
+		return true
+	}
+	t.Cleanup(func() { watchPoll = prev })
+}
+
+func TestRunWatchHoldsRerunsWhileWorkflowDoesNotParse(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".github", "workflows", "ci.yml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	good := "name: CI\njobs:\n  test:\n    steps:\n      - name: Build\n        run: echo build\n"
+	broken := "name: CI\njobs:\n  test:\n    steps:\n      - name: Build\n        run: echo build\n      name: Lint\n"
+	fixed := good + "      - name: Lint\n        run: echo lint\n"
+	if err := os.WriteFile(path, []byte(good), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	chdir(t, dir)
+	watchEdits(t, path, broken, broken+"\n", fixed)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--watch", "--no-check", "--no-streaming"})
+	out, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(errBuf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v\n%s", err, errBuf.String())
+	}
+
+	banner := "PARSE ERROR: .github/workflows/ci.yml line 4: did not find expected '-' indicator\n" +
+		"  not rerunning until the file parses; the results below are from the last good run\n"
+	got := out.String()
+	first, held, rerun := strings.Index(got, "Build"), strings.Index(got, banner), strings.Index(got, "Lint")
+	if first < 0 || held < first || rerun < held {
+		t.Fatalf("expected a run, the parse error, then a rerun with the fix, got:\n%s", got)
+	}
+	if n := strings.Count(got, banner); n != 2 {
+		t.Fatalf("expected the banner once per broken edit, got %d:\n%s", n, got)
+	}
+	if n := strings.Count(got, "Build"); n != 2 {
+		t.Fatalf("expected two runs, none while the file was broken, got %d:\n%s", n, got)
+	}
+	if !strings.Contains(errBuf.String(), "info: .github/workflows/ci.yml changed\n") {
+		t.Fatalf("expected each change to be noted, got:\n%s", errBuf.String())
+	}
+}
+
+func TestRunWatchWaitsForFirstParse(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".github", "workflows", "ci.yml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("jobs: [\n"), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	chdir(t, dir)
+	watchEdits(t, path, "jobs:\n  test:\n    steps:\n      - name: Build\n        run: echo build\n")
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--watch", "--no-check", "--no-streaming"})
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v", err)
+	}
+	got := out.String()
+	if !strings.HasPrefix(got, "PARSE ERROR: .github/workflows/ci.yml line 1: did not find expected node content\n") || !strings.Contains(got, "  not running until the file parses\n") || !strings.Contains(got, "Build") {
+		t.Fatalf("expected the parse error, then a run once it parsed, got:\n%s", got)
+	}
+}
+
+func TestRunWatchRejectsOtherModes(t *testing.T) {
+	chdir(t, projectRoot(t))
+	for _, args := range [][]string{
+		{"--plan"},
+		{"--format", "json"},
+		{"--manifest", "repos.yml"},
+	} {
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"run", "--watch", "--workflow", "testdata/workflows/ci_basic.yml"}, args...))
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		err := cmd.Execute()
+		if err == nil || !strings.Contains(err.Error(), "--watch") {
+			t.Fatalf("run --watch %v: expected a --watch error, got %v", args, err)
+		}
+	}
+}
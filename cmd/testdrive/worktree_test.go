@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const worktreeWorkflow = `name: Generate
+jobs:
+  codegen:
+    steps:
+      - name: Write artifact
+        run: echo generated > artifact.txt && echo "$GITHUB_WORKSPACE" > workspace.txt
+      - name: Write in subproject
+        working-directory: web
+        run: pwd > cwd.txt
+`
+
+func worktreeRepo(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "web"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	files := map[string]string{
+		".github/workflows/ci.yml": worktreeWorkflow,
+		"web/.keep":                "",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(contents), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestRunCommandWorktreeLeavesTreeUntouched(t *testing.T) {
+	dir := worktreeRepo(t)
+	chdir(t, dir)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--worktree", "--format", "json"})
+	errBuf := &bytes.Buffer{}
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(errBuf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v\n%s", err, errBuf.String())
+	}
+
+	if !strings.Contains(errBuf.String(), "info: running in worktree ") {
+		t.Fatalf("expected worktree path to be printed, got %q", errBuf.String())
+	}
+	for _, name := range []string{"artifact.txt", "workspace.txt", "web/cwd.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to stay out of the original tree, got %v", name, err)
+		}
+	}
+	status, err := exec.Command("git", "-C", dir, "status", "--porcelain").Output()
+	if err != nil || len(status) != 0 {
+		t.Fatalf("expected clean working tree, got %q (%v)", status, err)
+	}
+	worktrees, err := exec.Command("git", "-C", dir, "worktree", "list").Output()
+	if err != nil || strings.Count(strings.TrimSpace(string(worktrees)), "\n") != 0 {
+		t.Fatalf("expected worktree to be removed, got %q (%v)", worktrees, err)
+	}
+}
+
+func TestRunCommandKeepWorktree(t *testing.T) {
+	dir := worktreeRepo(t)
+	chdir(t, dir)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--worktree", "--keep-worktree", "--format", "json"})
+	errBuf := &bytes.Buffer{}
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(errBuf)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("command execute: %v\n%s", err, errBuf.String())
+	}
+
+	var kept string
+	for _, line := range strings.Split(errBuf.String(), "\n") {
+		if path, ok := strings.CutPrefix(line, "info: kept worktree "); ok {
+			kept = path
+		}
+	}
+	if kept == "" {
+		t.Fatalf("expected kept worktree path, got %q", errBuf.String())
+	}
+	t.Cleanup(func() {
+		exec.Command("git", "-C", dir, "worktree", "remove", "--force", kept).Run()
+		os.RemoveAll(filepath.Dir(kept))
+	})
+
+	artifact, err := os.ReadFile(filepath.Join(kept, "artifact.txt"))
+	if err != nil || strings.TrimSpace(string(artifact)) != "generated" {
+		t.Fatalf("expected artifact in kept worktree, got %q (%v)", artifact, err)
+	}
+	workspace, err := os.ReadFile(filepath.Join(kept, "workspace.txt"))
+	if err != nil || strings.TrimSpace(string(workspace)) != kept {
+		t.Fatalf("expected GITHUB_WORKSPACE=%s, got %q (%v)", kept, workspace, err)
+	}
+	cwd, err := os.ReadFile(filepath.Join(kept, "web", "cwd.txt"))
+	if err != nil || strings.TrimSpace(string(cwd)) != filepath.Join(kept, "web") {
+		t.Fatalf("expected working-directory inside worktree, got %q (%v)", cwd, err)
+	}
+}
+
+func TestRunCommandKeepWorktreeRequiresWorktree(t *testing.T) {
+	root := projectRoot(t)
+	chdir(t, root)
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"run", "--workflow", "testdata/workflows/ci_basic.yml", "--dry-run", "--keep-worktree"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--keep-worktree requires --worktree") {
+		t.Fatalf("expected flag validation error, got %v", err)
+	}
+}
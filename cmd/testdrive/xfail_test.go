@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const xfailWorkflow = `name: CI
+jobs:
+  test:
+    steps:
+      - name: Known broken
+        run: exit 1
+      - name: Fixed upstream
+        run: |
+          # testdrive: xfail
+          echo fixed
+      - name: Build
+        run: echo build
+`
+
+func TestRunCommandExpectedFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ci.yml"), []byte(xfailWorkflow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".testdrive.yml"), []byte("expected_failures: [Known broken]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, root)
+
+	t.Run("pretty", func(t *testing.T) {
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"run", "--workflow", "ci.yml", "--no-lock", "--no-streaming"})
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("expected the expected failure not to fail the run: %v\n%s", err, out.String())
+		}
+		for _, want := range []string{
+			"Known broken",
+			"(expected failure)",
+			"(unexpectedly passed)",
+			"1 passed, 0 failed, 0 skipped, 1 xfail, 1 xpass",
+			`step "Fixed upstream" passed but is marked as an expected failure`,
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Fatalf("expected %q in output:\n%s", want, out.String())
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"run", "--workflow", "ci.yml", "--no-lock", "--format", "json"})
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("run: %v\n%s", err, out.String())
+		}
+		var got struct {
+			Steps []struct {
+				StepName        string `json:"step_name"`
+				Status          string `json:"status"`
+				ExpectedFailure bool   `json:"expected_failure"`
+			} `json:"steps"`
+			Summary struct {
+				Passed   int `json:"passed"`
+				Failed   int `json:"failed"`
+				XFailed  int `json:"xfailed"`
+				XPassed  int `json:"xpassed"`
+				ExitCode int `json:"exit_code"`
+			} `json:"summary"`
+			Warnings []string `json:"warnings"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+			t.Fatalf("decode report: %v\n%s", err, out.String())
+		}
+		if len(got.Steps) != 3 || got.Steps[0].Status != "xfail" || got.Steps[1].Status != "xpass" || got.Steps[2].Status != "passed" {
+			t.Fatalf("unexpected results: %+v", got.Steps)
+		}
+		if !got.Steps[0].ExpectedFailure || !got.Steps[1].ExpectedFailure || got.Steps[2].ExpectedFailure {
+			t.Fatalf("expected the marked steps to be flagged: %+v", got.Steps)
+		}
+		if s := got.Summary; s.Passed != 1 || s.Failed != 0 || s.XFailed != 1 || s.XPassed != 1 || s.ExitCode != 0 {
+			t.Fatalf("unexpected summary: %+v", s)
+		}
+		if len(got.Warnings) != 1 || !strings.Contains(got.Warnings[0], "Fixed upstream") {
+			t.Fatalf("expected an unexpected pass warning, got %v", got.Warnings)
+		}
+	})
+
+	t.Run("suppressed", func(t *testing.T) {
+		t.Setenv("TESTDRIVE_SUPPRESS_WARNINGS", "unexpected_pass")
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"run", "--workflow", "ci.yml", "--no-lock", "--no-streaming"})
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("run: %v\n%s", err, out.String())
+		}
+		if strings.Contains(out.String(), "marked as an expected failure") {
+			t.Fatalf("expected the warning to be suppressed:\n%s", out.String())
+		}
+	})
+}
@@ -0,0 +1,178 @@
+// Package baseline records a normalized description of what a pipeline runs
+// so later changes to the workflows can be reported as drift.
+package baseline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// Path is where the baseline is kept, relative to the project root.
+const Path = ".testdrive/baseline.json"
+
+// Version is the snapshot format written by Build.
+const Version = 1
+
+// ErrNoBaseline is returned by Load when no baseline has been written yet.
+var ErrNoBaseline = errors.New("no baseline found")
+
+// Snapshot describes the jobs and steps of a pipeline without anything that
+// changes between runs or machines.
+type Snapshot struct {
+	Version   int        `json:"version"`
+	Workflows []Workflow `json:"workflows"`
+}
+
+// Workflow is one workflow file in a Snapshot.
+type Workflow struct {
+	Path    string   `json:"path"`
+	Name    string   `json:"name"`
+	EnvKeys []string `json:"env_keys,omitempty"`
+	Jobs    []Job    `json:"jobs"`
+}
+
+// Job is one job, or one matrix variant of a job, in a Snapshot.
+type Job struct {
+	ID      string   `json:"id"`
+	Variant string   `json:"variant,omitempty"`
+	Name    string   `json:"name"`
+	EnvKeys []string `json:"env_keys,omitempty"`
+	Steps   []Step   `json:"steps"`
+}
+
+// Step is one step in a Snapshot. Run holds the normalized script.
+type Step struct {
+	Name             string   `json:"name"`
+	Uses             string   `json:"uses,omitempty"`
+	Run              string   `json:"run,omitempty"`
+	Shell            string   `json:"shell,omitempty"`
+	WorkingDirectory string   `json:"working_directory,omitempty"`
+	EnvKeys          []string `json:"env_keys,omitempty"`
+}
+
+// label names the job as diffs report it.
+func (j Job) label() string {
+	if j.Variant == "" {
+		return j.ID
+	}
+	return fmt.Sprintf("%s (%s)", j.ID, j.Variant)
+}
+
+// Build normalizes workflows into a Snapshot. Workflows are sorted by path
+// and jobs by ID and variant; steps keep their declared order, which is part
+// of what a workflow does. Only env keys are kept, since values tend to be
+// secrets or differ between machines.
+func Build(workflows []provider.Workflow) Snapshot {
+	snap := Snapshot{Version: Version, Workflows: make([]Workflow, 0, len(workflows))}
+	for _, wf := range workflows {
+		out := Workflow{
+			Path:    filepath.ToSlash(wf.Path),
+			Name:    wf.Name,
+			EnvKeys: envKeys(wf.Env),
+			Jobs:    make([]Job, 0, len(wf.Jobs)),
+		}
+		for _, job := range wf.Jobs {
+			j := Job{
+				ID:      job.RawID,
+				Variant: job.Variant,
+				Name:    job.Name,
+				EnvKeys: envKeys(job.Env),
+				Steps:   make([]Step, 0, len(job.Steps)),
+			}
+			for _, step := range job.Steps {
+				j.Steps = append(j.Steps, Step{
+					Name:             step.Name,
+					Uses:             strings.TrimSpace(step.Uses),
+					Run:              NormalizeScript(step.Run),
+					Shell:            strings.TrimSpace(step.Shell),
+					WorkingDirectory: strings.TrimSpace(step.WorkingDirectory),
+					EnvKeys:          envKeys(step.Env),
+				})
+			}
+			out.Jobs = append(out.Jobs, j)
+		}
+		sort.SliceStable(out.Jobs, func(a, b int) bool {
+			if out.Jobs[a].ID != out.Jobs[b].ID {
+				return out.Jobs[a].ID < out.Jobs[b].ID
+			}
+			return out.Jobs[a].Variant < out.Jobs[b].Variant
+		})
+		snap.Workflows = append(snap.Workflows, out)
+	}
+	sort.SliceStable(snap.Workflows, func(a, b int) bool {
+		return snap.Workflows[a].Path < snap.Workflows[b].Path
+	})
+	return snap
+}
+
+// NormalizeScript drops whitespace that does not change what a script does:
+// carriage returns, trailing spaces on each line, and blank lines at either
+// end, such as the final newline a `run: |` block keeps and `run: |-` drops.
+func NormalizeScript(script string) string {
+	lines := strings.Split(strings.ReplaceAll(script, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func envKeys(env map[string]string) []string {
+	if len(env) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Write stores snap under root, creating its directory as needed.
+func Write(root string, snap Snapshot) error {
+	path := filepath.Join(root, filepath.FromSlash(Path))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create baseline dir: %w", err)
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode baseline: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write baseline: %w", err)
+	}
+	return nil
+}
+
+// Load reads the baseline stored under root. It returns ErrNoBaseline when
+// there is none.
+func Load(root string) (Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(Path)))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Snapshot{}, ErrNoBaseline
+		}
+		return Snapshot{}, fmt.Errorf("read baseline: %w", err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return Snapshot{}, fmt.Errorf("decode baseline %s: %w", Path, err)
+	}
+	if snap.Version != Version {
+		return Snapshot{}, fmt.Errorf("baseline %s has version %d, expected %d; write it again with testdrive snapshot", Path, snap.Version, Version)
+	}
+	return snap, nil
+}
@@ -0,0 +1,144 @@
+package baseline
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestNormalizeScript(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{name: "block keep", script: "make\nmake test\n", want: "make\nmake test"},
+		{name: "block strip", script: "make\nmake test", want: "make\nmake test"},
+		{name: "crlf", script: "make\r\nmake test\r\n", want: "make\nmake test"},
+		{name: "trailing spaces", script: "make  \nmake test\t\n", want: "make\nmake test"},
+		{name: "blank ends", script: "\n\nmake\n\n", want: "make"},
+		// Indentation and blank lines inside the script can matter, as in
+		// heredocs, so they are kept.
+		{name: "inner layout", script: "cat <<EOF\n  a\n\n  b\nEOF\n", want: "cat <<EOF\n  a\n\n  b\nEOF"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NormalizeScript(tc.script); got != tc.want {
+				t.Fatalf("NormalizeScript(%q) = %q, want %q", tc.script, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildOrdersAndDropsValues(t *testing.T) {
+	workflows := []provider.Workflow{
+		{Path: "b.yml", Name: "B", Jobs: []provider.Job{{RawID: "only", Name: "only"}}},
+		{Path: "a.yml", Name: "A", Env: map[string]string{"TOKEN": "secret", "CI": "1"}, Jobs: []provider.Job{
+			{RawID: "test", Name: "Test", Steps: []provider.Step{
+				{Name: "Specs", Run: "bin/rspec\n", Env: map[string]string{"RAILS_ENV": "test"}},
+				{Name: "Lint", Run: "bin/lint"},
+			}},
+			{RawID: "build", Name: "build"},
+		}},
+	}
+	snap := Build(workflows)
+
+	if snap.Workflows[0].Path != "a.yml" || snap.Workflows[1].Path != "b.yml" {
+		t.Fatalf("expected workflows sorted by path, got %+v", snap.Workflows)
+	}
+	a := snap.Workflows[0]
+	if !reflect.DeepEqual(a.EnvKeys, []string{"CI", "TOKEN"}) {
+		t.Fatalf("expected sorted env keys only, got %v", a.EnvKeys)
+	}
+	if a.Jobs[0].ID != "build" || a.Jobs[1].ID != "test" {
+		t.Fatalf("expected jobs sorted by id, got %+v", a.Jobs)
+	}
+	steps := a.Jobs[1].Steps
+	if steps[0].Name != "Specs" || steps[0].Run != "bin/rspec" || !reflect.DeepEqual(steps[0].EnvKeys, []string{"RAILS_ENV"}) || steps[1].Name != "Lint" {
+		t.Fatalf("expected steps in declared order with normalized scripts, got %+v", steps)
+	}
+
+	// Reordered input and whitespace-only edits make no difference.
+	workflows[0], workflows[1] = workflows[1], workflows[0]
+	workflows[0].Jobs[0].Steps[0].Run = "bin/rspec   \r\n"
+	if changes := Diff(snap, Build(workflows)); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	base := Snapshot{Version: Version, Workflows: []Workflow{
+		{Path: "ci.yml", Name: "CI", Jobs: []Job{
+			{ID: "lint", Name: "lint", Steps: []Step{{Name: "Lint", Run: "bin/lint"}}},
+			{ID: "test", Name: "test", Steps: []Step{
+				{Name: "Checkout", Uses: "actions/checkout@v4"},
+				{Name: "Specs", Run: "bin/rspec"},
+				{Name: "Setup", Run: "bin/setup"},
+				{Name: "Build", Run: "make\nmake test", Shell: "bash"},
+			}},
+		}},
+		{Path: "old.yml", Name: "Old"},
+	}}
+	current := Snapshot{Version: Version, Workflows: []Workflow{
+		{Path: "ci.yml", Name: "CI", Jobs: []Job{
+			{ID: "deploy", Name: "deploy"},
+			{ID: "test", Name: "Tests", EnvKeys: []string{"CI"}, Steps: []Step{
+				{Name: "Checkout", Uses: "actions/checkout@v4"},
+				{Name: "System specs", Run: "bin/rspec"},
+				{Name: "Build", Run: "make\nmake check", Shell: "sh"},
+				{Name: "Audit", Run: "bin/audit"},
+			}},
+		}},
+		{Path: "new.yml", Name: "New"},
+	}}
+
+	want := []Change{
+		{Kind: ChangeAdded, Workflow: "ci.yml", Job: "deploy"},
+		{Kind: ChangeChanged, Workflow: "ci.yml", Job: "test", Field: "name", Old: "test", New: "Tests"},
+		{Kind: ChangeChanged, Workflow: "ci.yml", Job: "test", Field: "env_keys", New: "CI"},
+		{Kind: ChangeRenamed, Workflow: "ci.yml", Job: "test", Step: "System specs", Field: "name", Old: "Specs", New: "System specs"},
+		{Kind: ChangeRemoved, Workflow: "ci.yml", Job: "test", Step: "Setup"},
+		{Kind: ChangeChanged, Workflow: "ci.yml", Job: "test", Step: "Build", Field: "run", Diff: "--- baseline\n+++ current\n@@ -1,2 +1,2 @@\n make\n-make test\n+make check\n"},
+		{Kind: ChangeChanged, Workflow: "ci.yml", Job: "test", Step: "Build", Field: "shell", Old: "bash", New: "sh"},
+		{Kind: ChangeAdded, Workflow: "ci.yml", Job: "test", Step: "Audit"},
+		{Kind: ChangeRemoved, Workflow: "ci.yml", Job: "lint"},
+		{Kind: ChangeAdded, Workflow: "new.yml"},
+		{Kind: ChangeRemoved, Workflow: "old.yml"},
+	}
+	if got := Diff(base, current); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff mismatch:\n got %+v\nwant %+v", got, want)
+	}
+	if got := Diff(base, base); len(got) != 0 {
+		t.Fatalf("expected no changes against itself, got %+v", got)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	got := UnifiedDiff("a\nb\nc", "a\nc\nd")
+	want := "--- baseline\n+++ current\n@@ -1,3 +1,3 @@\n a\n-b\n c\n+d\n"
+	if got != want {
+		t.Fatalf("UnifiedDiff = %q, want %q", got, want)
+	}
+	if got := UnifiedDiff("", "make"); got != "--- baseline\n+++ current\n@@ -0,0 +1,1 @@\n+make\n" {
+		t.Fatalf("unexpected diff from an empty script: %q", got)
+	}
+}
+
+func TestWriteLoad(t *testing.T) {
+	root := t.TempDir()
+	if _, err := Load(root); !errors.Is(err, ErrNoBaseline) {
+		t.Fatalf("expected ErrNoBaseline, got %v", err)
+	}
+	snap := Build([]provider.Workflow{{Path: "ci.yml", Name: "CI", Jobs: []provider.Job{{RawID: "test", Name: "test", Steps: []provider.Step{{Name: "Specs", Run: "bin/rspec"}}}}}})
+	if err := Write(root, snap); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	got, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(got, snap) {
+		t.Fatalf("round trip mismatch: %+v vs %+v", got, snap)
+	}
+}
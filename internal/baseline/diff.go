@@ -0,0 +1,228 @@
+package baseline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Change kinds.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+	// ChangeRenamed is a step whose name changed while its command did not.
+	ChangeRenamed = "renamed"
+)
+
+// Change is one difference between a baseline and the current pipeline.
+// Job and Step are empty for changes to a whole workflow or job.
+type Change struct {
+	Kind     string `json:"kind"`
+	Workflow string `json:"workflow"`
+	Job      string `json:"job,omitempty"`
+	Step     string `json:"step,omitempty"`
+	// Field names what changed: name, run, uses, shell, working_directory,
+	// or env_keys.
+	Field string `json:"field,omitempty"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+	// Diff is a unified diff of a changed run script.
+	Diff string `json:"diff,omitempty"`
+}
+
+// Diff reports how current differs from base, in the order of current with
+// removals next to where they were. Workflows are matched by path and jobs
+// by ID and variant. Steps are matched by name, then a step left over on
+// both sides with the same command counts as renamed.
+func Diff(base, current Snapshot) []Change {
+	changes := []Change{}
+	baseWorkflows := make(map[string]Workflow, len(base.Workflows))
+	for _, wf := range base.Workflows {
+		baseWorkflows[wf.Path] = wf
+	}
+	seen := make(map[string]bool, len(current.Workflows))
+	for _, wf := range current.Workflows {
+		seen[wf.Path] = true
+		old, ok := baseWorkflows[wf.Path]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, Workflow: wf.Path})
+			continue
+		}
+		changes = append(changes, diffWorkflow(old, wf)...)
+	}
+	for _, wf := range base.Workflows {
+		if !seen[wf.Path] {
+			changes = append(changes, Change{Kind: ChangeRemoved, Workflow: wf.Path})
+		}
+	}
+	return changes
+}
+
+func diffWorkflow(base, current Workflow) []Change {
+	var changes []Change
+	field := func(name, was, now string) {
+		if was != now {
+			changes = append(changes, Change{Kind: ChangeChanged, Workflow: current.Path, Field: name, Old: was, New: now})
+		}
+	}
+	field("name", base.Name, current.Name)
+	field("env_keys", strings.Join(base.EnvKeys, ", "), strings.Join(current.EnvKeys, ", "))
+
+	baseJobs := make(map[string]Job, len(base.Jobs))
+	for _, job := range base.Jobs {
+		baseJobs[job.label()] = job
+	}
+	seen := make(map[string]bool, len(current.Jobs))
+	for _, job := range current.Jobs {
+		seen[job.label()] = true
+		old, ok := baseJobs[job.label()]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, Workflow: current.Path, Job: job.label()})
+			continue
+		}
+		changes = append(changes, diffJob(current.Path, old, job)...)
+	}
+	for _, job := range base.Jobs {
+		if !seen[job.label()] {
+			changes = append(changes, Change{Kind: ChangeRemoved, Workflow: current.Path, Job: job.label()})
+		}
+	}
+	return changes
+}
+
+func diffJob(workflow string, base, current Job) []Change {
+	var changes []Change
+	jobLabel := current.label()
+	if base.Name != current.Name {
+		changes = append(changes, Change{Kind: ChangeChanged, Workflow: workflow, Job: jobLabel, Field: "name", Old: base.Name, New: current.Name})
+	}
+	if was, now := strings.Join(base.EnvKeys, ", "), strings.Join(current.EnvKeys, ", "); was != now {
+		changes = append(changes, Change{Kind: ChangeChanged, Workflow: workflow, Job: jobLabel, Field: "env_keys", Old: was, New: now})
+	}
+
+	// Pair steps by name, then pair what is left by command.
+	match := make([]int, len(current.Steps))
+	used := make([]bool, len(base.Steps))
+	byName := make(map[string]int, len(base.Steps))
+	for i := len(base.Steps) - 1; i >= 0; i-- {
+		byName[base.Steps[i].Name] = i
+	}
+	for i, step := range current.Steps {
+		match[i] = -1
+		if j, ok := byName[step.Name]; ok && !used[j] {
+			match[i], used[j] = j, true
+		}
+	}
+	for i, step := range current.Steps {
+		if match[i] >= 0 || step.Run == "" && step.Uses == "" {
+			continue
+		}
+		for j, old := range base.Steps {
+			if !used[j] && old.Run == step.Run && old.Uses == step.Uses {
+				match[i], used[j] = j, true
+				break
+			}
+		}
+	}
+
+	next := 0
+	removed := func(upTo int) {
+		for ; next < upTo; next++ {
+			if !used[next] {
+				changes = append(changes, Change{Kind: ChangeRemoved, Workflow: workflow, Job: jobLabel, Step: base.Steps[next].Name})
+			}
+		}
+	}
+	for i, step := range current.Steps {
+		j := match[i]
+		if j < 0 {
+			changes = append(changes, Change{Kind: ChangeAdded, Workflow: workflow, Job: jobLabel, Step: step.Name})
+			continue
+		}
+		removed(j)
+		old := base.Steps[j]
+		if old.Name != step.Name {
+			changes = append(changes, Change{Kind: ChangeRenamed, Workflow: workflow, Job: jobLabel, Step: step.Name, Field: "name", Old: old.Name, New: step.Name})
+		}
+		changes = append(changes, diffStep(workflow, jobLabel, old, step)...)
+	}
+	removed(len(base.Steps))
+	return changes
+}
+
+func diffStep(workflow, job string, base, current Step) []Change {
+	var changes []Change
+	field := func(name, was, now string) {
+		if was == now {
+			return
+		}
+		change := Change{Kind: ChangeChanged, Workflow: workflow, Job: job, Step: current.Name, Field: name, Old: was, New: now}
+		if name == "run" {
+			change.Old, change.New = "", ""
+			change.Diff = UnifiedDiff(was, now)
+		}
+		changes = append(changes, change)
+	}
+	field("run", base.Run, current.Run)
+	field("uses", base.Uses, current.Uses)
+	field("shell", base.Shell, current.Shell)
+	field("working_directory", base.WorkingDirectory, current.WorkingDirectory)
+	field("env_keys", strings.Join(base.EnvKeys, ", "), strings.Join(current.EnvKeys, ", "))
+	return changes
+}
+
+// UnifiedDiff returns the lines of was and now as one unified diff hunk,
+// with every line of both scripts shown: run scripts are short enough that
+// context is more useful than brevity.
+func UnifiedDiff(was, now string) string {
+	a, b := splitLines(was), splitLines(now)
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- baseline\n+++ current\n@@ -%s +%s @@\n", hunkRange(len(a)), hunkRange(len(b)))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			sb.WriteString(" " + a[i] + "\n")
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("-" + a[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+" + b[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// hunkRange formats a hunk header range covering n lines from the start.
+func hunkRange(n int) string {
+	if n == 0 {
+		return "0,0"
+	}
+	return fmt.Sprintf("1,%d", n)
+}
@@ -0,0 +1,192 @@
+// Package cleanup finds and removes what testdrive leaves behind: state under
+// .testdrive in a project, and temp files and worktrees in the system temp
+// directory that a killed run never got to remove.
+package cleanup
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/history"
+)
+
+// StateDir holds testdrive's per-project state, relative to the project root.
+const StateDir = ".testdrive"
+
+// TempPrefix starts the name of everything testdrive creates in the system
+// temp directory.
+const TempPrefix = "testdrive-"
+
+// MinTempAge is how old a temp artifact must be before Find offers it. A
+// younger one may belong to a run still in progress, in this project or
+// another.
+const MinTempAge = time.Hour
+
+// Kinds of artifact.
+const (
+	// KindTemp is a run's temp directory, script, or step summary file.
+	KindTemp = "temp"
+	// KindWorktree is a --worktree sandbox.
+	KindWorktree = "worktree"
+	// KindHistory is the run history under .testdrive/history.
+	KindHistory = "history"
+	// KindState is anything else under .testdrive, such as the baseline.
+	KindState = "state"
+)
+
+// lockName is the run lock in StateDir. It is never removed: a run may hold
+// it, and removing a held lock lets a second run take another.
+const lockName = "lock"
+
+// Artifact is one file or directory to remove.
+type Artifact struct {
+	Path string
+	Kind string
+	// Bytes is the total size of the files under Path.
+	Bytes int64
+}
+
+// Options selects what Find looks for.
+type Options struct {
+	// Root is the project whose StateDir is searched.
+	Root string
+	// TempDir is the system temp directory; empty means os.TempDir().
+	TempDir string
+	// Temp selects stale temp files and worktrees, History the run
+	// history, and State everything under StateDir but the lock and the
+	// history's ignore file.
+	Temp, History, State bool
+	// Now is compared with temp artifacts' modification times.
+	Now time.Time
+}
+
+func (o Options) tempDir() string {
+	if o.TempDir == "" {
+		return os.TempDir()
+	}
+	return o.TempDir
+}
+
+// Find returns the artifacts opts selects, state first and then temp
+// artifacts, each sorted by path.
+func Find(opts Options) ([]Artifact, error) {
+	var found []Artifact
+	if opts.History || opts.State {
+		state := filepath.Join(opts.Root, StateDir)
+		entries, err := os.ReadDir(state)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read %s: %w", StateDir, err)
+		}
+		for _, entry := range entries {
+			kind := KindState
+			switch entry.Name() {
+			case lockName, history.IgnoreFile:
+				continue
+			case filepath.Base(history.Dir):
+				kind = KindHistory
+			}
+			if kind == KindState && !opts.State || kind == KindHistory && !opts.History {
+				continue
+			}
+			path := filepath.Join(state, entry.Name())
+			found = append(found, Artifact{Path: path, Kind: kind, Bytes: size(path)})
+		}
+	}
+	if opts.Temp {
+		entries, err := os.ReadDir(opts.tempDir())
+		if err != nil {
+			return nil, fmt.Errorf("read temp directory: %w", err)
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), TempPrefix) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || opts.Now.Sub(info.ModTime()) < MinTempAge {
+				continue
+			}
+			kind := KindTemp
+			if strings.HasPrefix(entry.Name(), TempPrefix+"worktree-") {
+				kind = KindWorktree
+			}
+			path := filepath.Join(opts.tempDir(), entry.Name())
+			found = append(found, Artifact{Path: path, Kind: kind, Bytes: size(path)})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if ti, tj := isTemp(found[i].Kind), isTemp(found[j].Kind); ti != tj {
+			return tj
+		}
+		return found[i].Path < found[j].Path
+	})
+	return found, nil
+}
+
+func isTemp(kind string) bool {
+	return kind == KindTemp || kind == KindWorktree
+}
+
+// Remove deletes each artifact after checking with Check that it is safe
+// to. It stops at the first artifact that is not.
+func Remove(opts Options, artifacts []Artifact) error {
+	for _, a := range artifacts {
+		if err := Check(opts, a.Path); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(a.Path); err != nil {
+			return fmt.Errorf("remove %s: %w", a.Path, err)
+		}
+	}
+	return nil
+}
+
+// Check refuses a path that is neither inside the project's StateDir nor a
+// TempPrefix entry directly in the temp directory. Links in the path's
+// parent directories are resolved first, and a StateDir that is itself a
+// link is refused, so nothing is removed through a link to elsewhere. A
+// link in the final element is removed as a link.
+func Check(opts Options, path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("refusing to remove %s: %w", path, err)
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return fmt.Errorf("refusing to remove %s: %w", path, err)
+	}
+	name := filepath.Base(abs)
+	if root, err := filepath.EvalSymlinks(opts.Root); err == nil {
+		state := filepath.Join(root, StateDir)
+		if info, err := os.Lstat(state); err == nil && info.IsDir() {
+			if rel, err := filepath.Rel(state, filepath.Join(parent, name)); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+				return nil
+			}
+		}
+	}
+	if temp, err := filepath.EvalSymlinks(opts.tempDir()); err == nil && parent == temp && strings.HasPrefix(name, TempPrefix) {
+		return nil
+	}
+	return fmt.Errorf("refusing to remove %s: it is neither under %s nor a %s* temp path", path, StateDir, TempPrefix)
+}
+
+// size totals the regular files under path without following links.
+func size(path string) int64 {
+	var total int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,156 @@
+package cleanup
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// layout creates a project with history, a baseline, and a lock, and a temp
+// directory with stale and fresh testdrive entries beside an unrelated one.
+func layout(t *testing.T) Options {
+	t.Helper()
+	root, temp := t.TempDir(), t.TempDir()
+	now := time.Now()
+	write := func(path string, age time.Duration) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(root, StateDir, "history", "runs.jsonl"), 0)
+	write(filepath.Join(root, StateDir, "baseline.json"), 0)
+	write(filepath.Join(root, StateDir, "lock"), 0)
+	write(filepath.Join(temp, "testdrive-script-1.sh"), 2*time.Hour)
+	write(filepath.Join(temp, "testdrive-step-summary-2.md"), time.Minute)
+	write(filepath.Join(temp, "other-tool-3"), 2*time.Hour)
+	wt := filepath.Join(temp, "testdrive-worktree-4")
+	write(filepath.Join(wt, "tree", "main.go"), 2*time.Hour)
+	if err := os.Chtimes(wt, now.Add(-2*time.Hour), now.Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	return Options{Root: root, TempDir: temp, Now: now}
+}
+
+func names(artifacts []Artifact) string {
+	var out []string
+	for _, a := range artifacts {
+		out = append(out, a.Kind+":"+filepath.Base(a.Path))
+	}
+	return strings.Join(out, " ")
+}
+
+func TestFind(t *testing.T) {
+	cases := []struct {
+		name                 string
+		temp, history, state bool
+		want                 string
+	}{
+		{"temp", true, false, false, "temp:testdrive-script-1.sh worktree:testdrive-worktree-4"},
+		{"history", false, true, false, "history:history"},
+		{"all", true, true, true, "state:baseline.json history:history temp:testdrive-script-1.sh worktree:testdrive-worktree-4"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := layout(t)
+			opts.Temp, opts.History, opts.State = tc.temp, tc.history, tc.state
+			found, err := Find(opts)
+			if err != nil {
+				t.Fatalf("Find: %v", err)
+			}
+			if got := names(found); got != tc.want {
+				t.Fatalf("Find = %s, want %s", got, tc.want)
+			}
+			for _, a := range found {
+				if a.Bytes != 4 {
+					t.Fatalf("%s: Bytes = %d, want 4", a.Path, a.Bytes)
+				}
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	opts := layout(t)
+	opts.Temp, opts.History = true, true
+	found, err := Find(opts)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if err := Remove(opts, found); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	for _, a := range found {
+		if _, err := os.Lstat(a.Path); !os.IsNotExist(err) {
+			t.Fatalf("%s still exists: %v", a.Path, err)
+		}
+	}
+	for _, kept := range []string{
+		filepath.Join(opts.Root, StateDir, "baseline.json"),
+		filepath.Join(opts.Root, StateDir, "lock"),
+		filepath.Join(opts.TempDir, "testdrive-step-summary-2.md"),
+		filepath.Join(opts.TempDir, "other-tool-3"),
+	} {
+		if _, err := os.Stat(kept); err != nil {
+			t.Fatalf("%s was removed: %v", kept, err)
+		}
+	}
+}
+
+func TestCheckRefuses(t *testing.T) {
+	opts := layout(t)
+	for _, path := range []string{
+		opts.Root,
+		filepath.Join(opts.Root, StateDir),
+		filepath.Join(opts.Root, "main.go"),
+		filepath.Join(opts.Root, StateDir, "..", "main.go"),
+		filepath.Join(opts.TempDir, "other-tool-3"),
+		filepath.Join(opts.TempDir, "testdrive-worktree-4", "tree"),
+	} {
+		if err := Check(opts, path); err == nil || !strings.Contains(err.Error(), "refusing to remove") {
+			t.Fatalf("Check(%s) = %v, want a refusal", path, err)
+		}
+	}
+	if err := Check(opts, filepath.Join(opts.Root, StateDir, "history")); err != nil {
+		t.Fatalf("Check(history) = %v", err)
+	}
+}
+
+func TestCheckRefusesLinkedStateDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+	opts := layout(t)
+	elsewhere := t.TempDir()
+	if err := os.WriteFile(filepath.Join(elsewhere, "precious"), []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state := filepath.Join(opts.Root, StateDir)
+	if err := os.RemoveAll(state); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(elsewhere, state); err != nil {
+		t.Fatal(err)
+	}
+	opts.State = true
+
+	found, err := Find(opts)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if err := Remove(opts, found); err == nil || !strings.Contains(err.Error(), "refusing to remove") {
+		t.Fatalf("Remove = %v, want a refusal", err)
+	}
+	if _, err := os.Stat(filepath.Join(elsewhere, "precious")); err != nil {
+		t.Fatalf("file behind the link was removed: %v", err)
+	}
+}
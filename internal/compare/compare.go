@@ -0,0 +1,301 @@
+// Package compare lines up local step results with the outcome of a real
+// GitHub Actions run and reports where they disagree.
+package compare
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// Divergence kinds.
+const (
+	// KindPassedLocally is a step that passed locally but failed on CI.
+	KindPassedLocally = "passed-locally-failed-on-ci"
+	// KindFailedLocally is a step that failed locally but passed on CI.
+	KindFailedLocally = "failed-locally-passed-on-ci"
+	// KindSkippedLocally is a step that ran on CI but was skipped locally.
+	KindSkippedLocally = "skipped-locally"
+	// KindNotOnCI is a step that ran locally with no counterpart in the run.
+	KindNotOnCI = "not-on-ci"
+)
+
+// Divergence is a single step whose local and CI outcomes disagree.
+type Divergence struct {
+	Workflow string `json:"workflow"`
+	// Job is the CI job name, which includes the matrix combination for
+	// matrix jobs, or the local job name when no CI job matched.
+	Job  string `json:"job"`
+	Step string `json:"step"`
+	// RemoteStep is the CI step name when it was matched under another name.
+	RemoteStep string `json:"remote_step,omitempty"`
+	Kind       string `json:"kind"`
+	Local      string `json:"local"`
+	CI         string `json:"ci"`
+}
+
+// Result is the outcome of comparing a local run with a CI run.
+type Result struct {
+	// Compared counts local steps that were matched to a CI step.
+	Compared    int          `json:"compared"`
+	Divergences []Divergence `json:"divergences"`
+}
+
+// Steps compares local results with the jobs of a CI run. Local jobs are
+// matched to CI jobs by workflow and job name, so one local job can match
+// every leg of a CI matrix. Steps are matched by name, then by the "Run
+// <command>" name GitHub gives unnamed steps, then by word overlap to
+// tolerate small renames. Local workflows absent from the run are ignored.
+func Steps(local []report.StepResult, remote []RemoteJob) Result {
+	result := Result{Divergences: []Divergence{}}
+	for _, group := range groupLocal(local) {
+		if !workflowInRun(group.workflow, remote) {
+			continue
+		}
+		matched := false
+		for _, rj := range remote {
+			if rj.WorkflowName != "" && normalize(rj.WorkflowName) != normalize(group.workflow) {
+				continue
+			}
+			if !jobMatches(group.job, rj.Name) {
+				continue
+			}
+			matched = true
+			compareJob(&result, group, rj)
+		}
+		if !matched {
+			for _, res := range group.steps {
+				if localOutcome(res) == outcomeSkipped {
+					continue
+				}
+				result.Divergences = append(result.Divergences, Divergence{
+					Workflow: group.workflow,
+					Job:      group.job,
+					Step:     res.StepName,
+					Kind:     KindNotOnCI,
+					Local:    localOutcome(res),
+					CI:       "-",
+				})
+			}
+		}
+	}
+	return result
+}
+
+type localJob struct {
+	workflow string
+	job      string
+	steps    []report.StepResult
+}
+
+func groupLocal(results []report.StepResult) []*localJob {
+	var groups []*localJob
+	index := make(map[[2]string]*localJob)
+	for _, res := range results {
+		k := [2]string{res.WorkflowName, res.JobName}
+		g, ok := index[k]
+		if !ok {
+			g = &localJob{workflow: res.WorkflowName, job: res.JobName}
+			index[k] = g
+			groups = append(groups, g)
+		}
+		g.steps = append(g.steps, res)
+	}
+	return groups
+}
+
+// workflowInRun reports whether the run belongs to the named workflow. Jobs
+// saved without a workflow_name are assumed to belong to any workflow.
+func workflowInRun(workflow string, remote []RemoteJob) bool {
+	for _, rj := range remote {
+		if rj.WorkflowName == "" || normalize(rj.WorkflowName) == normalize(workflow) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareJob(result *Result, group *localJob, rj RemoteJob) {
+	used := make([]bool, len(rj.Steps))
+	for i, step := range rj.Steps {
+		used[i] = syntheticStep(step.Name)
+	}
+	for _, res := range group.steps {
+		local := localOutcome(res)
+		i := matchStep(res, rj.Steps, used)
+		if i < 0 {
+			if local == outcomeSkipped {
+				continue
+			}
+			result.Divergences = append(result.Divergences, Divergence{
+				Workflow: group.workflow,
+				Job:      rj.Name,
+				Step:     res.StepName,
+				Kind:     KindNotOnCI,
+				Local:    local,
+				CI:       "-",
+			})
+			continue
+		}
+		used[i] = true
+		result.Compared++
+
+		remoteStep := rj.Steps[i]
+		ci := remoteOutcome(remoteStep.Conclusion)
+		var kind string
+		switch {
+		case local == outcomeSkipped && (ci == outcomePassed || ci == outcomeFailed):
+			kind = KindSkippedLocally
+		case local == outcomePassed && ci == outcomeFailed:
+			kind = KindPassedLocally
+		case local == outcomeFailed && ci == outcomePassed:
+			kind = KindFailedLocally
+		default:
+			continue
+		}
+		d := Divergence{
+			Workflow: group.workflow,
+			Job:      rj.Name,
+			Step:     res.StepName,
+			Kind:     kind,
+			Local:    local,
+			CI:       remoteStep.Conclusion,
+		}
+		if normalize(remoteStep.Name) != normalize(res.StepName) {
+			d.RemoteStep = remoteStep.Name
+		}
+		result.Divergences = append(result.Divergences, d)
+	}
+}
+
+const (
+	outcomePassed  = "passed"
+	outcomeFailed  = "failed"
+	outcomeSkipped = "skipped"
+)
+
+// localOutcome treats steps deduplicated against an earlier pass as passed,
+// and expected failures as what they did.
+func localOutcome(res report.StepResult) string {
+	switch {
+	case res.Status == outcomeSkipped && res.SkipReason == report.ReasonDuplicate:
+		return outcomePassed
+	case res.Status == "xfail":
+		return outcomeFailed
+	case res.Status == "xpass":
+		return outcomePassed
+	}
+	return res.Status
+}
+
+func remoteOutcome(conclusion string) string {
+	switch conclusion {
+	case "success":
+		return outcomePassed
+	case "failure", "timed_out", "cancelled":
+		return outcomeFailed
+	default:
+		return outcomeSkipped
+	}
+}
+
+// syntheticStep reports whether a CI step was added by the runner rather
+// than declared in the workflow.
+func syntheticStep(name string) bool {
+	n := normalize(name)
+	return n == "set up job" || n == "complete job" || strings.HasPrefix(n, "post ") || strings.HasPrefix(n, "pre ")
+}
+
+var (
+	unnamedStep = regexp.MustCompile(`^step \d+$`)
+	expression  = regexp.MustCompile(`\$\{\{.*?\}\}`)
+	wordSplit   = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// minOverlap is the word overlap needed to pair steps whose names differ.
+const minOverlap = 0.5
+
+// matchStep returns the index of the unused CI step that corresponds to res,
+// or -1.
+func matchStep(res report.StepResult, steps []RemoteStep, used []bool) int {
+	names := []string{normalize(res.StepName)}
+	if unnamedStep.MatchString(names[0]) || names[0] == "" {
+		names = []string{normalize("Run " + firstLine(res.StepRun))}
+	}
+	for _, name := range names {
+		for i, step := range steps {
+			if !used[i] && normalize(step.Name) == name {
+				return i
+			}
+		}
+	}
+
+	best, bestScore := -1, 0.0
+	for i, step := range steps {
+		if used[i] {
+			continue
+		}
+		if score := overlap(names[0], normalize(step.Name)); score >= minOverlap && score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// jobMatches reports whether a CI job name belongs to a local job. GitHub
+// appends the matrix values to the job name ("test (ubuntu-latest, 1.22)")
+// unless the name interpolates them itself ("test ${{ matrix.os }}").
+func jobMatches(local, remote string) bool {
+	local, remote = normalize(local), normalize(remote)
+	if local == remote || strings.HasPrefix(remote, local+" (") && strings.HasSuffix(remote, ")") {
+		return true
+	}
+	if !expression.MatchString(local) {
+		return false
+	}
+	parts := expression.Split(local, -1)
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	re, err := regexp.Compile("^" + strings.Join(parts, ".+?") + `(?: \(.*\))?$`)
+	return err == nil && re.MatchString(remote)
+}
+
+// overlap is the Jaccard similarity of the words in a and b.
+func overlap(a, b string) float64 {
+	wa, wb := words(a), words(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range wa {
+		if wb[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wa)+len(wb)-shared)
+}
+
+func words(s string) map[string]bool {
+	out := make(map[string]bool)
+	for _, w := range wordSplit.Split(s, -1) {
+		if w != "" {
+			out[w] = true
+		}
+	}
+	return out
+}
+
+func normalize(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package compare
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func loadFixture(t *testing.T) []RemoteJob {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "actions", "run_jobs.json"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	jobs, err := ParseJobs(data)
+	if err != nil {
+		t.Fatalf("ParseJobs: %v", err)
+	}
+	return jobs
+}
+
+func localResults() []report.StepResult {
+	step := func(job, name, run, status string) report.StepResult {
+		return report.StepResult{WorkflowName: "CI", JobName: job, StepName: name, StepRun: run, Status: status}
+	}
+	publish := step("integration", "Publish coverage report", "./publish.sh", "skipped")
+	publish.SkipReason = report.ReasonPrivileged
+	return []report.StepResult{
+		step("lint", "Lint", "make lint", "passed"),
+		step("Test ${{ matrix.os }}", "Unit tests", "go test ./...", "passed"),
+		step("Test ${{ matrix.os }}", "step 2", "echo build", "passed"),
+		step("integration", "Integration suite", "make integration", "failed"),
+		publish,
+		step("docs", "Build docs", "make docs", "passed"),
+	}
+}
+
+func TestSteps(t *testing.T) {
+	got := Steps(localResults(), loadFixture(t))
+
+	want := []Divergence{
+		{Workflow: "CI", Job: "lint", Step: "Lint", Kind: KindPassedLocally, Local: "passed", CI: "failure"},
+		{Workflow: "CI", Job: "Test macos-latest", Step: "Unit tests", RemoteStep: "Run unit tests", Kind: KindPassedLocally, Local: "passed", CI: "failure"},
+		{Workflow: "CI", Job: "integration", Step: "Integration suite", Kind: KindFailedLocally, Local: "failed", CI: "success"},
+		{Workflow: "CI", Job: "integration", Step: "Publish coverage report", Kind: KindSkippedLocally, Local: "skipped", CI: "success"},
+		{Workflow: "CI", Job: "docs", Step: "Build docs", Kind: KindNotOnCI, Local: "passed", CI: "-"},
+	}
+	if !reflect.DeepEqual(got.Divergences, want) {
+		t.Fatalf("Divergences =\n%+v\nwant\n%+v", got.Divergences, want)
+	}
+	if got.Compared != 7 {
+		t.Fatalf("Compared = %d, want 7", got.Compared)
+	}
+}
+
+func TestStepsIgnoresOtherWorkflows(t *testing.T) {
+	local := []report.StepResult{{WorkflowName: "Nightly", JobName: "lint", StepName: "Lint", Status: "passed"}}
+	got := Steps(local, loadFixture(t))
+	if got.Compared != 0 || len(got.Divergences) != 0 {
+		t.Fatalf("expected nothing compared, got %+v", got)
+	}
+}
+
+func TestStepsDuplicateCountsAsPassed(t *testing.T) {
+	local := []report.StepResult{{WorkflowName: "CI", JobName: "integration", StepName: "Integration suite", Status: "skipped", SkipReason: report.ReasonDuplicate}}
+	got := Steps(local, loadFixture(t))
+	if got.Compared != 1 || len(got.Divergences) != 0 {
+		t.Fatalf("expected a matching duplicate, got %+v", got)
+	}
+}
+
+func TestJobMatches(t *testing.T) {
+	cases := []struct {
+		local, remote string
+		want          bool
+	}{
+		{"test", "test", true},
+		{"test", "Test", true},
+		{"test", "test (ubuntu-latest, 1.22)", true},
+		{"test", "test-e2e", false},
+		{"Test ${{ matrix.os }}", "Test ubuntu-latest", true},
+		{"Test ${{ matrix.os }}", "Test ubuntu-latest (1.22)", true},
+		{"${{ matrix.os }} / build", "macos-latest / build", true},
+		{"${{ matrix.os }} / build", "macos-latest / lint", false},
+	}
+	for _, tc := range cases {
+		if got := jobMatches(tc.local, tc.remote); got != tc.want {
+			t.Errorf("jobMatches(%q, %q) = %v, want %v", tc.local, tc.remote, got, tc.want)
+		}
+	}
+}
+
+func TestMatchStep(t *testing.T) {
+	steps := []RemoteStep{
+		{Name: "Set up job"},
+		{Name: "Run npm ci"},
+		{Name: "Run unit tests"},
+		{Name: "Lint sources"},
+	}
+	cases := []struct {
+		name string
+		res  report.StepResult
+		want int
+	}{
+		{"exact", report.StepResult{StepName: "lint sources"}, 3},
+		{"unnamed step uses run line", report.StepResult{StepName: "step 1", StepRun: "npm ci\nnpm run build"}, 1},
+		{"renamed step", report.StepResult{StepName: "Unit tests"}, 2},
+		{"no match", report.StepResult{StepName: "Deploy"}, -1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			used := []bool{true, false, false, false}
+			if got := matchStep(tc.res, steps, used); got != tc.want {
+				t.Fatalf("matchStep = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
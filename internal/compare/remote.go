@@ -0,0 +1,155 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// DefaultAPIURL is used when GITHUB_API_URL is not set.
+const DefaultAPIURL = "https://api.github.com"
+
+// RemoteStep is a step as reported by the GitHub Actions jobs API.
+type RemoteStep struct {
+	Name       string `json:"name"`
+	Number     int    `json:"number"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+}
+
+// RemoteJob is a job as reported by the GitHub Actions jobs API. Matrix jobs
+// appear once per combination, e.g. "test (ubuntu-latest, 1.22)".
+type RemoteJob struct {
+	ID           int64        `json:"id"`
+	RunID        int64        `json:"run_id"`
+	Name         string       `json:"name"`
+	WorkflowName string       `json:"workflow_name"`
+	Status       string       `json:"status"`
+	Conclusion   string       `json:"conclusion"`
+	Steps        []RemoteStep `json:"steps"`
+}
+
+// jobsPage mirrors GET /repos/{owner}/{repo}/actions/runs/{run_id}/jobs.
+type jobsPage struct {
+	TotalCount int         `json:"total_count"`
+	Jobs       []RemoteJob `json:"jobs"`
+}
+
+// ParseJobs decodes a saved jobs API response, such as the output of
+// `gh api repos/OWNER/REPO/actions/runs/ID/jobs`.
+func ParseJobs(data []byte) ([]RemoteJob, error) {
+	var page jobsPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, fmt.Errorf("decode jobs payload: %w", err)
+	}
+	if page.Jobs == nil {
+		return nil, fmt.Errorf("decode jobs payload: no jobs array")
+	}
+	return page.Jobs, nil
+}
+
+// Client fetches workflow run results from the GitHub REST API.
+type Client struct {
+	HTTP    *http.Client
+	BaseURL string
+	Token   string
+}
+
+// FetchJobs returns every job of a workflow run, following pagination. The
+// raw pages are merged into a single payload that ParseJobs accepts, so it
+// can be saved for offline comparisons.
+func (c *Client) FetchJobs(ctx context.Context, repo string, runID int64) ([]RemoteJob, []byte, error) {
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	base := strings.TrimRight(c.BaseURL, "/")
+	if base == "" {
+		base = DefaultAPIURL
+	}
+
+	var all jobsPage
+	url := fmt.Sprintf("%s/repos/%s/actions/runs/%d/jobs?per_page=100", base, repo, runID)
+	for url != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("build request: %w", err)
+		}
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetch run %d jobs: %w", runID, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("read run %d jobs: %w", runID, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, nil, fmt.Errorf("fetch run %d jobs: %s: %s", runID, resp.Status, apiMessage(body))
+		}
+
+		var page jobsPage
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, nil, fmt.Errorf("decode run %d jobs: %w", runID, err)
+		}
+		all.TotalCount = page.TotalCount
+		all.Jobs = append(all.Jobs, page.Jobs...)
+		url = nextLink(resp.Header.Get("Link"))
+	}
+	if all.Jobs == nil {
+		all.Jobs = []RemoteJob{}
+	}
+
+	raw, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode jobs payload: %w", err)
+	}
+	return all.Jobs, raw, nil
+}
+
+var linkNext = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextLink extracts the rel="next" URL from a Link header.
+func nextLink(header string) string {
+	if m := linkNext.FindStringSubmatch(header); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// apiMessage returns the message field of a GitHub error body, or the body.
+func apiMessage(body []byte) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Message != "" {
+		return e.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+var (
+	sshRemote   = regexp.MustCompile(`^(?:ssh://)?git@[^:/]+[:/]([^/]+/[^/]+?)(?:\.git)?/?$`)
+	httpsRemote = regexp.MustCompile(`^https?://[^/]+/([^/]+/[^/]+?)(?:\.git)?/?$`)
+)
+
+// RepoFromRemote returns "owner/name" from a git remote URL.
+func RepoFromRemote(remote string) (string, error) {
+	remote = strings.TrimSpace(remote)
+	for _, re := range []*regexp.Regexp{sshRemote, httpsRemote} {
+		if m := re.FindStringSubmatch(remote); m != nil {
+			return m[1], nil
+		}
+	}
+	return "", fmt.Errorf("cannot determine repository from remote %q", remote)
+}
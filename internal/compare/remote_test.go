@@ -0,0 +1,87 @@
+package compare
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchJobsPaginates(t *testing.T) {
+	var auth []string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		if r.URL.Path != "/repos/acme/app/actions/runs/42/jobs" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"total_count": 2, "jobs": [{"name": "test", "workflow_name": "CI", "steps": []}]}`)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s?per_page=100&page=2>; rel="next", <%s%s?per_page=100&page=2>; rel="last"`, srv.URL, r.URL.Path, srv.URL, r.URL.Path))
+		fmt.Fprint(w, `{"total_count": 2, "jobs": [{"name": "lint", "workflow_name": "CI", "steps": []}]}`)
+	}))
+	defer srv.Close()
+
+	client := &Client{BaseURL: srv.URL, Token: "secret"}
+	jobs, raw, err := client.FetchJobs(context.Background(), "acme/app", 42)
+	if err != nil {
+		t.Fatalf("FetchJobs: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].Name != "lint" || jobs[1].Name != "test" {
+		t.Fatalf("unexpected jobs: %+v", jobs)
+	}
+	if len(auth) != 2 || auth[0] != "Bearer secret" {
+		t.Fatalf("expected two authenticated requests, got %q", auth)
+	}
+
+	saved, err := ParseJobs(raw)
+	if err != nil {
+		t.Fatalf("ParseJobs(saved payload): %v", err)
+	}
+	if len(saved) != 2 {
+		t.Fatalf("saved payload should round-trip, got %+v", saved)
+	}
+}
+
+func TestFetchJobsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message": "Not Found"}`)
+	}))
+	defer srv.Close()
+
+	_, _, err := (&Client{BaseURL: srv.URL}).FetchJobs(context.Background(), "acme/app", 7)
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found: Not Found") {
+		t.Fatalf("expected API error, got %v", err)
+	}
+}
+
+func TestParseJobsRejectsOtherPayloads(t *testing.T) {
+	if _, err := ParseJobs([]byte(`{"workflow_runs": []}`)); err == nil {
+		t.Fatalf("expected error for payload without jobs")
+	}
+}
+
+func TestRepoFromRemote(t *testing.T) {
+	cases := map[string]string{
+		"git@github.com:acme/app.git":         "acme/app",
+		"ssh://git@github.com/acme/app.git":   "acme/app",
+		"https://github.com/acme/app.git\n":   "acme/app",
+		"https://github.com/acme/app":         "acme/app",
+		"https://ghe.example.com/acme/app.js": "acme/app.js",
+	}
+	for remote, want := range cases {
+		got, err := RepoFromRemote(remote)
+		if err != nil || got != want {
+			t.Errorf("RepoFromRemote(%q) = %q, %v; want %q", remote, got, err, want)
+		}
+	}
+	if _, err := RepoFromRemote("/srv/git/app"); err == nil {
+		t.Errorf("expected error for a local path remote")
+	}
+}
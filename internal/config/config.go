@@ -4,41 +4,378 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config captures CLI options sourced from config files or flags.
 type Config struct {
-	Provider  string   `yaml:"provider"`
-	Workflows []string `yaml:"workflows"`
-	Jobs      []string `yaml:"jobs"`
+	Provider  string   `yaml:"provider" json:"provider"`
+	Workflows []string `yaml:"workflows" json:"workflows"`
+	// ExcludeWorkflows drops discovered workflow files matching a glob or
+	// /regex/. Explicitly listed workflows are never excluded.
+	ExcludeWorkflows []string `yaml:"exclude_workflows" json:"exclude_workflows"`
+	// WorkflowDirs lists the directories searched for workflow files, in
+	// precedence order. Empty means .github/workflows, .gitea/workflows,
+	// and .forgejo/workflows.
+	WorkflowDirs []string `yaml:"workflow_dirs" json:"workflow_dirs"`
+	// WorkflowRefs loads workflows from other git refs as REF:PATH specs,
+	// e.g. origin/feature:.github/workflows/ci.yml.
+	WorkflowRefs []string `yaml:"workflow_refs" json:"workflow_refs"`
+	// WorkflowURLs downloads workflows over http(s) on every load.
+	WorkflowURLs []string `yaml:"workflow_urls" json:"workflow_urls"`
+	Jobs         []string `yaml:"jobs" json:"jobs"`
 
-	OnlySteps []string `yaml:"only_step"`
-	SkipSteps []string `yaml:"skip_step"`
+	OnlySteps []string `yaml:"only_step" json:"only_step"`
+	SkipSteps []string `yaml:"skip_step" json:"skip_step"`
+	// TeardownSteps marks matching steps as teardown: they run at the end
+	// of their job even after a failure or cancellation, like steps named
+	// "post: ...".
+	TeardownSteps []string `yaml:"teardown_steps" json:"teardown_steps"`
+	// ExpectedFailures marks matching steps as known to fail locally: a
+	// failure is recorded as xfail and does not fail the run, and a pass is
+	// recorded as xpass with a warning to drop the marker. Patterns match
+	// step names or scripts as skip_step does.
+	ExpectedFailures []string `yaml:"expected_failures" json:"expected_failures"`
 
-	DryRun  bool   `yaml:"dry_run"`
-	Verbose bool   `yaml:"verbose"`
-	Format  string `yaml:"format"`
+	DryRun    bool   `yaml:"dry_run" json:"dry_run"`
+	Verbose   bool   `yaml:"verbose" json:"verbose"`
+	Format    string `yaml:"format" json:"format"`
+	TailLines int    `yaml:"tail_lines" json:"tail_lines"`
 
-	Warn                      WarnConfig `yaml:"warn"`
-	PrivilegedCommandPatterns []string   `yaml:"privileged_command_patterns"`
+	// CombineOutput captures each step's stdout and stderr as one stream so
+	// their interleaving survives in verbose output and failure details.
+	CombineOutput bool `yaml:"combine_output" json:"combine_output"`
+
+	// Compact writes JSON output on a single line instead of indenting it.
+	Compact bool `yaml:"compact" json:"compact"`
+	// NoCache disables reuse of parsed workflows within a process.
+	NoCache bool `yaml:"no_cache" json:"no_cache"`
+	// Dedupe skips steps whose script, working directory, and env match a
+	// step that already passed earlier in the run.
+	Dedupe bool `yaml:"dedupe" json:"dedupe"`
+	// RequireMatch makes filters that select no run step an error that
+	// names the filters and suggests the jobs or steps they were close to.
+	RequireMatch bool `yaml:"require_match" json:"require_match"`
+	// MaxParallel caps how many jobs run at once. Jobs sharing a
+	// concurrency group are still serialized.
+	MaxParallel int `yaml:"max_parallel" json:"max_parallel"`
+	// Schedule picks the order parallel runs start jobs in: declared order,
+	// or longest-first by each job's last recorded duration.
+	Schedule string `yaml:"schedule" json:"schedule"`
+	// Follow names the job (substring or /regex/) whose verbose output
+	// streams live in parallel runs; other jobs' output is shown as one
+	// block each when they finish.
+	Follow string `yaml:"follow" json:"follow"`
+	// History records every run under .testdrive/history.
+	History bool `yaml:"history" json:"history"`
+	// NoVersionCheck skips probing installed tool versions entirely.
+	NoVersionCheck bool `yaml:"no_version_check" json:"no_version_check"`
+
+	Warn WarnConfig `yaml:"warn" json:"warn"`
+	// Checks controls the checks that probe this machine rather than the
+	// workflow files.
+	Checks ChecksConfig `yaml:"checks" json:"checks"`
+	// Output tunes how pretty results are shown.
+	Output OutputConfig `yaml:"output" json:"output"`
+	// Limits caps the size of workflows the parser accepts.
+	Limits LimitsConfig `yaml:"limits" json:"limits"`
+	// SuppressWarnings hides warnings of the listed kinds.
+	SuppressWarnings []string `yaml:"suppress_warnings" json:"suppress_warnings"`
+
+	PrivilegedCommandPatterns []string `yaml:"privileged_command_patterns" json:"privileged_command_patterns"`
+	// DestructiveCommandPatterns match commands that delete data or
+	// infrastructure; matching steps are skipped unless AllowDestructive is
+	// set. Empty means the runner defaults.
+	DestructiveCommandPatterns []string `yaml:"destructive_command_patterns" json:"destructive_command_patterns"`
+	// Patterns adds to and removes from the built-in pattern sets.
+	Patterns PatternsConfig `yaml:"patterns" json:"patterns"`
+	// AllowDestructive runs steps that look destructive.
+	AllowDestructive bool `yaml:"allow_destructive" json:"allow_destructive"`
+	// AllowUnresolvedExpressions runs steps that still hold ${{ }}
+	// expressions instead of failing them.
+	AllowUnresolvedExpressions bool `yaml:"allow_unresolved_expressions" json:"allow_unresolved_expressions"`
+	// AllowedEnvironments lists deployment environments whose jobs may run
+	// locally; jobs targeting any other environment are skipped.
+	AllowedEnvironments []string   `yaml:"allowed_environments" json:"allowed_environments"`
+	Overrides           []Override `yaml:"overrides" json:"overrides"`
+	// PathMappings rewrites absolute CI paths, such as
+	// /home/runner/work/app/app, to local ones in working directories and
+	// env values. Relative targets are resolved against the repository root.
+	PathMappings map[string]string `yaml:"path_mappings" json:"path_mappings"`
+	// AutoPath puts the node_modules/.bin and bin directories nearest each
+	// step's working directory at the front of its PATH, as CI setup
+	// actions make project binaries available.
+	AutoPath bool `yaml:"auto_path" json:"auto_path"`
+
+	// EnvFile names a KEY=VALUE file, relative to the repository root, whose
+	// entries are added to every step's environment.
+	EnvFile string `yaml:"env_file" json:"env_file"`
+	// Vars holds repository variables that `${{ vars.NAME }}` expressions
+	// resolve to. They are not secrets and are never masked.
+	Vars map[string]string `yaml:"vars" json:"vars"`
+	// VarsFile names a file of repository variables, relative to the
+	// repository root, as KEY=VALUE lines or a YAML map. Its entries take
+	// precedence over Vars.
+	VarsFile string `yaml:"vars_file" json:"vars_file"`
+	// RedactEnvPatterns are glob patterns matched, ignoring case, against
+	// the names in each step's environment. The values of matching
+	// variables are masked in step output, hints, and reports; values
+	// shorter than four characters are left alone. Empty masks nothing.
+	RedactEnvPatterns []string `yaml:"redact_env_patterns" json:"redact_env_patterns"`
+	// ShellAudit configures the static scan of run scripts for constructs
+	// their shell does not understand.
+	ShellAudit ShellAuditConfig `yaml:"shell_audit" json:"shell_audit"`
+	// RequiredEnv lists variables that must be set before anything runs.
+	RequiredEnv []RequiredEnv `yaml:"required_env" json:"required_env"`
+	// CheckEnv scans run scripts for variables and secrets that are not set
+	// locally and reports them as warnings.
+	CheckEnv bool `yaml:"check_env" json:"check_env"`
+	// CheckPorts lists TCP ports that must be free before each job starts,
+	// such as the ports its dev servers bind. DetectPorts adds the ports
+	// each job's scripts and PORT-like env values name.
+	CheckPorts  []int `yaml:"check_ports" json:"check_ports"`
+	DetectPorts bool  `yaml:"detect_ports" json:"detect_ports"`
+	// TimeBudgets maps job patterns (substring or /regex/, as for --job) to
+	// durations such as "20m". A job that runs longer than its budget, or
+	// than its timeout-minutes on CI, is warned about after the run.
+	TimeBudgets map[string]string `yaml:"time_budgets" json:"time_budgets"`
+	// StallWarningAfter is how long a running step may write no output
+	// before it is warned about, e.g. "2m"; StallTimeout is how long before
+	// it is killed. Empty turns either off.
+	StallWarningAfter string `yaml:"stall_warning_after" json:"stall_warning_after"`
+	StallTimeout      string `yaml:"stall_timeout" json:"stall_timeout"`
+	// ShowInfo prints info notices, such as workflow keys that have no local
+	// effect, alongside warnings. JSON output always includes them.
+	ShowInfo bool `yaml:"show_info" json:"show_info"`
+	// StrictGit turns the warn.dirty_worktree findings into errors.
+	StrictGit bool `yaml:"strict_git" json:"strict_git"`
+
+	// TraceShell turns on command tracing in the steps OnlySteps selects,
+	// and OverrideShell, when set, replaces their shell. Both exist only
+	// for one-off debugging from the command line.
+	TraceShell    bool   `yaml:"-" json:"-"`
+	OverrideShell string `yaml:"-" json:"-"`
+
+	// Origins records which source supplied each key. It is populated by Load
+	// and ApplyFlags and is never read from or written to config files.
+	Origins Origins `yaml:"-" json:"-"`
 }
 
 // WarnConfig controls additional warning behaviour.
 type WarnConfig struct {
-	VersionMismatch bool `yaml:"version_mismatch"`
+	VersionMismatch bool `yaml:"version_mismatch" json:"version_mismatch"`
+	// DirtyWorktree warns before a run when uncommitted changes or the
+	// branch's position differ from what CI would build.
+	DirtyWorktree bool `yaml:"dirty_worktree" json:"dirty_worktree"`
+}
+
+// ChecksConfig controls the tool version probes, git state warnings, and
+// environment checks (check_env, and required_env before a run) that
+// commands make before reporting.
+type ChecksConfig struct {
+	// Enabled picks when they run: auto runs them for `run` but not for
+	// `list`, true always runs them, and false never does.
+	Enabled string `yaml:"enabled" json:"enabled"`
+}
+
+// OutputConfig controls how pretty results reach the terminal.
+type OutputConfig struct {
+	// Pager pipes long failure blocks through $PAGER (or less -R) when set
+	// to auto and results go to a terminal.
+	Pager string `yaml:"pager" json:"pager"`
+	// Stream picks how `run` shows pretty results: auto streams job status
+	// live when that suits the terminal, true always streams, and false
+	// prints every result once the run ends.
+	Stream string `yaml:"stream" json:"stream"`
+	// FoldMarkers are the lines --verbose output puts around each job and
+	// step so an editor can fold them.
+	FoldMarkers FoldMarkers `yaml:"fold_markers" json:"fold_markers"`
+	// Layout shapes pretty output for narrow terminals and side panels.
+	Layout LayoutConfig `yaml:"layout" json:"layout"`
+}
+
+// FoldMarkers are templates for the lines that open and close a fold;
+// {name} becomes the job or step name. The defaults are the Actions
+// ##[group] commands. An empty template writes no line.
+type FoldMarkers struct {
+	Start string `yaml:"start" json:"start"`
+	End   string `yaml:"end" json:"end"`
+}
+
+// LayoutConfig shapes pretty output. The defaults are the layout testdrive
+// has always printed.
+type LayoutConfig struct {
+	// Indent is the number of spaces per level of the workflow, job and
+	// step tree.
+	Indent int `yaml:"indent" json:"indent"`
+	// WorkflowPaths shows each workflow's path next to its name.
+	WorkflowPaths bool `yaml:"workflow_paths" json:"workflow_paths"`
+	// Width cuts longer lines short with an ellipsis; zero never does.
+	Width int `yaml:"width" json:"width"`
+	// PassedDurations shows how long each passing step took.
+	PassedDurations bool `yaml:"passed_durations" json:"passed_durations"`
+}
+
+// LimitsConfig bounds how large a workflow may be before parsing gives up,
+// so a generated or hostile file fails with a clear error instead of eating
+// time and memory. Zero disables a limit.
+type LimitsConfig struct {
+	// WorkflowBytes is the largest workflow file read, in bytes.
+	WorkflowBytes int `yaml:"workflow_bytes" json:"workflow_bytes"`
+	// Jobs and Steps cap the jobs in a workflow and the steps across them.
+	Jobs  int `yaml:"jobs" json:"jobs"`
+	Steps int `yaml:"steps" json:"steps"`
+}
+
+// PatternsConfig changes the built-in privileged, destructive, and noise
+// pattern sets without restating them.
+type PatternsConfig struct {
+	Privileged  PatternChanges `yaml:"privileged" json:"privileged"`
+	Destructive PatternChanges `yaml:"destructive" json:"destructive"`
+	Noise       PatternChanges `yaml:"noise" json:"noise"`
+}
+
+// PatternChanges lists expressions to append to a pattern set and
+// expressions to drop from it, matched exactly.
+type PatternChanges struct {
+	Add    []string `yaml:"add" json:"add"`
+	Remove []string `yaml:"remove" json:"remove"`
+}
+
+// Override adjusts steps matching the job and/or step pattern. Patterns use
+// the same substring or /regex/ syntax as the CLI filters.
+type Override struct {
+	Job  string            `yaml:"job,omitempty" json:"job,omitempty"`
+	Step string            `yaml:"step,omitempty" json:"step,omitempty"`
+	Skip bool              `yaml:"skip,omitempty" json:"skip,omitempty"`
+	Env  map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	Run  string            `yaml:"run,omitempty" json:"run,omitempty"`
+	// Shell replaces the shell of every matching step, for jobs whose
+	// declared shell is missing locally.
+	Shell string `yaml:"shell,omitempty" json:"shell,omitempty"`
+}
+
+// ShellAuditConfig controls the shell audit that validate --shell-audit
+// runs.
+type ShellAuditConfig struct {
+	// Enabled also runs it whenever workflows are loaded, adding what it
+	// finds to the warnings of list, run and the other commands.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Disable turns off built-in or added rules by ID.
+	Disable []string `yaml:"disable" json:"disable"`
+	// Rules adds rules to the built-in set.
+	Rules []ShellAuditRule `yaml:"rules" json:"rules"`
+}
+
+// ShellAuditRule flags script lines matching Pattern, a regular
+// expression, in steps run by Shell: sh, posix or python.
+type ShellAuditRule struct {
+	ID      string `yaml:"id" json:"id"`
+	Shell   string `yaml:"shell" json:"shell"`
+	Pattern string `yaml:"pattern" json:"pattern"`
+	Message string `yaml:"message" json:"message"`
+}
+
+// RequiredEnv names variables that must be set locally. When Job is set the
+// requirement only applies if a matching job is selected. A plain string in
+// the config file is shorthand for a single unscoped key.
+type RequiredEnv struct {
+	Job  string   `yaml:"job,omitempty" json:"job,omitempty"`
+	Keys []string `yaml:"keys" json:"keys"`
+}
+
+// UnmarshalYAML accepts either a bare variable name or a job/keys mapping.
+func (r *RequiredEnv) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*r = RequiredEnv{Keys: []string{node.Value}}
+		return nil
+	}
+	type plain RequiredEnv
+	var p plain
+	if err := node.Decode(&p); err != nil {
+		return err
+	}
+	*r = RequiredEnv(p)
+	return nil
+}
+
+// Source identifies where a configuration value came from.
+type Source string
+
+const (
+	// SourceDefault marks values that were never overridden.
+	SourceDefault Source = "default"
+	// SourceFile marks values read from .testdrive.yml.
+	SourceFile Source = "config"
+	// SourceEnv marks values read from TESTDRIVE_* environment variables.
+	SourceEnv Source = "env"
+	// SourceFlag marks values supplied on the command line.
+	SourceFlag Source = "flag"
+)
+
+// Origins maps dotted YAML keys (for example "warn.version_mismatch") to the
+// source that last set them. Keys without an entry come from the defaults.
+type Origins map[string]Source
+
+// Of returns the source for key, falling back to SourceDefault.
+func (o Origins) Of(key string) Source {
+	if src, ok := o[key]; ok {
+		return src
+	}
+	return SourceDefault
+}
+
+func (o Origins) set(key string, src Source) {
+	if o != nil {
+		o[key] = src
+	}
 }
 
 // Default returns the baseline configuration used when no flags or config file specify values.
 func Default() Config {
 	return Config{
-		Provider: ProviderAuto,
-		Format:   FormatPretty,
+		Provider:    ProviderAuto,
+		Format:      FormatPretty,
+		TailLines:   20,
+		MaxParallel: 1,
+		Schedule:    ScheduleLongestFirst,
+		History:     true,
+		AutoPath:    true,
+
+		StallWarningAfter: "2m",
+		RedactEnvPatterns: []string{"*_TOKEN", "*_KEY", "*_SECRET", "*PASSWORD"},
 		Warn: WarnConfig{
 			VersionMismatch: true,
+			DirtyWorktree:   true,
+		},
+		Checks: ChecksConfig{
+			Enabled: ChecksAuto,
+		},
+		Output: OutputConfig{
+			Pager:  PagerNever,
+			Stream: StreamAuto,
+			FoldMarkers: FoldMarkers{
+				Start: "##[group]{name}",
+				End:   "##[endgroup]",
+			},
+			Layout: LayoutConfig{
+				Indent:          2,
+				WorkflowPaths:   true,
+				PassedDurations: true,
+			},
 		},
+		Limits: LimitsConfig{
+			WorkflowBytes: 4 << 20,
+			Jobs:          1000,
+			Steps:         10000,
+		},
+		Origins: Origins{},
 	}
 }
 
@@ -52,12 +389,126 @@ const (
 	FormatPretty = "pretty"
 	// FormatJSON renders machine readable output.
 	FormatJSON = "json"
+	// FormatMarkdown renders documentation tables; only list supports it.
+	FormatMarkdown = "markdown"
+	// FormatCompletion lists jobs and steps as tab-separated lines for
+	// completion scripts and pickers; only list supports it.
+	FormatCompletion = "completion"
+
+	// ScheduleDeclared starts jobs in workflow and job order.
+	ScheduleDeclared = "declared"
+	// ScheduleLongestFirst starts the historically slowest jobs first.
+	ScheduleLongestFirst = "longest-first"
+
+	// PagerAuto pages long failure blocks when results go to a terminal.
+	PagerAuto = "auto"
+	// PagerNever always prints failure blocks inline.
+	PagerNever = "never"
+
+	// StreamAuto streams pretty run results unless verbose output, a dry run
+	// piped elsewhere, parallel jobs, debug logging, or a pager rule it out.
+	StreamAuto = "auto"
+	// StreamOn always streams pretty run results.
+	StreamOn = "true"
+	// StreamOff always prints pretty run results after the run.
+	StreamOff = "false"
+
+	// ChecksAuto checks the machine before runs but not listings.
+	ChecksAuto = "auto"
+	// ChecksOn always checks the machine.
+	ChecksOn = "true"
+	// ChecksOff never checks the machine.
+	ChecksOff = "false"
 )
 
+// formats lists every output format in the order errors name them, with the
+// commands that render it; nil means every command. A new renderer is added
+// here and in the command's switch.
+var formats = []struct {
+	name     string
+	commands []string
+}{
+	{name: FormatPretty},
+	{name: FormatJSON},
+	{name: FormatMarkdown, commands: []string{"list"}},
+	{name: FormatCompletion, commands: []string{"list"}},
+}
+
+// FormatsFor returns the output formats command can render.
+func FormatsFor(command string) []string {
+	var out []string
+	for _, f := range formats {
+		if f.commands == nil || slices.Contains(f.commands, command) {
+			out = append(out, f.name)
+		}
+	}
+	return out
+}
+
+// CheckFormat reports an error naming the supported formats when command
+// cannot render format. Commands call it before doing any work so a typo
+// does not surface only after a full run.
+func CheckFormat(command, format string) error {
+	supported := FormatsFor(command)
+	if slices.Contains(supported, strings.ToLower(format)) {
+		return nil
+	}
+	return fmt.Errorf("unsupported format %q for %s; use %s", format, command, strings.Join(supported, ", "))
+}
+
+// CheckLayout reports an output.layout that cannot be rendered. Width must
+// leave room for the ellipsis and a character before it.
+func CheckLayout(layout LayoutConfig) error {
+	if layout.Indent < 1 {
+		return fmt.Errorf("output.layout.indent must be at least 1, got %d", layout.Indent)
+	}
+	if layout.Width != 0 && layout.Width < 2 {
+		return fmt.Errorf("output.layout.width must be 0 (no limit) or at least 2, got %d", layout.Width)
+	}
+	return nil
+}
+
+// StallDurations parses StallWarningAfter and StallTimeout. An empty value
+// parses as zero, which turns that check off.
+func StallDurations(cfg Config) (warnAfter, timeout time.Duration, err error) {
+	for _, field := range []struct {
+		key, value string
+		d          *time.Duration
+	}{
+		{"stall_warning_after", cfg.StallWarningAfter, &warnAfter},
+		{"stall_timeout", cfg.StallTimeout, &timeout},
+	} {
+		value := strings.TrimSpace(field.value)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("%s: invalid duration %q (use a duration such as 2m or 1h30m, or leave it empty)", field.key, field.value)
+		}
+		*field.d = d
+	}
+	return warnAfter, timeout, nil
+}
+
+// CheckRedactEnvPatterns reports the first malformed pattern in
+// RedactEnvPatterns.
+func CheckRedactEnvPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("redact_env_patterns: invalid pattern %q (use a glob such as *_TOKEN)", pattern)
+		}
+	}
+	return nil
+}
+
+// FileName is the repository-level config file read by Load.
+const FileName = ".testdrive.yml"
+
 // Load reads .testdrive.yml from the repository root when present. Missing files are ignored.
 func Load(root string) (Config, error) {
 	cfg := Default()
-    path := filepath.Join(root, ".testdrive.yml")
+	path := filepath.Join(root, FileName)
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -66,78 +517,433 @@ func Load(root string) (Config, error) {
 		return cfg, fmt.Errorf("read config %q: %w", path, err)
 	}
 
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return cfg, fmt.Errorf("parse config %q: %w", path, err)
+	}
 	var fileCfg Config
-	if err := yaml.Unmarshal(data, &fileCfg); err != nil {
+	if err := doc.Decode(&fileCfg); err != nil {
 		return cfg, fmt.Errorf("parse config %q: %w", path, err)
 	}
 
-	cfg = merge(cfg, fileCfg)
+	cfg = merge(cfg, fileCfg, documentKeys(&doc))
 	return cfg, nil
 }
 
-func merge(base, override Config) Config {
+// merge overlays the keys present in the config file onto base. Only keys
+// that appear in the document are applied so explicit false/empty values
+// can override defaults.
+func merge(base, override Config, present map[string]bool) Config {
 	out := base
+	out.Origins = make(Origins, len(base.Origins)+len(present))
+	for k, v := range base.Origins {
+		out.Origins[k] = v
+	}
 
-	if override.Provider != "" {
+	if present["provider"] {
 		out.Provider = override.Provider
 	}
-	if len(override.Workflows) > 0 {
+	if present["workflows"] {
 		out.Workflows = append([]string{}, override.Workflows...)
 	}
-	if len(override.Jobs) > 0 {
+	if present["exclude_workflows"] {
+		out.ExcludeWorkflows = append([]string{}, override.ExcludeWorkflows...)
+	}
+	if present["workflow_dirs"] {
+		out.WorkflowDirs = append([]string{}, override.WorkflowDirs...)
+	}
+	if present["workflow_refs"] {
+		out.WorkflowRefs = append([]string{}, override.WorkflowRefs...)
+	}
+	if present["workflow_urls"] {
+		out.WorkflowURLs = append([]string{}, override.WorkflowURLs...)
+	}
+	if present["jobs"] {
 		out.Jobs = append([]string{}, override.Jobs...)
 	}
-	if len(override.OnlySteps) > 0 {
+	if present["only_step"] {
 		out.OnlySteps = append([]string{}, override.OnlySteps...)
 	}
-	if len(override.SkipSteps) > 0 {
+	if present["skip_step"] {
 		out.SkipSteps = append([]string{}, override.SkipSteps...)
 	}
-	if len(override.PrivilegedCommandPatterns) > 0 {
+	if present["teardown_steps"] {
+		out.TeardownSteps = append([]string{}, override.TeardownSteps...)
+	}
+	if present["expected_failures"] {
+		out.ExpectedFailures = append([]string{}, override.ExpectedFailures...)
+	}
+	if present["privileged_command_patterns"] {
 		out.PrivilegedCommandPatterns = append([]string{}, override.PrivilegedCommandPatterns...)
 	}
-	if override.Format != "" {
+	if present["destructive_command_patterns"] {
+		out.DestructiveCommandPatterns = append([]string{}, override.DestructiveCommandPatterns...)
+	}
+	if present["patterns.privileged.add"] {
+		out.Patterns.Privileged.Add = append([]string{}, override.Patterns.Privileged.Add...)
+	}
+	if present["patterns.privileged.remove"] {
+		out.Patterns.Privileged.Remove = append([]string{}, override.Patterns.Privileged.Remove...)
+	}
+	if present["patterns.destructive.add"] {
+		out.Patterns.Destructive.Add = append([]string{}, override.Patterns.Destructive.Add...)
+	}
+	if present["patterns.destructive.remove"] {
+		out.Patterns.Destructive.Remove = append([]string{}, override.Patterns.Destructive.Remove...)
+	}
+	if present["patterns.noise.add"] {
+		out.Patterns.Noise.Add = append([]string{}, override.Patterns.Noise.Add...)
+	}
+	if present["patterns.noise.remove"] {
+		out.Patterns.Noise.Remove = append([]string{}, override.Patterns.Noise.Remove...)
+	}
+	if present["allow_destructive"] {
+		out.AllowDestructive = override.AllowDestructive
+	}
+	if present["allow_unresolved_expressions"] {
+		out.AllowUnresolvedExpressions = override.AllowUnresolvedExpressions
+	}
+	if present["allowed_environments"] {
+		out.AllowedEnvironments = append([]string{}, override.AllowedEnvironments...)
+	}
+	if present["overrides"] {
+		out.Overrides = append([]Override{}, override.Overrides...)
+	}
+	if present["path_mappings"] {
+		out.PathMappings = make(map[string]string, len(override.PathMappings))
+		for from, to := range override.PathMappings {
+			out.PathMappings[from] = to
+		}
+	}
+	if present["env_file"] {
+		out.EnvFile = override.EnvFile
+	}
+	if present["vars"] {
+		out.Vars = make(map[string]string, len(override.Vars))
+		for name, value := range override.Vars {
+			out.Vars[name] = value
+		}
+	}
+	if present["vars_file"] {
+		out.VarsFile = override.VarsFile
+	}
+	if present["redact_env_patterns"] {
+		out.RedactEnvPatterns = append([]string{}, override.RedactEnvPatterns...)
+	}
+	if present["shell_audit.enabled"] {
+		out.ShellAudit.Enabled = override.ShellAudit.Enabled
+	}
+	if present["shell_audit.disable"] {
+		out.ShellAudit.Disable = append([]string{}, override.ShellAudit.Disable...)
+	}
+	if present["shell_audit.rules"] {
+		out.ShellAudit.Rules = append([]ShellAuditRule{}, override.ShellAudit.Rules...)
+	}
+	if present["required_env"] {
+		out.RequiredEnv = append([]RequiredEnv{}, override.RequiredEnv...)
+	}
+	if present["check_env"] {
+		out.CheckEnv = override.CheckEnv
+	}
+	if present["check_ports"] {
+		out.CheckPorts = append([]int{}, override.CheckPorts...)
+	}
+	if present["detect_ports"] {
+		out.DetectPorts = override.DetectPorts
+	}
+	if present["time_budgets"] {
+		out.TimeBudgets = make(map[string]string, len(override.TimeBudgets))
+		for pattern, budget := range override.TimeBudgets {
+			out.TimeBudgets[pattern] = budget
+		}
+	}
+	if present["stall_warning_after"] {
+		out.StallWarningAfter = override.StallWarningAfter
+	}
+	if present["stall_timeout"] {
+		out.StallTimeout = override.StallTimeout
+	}
+	if present["show_info"] {
+		out.ShowInfo = override.ShowInfo
+	}
+	if present["format"] {
 		out.Format = override.Format
 	}
-	if override.DryRun {
-		out.DryRun = true
+	if present["tail_lines"] {
+		out.TailLines = override.TailLines
+	}
+	if present["compact"] {
+		out.Compact = override.Compact
+	}
+	if present["no_cache"] {
+		out.NoCache = override.NoCache
+	}
+	if present["no_version_check"] {
+		out.NoVersionCheck = override.NoVersionCheck
+	}
+	if present["max_parallel"] {
+		out.MaxParallel = override.MaxParallel
+	}
+	if present["schedule"] {
+		out.Schedule = override.Schedule
+	}
+	if present["follow"] {
+		out.Follow = override.Follow
+	}
+	if present["history"] {
+		out.History = override.History
+	}
+	if present["auto_path"] {
+		out.AutoPath = override.AutoPath
+	}
+	if present["dedupe"] {
+		out.Dedupe = override.Dedupe
+	}
+	if present["require_match"] {
+		out.RequireMatch = override.RequireMatch
+	}
+	if present["dry_run"] {
+		out.DryRun = override.DryRun
+	}
+	if present["verbose"] {
+		out.Verbose = override.Verbose
+	}
+	if present["combine_output"] {
+		out.CombineOutput = override.CombineOutput
+	}
+	if present["warn.version_mismatch"] {
+		out.Warn.VersionMismatch = override.Warn.VersionMismatch
+	}
+	if present["warn.dirty_worktree"] {
+		out.Warn.DirtyWorktree = override.Warn.DirtyWorktree
+	}
+	if present["checks.enabled"] {
+		out.Checks.Enabled = override.Checks.Enabled
+	}
+	if present["output.pager"] {
+		out.Output.Pager = override.Output.Pager
+	}
+	if present["output.stream"] {
+		out.Output.Stream = override.Output.Stream
+	}
+	if present["output.fold_markers.start"] {
+		out.Output.FoldMarkers.Start = override.Output.FoldMarkers.Start
+	}
+	if present["output.fold_markers.end"] {
+		out.Output.FoldMarkers.End = override.Output.FoldMarkers.End
+	}
+	if present["output.layout.indent"] {
+		out.Output.Layout.Indent = override.Output.Layout.Indent
+	}
+	if present["output.layout.workflow_paths"] {
+		out.Output.Layout.WorkflowPaths = override.Output.Layout.WorkflowPaths
+	}
+	if present["output.layout.width"] {
+		out.Output.Layout.Width = override.Output.Layout.Width
 	}
-	if override.Verbose {
-		out.Verbose = true
+	if present["output.layout.passed_durations"] {
+		out.Output.Layout.PassedDurations = override.Output.Layout.PassedDurations
+	}
+	if present["limits.workflow_bytes"] {
+		out.Limits.WorkflowBytes = override.Limits.WorkflowBytes
+	}
+	if present["limits.jobs"] {
+		out.Limits.Jobs = override.Limits.Jobs
+	}
+	if present["limits.steps"] {
+		out.Limits.Steps = override.Limits.Steps
+	}
+	if present["strict_git"] {
+		out.StrictGit = override.StrictGit
+	}
+	if present["suppress_warnings"] {
+		out.SuppressWarnings = append([]string{}, override.SuppressWarnings...)
 	}
 
-	if override.Warn.VersionMismatch {
-		out.Warn.VersionMismatch = true
+	for key := range present {
+		out.Origins.set(key, SourceFile)
 	}
 
 	return out
 }
 
+// documentKeys returns the dotted paths of every mapping key in a YAML
+// document, including intermediate keys such as "warn".
+func documentKeys(doc *yaml.Node) map[string]bool {
+	keys := make(map[string]bool)
+	var walk func(node *yaml.Node, prefix string)
+	walk = func(node *yaml.Node, prefix string) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, prefix)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				keys[key] = true
+				walk(node.Content[i+1], key)
+			}
+		}
+	}
+	walk(doc, "")
+	return keys
+}
+
 // ApplyFlags mutates cfg by applying values from CLI flags when they are present.
 func ApplyFlags(cfg *Config, flags FlagValues) {
+	if cfg.Origins == nil {
+		cfg.Origins = Origins{}
+	}
 	if flags.Provider.Set {
 		cfg.Provider = flags.Provider.Value
+		cfg.Origins.set("provider", SourceFlag)
 	}
 	if len(flags.Workflows.Values) > 0 {
 		cfg.Workflows = append([]string{}, flags.Workflows.Values...)
+		cfg.Origins.set("workflows", SourceFlag)
+	}
+	if len(flags.ExcludeWorkflows.Values) > 0 {
+		cfg.ExcludeWorkflows = append([]string{}, flags.ExcludeWorkflows.Values...)
+		cfg.Origins.set("exclude_workflows", SourceFlag)
+	}
+	if len(flags.WorkflowRefs.Values) > 0 {
+		cfg.WorkflowRefs = append([]string{}, flags.WorkflowRefs.Values...)
+		cfg.Origins.set("workflow_refs", SourceFlag)
+	}
+	if len(flags.WorkflowURLs.Values) > 0 {
+		cfg.WorkflowURLs = append([]string{}, flags.WorkflowURLs.Values...)
+		cfg.Origins.set("workflow_urls", SourceFlag)
 	}
 	if len(flags.Jobs.Values) > 0 {
 		cfg.Jobs = append([]string{}, flags.Jobs.Values...)
+		cfg.Origins.set("jobs", SourceFlag)
 	}
 	if len(flags.OnlySteps.Values) > 0 {
 		cfg.OnlySteps = append([]string{}, flags.OnlySteps.Values...)
+		cfg.Origins.set("only_step", SourceFlag)
 	}
 	if len(flags.SkipSteps.Values) > 0 {
 		cfg.SkipSteps = append([]string{}, flags.SkipSteps.Values...)
+		cfg.Origins.set("skip_step", SourceFlag)
+	}
+	if len(flags.AllowedEnvironments.Values) > 0 {
+		cfg.AllowedEnvironments = append([]string{}, flags.AllowedEnvironments.Values...)
+		cfg.Origins.set("allowed_environments", SourceFlag)
 	}
 	if flags.Format.Set {
 		cfg.Format = flags.Format.Value
+		cfg.Origins.set("format", SourceFlag)
+	}
+	if len(flags.SuppressWarnings.Values) > 0 {
+		cfg.SuppressWarnings = append([]string{}, flags.SuppressWarnings.Values...)
+		cfg.Origins.set("suppress_warnings", SourceFlag)
+	}
+	if flags.Compact.Set {
+		cfg.Compact = flags.Compact.Value
+		cfg.Origins.set("compact", SourceFlag)
+	}
+	if flags.EnvFile.Set {
+		cfg.EnvFile = flags.EnvFile.Value
+		cfg.Origins.set("env_file", SourceFlag)
+	}
+	if flags.VarsFile.Set {
+		cfg.VarsFile = flags.VarsFile.Value
+		cfg.Origins.set("vars_file", SourceFlag)
+	}
+	if flags.CheckEnv.Set {
+		cfg.CheckEnv = flags.CheckEnv.Value
+		cfg.Origins.set("check_env", SourceFlag)
+	}
+	if flags.ShowInfo.Set {
+		cfg.ShowInfo = flags.ShowInfo.Value
+		cfg.Origins.set("show_info", SourceFlag)
 	}
 	if flags.DryRun.Set {
 		cfg.DryRun = flags.DryRun.Value
+		cfg.Origins.set("dry_run", SourceFlag)
 	}
 	if flags.Verbose.Set {
 		cfg.Verbose = flags.Verbose.Value
+		cfg.Origins.set("verbose", SourceFlag)
+	}
+	if flags.CombineOutput.Set {
+		cfg.CombineOutput = flags.CombineOutput.Value
+		cfg.Origins.set("combine_output", SourceFlag)
+	}
+	if flags.NoCache.Set {
+		cfg.NoCache = flags.NoCache.Value
+		cfg.Origins.set("no_cache", SourceFlag)
+	}
+	if flags.NoVersionCheck.Set {
+		cfg.NoVersionCheck = flags.NoVersionCheck.Value
+		cfg.Origins.set("no_version_check", SourceFlag)
+	}
+	if flags.NoAutoPath.Set {
+		cfg.AutoPath = !flags.NoAutoPath.Value
+		cfg.Origins.set("auto_path", SourceFlag)
+	}
+	if flags.MaxParallel.Set {
+		cfg.MaxParallel = flags.MaxParallel.Value
+		cfg.Origins.set("max_parallel", SourceFlag)
+	}
+	if flags.Dedupe.Set {
+		cfg.Dedupe = flags.Dedupe.Value
+		cfg.Origins.set("dedupe", SourceFlag)
+	}
+	if flags.RequireMatch.Set {
+		cfg.RequireMatch = flags.RequireMatch.Value
+		cfg.Origins.set("require_match", SourceFlag)
+	}
+	if flags.Schedule.Set {
+		cfg.Schedule = flags.Schedule.Value
+		cfg.Origins.set("schedule", SourceFlag)
+	}
+	if flags.Follow.Set {
+		cfg.Follow = flags.Follow.Value
+		cfg.Origins.set("follow", SourceFlag)
+	}
+	if flags.StrictGit.Set {
+		cfg.StrictGit = flags.StrictGit.Value
+		cfg.Origins.set("strict_git", SourceFlag)
+	}
+	if flags.AllowDestructive.Set {
+		cfg.AllowDestructive = flags.AllowDestructive.Value
+		cfg.Origins.set("allow_destructive", SourceFlag)
+	}
+	if flags.AllowUnresolvedExpressions.Set {
+		cfg.AllowUnresolvedExpressions = flags.AllowUnresolvedExpressions.Value
+		cfg.Origins.set("allow_unresolved_expressions", SourceFlag)
+	}
+	if flags.Pager.Set {
+		cfg.Output.Pager = PagerNever
+		if flags.Pager.Value {
+			cfg.Output.Pager = PagerAuto
+		}
+		cfg.Origins.set("output.pager", SourceFlag)
+	}
+	if flags.Checks.Set {
+		cfg.Checks.Enabled = ChecksOff
+		if flags.Checks.Value {
+			cfg.Checks.Enabled = ChecksOn
+		}
+		cfg.Origins.set("checks.enabled", SourceFlag)
+	}
+	if flags.Streaming.Set {
+		cfg.Output.Stream = StreamOff
+		if flags.Streaming.Value {
+			cfg.Output.Stream = StreamOn
+		}
+		cfg.Origins.set("output.stream", SourceFlag)
+	}
+	if flags.TraceShell.Set {
+		cfg.TraceShell = flags.TraceShell.Value
+	}
+	if flags.OverrideShell.Set {
+		cfg.OverrideShell = flags.OverrideShell.Value
 	}
 }
 
@@ -145,12 +951,51 @@ func ApplyFlags(cfg *Config, flags FlagValues) {
 type FlagValues struct {
 	Provider  StringFlag
 	Workflows SliceFlag
-	Jobs      SliceFlag
-	OnlySteps SliceFlag
-	SkipSteps SliceFlag
-	Format    StringFlag
-	DryRun    BoolFlag
-	Verbose   BoolFlag
+	// ExcludeWorkflows holds --skip-workflow patterns.
+	ExcludeWorkflows SliceFlag
+	// WorkflowRefs holds --workflow-ref specs.
+	WorkflowRefs SliceFlag
+	// WorkflowURLs holds --workflow-url addresses.
+	WorkflowURLs SliceFlag
+	Jobs         SliceFlag
+	OnlySteps    SliceFlag
+	SkipSteps    SliceFlag
+	// AllowedEnvironments holds --allow-environment names.
+	AllowedEnvironments SliceFlag
+	Format              StringFlag
+	Compact             BoolFlag
+	// SuppressWarnings holds --suppress warning kinds.
+	SuppressWarnings SliceFlag
+	EnvFile          StringFlag
+	VarsFile         StringFlag
+	CheckEnv         BoolFlag
+	ShowInfo         BoolFlag
+	DryRun           BoolFlag
+	Verbose          BoolFlag
+	CombineOutput    BoolFlag
+	NoCache          BoolFlag
+	Dedupe           BoolFlag
+	RequireMatch     BoolFlag
+	MaxParallel      IntFlag
+	Schedule         StringFlag
+	Follow           StringFlag
+	NoVersionCheck   BoolFlag
+	// NoAutoPath holds --no-auto-path; true turns auto_path off.
+	NoAutoPath       BoolFlag
+	StrictGit        BoolFlag
+	AllowDestructive BoolFlag
+
+	AllowUnresolvedExpressions BoolFlag
+
+	// Pager holds --pager; true means output.pager auto.
+	Pager BoolFlag
+	// Streaming holds --streaming, or --no-streaming as false.
+	Streaming BoolFlag
+	// Checks holds --check, or --no-check as false.
+	Checks BoolFlag
+	// TraceShell holds --trace-shell and OverrideShell --override-shell.
+	TraceShell    BoolFlag
+	OverrideShell StringFlag
 }
 
 // StringFlag represents a string flag and whether it was set.
@@ -159,6 +1004,12 @@ type StringFlag struct {
 	Set   bool
 }
 
+// IntFlag represents an int flag and whether it was set.
+type IntFlag struct {
+	Value int
+	Set   bool
+}
+
 // SliceFlag represents a slice flag and whether it captured values via CLI.
 type SliceFlag struct {
 	Values []string
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadTracksOrigins(t *testing.T) {
+	root := t.TempDir()
+	data := []byte(`format: json
+jobs:
+  - test
+warn:
+  version_mismatch: false
+`)
+	if err := os.WriteFile(filepath.Join(root, ".testdrive.yml"), data, 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Warn.VersionMismatch {
+		t.Fatalf("expected explicit false to override default")
+	}
+
+	ApplyFlags(&cfg, FlagValues{Format: StringFlag{Value: "pretty", Set: true}})
+
+	cases := map[string]Source{
+		"format":                SourceFlag,
+		"jobs":                  SourceFile,
+		"warn.version_mismatch": SourceFile,
+		"provider":              SourceDefault,
+	}
+	for key, want := range cases {
+		if got := cfg.Origins.Of(key); got != want {
+			t.Fatalf("origin of %q = %q, want %q", key, got, want)
+		}
+	}
+	if cfg.Format != "pretty" {
+		t.Fatalf("expected flag to win, got %q", cfg.Format)
+	}
+}
+
+func TestLoadRequiredEnvForms(t *testing.T) {
+	root := t.TempDir()
+	data := []byte(`required_env:
+  - DATABASE_URL
+  - job: deploy
+    keys: [STRIPE_TEST_KEY, NPM_TOKEN]
+`)
+	if err := os.WriteFile(filepath.Join(root, ".testdrive.yml"), data, 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []RequiredEnv{
+		{Keys: []string{"DATABASE_URL"}},
+		{Job: "deploy", Keys: []string{"STRIPE_TEST_KEY", "NPM_TOKEN"}},
+	}
+	if !reflect.DeepEqual(cfg.RequiredEnv, want) {
+		t.Fatalf("required_env = %+v, want %+v", cfg.RequiredEnv, want)
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	if got := FormatsFor("list"); !reflect.DeepEqual(got, []string{FormatPretty, FormatJSON, FormatMarkdown, FormatCompletion}) {
+		t.Fatalf("FormatsFor(list) = %v", got)
+	}
+	if got := FormatsFor("run"); !reflect.DeepEqual(got, []string{FormatPretty, FormatJSON}) {
+		t.Fatalf("FormatsFor(run) = %v", got)
+	}
+	for _, ok := range []struct{ command, format string }{{"run", "json"}, {"run", "Pretty"}, {"list", "markdown"}} {
+		if err := CheckFormat(ok.command, ok.format); err != nil {
+			t.Fatalf("CheckFormat(%s, %s): %v", ok.command, ok.format, err)
+		}
+	}
+	if err := CheckFormat("run", "markdown"); err == nil || err.Error() != `unsupported format "markdown" for run; use pretty, json` {
+		t.Fatalf("expected markdown to be rejected for run, got %v", err)
+	}
+	if err := CheckFormat("list", "josn"); err == nil || err.Error() != `unsupported format "josn" for list; use pretty, json, markdown, completion` {
+		t.Fatalf("expected a typo to be rejected, got %v", err)
+	}
+}
+
+func TestStallDurations(t *testing.T) {
+	cfg := Default()
+	warnAfter, timeout, err := StallDurations(cfg)
+	if err != nil || warnAfter != 2*time.Minute || timeout != 0 {
+		t.Fatalf("StallDurations(Default()) = %s, %s, %v; want 2m, 0", warnAfter, timeout, err)
+	}
+	cfg.StallWarningAfter, cfg.StallTimeout = "", " 15m "
+	if warnAfter, timeout, err = StallDurations(cfg); err != nil || warnAfter != 0 || timeout != 15*time.Minute {
+		t.Fatalf("StallDurations = %s, %s, %v; want 0, 15m", warnAfter, timeout, err)
+	}
+	cfg.StallTimeout = "15"
+	if _, _, err := StallDurations(cfg); err == nil || err.Error() != `stall_timeout: invalid duration "15" (use a duration such as 2m or 1h30m, or leave it empty)` {
+		t.Fatalf("expected a duration without a unit to be rejected, got %v", err)
+	}
+}
+
+func TestCheckRedactEnvPatterns(t *testing.T) {
+	if err := CheckRedactEnvPatterns(Default().RedactEnvPatterns); err != nil {
+		t.Fatalf("default patterns rejected: %v", err)
+	}
+	if err := CheckRedactEnvPatterns([]string{"*_TOKEN", "[A-"}); err == nil || err.Error() != `redact_env_patterns: invalid pattern "[A-" (use a glob such as *_TOKEN)` {
+		t.Fatalf("expected a malformed pattern to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is prepended to every environment variable that overrides a config key.
+const EnvPrefix = "TESTDRIVE_"
+
+// EnvName returns the environment variable that overrides the dotted config
+// key, e.g. "warn.version_mismatch" becomes TESTDRIVE_WARN_VERSION_MISMATCH.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// ApplyEnv overlays TESTDRIVE_* environment variables onto cfg. Variable names
+// are derived from the yaml struct tags so every config key is covered without
+// additional wiring. Slice values are comma separated; booleans must parse
+// with strconv.ParseBool.
+func ApplyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	if cfg.Origins == nil {
+		cfg.Origins = Origins{}
+	}
+	return applyEnvStruct(reflect.ValueOf(cfg).Elem(), "", cfg.Origins, lookup)
+}
+
+func applyEnvStruct(v reflect.Value, prefix string, origins Origins, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := yamlKey(field)
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			if err := applyEnvStruct(fv, key, origins, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		if !envSupported(fv.Type()) {
+			continue
+		}
+
+		name := EnvName(key)
+		raw, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(fv, raw); err != nil {
+			return fmt.Errorf("parse %s: %w", name, err)
+		}
+		origins.set(key, SourceEnv)
+	}
+	return nil
+}
+
+func yamlKey(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	tag := field.Tag.Get("yaml")
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "-" || name == "" {
+		return ""
+	}
+	return name
+}
+
+// envSupported reports whether a field type has a flat string representation.
+// Structured keys such as overrides can only be set from the config file.
+func envSupported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	default:
+		return false
+	}
+}
+
+func setFromEnv(fv reflect.Value, raw string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(strings.TrimSpace(raw))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		fv.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		fv.SetInt(int64(n))
+	case reflect.Slice:
+		fv.Set(reflect.ValueOf(splitList(raw)))
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+func splitList(raw string) []string {
+	parts := strings.Split(raw, ",")
+	out := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
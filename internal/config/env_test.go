@@ -0,0 +1,86 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyEnvFieldKinds(t *testing.T) {
+	env := map[string]string{
+		"TESTDRIVE_FORMAT":                "json",
+		"TESTDRIVE_DRY_RUN":               "1",
+		"TESTDRIVE_TAIL_LINES":            "50",
+		"TESTDRIVE_JOBS":                  "test, lint,,",
+		"TESTDRIVE_WARN_VERSION_MISMATCH": "false",
+	}
+	cfg := Default()
+	if err := ApplyEnv(&cfg, lookupMap(env)); err != nil {
+		t.Fatalf("ApplyEnv: %v", err)
+	}
+
+	if cfg.Format != "json" {
+		t.Fatalf("format = %q", cfg.Format)
+	}
+	if !cfg.DryRun {
+		t.Fatalf("expected dry run enabled")
+	}
+	if cfg.TailLines != 50 {
+		t.Fatalf("tail lines = %d", cfg.TailLines)
+	}
+	if want := []string{"test", "lint"}; !reflect.DeepEqual(cfg.Jobs, want) {
+		t.Fatalf("jobs = %v, want %v", cfg.Jobs, want)
+	}
+	if cfg.Warn.VersionMismatch {
+		t.Fatalf("expected nested bool to be overridden")
+	}
+	for _, key := range []string{"format", "dry_run", "tail_lines", "jobs", "warn.version_mismatch"} {
+		if got := cfg.Origins.Of(key); got != SourceEnv {
+			t.Fatalf("origin of %q = %q, want env", key, got)
+		}
+	}
+	if got := cfg.Origins.Of("provider"); got != SourceDefault {
+		t.Fatalf("origin of provider = %q, want default", got)
+	}
+}
+
+func TestApplyEnvRejectsJunk(t *testing.T) {
+	cases := map[string]string{
+		"TESTDRIVE_DRY_RUN":    "sometimes",
+		"TESTDRIVE_TAIL_LINES": "lots",
+	}
+	for name, value := range cases {
+		cfg := Default()
+		err := ApplyEnv(&cfg, lookupMap(map[string]string{name: value}))
+		if err == nil {
+			t.Fatalf("expected error for %s=%q", name, value)
+		}
+	}
+}
+
+func TestApplyEnvPrecedence(t *testing.T) {
+	cfg := Default()
+	if err := ApplyEnv(&cfg, lookupMap(map[string]string{"TESTDRIVE_FORMAT": "json", "TESTDRIVE_VERBOSE": "true"})); err != nil {
+		t.Fatalf("ApplyEnv: %v", err)
+	}
+	ApplyFlags(&cfg, FlagValues{Format: StringFlag{Value: "pretty", Set: true}})
+
+	if cfg.Format != "pretty" || cfg.Origins.Of("format") != SourceFlag {
+		t.Fatalf("expected flag to win over env, got %q from %q", cfg.Format, cfg.Origins.Of("format"))
+	}
+	if !cfg.Verbose || cfg.Origins.Of("verbose") != SourceEnv {
+		t.Fatalf("expected env value to survive when flag unset")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := EnvName("warn.version_mismatch"); got != "TESTDRIVE_WARN_VERSION_MISMATCH" {
+		t.Fatalf("EnvName = %q", got)
+	}
+}
+
+func lookupMap(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
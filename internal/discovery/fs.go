@@ -4,56 +4,74 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+
+	"github.com/bgricker/testdrive/internal/suggest"
 )
 
 // ErrNoWorkflows indicates that no workflow files were found during discovery.
 var ErrNoWorkflows = errors.New("no workflows discovered")
 
+// DefaultDirs returns the directories searched for workflows when none are
+// configured, in precedence order. Gitea and Forgejo run GitHub-compatible
+// workflows from their own directories.
+func DefaultDirs() []string {
+	return []string{".github/workflows", ".gitea/workflows", ".forgejo/workflows"}
+}
+
 // Workflows returns workflow file paths. If explicit paths are provided they are
-// validated and returned in the order given. Otherwise the default GitHub
-// Actions workflow glob is used and results are sorted lexicographically.
+// validated and returned in the order given. Otherwise the DefaultDirs are
+// searched as WorkflowsIn does.
 func Workflows(root string, explicit []string) ([]string, error) {
 	if len(explicit) > 0 {
 		return resolveExplicit(root, explicit)
 	}
+	return WorkflowsIn(root, nil)
+}
 
-	ymlGlob := filepath.Join(root, ".github", "workflows", "*.yml")
-	yamlGlob := filepath.Join(root, ".github", "workflows", "*.yaml")
-
-	matches := make(map[string]struct{})
+// WorkflowsIn returns the *.yml and *.yaml files in each of dirs, relative to
+// root unless absolute. Files are grouped by directory in the order given
+// and sorted lexicographically within each, and each path keeps its
+// directory so workflows from different forges stay distinguishable. Empty
+// dirs means DefaultDirs; directories that do not exist are skipped.
+func WorkflowsIn(root string, dirs []string) ([]string, error) {
+	if len(dirs) == 0 {
+		dirs = DefaultDirs()
+	}
 
-	addMatches := func(pattern string) error {
-		found, err := filepath.Glob(pattern)
-		if err != nil {
-			return fmt.Errorf("glob %q: %w", pattern, err)
+	seen := make(map[string]struct{})
+	var paths []string
+	for _, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, filepath.FromSlash(dir))
 		}
-		for _, m := range found {
-			matches[m] = struct{}{}
+		var found []string
+		for _, ext := range []string{"*.yml", "*.yaml"} {
+			pattern := filepath.Join(dir, ext)
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("glob %q: %w", pattern, err)
+			}
+			for _, m := range matches {
+				rel := mustRelOrClean(root, m)
+				if _, ok := seen[rel]; ok {
+					continue
+				}
+				seen[rel] = struct{}{}
+				found = append(found, rel)
+			}
 		}
-		return nil
+		sort.Strings(found)
+		paths = append(paths, found...)
 	}
 
-	if err := addMatches(ymlGlob); err != nil {
-		return nil, err
-	}
-	if err := addMatches(yamlGlob); err != nil {
-		return nil, err
-	}
-
-	if len(matches) == 0 {
+	if len(paths) == 0 {
 		return nil, ErrNoWorkflows
 	}
-
-	paths := make([]string, 0, len(matches))
-	for p := range matches {
-		rel := mustRelOrClean(root, p)
-		paths = append(paths, rel)
-	}
-	sort.Strings(paths)
-
 	return paths, nil
 }
 
@@ -68,7 +86,7 @@ func resolveExplicit(root string, explicit []string) ([]string, error) {
 		info, err := os.Stat(cleaned)
 		if err != nil {
 			if errors.Is(err, os.ErrNotExist) {
-				return nil, fmt.Errorf("workflow %q not found", input)
+				return nil, fmt.Errorf("workflow %q not found%s", input, didYouMean(root, input))
 			}
 			return nil, fmt.Errorf("stat %q: %w", input, err)
 		}
@@ -88,6 +106,33 @@ func resolveExplicit(root string, explicit []string) ([]string, error) {
 	return resolved, nil
 }
 
+// didYouMean returns "; did you mean ...?" naming the discovered workflows
+// whose file names are closest to that of a path that does not exist, or ""
+// when none is close.
+func didYouMean(root, input string) string {
+	paths, err := WorkflowsIn(root, nil)
+	if err != nil {
+		return ""
+	}
+	bases := make([]string, len(paths))
+	for i, p := range paths {
+		bases[i] = filepath.Base(p)
+	}
+	closest := suggest.Closest(filepath.Base(input), bases)
+	var quoted []string
+	for _, base := range closest {
+		for _, p := range paths {
+			if filepath.Base(p) == base {
+				quoted = append(quoted, fmt.Sprintf("%q", filepath.ToSlash(p)))
+			}
+		}
+	}
+	if len(quoted) == 0 {
+		return ""
+	}
+	return "; did you mean " + strings.Join(quoted, " or ") + "?"
+}
+
 func mustRelOrClean(root, path string) string {
 	rel, err := filepath.Rel(root, path)
 	if err != nil {
@@ -99,3 +144,67 @@ func mustRelOrClean(root, path string) string {
 	}
 	return rel
 }
+
+// Exclude drops discovered workflow paths matching any of the patterns. A
+// pattern wrapped in slashes is a regular expression matched against the
+// slash-separated path; anything else is a glob matched against both the
+// path and the file name. Excluded paths are returned separately so callers
+// can report them.
+func Exclude(paths []string, patterns []string) (kept, excluded []string, err error) {
+	if len(patterns) == 0 {
+		return paths, nil, nil
+	}
+	matchers := make([]func(string) bool, 0, len(patterns))
+	for _, raw := range patterns {
+		m, err := compileExclusion(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		if m != nil {
+			matchers = append(matchers, m)
+		}
+	}
+
+	kept = make([]string, 0, len(paths))
+	for _, p := range paths {
+		slashed := filepath.ToSlash(p)
+		drop := false
+		for _, m := range matchers {
+			if m(slashed) {
+				drop = true
+				break
+			}
+		}
+		if drop {
+			excluded = append(excluded, p)
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, excluded, nil
+}
+
+func compileExclusion(raw string) (func(string) bool, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	if len(raw) >= 2 && strings.HasPrefix(raw, "/") && strings.HasSuffix(raw, "/") {
+		re, err := regexp.Compile(raw[1 : len(raw)-1])
+		if err != nil {
+			return nil, fmt.Errorf("compile workflow exclusion %q: %w", raw, err)
+		}
+		return re.MatchString, nil
+	}
+	glob := filepath.ToSlash(raw)
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid workflow exclusion %q: %w", raw, err)
+	}
+	return func(p string) bool {
+		if ok, _ := path.Match(glob, p); ok {
+			return true
+		}
+		ok, _ := path.Match(glob, path.Base(p))
+		return ok
+	}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -43,6 +44,87 @@ func TestWorkflowsAuto(t *testing.T) {
 	}
 }
 
+// forgeRepo creates root with the given slash-separated workflow files.
+func forgeRepo(t *testing.T, files ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, f := range files {
+		path := filepath.Join(root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		writeFile(t, path)
+	}
+	return root
+}
+
+func TestWorkflowsInDefaultDirs(t *testing.T) {
+	cases := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{
+			name:  "gitea only",
+			files: []string{".gitea/workflows/ci.yml"},
+			want:  []string{".gitea/workflows/ci.yml"},
+		},
+		{
+			name:  "forgejo only",
+			files: []string{".forgejo/workflows/test.yaml", ".forgejo/workflows/build.yml"},
+			want:  []string{".forgejo/workflows/build.yml", ".forgejo/workflows/test.yaml"},
+		},
+		{
+			name: "every forge in precedence order",
+			files: []string{
+				".forgejo/workflows/a.yml",
+				".gitea/workflows/ci.yml",
+				".github/workflows/z.yml",
+				".github/workflows/ci.yml",
+			},
+			want: []string{
+				".github/workflows/ci.yml",
+				".github/workflows/z.yml",
+				".gitea/workflows/ci.yml",
+				".forgejo/workflows/a.yml",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := forgeRepo(t, tc.files...)
+			got, err := Workflows(root, nil)
+			if err != nil {
+				t.Fatalf("Workflows returned error: %v", err)
+			}
+			want := make([]string, len(tc.want))
+			for i, p := range tc.want {
+				want[i] = filepath.FromSlash(p)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWorkflowsInConfiguredDirs(t *testing.T) {
+	root := forgeRepo(t, ".github/workflows/ci.yml", ".forgejo/workflows/ci.yml", "ci/pipelines/lint.yml")
+
+	got, err := WorkflowsIn(root, []string{".forgejo/workflows", "ci/pipelines", ".forgejo/workflows/"})
+	if err != nil {
+		t.Fatalf("WorkflowsIn returned error: %v", err)
+	}
+	want := []string{filepath.FromSlash(".forgejo/workflows/ci.yml"), filepath.FromSlash("ci/pipelines/lint.yml")}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	if _, err := WorkflowsIn(root, []string{"missing"}); !errors.Is(err, ErrNoWorkflows) {
+		t.Fatalf("expected ErrNoWorkflows for a missing directory, got %v", err)
+	}
+}
+
 func TestWorkflowsExplicit(t *testing.T) {
 	root := t.TempDir()
 	file := filepath.Join(root, "workflow.yml")
@@ -88,9 +170,70 @@ func TestWorkflowsErrors(t *testing.T) {
 	}
 }
 
+func TestWorkflowsMissingSuggests(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, ".github", "workflows")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeFile(t, filepath.Join(dir, "ci.yml"))
+	writeFile(t, filepath.Join(dir, "release.yml"))
+
+	_, err := Workflows(root, []string{".github/workflows/relase.yml"})
+	want := `workflow ".github/workflows/relase.yml" not found; did you mean ".github/workflows/release.yml"?`
+	if err == nil || err.Error() != want {
+		t.Fatalf("error = %v, want %s", err, want)
+	}
+
+	_, err = Workflows(root, []string{"deploy.yml"})
+	if want := `workflow "deploy.yml" not found`; err == nil || err.Error() != want {
+		t.Fatalf("error = %v, want %s", err, want)
+	}
+}
+
 func writeFile(t *testing.T, path string) {
 	t.Helper()
 	if err := os.WriteFile(path, []byte("name: test"), 0o644); err != nil {
 		t.Fatalf("write file %s: %v", path, err)
 	}
 }
+
+func TestExcludeGlob(t *testing.T) {
+	paths := []string{
+		".github/workflows/ci.yml",
+		".github/workflows/release.yml",
+		".github/workflows/stale.yaml",
+	}
+	kept, excluded, err := Exclude(paths, []string{"release.yml", ".github/workflows/stale.*"})
+	if err != nil {
+		t.Fatalf("Exclude returned error: %v", err)
+	}
+	if len(kept) != 1 || kept[0] != ".github/workflows/ci.yml" {
+		t.Fatalf("unexpected kept paths: %v", kept)
+	}
+	if len(excluded) != 2 {
+		t.Fatalf("expected 2 excluded paths, got %v", excluded)
+	}
+}
+
+func TestExcludeRegex(t *testing.T) {
+	paths := []string{
+		".github/workflows/ci.yml",
+		".github/workflows/label-sync.yml",
+		".github/workflows/labeler.yml",
+	}
+	kept, excluded, err := Exclude(paths, []string{"/label/"})
+	if err != nil {
+		t.Fatalf("Exclude returned error: %v", err)
+	}
+	if len(kept) != 1 || len(excluded) != 2 {
+		t.Fatalf("unexpected split kept=%v excluded=%v", kept, excluded)
+	}
+
+	if _, _, err := Exclude(paths, []string{"/[/"}); err == nil {
+		t.Fatalf("expected invalid regex error")
+	}
+	if _, _, err := Exclude(paths, []string{"[x"}); err == nil {
+		t.Fatalf("expected invalid glob error")
+	}
+}
@@ -0,0 +1,150 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// maxRemoteSize caps how much of a fetched workflow is read; real workflow
+// files are a few kilobytes.
+const maxRemoteSize = 4 << 20
+
+// Remote is workflow content that does not live in the working tree: a file
+// at another git ref or a download. Display names it in output and errors,
+// e.g. "origin/feature:.github/workflows/ci.yml".
+type Remote struct {
+	Display string
+	Content []byte
+}
+
+// SplitRef splits a "ref:path" spec such as
+// "origin/feature:.github/workflows/ci.yml".
+func SplitRef(spec string) (ref, file string, err error) {
+	ref, file, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok || ref == "" || file == "" {
+		return "", "", fmt.Errorf("workflow ref %q: expected REF:PATH, e.g. origin/main:.github/workflows/ci.yml", spec)
+	}
+	return ref, file, nil
+}
+
+// FromRef reads a workflow from another git ref with `git show REF:PATH`,
+// run in root. Paths are relative to the repository top level, as in git;
+// prefix them with ./ to resolve against root instead.
+func FromRef(root, spec string) (Remote, error) {
+	ref, file, err := SplitRef(spec)
+	if err != nil {
+		return Remote{}, err
+	}
+	cmd := exec.Command("git", "-C", root, "show", ref+":"+file)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return Remote{}, fmt.Errorf("workflow ref %q: git show: %w", spec, err)
+		}
+		return Remote{}, fmt.Errorf("workflow ref %q: git show: %s", spec, msg)
+	}
+	if stdout.Len() == 0 {
+		return Remote{}, fmt.Errorf("workflow ref %q is empty", spec)
+	}
+	return Remote{Display: ref + ":" + file, Content: stdout.Bytes()}, nil
+}
+
+// Fetcher downloads workflows over HTTP. Every call goes to the network;
+// responses are never cached so a re-run always sees the current content.
+type Fetcher struct {
+	HTTP *http.Client
+}
+
+// FromURL downloads a workflow from an http or https URL. The display path
+// is the URL without credentials, query, or fragment, which may carry tokens.
+func (f *Fetcher) FromURL(ctx context.Context, rawURL string) (Remote, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return Remote{}, fmt.Errorf("workflow url %q: %w", rawURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Remote{}, fmt.Errorf("workflow url %q: only http and https URLs are supported", rawURL)
+	}
+	display := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path, RawPath: u.RawPath}).String()
+
+	httpClient := f.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Remote{}, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return Remote{}, fmt.Errorf("fetch workflow %s: %w", display, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Remote{}, fmt.Errorf("fetch workflow %s: %s", display, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteSize+1))
+	if err != nil {
+		return Remote{}, fmt.Errorf("read workflow %s: %w", display, err)
+	}
+	if len(body) > maxRemoteSize {
+		return Remote{}, fmt.Errorf("fetch workflow %s: larger than %d bytes", display, maxRemoteSize)
+	}
+	if len(body) == 0 {
+		return Remote{}, fmt.Errorf("fetch workflow %s: empty response", display)
+	}
+	return Remote{Display: display, Content: body}, nil
+}
+
+// SplitSpecs sorts workflow specs into local files, "ref:path" specs, and
+// URLs. A spec containing a colon is only treated as a ref when no local
+// file by that name exists, so oddly named files keep working.
+func SplitSpecs(root string, specs []string) (local, refs, urls []string) {
+	for _, spec := range specs {
+		switch {
+		case IsURL(spec):
+			urls = append(urls, spec)
+		case isRefSpec(root, spec):
+			refs = append(refs, spec)
+		default:
+			local = append(local, spec)
+		}
+	}
+	return local, refs, urls
+}
+
+// IsURL reports whether spec looks like an http or https workflow URL.
+func IsURL(spec string) bool {
+	lower := strings.ToLower(strings.TrimSpace(spec))
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func isRefSpec(root, spec string) bool {
+	if !strings.Contains(spec, ":") || filepath.VolumeName(spec) != "" {
+		return false
+	}
+	full := spec
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(root, spec)
+	}
+	_, err := os.Stat(full)
+	return errors.Is(err, os.ErrNotExist)
+}
@@ -0,0 +1,133 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFromRef(t *testing.T) {
+	repo := gitRepo(t)
+	path := filepath.Join(repo, ".github", "workflows", "ci.yml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("name: old\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "old")
+	runGit(t, repo, "branch", "feature")
+	if err := os.WriteFile(path, []byte("name: new\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	remote, err := FromRef(repo, "feature:.github/workflows/ci.yml")
+	if err != nil {
+		t.Fatalf("FromRef: %v", err)
+	}
+	if remote.Display != "feature:.github/workflows/ci.yml" {
+		t.Fatalf("unexpected display path %q", remote.Display)
+	}
+	if string(remote.Content) != "name: old\n" {
+		t.Fatalf("expected committed content, got %q", remote.Content)
+	}
+
+	cases := map[string]string{
+		"feature":                          "expected REF:PATH",
+		"missing:.github/workflows/ci.yml": "git show",
+		"feature:nope.yml":                 "nope.yml",
+	}
+	for spec, want := range cases {
+		_, err := FromRef(repo, spec)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("FromRef(%q): expected error containing %q, got %v", spec, want, err)
+		}
+	}
+}
+
+func TestFetcherFromURL(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ci.yml" {
+			http.NotFound(w, r)
+			return
+		}
+		hits++
+		if r.Header.Get("Cache-Control") != "no-cache" {
+			t.Errorf("expected a no-cache request, got %q", r.Header.Get("Cache-Control"))
+		}
+		w.Write([]byte("name: remote\n"))
+	}))
+	defer srv.Close()
+
+	f := &Fetcher{HTTP: srv.Client()}
+	for i := 0; i < 2; i++ {
+		remote, err := f.FromURL(context.Background(), srv.URL+"/ci.yml?token=secret")
+		if err != nil {
+			t.Fatalf("FromURL: %v", err)
+		}
+		if remote.Display != srv.URL+"/ci.yml" {
+			t.Fatalf("expected query to be dropped from display path, got %q", remote.Display)
+		}
+		if string(remote.Content) != "name: remote\n" {
+			t.Fatalf("unexpected content %q", remote.Content)
+		}
+	}
+	if hits != 2 {
+		t.Fatalf("expected every fetch to reach the server, got %d requests", hits)
+	}
+
+	if _, err := f.FromURL(context.Background(), srv.URL+"/missing.yml"); err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Fatalf("expected a 404 error, got %v", err)
+	}
+	if _, err := f.FromURL(context.Background(), "ftp://example.com/ci.yml"); err == nil || !strings.Contains(err.Error(), "only http and https") {
+		t.Fatalf("expected a scheme error, got %v", err)
+	}
+}
+
+func TestSplitSpecs(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "odd:name.yml"), []byte("name: odd\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	local, refs, urls := SplitSpecs(root, []string{
+		"ci.yml",
+		"origin/main:.github/workflows/ci.yml",
+		"odd:name.yml",
+		"https://example.com/ci.yml",
+	})
+	if strings.Join(local, ",") != "ci.yml,odd:name.yml" {
+		t.Fatalf("unexpected local specs %v", local)
+	}
+	if strings.Join(refs, ",") != "origin/main:.github/workflows/ci.yml" {
+		t.Fatalf("unexpected ref specs %v", refs)
+	}
+	if strings.Join(urls, ",") != "https://example.com/ci.yml" {
+		t.Fatalf("unexpected url specs %v", urls)
+	}
+}
+
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	runGit(t, repo, "init", "-q")
+	return repo
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
@@ -0,0 +1,110 @@
+package envcheck
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReadFile parses a dotenv-style file: one KEY=VALUE per line, with blank
+// lines and # comments ignored. An optional "export " prefix is accepted and
+// values wrapped in matching single or double quotes are unquoted.
+func ReadFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read env file %q: %w", path, err)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+		key, value, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !validName(key) {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, line)
+		}
+		values[key] = unquote(strings.TrimSpace(value))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read env file %q: %w", path, err)
+	}
+	return values, nil
+}
+
+// dotenvLine matches a KEY=VALUE line, which ReadVarsFile uses to tell a
+// dotenv-style file from YAML.
+var dotenvLine = regexp.MustCompile(`^(?:export\s+)?[A-Za-z_][A-Za-z0-9_]*\s*=`)
+
+// ReadVarsFile parses a repository variables file. It is read as KEY=VALUE
+// lines, like ReadFile, when its first entry looks like one, and otherwise
+// as a YAML map of names to scalar values.
+func ReadVarsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read vars file %q: %w", path, err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if dotenvLine.MatchString(line) {
+			return ReadFile(path)
+		}
+		break
+	}
+
+	var doc map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: expected KEY=VALUE lines or a YAML map: %w", path, err)
+	}
+	values := make(map[string]string, len(doc))
+	for key, node := range doc {
+		if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("%s:%d: %s must be a scalar value", path, node.Line, key)
+		}
+		if node.Tag == "!!null" {
+			values[key] = ""
+			continue
+		}
+		values[key] = node.Value
+	}
+	return values, nil
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if first == last && (first == '"' || first == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package envcheck
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	data := `# local secrets
+STRIPE_TEST_KEY=sk_test_123
+
+export DATABASE_URL="postgres://localhost/app"
+GREETING='hello world'
+EMPTY=
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	want := map[string]string{
+		"STRIPE_TEST_KEY": "sk_test_123",
+		"DATABASE_URL":    "postgres://localhost/app",
+		"GREETING":        "hello world",
+		"EMPTY":           "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadFile = %v, want %v", got, want)
+	}
+}
+
+func TestReadFileRejectsMalformedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("OK=1\nnot a pair\n"), 0o644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	_, err := ReadFile(path)
+	if err == nil || !strings.Contains(err.Error(), ":2: expected KEY=VALUE") {
+		t.Fatalf("expected line 2 error, got %v", err)
+	}
+}
+
+func TestReadVarsFile(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name string
+		data string
+		want map[string]string
+	}{
+		{
+			name: "vars.env",
+			data: "# repository variables\nRAILS_MAX_THREADS=5\nexport REGION='eu-west-1'\n",
+			want: map[string]string{"RAILS_MAX_THREADS": "5", "REGION": "eu-west-1"},
+		},
+		{
+			name: "vars.yml",
+			data: "# repository variables\nRAILS_MAX_THREADS: 5\nREGION: eu-west-1\nFEATURE: true\nEMPTY:\n",
+			want: map[string]string{"RAILS_MAX_THREADS": "5", "REGION": "eu-west-1", "FEATURE": "true", "EMPTY": ""},
+		},
+		{
+			name: "empty.yml",
+			data: "",
+			want: map[string]string{},
+		},
+	}
+	for _, tc := range cases {
+		path := filepath.Join(dir, tc.name)
+		if err := os.WriteFile(path, []byte(tc.data), 0o644); err != nil {
+			t.Fatalf("write %s: %v", tc.name, err)
+		}
+		got, err := ReadVarsFile(path)
+		if err != nil {
+			t.Fatalf("ReadVarsFile(%s): %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("ReadVarsFile(%s) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	path := filepath.Join(dir, "nested.yml")
+	if err := os.WriteFile(path, []byte("OK: 1\nNESTED:\n  a: b\n"), 0o644); err != nil {
+		t.Fatalf("write nested.yml: %v", err)
+	}
+	if _, err := ReadVarsFile(path); err == nil || !strings.Contains(err.Error(), ":3: NESTED must be a scalar value") {
+		t.Fatalf("expected nested value error, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package envcheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/provider/filter"
+)
+
+// Lookup reports the local value of an environment variable.
+type Lookup func(key string) (string, bool)
+
+// Missing is a required variable that is unset or empty. Jobs lists the
+// selected jobs that asked for it; it is empty for unscoped requirements.
+type Missing struct {
+	Key  string
+	Jobs []string
+}
+
+// CheckRequired returns the required variables that lookup cannot supply, in
+// config order. Scoped requirements only apply when one of the given jobs
+// matches their pattern, so filtering jobs out also drops their requirements.
+func CheckRequired(reqs []config.RequiredEnv, workflows []provider.Workflow, lookup Lookup) ([]Missing, error) {
+	var missing []Missing
+	index := make(map[string]int)
+	for i, req := range reqs {
+		var jobs []string
+		if strings.TrimSpace(req.Job) != "" {
+			patterns, err := filter.Compile([]string{req.Job})
+			if err != nil {
+				return nil, fmt.Errorf("required_env[%d]: %w", i, err)
+			}
+			jobs = matchingJobs(workflows, patterns[0])
+			if len(jobs) == 0 {
+				continue
+			}
+		}
+		for _, key := range req.Keys {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				continue
+			}
+			if value, ok := lookup(key); ok && value != "" {
+				continue
+			}
+			if at, seen := index[key]; seen {
+				// An unscoped requirement wins over any job list.
+				if len(jobs) == 0 || len(missing[at].Jobs) == 0 {
+					missing[at].Jobs = nil
+				} else {
+					missing[at].Jobs = appendUnique(missing[at].Jobs, jobs...)
+				}
+				continue
+			}
+			index[key] = len(missing)
+			missing = append(missing, Missing{Key: key, Jobs: jobs})
+		}
+	}
+	return missing, nil
+}
+
+func matchingJobs(workflows []provider.Workflow, pattern filter.Pattern) []string {
+	var jobs []string
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			if pattern.Match(job.Name) || pattern.Match(job.RawID) {
+				jobs = appendUnique(jobs, job.RawID)
+			}
+		}
+	}
+	return jobs
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, existing := range list {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
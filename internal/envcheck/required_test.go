@@ -0,0 +1,53 @@
+package envcheck
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/config"
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func lookupFrom(values map[string]string) Lookup {
+	return func(key string) (string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+}
+
+func TestCheckRequired(t *testing.T) {
+	workflows := []provider.Workflow{{
+		Path: "ci.yml",
+		Jobs: []provider.Job{
+			{RawID: "test", Name: "Unit Tests"},
+			{RawID: "deploy", Name: "Deploy"},
+		},
+	}}
+	reqs := []config.RequiredEnv{
+		{Keys: []string{"DATABASE_URL", "PRESENT"}},
+		{Job: "deploy", Keys: []string{"STRIPE_TEST_KEY", "EMPTY"}},
+		{Job: "lint", Keys: []string{"NEVER_CHECKED"}},
+		{Job: "/^(test|deploy)$/", Keys: []string{"STRIPE_TEST_KEY"}},
+	}
+	lookup := lookupFrom(map[string]string{"PRESENT": "1", "EMPTY": ""})
+
+	got, err := CheckRequired(reqs, workflows, lookup)
+	if err != nil {
+		t.Fatalf("CheckRequired: %v", err)
+	}
+	want := []Missing{
+		{Key: "DATABASE_URL"},
+		{Key: "STRIPE_TEST_KEY", Jobs: []string{"deploy", "test"}},
+		{Key: "EMPTY", Jobs: []string{"deploy"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("CheckRequired = %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckRequiredInvalidPattern(t *testing.T) {
+	_, err := CheckRequired([]config.RequiredEnv{{Job: "/(/", Keys: []string{"X"}}}, nil, lookupFrom(nil))
+	if err == nil {
+		t.Fatalf("expected pattern error")
+	}
+}
@@ -0,0 +1,122 @@
+package envcheck
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// Finding lists the references in one job that may not resolve locally.
+// Names are "$VAR" for shell variables and "secrets.NAME" for secrets.
+type Finding struct {
+	Workflow string
+	Job      string
+	Names    []string
+}
+
+var (
+	secretRef     = regexp.MustCompile(`\$\{\{\s*secrets\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	expressionRef = regexp.MustCompile(`\$\{\{.*?\}\}`)
+	// Only upper-case names are treated as environment references; lower
+	// case is overwhelmingly used for script locals.
+	shellRef   = regexp.MustCompile(`\$(?:\{([A-Z_][A-Z0-9_]*)(:?[-=+?])?|([A-Z_][A-Z0-9_]*))`)
+	assignment = regexp.MustCompile(`(?:^|[\s;&|(])(?:export\s+|local\s+|readonly\s+|declare\s+(?:-\w+\s+)*)?([A-Za-z_][A-Za-z0-9_]*)\+?=`)
+	loopVar    = regexp.MustCompile(`\b(?:for|read(?:\s+-\w+)*)\s+([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// runnerProvided are variables the shell or an Actions runner always sets.
+var runnerProvided = map[string]bool{
+	"CI": true, "HOME": true, "PATH": true, "PWD": true, "OLDPWD": true,
+	"USER": true, "SHELL": true, "TMPDIR": true, "HOSTNAME": true, "IFS": true,
+	"RANDOM": true, "LINENO": true, "SECONDS": true, "UID": true, "EUID": true,
+	"PPID": true, "LANG": true, "TERM": true,
+}
+
+var runnerPrefixes = []string{"GITHUB_", "RUNNER_", "BASH"}
+
+// Scan is a best-effort static check of run scripts for secrets and
+// upper-case shell variables that lookup cannot supply. Variables defined by
+// the workflow, job, or step env, or assigned in the script itself, are not
+// reported. A workflow env entry whose value is a secret expression only
+// counts as defined when the secret is available locally under its own name.
+func Scan(workflows []provider.Workflow, lookup Lookup) []Finding {
+	var findings []Finding
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			names := make(map[string]bool)
+			for _, step := range job.Steps {
+				if step.Run == "" || step.Skip {
+					continue
+				}
+				scanStep(wf, job, step, lookup, names)
+			}
+			if len(names) == 0 {
+				continue
+			}
+			list := make([]string, 0, len(names))
+			for name := range names {
+				list = append(list, name)
+			}
+			sort.Strings(list)
+			findings = append(findings, Finding{Workflow: wf.Path, Job: job.RawID, Names: list})
+		}
+	}
+	return findings
+}
+
+func scanStep(wf provider.Workflow, job provider.Job, step provider.Step, lookup Lookup, names map[string]bool) {
+	available := func(key string) bool {
+		value, ok := lookup(key)
+		return ok && value != ""
+	}
+
+	defined := make(map[string]bool)
+	for _, env := range []map[string]string{wf.Env, job.Env, step.Env} {
+		for key, value := range env {
+			defined[key] = true
+			for _, m := range secretRef.FindAllStringSubmatch(value, -1) {
+				if !available(m[1]) {
+					names["secrets."+m[1]] = true
+				}
+			}
+		}
+	}
+	for _, m := range secretRef.FindAllStringSubmatch(step.Run, -1) {
+		if !available(m[1]) {
+			names["secrets."+m[1]] = true
+		}
+	}
+
+	script := expressionRef.ReplaceAllString(step.Run, "")
+	for _, m := range assignment.FindAllStringSubmatch(script, -1) {
+		defined[m[1]] = true
+	}
+	for _, m := range loopVar.FindAllStringSubmatch(script, -1) {
+		defined[m[1]] = true
+	}
+	for _, m := range shellRef.FindAllStringSubmatch(script, -1) {
+		name := m[1] + m[3]
+		if m[2] != "" {
+			// ${VAR:-default} and friends tolerate an unset variable.
+			continue
+		}
+		if defined[name] || providedByRunner(name) || available(name) {
+			continue
+		}
+		names["$"+name] = true
+	}
+}
+
+func providedByRunner(name string) bool {
+	if runnerProvided[name] {
+		return true
+	}
+	for _, prefix := range runnerPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
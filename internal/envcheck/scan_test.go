@@ -0,0 +1,44 @@
+package envcheck
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestScan(t *testing.T) {
+	workflows := []provider.Workflow{{
+		Path: "ci.yml",
+		Env:  map[string]string{"WF_VAR": "x", "NPM_TOKEN": "${{ secrets.NPM_TOKEN }}"},
+		Jobs: []provider.Job{
+			{
+				RawID: "test",
+				Steps: []provider.Step{
+					{Name: "uses", Uses: "actions/checkout@v4"},
+					{Name: "script", Run: `OUT=build
+export COUNT=3
+for FILE in *.go; do echo "$FILE"; done
+echo "$WF_VAR $OUT $COUNT ${HOME} $GITHUB_SHA $local_var ${OPTIONAL:-none}"
+curl -H "Authorization: $API_TOKEN" "${DATABASE_URL}/x" --data "${{ secrets.STRIPE_TEST_KEY }}"
+echo $LOCAL_ONLY`},
+				},
+			},
+			{
+				RawID: "clean",
+				Steps: []provider.Step{{Name: "ok", Run: "echo $WF_VAR"}},
+			},
+		},
+	}}
+	lookup := lookupFrom(map[string]string{"LOCAL_ONLY": "1", "HOME": ""})
+
+	got := Scan(workflows, lookup)
+	// Workflow env reaches every job, so its secret is reported for both.
+	want := []Finding{
+		{Workflow: "ci.yml", Job: "test", Names: []string{"$API_TOKEN", "$DATABASE_URL", "secrets.NPM_TOKEN", "secrets.STRIPE_TEST_KEY"}},
+		{Workflow: "ci.yml", Job: "clean", Names: []string{"secrets.NPM_TOKEN"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Scan = %+v, want %+v", got, want)
+	}
+}
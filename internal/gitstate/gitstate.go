@@ -0,0 +1,166 @@
+// Package gitstate compares the local checkout with what CI would build.
+package gitstate
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Git runs git subcommands in a directory and returns their trimmed stdout.
+// Tests substitute a scripted fake.
+type Git interface {
+	Run(dir string, args ...string) (string, error)
+}
+
+// ExecGit runs the git binary on PATH.
+type ExecGit struct{}
+
+// Run executes git -C dir args.
+func (ExecGit) Run(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+		}
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// stateDir is testdrive's own state directory in the project. A run writes
+// its lock and history there, so it must not count as a change to the
+// checkout.
+const stateDir = ".testdrive"
+
+// status lists the uncommitted changes in the checkout containing dir,
+// leaving out dir's state directory.
+func status(git Git, dir string) (string, error) {
+	return git.Run(dir, "status", "--porcelain", "--", ":(exclude)"+stateDir)
+}
+
+// State describes a checkout. Upstream is empty when the branch does not
+// track a remote branch, and Unborn is set when the branch has no commits.
+type State struct {
+	Branch      string
+	Detached    bool
+	Unborn      bool
+	Uncommitted int
+	Upstream    string
+	Ahead       int
+	Behind      int
+}
+
+// Probe inspects the checkout containing dir. ok is false when dir is not
+// inside a git work tree, in which case there is nothing to compare.
+func Probe(git Git, dir string) (state State, ok bool, err error) {
+	if inside, err := git.Run(dir, "rev-parse", "--is-inside-work-tree"); err != nil || inside != "true" {
+		return State{}, false, nil
+	}
+
+	changes, err := status(git, dir)
+	if err != nil {
+		return State{}, true, err
+	}
+	if changes != "" {
+		state.Uncommitted = len(strings.Split(changes, "\n"))
+	}
+
+	branch, err := git.Run(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		// HEAD cannot be resolved before the first commit, but it still
+		// names the branch that commit will start.
+		if unborn, symErr := git.Run(dir, "symbolic-ref", "--short", "HEAD"); symErr == nil && unborn != "" {
+			state.Branch = unborn
+			state.Unborn = true
+			return state, true, nil
+		}
+		return State{}, true, err
+	}
+	if branch == "HEAD" {
+		state.Detached = true
+		return state, true, nil
+	}
+	state.Branch = branch
+
+	// A branch without an upstream is not an error; it just has nothing to
+	// compare against.
+	upstream, err := git.Run(dir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	if err != nil {
+		return state, true, nil
+	}
+	state.Upstream = upstream
+	counts, err := git.Run(dir, "rev-list", "--left-right", "--count", "@{upstream}...HEAD")
+	if err != nil {
+		return State{}, true, err
+	}
+	fields := strings.Fields(counts)
+	if len(fields) != 2 {
+		return State{}, true, fmt.Errorf("git rev-list: unexpected output %q", counts)
+	}
+	if state.Behind, err = strconv.Atoi(fields[0]); err != nil {
+		return State{}, true, fmt.Errorf("git rev-list: unexpected output %q", counts)
+	}
+	if state.Ahead, err = strconv.Atoi(fields[1]); err != nil {
+		return State{}, true, fmt.Errorf("git rev-list: unexpected output %q", counts)
+	}
+	return state, true, nil
+}
+
+// Head names the commit a checkout is at. Branch is empty on a detached
+// HEAD, and Dirty is set when the working tree has uncommitted changes.
+type Head struct {
+	Branch string
+	SHA    string
+	Dirty  bool
+}
+
+// ProbeHead inspects the checkout containing dir. ok is false when dir is
+// not inside a git work tree or HEAD cannot be read, as in a repository
+// without commits; callers treat that as nothing to report.
+func ProbeHead(git Git, dir string) (head Head, ok bool) {
+	if inside, err := git.Run(dir, "rev-parse", "--is-inside-work-tree"); err != nil || inside != "true" {
+		return Head{}, false
+	}
+	sha, err := git.Run(dir, "rev-parse", "--short", "HEAD")
+	if err != nil || sha == "" {
+		return Head{}, false
+	}
+	head.SHA = sha
+	if branch, err := git.Run(dir, "rev-parse", "--abbrev-ref", "HEAD"); err == nil && branch != "HEAD" {
+		head.Branch = branch
+	}
+	if changes, err := status(git, dir); err == nil {
+		head.Dirty = changes != ""
+	}
+	return head, true
+}
+
+// Problems describes each way the checkout differs from what CI would build.
+func (s State) Problems() []string {
+	var problems []string
+	if s.Uncommitted > 0 {
+		problems = append(problems, fmt.Sprintf("working tree has %d uncommitted change(s); CI will build the committed state", s.Uncommitted))
+	}
+	switch {
+	case s.Unborn:
+		problems = append(problems, fmt.Sprintf("branch %s has no commits yet; CI only sees pushed commits", s.Branch))
+	case s.Detached:
+		problems = append(problems, "HEAD is detached; CI builds a branch or pull request")
+	case s.Upstream == "":
+		problems = append(problems, fmt.Sprintf("branch %s has no upstream; CI only sees pushed commits", s.Branch))
+	case s.Ahead > 0 && s.Behind > 0:
+		problems = append(problems, fmt.Sprintf("branch %s has diverged from %s (%d ahead, %d behind)", s.Branch, s.Upstream, s.Ahead, s.Behind))
+	case s.Ahead > 0:
+		problems = append(problems, fmt.Sprintf("branch %s is %d commit(s) ahead of %s; CI has not seen them", s.Branch, s.Ahead, s.Upstream))
+	case s.Behind > 0:
+		problems = append(problems, fmt.Sprintf("branch %s is %d commit(s) behind %s", s.Branch, s.Behind, s.Upstream))
+	}
+	return problems
+}
@@ -0,0 +1,148 @@
+package gitstate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// scriptedGit answers git commands from a table keyed by the joined args.
+// Commands missing from the table fail like git does outside a repository.
+type scriptedGit map[string]string
+
+func (s scriptedGit) Run(dir string, args ...string) (string, error) {
+	out, ok := s[strings.Join(args, " ")]
+	if !ok {
+		return "", errors.New("fatal: not a git repository")
+	}
+	return out, nil
+}
+
+// repo scripts a clean branch tracking origin. An empty override removes the
+// command so it fails, except for status where empty means clean.
+func repo(overrides map[string]string) scriptedGit {
+	git := scriptedGit{
+		"rev-parse --is-inside-work-tree":                         "true",
+		"status --porcelain -- :(exclude).testdrive":              "",
+		"rev-parse --abbrev-ref HEAD":                             "feature",
+		"rev-parse --abbrev-ref --symbolic-full-name @{upstream}": "origin/feature",
+		"rev-list --left-right --count @{upstream}...HEAD":        "0\t0",
+	}
+	for k, v := range overrides {
+		if v == "" && k != "status --porcelain -- :(exclude).testdrive" {
+			delete(git, k)
+			continue
+		}
+		git[k] = v
+	}
+	return git
+}
+
+func TestProbe(t *testing.T) {
+	cases := []struct {
+		name     string
+		git      scriptedGit
+		state    State
+		problems []string
+	}{
+		{
+			name:  "clean and pushed",
+			git:   repo(nil),
+			state: State{Branch: "feature", Upstream: "origin/feature"},
+		},
+		{
+			name:     "uncommitted changes",
+			git:      repo(map[string]string{"status --porcelain -- :(exclude).testdrive": " M main.go\n?? new.go"}),
+			state:    State{Branch: "feature", Upstream: "origin/feature", Uncommitted: 2},
+			problems: []string{"working tree has 2 uncommitted change(s); CI will build the committed state"},
+		},
+		{
+			name:     "detached",
+			git:      repo(map[string]string{"rev-parse --abbrev-ref HEAD": "HEAD"}),
+			state:    State{Detached: true},
+			problems: []string{"HEAD is detached; CI builds a branch or pull request"},
+		},
+		{
+			name:     "no upstream",
+			git:      repo(map[string]string{"rev-parse --abbrev-ref --symbolic-full-name @{upstream}": ""}),
+			state:    State{Branch: "feature"},
+			problems: []string{"branch feature has no upstream; CI only sees pushed commits"},
+		},
+		{
+			name: "no commits",
+			git: repo(map[string]string{
+				"status --porcelain -- :(exclude).testdrive": "?? main.go",
+				"rev-parse --abbrev-ref HEAD":                "",
+				"symbolic-ref --short HEAD":                  "main",
+			}),
+			state: State{Branch: "main", Unborn: true, Uncommitted: 1},
+			problems: []string{
+				"working tree has 1 uncommitted change(s); CI will build the committed state",
+				"branch main has no commits yet; CI only sees pushed commits",
+			},
+		},
+		{
+			name:     "ahead",
+			git:      repo(map[string]string{"rev-list --left-right --count @{upstream}...HEAD": "0\t3"}),
+			state:    State{Branch: "feature", Upstream: "origin/feature", Ahead: 3},
+			problems: []string{"branch feature is 3 commit(s) ahead of origin/feature; CI has not seen them"},
+		},
+		{
+			name:     "diverged",
+			git:      repo(map[string]string{"rev-list --left-right --count @{upstream}...HEAD": "2\t1"}),
+			state:    State{Branch: "feature", Upstream: "origin/feature", Ahead: 1, Behind: 2},
+			problems: []string{"branch feature has diverged from origin/feature (1 ahead, 2 behind)"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			state, ok, err := Probe(tc.git, ".")
+			if err != nil || !ok {
+				t.Fatalf("Probe: ok=%v err=%v", ok, err)
+			}
+			if state != tc.state {
+				t.Fatalf("state = %+v, want %+v", state, tc.state)
+			}
+			if got := state.Problems(); !reflect.DeepEqual(got, tc.problems) {
+				t.Fatalf("problems = %q, want %q", got, tc.problems)
+			}
+		})
+	}
+}
+
+func TestProbeOutsideRepository(t *testing.T) {
+	_, ok, err := Probe(scriptedGit{}, ".")
+	if ok || err != nil {
+		t.Fatalf("expected no state outside a repository, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestProbeReportsGitFailures(t *testing.T) {
+	git := repo(map[string]string{"rev-parse --abbrev-ref HEAD": ""})
+	_, ok, err := Probe(git, ".")
+	if !ok || err == nil {
+		t.Fatalf("expected an error inside a repository, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestProbeHead(t *testing.T) {
+	git := repo(map[string]string{"rev-parse --short HEAD": "a1b2c3d"})
+	if head, ok := ProbeHead(git, "."); !ok || head != (Head{Branch: "feature", SHA: "a1b2c3d"}) {
+		t.Fatalf("clean: head = %+v, ok = %v", head, ok)
+	}
+
+	git["status --porcelain -- :(exclude).testdrive"] = " M main.go"
+	git["rev-parse --abbrev-ref HEAD"] = "HEAD"
+	if head, ok := ProbeHead(git, "."); !ok || head != (Head{SHA: "a1b2c3d", Dirty: true}) {
+		t.Fatalf("dirty and detached: head = %+v, ok = %v", head, ok)
+	}
+
+	// A repository without commits has no HEAD to name.
+	if _, ok := ProbeHead(repo(nil), "."); ok {
+		t.Fatal("ProbeHead reported a head without a commit")
+	}
+	if _, ok := ProbeHead(scriptedGit{}, "."); ok {
+		t.Fatal("ProbeHead reported a head outside a repository")
+	}
+}
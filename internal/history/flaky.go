@@ -0,0 +1,177 @@
+package history
+
+import "sort"
+
+// FlakyWindow is how many of a step's most recent executions its flakiness
+// covers.
+const FlakyWindow = 10
+
+// FlakyMinRecoveries is how many recoveries within the window mark a step as
+// flaky. A single recovery is usually just a fix.
+const FlakyMinRecoveries = 2
+
+// StepKey identifies a step across runs by workflow path, job name and step
+// name.
+type StepKey struct {
+	Workflow string
+	Job      string
+	Step     string
+}
+
+// Flakiness is how often a step recently passed in the run right after one
+// where it failed.
+type Flakiness struct {
+	StepKey
+	// Recoveries counts executions that passed right after a failed one.
+	Recoveries int
+	// Runs counts the executions considered, at most FlakyWindow.
+	Runs int
+}
+
+// Score returns the share of the considered runs that were recoveries.
+func (f Flakiness) Score() float64 {
+	if f.Runs == 0 {
+		return 0
+	}
+	return float64(f.Recoveries) / float64(f.Runs)
+}
+
+// Flaky reports whether the step recovered often enough to be called flaky.
+func (f Flakiness) Flaky() bool {
+	return f.Recoveries >= FlakyMinRecoveries
+}
+
+// hashKey identifies a step within a job by its command hash.
+type hashKey struct {
+	workflow string
+	job      string
+	hash     string
+}
+
+// indexedRun holds the passed or failed steps of one run, by step ID, by
+// name and by command hash. A hash shared by several steps of a job maps to
+// "", since it cannot tell them apart.
+type indexedRun struct {
+	byID   map[string]string
+	byName map[StepKey]string
+	byHash map[hashKey]string
+}
+
+// FlakyIndex scores steps against recorded runs.
+type FlakyIndex struct {
+	// runs is newest first.
+	runs []indexedRun
+}
+
+// NewFlakyIndex indexes runs, which are oldest first as Load returns them.
+func NewFlakyIndex(runs []Run) *FlakyIndex {
+	index := &FlakyIndex{runs: make([]indexedRun, 0, len(runs))}
+	for i := len(runs) - 1; i >= 0; i-- {
+		indexed := indexedRun{byID: map[string]string{}, byName: map[StepKey]string{}, byHash: map[hashKey]string{}}
+		for _, step := range runs[i].Steps {
+			if step.Status != "passed" && step.Status != "failed" {
+				continue
+			}
+			if step.StepID != "" {
+				indexed.byID[step.StepID] = step.Status
+			}
+			indexed.byName[StepKey{Workflow: step.Workflow, Job: step.Job, Step: step.Name}] = step.Status
+			if step.RunHash == "" {
+				continue
+			}
+			key := hashKey{workflow: step.Workflow, job: step.Job, hash: step.RunHash}
+			if _, dup := indexed.byHash[key]; dup {
+				indexed.byHash[key] = ""
+			} else {
+				indexed.byHash[key] = step.Status
+			}
+		}
+		index.runs = append(index.runs, indexed)
+	}
+	return index
+}
+
+// Lookup scores the step over its last FlakyWindow executions. Runs are
+// matched by stepID first, so renaming the step or its job keeps its record.
+// Runs recorded without step IDs, or where the ID changed with the command,
+// are matched by name, and then by runHash. A nil index scores nothing.
+func (x *FlakyIndex) Lookup(key StepKey, stepID, runHash string) Flakiness {
+	flakiness := Flakiness{StepKey: key}
+	if x == nil {
+		return flakiness
+	}
+	var statuses []string
+	for _, run := range x.runs {
+		if len(statuses) == FlakyWindow {
+			break
+		}
+		status, ok := run.byID[stepID]
+		if !ok {
+			status, ok = run.byName[key]
+		}
+		if !ok && runHash != "" {
+			status = run.byHash[hashKey{workflow: key.Workflow, job: key.Job, hash: runHash}]
+		}
+		if status != "" {
+			statuses = append(statuses, status)
+		}
+	}
+	flakiness.Runs = len(statuses)
+	// statuses is newest first, so a recovery is a pass just before a failure.
+	for i := 0; i+1 < len(statuses); i++ {
+		if statuses[i] == "passed" && statuses[i+1] == "failed" {
+			flakiness.Recoveries++
+		}
+	}
+	return flakiness
+}
+
+// FlakySteps scores every step that recovered at least once in its recent
+// runs, most flaky first. Steps are named as in the newest run they appear
+// in; older names sharing the step ID or command are folded into them.
+func FlakySteps(runs []Run) []Flakiness {
+	index := NewFlakyIndex(runs)
+	seen := map[StepKey]bool{}
+	claimed := map[hashKey]bool{}
+	claimedIDs := map[string]bool{}
+	var out []Flakiness
+	for i := len(runs) - 1; i >= 0; i-- {
+		for _, step := range runs[i].Steps {
+			if step.Status != "passed" && step.Status != "failed" {
+				continue
+			}
+			key := StepKey{Workflow: step.Workflow, Job: step.Job, Step: step.Name}
+			hash := hashKey{workflow: step.Workflow, job: step.Job, hash: step.RunHash}
+			if seen[key] || step.StepID != "" && claimedIDs[step.StepID] || step.RunHash != "" && claimed[hash] {
+				continue
+			}
+			seen[key] = true
+			if step.StepID != "" {
+				claimedIDs[step.StepID] = true
+			}
+			if step.RunHash != "" {
+				claimed[hash] = true
+			}
+			if f := index.Lookup(key, step.StepID, step.RunHash); f.Recoveries > 0 {
+				out = append(out, f)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score() != out[j].Score() {
+			return out[i].Score() > out[j].Score()
+		}
+		if out[i].Recoveries != out[j].Recoveries {
+			return out[i].Recoveries > out[j].Recoveries
+		}
+		a, b := out[i].StepKey, out[j].StepKey
+		if a.Workflow != b.Workflow {
+			return a.Workflow < b.Workflow
+		}
+		if a.Job != b.Job {
+			return a.Job < b.Job
+		}
+		return a.Step < b.Step
+	})
+	return out
+}
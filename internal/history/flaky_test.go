@@ -0,0 +1,137 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// flakyRuns builds one run per status for a single step, oldest first. An
+// empty status records the run without the step.
+func flakyRuns(name, run string, statuses ...string) []Run {
+	runs := make([]Run, 0, len(statuses))
+	for _, status := range statuses {
+		var steps []Step
+		if status != "" {
+			steps = append(steps, Step{Workflow: "ci.yml", Job: "test", Name: name, Status: status, RunHash: CommandHash(run)})
+		}
+		runs = append(runs, Run{Steps: steps})
+	}
+	return runs
+}
+
+func TestFlakyIndexLookup(t *testing.T) {
+	key := StepKey{Workflow: "ci.yml", Job: "test", Step: "System specs"}
+	for _, tc := range []struct {
+		name     string
+		statuses []string
+		want     Flakiness
+		flaky    bool
+	}{
+		{name: "always passing", statuses: []string{"passed", "passed", "passed"}, want: Flakiness{StepKey: key, Runs: 3}},
+		{name: "fixed once", statuses: []string{"failed", "failed", "passed", "passed"}, want: Flakiness{StepKey: key, Recoveries: 1, Runs: 4}},
+		{name: "alternating", statuses: []string{"failed", "passed", "failed", "passed", "passed"}, want: Flakiness{StepKey: key, Recoveries: 2, Runs: 5}, flaky: true},
+		// Skipped runs say nothing about the step and are not counted.
+		{name: "skips ignored", statuses: []string{"failed", "skipped", "passed", "", "failed", "passed"}, want: Flakiness{StepKey: key, Recoveries: 2, Runs: 4}, flaky: true},
+		// Only the last FlakyWindow executions count.
+		{name: "window", statuses: []string{"failed", "passed", "failed", "passed", "passed", "passed", "passed", "passed", "passed", "passed", "passed", "failed", "passed"}, want: Flakiness{StepKey: key, Recoveries: 1, Runs: FlakyWindow}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewFlakyIndex(flakyRuns("System specs", "bin/rspec spec/system", tc.statuses...)).Lookup(key, "", CommandHash("bin/rspec spec/system"))
+			if got != tc.want || got.Flaky() != tc.flaky {
+				t.Fatalf("Lookup = %+v (flaky %v), want %+v (flaky %v)", got, got.Flaky(), tc.want, tc.flaky)
+			}
+		})
+	}
+
+	if got := (*FlakyIndex)(nil).Lookup(key, "", ""); got.Runs != 0 || got.Score() != 0 {
+		t.Fatalf("expected a nil index to score nothing, got %+v", got)
+	}
+}
+
+func TestFlakyIndexMatchesRenamedSteps(t *testing.T) {
+	run := "bin/rspec spec/system"
+	runs := append(flakyRuns("Specs", run, "failed", "passed", "failed"), flakyRuns("System specs", run, "passed")...)
+	index := NewFlakyIndex(runs)
+
+	got := index.Lookup(StepKey{Workflow: "ci.yml", Job: "test", Step: "System specs"}, "", CommandHash(run))
+	if got.Recoveries != 2 || got.Runs != 4 {
+		t.Fatalf("expected the old name's runs to count, got %+v", got)
+	}
+	if got := index.Lookup(StepKey{Workflow: "ci.yml", Job: "test", Step: "System specs"}, "", CommandHash("bin/rspec")); got.Runs != 1 {
+		t.Fatalf("expected a different command not to match, got %+v", got)
+	}
+
+	// Two steps sharing a command cannot be told apart by it.
+	runs[0].Steps = append(runs[0].Steps, Step{Workflow: "ci.yml", Job: "test", Name: "Specs again", Status: "passed", RunHash: CommandHash(run)})
+	if got := NewFlakyIndex(runs).Lookup(StepKey{Workflow: "ci.yml", Job: "test", Step: "System specs"}, "", CommandHash(run)); got.Runs != 3 {
+		t.Fatalf("expected an ambiguous hash to be ignored, got %+v", got)
+	}
+}
+
+func TestFlakyIndexMatchesStepIDs(t *testing.T) {
+	// Two steps of the job share a command, so only the ID tells them
+	// apart once the step and its job are renamed.
+	var runs []Run
+	for _, status := range []string{"failed", "passed", "failed"} {
+		runs = append(runs, Run{Steps: []Step{
+			{Workflow: "ci.yml", Job: "test", Name: "Specs", StepID: "a1", Status: status, RunHash: CommandHash("bin/rspec")},
+			{Workflow: "ci.yml", Job: "test", Name: "Specs again", StepID: "b2", Status: "passed", RunHash: CommandHash("bin/rspec")},
+		}})
+	}
+	runs = append(runs, Run{Steps: []Step{{Workflow: "ci.yml", Job: "Test suite", Name: "System specs", StepID: "a1", Status: "passed", RunHash: CommandHash("bin/rspec")}}})
+	index := NewFlakyIndex(runs)
+
+	key := StepKey{Workflow: "ci.yml", Job: "Test suite", Step: "System specs"}
+	if got := index.Lookup(key, "a1", CommandHash("bin/rspec")); got.Recoveries != 2 || got.Runs != 4 {
+		t.Fatalf("expected runs to be matched by step ID, got %+v", got)
+	}
+	// Without an ID, as in runs recorded before IDs existed, the name is
+	// all there is to go on.
+	if got := index.Lookup(StepKey{Workflow: "ci.yml", Job: "test", Step: "Specs"}, "", CommandHash("bin/rspec")); got.Recoveries != 1 || got.Runs != 3 {
+		t.Fatalf("expected name matching without an ID, got %+v", got)
+	}
+
+	var got []string
+	for _, f := range FlakySteps(runs) {
+		got = append(got, f.Job+"/"+f.Step)
+	}
+	if want := []string{"Test suite/System specs"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("FlakySteps = %v, want %v", got, want)
+	}
+}
+
+func TestFlakySteps(t *testing.T) {
+	runs := flakyRuns("Specs", "bin/rspec", "failed", "passed", "failed", "passed")
+	lint := flakyRuns("Lint", "bin/lint", "failed", "passed", "passed", "passed")
+	for i := range runs {
+		runs[i].Steps = append(runs[i].Steps, lint[i].Steps...)
+		runs[i].Steps = append(runs[i].Steps, Step{Workflow: "ci.yml", Job: "test", Name: "Build", Status: "passed", RunHash: CommandHash("make")})
+	}
+	// The newest run knows Specs under a new name.
+	runs = append(runs, Run{Steps: []Step{{Workflow: "ci.yml", Job: "test", Name: "System specs", Status: "passed", RunHash: CommandHash("bin/rspec")}}})
+
+	var got []string
+	for _, f := range FlakySteps(runs) {
+		got = append(got, f.Step)
+	}
+	if want := []string{"System specs", "Lint"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("FlakySteps = %v, want %v", got, want)
+	}
+}
+
+func TestNewRunRecordsCommandHash(t *testing.T) {
+	results := []report.StepResult{
+		{WorkflowPath: "ci.yml", JobName: "test", StepName: "Specs", StepID: "a1", StepRun: "bin/rspec", Status: "passed"},
+		{WorkflowPath: "ci.yml", JobName: "test", StepName: "Checkout", Status: "skipped"},
+	}
+	run := NewRun(time.Now(), results, report.Summary{})
+	if run.Steps[0].RunHash != CommandHash("bin/rspec") || run.Steps[1].RunHash != "" || run.Steps[0].StepID != "a1" {
+		t.Fatalf("unexpected command hashes: %+v", run.Steps)
+	}
+	if CommandHash("bin/rspec") == CommandHash("bin/rspec spec/system") {
+		t.Fatal("expected different commands to hash differently")
+	}
+}
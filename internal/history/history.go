@@ -0,0 +1,245 @@
+// Package history records the outcome of past runs so later runs can use
+// their timings and spot flaky steps.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// Dir is where run history is kept, relative to the project root.
+const Dir = ".testdrive/history"
+
+// MaxRuns is how many runs the store keeps; older runs are dropped on append.
+const MaxRuns = 200
+
+const runsFile = "runs.jsonl"
+
+// IgnoreFile is the .gitignore written next to the history directory so
+// the state directory never shows up as untracked.
+const IgnoreFile = ".gitignore"
+
+// ignoreRules ignore everything in the state directory but the baseline
+// `testdrive snapshot` writes there, which is meant to be committed.
+const ignoreRules = "*\n!baseline.json\n"
+
+// Run is one recorded `testdrive run`.
+type Run struct {
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+	Jobs       []Job     `json:"jobs"`
+	Steps      []Step    `json:"steps"`
+}
+
+// Job is the outcome of one job within a run.
+type Job struct {
+	Workflow   string `json:"workflow"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Step is the outcome of one step within a run.
+type Step struct {
+	Workflow string `json:"workflow"`
+	Job      string `json:"job"`
+	Name     string `json:"name"`
+	// StepID identifies the step across renames; runs recorded before step
+	// IDs existed leave it empty and are matched by name.
+	StepID     string `json:"step_id,omitempty"`
+	Status     string `json:"status"`
+	SkipReason string `json:"skip_reason,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	// RunHash identifies the step's command, so a renamed step can still be
+	// matched to its earlier runs.
+	RunHash string `json:"run_hash,omitempty"`
+	// EnvSnapshot is the redacted environment of a failed step.
+	EnvSnapshot *report.EnvSnapshot `json:"env_snapshot,omitempty"`
+}
+
+// NewRun converts the results of a finished run into a history entry.
+func NewRun(startedAt time.Time, results []report.StepResult, summary report.Summary) Run {
+	run := Run{
+		StartedAt:  startedAt.UTC(),
+		DurationMS: summary.Duration.Milliseconds(),
+		ExitCode:   summary.ExitCode,
+		Jobs:       make([]Job, 0, len(summary.Jobs)),
+		Steps:      make([]Step, 0, len(results)),
+	}
+	for _, job := range summary.Jobs {
+		run.Jobs = append(run.Jobs, Job{
+			Workflow:   filepath.ToSlash(job.WorkflowPath),
+			Name:       job.JobName,
+			Status:     job.Status,
+			DurationMS: job.Duration.Milliseconds(),
+		})
+	}
+	for _, res := range results {
+		run.Steps = append(run.Steps, Step{
+			Workflow:    filepath.ToSlash(res.WorkflowPath),
+			Job:         res.JobName,
+			Name:        res.StepName,
+			StepID:      res.StepID,
+			Status:      res.Status,
+			SkipReason:  res.SkipReason,
+			DurationMS:  res.Duration.Milliseconds(),
+			RunHash:     CommandHash(res.StepRun),
+			EnvSnapshot: res.EnvSnapshot,
+		})
+	}
+	return run
+}
+
+// CommandHash returns a short, stable digest of a step's run command, or ""
+// for a step without one.
+func CommandHash(run string) string {
+	if run == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(run))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Store reads and appends runs under a history directory.
+type Store struct {
+	Dir string
+}
+
+// Open returns the store for the project at root. Nothing is created until
+// the first Append.
+func Open(root string) *Store {
+	return &Store{Dir: filepath.Join(root, filepath.FromSlash(Dir))}
+}
+
+// Load returns the recorded runs, oldest first. A missing store is empty,
+// and lines that fail to decode, such as one cut short by a crash, are
+// skipped.
+func (s *Store) Load() ([]Run, error) {
+	runs, _, err := s.Scan()
+	return runs, err
+}
+
+// Scan is Load that also counts the lines it skipped, for callers that
+// report on the store itself.
+func (s *Store) Scan() ([]Run, int, error) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, runsFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("read history: %w", err)
+	}
+	var runs []Run
+	corrupt := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var run Run
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			corrupt++
+			continue
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("read history: %w", err)
+	}
+	return runs, corrupt, nil
+}
+
+// Append records run, dropping the oldest runs beyond MaxRuns. The state
+// directory holding the store gets an IgnoreFile, unless it already has
+// one.
+func (s *Store) Append(run Run) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("create history dir: %w", err)
+	}
+	if err := ignoreStateDir(filepath.Dir(s.Dir)); err != nil {
+		return err
+	}
+	runs, err := s.Load()
+	if err != nil {
+		return err
+	}
+	runs = append(runs, run)
+	if len(runs) > MaxRuns {
+		runs = runs[len(runs)-MaxRuns:]
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range runs {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("encode history: %w", err)
+		}
+	}
+	// Write to a temp file first so an interrupted run never truncates the
+	// existing history.
+	tmp, err := os.CreateTemp(s.Dir, runsFile+".*")
+	if err != nil {
+		return fmt.Errorf("write history: %w", err)
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.Dir, runsFile)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write history: %w", err)
+	}
+	return nil
+}
+
+// ignoreStateDir writes an IgnoreFile in dir that keeps git from listing
+// the state in it, leaving one that is already there as it is.
+func ignoreStateDir(dir string) error {
+	path := filepath.Join(dir, IgnoreFile)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(ignoreRules), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// JobKey identifies a job across runs by workflow path and job name.
+type JobKey struct {
+	Workflow string
+	Job      string
+}
+
+// LastJobDurations returns, for every job that executed at least one step,
+// its duration in the most recent run where it did. Skipped jobs carry no
+// timing and are ignored.
+func LastJobDurations(runs []Run) map[JobKey]time.Duration {
+	durations := make(map[JobKey]time.Duration)
+	for _, run := range runs {
+		for _, job := range run.Jobs {
+			if job.Status != "passed" && job.Status != "failed" {
+				continue
+			}
+			durations[JobKey{Workflow: job.Workflow, Job: job.Name}] = time.Duration(job.DurationMS) * time.Millisecond
+		}
+	}
+	return durations
+}
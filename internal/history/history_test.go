@@ -0,0 +1,105 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestStoreAppendAndLoad(t *testing.T) {
+	root := t.TempDir()
+	store := Open(root)
+	runs, err := store.Load()
+	if err != nil || runs != nil {
+		t.Fatalf("expected an empty history, got %v, %v", runs, err)
+	}
+
+	results := []report.StepResult{
+		{WorkflowPath: "ci.yml", JobName: "test", StepName: "unit", Status: "passed", Duration: 3 * time.Second},
+		{WorkflowPath: "ci.yml", JobName: "test", StepName: "upload", Status: "skipped", SkipReason: report.ReasonDryRun},
+	}
+	summary := report.Summary{
+		Duration: 3 * time.Second,
+		Jobs:     report.SummarizeJobs(results),
+	}
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := store.Append(NewRun(started, results, summary)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	runs, err = store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("expected one run, got %d", len(runs))
+	}
+	run := runs[0]
+	if !run.StartedAt.Equal(started) || run.DurationMS != 3000 {
+		t.Fatalf("unexpected run %+v", run)
+	}
+	if len(run.Jobs) != 1 || run.Jobs[0] != (Job{Workflow: "ci.yml", Name: "test", Status: "passed", DurationMS: 3000}) {
+		t.Fatalf("unexpected jobs %+v", run.Jobs)
+	}
+	if len(run.Steps) != 2 || run.Steps[1].SkipReason != report.ReasonDryRun {
+		t.Fatalf("unexpected steps %+v", run.Steps)
+	}
+	ignore, err := os.ReadFile(filepath.Join(root, ".testdrive", IgnoreFile))
+	if err != nil || string(ignore) != ignoreRules {
+		t.Fatalf("expected the state dir to be ignored, got %q, %v", ignore, err)
+	}
+}
+
+func TestStoreSkipsCorruptLinesAndTrims(t *testing.T) {
+	store := Open(t.TempDir())
+	if err := os.MkdirAll(store.Dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(store.Dir, runsFile), []byte("{\"exit_code\":1}\n{\"exit_co"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	runs, err := store.Load()
+	if err != nil || len(runs) != 1 || runs[0].ExitCode != 1 {
+		t.Fatalf("expected the truncated line to be skipped, got %+v, %v", runs, err)
+	}
+	if _, corrupt, err := store.Scan(); err != nil || corrupt != 1 {
+		t.Fatalf("Scan counted %d corrupt lines (%v), want 1", corrupt, err)
+	}
+
+	for i := 0; i < MaxRuns+5; i++ {
+		if err := store.Append(Run{DurationMS: int64(i)}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	runs, err = store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(runs) != MaxRuns || runs[len(runs)-1].DurationMS != MaxRuns+4 {
+		t.Fatalf("expected the newest %d runs, got %d ending with %+v", MaxRuns, len(runs), runs[len(runs)-1])
+	}
+}
+
+func TestLastJobDurations(t *testing.T) {
+	runs := []Run{
+		{Jobs: []Job{
+			{Workflow: "ci.yml", Name: "test", Status: "passed", DurationMS: 1000},
+			{Workflow: "ci.yml", Name: "lint", Status: "failed", DurationMS: 500},
+		}},
+		{Jobs: []Job{
+			{Workflow: "ci.yml", Name: "test", Status: "passed", DurationMS: 4000},
+			// A job whose steps were all skipped says nothing about its runtime.
+			{Workflow: "ci.yml", Name: "lint", Status: "skipped"},
+		}},
+	}
+	got := LastJobDurations(runs)
+	if got[JobKey{Workflow: "ci.yml", Job: "test"}] != 4*time.Second {
+		t.Fatalf("expected the latest duration for test, got %v", got)
+	}
+	if got[JobKey{Workflow: "ci.yml", Job: "lint"}] != 500*time.Millisecond {
+		t.Fatalf("expected lint to keep its last executed duration, got %v", got)
+	}
+}
@@ -0,0 +1,378 @@
+// Package listen serves a run's progress to other programs, such as editor
+// integrations, while the run executes: the JSON stream's events as they
+// happen, and a snapshot of where the run stands.
+package listen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// UnixPrefix marks a Listen address as a Unix socket path.
+const UnixPrefix = "unix:"
+
+// shutdownTimeout bounds how long Close waits for clients to finish
+// reading.
+const shutdownTimeout = 2 * time.Second
+
+// Run states reported by /status.
+const (
+	StateRunning  = "running"
+	StateFinished = "finished"
+)
+
+// Status is the snapshot GET /status returns.
+type Status struct {
+	State string      `json:"state"`
+	Jobs  []JobStatus `json:"jobs"`
+	// Summary counts the steps finished so far, until the run's own summary
+	// replaces it at the end.
+	Summary report.Summary `json:"summary"`
+}
+
+// JobStatus is one job in a Status, in the order the run lists them.
+type JobStatus struct {
+	// ID is the job's ID in stream events.
+	ID       string `json:"id"`
+	Workflow string `json:"workflow"`
+	Name     string `json:"name"`
+	// Status is pending or running until the job finishes, then passed,
+	// failed, or skipped.
+	Status string `json:"status"`
+	// Step names the step running now.
+	Step  string       `json:"step,omitempty"`
+	Steps []StepStatus `json:"steps"`
+}
+
+// StepStatus is a finished step of a JobStatus.
+type StepStatus struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	SkipReason string `json:"skip_reason,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Server is a StreamingRenderer that serves what it is told over HTTP:
+// GET /events streams the same newline-delimited events as
+// `run --format json --stream`, starting from the run's first, and ends
+// once the run does; GET /status returns a Status. It is safe for
+// concurrent use.
+type Server struct {
+	http   *http.Server
+	addr   string
+	stream *output.JSONStreamRenderer
+
+	mu sync.Mutex
+	// events holds every line emitted so far; changed is closed and
+	// replaced whenever one is added or the run finishes.
+	events  [][]byte
+	changed chan struct{}
+	status  Status
+	jobs    map[string]*JobStatus
+}
+
+// Listen starts serving on addr: "unix:" and a socket path, or a host and
+// port. A host must be a loopback address, since step output can hold
+// secrets; port 0 picks a free one, which Addr reports.
+func Listen(addr string) (*Server, error) {
+	listener, err := listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		addr:    addr,
+		changed: make(chan struct{}),
+		status:  Status{State: StateRunning, Jobs: []JobStatus{}},
+		jobs:    make(map[string]*JobStatus),
+	}
+	if !strings.HasPrefix(addr, UnixPrefix) {
+		s.addr = listener.Addr().String()
+	}
+	s.stream = output.NewJSONStream(eventWriter{s})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", s.serveEvents)
+	mux.HandleFunc("GET /status", s.serveStatus)
+	s.http = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go s.http.Serve(listener)
+	return s, nil
+}
+
+func listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, UnixPrefix); ok {
+		if path == "" {
+			return nil, fmt.Errorf("listen %s: no socket path", addr)
+		}
+		listener, err := net.Listen("unix", path)
+		if err != nil && errors.Is(err, syscall.EADDRINUSE) && stale(path) {
+			// A run that was killed left its socket behind.
+			if err := os.Remove(path); err != nil {
+				return nil, fmt.Errorf("listen %s: %w", addr, err)
+			}
+			listener, err = net.Listen("unix", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listen %s: %w", addr, err)
+		}
+		return listener, nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w (use unix:/path/to.sock or 127.0.0.1:port)", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("listen %s: %q is not a loopback address; step output could reach other machines", addr, host)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", addr, err)
+	}
+	return listener, nil
+}
+
+// stale reports whether path is a socket nothing listens on.
+func stale(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode().Type() != os.ModeSocket {
+		return false
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return true
+	}
+	conn.Close()
+	return false
+}
+
+// Addr is the address clients connect to: the one Listen was given, with
+// the port picked for port 0.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// SetProvider names the provider in the start event.
+func (s *Server) SetProvider(name string) {
+	s.stream.Provider = name
+}
+
+// Close ends every event stream, waits briefly for clients to read what is
+// left, and stops serving. A Unix socket is removed.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.status.State = StateFinished
+	s.notify()
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := s.http.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = s.http.Close()
+	}
+	if path, ok := strings.CutPrefix(s.addr, UnixPrefix); ok {
+		// Serve removes the socket when the listener closes, unless the
+		// server never got that far.
+		if rmErr := os.Remove(path); rmErr != nil && !os.IsNotExist(rmErr) && err == nil {
+			err = rmErr
+		}
+	}
+	return err
+}
+
+// notify wakes every client waiting for an event. Callers must hold s.mu.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// eventWriter records each line the JSON stream writes as one event.
+type eventWriter struct {
+	s *Server
+}
+
+func (w eventWriter) Write(p []byte) (int, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	w.s.events = append(w.s.events, append([]byte(nil), p...))
+	w.s.notify()
+	return len(p), nil
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	for sent := 0; ; {
+		s.mu.Lock()
+		pending := s.events[sent:]
+		finished := s.status.State == StateFinished
+		changed := s.changed
+		s.mu.Unlock()
+
+		for _, line := range pending {
+			if _, err := w.Write(line); err != nil {
+				return
+			}
+		}
+		sent += len(pending)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if finished {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) serveStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, err := json.Marshal(s.status)
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+// job returns the status of jobID. Callers must hold s.mu.
+func (s *Server) job(jobID string) (*JobStatus, error) {
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return nil, fmt.Errorf("listen: unknown job %q", jobID)
+	}
+	return job, nil
+}
+
+// InitializeAllJobs lists every job as pending and emits the start event.
+func (s *Server) InitializeAllJobs(workflows []provider.Workflow) error {
+	var ids []string
+	jobs := []JobStatus{}
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			id := output.JobID(wf, job)
+			ids = append(ids, id)
+			jobs = append(jobs, JobStatus{ID: filepath.ToSlash(id), Workflow: filepath.ToSlash(wf.Path), Name: job.Name, Status: "pending", Steps: []StepStatus{}})
+		}
+	}
+	s.mu.Lock()
+	s.status.Jobs = jobs
+	s.jobs = make(map[string]*JobStatus, len(jobs))
+	for i, id := range ids {
+		s.jobs[id] = &s.status.Jobs[i]
+	}
+	s.mu.Unlock()
+	return s.stream.InitializeAllJobs(workflows)
+}
+
+// StartJob marks the job running and emits a job_started event.
+func (s *Server) StartJob(jobID string) error {
+	s.mu.Lock()
+	job, err := s.job(jobID)
+	if err == nil {
+		job.Status = "running"
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.stream.StartJob(jobID)
+}
+
+// InitializeWorkflow does nothing; InitializeAllJobs already listed the jobs.
+func (s *Server) InitializeWorkflow(workflowName, jobName string, stepCount int) error {
+	return nil
+}
+
+// StartStep notes the job's running step and emits a step_started event.
+func (s *Server) StartStep(jobID, stepName string) error {
+	s.mu.Lock()
+	job, err := s.job(jobID)
+	if err == nil {
+		job.Step = stepName
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.stream.StartStep(jobID, stepName)
+}
+
+// CompleteStep records the step's result, counts it in the summary so
+// far, and emits a step_finished event.
+func (s *Server) CompleteStep(jobID, stepName string, result report.StepResult) error {
+	s.mu.Lock()
+	job, err := s.job(jobID)
+	if err == nil {
+		job.Step = ""
+		job.Steps = append(job.Steps, StepStatus{Name: stepName, Status: result.Status, SkipReason: result.SkipReason, DurationMS: result.Duration.Milliseconds()})
+		switch result.Status {
+		case "passed":
+			s.status.Summary.Passed++
+		case "failed":
+			s.status.Summary.Failed++
+		case "xfail":
+			s.status.Summary.XFailed++
+		case "xpass":
+			s.status.Summary.XPassed++
+		case "skipped":
+			s.status.Summary.Skipped++
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.stream.CompleteStep(jobID, stepName, result)
+}
+
+// CompleteJob settles the job's status from its steps and emits a
+// job_finished event.
+func (s *Server) CompleteJob(jobID string) error {
+	s.mu.Lock()
+	job, err := s.job(jobID)
+	if err == nil {
+		var passed, failed int
+		for _, step := range job.Steps {
+			switch step.Status {
+			case "passed", "xfail", "xpass":
+				passed++
+			case "failed":
+				failed++
+			}
+		}
+		job.Status, job.Step = report.JobStatus(passed, failed), ""
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.stream.CompleteJob(jobID)
+}
+
+// RenderSummary replaces the summary so far with the run's and emits the
+// summary event.
+func (s *Server) RenderSummary(summary report.Summary) error {
+	s.mu.Lock()
+	s.status.Summary = summary
+	s.mu.Unlock()
+	return s.stream.RenderSummary(summary)
+}
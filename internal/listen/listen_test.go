@@ -0,0 +1,195 @@
+package listen
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func socketPath(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a Unix socket")
+	}
+	return filepath.Join(t.TempDir(), "testdrive.sock")
+}
+
+// unixClient returns an HTTP client that connects to the socket at path.
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func getStatus(t *testing.T, client *http.Client) Status {
+	t.Helper()
+	resp, err := client.Get("http://testdrive/status")
+	if err != nil {
+		t.Fatalf("GET /status: %v", err)
+	}
+	defer resp.Body.Close()
+	var status Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	return status
+}
+
+// nextEvent reads one event line and returns its event name.
+func nextEvent(t *testing.T, events *bufio.Scanner) string {
+	t.Helper()
+	if !events.Scan() {
+		t.Fatalf("event stream ended early: %v", events.Err())
+	}
+	var event output.StreamEvent
+	if err := json.Unmarshal(events.Bytes(), &event); err != nil {
+		t.Fatalf("decode event %q: %v", events.Text(), err)
+	}
+	return event.Event
+}
+
+func TestServerStreamsEventsAndStatus(t *testing.T) {
+	path := socketPath(t)
+	server, err := Listen(UnixPrefix + path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer server.Close()
+	if server.Addr() != UnixPrefix+path {
+		t.Fatalf("Addr = %q", server.Addr())
+	}
+	client := unixClient(path)
+
+	wf := provider.Workflow{Path: "ci.yml", Name: "CI", Jobs: []provider.Job{
+		{Name: "lint", RawID: "lint", Steps: []provider.Step{{Name: "Vet", Run: "go vet"}}},
+		{Name: "test", RawID: "test", Steps: []provider.Step{{Name: "Test", Run: "go test"}}},
+	}}
+	lint, test := output.JobID(wf, wf.Jobs[0]), output.JobID(wf, wf.Jobs[1])
+	if err := server.InitializeAllJobs([]provider.Workflow{wf}); err != nil {
+		t.Fatal(err)
+	}
+	server.StartJob(lint)
+	server.StartStep(lint, "Vet")
+
+	status := getStatus(t, client)
+	if status.State != StateRunning || len(status.Jobs) != 2 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if got := status.Jobs[0]; got.ID != "ci.yml#lint" || got.Status != "running" || got.Step != "Vet" {
+		t.Fatalf("lint = %+v, want running Vet", got)
+	}
+	if got := status.Jobs[1]; got.Status != "pending" {
+		t.Fatalf("test = %+v, want pending", got)
+	}
+
+	// A client that connects partway through still gets every event.
+	resp, err := client.Get("http://testdrive/events")
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewScanner(resp.Body)
+	for _, want := range []string{output.EventStart, output.EventJobStarted, output.EventStepStarted} {
+		if got := nextEvent(t, events); got != want {
+			t.Fatalf("event = %q, want %q", got, want)
+		}
+	}
+
+	server.CompleteStep(lint, "Vet", report.StepResult{Status: "failed", Duration: 1500 * time.Millisecond})
+	if got := nextEvent(t, events); got != output.EventStepFinished {
+		t.Fatalf("event = %q, want %q", got, output.EventStepFinished)
+	}
+	server.CompleteJob(lint)
+	server.StartJob(test)
+	status = getStatus(t, client)
+	if status.Summary.Failed != 1 || status.Jobs[0].Status != "failed" || status.Jobs[1].Status != "running" {
+		t.Fatalf("unexpected status after lint: %+v", status)
+	}
+	if steps := status.Jobs[0].Steps; len(steps) != 1 || steps[0].DurationMS != 1500 {
+		t.Fatalf("lint steps = %+v", steps)
+	}
+
+	server.StartStep(test, "Test")
+	server.CompleteStep(test, "Test", report.StepResult{Status: "passed"})
+	server.CompleteJob(test)
+	server.RenderSummary(report.Summary{Passed: 1, Failed: 1, ExitCode: 1})
+	if status := getStatus(t, client); status.Summary.ExitCode != 1 {
+		t.Fatalf("summary = %+v, want the run's", status.Summary)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	var rest []string
+	for events.Scan() {
+		var event output.StreamEvent
+		json.Unmarshal(events.Bytes(), &event)
+		rest = append(rest, event.Event)
+	}
+	want := []string{output.EventJobFinished, output.EventJobStarted, output.EventStepStarted, output.EventStepFinished, output.EventJobFinished, output.EventSummary}
+	if strings.Join(rest, " ") != strings.Join(want, " ") {
+		t.Fatalf("remaining events = %v, want %v", rest, want)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket left behind: %v", err)
+	}
+}
+
+func TestListenTCP(t *testing.T) {
+	server, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer server.Close()
+	if strings.HasSuffix(server.Addr(), ":0") {
+		t.Fatalf("Addr = %q, want the port picked", server.Addr())
+	}
+	resp, err := http.Get("http://" + server.Addr() + "/status")
+	if err != nil {
+		t.Fatalf("GET /status: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status code %d", resp.StatusCode)
+	}
+
+	for _, addr := range []string{"0.0.0.0:0", ":0", "example.com:80"} {
+		if _, err := Listen(addr); err == nil || !strings.Contains(err.Error(), "not a loopback address") {
+			t.Fatalf("Listen(%s) = %v, want a refusal", addr, err)
+		}
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	path := socketPath(t)
+	old, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.(*net.UnixListener).SetUnlinkOnClose(false)
+	old.Close()
+
+	server, err := Listen(UnixPrefix + path)
+	if err != nil {
+		t.Fatalf("Listen over a stale socket: %v", err)
+	}
+	defer server.Close()
+
+	if _, err := Listen(UnixPrefix + path); err == nil {
+		t.Fatal("a second Listen took over a socket in use")
+	}
+}
@@ -0,0 +1,137 @@
+// Package lockfile provides an advisory lock on a file that one process
+// holds at a time. The holder records its pid and start time in the file so
+// the next process can say who is in the way.
+package lockfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Holder is what the process holding a lock recorded about itself.
+type Holder struct {
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+}
+
+// LockedError reports a lock another live process holds.
+type LockedError struct {
+	Path   string
+	Holder Holder
+}
+
+func (e *LockedError) Error() string {
+	if e.Holder.PID == 0 {
+		return fmt.Sprintf("%s is locked by another process", e.Path)
+	}
+	return fmt.Sprintf("%s is locked by pid %d", e.Path, e.Holder.PID)
+}
+
+// errHeld is returned by lockFile when another open file holds the lock,
+// and errUnsupported when the file system cannot lock files at all.
+var (
+	errHeld        = errors.New("lock held")
+	errUnsupported = errors.New("file locking not supported")
+)
+
+// Lock is a held lock. Release it when done; the operating system also
+// drops it if the process exits without doing so.
+type Lock struct {
+	f *os.File
+	// locked is false when the file system could not lock the file and the
+	// recorded pid alone guards it.
+	locked bool
+}
+
+// Acquire takes the lock at path without waiting, creating the file and its
+// directory as needed, and records the current process as its holder with
+// started as its start time. It returns a *LockedError when a live process
+// holds the lock. A lock left by a process that is gone is taken over.
+func Acquire(path string, started time.Time) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+	l := &Lock{f: f, locked: true}
+	switch err := lockFile(f); {
+	case err == nil:
+	case errors.Is(err, errHeld):
+		holder, _ := readHolder(f)
+		f.Close()
+		return nil, &LockedError{Path: path, Holder: holder}
+	case errors.Is(err, errUnsupported):
+		// Without file locks the recorded pid is all there is to go on:
+		// the lock is free once that process is gone.
+		holder, _ := readHolder(f)
+		if holder.PID != 0 && processAlive(holder.PID) {
+			f.Close()
+			return nil, &LockedError{Path: path, Holder: holder}
+		}
+		l.locked = false
+	default:
+		f.Close()
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	if err := l.record(Holder{PID: os.Getpid(), Started: started}); err != nil {
+		l.Release()
+		return nil, fmt.Errorf("write lock file: %w", err)
+	}
+	return l, nil
+}
+
+// Release empties the lock file and unlocks it. The file is left in place:
+// removing it could let a process that already opened it lock a file no
+// one else can see.
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := l.f.Truncate(0)
+	if l.locked {
+		if unlockErr := unlockFile(l.f); err == nil {
+			err = unlockErr
+		}
+	}
+	if closeErr := l.f.Close(); err == nil {
+		err = closeErr
+	}
+	l.f = nil
+	return err
+}
+
+func (l *Lock) record(h Holder) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if err := l.f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = l.f.WriteAt(append(data, '\n'), 0)
+	return err
+}
+
+// readHolder reads the holder recorded in f. An empty or unreadable file
+// yields the zero Holder.
+func readHolder(f *os.File) (Holder, error) {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 1<<16))
+	if err != nil {
+		return Holder{}, err
+	}
+	var h Holder
+	if len(data) == 0 {
+		return h, nil
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return Holder{}, err
+	}
+	return h, nil
+}
@@ -0,0 +1,20 @@
+//go:build !windows && !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package lockfile
+
+import "os"
+
+// Platforms without flock fall back to the pid recorded in the file.
+func lockFile(f *os.File) error {
+	return errUnsupported
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
+
+// processAlive cannot probe processes here, so a recorded holder is assumed
+// to be running.
+func processAlive(pid int) bool {
+	return true
+}
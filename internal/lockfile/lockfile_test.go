@@ -0,0 +1,81 @@
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAcquireExcludesOtherHolders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".testdrive", "lock")
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	lock, err := Acquire(path, started)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	_, err = Acquire(path, started.Add(time.Minute))
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("expected a LockedError while the lock is held, got %v", err)
+	}
+	if locked.Holder.PID != os.Getpid() || !locked.Holder.Started.Equal(started) {
+		t.Fatalf("expected this process as the holder, got %+v", locked.Holder)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || len(data) != 0 {
+		t.Fatalf("expected an empty lock file after release, got %q, %v", data, err)
+	}
+	again, err := Acquire(path, started)
+	if err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+	if err := again.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+}
+
+func TestAcquireTakesOverStaleLock(t *testing.T) {
+	// A child that has exited stands in for a run that crashed.
+	child := exec.Command(os.Args[0], "-test.run=^$")
+	if err := child.Run(); err != nil {
+		t.Fatalf("run child: %v", err)
+	}
+	if !processAlive(os.Getpid()) || processAlive(child.Process.Pid) {
+		t.Fatalf("expected only this process to be alive")
+	}
+	path := filepath.Join(t.TempDir(), "lock")
+	stale := `{"pid":` + strconv.Itoa(child.Process.Pid) + `,"started":"2024-05-01T12:00:00Z"}` + "\n"
+	if err := os.WriteFile(path, []byte(stale), 0o644); err != nil {
+		t.Fatalf("write stale lock: %v", err)
+	}
+
+	lock, err := Acquire(path, time.Now())
+	if err != nil {
+		t.Fatalf("expected the stale lock to be taken over, got %v", err)
+	}
+	defer lock.Release()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open lock file: %v", err)
+	}
+	defer f.Close()
+	holder, err := readHolder(f)
+	if err != nil || holder.PID != os.Getpid() {
+		t.Fatalf("expected this process recorded as the holder, got %+v, %v", holder, err)
+	}
+}
+
+func TestReleaseNil(t *testing.T) {
+	var lock *Lock
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release on nil lock: %v", err)
+	}
+}
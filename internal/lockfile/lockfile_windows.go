@@ -0,0 +1,67 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+)
+
+// lockRange returns the overlapped offset of the byte that is locked. It
+// lies far past the holder record, since Windows locks are mandatory and
+// would otherwise keep other processes from reading who holds the lock.
+func lockRange() *syscall.Overlapped {
+	return &syscall.Overlapped{OffsetHigh: 0x7fffffff}
+}
+
+func lockFile(f *os.File) error {
+	r, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(lockRange())))
+	switch {
+	case r != 0:
+		return nil
+	case errors.Is(err, errorLockViolation):
+		return errHeld
+	default:
+		return err
+	}
+}
+
+func unlockFile(f *os.File) error {
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(lockRange())))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// processAlive reports whether pid names a running process.
+func processAlive(pid int) bool {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// Access is denied for processes that exist but belong to others.
+		return errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -0,0 +1,196 @@
+package output
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// annotationParsers each recognise one tool's failure output. They only
+// report a location when the line format leaves no doubt about it; output
+// they do not recognise yields nothing.
+var annotationParsers = []func(lines []string) []report.Annotation{
+	goAnnotations,
+	pytestAnnotations,
+	jestAnnotations,
+	rspecAnnotations,
+}
+
+// Annotations returns the source locations of failures found in a failed
+// step's output, in the order each tool reported them. Paths are as the
+// tool printed them, less any leading "./".
+func Annotations(output string) []report.Annotation {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	var out []report.Annotation
+	seen := map[string]bool{}
+	for _, parse := range annotationParsers {
+		for _, a := range parse(lines) {
+			key := a.Path + ":" + strconv.Itoa(a.Line)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func annotation(path, line, column, message string) report.Annotation {
+	a := report.Annotation{Path: strings.TrimPrefix(path, "./"), Message: strings.TrimSpace(message)}
+	a.Line, _ = strconv.Atoi(line)
+	if column != "" {
+		a.Column, _ = strconv.Atoi(column)
+	}
+	return a
+}
+
+var (
+	// goCompileError is a build or vet diagnostic: "./main.go:12:5: undefined: x".
+	goCompileError = regexp.MustCompile(`^(\S+\.go):(\d+):(\d+): (.+)$`)
+	// goTestError is a t.Error or t.Fatal line, indented under its test:
+	// "    parse_test.go:42: got 1, want 2".
+	goTestError = regexp.MustCompile(`^\s+([\w.-]+_test\.go):(\d+): (.+)$`)
+	// goFrame is a file line of a goroutine trace: "\t/src/app/main.go:8 +0x1d".
+	goFrame = regexp.MustCompile(`^\t(\S+\.go):(\d+)(?: \+0x[0-9a-f]+)?$`)
+)
+
+// goAnnotations finds compiler and vet diagnostics, failed test
+// assertions, and the first frame of each panic outside the runtime and
+// testing packages.
+func goAnnotations(lines []string) []report.Annotation {
+	var out []report.Annotation
+	panicMsg := ""
+	for i, line := range lines {
+		if strings.HasPrefix(line, "panic: ") {
+			panicMsg = strings.TrimPrefix(line, "panic: ")
+			continue
+		}
+		if panicMsg != "" {
+			m := goFrame.FindStringSubmatch(line)
+			if m == nil || i == 0 || isGoInternalFunc(lines[i-1]) {
+				continue
+			}
+			out = append(out, annotation(m[1], m[2], "", "panic: "+panicMsg))
+			panicMsg = ""
+			continue
+		}
+		if m := goCompileError.FindStringSubmatch(line); m != nil {
+			out = append(out, annotation(m[1], m[2], m[3], m[4]))
+		} else if m := goTestError.FindStringSubmatch(line); m != nil {
+			out = append(out, annotation(m[1], m[2], "", m[3]))
+		}
+	}
+	return out
+}
+
+// isGoInternalFunc reports whether a trace's function line belongs to the
+// runtime or testing packages, which are never where a panic's cause is.
+func isGoInternalFunc(line string) bool {
+	for _, prefix := range []string{"runtime.", "testing.", "panic("} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// pytestLocation ends each failure in pytest's long report:
+	// "tests/test_calc.py:7: AssertionError".
+	pytestLocation = regexp.MustCompile(`^(\S+\.py):(\d+): (\w+)$`)
+	// pytestHeader opens each failure: "____ test_add ____".
+	pytestHeader = regexp.MustCompile(`^_{3,} .+ _{3,}$`)
+)
+
+// pytestAnnotations reads pytest's long failure report. The message is the
+// first "E" line of the failure, or the exception name when there is none.
+func pytestAnnotations(lines []string) []report.Annotation {
+	var out []report.Annotation
+	explanation := ""
+	for _, line := range lines {
+		switch {
+		case pytestHeader.MatchString(line):
+			explanation = ""
+		case strings.HasPrefix(line, "E   ") && explanation == "":
+			explanation = strings.TrimSpace(line[1:])
+		default:
+			m := pytestLocation.FindStringSubmatch(line)
+			if m == nil || !isExceptionName(m[3]) {
+				continue
+			}
+			msg := m[3]
+			if explanation != "" {
+				msg = explanation
+			}
+			out = append(out, annotation(m[1], m[2], "", msg))
+			explanation = ""
+		}
+	}
+	return out
+}
+
+func isExceptionName(name string) bool {
+	return strings.HasSuffix(name, "Error") || strings.HasSuffix(name, "Exception") || name == "Failed"
+}
+
+var (
+	// jestFrame is a stack frame: "at Object.<anonymous> (src/sum.test.ts:4:21)"
+	// or "at src/sum.test.ts:4:21".
+	jestFrame = regexp.MustCompile(`^\s+at (?:.* \()?([^\s()]+\.[cm]?[jt]sx?):(\d+):(\d+)\)?$`)
+	// jestTitle names a failed test: "● sum › adds numbers".
+	jestTitle = regexp.MustCompile(`^\s*● (.+)$`)
+)
+
+// jestAnnotations reports the first stack frame in the project under each
+// failed test's title.
+func jestAnnotations(lines []string) []report.Annotation {
+	var out []report.Annotation
+	title := ""
+	for _, line := range lines {
+		if m := jestTitle.FindStringSubmatch(line); m != nil {
+			title = m[1]
+			continue
+		}
+		if title == "" {
+			continue
+		}
+		m := jestFrame.FindStringSubmatch(line)
+		if m == nil || strings.Contains(m[1], "node_modules/") || strings.HasPrefix(m[1], "node:") {
+			continue
+		}
+		out = append(out, annotation(m[1], m[2], m[3], title))
+		title = ""
+	}
+	return out
+}
+
+var (
+	// rspecRerun lists a failed example: "rspec ./spec/user_spec.rb:12 # User is valid".
+	rspecRerun = regexp.MustCompile(`^rspec (\./\S+_spec\.rb):(\d+)(?: # (.+))?$`)
+	// rspecFrame is a backtrace line: "# ./spec/user_spec.rb:14:in 'block (2 levels)'".
+	rspecFrame = regexp.MustCompile(`^# (\./\S+_spec\.rb):(\d+)(?::in .*)?$`)
+)
+
+// rspecAnnotations reports the spec line of each failed expectation and
+// each example in the "Failed examples:" list.
+func rspecAnnotations(lines []string) []report.Annotation {
+	var out []report.Annotation
+	failure := ""
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if idx := strings.Index(line, "Failure/Error:"); idx != -1 {
+			failure = line[idx+len("Failure/Error:"):]
+			continue
+		}
+		if m := rspecFrame.FindStringSubmatch(line); m != nil && failure != "" {
+			out = append(out, annotation(m[1], m[2], "", failure))
+			failure = ""
+		} else if m := rspecRerun.FindStringSubmatch(line); m != nil {
+			out = append(out, annotation(m[1], m[2], "", m[3]))
+		}
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+package output
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestAnnotations(t *testing.T) {
+	cases := []struct {
+		name   string
+		output string
+		want   []report.Annotation
+	}{
+		{
+			name: "go build",
+			output: `# github.com/acme/shop/cart
+./cart.go:14:2: undefined: totl
+./cart.go:21:9: cannot use price (variable of type float64) as int value in return statement
+`,
+			want: []report.Annotation{
+				{Path: "cart.go", Line: 14, Column: 2, Message: "undefined: totl"},
+				{Path: "cart.go", Line: 21, Column: 9, Message: "cannot use price (variable of type float64) as int value in return statement"},
+			},
+		},
+		{
+			name: "go test",
+			output: `--- FAIL: TestTotal (0.00s)
+    cart_test.go:31: Total() = 12, want 15
+--- FAIL: TestDiscount (0.00s)
+    cart_test.go:58: unexpected discount: 0.2
+FAIL
+FAIL	github.com/acme/shop/cart	0.004s
+`,
+			want: []report.Annotation{
+				{Path: "cart_test.go", Line: 31, Message: "Total() = 12, want 15"},
+				{Path: "cart_test.go", Line: 58, Message: "unexpected discount: 0.2"},
+			},
+		},
+		{
+			name: "go panic",
+			output: `--- FAIL: TestCheckout (0.00s)
+panic: runtime error: index out of range [3] with length 3 [recovered]
+	panic: runtime error: index out of range [3] with length 3
+
+goroutine 7 [running]:
+testing.tRunner.func1.2({0x5a1f40, 0xc000018168})
+	/usr/local/go/src/testing/testing.go:1631 +0x24a
+panic({0x5a1f40?, 0xc000018168?})
+	/usr/local/go/src/runtime/panic.go:770 +0x132
+github.com/acme/shop/cart.(*Cart).Item(...)
+	/home/dev/shop/cart/cart.go:40
+github.com/acme/shop/cart.TestCheckout(0xc0000a6820?)
+	/home/dev/shop/cart/cart_test.go:77 +0x1d
+testing.tRunner(0xc0000a6820, 0x5c3a58)
+	/usr/local/go/src/testing/testing.go:1689 +0xfb
+exit status 2
+`,
+			want: []report.Annotation{
+				{Path: "/home/dev/shop/cart/cart.go", Line: 40, Message: "panic: runtime error: index out of range [3] with length 3 [recovered]"},
+			},
+		},
+		{
+			name: "pytest",
+			output: `============================= FAILURES ==============================
+____________________________ test_add _______________________________
+
+    def test_add():
+>       assert add(1, 2) == 4
+E       assert 3 == 4
+E        +  where 3 = add(1, 2)
+
+tests/test_calc.py:7: AssertionError
+__________________________ test_divide ______________________________
+
+    def test_divide():
+>       divide(1, 0)
+
+tests/test_calc.py:11:
+_ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _ _
+
+    def divide(a, b):
+>       return a / b
+E       ZeroDivisionError: division by zero
+
+calc/ops.py:9: ZeroDivisionError
+=========================== short test summary info ===========================
+FAILED tests/test_calc.py::test_add - assert 3 == 4
+FAILED tests/test_calc.py::test_divide - ZeroDivisionError: division by zero
+`,
+			want: []report.Annotation{
+				{Path: "tests/test_calc.py", Line: 7, Message: "assert 3 == 4"},
+				{Path: "calc/ops.py", Line: 9, Message: "ZeroDivisionError: division by zero"},
+			},
+		},
+		{
+			name: "jest",
+			output: `FAIL src/sum.test.ts
+  ● sum › adds numbers
+
+    expect(received).toBe(expected) // Object.is equality
+
+    Expected: 4
+    Received: 3
+
+      3 | test('adds numbers', () => {
+    > 4 |   expect(sum(1, 2)).toBe(4);
+        |                     ^
+      5 | });
+
+      at Object.<anonymous> (src/sum.test.ts:4:21)
+
+  ● sum › rejects strings
+
+    TypeError: Cannot read properties of undefined (reading 'length')
+
+      at length (node_modules/lodash/size.js:12:7)
+      at sum (src/sum.ts:2:10)
+      at Object.<anonymous> (src/sum.test.ts:9:5)
+
+Tests:       2 failed, 1 passed, 3 total
+`,
+			want: []report.Annotation{
+				{Path: "src/sum.test.ts", Line: 4, Column: 21, Message: "sum › adds numbers"},
+				{Path: "src/sum.ts", Line: 2, Column: 10, Message: "sum › rejects strings"},
+			},
+		},
+		{
+			name: "rspec",
+			output: `Failures:
+
+  1) User is valid with a name
+     Failure/Error: expect(user).to be_valid
+       expected #<User id: nil, name: nil> to be valid, but got errors: Name can't be blank
+     # ./spec/models/user_spec.rb:8:in 'block (2 levels) in <top (required)>'
+
+Finished in 0.04 seconds (files took 1.2 seconds to load)
+3 examples, 1 failure
+
+Failed examples:
+
+rspec ./spec/models/user_spec.rb:6 # User is valid with a name
+`,
+			want: []report.Annotation{
+				{Path: "spec/models/user_spec.rb", Line: 8, Message: "expect(user).to be_valid"},
+				{Path: "spec/models/user_spec.rb", Line: 6, Message: "User is valid with a name"},
+			},
+		},
+		{
+			name: "locations in prose are not annotations",
+			output: `make: *** [Makefile:12: test] Error 1
+see docs/setup.md:4 for help
+error: could not open config.go:12
+`,
+			want: nil,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Annotations(tc.output); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Annotations() =\n%+v\nwant\n%+v", got, tc.want)
+			}
+		})
+	}
+}
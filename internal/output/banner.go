@@ -0,0 +1,78 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// Banner keeps a message pinned above the results of a finished run, as
+// `run --watch` does while a workflow does not parse. On a terminal the
+// banner is drawn at the top of the screen and replaced in place; other
+// output gets it appended.
+type Banner struct {
+	out      io.Writer
+	terminal bool
+	// lines counts the banner lines on screen, which a new banner replaces.
+	lines int
+}
+
+// NewBanner returns a Banner writing to out, pinning it at the top of the
+// screen when terminal is true.
+func NewBanner(out io.Writer, terminal bool) *Banner {
+	return &Banner{out: out, terminal: terminal}
+}
+
+// Reset starts a fresh screen for the next run. On a terminal it clears the
+// screen, taking any pinned banner with it.
+func (b *Banner) Reset() {
+	if b.terminal {
+		fmt.Fprint(b.out, "\033[H\033[2J")
+	}
+	b.lines = 0
+}
+
+// ShowParseError pins err above what is on screen, replacing the banner
+// shown before it. lastGood says whether the results below are from an
+// earlier run that parsed.
+func (b *Banner) ShowParseError(err *provider.ParseError, lastGood bool) error {
+	where := err.Path
+	if err.Line > 0 {
+		where = fmt.Sprintf("%s line %d", err.Path, err.Line)
+	}
+	note := "not running until the file parses"
+	if lastGood {
+		note = "not rerunning until the file parses; the results below are from the last good run"
+	}
+	return b.show([]string{fmt.Sprintf("PARSE ERROR: %s: %s", where, err.Message), "  " + note})
+}
+
+func (b *Banner) show(lines []string) error {
+	text := strings.Join(lines, "\n") + "\n"
+	if !b.terminal {
+		_, err := io.WriteString(b.out, text)
+		return err
+	}
+	// Save the cursor, replace the old banner's lines at the top with the
+	// new ones, then return below the results, which moved by the
+	// difference.
+	var buf strings.Builder
+	buf.WriteString("\0337\033[H")
+	if b.lines > 0 {
+		fmt.Fprintf(&buf, "\033[%dM", b.lines)
+	}
+	fmt.Fprintf(&buf, "\033[%dL", len(lines))
+	buf.WriteString(text)
+	buf.WriteString("\0338")
+	switch shift := len(lines) - b.lines; {
+	case shift > 0:
+		fmt.Fprintf(&buf, "\033[%dB", shift)
+	case shift < 0:
+		fmt.Fprintf(&buf, "\033[%dA", -shift)
+	}
+	b.lines = len(lines)
+	_, err := io.WriteString(b.out, buf.String())
+	return err
+}
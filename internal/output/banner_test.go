@@ -0,0 +1,47 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestBannerAppendsParseErrorOutsideTerminal(t *testing.T) {
+	var buf bytes.Buffer
+	banner := NewBanner(&buf, false)
+	banner.Reset()
+	if err := banner.ShowParseError(&provider.ParseError{Path: ".github/workflows/ci.yml", Line: 7, Message: "could not find expected ':'"}, true); err != nil {
+		t.Fatalf("ShowParseError: %v", err)
+	}
+	if err := banner.ShowParseError(&provider.ParseError{Path: "ci.yml", Message: "no jobs"}, false); err != nil {
+		t.Fatalf("ShowParseError: %v", err)
+	}
+	want := "PARSE ERROR: .github/workflows/ci.yml line 7: could not find expected ':'\n" +
+		"  not rerunning until the file parses; the results below are from the last good run\n" +
+		"PARSE ERROR: ci.yml: no jobs\n" +
+		"  not running until the file parses\n"
+	if buf.String() != want {
+		t.Fatalf("banner output:\n%q\nwant:\n%q", buf.String(), want)
+	}
+}
+
+func TestBannerPinsParseErrorOnTerminal(t *testing.T) {
+	var buf bytes.Buffer
+	banner := NewBanner(&buf, true)
+	banner.Reset()
+	parseErr := &provider.ParseError{Path: "ci.yml", Line: 2, Message: "bad"}
+	if err := banner.ShowParseError(parseErr, false); err != nil {
+		t.Fatalf("ShowParseError: %v", err)
+	}
+	if err := banner.ShowParseError(parseErr, false); err != nil {
+		t.Fatalf("ShowParseError: %v", err)
+	}
+	text := "PARSE ERROR: ci.yml line 2: bad\n  not running until the file parses\n"
+	want := "\033[H\033[2J" +
+		"\0337\033[H\033[2L" + text + "\0338\033[2B" +
+		"\0337\033[H\033[2M\033[2L" + text + "\0338"
+	if buf.String() != want {
+		t.Fatalf("banner output:\n%q\nwant:\n%q", buf.String(), want)
+	}
+}
@@ -0,0 +1,83 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// CompletionRenderer lists jobs and steps one per line as tab-separated
+// fields, for shell completion and pickers such as fzf:
+//
+//	job	<workflow>	<job id>	<job name>
+//	step	<workflow>	<job id>	<step number>	<step name>
+//
+// Step numbers count from one over every step the job declares, uses:
+// steps included. There is no header or decoration, and tabs or newlines
+// inside names become spaces.
+type CompletionRenderer struct {
+	out io.Writer
+	// Declared holds the workflows before filtering. When set, steps are
+	// numbered by their place there, so numbers stay put when filters hide
+	// other steps.
+	Declared []provider.Workflow
+}
+
+// NewCompletion creates a completion renderer writing to out.
+func NewCompletion(out io.Writer) *CompletionRenderer {
+	return &CompletionRenderer{out: out}
+}
+
+// RenderList writes a line per job and per run: step. uses: steps only
+// count towards the step numbers, since they cannot be selected.
+func (c *CompletionRenderer) RenderList(workflows []provider.Workflow) error {
+	declared := make(map[[2]string][]provider.Step)
+	for _, wf := range c.Declared {
+		for _, job := range wf.Jobs {
+			declared[[2]string{wf.Path, job.Name}] = job.Steps
+		}
+	}
+
+	w := bufio.NewWriter(c.out)
+	for _, wf := range workflows {
+		path := completionField(slashPath(wf.Path))
+		for _, job := range wf.Jobs {
+			id := completionField(job.RawID)
+			fmt.Fprintf(w, "job\t%s\t%s\t%s\n", path, id, completionField(job.Name))
+			all, ok := declared[[2]string{wf.Path, job.Name}]
+			if !ok {
+				all = job.Steps
+			}
+			// Kept steps appear in declared order, so one pass over the
+			// declared steps finds each one's place.
+			next := 0
+			for _, step := range job.Steps {
+				if step.Run == "" {
+					continue
+				}
+				for next < len(all) && (all[next].Run == "" || all[next].Name != step.Name) {
+					next++
+				}
+				if next == len(all) {
+					break
+				}
+				next++
+				fmt.Fprintf(w, "step\t%s\t%s\t%d\t%s\n", path, id, next, completionField(step.Name))
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// completionField keeps a value on its own line and in its own column.
+func completionField(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return ' '
+		}
+		return r
+	}, s)
+}
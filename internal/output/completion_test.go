@@ -0,0 +1,35 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestCompletionRenderList(t *testing.T) {
+	declared := []provider.Workflow{{
+		Path: `.github\workflows\ci.yml`,
+		Jobs: []provider.Job{{RawID: "test", Name: "Test Suite", Steps: []provider.Step{
+			{Name: "Checkout", Uses: "actions/checkout@v4"},
+			{Name: "Install", Run: "bundle install"},
+			{Name: "Run\trspec\nnow", Run: "bundle exec rspec"},
+		}}},
+	}}
+	filtered := []provider.Workflow{{
+		Path: declared[0].Path,
+		Jobs: []provider.Job{{RawID: "test", Name: "Test Suite", Steps: []provider.Step{declared[0].Jobs[0].Steps[2]}}},
+	}}
+
+	var buf bytes.Buffer
+	renderer := NewCompletion(&buf)
+	renderer.Declared = declared
+	if err := renderer.RenderList(filtered); err != nil {
+		t.Fatalf("RenderList: %v", err)
+	}
+	want := "job\t.github/workflows/ci.yml\ttest\tTest Suite\n" +
+		"step\t.github/workflows/ci.yml\ttest\t3\tRun rspec now\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,147 @@
+package output
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/patterns"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// failingResult is a Go test failure reported on stdout, with shell noise on
+// stderr and a hint from the runner.
+func failingResult() report.StepResult {
+	return report.StepResult{
+		WorkflowPath: "ci.yml",
+		WorkflowName: "CI",
+		JobName:      "test",
+		StepName:     "Unit",
+		StepRun:      "go test ./...",
+		Status:       "failed",
+		Duration:     time.Second,
+		Stdout:       "=== RUN   TestAdd\n    add_test.go:9: got 3, want 4\n--- FAIL: TestAdd (0.00s)\nFAIL\nFAIL\texample.com/calc\t0.002s\n",
+		Stderr:       "asdf: the Bash implementation is deprecated\n",
+		ExitCode:     1,
+		Hint:         "run `go mod download` if packages are missing",
+	}
+}
+
+// TestFailureBlockMatchesAcrossRenderers renders one failure through every
+// pretty renderer and checks each shows the golden block under the step.
+func TestFailureBlockMatchesAcrossRenderers(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "golden", "failure_block.txt"))
+	if err != nil {
+		t.Fatalf("read golden: %v", err)
+	}
+	want := strings.TrimRight(string(data), "\n")
+	res := failingResult()
+
+	if got := FormatFailure(res); got != want {
+		t.Fatalf("FormatFailure mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	block := Indent(want, "      ") + "\n"
+
+	var batch bytes.Buffer
+	renderer := NewPretty(&batch)
+	renderer.ShowStdoutOnFailure = true
+	if err := renderer.RenderResults([]report.StepResult{res}, report.Summary{Failed: 1}); err != nil {
+		t.Fatalf("RenderResults: %v", err)
+	}
+	if !strings.Contains(batch.String(), "    ✗ Unit (1s)\n"+block) {
+		t.Fatalf("batch output lacks the failure block:\n%s", batch.String())
+	}
+
+	var streamed bytes.Buffer
+	stream := NewStreamingPretty(&streamed)
+	wf := provider.Workflow{Path: "ci.yml", Name: "CI", Jobs: []provider.Job{{Name: "test", RawID: "test", Steps: []provider.Step{{Name: "Unit", Run: res.StepRun}}}}}
+	id := JobID(wf, wf.Jobs[0])
+	for _, err := range []error{
+		stream.InitializeAllJobs([]provider.Workflow{wf}),
+		stream.StartJob(id),
+		stream.StartStep(id, "Unit"),
+		stream.CompleteStep(id, "Unit", res),
+		stream.CompleteJob(id),
+	} {
+		if err != nil {
+			t.Fatalf("streaming: %v", err)
+		}
+	}
+	if !strings.Contains(streamed.String(), "    ❌ Unit (1s)\n"+block) {
+		t.Fatalf("streaming output lacks the failure block:\n%s", streamed.String())
+	}
+}
+
+func TestFormatFailureLabelsStreams(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		res  report.StepResult
+		want string
+	}{
+		{"both", report.StepResult{Stdout: "FAIL TestAdd\n", Stderr: "exit status 1\n"}, "stdout:\n  FAIL TestAdd\nstderr:\n  exit status 1"},
+		{"stderr only", report.StepResult{Stderr: "error: boom\n"}, "stderr:\n  error: boom"},
+		{"noise only", report.StepResult{Stderr: "asdf: the Bash implementation is deprecated\n"}, suppressedOutput},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := FormatFailure(tc.res); got != tc.want {
+				t.Fatalf("FormatFailure = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatFailureUsesCombinedOutput(t *testing.T) {
+	res := report.StepResult{
+		StepRun:        "make test",
+		Stdout:         "ignored\n",
+		CombinedOutput: "compiling\nerror: undefined x\nmake: *** [test] Error 1\n",
+	}
+	want := "Command: make test\noutput:\n  error: undefined x\n  make: *** [test] Error 1"
+	if got := FormatFailure(res); got != want {
+		t.Fatalf("FormatFailure = %q, want %q", got, want)
+	}
+}
+
+func TestFormatFailureIndentsMultiLineCommand(t *testing.T) {
+	res := report.StepResult{
+		StepRun: "bundle install\nbundle exec rspec\n",
+		Stderr:  "error: boom\n",
+	}
+	want := "Command: bundle install\n  bundle exec rspec\nstderr:\n  error: boom"
+	if got := FormatFailure(res); got != want {
+		t.Fatalf("FormatFailure = %q, want %q", got, want)
+	}
+}
+
+func TestFormatFailureListsAnnotations(t *testing.T) {
+	res := report.StepResult{
+		StepRun: "go test ./...",
+		Stderr:  "FAIL\n",
+		Annotations: []report.Annotation{
+			{Path: "cart/cart_test.go", Line: 31, Message: "Total() = 12, want 15"},
+			{Path: "cart/cart.go", Line: 14, Column: 2},
+		},
+		Hint: "run with -v",
+	}
+	want := "Command: go test ./...\nstderr:\n  FAIL\nat cart/cart_test.go:31: Total() = 12, want 15\nat cart/cart.go:14:2\nhint: run with -v"
+	if got := FormatFailure(res); got != want {
+		t.Fatalf("FormatFailure = %q, want %q", got, want)
+	}
+}
+
+func TestSetNoisePatterns(t *testing.T) {
+	t.Cleanup(func() { SetNoisePatterns(patterns.Exprs(patterns.Builtin(patterns.Noise))) })
+	output := "Spring preloader in process 42\nasdf: the Bash implementation is deprecated\n"
+
+	if got := cleanErrorOutput(output); got != "Spring preloader in process 42" {
+		t.Fatalf("expected only the built-in noise dropped, got %q", got)
+	}
+	SetNoisePatterns([]string{`^Spring preloader`, "", "("})
+	if got := cleanErrorOutput(output); got != "asdf: the Bash implementation is deprecated" {
+		t.Fatalf("expected the configured set to replace the built-in one, got %q", got)
+	}
+}
@@ -0,0 +1,95 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StatusStyle is how renderers show one step or job status. Color is an
+// ANSI SGR code for renderers that color their output.
+type StatusStyle struct {
+	Glyph string
+	Emoji string
+	Word  string
+	Color string
+}
+
+// statusStyles maps every status a renderer can be handed to its style.
+// Statuses not listed render with unknownStatus.
+var statusStyles = map[string]StatusStyle{
+	"passed":  {Glyph: "✓", Emoji: "✅", Word: "passed", Color: "32"},
+	"failed":  {Glyph: "✗", Emoji: "❌", Word: "failed", Color: "31"},
+	"skipped": {Glyph: "-", Emoji: "⏭️", Word: "skipped", Color: "33"},
+	"running": {Glyph: "*", Emoji: "🟢", Word: "running", Color: "34"},
+	"pending": {Glyph: ".", Emoji: "⏳", Word: "pending", Color: "90"},
+	"dry-run": {Glyph: "-", Emoji: "📝", Word: "dry run", Color: "36"},
+	"xfail":   {Glyph: "x", Emoji: "🚧", Word: "expected failure", Color: "33"},
+	"xpass":   {Glyph: "!", Emoji: "❗", Word: "unexpectedly passed", Color: "35"},
+}
+
+var unknownStatus = StatusStyle{Glyph: "?", Emoji: "❓", Word: "unknown", Color: "35"}
+
+// Style returns the style of status.
+func Style(status string) StatusStyle {
+	if style, ok := statusStyles[status]; ok {
+		return style
+	}
+	return unknownStatus
+}
+
+// StatusGlyph returns the plain-text marker for status, as batch output
+// prints it in front of each step.
+func StatusGlyph(status string) string {
+	return Style(status).Glyph
+}
+
+// DecorateName shows a workflow as "Name (path)", or just its path when it
+// has no name of its own.
+func DecorateName(name, path string) string {
+	if name == "" || name == path {
+		return path
+	}
+	return fmt.Sprintf("%s (%s)", name, path)
+}
+
+// Indent trims s and prefixes each of its lines with pad. It returns "" for
+// blank input.
+func Indent(s, pad string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		lines[i] = pad + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatDuration renders d at a precision that suits its size: "<1ms",
+// "840ms", "4.2s", "12s", "3m12s", "1h02m". Larger units drop the smaller
+// ones and nothing is rounded up, so a step never reads as longer than it
+// took.
+// Zero and negative durations, as for steps that did not run, are "0s".
+// The output uses no locale-dependent separators.
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "0s"
+	case d < time.Millisecond:
+		return "<1ms"
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	case d < time.Minute:
+		tenths := d / (100 * time.Millisecond)
+		if tenths%10 == 0 {
+			return fmt.Sprintf("%ds", tenths/10)
+		}
+		return fmt.Sprintf("%d.%ds", tenths/10, tenths%10)
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", d/time.Minute, d%time.Minute/time.Second)
+	default:
+		return fmt.Sprintf("%dh%02dm", d/time.Hour, d%time.Hour/time.Minute)
+	}
+}
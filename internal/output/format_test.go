@@ -0,0 +1,76 @@
+package output
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	cases := []struct {
+		in   time.Duration
+		want string
+	}{
+		{-time.Second, "0s"},
+		{0, "0s"},
+		{time.Nanosecond, "<1ms"},
+		{999 * time.Microsecond, "<1ms"},
+		{time.Millisecond, "1ms"},
+		{840*time.Millisecond + 900*time.Microsecond, "840ms"},
+		{999 * time.Millisecond, "999ms"},
+		{time.Second, "1s"},
+		{12*time.Second + 50*time.Millisecond, "12s"},
+		{4*time.Second + 270*time.Millisecond, "4.2s"},
+		{59*time.Second + 999*time.Millisecond, "59.9s"},
+		{time.Minute, "1m00s"},
+		{3*time.Minute + 12*time.Second + 900*time.Millisecond, "3m12s"},
+		{59*time.Minute + 59*time.Second, "59m59s"},
+		{time.Hour, "1h00m"},
+		{time.Hour + 2*time.Minute + 3*time.Second, "1h02m"},
+		{26*time.Hour + 30*time.Minute, "26h30m"},
+	}
+	for _, tc := range cases {
+		if got := FormatDuration(tc.in); got != tc.want {
+			t.Errorf("FormatDuration(%v) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestStatusStyles(t *testing.T) {
+	cases := []struct {
+		status string
+		want   StatusStyle
+	}{
+		{"passed", StatusStyle{Glyph: "✓", Emoji: "✅", Word: "passed", Color: "32"}},
+		{"failed", StatusStyle{Glyph: "✗", Emoji: "❌", Word: "failed", Color: "31"}},
+		{"skipped", StatusStyle{Glyph: "-", Emoji: "⏭️", Word: "skipped", Color: "33"}},
+		{"running", StatusStyle{Glyph: "*", Emoji: "🟢", Word: "running", Color: "34"}},
+		{"pending", StatusStyle{Glyph: ".", Emoji: "⏳", Word: "pending", Color: "90"}},
+		{"dry-run", StatusStyle{Glyph: "-", Emoji: "📝", Word: "dry run", Color: "36"}},
+		{"xfail", StatusStyle{Glyph: "x", Emoji: "🚧", Word: "expected failure", Color: "33"}},
+		{"xpass", StatusStyle{Glyph: "!", Emoji: "❗", Word: "unexpectedly passed", Color: "35"}},
+		{"bogus", StatusStyle{Glyph: "?", Emoji: "❓", Word: "unknown", Color: "35"}},
+	}
+	for _, tc := range cases {
+		if got := Style(tc.status); got != tc.want {
+			t.Errorf("Style(%q) = %+v, want %+v", tc.status, got, tc.want)
+		}
+		if got := StatusGlyph(tc.status); got != tc.want.Glyph {
+			t.Errorf("StatusGlyph(%q) = %q, want %q", tc.status, got, tc.want.Glyph)
+		}
+	}
+}
+
+func TestDecorateNameAndIndent(t *testing.T) {
+	if got := DecorateName("CI", "ci.yml"); got != "CI (ci.yml)" {
+		t.Fatalf("unexpected decorated name %q", got)
+	}
+	if got := DecorateName("", "ci.yml"); got != "ci.yml" {
+		t.Fatalf("expected the bare path, got %q", got)
+	}
+	if got := Indent("\n one\ntwo\n", "  "); got != "  one\n  two" {
+		t.Fatalf("unexpected indent %q", got)
+	}
+	if got := Indent(" \n", "  "); got != "" {
+		t.Fatalf("expected blank input to stay blank, got %q", got)
+	}
+}
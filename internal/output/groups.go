@@ -0,0 +1,77 @@
+package output
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// groupMarker matches an explicit "# testdrive:group <name>" suffix.
+	groupMarker = regexp.MustCompile(`\s*#\s*testdrive:group\s+(.+?)\s*$`)
+	// groupPrefix matches a "<word>: rest" step name.
+	groupPrefix = regexp.MustCompile(`^([A-Za-z][\w-]*):\s+(\S.*)$`)
+)
+
+// stepGroup is a run of consecutive steps rendered together. Ungrouped steps
+// form a group of one with an empty name.
+type stepGroup struct {
+	name    string
+	indexes []int
+	labels  []string
+}
+
+// groupSteps folds consecutive steps into groups for display. A step named
+// with an explicit "# testdrive:group <name>" marker always joins that
+// group; with byPrefix, two or more consecutive steps sharing a "<word>:"
+// prefix are folded as well. Grouping is presentation only and never
+// reorders steps.
+func groupSteps(names []string, byPrefix bool) []stepGroup {
+	type parsed struct {
+		group, label string
+		explicit     bool
+	}
+	items := make([]parsed, len(names))
+	for i, name := range names {
+		if m := groupMarker.FindStringSubmatchIndex(name); m != nil {
+			items[i] = parsed{group: name[m[2]:m[3]], label: strings.TrimSpace(name[:m[0]]), explicit: true}
+			continue
+		}
+		if byPrefix {
+			if m := groupPrefix.FindStringSubmatch(name); m != nil {
+				items[i] = parsed{group: m[1], label: m[2]}
+				continue
+			}
+		}
+		items[i] = parsed{label: name}
+	}
+
+	var groups []stepGroup
+	for i := 0; i < len(items); {
+		j := i + 1
+		if items[i].group != "" {
+			for j < len(items) && items[j].group == items[i].group {
+				j++
+			}
+		}
+		explicit := false
+		for k := i; k < j; k++ {
+			explicit = explicit || items[k].explicit
+		}
+		if items[i].group == "" || (j-i < 2 && !explicit) {
+			// A lone prefixed step reads better flat, with its full name.
+			for k := i; k < j; k++ {
+				groups = append(groups, stepGroup{indexes: []int{k}, labels: []string{names[k]}})
+			}
+			i = j
+			continue
+		}
+		g := stepGroup{name: items[i].group}
+		for k := i; k < j; k++ {
+			g.indexes = append(g.indexes, k)
+			g.labels = append(g.labels, items[k].label)
+		}
+		groups = append(groups, g)
+		i = j
+	}
+	return groups
+}
@@ -0,0 +1,78 @@
+package output
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestGroupSteps(t *testing.T) {
+	names := []string{
+		"Setup: ruby",
+		"Setup: node",
+		"Build",
+		"Test: unit",
+		"Teardown: db",
+		"Docs # testdrive:group Release",
+		"Release: gem",
+	}
+
+	got := groupSteps(names, true)
+	want := []stepGroup{
+		{name: "Setup", indexes: []int{0, 1}, labels: []string{"ruby", "node"}},
+		{indexes: []int{2}, labels: []string{"Build"}},
+		{indexes: []int{3}, labels: []string{"Test: unit"}},
+		{indexes: []int{4}, labels: []string{"Teardown: db"}},
+		{name: "Release", indexes: []int{5, 6}, labels: []string{"Docs", "gem"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("groupSteps(byPrefix) =\n%+v\nwant\n%+v", got, want)
+	}
+
+	got = groupSteps(names, false)
+	if len(got) != 7 || got[5].name != "Release" || got[5].labels[0] != "Docs" || got[0].labels[0] != "Setup: ruby" {
+		t.Fatalf("without byPrefix only explicit markers should group, got %+v", got)
+	}
+
+	if got := groupSteps([]string{"Build", "Lint"}, true); len(got) != 2 || got[0].name != "" || got[1].name != "" {
+		t.Fatalf("expected flat output without prefixes, got %+v", got)
+	}
+}
+
+func TestRenderResultsGroupedAggregates(t *testing.T) {
+	step := func(name, status string, d time.Duration) report.StepResult {
+		return report.StepResult{WorkflowName: "CI", JobName: "test", StepName: name, Status: status, Duration: d}
+	}
+	results := []report.StepResult{
+		step("Setup: ruby", "passed", time.Second),
+		step("Setup: node", "failed", 2*time.Second),
+		step("Lint", "passed", time.Second),
+	}
+	results[1].Stderr = "boom"
+	summary := report.Summary{Passed: 2, Failed: 1, TotalSteps: 3, Duration: 4 * time.Second}
+
+	var buf bytes.Buffer
+	r := NewPretty(&buf)
+	r.GroupByPrefix = true
+	if err := r.RenderResults(results, summary); err != nil {
+		t.Fatalf("RenderResults: %v", err)
+	}
+	want := "Workflow CI ()\n" +
+		"  Job test\n" +
+		"    ✗ Setup (2 steps, 3s)\n" +
+		"      ✓ ruby (1s)\n" +
+		"      ✗ node (2s)\n" +
+		"        stderr:\n" +
+		"          boom\n" +
+		"    ✓ Lint (1s)\n"
+	if got := buf.String(); !strings.HasPrefix(got, want) {
+		t.Fatalf("unexpected output:\n%s\nwant prefix:\n%s", got, want)
+	}
+	if !strings.Contains(buf.String(), "SUMMARY: 2 passed, 1 failed, 0 skipped") {
+		t.Fatalf("summary must count individual steps:\n%s", buf.String())
+	}
+}
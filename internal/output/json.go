@@ -3,6 +3,7 @@ package output
 import (
 	"encoding/json"
 	"io"
+	"strings"
 
     "github.com/bgricker/testdrive/internal/provider"
     "github.com/bgricker/testdrive/internal/report"
@@ -11,6 +12,8 @@ import (
 // JSONRenderer emits structured execution data.
 type JSONRenderer struct {
 	out io.Writer
+	// Compact writes each document on a single line instead of indenting.
+	Compact bool
 }
 
 // NewJSON creates a JSON renderer writing to out.
@@ -20,16 +23,137 @@ func NewJSON(out io.Writer) *JSONRenderer {
 
 // Report captures JSON output schema.
 type Report struct {
-	Provider  string              `json:"provider"`
-	Workflows []provider.Workflow `json:"workflows"`
-	Steps     []report.StepResult `json:"steps,omitempty"`
-	Summary   report.Summary      `json:"summary"`
-	Warnings  []string            `json:"warnings,omitempty"`
+	Provider      string                `json:"provider"`
+	Workflows     []provider.Workflow   `json:"workflows"`
+	Steps         []report.StepResult   `json:"steps,omitempty"`
+	Summary       report.Summary        `json:"summary"`
+	Coverage      *report.Coverage      `json:"coverage,omitempty"`
+	LocalCoverage *report.LocalCoverage `json:"local_coverage,omitempty"`
+	Plan          *report.Plan          `json:"plan,omitempty"`
+	Versions      []report.VersionCheck `json:"versions,omitempty"`
+	Warnings      []string              `json:"warnings,omitempty"`
+	Infos         []string              `json:"infos,omitempty"`
+	// Overruns lists jobs that ran longer than their CI timeout or time
+	// budget.
+	Overruns []report.BudgetOverrun `json:"time_budget_overruns,omitempty"`
+	// Meta names the checkout the report was produced from. It is nil
+	// outside a git repository.
+	Meta *report.Meta `json:"meta,omitempty"`
+	// Repos holds each repository's results in a run across a manifest.
+	Repos []report.RepoRun `json:"repos,omitempty"`
+	// Invocation records how a run was started. Only run reports carry it.
+	Invocation *report.Invocation `json:"invocation,omitempty"`
 }
 
-// Render encodes the report as JSON.
+// Render encodes the report as JSON. Paths are written with forward slashes
+// so reports produced on Windows diff cleanly against macOS and Linux ones.
 func (j *JSONRenderer) Render(report Report) error {
+	return j.Encode(normalizeReport(report))
+}
+
+// Encode writes v as a JSON document. Map keys are always sorted, so the same
+// value encodes to the same bytes.
+func (j *JSONRenderer) Encode(v interface{}) error {
 	enc := json.NewEncoder(j.out)
-	enc.SetIndent("", "  ")
-	return enc.Encode(report)
+	if !j.Compact {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
+}
+
+// normalizeReport returns a copy of r with every path field slash-separated.
+func normalizeReport(r Report) Report {
+	if r.Workflows != nil {
+		workflows := make([]provider.Workflow, len(r.Workflows))
+		for i, wf := range r.Workflows {
+			wf.Path = slashPath(wf.Path)
+			workflows[i] = wf
+		}
+		r.Workflows = workflows
+	}
+	if r.Steps != nil {
+		steps := make([]report.StepResult, len(r.Steps))
+		for i, step := range r.Steps {
+			step.WorkflowPath = slashPath(step.WorkflowPath)
+			steps[i] = step
+		}
+		r.Steps = steps
+	}
+	if r.Summary.Jobs != nil {
+		jobs := make([]report.JobSummary, len(r.Summary.Jobs))
+		for i, job := range r.Summary.Jobs {
+			job.WorkflowPath = slashPath(job.WorkflowPath)
+			jobs[i] = job
+		}
+		r.Summary.Jobs = jobs
+	}
+	if r.Overruns != nil {
+		overruns := make([]report.BudgetOverrun, len(r.Overruns))
+		for i, o := range r.Overruns {
+			o.WorkflowPath = slashPath(o.WorkflowPath)
+			overruns[i] = o
+		}
+		r.Overruns = overruns
+	}
+	if r.Repos != nil {
+		repos := make([]report.RepoRun, len(r.Repos))
+		for i, repo := range r.Repos {
+			inner := normalizeReport(Report{Steps: repo.Steps, Summary: repo.Summary})
+			repo.Path = slashPath(repo.Path)
+			repo.Steps, repo.Summary = inner.Steps, inner.Summary
+			repos[i] = repo
+		}
+		r.Repos = repos
+	}
+	if r.Coverage != nil {
+		cov := *r.Coverage
+		if cov.Skipped != nil {
+			cov.Skipped = make([]report.SkippedStep, len(r.Coverage.Skipped))
+			for i, s := range r.Coverage.Skipped {
+				s.WorkflowPath = slashPath(s.WorkflowPath)
+				cov.Skipped[i] = s
+			}
+		}
+		r.Coverage = &cov
+	}
+	if r.LocalCoverage != nil {
+		cov := *r.LocalCoverage
+		cov.Workflows = make([]report.WorkflowCoverage, len(r.LocalCoverage.Workflows))
+		for i, wf := range r.LocalCoverage.Workflows {
+			wf.WorkflowPath = slashPath(wf.WorkflowPath)
+			cov.Workflows[i] = wf
+		}
+		r.LocalCoverage = &cov
+	}
+	if r.Plan != nil {
+		plan := *r.Plan
+		plan.Steps = make([]report.PlannedStep, len(r.Plan.Steps))
+		for i, s := range r.Plan.Steps {
+			s.WorkflowPath = slashPath(s.WorkflowPath)
+			plan.Steps[i] = s
+		}
+		if r.Plan.Dropped != nil {
+			plan.Dropped = make([]report.SkippedStep, len(r.Plan.Dropped))
+			for i, s := range r.Plan.Dropped {
+				s.WorkflowPath = slashPath(s.WorkflowPath)
+				plan.Dropped[i] = s
+			}
+		}
+		r.Plan = &plan
+	}
+	if r.Versions != nil {
+		versions := make([]report.VersionCheck, len(r.Versions))
+		for i, v := range r.Versions {
+			v.RequiredSource = slashPath(v.RequiredSource)
+			versions[i] = v
+		}
+		r.Versions = versions
+	}
+	return r
+}
+
+// slashPath converts Windows separators to forward slashes. It does not rely
+// on filepath.ToSlash so reports normalize the same way on every platform.
+func slashPath(p string) string {
+	return strings.ReplaceAll(p, "\\", "/")
 }
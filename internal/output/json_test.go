@@ -3,6 +3,7 @@ package output
 import (
 	"bytes"
 	"encoding/json"
+	"strings"
 	"testing"
 
     "github.com/bgricker/testdrive/internal/provider"
@@ -44,3 +45,86 @@ func TestJSONRenderer(t *testing.T) {
 		t.Fatalf("expected warnings serialized")
 	}
 }
+
+func envReport() Report {
+	env := map[string]string{}
+	for _, k := range []string{"ZED", "ALPHA", "MIDDLE", "BETA", "GO_VERSION", "NODE_ENV", "CI"} {
+		env[k] = strings.ToLower(k)
+	}
+	return Report{
+		Provider: "github",
+		Workflows: []provider.Workflow{{
+			Path: `.github\workflows\ci.yml`,
+			Name: "CI",
+			Env:  env,
+			Jobs: []provider.Job{{Name: "build", RawID: "build", Env: env, Steps: []provider.Step{{Name: "Compile", Run: "make", Env: env}}}},
+		}},
+		Steps: []report.StepResult{{WorkflowPath: `.github\workflows\ci.yml`, WorkflowName: "CI", JobName: "build", StepName: "Compile"}},
+		Summary: report.Summary{
+			TotalWorkflows: 1,
+			Jobs:           []report.JobSummary{{WorkflowPath: `.github\workflows\ci.yml`, JobName: "build"}},
+		},
+		Coverage: &report.Coverage{Skipped: []report.SkippedStep{{WorkflowPath: `.github\workflows\ci.yml`, StepName: "Upload"}}},
+		Versions: []report.VersionCheck{{Tool: "ruby", RequiredSource: `backend\.ruby-version`}},
+	}
+}
+
+func TestJSONRendererDeterministic(t *testing.T) {
+	var first, second bytes.Buffer
+	if err := NewJSON(&first).Render(envReport()); err != nil {
+		t.Fatalf("render json: %v", err)
+	}
+	if err := NewJSON(&second).Render(envReport()); err != nil {
+		t.Fatalf("render json: %v", err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatalf("renders differ:\n%s\n---\n%s", first.String(), second.String())
+	}
+	alpha, zed := strings.Index(first.String(), `"ALPHA"`), strings.Index(first.String(), `"ZED"`)
+	if alpha < 0 || zed < alpha {
+		t.Fatalf("expected env keys in sorted order:\n%s", first.String())
+	}
+}
+
+func TestJSONRendererCompact(t *testing.T) {
+	buf := &bytes.Buffer{}
+	renderer := NewJSON(buf)
+	renderer.Compact = true
+	if err := renderer.Render(envReport()); err != nil {
+		t.Fatalf("render json: %v", err)
+	}
+	out := buf.String()
+	if strings.Count(out, "\n") != 1 || !strings.HasSuffix(out, "\n") {
+		t.Fatalf("expected a single line, got:\n%s", out)
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Fatalf("compact output is not valid JSON: %s", out)
+	}
+}
+
+func TestJSONRendererNormalizesWindowsPaths(t *testing.T) {
+	rep := envReport()
+	buf := &bytes.Buffer{}
+	if err := NewJSON(buf).Render(rep); err != nil {
+		t.Fatalf("render json: %v", err)
+	}
+	if strings.Contains(buf.String(), `\\`) {
+		t.Fatalf("expected forward slashes only:\n%s", buf.String())
+	}
+
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	const want = ".github/workflows/ci.yml"
+	if decoded.Workflows[0].Path != want || decoded.Steps[0].WorkflowPath != want ||
+		decoded.Summary.Jobs[0].WorkflowPath != want || decoded.Coverage.Skipped[0].WorkflowPath != want {
+		t.Fatalf("paths not normalized: %+v", decoded)
+	}
+	if decoded.Versions[0].RequiredSource != "backend/.ruby-version" {
+		t.Fatalf("version source not normalized: %+v", decoded.Versions)
+	}
+	if rep.Steps[0].WorkflowPath != `.github\workflows\ci.yml` {
+		t.Fatalf("Render must not modify the caller's report")
+	}
+}
@@ -0,0 +1,113 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// Stream events, in the order a run emits them. Every line of a JSON stream
+// is one object whose "event" field holds one of these.
+const (
+	EventStart        = "start"
+	EventJobStarted   = "job_started"
+	EventStepStarted  = "step_started"
+	EventStepFinished = "step_finished"
+	EventJobFinished  = "job_finished"
+	EventSummary      = "summary"
+	EventReport       = "report"
+)
+
+// StreamEvent is one line of a JSON stream. Fields other than Event are set
+// only for the events that carry them.
+type StreamEvent struct {
+	Event     string              `json:"event"`
+	Provider  string              `json:"provider,omitempty"`
+	Workflows []provider.Workflow `json:"workflows,omitempty"`
+	Job       string              `json:"job,omitempty"`
+	Step      string              `json:"step,omitempty"`
+	Result    *report.StepResult  `json:"result,omitempty"`
+	Summary   *report.Summary     `json:"summary,omitempty"`
+}
+
+// JSONStreamRenderer writes a run as newline-delimited JSON events while it
+// happens, so a consumer sees each step as it finishes and every line that
+// made it out before a crash still parses. The last line of a finished run
+// is the complete Report, with "event": "report" added. Each line is
+// flushed when out supports it. It is safe for concurrent use.
+type JSONStreamRenderer struct {
+	mu  sync.Mutex
+	out io.Writer
+	// Provider is reported in the start event.
+	Provider string
+}
+
+// NewJSONStream creates a JSON stream renderer writing to out.
+func NewJSONStream(out io.Writer) *JSONStreamRenderer {
+	return &JSONStreamRenderer{out: out}
+}
+
+// InitializeAllJobs emits the start event with the workflows about to run.
+func (j *JSONStreamRenderer) InitializeAllJobs(workflows []provider.Workflow) error {
+	return j.emit(StreamEvent{Event: EventStart, Provider: j.Provider, Workflows: normalizeReport(Report{Workflows: workflows}).Workflows})
+}
+
+// StartJob emits a job_started event.
+func (j *JSONStreamRenderer) StartJob(jobID string) error {
+	return j.emit(StreamEvent{Event: EventJobStarted, Job: slashPath(jobID)})
+}
+
+// InitializeWorkflow emits nothing; the start event already lists every job.
+func (j *JSONStreamRenderer) InitializeWorkflow(workflowName, jobName string, stepCount int) error {
+	return nil
+}
+
+// StartStep emits a step_started event.
+func (j *JSONStreamRenderer) StartStep(jobID, stepName string) error {
+	return j.emit(StreamEvent{Event: EventStepStarted, Job: slashPath(jobID), Step: stepName})
+}
+
+// CompleteStep emits a step_finished event carrying the step's result.
+func (j *JSONStreamRenderer) CompleteStep(jobID, stepName string, result report.StepResult) error {
+	result = normalizeReport(Report{Steps: []report.StepResult{result}}).Steps[0]
+	return j.emit(StreamEvent{Event: EventStepFinished, Job: slashPath(jobID), Step: stepName, Result: &result})
+}
+
+// CompleteJob emits a job_finished event.
+func (j *JSONStreamRenderer) CompleteJob(jobID string) error {
+	return j.emit(StreamEvent{Event: EventJobFinished, Job: slashPath(jobID)})
+}
+
+// RenderSummary emits the run's summary event.
+func (j *JSONStreamRenderer) RenderSummary(summary report.Summary) error {
+	summary = normalizeReport(Report{Summary: summary}).Summary
+	return j.emit(StreamEvent{Event: EventSummary, Summary: &summary})
+}
+
+// RenderReport emits the complete report as the stream's last line.
+func (j *JSONStreamRenderer) RenderReport(r Report) error {
+	return j.emit(struct {
+		Event string `json:"event"`
+		Report
+	}{EventReport, normalizeReport(r)})
+}
+
+// emit writes v as one line and flushes it.
+func (j *JSONStreamRenderer) emit(v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.out.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if f, ok := j.out.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestJSONStreamRenderer(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := NewJSONStream(buf)
+	r.Provider = "github"
+	wf := provider.Workflow{Path: `.github\workflows\ci.yml`, Name: "CI", Jobs: []provider.Job{{Name: "build", RawID: "build"}}}
+	jobID := JobID(wf, wf.Jobs[0])
+	result := report.StepResult{WorkflowPath: wf.Path, JobName: "build", StepName: "Compile", Status: "passed"}
+
+	steps := []func() error{
+		func() error { return r.InitializeAllJobs([]provider.Workflow{wf}) },
+		func() error { return r.StartJob(jobID) },
+		func() error { return r.InitializeWorkflow("CI", "build", 1) },
+		func() error { return r.StartStep(jobID, "Compile") },
+		func() error { return r.CompleteStep(jobID, "Compile", result) },
+		func() error { return r.CompleteJob(jobID) },
+		func() error { return r.RenderSummary(report.Summary{TotalSteps: 1, Passed: 1}) },
+		func() error {
+			return r.RenderReport(Report{Provider: "github", Workflows: []provider.Workflow{wf}, Steps: []report.StepResult{result}})
+		},
+	}
+	emitted := 0
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		// Whatever has been written so far must parse line by line, as a
+		// consumer cut off at this point would see it.
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		for _, line := range lines {
+			var event StreamEvent
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Fatalf("after call %d, line %q does not parse: %v", i, line, err)
+			}
+		}
+		// InitializeWorkflow, the third call, writes nothing.
+		if i != 2 {
+			emitted++
+		}
+		if len(lines) != emitted {
+			t.Fatalf("after call %d: %d lines, want %d:\n%s", i, len(lines), emitted, buf.String())
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	var events []StreamEvent
+	for _, line := range lines {
+		var event StreamEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Event)
+	}
+	want := []string{EventStart, EventJobStarted, EventStepStarted, EventStepFinished, EventJobFinished, EventSummary, EventReport}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", names, want)
+	}
+	if events[0].Provider != "github" || len(events[0].Workflows) != 1 || events[0].Workflows[0].Path != ".github/workflows/ci.yml" {
+		t.Fatalf("start event = %+v", events[0])
+	}
+	if events[1].Job != ".github/workflows/ci.yml#build" {
+		t.Fatalf("job_started job = %q", events[1].Job)
+	}
+	if res := events[3].Result; res == nil || res.Status != "passed" || res.WorkflowPath != ".github/workflows/ci.yml" {
+		t.Fatalf("step_finished result = %+v", res)
+	}
+	if s := events[5].Summary; s == nil || s.Passed != 1 {
+		t.Fatalf("summary event = %+v", s)
+	}
+
+	var final Report
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &final); err != nil {
+		t.Fatal(err)
+	}
+	if final.Provider != "github" || len(final.Steps) != 1 || final.Steps[0].WorkflowPath != ".github/workflows/ci.yml" {
+		t.Fatalf("final report = %+v", final)
+	}
+}
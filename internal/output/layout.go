@@ -0,0 +1,150 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// LayoutOptions shapes what the pretty renderers print. The zero value is
+// the default layout.
+type LayoutOptions struct {
+	// Indent is the number of spaces per level of the workflow, job and
+	// step tree; zero means 2.
+	Indent int
+	// HidePaths names workflows without the path DecorateName adds.
+	HidePaths bool
+	// Width cuts lines longer than this many columns short with "…"; zero
+	// never does.
+	Width int
+	// HidePassedDurations leaves the duration off passing steps.
+	HidePassedDurations bool
+}
+
+// pad returns the indentation of the given tree level.
+func (l LayoutOptions) pad(level int) string {
+	indent := l.Indent
+	if indent <= 0 {
+		indent = 2
+	}
+	return strings.Repeat(" ", indent*level)
+}
+
+// workflowName names a workflow, with its path unless HidePaths is set.
+func (l LayoutOptions) workflowName(name, path string) string {
+	if l.HidePaths && name != "" {
+		return name
+	}
+	return DecorateName(name, path)
+}
+
+// stepDuration returns a step's " (1.2s)" suffix, or "" for a passing step
+// when HidePassedDurations is set.
+func (l LayoutOptions) stepDuration(res report.StepResult) string {
+	if l.HidePassedDurations && res.Status == "passed" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", FormatDuration(res.Duration))
+}
+
+// writer returns out, wrapped to cut lines to Width when it is set.
+func (l LayoutOptions) writer(out io.Writer) io.Writer {
+	if l.Width <= 0 {
+		return out
+	}
+	return &fitWriter{out: out, width: l.Width}
+}
+
+// fitWriter cuts each line written through it to width columns, ending a
+// cut line with "…". Terminal escape sequences pass through uncounted and a
+// carriage return starts the line over, so in-place redraws keep working.
+// Lines may arrive split across writes.
+type fitWriter struct {
+	out   io.Writer
+	width int
+	// col is the columns written on the current line.
+	col int
+	// held is the last character that fits, kept back until it is known
+	// whether the line ends after it or needs the ellipsis in its place.
+	held []byte
+	// cut is set once the current line has been cut.
+	cut bool
+	// escape is set inside an escape sequence.
+	escape bool
+	// partial holds the start of a character split across writes.
+	partial []byte
+}
+
+func (w *fitWriter) Write(p []byte) (int, error) {
+	var buf []byte
+	data := append(w.partial, p...)
+	w.partial = nil
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 && !utf8.FullRune(data) {
+			w.partial = append([]byte(nil), data...)
+			break
+		}
+		char := data[:size]
+		data = data[size:]
+		switch {
+		case w.escape:
+			buf = append(buf, char...)
+			// CSI sequences end with a byte in @ to ~; ESC [ starts them.
+			if r != '[' && r >= '@' && r <= '~' {
+				w.escape = false
+			}
+		case r == '\033':
+			w.escape = true
+			buf = append(buf, char...)
+		case r == '\n' || r == '\r':
+			buf = append(buf, w.held...)
+			buf = append(buf, char...)
+			w.col, w.held, w.cut = 0, nil, false
+		case w.cut:
+		default:
+			width := runeWidth(r)
+			switch {
+			case w.held != nil && width > 0:
+				// The line goes on past width; the ellipsis takes the
+				// held character's place.
+				buf = append(buf, "…"...)
+				w.held, w.cut = nil, true
+			case w.held != nil:
+				w.held = append(w.held, char...)
+			case w.col+width < w.width:
+				buf = append(buf, char...)
+				w.col += width
+			case w.col+width == w.width:
+				w.held = append([]byte(nil), char...)
+			default:
+				// A wide character that would overflow the line.
+				buf = append(buf, "…"...)
+				w.cut = true
+			}
+		}
+	}
+	if _, err := w.out.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// runeWidth estimates the terminal columns r takes: none for combining
+// marks and variation selectors, two for the emoji status glyphs and other
+// wide characters, one otherwise.
+func runeWidth(r rune) int {
+	switch {
+	case unicode.Is(unicode.Mn, r), r == 0xFE0F, r == 0x200D:
+		return 0
+	case r >= 0x1F000, r >= 0x23E9 && r <= 0x23FA, r == 0x2705, r == 0x274C, r >= 0x2753 && r <= 0x2755,
+		r >= 0x1100 && r <= 0x115F, r >= 0x2E80 && r <= 0xA4CF, r >= 0xAC00 && r <= 0xD7A3, r >= 0xF900 && r <= 0xFAFF, r >= 0xFF00 && r <= 0xFF60:
+		return 2
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,59 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestFitWriter(t *testing.T) {
+	cases := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"short lines pass", []string{"abc\n", "de\n"}, "abc\nde\n"},
+		{"exact width kept", []string{"abcdef\n"}, "abcdef\n"},
+		{"long line cut", []string{"abcdefgh\n"}, "abcde…\n"},
+		{"split across writes", []string{"abc", "defgh", "\nxy\n"}, "abcde…\nxy\n"},
+		{"escapes not counted", []string{"\033[2K\rabcdef\n"}, "\033[2K\rabcdef\n"},
+		{"carriage return restarts", []string{"abcdefgh\rab\n"}, "abcde…\rab\n"},
+		{"emoji is two columns", []string{"✅ abcdef\n"}, "✅ ab…\n"},
+		{"wide character at the edge", []string{"abcde✅\n"}, "abcde…\n"},
+		{"split utf-8", []string{"abcd\xe2\x80", "\xa6xyz\n"}, "abcd……\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := LayoutOptions{Width: 6}.writer(&buf)
+			for _, s := range tc.writes {
+				if n, err := w.Write([]byte(s)); err != nil || n != len(s) {
+					t.Fatalf("Write(%q) = %d, %v", s, n, err)
+				}
+			}
+			if buf.String() != tc.want {
+				t.Fatalf("got %q, want %q", buf.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestPrettyLayout(t *testing.T) {
+	results := []report.StepResult{
+		{WorkflowName: "CI", WorkflowPath: "ci.yml", JobName: "test", StepName: "Unit", Status: "passed", Duration: 1500 * time.Millisecond},
+		{WorkflowName: "CI", WorkflowPath: "ci.yml", JobName: "test", StepName: "Lint", Status: "failed", Duration: 2 * time.Second, Stderr: "boom"},
+	}
+	var buf bytes.Buffer
+	layout := LayoutOptions{Indent: 4, HidePaths: true, HidePassedDurations: true}
+	if err := NewPrettyLayout(&buf, layout).RenderResults(results, report.Summary{}); err != nil {
+		t.Fatalf("render results: %v", err)
+	}
+	for _, want := range []string{"Workflow CI\n", "\n    Job test\n", "\n        ✓ Unit\n", "\n        ✗ Lint (2s)\n", "\n            stderr:\n              boom"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("output lacks %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// MarkdownRenderer emits GitHub-flavored markdown suitable for docs.
+type MarkdownRenderer struct {
+	out io.Writer
+	// TOC adds a linked table of contents ahead of the workflows.
+	TOC bool
+	// Meta, when set, names the checkout in a line above everything else.
+	Meta *report.Meta
+}
+
+// NewMarkdown creates a markdown renderer writing to out.
+func NewMarkdown(out io.Writer) *MarkdownRenderer {
+	return &MarkdownRenderer{out: out}
+}
+
+// RenderList writes one section per workflow with a table of each job's
+// steps. Steps that only reference an action are listed with a note since
+// they do not run locally.
+func (m *MarkdownRenderer) RenderList(workflows []provider.Workflow) error {
+	// Tables are written as they are built so huge pipelines are never held
+	// in memory twice.
+	b := bufio.NewWriter(m.out)
+	// Anchors are numbered in document order, so walk every heading first.
+	anchors := newAnchorSet()
+	if m.TOC {
+		anchors.add("Contents")
+	}
+	workflowAnchors := make([]string, len(workflows))
+	for i, wf := range workflows {
+		workflowAnchors[i] = anchors.add(wf.Name)
+		for _, job := range wf.Jobs {
+			anchors.add(job.Name)
+		}
+	}
+
+	if m.Meta != nil {
+		fmt.Fprintf(b, "Checkout: %s\n\n", markdownCode(m.Meta.String()))
+	}
+	if m.TOC {
+		b.WriteString("## Contents\n\n")
+		for i, wf := range workflows {
+			fmt.Fprintf(b, "- [%s](#%s) (%s)\n", markdownEscape(wf.Name), workflowAnchors[i], markdownCode(wf.Path))
+		}
+		b.WriteString("\n")
+	}
+
+	for i, wf := range workflows {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "## %s\n\n", markdownEscape(wf.Name))
+		fmt.Fprintf(b, "File: %s\n", markdownCode(wf.Path))
+		for _, job := range wf.Jobs {
+			fmt.Fprintf(b, "\n### %s\n\n", markdownEscape(job.Name))
+			if job.Environment != "" {
+				fmt.Fprintf(b, "Environment: %s\n\n", markdownCode(job.Environment))
+			}
+			if len(job.Steps) == 0 {
+				b.WriteString("_No steps._\n")
+				continue
+			}
+			b.WriteString("| Step | Command | Shell | Working directory | Notes |\n")
+			b.WriteString("| --- | --- | --- | --- | --- |\n")
+			for _, step := range job.Steps {
+				fmt.Fprintf(b, "| %s | %s | %s | %s | %s |\n",
+					markdownCell(step.Name),
+					markdownCommand(step.Run),
+					markdownCell(listShell(wf, job, step)),
+					markdownCell(listWorkingDir(wf, job, step)),
+					markdownCell(stepNotes(step)),
+				)
+			}
+		}
+	}
+
+	return b.Flush()
+}
+
+func listShell(wf provider.Workflow, job provider.Job, step provider.Step) string {
+	if step.Run == "" {
+		return ""
+	}
+	for _, shell := range []string{step.Shell, job.Defaults.RunShell, wf.Defaults.RunShell} {
+		if s := strings.TrimSpace(shell); s != "" {
+			return s
+		}
+	}
+	return "bash"
+}
+
+func listWorkingDir(wf provider.Workflow, job provider.Job, step provider.Step) string {
+	if step.Run == "" {
+		return ""
+	}
+	for _, dir := range []string{step.WorkingDirectory, job.Defaults.WorkingDirectory, wf.Defaults.WorkingDirectory} {
+		if d := strings.TrimSpace(dir); d != "" {
+			return d
+		}
+	}
+	return "."
+}
+
+func stepNotes(step provider.Step) string {
+	var notes []string
+	if step.Uses != "" {
+		notes = append(notes, "uses "+markdownCode(step.Uses)+"; not run locally")
+	}
+	if note := directiveNote(step); note != "" {
+		notes = append(notes, note)
+	}
+	if step.Skip {
+		notes = append(notes, "skipped by override")
+	} else if step.Overridden {
+		notes = append(notes, "overridden")
+	}
+	return strings.Join(notes, "; ")
+}
+
+// markdownCommand renders a script as one code span per line.
+func markdownCommand(script string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(script), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, markdownCode(line))
+		}
+	}
+	return strings.Join(lines, "<br>")
+}
+
+// markdownCode wraps s in a code span, widening the fence when s itself
+// contains backticks.
+func markdownCode(s string) string {
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + strings.ReplaceAll(s, "|", "\\|") + fence
+}
+
+// markdownCell escapes a plain value for use inside a table cell.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\n", " "), "|", "\\|")
+}
+
+var markdownSpecial = regexp.MustCompile("([\\\\`*_\\[\\]<>#|])")
+
+// markdownEscape escapes characters that would otherwise format text.
+func markdownEscape(s string) string {
+	return markdownSpecial.ReplaceAllString(s, "\\$1")
+}
+
+// anchorSet assigns GitHub-style heading anchors, numbering repeats the way
+// GitHub does ("build", "build-1", ...).
+type anchorSet map[string]int
+
+func newAnchorSet() anchorSet {
+	return anchorSet{}
+}
+
+var anchorStrip = regexp.MustCompile(`[^\p{L}\p{N}\- _]`)
+
+func (a anchorSet) add(heading string) string {
+	slug := strings.ReplaceAll(anchorStrip.ReplaceAllString(strings.ToLower(strings.TrimSpace(heading)), ""), " ", "-")
+	n := a[slug]
+	a[slug] = n + 1
+	if n == 0 {
+		return slug
+	}
+	return fmt.Sprintf("%s-%d", slug, n)
+}
+
+var (
+	markdownHeading   = regexp.MustCompile(`^#{1,6}\s+`)
+	markdownTableRule = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+	markdownEmphasis  = regexp.MustCompile("\\*\\*|__|`")
+)
+
+// RenderStepSummaries prints the GITHUB_STEP_SUMMARY markdown each job wrote,
+// flattened to plain text. Jobs without a summary are left out.
+func (p *PrettyRenderer) RenderStepSummaries(jobs []report.JobSummary) error {
+	var b strings.Builder
+	for _, job := range jobs {
+		if job.StepSummary == "" {
+			continue
+		}
+		name := job.JobName
+		if job.WorkflowName != "" {
+			name = job.WorkflowName + " / " + name
+		}
+		fmt.Fprintf(&b, "%s%s\n", p.layout.pad(1), name)
+		for _, line := range strings.SplitAfter(markdownText(job.StepSummary), "\n") {
+			if strings.TrimSpace(line) != "" {
+				b.WriteString(p.layout.pad(2) + line)
+			} else if line != "" {
+				b.WriteString("\n")
+			}
+		}
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	fmt.Fprintln(p.out, "STEP SUMMARIES:")
+	_, err := fmt.Fprint(p.out, b.String())
+	return err
+}
+
+// markdownText approximates rendered markdown in plain text: heading markers
+// and emphasis are dropped and tables are aligned into columns. Every output
+// line ends in a newline.
+func markdownText(md string) string {
+	var out strings.Builder
+	var table [][]string
+
+	flushTable := func() {
+		if len(table) == 0 {
+			return
+		}
+		var buf bytes.Buffer
+		tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		for _, row := range table {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		tw.Flush()
+		for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+			out.WriteString(strings.TrimRight(line, " ") + "\n")
+		}
+		table = nil
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(md, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "|") {
+			if markdownTableRule.MatchString(trimmed) {
+				continue
+			}
+			cells := strings.Split(strings.Trim(trimmed, "|"), "|")
+			for i, cell := range cells {
+				cells[i] = markdownEmphasis.ReplaceAllString(strings.TrimSpace(cell), "")
+			}
+			table = append(table, cells)
+			continue
+		}
+		flushTable()
+		line = markdownHeading.ReplaceAllString(trimmed, "")
+		out.WriteString(markdownEmphasis.ReplaceAllString(line, "") + "\n")
+	}
+	flushTable()
+	return out.String()
+}
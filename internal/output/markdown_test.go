@@ -0,0 +1,91 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestMarkdownText(t *testing.T) {
+	md := "## Coverage\n\n| File | **Lines** |\n| :--- | ---: |\n| `main.go` | 91% |\n| exec.go | 100% |\n\nTotal: **95%**\n"
+	want := "Coverage\n\nFile     Lines\nmain.go  91%\nexec.go  100%\n\nTotal: 95%\n"
+	if got := markdownText(md); got != want {
+		t.Fatalf("markdownText() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderStepSummaries(t *testing.T) {
+	var buf bytes.Buffer
+	jobs := []report.JobSummary{
+		{WorkflowName: "CI", JobName: "lint"},
+		{WorkflowName: "CI", JobName: "test", StepSummary: "### Sizes\n\n| a | b |\n|---|---|\n| 1 | 2 |\n"},
+	}
+	if err := NewPretty(&buf).RenderStepSummaries(jobs); err != nil {
+		t.Fatalf("RenderStepSummaries: %v", err)
+	}
+	want := "STEP SUMMARIES:\n  CI / test\n    Sizes\n\n    a  b\n    1  2\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected output:\n%q\nwant:\n%q", got, want)
+	}
+
+	buf.Reset()
+	if err := NewPretty(&buf).RenderStepSummaries(jobs[:1]); err != nil {
+		t.Fatalf("RenderStepSummaries: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output without summaries, got %q", buf.String())
+	}
+}
+
+func TestMarkdownRendererList(t *testing.T) {
+	workflows := []provider.Workflow{
+		{
+			Path:     "ci.yml",
+			Name:     "build",
+			Defaults: provider.Defaults{RunShell: "sh"},
+			Jobs: []provider.Job{{
+				Name: "build",
+				Steps: []provider.Step{
+					{Name: "Checkout", Uses: "actions/checkout@v4"},
+					{Name: "Pipe | name", Run: "echo `date` | tee out\nmake", WorkingDirectory: "app"},
+					{Name: "Deploy", Run: "./deploy", Skip: true, Overridden: true},
+				},
+			}},
+		},
+	}
+	var buf bytes.Buffer
+	r := NewMarkdown(&buf)
+	r.TOC = true
+	if err := r.RenderList(workflows); err != nil {
+		t.Fatalf("RenderList: %v", err)
+	}
+	want := "## Contents\n\n" +
+		"- [build](#build) (`ci.yml`)\n\n" +
+		"## build\n\n" +
+		"File: `ci.yml`\n\n" +
+		"### build\n\n" +
+		"| Step | Command | Shell | Working directory | Notes |\n" +
+		"| --- | --- | --- | --- | --- |\n" +
+		"| Checkout |  |  |  | uses `actions/checkout@v4`; not run locally |\n" +
+		"| Pipe \\| name | ``echo `date` \\| tee out``<br>`make` | sh | app |  |\n" +
+		"| Deploy | `./deploy` | sh | . | skipped by override |\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected markdown:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestAnchorSet(t *testing.T) {
+	a := newAnchorSet()
+	cases := []struct{ heading, want string }{
+		{"Basic CI", "basic-ci"},
+		{"Basic CI", "basic-ci-1"},
+		{"Release: v2 (prod)", "release-v2-prod"},
+	}
+	for _, tc := range cases {
+		if got := a.add(tc.heading); got != tc.want {
+			t.Fatalf("add(%q) = %q, want %q", tc.heading, got, tc.want)
+		}
+	}
+}
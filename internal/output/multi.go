@@ -0,0 +1,138 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// ErrStallNotShown is returned by MultiRenderer.StepStalled when none of
+// its renderers reports stalls, so the caller can warn some other way.
+var ErrStallNotShown = errors.New("no renderer shows stalled steps")
+
+// MultiRenderer is a StreamingRenderer that forwards every call to each of
+// its renderers in order, so one run can be shown in several places at
+// once: a terminal view, a JSON stream, a listening server.
+//
+// Calls are forwarded one at a time, so every renderer sees them in the same
+// order and none needs to be safe for concurrent use on its own account.
+// Each renderer gets every call even when an earlier one returns an error;
+// the errors are joined. A renderer that panics is disabled for the rest of
+// the run with a warning on Warn, and the others carry on.
+type MultiRenderer struct {
+	// Warn receives a line for each renderer disabled by a panic. Nil
+	// discards them.
+	Warn io.Writer
+
+	mu        sync.Mutex
+	renderers []StreamingRenderer
+	disabled  []bool
+}
+
+// NewMultiRenderer creates a MultiRenderer forwarding to renderers.
+func NewMultiRenderer(renderers ...StreamingRenderer) *MultiRenderer {
+	return &MultiRenderer{renderers: renderers, disabled: make([]bool, len(renderers))}
+}
+
+// each calls call with every enabled renderer, under m.mu.
+func (m *MultiRenderer) each(method string, call func(StreamingRenderer) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for i := range m.renderers {
+		if m.disabled[i] {
+			continue
+		}
+		if err := m.call(i, method, call); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// call runs call with renderer i, disabling it if it panics. Callers must
+// hold m.mu.
+func (m *MultiRenderer) call(i int, method string, call func(StreamingRenderer) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			m.disabled[i] = true
+			if m.Warn != nil {
+				fmt.Fprintf(m.Warn, "warning: %T panicked in %s and was disabled: %v\n", m.renderers[i], method, p)
+			}
+			err = nil
+		}
+	}()
+	return call(m.renderers[i])
+}
+
+func (m *MultiRenderer) InitializeAllJobs(workflows []provider.Workflow) error {
+	return m.each("InitializeAllJobs", func(r StreamingRenderer) error { return r.InitializeAllJobs(workflows) })
+}
+
+func (m *MultiRenderer) StartJob(jobID string) error {
+	return m.each("StartJob", func(r StreamingRenderer) error { return r.StartJob(jobID) })
+}
+
+func (m *MultiRenderer) InitializeWorkflow(workflowName, jobName string, stepCount int) error {
+	return m.each("InitializeWorkflow", func(r StreamingRenderer) error { return r.InitializeWorkflow(workflowName, jobName, stepCount) })
+}
+
+func (m *MultiRenderer) StartStep(jobID, stepName string) error {
+	return m.each("StartStep", func(r StreamingRenderer) error { return r.StartStep(jobID, stepName) })
+}
+
+func (m *MultiRenderer) CompleteStep(jobID, stepName string, result report.StepResult) error {
+	return m.each("CompleteStep", func(r StreamingRenderer) error { return r.CompleteStep(jobID, stepName, result) })
+}
+
+func (m *MultiRenderer) CompleteJob(jobID string) error {
+	return m.each("CompleteJob", func(r StreamingRenderer) error { return r.CompleteJob(jobID) })
+}
+
+func (m *MultiRenderer) RenderSummary(summary report.Summary) error {
+	return m.each("RenderSummary", func(r StreamingRenderer) error { return r.RenderSummary(summary) })
+}
+
+// StartTimer starts the timer of every renderer that has one.
+func (m *MultiRenderer) StartTimer() {
+	m.each("StartTimer", func(r StreamingRenderer) error {
+		if timer, ok := r.(TimerController); ok {
+			timer.StartTimer()
+		}
+		return nil
+	})
+}
+
+// StopTimer stops the timer of every renderer that has one.
+func (m *MultiRenderer) StopTimer() {
+	m.each("StopTimer", func(r StreamingRenderer) error {
+		if timer, ok := r.(TimerController); ok {
+			timer.StopTimer()
+		}
+		return nil
+	})
+}
+
+// StepStalled forwards to every renderer that reports stalls. It returns
+// ErrStallNotShown when there is none.
+func (m *MultiRenderer) StepStalled(jobID, stepName string, silent time.Duration) error {
+	shown := false
+	err := m.each("StepStalled", func(r StreamingRenderer) error {
+		reporter, ok := r.(StallReporter)
+		if !ok {
+			return nil
+		}
+		err := reporter.StepStalled(jobID, stepName, silent)
+		shown = true
+		return err
+	})
+	if !shown {
+		return ErrStallNotShown
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// recordingRenderer notes every call it gets. It is deliberately not safe
+// for concurrent use.
+type recordingRenderer struct {
+	calls []string
+	// fail makes StartJob return an error; panicOn makes the named method
+	// panic.
+	fail    bool
+	panicOn string
+}
+
+func (r *recordingRenderer) note(call string) {
+	if call == r.panicOn {
+		panic("renderer broke")
+	}
+	r.calls = append(r.calls, call)
+}
+
+func (r *recordingRenderer) InitializeAllJobs([]provider.Workflow) error {
+	r.note("InitializeAllJobs")
+	return nil
+}
+
+func (r *recordingRenderer) StartJob(jobID string) error {
+	r.note("StartJob " + jobID)
+	if r.fail {
+		return fmt.Errorf("cannot start %s", jobID)
+	}
+	return nil
+}
+
+func (r *recordingRenderer) InitializeWorkflow(string, string, int) error {
+	r.note("InitializeWorkflow")
+	return nil
+}
+
+func (r *recordingRenderer) StartStep(jobID, stepName string) error {
+	r.note("StartStep " + stepName)
+	return nil
+}
+
+func (r *recordingRenderer) CompleteStep(jobID, stepName string, _ report.StepResult) error {
+	r.note("CompleteStep " + stepName)
+	return nil
+}
+
+func (r *recordingRenderer) CompleteJob(jobID string) error {
+	r.note("CompleteJob " + jobID)
+	return nil
+}
+
+func (r *recordingRenderer) RenderSummary(report.Summary) error {
+	r.note("RenderSummary")
+	return nil
+}
+
+func TestMultiRendererJoinsErrors(t *testing.T) {
+	failing, healthy := &recordingRenderer{fail: true}, &recordingRenderer{}
+	multi := NewMultiRenderer(failing, &recordingRenderer{fail: true}, healthy)
+
+	err := multi.StartJob("ci.yml#test")
+	if err == nil || err.Error() != "cannot start ci.yml#test\ncannot start ci.yml#test" {
+		t.Fatalf("StartJob = %v, want both failures joined", err)
+	}
+	if err := multi.CompleteJob("ci.yml#test"); err != nil {
+		t.Fatalf("CompleteJob = %v", err)
+	}
+	want := []string{"StartJob ci.yml#test", "CompleteJob ci.yml#test"}
+	for _, r := range []*recordingRenderer{failing, healthy} {
+		if strings.Join(r.calls, ", ") != strings.Join(want, ", ") {
+			t.Fatalf("calls = %v, want %v", r.calls, want)
+		}
+	}
+}
+
+func TestMultiRendererDisablesPanickingRenderer(t *testing.T) {
+	broken, healthy := &recordingRenderer{panicOn: "StartStep Build"}, &recordingRenderer{}
+	warn := &bytes.Buffer{}
+	multi := NewMultiRenderer(broken, healthy)
+	multi.Warn = warn
+
+	multi.StartJob("ci.yml#test")
+	if err := multi.StartStep("ci.yml#test", "Build"); err != nil {
+		t.Fatalf("StartStep = %v, want the panic kept from the caller", err)
+	}
+	multi.CompleteStep("ci.yml#test", "Build", report.StepResult{})
+
+	if got := strings.Join(broken.calls, ", "); got != "StartJob ci.yml#test" {
+		t.Fatalf("panicking renderer got %s after it was disabled", got)
+	}
+	if got := strings.Join(healthy.calls, ", "); got != "StartJob ci.yml#test, StartStep Build, CompleteStep Build" {
+		t.Fatalf("healthy renderer got %s", got)
+	}
+	want := "warning: *output.recordingRenderer panicked in StartStep and was disabled: renderer broke\n"
+	if warn.String() != want {
+		t.Fatalf("warning = %q, want %q", warn, want)
+	}
+}
+
+func TestMultiRendererSerializesCalls(t *testing.T) {
+	first, second := &recordingRenderer{}, &recordingRenderer{}
+	multi := NewMultiRenderer(first, second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				multi.StartStep("ci.yml#test", fmt.Sprintf("%d-%d", i, j))
+			}
+		}()
+	}
+	wg.Wait()
+	if len(first.calls) != 400 || strings.Join(first.calls, ",") != strings.Join(second.calls, ",") {
+		t.Fatalf("renderers saw different calls: %d and %d", len(first.calls), len(second.calls))
+	}
+}
+
+func TestMultiRendererStalls(t *testing.T) {
+	wf := provider.Workflow{Path: "ci.yml", Name: "CI", Jobs: []provider.Job{{Name: "test", RawID: "test"}}}
+	id := JobID(wf, wf.Jobs[0])
+	plain := &bytes.Buffer{}
+	multi := NewMultiRenderer(NewJSONStream(&bytes.Buffer{}), NewPlainStreamingPretty(plain))
+	multi.InitializeAllJobs([]provider.Workflow{wf})
+	multi.StartJob(id)
+
+	if err := multi.StepStalled(id, "Test", 2*time.Minute); err != nil {
+		t.Fatalf("StepStalled = %v", err)
+	}
+	if !strings.Contains(plain.String(), "⚠ no output for 2m00s in Test") {
+		t.Fatalf("pretty renderer did not flag the stall:\n%s", plain)
+	}
+	if err := NewMultiRenderer(NewJSONStream(&bytes.Buffer{})).StepStalled(id, "Test", time.Minute); !errors.Is(err, ErrStallNotShown) {
+		t.Fatalf("StepStalled without a renderer that shows stalls = %v, want ErrStallNotShown", err)
+	}
+}
@@ -0,0 +1,38 @@
+package output
+
+import (
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// DefaultPagerLines is the length, in lines, a failure block must exceed
+// before it is handed to the pager.
+const DefaultPagerLines = 40
+
+// Pager shows long failure blocks through an external command such as
+// less, so one noisy failure does not scroll the rest of the results away.
+type Pager struct {
+	// Command is the pager and its arguments, e.g. less -R.
+	Command []string
+	// MinLines is the block length above which the pager is used.
+	MinLines int
+	// Out and Err are the pager's stdout and stderr, normally the terminal
+	// the results are rendered to.
+	Out io.Writer
+	Err io.Writer
+}
+
+// wants reports whether block is long enough to page.
+func (p *Pager) wants(block string) bool {
+	return p != nil && len(p.Command) > 0 && strings.Count(block, "\n") > p.MinLines
+}
+
+// Show feeds block to the pager on stdin and waits for the user to quit it.
+func (p *Pager) Show(block string) error {
+	cmd := exec.Command(p.Command[0], p.Command[1:]...)
+	cmd.Stdin = strings.NewReader(block)
+	cmd.Stdout = p.Out
+	cmd.Stderr = p.Err
+	return cmd.Run()
+}
@@ -1,42 +1,90 @@
 package output
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
+    "github.com/bgricker/testdrive/internal/baseline"
+    "github.com/bgricker/testdrive/internal/compare"
+    "github.com/bgricker/testdrive/internal/patterns"
     "github.com/bgricker/testdrive/internal/provider"
     "github.com/bgricker/testdrive/internal/report"
+    "github.com/bgricker/testdrive/internal/shellaudit"
 )
 
-// StreamingRenderer interface for real-time step updates.
+// StreamingRenderer interface for real-time step updates. Jobs are addressed
+// by the ID returned from JobID so implementations can track several running
+// jobs at once; every method may be called from multiple goroutines.
 type StreamingRenderer interface {
 	InitializeAllJobs(workflows []provider.Workflow) error
-	StartJob(jobName string) error
+	StartJob(jobID string) error
 	InitializeWorkflow(workflowName, jobName string, stepCount int) error
-	StartStep(stepName string) error
-	CompleteStep(stepName string, status string, duration time.Duration, stdout, stderr, command string) error
-	CompleteJob() error
+	StartStep(jobID, stepName string) error
+	CompleteStep(jobID, stepName string, result report.StepResult) error
+	CompleteJob(jobID string) error
 	RenderSummary(summary report.Summary) error
 }
 
+// JobID identifies a job for StreamingRenderer calls. Job names are not
+// unique across workflows, so the workflow path is part of the ID, and the
+// variants of a matrix share a job ID, so their variant is too.
+func JobID(wf provider.Workflow, job provider.Job) string {
+	id := job.RawID
+	if id == "" {
+		id = job.Name
+	}
+	if job.Variant != "" {
+		id += " (" + job.Variant + ")"
+	}
+	return wf.Path + "#" + id
+}
+
 // TimerController is an optional interface for renderers that support a live timer.
 type TimerController interface {
     StartTimer()
     StopTimer()
 }
 
+// StallReporter is an optional interface for streaming renderers that can
+// flag a running step that has written no output for silent. StepStalled
+// returns ErrStallNotShown when the renderer turns out not to show it.
+type StallReporter interface {
+	StepStalled(jobID, stepName string, silent time.Duration) error
+}
+
 // PrettyRenderer renders execution results in a human-friendly format.
 type PrettyRenderer struct {
 	out io.Writer
+	// GroupByPrefix folds consecutive steps sharing a "<word>:" name prefix
+	// under one header. Explicit group markers are honored regardless.
+	GroupByPrefix bool
+	// ShowStdoutOnFailure includes the last TailLines lines of a failed
+	// step's stdout in its failure details; test runners often report
+	// failures there. Zero TailLines keeps the whole captured tail.
+	ShowStdoutOnFailure bool
+	TailLines           int
+	// Pager, when set, shows failure blocks longer than its MinLines
+	// outside the results; the results keep a one-line pointer instead.
+	Pager *Pager
+	// Meta, when set, names the checkout on the summary line.
+	Meta *report.Meta
+	layout LayoutOptions
 }
 
 // StreamingPrettyRenderer renders execution results with real-time updates like GitHub CI.
+// It is safe for concurrent use; mu guards all job state and every write to out.
 type StreamingPrettyRenderer struct {
+	mu sync.Mutex
 	out io.Writer
 	workflows []workflowInfo
+	jobs map[string]*jobInfo
 	currentWorkflow int
 	currentJob int
     // Timer controls for live updates
@@ -45,11 +93,19 @@ type StreamingPrettyRenderer struct {
     currentLine int
     // Track total lines printed to avoid cursor positioning issues
     totalLinesPrinted int
+	// plain appends job lines instead of redrawing the job block.
+	plain bool
+	// linesBelow counts the detail lines printed under the job block, which
+	// redraws have to skip over.
+	linesBelow int
+	// Meta, when set, names the checkout on the summary line.
+	Meta *report.Meta
+	layout LayoutOptions
 }
 
 type workflowInfo struct {
 	name string
-	jobs []jobInfo
+	jobs []*jobInfo
 }
 
 type jobInfo struct {
@@ -60,52 +116,110 @@ type jobInfo struct {
 	steps []stepResult
 	lineNumber int // For cursor positioning
 	detailsShown bool // Track if we've already shown detailed failure info
+	// depth is how many levels of needs the job sits below a job without
+	// any; its line is indented by that many levels.
+	depth int
+	// needs holds the jobs this one needs, every variant of each.
+	needs []*jobInfo
+	// blockedBy names the failed job that kept this one from running.
+	blockedBy string
+	// stalledStep names the running step that has gone quiet, and silent
+	// how long it had written nothing when last reported.
+	stalledStep string
+	silent      time.Duration
 }
 
 type stepResult struct {
-	name string
-	status string
-	duration time.Duration
-	stderr string
-	stdout string
-	command string
+	name   string
+	result report.StepResult
 }
 
 // NewPretty creates a PrettyRenderer writing to the provided writer.
 func NewPretty(out io.Writer) *PrettyRenderer {
-	return &PrettyRenderer{out: out}
+	return NewPrettyLayout(out, LayoutOptions{})
+}
+
+// NewPrettyLayout creates a PrettyRenderer writing to out in layout.
+func NewPrettyLayout(out io.Writer, layout LayoutOptions) *PrettyRenderer {
+	return &PrettyRenderer{out: layout.writer(out), layout: layout}
 }
 
 // NewStreamingPretty creates a StreamingPrettyRenderer for real-time updates.
 func NewStreamingPretty(out io.Writer) *StreamingPrettyRenderer {
-	return &StreamingPrettyRenderer{out: out}
+	return NewStreamingPrettyLayout(out, LayoutOptions{})
+}
+
+// NewStreamingPrettyLayout creates a StreamingPrettyRenderer writing to out
+// in layout.
+func NewStreamingPrettyLayout(out io.Writer, layout LayoutOptions) *StreamingPrettyRenderer {
+	return &StreamingPrettyRenderer{out: layout.writer(out), layout: layout}
+}
+
+// NewPlainStreamingPretty creates a StreamingPrettyRenderer that never
+// moves the cursor: each job gets a line when it starts and another when it
+// finishes. It suits output that is not a terminal or that step output is
+// written into.
+func NewPlainStreamingPretty(out io.Writer) *StreamingPrettyRenderer {
+	return NewPlainStreamingPrettyLayout(out, LayoutOptions{})
+}
+
+// NewPlainStreamingPrettyLayout creates a plain StreamingPrettyRenderer
+// writing to out in layout.
+func NewPlainStreamingPrettyLayout(out io.Writer, layout LayoutOptions) *StreamingPrettyRenderer {
+	s := NewStreamingPrettyLayout(out, layout)
+	s.plain = true
+	return s
 }
 
 // RenderList renders workflows/jobs/steps in list mode.
 func (p *PrettyRenderer) RenderList(workflows []provider.Workflow) error {
+	w := bufio.NewWriter(p.out)
 	for _, wf := range workflows {
-		if _, err := fmt.Fprintf(p.out, "Workflow %s\n", decorateName(wf.Name, wf.Path)); err != nil {
+		if _, err := fmt.Fprintf(w, "Workflow %s\n", p.layout.workflowName(wf.Name, wf.Path)); err != nil {
 			return err
 		}
 		for _, job := range wf.Jobs {
-			if _, err := fmt.Fprintf(p.out, "  Job %s\n", job.Name); err != nil {
+			line := p.layout.pad(1) + "Job " + job.Name
+			if job.Environment != "" {
+				line += fmt.Sprintf(" [environment: %s]", job.Environment)
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
 				return err
 			}
+			var steps []provider.Step
+			var names []string
 			for _, step := range job.Steps {
+				if step.Run == "" {
+					continue
+				}
 				label := step.Name
 				if label == "" {
 					label = step.Run
 				}
-				if step.Run == "" {
-					continue
+				steps = append(steps, step)
+				names = append(names, label)
+			}
+			for _, g := range groupSteps(names, p.GroupByPrefix) {
+				pad := p.layout.pad(2)
+				if g.name != "" {
+					if _, err := fmt.Fprintf(w, "%s▸ %s\n", pad, g.name); err != nil {
+						return err
+					}
+					pad = p.layout.pad(3)
 				}
-				if _, err := fmt.Fprintf(p.out, "    • %s\n", label); err != nil {
-					return err
+				for i, idx := range g.indexes {
+					line := pad + "• " + StepLabel(g.labels[i], steps[idx].Overridden)
+					if note := directiveNote(steps[idx]); note != "" {
+						line += " [" + note + "]"
+					}
+					if _, err := fmt.Fprintln(w, line); err != nil {
+						return err
+					}
 				}
 			}
 		}
 	}
-	return nil
+	return w.Flush()
 }
 
 // RenderResults shows execution outcomes for steps with a summary.
@@ -115,71 +229,695 @@ func (p *PrettyRenderer) RenderResults(results []report.StepResult, summary repo
 		job      string
 	}
 
-	var current key
 	var buffer bytes.Buffer
-
-	flush := func() error {
-		if buffer.Len() == 0 {
-			return nil
+	for start := 0; start < len(results); {
+		k := key{workflow: results[start].WorkflowName, job: results[start].JobName}
+		end := start + 1
+		for end < len(results) && (key{workflow: results[end].WorkflowName, job: results[end].JobName}) == k {
+			end++
 		}
+		fmt.Fprintf(&buffer, "Workflow %s\n", p.layout.workflowName(results[start].WorkflowName, results[start].WorkflowPath))
+		fmt.Fprintf(&buffer, "%sJob %s\n", p.layout.pad(1), results[start].JobName)
+		p.renderJobSteps(&buffer, results[start:end])
 		if _, err := buffer.WriteTo(p.out); err != nil {
 			return err
 		}
-		buffer.Reset()
+		start = end
+	}
+
+	if err := p.renderJobTable(summary.Jobs); err != nil {
+		return err
+	}
+	fmt.Fprintln(p.out, summaryLine(summary, p.Meta))
+	return nil
+}
+
+// renderJobSteps writes one job's step results, folding grouped steps under
+// a header that carries the group's aggregate status and duration.
+func (p *PrettyRenderer) renderJobSteps(buf *bytes.Buffer, results []report.StepResult) {
+	names := make([]string, len(results))
+	for i, res := range results {
+		names[i] = res.StepName
+		if names[i] == "" {
+			names[i] = res.StepRun
+		}
+	}
+	for _, g := range groupSteps(names, p.GroupByPrefix) {
+		pad := p.layout.pad(2)
+		if g.name != "" {
+			var rollup report.JobSummary
+			for _, idx := range g.indexes {
+				rollup.Add(results[idx])
+			}
+			fmt.Fprintf(buf, "%s%s %s (%d steps, %s)\n", pad, StatusGlyph(rollup.Status), g.name, len(g.indexes), FormatDuration(rollup.Duration))
+			pad = p.layout.pad(3)
+		}
+		for i, idx := range g.indexes {
+			p.writeStepResult(buf, pad, g.labels[i], results[idx])
+		}
+	}
+}
+
+// writeStepResult writes a single step line and its details at pad.
+func (p *PrettyRenderer) writeStepResult(buf *bytes.Buffer, pad, label string, res report.StepResult) {
+	detailPad := pad + p.layout.pad(1)
+	fmt.Fprintf(buf, "%s%s %s%s%s%s\n", pad, StatusGlyph(res.Status), flakyLabel(StepLabel(label, res.Overridden), res), p.layout.stepDuration(res), classNote(res)+privilegedNote(res)+expectedNote(res), flakyNote(res))
+	if res.Status == "failed" {
+		shown := res
+		shown.Stdout = ""
+		if p.ShowStdoutOnFailure {
+			shown.Stdout = lastLines(res.Stdout, p.TailLines)
+		}
+		details := Indent(FormatFailure(shown), detailPad) + "\n"
+		if p.page(buf, fmt.Sprintf("%s %s\n", StatusGlyph(res.Status), flakyLabel(StepLabel(label, res.Overridden), res))+details) {
+			fmt.Fprintf(buf, "%s(%d lines of failure output shown in the pager)\n", detailPad, strings.Count(details, "\n"))
+		} else {
+			buf.WriteString(details)
+		}
+	}
+	if res.Status == "skipped" && res.SkipDetail != "" {
+		fmt.Fprintf(buf, "%s\n", Indent("note: "+res.SkipDetail, detailPad))
+	}
+	if res.DryRun {
+		fmt.Fprintf(buf, "%s\n", Indent("command: "+res.StepRun, detailPad))
+	}
+}
+
+// page hands a long failure block to the pager. Everything rendered so far
+// is flushed first so the block appears in order, and false is returned when
+// the block should be printed inline instead.
+func (p *PrettyRenderer) page(buf *bytes.Buffer, block string) bool {
+	if !p.Pager.wants(block) {
+		return false
+	}
+	if _, err := buf.WriteTo(p.out); err != nil {
+		return false
+	}
+	return p.Pager.Show(block) == nil
+}
+
+// renderJobTable prints one aligned row per job rollup.
+func (p *PrettyRenderer) renderJobTable(jobs []report.JobSummary) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	fmt.Fprintln(p.out, "JOBS:")
+	tw := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	for _, job := range jobs {
+		name := job.JobName
+		if job.WorkflowName != "" {
+			name = job.WorkflowName + " / " + name
+		}
+		fmt.Fprintf(tw, "%s%s\t%s\t%d passed, %d failed, %d skipped%s\t%s\n", p.layout.pad(1), name, job.Status, job.Passed, job.Failed, job.Skipped, expectedCounts(job.XFailed, job.XPassed), FormatDuration(job.Duration))
+	}
+	return tw.Flush()
+}
+
+// RenderVersions prints a table of pinned tool versions and what is
+// installed locally.
+func (p *PrettyRenderer) RenderVersions(checks []report.VersionCheck) error {
+	if len(checks) == 0 {
 		return nil
 	}
+	fmt.Fprintln(p.out, "VERSIONS:")
+	tw := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	for _, c := range checks {
+		detected, status := c.Detected, "ok"
+		switch {
+		case c.Error != "":
+			detected, status = "-", "unknown"
+		case !c.Match:
+			status = "mismatch"
+		}
+		fmt.Fprintf(tw, "  %s\t%s (%s)\t%s\t%s\n", c.Tool, c.Required, c.RequiredSource, detected, status)
+	}
+	return tw.Flush()
+}
 
-	for _, res := range results {
-		k := key{workflow: res.WorkflowName, job: res.JobName}
-		if current != k {
-			if err := flush(); err != nil {
-				return err
+// RenderContainers prints the container each job declares. Steps run on the
+// host regardless; the table shows what CI would have run them in.
+func (p *PrettyRenderer) RenderContainers(workflows []provider.Workflow) error {
+	tw := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	header := false
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			c := job.Container
+			if c == nil {
+				continue
+			}
+			if !header {
+				fmt.Fprintln(p.out, "CONTAINERS:")
+				header = true
 			}
-			current = k
-			fmt.Fprintf(&buffer, "Workflow %s\n", decorateName(res.WorkflowName, res.WorkflowPath))
-			fmt.Fprintf(&buffer, "  Job %s\n", res.JobName)
+			var extra []string
+			if len(c.Env) > 0 {
+				keys := make([]string, 0, len(c.Env))
+				for k := range c.Env {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				extra = append(extra, "env "+strings.Join(keys, ", "))
+			}
+			if c.Options != "" {
+				extra = append(extra, "options "+c.Options)
+			}
+			if len(c.Volumes) > 0 {
+				extra = append(extra, "volumes "+strings.Join(c.Volumes, ", "))
+			}
+			line := fmt.Sprintf("  %s / %s\t%s", wf.Name, job.Name, c.Image)
+			if len(extra) > 0 {
+				line += "\t" + strings.Join(extra, "; ")
+			}
+			fmt.Fprintln(tw, line)
 		}
+	}
+	return tw.Flush()
+}
+
+// RenderFlaky prints the steps that recently recovered from failures, most
+// flaky first. Steps marked flaky in run results carry the ~ marker.
+func (p *PrettyRenderer) RenderFlaky(steps []report.FlakyStep) error {
+	if len(steps) == 0 {
+		fmt.Fprintln(p.out, "No step has recovered from a failure in the recorded runs")
+		return nil
+	}
+	fmt.Fprintln(p.out, "FLAKY STEPS:")
+	tw := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	for _, step := range steps {
+		marker := " "
+		if step.Flaky {
+			marker = "~"
+		}
+		fmt.Fprintf(tw, "  %s %s / %s / %s\t%d/%d recent runs\t%.2f\n", marker, step.WorkflowPath, step.JobName, step.StepName, step.Recoveries, step.Runs, step.FlakyScore)
+	}
+	return tw.Flush()
+}
 
-		statusSymbol := statusGlyph(res.Status)
-		duration := formatDuration(res.Duration)
-		label := res.StepName
-		if label == "" {
-			label = res.StepRun
+// RenderSnapshotDiffs prints, per step, how its environment differed
+// between the two runs compared.
+func (p *PrettyRenderer) RenderSnapshotDiffs(diffs []report.SnapshotDiff) error {
+	for i, diff := range diffs {
+		if i > 0 {
+			fmt.Fprintln(p.out)
 		}
-		fmt.Fprintf(&buffer, "    %s %s (%s)\n", statusSymbol, label, duration)
-		if res.Status == "failed" && res.Stderr != "" {
-			fmt.Fprintf(&buffer, "      stderr: %s\n", indent(res.Stderr, "      "))
+		fmt.Fprintf(p.out, "%s / %s / %s\n", diff.WorkflowPath, diff.JobName, diff.StepName)
+		if len(diff.Changes) == 0 {
+			fmt.Fprintln(p.out, "  no differences")
+			continue
 		}
-		if res.Status == "skipped" && res.Stderr != "" {
-			fmt.Fprintf(&buffer, "      note: %s\n", indent(res.Stderr, "      "))
+		tw := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+		for _, c := range diff.Changes {
+			label := c.Kind + " " + c.Name
+			if c.Kind == report.SnapshotShell {
+				label = c.Kind
+			}
+			fmt.Fprintf(tw, "  %s\t%s -> %s\n", label, snapshotValue(c.A, c.ASet), snapshotValue(c.B, c.BSet))
 		}
-		if res.DryRun {
-			fmt.Fprintf(&buffer, "      command: %s\n", res.StepRun)
+		if err := tw.Flush(); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+func snapshotValue(value string, set bool) string {
+	switch {
+	case !set:
+		return "(unset)"
+	case value == "":
+		return `""`
+	default:
+		return value
+	}
+}
+
+// RenderStats prints usage totals, the pass rate and average wall time per
+// day or week, then the steps that fail most and the jobs run most.
+func (p *PrettyRenderer) RenderStats(stats report.Stats) error {
+	if stats.Runs == 0 {
+		_, err := fmt.Fprintln(p.out, "No runs recorded")
+		return err
+	}
+	fmt.Fprintf(p.out, "RUNS: %d, %d passed (%.0f%%), average %s, %s to %s\n",
+		stats.Runs, stats.Passed, stats.PassRate*100, FormatDuration(stats.AvgDuration),
+		stats.First.Format("2006-01-02"), stats.Last.Format("2006-01-02"))
 
-	if err := flush(); err != nil {
+	fmt.Fprintf(p.out, "\nTREND (by %s):\n", stats.Period)
+	tw := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	for _, period := range stats.Trend {
+		fmt.Fprintf(tw, "  %s\t%d runs\t%.0f%% passed\taverage %s\n", period.Start.Format("2006-01-02"), period.Runs, period.PassRate*100, FormatDuration(period.AvgDuration))
+	}
+	if err := tw.Flush(); err != nil {
 		return err
 	}
 
-	fmt.Fprintf(p.out, "SUMMARY: %d passed, %d failed, %d skipped (%s)\n", summary.Passed, summary.Failed, summary.Skipped, formatDuration(summary.Duration))
+	if len(stats.FailingSteps) > 0 {
+		fmt.Fprintln(p.out, "\nMOST FAILING STEPS:")
+		tw = tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+		for _, step := range stats.FailingSteps {
+			fmt.Fprintf(tw, "  %s / %s / %s\t%d of %d runs failed\n", step.WorkflowPath, step.JobName, step.StepName, step.Failures, step.Runs)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(stats.TopJobs) > 0 {
+		fmt.Fprintln(p.out, "\nMOST RUN JOBS:")
+		tw = tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+		for _, job := range stats.TopJobs {
+			fmt.Fprintf(tw, "  %s / %s\t%d runs\t%d failed\n", job.WorkflowPath, job.JobName, job.Runs, job.Failed)
+		}
+		return tw.Flush()
+	}
 	return nil
 }
 
+// RenderOverruns lists jobs that ran longer than their CI timeout or time
+// budget, below the summary.
+func (p *PrettyRenderer) RenderOverruns(overruns []report.BudgetOverrun) error {
+	if len(overruns) == 0 {
+		return nil
+	}
+	fmt.Fprintln(p.out, "OVER TIME:")
+	for _, o := range overruns {
+		if _, err := fmt.Fprintf(p.out, "  %s (%s)\n", OverrunMessage(o), o.WorkflowPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OverrunMessage describes an overrun, e.g. `job "test" took 48m00s,
+// exceeds CI timeout of 30m00s`.
+func OverrunMessage(o report.BudgetOverrun) string {
+	limit := "time budget"
+	if o.Source == report.BudgetTimeout {
+		limit = "CI timeout"
+	}
+	return fmt.Sprintf("job %q took %s, exceeds %s of %s", o.JobName, FormatDuration(o.Duration), limit, FormatDuration(o.Limit))
+}
+
+// RenderRepos prints one line per repository of a manifest run, then the
+// totals across all of them.
+func (p *PrettyRenderer) RenderRepos(repos []report.RepoRun, total report.Summary) error {
+	fmt.Fprintln(p.out, "REPOSITORIES:")
+	tw := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	for _, repo := range repos {
+		switch repo.Status {
+		case report.RepoError:
+			fmt.Fprintf(tw, "  %s\terror\t%s\n", repo.Name, repo.Error)
+		case report.RepoNotRun:
+			fmt.Fprintf(tw, "  %s\tnot run\t\n", repo.Name)
+		default:
+			s := repo.Summary
+			fmt.Fprintf(tw, "  %s\t%s\t%d passed, %d failed, %d skipped (%s)\n", repo.Name, repo.Status, s.Passed, s.Failed, s.Skipped, FormatDuration(s.Duration))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(p.out, summaryLine(total, p.Meta))
+	return err
+}
+
+// summaryLine formats the totals shared by the batch and streaming renderers,
+// ending with the checkout they ran against when meta is set.
+func summaryLine(summary report.Summary, meta *report.Meta) string {
+	line := fmt.Sprintf("SUMMARY: %d passed, %d failed%s, %d skipped%s (%s)", summary.Passed, summary.Failed, failureClasses(summary), summary.Skipped, expectedCounts(summary.XFailed, summary.XPassed), FormatDuration(summary.Duration))
+	if summary.Deduped > 0 {
+		line += fmt.Sprintf(", %d deduplicated (saved %s)", summary.Deduped, FormatDuration(summary.DedupeSaved))
+	}
+	if summary.Cancelled > 0 {
+		line += fmt.Sprintf(", %d cancelled", summary.Cancelled)
+	}
+	if meta != nil {
+		line += " on " + meta.String()
+	}
+	if summary.SelectedJobs < summary.TotalJobs {
+		// Filters left part of the pipeline out; say how much ran.
+		line = fmt.Sprintf("Ran %d of %d jobs (%d of %d steps)\n", summary.SelectedJobs, summary.TotalJobs, summary.SelectedSteps, summary.TotalSteps) + line
+	}
+	return line
+}
+
+// expectedCounts returns how many expected failures failed and passed, e.g.
+// ", 1 xfail, 1 xpass", leaving out a count that is zero.
+func expectedCounts(xfailed, xpassed int) string {
+	var out string
+	if xfailed > 0 {
+		out += fmt.Sprintf(", %d xfail", xfailed)
+	}
+	if xpassed > 0 {
+		out += fmt.Sprintf(", %d xpass", xpassed)
+	}
+	return out
+}
+
+// failureClasses breaks the failed count down by class, e.g.
+// " (4 environment, 1 test)", or "" when no failure was classified.
+func failureClasses(summary report.Summary) string {
+	if summary.FailedEnvironment+summary.FailedTest == 0 {
+		return ""
+	}
+	var parts []string
+	if summary.FailedEnvironment > 0 {
+		parts = append(parts, fmt.Sprintf("%d environment", summary.FailedEnvironment))
+	}
+	if summary.FailedTest > 0 {
+		parts = append(parts, fmt.Sprintf("%d test", summary.FailedTest))
+	}
+	if unknown := summary.Failed - summary.FailedEnvironment - summary.FailedTest; unknown > 0 {
+		parts = append(parts, fmt.Sprintf("%d unknown", unknown))
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// RenderComparison prints each step whose local outcome differs from CI,
+// followed by a one-line tally.
+func (p *PrettyRenderer) RenderComparison(result compare.Result) error {
+	if len(result.Divergences) > 0 {
+		fmt.Fprintln(p.out, "DIVERGENCES:")
+		tw := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+		for _, d := range result.Divergences {
+			step := d.Step
+			if d.RemoteStep != "" {
+				step += fmt.Sprintf(" (CI: %s)", d.RemoteStep)
+			}
+			fmt.Fprintf(tw, "  %s\t%s / %s\t%s\tlocal %s, CI %s\n", d.Kind, d.Workflow, d.Job, step, d.Local, d.CI)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	tally := "no divergences"
+	if n := len(result.Divergences); n > 0 {
+		tally = fmt.Sprintf("%d divergence(s)", n)
+	}
+	_, err := fmt.Fprintf(p.out, "COMPARE: %d step(s) compared, %s\n", result.Compared, tally)
+	return err
+}
+
+// RenderBaselineDiff prints each difference from the baseline, marked +
+// for added, - for removed and ~ for changed or renamed, with changed run
+// scripts as unified diffs, followed by a one-line tally.
+func (p *PrettyRenderer) RenderBaselineDiff(changes []baseline.Change) error {
+	if len(changes) > 0 {
+		fmt.Fprintln(p.out, "CHANGES:")
+	}
+	for _, c := range changes {
+		kind, target := "workflow", c.Workflow
+		switch {
+		case c.Step != "":
+			kind, target = "step", fmt.Sprintf("%s / %s / %s", c.Workflow, c.Job, c.Step)
+		case c.Job != "":
+			kind, target = "job", fmt.Sprintf("%s / %s", c.Workflow, c.Job)
+		}
+		var line string
+		switch c.Kind {
+		case baseline.ChangeAdded:
+			line = fmt.Sprintf("  + %s %s", kind, target)
+		case baseline.ChangeRemoved:
+			line = fmt.Sprintf("  - %s %s", kind, target)
+		case baseline.ChangeRenamed:
+			line = fmt.Sprintf("  ~ %s %s: renamed from %q", kind, target, c.Old)
+		case baseline.ChangeChanged:
+			if c.Diff != "" {
+				line = fmt.Sprintf("  ~ %s %s: %s changed", kind, target, c.Field)
+			} else {
+				line = fmt.Sprintf("  ~ %s %s: %s %q -> %q", kind, target, c.Field, c.Old, c.New)
+			}
+		}
+		if _, err := fmt.Fprintln(p.out, line); err != nil {
+			return err
+		}
+		for _, diffLine := range strings.Split(strings.TrimSuffix(c.Diff, "\n"), "\n") {
+			if diffLine == "" {
+				continue
+			}
+			if _, err := fmt.Fprintf(p.out, "      %s\n", diffLine); err != nil {
+				return err
+			}
+		}
+	}
+	tally := "no changes"
+	if n := len(changes); n > 0 {
+		tally = fmt.Sprintf("%d change(s)", n)
+	}
+	_, err := fmt.Fprintf(p.out, "DIFF: %s from %s\n", tally, baseline.Path)
+	return err
+}
+
+// RenderValidation prints each shell audit finding with the line it
+// flagged, followed by a one-line tally of the workflows parsed. audited
+// says whether the shell audit ran at all.
+func (p *PrettyRenderer) RenderValidation(workflows int, findings []shellaudit.Finding, audited bool) error {
+	if len(findings) > 0 {
+		fmt.Fprintln(p.out, "SHELL AUDIT:")
+	}
+	for _, f := range findings {
+		line := fmt.Sprintf("  %s / %s / %s line %d (%s, %s): %s", f.Workflow, f.Job, f.Step, f.Line, f.Rule, f.Shell, f.Message)
+		if _, err := fmt.Fprintf(p.out, "%s\n      %s\n", line, f.Text); err != nil {
+			return err
+		}
+	}
+	tally := fmt.Sprintf("%d workflow(s) parsed", workflows)
+	switch {
+	case !audited:
+	case len(findings) == 0:
+		tally += ", no shell audit findings"
+	default:
+		tally += fmt.Sprintf(", %d shell audit finding(s)", len(findings))
+	}
+	_, err := fmt.Fprintf(p.out, "VALIDATE: %s\n", tally)
+	return err
+}
+
+// RenderPatterns lists each pattern set with the origin and note of every
+// pattern. Sets that were tested against a string show only the pattern it
+// matched.
+func (p *PrettyRenderer) RenderPatterns(sets []patterns.Report) error {
+	var buf bytes.Buffer
+	for i, set := range sets {
+		if set.Test != "" {
+			switch {
+			case set.Match == nil:
+				fmt.Fprintf(&buf, "%s: no match\n", set.Set)
+			case set.Match.Note != "":
+				fmt.Fprintf(&buf, "%s: matches %s (%s: %s)\n", set.Set, set.Match.Expr, set.Match.Origin, set.Match.Note)
+			default:
+				fmt.Fprintf(&buf, "%s: matches %s (%s)\n", set.Set, set.Match.Expr, set.Match.Origin)
+			}
+			continue
+		}
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "%s (%d)\n", strings.ToUpper(set.Set), len(set.Patterns))
+		tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		for _, pattern := range set.Patterns {
+			if pattern.Note == "" {
+				fmt.Fprintf(tw, "  %s\t%s\n", pattern.Origin, pattern.Expr)
+				continue
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", pattern.Origin, pattern.Expr, pattern.Note)
+		}
+		tw.Flush()
+	}
+	_, err := p.out.Write(buf.Bytes())
+	return err
+}
+
+// RenderCoverage lists every step that did not execute, grouped by reason.
+func (p *PrettyRenderer) RenderCoverage(cov report.Coverage) error {
+	total := cov.Executed + len(cov.Skipped)
+	if _, err := fmt.Fprintf(p.out, "SKIPPED STEPS: %d of %d steps did not run\n", len(cov.Skipped), total); err != nil {
+		return err
+	}
+	for _, reason := range cov.Reasons() {
+		if _, err := fmt.Fprintf(p.out, "%s%s (%d)\n", p.layout.pad(1), reason, cov.Counts[reason]); err != nil {
+			return err
+		}
+		for _, s := range cov.Skipped {
+			if s.Reason != reason {
+				continue
+			}
+			line := fmt.Sprintf("%s%s / %s / %s", p.layout.pad(2), s.WorkflowName, s.JobName, s.StepName)
+			if s.Detail != "" {
+				line += ": " + firstLine(s.Detail)
+			}
+			if _, err := fmt.Fprintln(p.out, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RenderLocalCoverage prints the share of parsed steps that can run locally.
+// With breakdown set, each workflow follows on its own row with the uses
+// steps and unsupported features that account for the rest.
+func (p *PrettyRenderer) RenderLocalCoverage(cov report.LocalCoverage, breakdown bool) error {
+	if _, err := fmt.Fprintf(p.out, "local coverage: %d/%d steps (%d%%)\n", cov.LocalSteps, cov.TotalSteps, cov.Percent); err != nil {
+		return err
+	}
+	if !breakdown {
+		return nil
+	}
+	tw := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	for _, wf := range cov.Workflows {
+		var parts []string
+		if wf.UsesSteps > 0 {
+			parts = append(parts, fmt.Sprintf("%d uses_step", wf.UsesSteps))
+		}
+		for _, kind := range report.UnsupportedKinds(wf.Unsupported) {
+			parts = append(parts, fmt.Sprintf("%d %s", wf.Unsupported[kind], kind))
+		}
+		line := fmt.Sprintf("%s%s\t%d/%d steps (%d%%)", p.layout.pad(1), p.layout.workflowName(wf.WorkflowName, wf.WorkflowPath), wf.LocalSteps, wf.TotalSteps, wf.Percent)
+		if len(parts) > 0 {
+			line += "\t" + strings.Join(parts, ", ")
+		}
+		fmt.Fprintln(tw, line)
+	}
+	return tw.Flush()
+}
+
+// RenderPlan prints the steps a run would execute, fail to start, or skip,
+// grouped by workflow and job, followed by a one-line tally.
+func (p *PrettyRenderer) RenderPlan(plan report.Plan) error {
+	var buf bytes.Buffer
+	var lastWorkflow, lastJob string
+	for i, step := range plan.Steps {
+		if i == 0 || step.WorkflowPath != lastWorkflow || step.JobName != lastJob {
+			fmt.Fprintf(&buf, "Workflow %s\n", p.layout.workflowName(step.WorkflowName, step.WorkflowPath))
+			fmt.Fprintf(&buf, "%sJob %s\n", p.layout.pad(1), step.JobName)
+			lastWorkflow, lastJob = step.WorkflowPath, step.JobName
+		}
+		label := StepLabel(step.StepName, step.Overridden)
+		pad, detailPad := p.layout.pad(2), p.layout.pad(3)
+		if step.Action == report.PlanRun {
+			fmt.Fprintf(&buf, "%s▸ %s\n", pad, label)
+			fmt.Fprintf(&buf, "%s\n", Indent("command: "+step.StepRun, detailPad))
+			continue
+		}
+		if step.Action == report.PlanFail {
+			fmt.Fprintf(&buf, "%s✗ %s\n", pad, label)
+			fmt.Fprintf(&buf, "%s\n", Indent("command: "+step.StepRun, detailPad))
+			fmt.Fprintf(&buf, "%serror: %s\n", detailPad, step.Detail)
+			continue
+		}
+		fmt.Fprintf(&buf, "%s- %s [%s]\n", pad, label, step.SkipReason)
+		if step.Detail != "" {
+			fmt.Fprintf(&buf, "%s\n", Indent("note: "+step.Detail, detailPad))
+		}
+	}
+	fmt.Fprintf(&buf, "PLAN: %d step(s) would run", plan.Run)
+	if plan.Failed > 0 {
+		fmt.Fprintf(&buf, ", %d would fail to start", plan.Failed)
+	}
+	fmt.Fprintf(&buf, ", %d would be skipped", plan.Skipped)
+	if n := len(plan.Dropped); n > 0 {
+		fmt.Fprintf(&buf, ", %d filtered out", n)
+	}
+	buf.WriteString("; nothing was executed\n")
+	_, err := buf.WriteTo(p.out)
+	return err
+}
+
+// RenderExplanations prints how each step would run: script, shell argv,
+// working directory, env changes against the host, and any skip rule.
+func (p *PrettyRenderer) RenderExplanations(explanations []report.Explanation) error {
+	var buf bytes.Buffer
+	for i, exp := range explanations {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "%s / %s / %s\n", p.layout.workflowName(exp.WorkflowName, exp.WorkflowPath), exp.JobName, exp.StepName)
+		fmt.Fprintf(&buf, "  run:\n%s\n", Indent(strings.TrimRight(exp.Run, "\n"), "    "))
+		shell := exp.Shell
+		if shell == "" {
+			shell = "platform default"
+		}
+		fmt.Fprintf(&buf, "  shell: %s (%s)\n", shell, exp.ShellSource)
+		if len(exp.Argv) > 0 {
+			quoted := make([]string, len(exp.Argv))
+			for j, arg := range exp.Argv {
+				quoted[j] = fmt.Sprintf("%q", arg)
+			}
+			fmt.Fprintf(&buf, "  argv: [%s]\n", strings.Join(quoted, ", "))
+		}
+		if exp.WorkingDir != "" {
+			fmt.Fprintf(&buf, "  cwd: %s (%s)\n", exp.WorkingDir, exp.WorkingDirSource)
+		}
+		for _, m := range exp.Mappings {
+			fmt.Fprintf(&buf, "  mapped: %s\n", m)
+		}
+		for _, dir := range exp.AutoPath {
+			fmt.Fprintf(&buf, "  path: %s added to PATH (auto_path)\n", dir)
+		}
+		if exp.Error != "" {
+			fmt.Fprintf(&buf, "  error: %s\n", exp.Error)
+		}
+		if len(exp.Env) == 0 {
+			buf.WriteString("  env: same as host\n")
+		} else {
+			buf.WriteString("  env:\n")
+			for _, change := range exp.Env {
+				note := change.Level
+				if change.HostSet {
+					note += fmt.Sprintf("; host: %s", change.Host)
+				}
+				fmt.Fprintf(&buf, "    %s=%s (%s)\n", change.Key, change.Value, note)
+			}
+		}
+		if len(exp.Overrides) > 0 {
+			fmt.Fprintf(&buf, "  overrides: %s\n", strings.Join(exp.Overrides, ", "))
+		}
+		if exp.Skip != nil {
+			fmt.Fprintf(&buf, "  skip: %s (%s)\n", exp.Skip.Detail, exp.Skip.Reason)
+		} else {
+			buf.WriteString("  skip: no; the step would run\n")
+		}
+		if len(exp.Warnings) > 0 {
+			buf.WriteString("  warnings:\n")
+			for _, w := range exp.Warnings {
+				fmt.Fprintf(&buf, "    %s\n", w)
+			}
+		}
+	}
+	_, err := p.out.Write(buf.Bytes())
+	return err
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if idx := strings.Index(s, "\n"); idx != -1 {
+		return s[:idx]
+	}
+	return s
+}
+
 // InitializeAllJobs shows all jobs upfront with waiting indicators
 func (s *StreamingPrettyRenderer) InitializeAllJobs(workflows []provider.Workflow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Clear existing workflows
 	s.workflows = []workflowInfo{}
+	s.jobs = make(map[string]*jobInfo)
 	s.currentLine = 0
 	s.totalLinesPrinted = 0
-	
+	s.linesBelow = 0
+
 	// Add all workflows and jobs
 	for _, wf := range workflows {
 		workflow := workflowInfo{
 			name: wf.Name,
-			jobs: []jobInfo{},
+			jobs: []*jobInfo{},
 		}
-		
+		depths := needDepths(wf)
+		byID := make(map[string][]*jobInfo)
+
 		for _, job := range wf.Jobs {
 			// Count run steps for this job
 			stepCount := 0
@@ -188,57 +926,118 @@ func (s *StreamingPrettyRenderer) InitializeAllJobs(workflows []provider.Workflo
 					stepCount++
 				}
 			}
-			
-			jobInfo := jobInfo{
-				name: job.Name,
-				status: "pending", // Start as pending
-				startTime: time.Now(),
-				duration: 0,
-				steps: make([]stepResult, 0, stepCount),
+
+			info := &jobInfo{
+				name:       job.Name,
+				status:     "pending", // Start as pending
+				startTime:  time.Now(),
+				steps:      make([]stepResult, 0, stepCount),
 				lineNumber: s.totalLinesPrinted, // Track which line this job is on
+				depth:      depths[job.RawID],
 			}
-			
-            // Set first job to "running" and others to "pending"; first job's line is already printed.
-            if s.totalLinesPrinted == 0 {
-                jobInfo.status = "running"
-                jobInfo.startTime = time.Now()
-            }
-			
-			// Print initial state - first job running, others waiting
-            if s.totalLinesPrinted == 0 {
-                fmt.Fprintf(s.out, "🟢 %s\n", job.Name)
-            } else {
-                fmt.Fprintf(s.out, "⏳ %s\n", job.Name)
-            }
-            // We just printed exactly one line for this job
-            s.totalLinesPrinted++
-			
-			workflow.jobs = append(workflow.jobs, jobInfo)
+			byID[job.RawID] = append(byID[job.RawID], info)
+
+			workflow.jobs = append(workflow.jobs, info)
+			s.jobs[JobID(wf, job)] = info
 		}
-		
+		for i, job := range wf.Jobs {
+			for _, id := range job.Needs {
+				if id != job.RawID {
+					workflow.jobs[i].needs = append(workflow.jobs[i].needs, byID[id]...)
+				}
+			}
+		}
+
 		s.workflows = append(s.workflows, workflow)
 	}
-	
+
+	// Plain output prints nothing until a job starts.
+	if s.plain {
+		return nil
+	}
+	for _, wf := range s.workflows {
+		for _, info := range wf.jobs {
+			// The first job is shown running straight away unless it waits
+			// on another.
+			if s.totalLinesPrinted == 0 && len(info.needs) == 0 {
+				info.status = "running"
+			}
+			fmt.Fprintln(s.out, s.jobLine(info))
+			// We just printed exactly one line for this job
+			s.totalLinesPrinted++
+		}
+	}
 	return nil
 }
 
+// needDepths maps each job ID in wf to the length of the longest chain of
+// needs below it, counting only jobs in wf. A cycle, which GitHub rejects,
+// stops counting where it closes.
+func needDepths(wf provider.Workflow) map[string]int {
+	needs := make(map[string][]string, len(wf.Jobs))
+	for _, job := range wf.Jobs {
+		needs[job.RawID] = append(needs[job.RawID], job.Needs...)
+	}
+	depths := make(map[string]int, len(needs))
+	visiting := make(map[string]bool)
+	var depth func(id string) int
+	depth = func(id string) int {
+		if d, ok := depths[id]; ok {
+			return d
+		}
+		if visiting[id] {
+			return -1
+		}
+		visiting[id] = true
+		d := 0
+		for _, need := range needs[id] {
+			if _, ok := needs[need]; ok && need != id {
+				if nd := depth(need) + 1; nd > d {
+					d = nd
+				}
+			}
+		}
+		visiting[id] = false
+		depths[id] = d
+		return d
+	}
+	for id := range needs {
+		depth(id)
+	}
+	return depths
+}
+
+// job looks up a registered job. Callers must hold s.mu.
+func (s *StreamingPrettyRenderer) job(jobID string) (*jobInfo, error) {
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return nil, fmt.Errorf("streaming renderer: unknown job %q", jobID)
+	}
+	return job, nil
+}
+
 // StartJob marks a job as running and updates its display in place
-func (s *StreamingPrettyRenderer) StartJob(jobName string) error {
-    // Find the job and mark it as running
-    for _, workflow := range s.workflows {
-        for i := range workflow.jobs {
-            if workflow.jobs[i].name == jobName && workflow.jobs[i].status == "pending" {
-                job := &workflow.jobs[i]
-                job.status = "running"
-                job.startTime = time.Now()
-                
-                // Update the display to show this job as running
-                s.updateJobLineInPlace(job)
-                return nil
-            }
-        }
-    }
-    return nil
+func (s *StreamingPrettyRenderer) StartJob(jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.job(jobID)
+	if err != nil {
+		return err
+	}
+	if job.status != "pending" {
+		return nil
+	}
+	job.status = "running"
+	job.startTime = time.Now()
+
+	if s.plain {
+		fmt.Fprintf(s.out, "%s%s %s\n", s.layout.pad(job.depth), Style(job.status).Emoji, job.name)
+		return nil
+	}
+	// Update the display to show this job as running
+	s.updateJobLineInPlace()
+	return nil
 }
 
 // InitializeWorkflow is kept for interface compatibility but not used in the new approach
@@ -247,74 +1046,113 @@ func (s *StreamingPrettyRenderer) InitializeWorkflow(workflowName, jobName strin
 	return nil
 }
 
-// StartStep shows a step as running with a green circle.
-func (s *StreamingPrettyRenderer) StartStep(stepName string) error {
+// StartStep records that a step of the job has begun.
+func (s *StreamingPrettyRenderer) StartStep(jobID, stepName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Don't show step details during execution - wait for job completion
+	job, err := s.job(jobID)
+	if err != nil {
+		return err
+	}
+	job.stalledStep, job.silent = "", 0
 	return nil
 }
 
-// CompleteStep updates a step's status with checkmark or X.
-func (s *StreamingPrettyRenderer) CompleteStep(stepName string, status string, duration time.Duration, stdout, stderr, command string) error {
-	// Find the current job by looking for the most recent running job
-	for _, workflow := range s.workflows {
-		for i := range workflow.jobs {
-			if workflow.jobs[i].status == "running" {
-				job := &workflow.jobs[i]
-				job.steps = append(job.steps, stepResult{
-					name: stepName,
-					status: status,
-					duration: duration,
-					stderr: stderr,
-					stdout: stdout,
-					command: command,
-				})
-				
-				// Don't change job status here - let CompleteJob() handle it
-				return nil
-			}
-		}
+// StepStalled flags that the job's running step has written no output for
+// silent, until the step finishes.
+func (s *StreamingPrettyRenderer) StepStalled(jobID, stepName string, silent time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.job(jobID)
+	if err != nil {
+		return err
 	}
-	
+	if job.status != "running" {
+		return nil
+	}
+	job.stalledStep, job.silent = stepName, silent
+	if s.plain {
+		fmt.Fprintln(s.out, s.jobLine(job))
+		return nil
+	}
+	s.updateJobLineInPlace()
+	return nil
+}
+
+// CompleteStep records a finished step against its job under stepName.
+func (s *StreamingPrettyRenderer) CompleteStep(jobID, stepName string, result report.StepResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.job(jobID)
+	if err != nil {
+		return err
+	}
+	job.steps = append(job.steps, stepResult{name: stepName, result: result})
+	job.stalledStep, job.silent = "", 0
+	// Don't change job status here - let CompleteJob() handle it
 	return nil
 }
 
 // CompleteJob shows the final job status and step details if failed.
-func (s *StreamingPrettyRenderer) CompleteJob() error {
-	// Find the current job by looking for the most recent running job
-	for _, workflow := range s.workflows {
-		for i := range workflow.jobs {
-			if workflow.jobs[i].status == "running" {
-				job := &workflow.jobs[i]
-				job.duration = time.Since(job.startTime)
-				
-				// Determine final job status based on steps
-				job.status = "passed" // Default to passed
-				for _, step := range job.steps {
-					if step.status == "failed" {
-						job.status = "failed"
-						break
-					}
-				}
-				
-                // Update the display to show this job as completed
-                s.updateJobLineInPlace(job)
-                
-                // If job failed, show details immediately
-				if job.status == "failed" {
-                    s.showJobDetails(job)
-					job.detailsShown = true // Mark that we've shown detailed failure info
-				}
-				
-                return nil
-			}
+func (s *StreamingPrettyRenderer) CompleteJob(jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.job(jobID)
+	if err != nil {
+		return err
+	}
+	job.duration = time.Since(job.startTime)
+
+	// Determine final job status based on steps
+	var passed, failed, dryRun int
+	for _, step := range job.steps {
+		switch {
+		case step.result.Status == "passed", step.result.Status == "xfail", step.result.Status == "xpass":
+			passed++
+		case step.result.Status == "failed":
+			failed++
+		case step.result.SkipReason == report.ReasonDryRun:
+			dryRun++
 		}
 	}
-	
+	blockedBy := ""
+	if job.status == "pending" {
+		// A job completed without starting was skipped by the runner;
+		// name the failure upstream that kept it from running.
+		blockedBy = failedNeed(job)
+	}
+	job.status = report.JobStatus(passed, failed)
+	if dryRun > 0 && passed+failed == 0 {
+		// Nothing ran, so the job has no duration to show.
+		job.status = "dry-run"
+	}
+	if blockedBy != "" && passed+failed == 0 {
+		job.status = "skipped"
+		job.blockedBy = blockedBy
+	}
+
+	// Update the display to show this job as completed
+	if s.plain {
+		fmt.Fprintln(s.out, s.jobLine(job))
+	} else {
+		s.updateJobLineInPlace()
+	}
+
+	// If job failed, show details immediately. A dry run lists every step
+	// with its command, which is the plan being previewed.
+	if job.status == "failed" || job.status == "dry-run" {
+		s.showJobDetails(job)
+		job.detailsShown = true // Mark that we've shown detailed failure info
+	}
 	return nil
 }
 
-
-// updateJobLineInPlace redraws all job lines in place
+// updateJobLineInPlace redraws all job lines in place. Callers must hold s.mu.
 func (s *StreamingPrettyRenderer) updateJobLineInPlace() {
     // Redraw the entire block deterministically.
     // 1) Move cursor up by number of jobs
@@ -322,94 +1160,124 @@ func (s *StreamingPrettyRenderer) updateJobLineInPlace() {
     for _, wf := range s.workflows {
         totalJobs += len(wf.jobs)
     }
-    for i := 0; i < totalJobs; i++ {
+    for i := 0; i < totalJobs+s.linesBelow; i++ {
         fmt.Fprint(s.out, "\033[1A")
     }
 
     // 2) Rewrite all job lines in fixed order, one line per job
     for _, wf := range s.workflows {
         for _, j := range wf.jobs {
-            switch j.status {
-            case "passed":
-                fmt.Fprintf(s.out, "\033[2K\r✅ %s (%s)\n", j.name, formatDuration(j.duration))
-            case "failed":
-                fmt.Fprintf(s.out, "\033[2K\r❌ %s (%s)\n", j.name, formatDuration(j.duration))
-            case "running":
-                // Show running with live elapsed
-                fmt.Fprintf(s.out, "\033[2K\r🟢 %s (%s)\n", j.name, formatDuration(time.Since(j.startTime)))
-            case "pending":
-                fmt.Fprintf(s.out, "\033[2K\r⏳ %s\n", j.name)
-            case "skipped":
-                fmt.Fprintf(s.out, "\033[2K\r⏭️ %s\n", j.name)
-            default:
-                fmt.Fprintf(s.out, "\033[2K\r%s\n", j.name)
-            }
+            fmt.Fprintf(s.out, "\033[2K\r%s\n", s.jobLine(j))
         }
     }
+	// 3) Return below the details printed under the block
+	if s.linesBelow > 0 {
+		fmt.Fprintf(s.out, "\033[%dB", s.linesBelow)
+	}
     // Cursor naturally ends one line below the block after printing \n each row
 }
 
-// updateJobLine updates the job status line in place
-func (s *StreamingPrettyRenderer) updateJobLine(job *jobInfo) {
-	var emoji string
-	switch job.status {
-	case "passed":
-		emoji = "✅"
-	case "failed":
-		emoji = "❌"
+// jobLine formats a job's status line: its indent by dependency depth, its
+// glyph, name, and the duration so far or in total. A pending job names the
+// needs it is waiting on, a running job whose step has gone quiet says so,
+// and a job skipped for a failed need names the failure. Redraws clear each line first, so a suffix that gets shorter
+// leaves nothing behind.
+func (s *StreamingPrettyRenderer) jobLine(j *jobInfo) string {
+	indent := s.layout.pad(j.depth)
+	emoji := Style(j.status).Emoji
+	switch j.status {
+	case "passed", "failed":
+		return fmt.Sprintf("%s%s %s (%s)", indent, emoji, j.name, FormatDuration(j.duration))
+	case "running":
+		// Show running with live elapsed
+		line := fmt.Sprintf("%s%s %s (%s)", indent, emoji, j.name, FormatDuration(time.Since(j.startTime)))
+		if j.stalledStep != "" {
+			line += fmt.Sprintf(" ⚠ no output for %s in %s", FormatDuration(j.silent), j.stalledStep)
+		}
+		return line
+	case "pending":
+		if waiting := waitingOn(j); len(waiting) > 0 {
+			return fmt.Sprintf("%s%s %s (waiting on: %s)", indent, emoji, j.name, strings.Join(waiting, ", "))
+		}
+		return fmt.Sprintf("%s%s %s", indent, emoji, j.name)
 	case "skipped":
-		emoji = "⏭️"
+		if j.blockedBy != "" {
+			return fmt.Sprintf("%s%s %s skipped (%s failed)", indent, emoji, j.name, j.blockedBy)
+		}
+		return fmt.Sprintf("%s%s %s", indent, emoji, j.name)
+	case "dry-run":
+		return fmt.Sprintf("%s%s %s (%s)", indent, emoji, j.name, Style(j.status).Word)
 	default:
-		emoji = "❓"
+		return indent + j.name
 	}
-	
+}
+
+// waitingOn lists the names of the jobs j needs that have not finished.
+func waitingOn(j *jobInfo) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, need := range j.needs {
+		if (need.status == "pending" || need.status == "running") && !seen[need.name] {
+			seen[need.name] = true
+			names = append(names, need.name)
+		}
+	}
+	return names
+}
+
+// failedNeed returns the name of the failed job that blocks j: a need that
+// failed, or the failure a skipped need was blocked by. It returns "" when
+// no need failed.
+func failedNeed(j *jobInfo) string {
+	for _, need := range j.needs {
+		switch {
+		case need.status == "failed":
+			return need.name
+		case need.blockedBy != "":
+			return need.blockedBy
+		}
+	}
+	return ""
+}
+
+// updateJobLine updates the job status line in place. Callers must hold s.mu.
+func (s *StreamingPrettyRenderer) updateJobLine(job *jobInfo) {
 	// Move cursor up to the job line and overwrite it
 	fmt.Fprintf(s.out, "\033[1A\033[K") // Move up, clear line
-	fmt.Fprintf(s.out, "%s %s (%s)\n", emoji, job.name, formatDuration(job.duration))
+	fmt.Fprintf(s.out, "%s %s (%s)\n", Style(job.status).Emoji, job.name, FormatDuration(job.duration))
 }
 
-// showJobDetails shows step details for failed jobs
+// showJobDetails shows step details for failed and dry-run jobs. Callers
+// must hold s.mu.
 func (s *StreamingPrettyRenderer) showJobDetails(job *jobInfo) {
-	// Then show step details
+	var buf strings.Builder
+	pad, detailPad := s.layout.pad(2), s.layout.pad(3)
 	for _, step := range job.steps {
-		var stepEmoji string
-		switch step.status {
-		case "passed":
-			stepEmoji = "✅"
-		case "failed":
-			stepEmoji = "❌"
-		case "skipped":
-			stepEmoji = "⏭️"
-		default:
-			stepEmoji = "❓"
+		if step.result.SkipReason == report.ReasonDryRun {
+			fmt.Fprintf(&buf, "%s%s %s\n", pad, Style("dry-run").Emoji, step.name)
+			fmt.Fprintf(&buf, "%s\n", Indent("command: "+step.result.StepRun, detailPad))
+			continue
 		}
-		fmt.Fprintf(s.out, "    %s %s (%s)\n", stepEmoji, step.name, formatDuration(step.duration))
-		s.totalLinesPrinted++
+		fmt.Fprintf(&buf, "%s%s %s%s%s%s\n", pad, Style(step.result.Status).Emoji, flakyLabel(step.name, step.result), s.layout.stepDuration(step.result), classNote(step.result)+privilegedNote(step.result)+expectedNote(step.result), flakyNote(step.result))
 		
-		// Show stderr for failed steps with better formatting
-		if step.status == "failed" {
-			// Show the command that failed first
-			if step.command != "" {
-				fmt.Fprintf(s.out, "      Command: %s\n", step.command)
-				s.totalLinesPrinted++
-			}
-			
-			// Combine stdout and stderr for RSpec parsing
-			combinedOutput := step.stdout + "\n" + step.stderr
-			cleanedOutput := cleanErrorOutput(combinedOutput)
-			if cleanedOutput != "" {
-				fmt.Fprintf(s.out, "%s\n", indent(cleanedOutput, "      "))
-				s.totalLinesPrinted++
-			}
+		if step.result.Status == "failed" {
+			fmt.Fprintf(&buf, "%s\n", Indent(FormatFailure(step.result), detailPad))
 		}
 	}
+	lines := strings.Count(buf.String(), "\n")
+	s.totalLinesPrinted += lines
+	s.linesBelow += lines
+	io.WriteString(s.out, buf.String())
 }
 
 // RenderSummary shows the final summary.
 func (s *StreamingPrettyRenderer) RenderSummary(summary report.Summary) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
     // Ensure we start summary on a fresh line
     fmt.Fprint(s.out, "\n")
-    fmt.Fprintf(s.out, "SUMMARY: %d passed, %d failed, %d skipped (%s)\n", summary.Passed, summary.Failed, summary.Skipped, formatDuration(summary.Duration))
+    fmt.Fprintln(s.out, summaryLine(summary, s.Meta))
     return nil
 }
 
@@ -424,7 +1292,7 @@ func (s *StreamingPrettyRenderer) StopTimer() {
     // Timer disabled for now
 }
 
-// updateRunningJobs updates all running jobs with current elapsed time
+// updateRunningJobs updates all running jobs with current elapsed time. Callers must hold s.mu.
 func (s *StreamingPrettyRenderer) updateRunningJobs() {
 	// Count how many lines we need to move up
 	totalJobs := 0
@@ -444,276 +1312,96 @@ func (s *StreamingPrettyRenderer) updateRunningJobs() {
 		for _, job := range workflow.jobs {
 			if job.status == "pending" {
 				fmt.Fprintf(s.out, "\033[K") // Clear line
-				fmt.Fprintf(s.out, "⏳ %s\n", job.name)
+				fmt.Fprintf(s.out, "%s %s\n", Style("pending").Emoji, job.name)
 			} else if job.status == "running" {
 				elapsed := time.Since(job.startTime)
 				fmt.Fprintf(s.out, "\033[K") // Clear line
-				fmt.Fprintf(s.out, "🟢 %s (%s)\n", job.name, formatDuration(elapsed))
+				fmt.Fprintf(s.out, "%s %s (%s)\n", Style("running").Emoji, job.name, FormatDuration(elapsed))
 			} else {
 				// Job is complete, show final status
 				// Skip failed jobs that already showed detailed failure info to avoid duplication
 				if job.status == "failed" && job.detailsShown {
 					continue
 				}
-				
-				var emoji string
-				switch job.status {
-				case "passed":
-					emoji = "✅"
-				case "failed":
-					emoji = "❌"
-				case "skipped":
-					emoji = "⏭️"
-				default:
-					emoji = "❓"
-				}
+
 				fmt.Fprintf(s.out, "\033[K") // Clear line
-				fmt.Fprintf(s.out, "%s %s (%s)\n", emoji, job.name, formatDuration(job.duration))
+				fmt.Fprintf(s.out, "%s %s (%s)\n", Style(job.status).Emoji, job.name, FormatDuration(job.duration))
 			}
 		}
 	}
 }
 
-// cleanErrorOutput removes noise and makes error output more readable
-func cleanErrorOutput(stderr string) string {
-    lines := strings.Split(stderr, "\n")
-	
-	// Check if this looks like RSpec output
-	if isRSpecOutput(lines) {
-		return formatRSpecFailures(lines)
+// StepLabel appends an "(overridden)" marker to steps changed by config overrides.
+func StepLabel(name string, overridden bool) string {
+	if overridden {
+		return name + " (overridden)"
 	}
-	
-    // Otherwise, use the general cleaning logic
-    var cleaned []string
-	
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		
-		// Skip empty lines
-		if line == "" {
-			continue
-		}
-		
-		// Skip asdf migration warnings
-		if strings.Contains(line, "Bash implementation") ||
-		   strings.Contains(line, "Migration guide") ||
-		   strings.Contains(line, "asdf website") ||
-		   strings.Contains(line, "Source code") ||
-		   strings.Contains(line, "migrate to the new version") {
-			continue
-		}
-		
-		// Skip parser warnings
-		if strings.Contains(line, "parser/current is loading parser") ||
-		   strings.Contains(line, "Please see https://github.com/whitequark/parser") {
-			continue
-		}
-		
-		// Skip config file warnings
-		if strings.Contains(line, "config file has been renamed") ||
-		   strings.Contains(line, "is deprecated") {
-			continue
-		}
-		
-		// Skip shoulda-matchers warnings
-		if strings.Contains(line, "Warning from shoulda-matchers") ||
-		   strings.Contains(line, "validate_inclusion_of") ||
-		   strings.Contains(line, "boolean column") ||
-		   strings.Contains(line, "************************************************************************") {
-			continue
-		}
-		
-        // Keep important error lines
-        lower := strings.ToLower(line)
-        if strings.Contains(lower, "failure/error:") ||
-           strings.Contains(lower, "expected ") ||
-           strings.Contains(lower, "got ") ||
-           strings.HasPrefix(line, "# ./spec/") ||
-           strings.Contains(lower, "failed") ||
-           strings.Contains(lower, "error") ||
-           strings.Contains(line, "FAILED") ||
-           strings.Contains(lower, "aborted") ||
-           strings.Contains(line, "Tasks: TOP") {
-            cleaned = append(cleaned, line)
-        }
-	}
-	
-	// If we have cleaned lines, return them; otherwise return a simple message
-	if len(cleaned) > 0 {
-		return strings.Join(cleaned, "\n")
-	}
-	
-    return "Step failed - output suppressed; run with --verbose for full logs"
+	return name
 }
 
-// isRSpecOutput checks if the output looks like RSpec test results
-func isRSpecOutput(lines []string) bool {
-	for _, line := range lines {
-        if strings.Contains(line, "Failures:") ||
-           strings.Contains(line, "Failed examples:") ||
-           strings.Contains(line, "rspec ./spec/") ||
-           strings.Contains(line, "Finished in") ||
-           strings.Contains(line, "examples,") ||
-           strings.Contains(line, "Failure/Error:") ||
-           strings.Contains(line, ") ") { // numbered failures like "1) ..."
-			return true
-		}
+// flakyLabel marks label with ~ when history says the step is flaky.
+func flakyLabel(label string, res report.StepResult) string {
+	if res.FlakyRuns == 0 {
+		return label
 	}
-	return false
+	return "~ " + label
 }
 
-// formatRSpecFailures formats RSpec failure output in a clean, hierarchical way
-func formatRSpecFailures(lines []string) string {
-	var result []string
-	var currentFailure []string
-    inFailedExamples := false
-	
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		
-        // Skip empty lines and noise
-		if line == "" || 
-		   strings.Contains(line, "Bash implementation") ||
-		   strings.Contains(line, "Migration guide") ||
-		   strings.Contains(line, "asdf website") ||
-		   strings.Contains(line, "Source code") ||
-		   strings.Contains(line, "migrate to the new version") ||
-		   strings.Contains(line, "parser/current is loading parser") ||
-		   strings.Contains(line, "Please see https://github.com/whitequark/parser") ||
-		   strings.Contains(line, "config file has been renamed") ||
-		   strings.Contains(line, "is deprecated") ||
-		   strings.Contains(line, "Warning from shoulda-matchers") ||
-		   strings.Contains(line, "validate_inclusion_of") ||
-		   strings.Contains(line, "boolean column") ||
-		   strings.Contains(line, "************************************************************************") ||
-		   strings.Contains(line, "Finished in") ||
-		   strings.Contains(line, "examples,") ||
-		   strings.Contains(line, "Randomized with seed") ||
-		   strings.Contains(line, "Pending:") ||
-		   strings.Contains(line, "Not yet implemented") ||
-		   strings.Contains(line, "Database connection mocking") ||
-		   strings.Contains(line, "# ./spec/support/database_cleaner.rb") {
-			continue
-        }
-
-        // Handle the concise "Failed examples:" tail section when our tail dropped the main block
-        if strings.HasPrefix(line, "Failed examples:") {
-            inFailedExamples = true
-            continue
-        }
-        if inFailedExamples {
-            if strings.HasPrefix(line, "rspec ./spec/") {
-                // Example format: "rspec ./spec/models/foo_spec.rb:12 # description..."
-                // Trim after first space following path to keep it short
-                path := line
-                if hash := strings.Index(line, " # "); hash != -1 {
-                    path = line[len("rspec "):hash]
-                } else if strings.HasPrefix(line, "rspec ") {
-                    path = strings.TrimPrefix(line, "rspec ")
-                }
-                result = append(result, fmt.Sprintf("        ❌ %s", path))
-            }
-			// Do not process other lines in this block
-            continue
-        }
-		
-        // Start of a new failure (numbered like "2) DetectMovementsJob...")
-        if strings.Contains(line, ") ") && !strings.Contains(line, "Failure/Error:") {
-			if len(currentFailure) > 0 {
-				result = append(result, formatSingleFailure(currentFailure)...)
-			}
-			currentFailure = []string{line}
-		} else if len(currentFailure) > 0 {
-			// Continue collecting details for current failure
-            if strings.Contains(line, "Failure/Error:") ||
-               strings.Contains(strings.ToLower(line), "expected") ||
-               strings.Contains(strings.ToLower(line), "got") ||
-               strings.HasPrefix(line, "# ./spec/") {
-				currentFailure = append(currentFailure, line)
-			}
+// directiveNote describes the testdrive comments found on step, e.g.
+// "testdrive: skip: needs AWS", or returns "" when it has none. A step
+// marked as an expected failure, by comment or config, notes "xfail".
+func directiveNote(step provider.Step) string {
+	var notes []string
+	if step.LocalSkip {
+		note := "testdrive: skip"
+		if step.LocalSkipReason != "" {
+			note += ": " + step.LocalSkipReason
 		}
+		notes = append(notes, note)
 	}
-	
-	// Handle the last failure
-	if len(currentFailure) > 0 {
-		result = append(result, formatSingleFailure(currentFailure)...)
+	if step.AllowPrivileged {
+		notes = append(notes, "testdrive: allow-privileged")
 	}
-	
-    if len(result) > 0 {
-		return strings.Join(result, "\n")
+	if step.ExpectedFailure {
+		notes = append(notes, "xfail")
 	}
-	
-    // Summarize failures we parsed, keeping it concise
-    return "RSpec tests failed"
+	return strings.Join(notes, "; ")
 }
 
-// formatSingleFailure formats a single RSpec failure
-func formatSingleFailure(failureLines []string) []string {
-	var result []string
-	
-	for i, line := range failureLines {
-		if i == 0 {
-			// Extract the spec file and line number from the failure line
-			// Format: "1) EspnInjuryService.get_injury_summary_for_event provides injury summary for both teams"
-			// We need to extract the spec file from the stack trace later
-			if strings.Contains(line, "Failure/Error:") {
-				// Extract the failure message
-				if idx := strings.Index(line, "Failure/Error:"); idx != -1 {
-					failureMsg := strings.TrimSpace(line[idx+len("Failure/Error:"):])
-					result = append(result, fmt.Sprintf("        ❌ %s", failureMsg))
-				}
-			}
-		} else if strings.Contains(line, "expected") && strings.Contains(line, "got") {
-			// This is the detailed error message
-			result = append(result, fmt.Sprintf("                    %s", line))
-		} else if strings.HasPrefix(line, "# ./spec/") {
-			// Extract the spec file path
-			specPath := strings.TrimPrefix(line, "# ./")
-			result = append(result, fmt.Sprintf("        ❌ %s", specPath))
-		}
+// privilegedNote marks a step a `# testdrive: allow-privileged` comment
+// exempted from the privileged command patterns.
+func privilegedNote(res report.StepResult) string {
+	if !res.AllowPrivileged {
+		return ""
 	}
-	
-	return result
+	return " (allow-privileged)"
 }
 
-func decorateName(name, path string) string {
-	if name == "" || name == path {
-		return path
+// expectedNote labels the outcome of a step marked as an expected failure,
+// or returns "" for other steps.
+func expectedNote(res report.StepResult) string {
+	switch res.Status {
+	case "xfail", "xpass":
+		return " (" + Style(res.Status).Word + ")"
 	}
-	return fmt.Sprintf("%s (%s)", name, path)
+	return ""
 }
 
-func statusGlyph(status string) string {
-	switch status {
-	case "passed":
-		return "✓"
-	case "failed":
-		return "✗"
-	case "skipped":
-		return "-"
-	default:
-		return "?"
+// classNote returns what a classified failure failed on, e.g.
+// " (environment failure)", or "" for other steps.
+func classNote(res report.StepResult) string {
+	if res.Status != "failed" || res.FailureClass == "" || res.FailureClass == report.FailureUnknown {
+		return ""
 	}
+	return fmt.Sprintf(" (%s failure)", res.FailureClass)
 }
 
-func indent(s, pad string) string {
-	s = strings.TrimSpace(s)
-	if s == "" {
+// flakyNote returns how often a flaky step recently recovered from a
+// failure, e.g. " (flaky: 4/10 recent runs)", or "" for other steps.
+func flakyNote(res report.StepResult) string {
+	if res.FlakyRuns == 0 {
 		return ""
 	}
-	lines := strings.Split(s, "\n")
-	for i := range lines {
-		lines[i] = pad + lines[i]
-	}
-	return strings.Join(lines, "\n")
+	return fmt.Sprintf(" (flaky: %d/%d recent runs)", res.FlakyRecoveries, res.FlakyRuns)
 }
 
-func formatDuration(d time.Duration) string {
-	if d <= 0 {
-		return "0s"
-	}
-	if d < time.Second {
-		return d.Round(time.Millisecond).String()
-	}
-	return d.Truncate(time.Millisecond).String()
-}
@@ -2,8 +2,11 @@ package output
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
     "github.com/bgricker/testdrive/internal/provider"
     "github.com/bgricker/testdrive/internal/report"
@@ -56,9 +59,19 @@ func TestPrettyRenderResults(t *testing.T) {
 			Status:       "failed",
 			Stderr:       "boom",
 		},
+		{
+			WorkflowPath: "wf.yml",
+			WorkflowName: "Workflow",
+			JobName:      "Build",
+			StepName:     "Install",
+			StepRun:      "sudo make install",
+			Status:       "skipped",
+			SkipReason:   report.ReasonPrivileged,
+			SkipDetail:   "skipped privileged command",
+		},
 	}
 
-	summary := report.Summary{Passed: 1, Failed: 1, Duration: 123456789, DurationMS: 123}
+	summary := report.Summary{Passed: 1, Failed: 1, Skipped: 1, Duration: 123456789, DurationMS: 123}
 
 	buf := &bytes.Buffer{}
 	renderer := NewPretty(buf)
@@ -73,10 +86,442 @@ func TestPrettyRenderResults(t *testing.T) {
 	if !strings.Contains(out, "✗ Test") {
 		t.Fatalf("expected failure glyph, got %q", out)
 	}
-	if !strings.Contains(out, "stderr:") || !strings.Contains(out, "boom") {
-		t.Fatalf("expected stderr output, got %q", out)
+	if !strings.Contains(out, "      Command: go test\n      stderr:\n        boom\n") {
+		t.Fatalf("expected the failure block, got %q", out)
+	}
+	if !strings.Contains(out, "note:") || !strings.Contains(out, "skipped privileged command") {
+		t.Fatalf("expected the skip detail as a note, got %q", out)
 	}
 	if !strings.Contains(out, "SUMMARY: 1 passed, 1 failed") {
 		t.Fatalf("expected summary line, got %q", out)
 	}
 }
+
+func TestPrettyRenderResultsShowsFailureClasses(t *testing.T) {
+	results := []report.StepResult{
+		{WorkflowPath: "wf.yml", JobName: "test", StepName: "Install", Status: "failed", ExitCode: 127, FailureClass: report.FailureEnvironment},
+		{WorkflowPath: "wf.yml", JobName: "test", StepName: "Specs", Status: "failed", ExitCode: 1, FailureClass: report.FailureTest},
+		{WorkflowPath: "wf.yml", JobName: "test", StepName: "Lint", Status: "failed", ExitCode: 2, FailureClass: report.FailureUnknown},
+	}
+	summary := report.Summary{Failed: 3, FailedEnvironment: 1, FailedTest: 1}
+
+	buf := &bytes.Buffer{}
+	if err := NewPretty(buf).RenderResults(results, summary); err != nil {
+		t.Fatalf("render results: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"✗ Install (0s) (environment failure)\n",
+		"✗ Specs (0s) (test failure)\n",
+		"✗ Lint (0s)\n",
+		"SUMMARY: 0 passed, 3 failed (1 environment, 1 test, 1 unknown), 0 skipped",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	if line := summaryLine(report.Summary{Failed: 2}, nil); !strings.HasPrefix(line, "SUMMARY: 0 passed, 2 failed, 0 skipped") {
+		t.Fatalf("unclassified failures should not be broken down, got %q", line)
+	}
+}
+
+func TestPrettyRenderResultsSkipNote(t *testing.T) {
+	results := []report.StepResult{{
+		WorkflowPath: "wf.yml",
+		JobName:      "test",
+		StepName:     "Deploy",
+		Status:       "skipped",
+		SkipReason:   "needs",
+		SkipDetail:   "needs build, which failed\nrerun build first",
+	}}
+
+	buf := &bytes.Buffer{}
+	if err := NewPretty(buf).RenderResults(results, report.Summary{Skipped: 1}); err != nil {
+		t.Fatalf("render results: %v", err)
+	}
+	want := "    - Deploy (0s)\n      note: needs build, which failed\n      rerun build first\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Fatalf("expected %q in output:\n%s", want, buf.String())
+	}
+}
+
+func TestPrettyRenderResultsDryRunCommand(t *testing.T) {
+	results := []report.StepResult{{
+		WorkflowPath: "wf.yml",
+		JobName:      "test",
+		StepName:     "Package",
+		StepRun:      "echo packaging\necho packaged\n",
+		Status:       "skipped",
+		SkipReason:   report.ReasonDryRun,
+		DryRun:       true,
+	}}
+
+	buf := &bytes.Buffer{}
+	if err := NewPretty(buf).RenderResults(results, report.Summary{Skipped: 1}); err != nil {
+		t.Fatalf("render results: %v", err)
+	}
+	want := "      command: echo packaging\n      echo packaged\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Fatalf("expected %q in output:\n%s", want, buf.String())
+	}
+}
+
+func TestSummaryLineShowsSelection(t *testing.T) {
+	line := summaryLine(report.Summary{TotalJobs: 6, TotalSteps: 20, SelectedJobs: 1, SelectedSteps: 3, Passed: 3}, nil)
+	if want := "Ran 1 of 6 jobs (3 of 20 steps)\nSUMMARY: 3 passed"; !strings.HasPrefix(line, want) {
+		t.Fatalf("summary line = %q, want prefix %q", line, want)
+	}
+	if line := summaryLine(report.Summary{TotalJobs: 2, TotalSteps: 4, SelectedJobs: 2, SelectedSteps: 3}, nil); strings.Contains(line, "Ran") {
+		t.Fatalf("expected no selection line when every job runs, got %q", line)
+	}
+}
+
+func TestSummaryLineShowsExpectedFailures(t *testing.T) {
+	line := summaryLine(report.Summary{Passed: 2, XFailed: 1, XPassed: 1}, nil)
+	if want := "SUMMARY: 2 passed, 0 failed, 0 skipped, 1 xfail, 1 xpass (0s)"; line != want {
+		t.Fatalf("summary line = %q, want %q", line, want)
+	}
+	if line := summaryLine(report.Summary{Passed: 2}, nil); strings.Contains(line, "xfail") || strings.Contains(line, "xpass") {
+		t.Fatalf("expected no expected-failure counts when there are none, got %q", line)
+	}
+}
+
+func TestSummaryLineShowsCheckout(t *testing.T) {
+	line := summaryLine(report.Summary{Passed: 1}, &report.Meta{Branch: "feature/login", SHA: "a1b2c3d", Dirty: true})
+	if want := "SUMMARY: 1 passed, 0 failed, 0 skipped (0s) on feature/login@a1b2c3d (dirty)"; line != want {
+		t.Fatalf("summary line = %q, want %q", line, want)
+	}
+	if line := summaryLine(report.Summary{Passed: 1}, &report.Meta{SHA: "a1b2c3d"}); !strings.HasSuffix(line, " on a1b2c3d") {
+		t.Fatalf("detached summary line = %q", line)
+	}
+}
+
+func TestPrettyRenderResultsShowsStdoutOnFailure(t *testing.T) {
+	results := []report.StepResult{{
+		WorkflowPath: "wf.yml",
+		JobName:      "test",
+		StepName:     "go test",
+		Status:       "failed",
+		Stdout:       "=== RUN   TestAdd\n    add_test.go:9: got 3, want 4\n--- FAIL: TestAdd (0.00s)\nFAIL\n",
+	}}
+
+	render := func(show bool, tail int) string {
+		buf := &bytes.Buffer{}
+		renderer := NewPretty(buf)
+		renderer.ShowStdoutOnFailure = show
+		renderer.TailLines = tail
+		if err := renderer.RenderResults(results, report.Summary{Failed: 1}); err != nil {
+			t.Fatalf("render results: %v", err)
+		}
+		return buf.String()
+	}
+
+	want := "wf.yml\n  Job test\n    ✗ go test (0s)\n      stdout:\n        add_test.go:9: got 3, want 4\n        --- FAIL: TestAdd (0.00s)\n        FAIL\n"
+	if out := render(true, 3); !strings.HasPrefix(out, "Workflow "+want) {
+		t.Fatalf("expected the last three stdout lines, got:\n%s", out)
+	}
+	if out := render(false, 3); strings.Contains(out, "got 3") {
+		t.Fatalf("expected no stdout when disabled, got:\n%s", out)
+	}
+
+	results[0].Stdout = "Failures:\n\n  1) Cart totals\n     Failure/Error: expect(total).to eq(4)\n       expected: 4\n            got: 3\n     # ./spec/cart_spec.rb:9\n"
+	if out := render(true, 20); !strings.Contains(out, "❌ spec/cart_spec.rb:9") || strings.Contains(out, "Failures:") {
+		t.Fatalf("expected parsed RSpec failures instead of raw stdout, got:\n%s", out)
+	}
+}
+
+func TestStreamingPrettyConcurrentJobs(t *testing.T) {
+	var workflows []provider.Workflow
+	for i := 0; i < 8; i++ {
+		workflows = append(workflows, provider.Workflow{
+			Path: fmt.Sprintf("wf%d.yml", i),
+			Name: fmt.Sprintf("wf%d", i),
+			Jobs: []provider.Job{{Name: "test", RawID: "test", Steps: []provider.Step{{Name: "run", Run: "make test"}}}},
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	renderer := NewStreamingPretty(buf)
+	if err := renderer.InitializeAllJobs(workflows); err != nil {
+		t.Fatalf("initialize jobs: %v", err)
+	}
+
+	start := make(chan struct{})
+	errs := make(chan error, len(workflows))
+	var wg sync.WaitGroup
+	for i, wf := range workflows {
+		wg.Add(1)
+		go func(i int, wf provider.Workflow) {
+			defer wg.Done()
+			<-start
+			id := JobID(wf, wf.Jobs[0])
+			status := "passed"
+			if i == 3 {
+				status = "failed"
+			}
+			if err := renderer.StartJob(id); err != nil {
+				errs <- err
+				return
+			}
+			if err := renderer.StartStep(id, "run"); err != nil {
+				errs <- err
+				return
+			}
+			if err := renderer.CompleteStep(id, "run", report.StepResult{Status: status, Duration: time.Millisecond, StepRun: wf.Path}); err != nil {
+				errs <- err
+				return
+			}
+			errs <- renderer.CompleteJob(id)
+		}(i, wf)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent job: %v", err)
+		}
+	}
+
+	out := buf.String()
+	if strings.Count(out, "Command: ") != 1 || !strings.Contains(out, "Command: wf3.yml") {
+		t.Fatalf("expected only the failed job's details, got:\n%s", out)
+	}
+	for _, wf := range workflows {
+		if got := renderer.jobs[JobID(wf, wf.Jobs[0])].steps; len(got) != 1 {
+			t.Fatalf("%s: expected its own step only, got %+v", wf.Path, got)
+		}
+	}
+}
+
+func TestStreamingPrettyDryRunJob(t *testing.T) {
+	wf := provider.Workflow{
+		Path: "wf.yml",
+		Name: "wf",
+		Jobs: []provider.Job{{Name: "build", RawID: "build", Steps: []provider.Step{{Name: "compile", Run: "make build"}}}},
+	}
+	buf := &bytes.Buffer{}
+	renderer := NewStreamingPretty(buf)
+	if err := renderer.InitializeAllJobs([]provider.Workflow{wf}); err != nil {
+		t.Fatalf("initialize jobs: %v", err)
+	}
+	id := JobID(wf, wf.Jobs[0])
+	renderer.StartJob(id)
+	renderer.StartStep(id, "compile")
+	renderer.CompleteStep(id, "compile", report.StepResult{Status: "skipped", SkipReason: report.ReasonDryRun, StepRun: "make build"})
+	if err := renderer.CompleteJob(id); err != nil {
+		t.Fatalf("complete job: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"📝 build (dry run)", "    📝 compile\n", "      command: make build\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestStreamingPrettyUnknownJob(t *testing.T) {
+	renderer := NewStreamingPretty(&bytes.Buffer{})
+	if err := renderer.InitializeAllJobs(nil); err != nil {
+		t.Fatalf("initialize jobs: %v", err)
+	}
+	if err := renderer.CompleteStep("missing.yml#job", "step", report.StepResult{Status: "passed"}); err == nil {
+		t.Fatalf("expected error for unknown job")
+	}
+}
+
+func TestPrettyRenderContainers(t *testing.T) {
+	buf := &bytes.Buffer{}
+	workflows := []provider.Workflow{{
+		Name: "CI",
+		Jobs: []provider.Job{
+			{Name: "lint"},
+			{Name: "test", Container: &provider.Container{
+				Image:   "ruby:3.3",
+				Env:     map[string]string{"RAILS_ENV": "test", "DATABASE_URL": "postgres://db"},
+				Options: "--cpus 2",
+				Volumes: []string{"/tmp/cache:/cache"},
+			}},
+			{Name: "js", Container: &provider.Container{Image: "node:20"}},
+		},
+	}}
+	if err := NewPretty(buf).RenderContainers(workflows); err != nil {
+		t.Fatalf("RenderContainers: %v", err)
+	}
+	want := "CONTAINERS:\n" +
+		"  CI / test  ruby:3.3  env DATABASE_URL, RAILS_ENV; options --cpus 2; volumes /tmp/cache:/cache\n" +
+		"  CI / js    node:20\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := NewPretty(buf).RenderContainers([]provider.Workflow{{Name: "CI", Jobs: []provider.Job{{Name: "lint"}}}}); err != nil || buf.Len() != 0 {
+		t.Fatalf("expected no output without containers, got %q, %v", buf.String(), err)
+	}
+}
+
+func TestPrettyRenderOverruns(t *testing.T) {
+	buf := &bytes.Buffer{}
+	overruns := []report.BudgetOverrun{
+		{WorkflowPath: "ci.yml", JobName: "test", Duration: 48 * time.Minute, Limit: 30 * time.Minute, Source: report.BudgetTimeout},
+		{WorkflowPath: "ci.yml", JobName: "lint", Duration: 90 * time.Second, Limit: time.Minute, Source: report.BudgetConfig},
+	}
+	if err := NewPretty(buf).RenderOverruns(overruns); err != nil {
+		t.Fatalf("RenderOverruns: %v", err)
+	}
+	want := "OVER TIME:\n" +
+		"  job \"test\" took 48m00s, exceeds CI timeout of 30m00s (ci.yml)\n" +
+		"  job \"lint\" took 1m30s, exceeds time budget of 1m00s (ci.yml)\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := NewPretty(buf).RenderOverruns(nil); err != nil || buf.Len() != 0 {
+		t.Fatalf("expected no output without overruns, got %q, %v", buf.String(), err)
+	}
+}
+
+func TestStreamingPrettyPlain(t *testing.T) {
+	wf := provider.Workflow{
+		Path: "wf.yml",
+		Name: "wf",
+		Jobs: []provider.Job{
+			{Name: "lint", RawID: "lint", Steps: []provider.Step{{Name: "vet", Run: "go vet"}}},
+			{Name: "test", RawID: "test", Steps: []provider.Step{{Name: "unit", Run: "go test"}}},
+		},
+	}
+	buf := &bytes.Buffer{}
+	renderer := NewPlainStreamingPretty(buf)
+	if err := renderer.InitializeAllJobs([]provider.Workflow{wf}); err != nil {
+		t.Fatalf("initialize jobs: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing before a job starts, got %q", buf.String())
+	}
+	for _, job := range wf.Jobs {
+		id := JobID(wf, job)
+		renderer.StartJob(id)
+		fmt.Fprintln(buf, "step output")
+		renderer.CompleteStep(id, job.Steps[0].Name, report.StepResult{Status: "passed", Duration: time.Millisecond})
+		if err := renderer.CompleteJob(id); err != nil {
+			t.Fatalf("complete job: %v", err)
+		}
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "\033[") {
+		t.Fatalf("expected no cursor movement, got %q", out)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	want := []string{Style("running").Emoji + " lint", "step output", Style("passed").Emoji + " lint (", Style("running").Emoji + " test", "step output", Style("passed").Emoji + " test ("}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got:\n%s", len(want), out)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Fatalf("line %d = %q, want prefix %q", i+1, lines[i], prefix)
+		}
+	}
+}
+
+// needsWorkflow is a three-level DAG: build, then test, then deploy, with
+// lint needing nothing. Jobs are listed by ID, as the parser orders them.
+func needsWorkflow() provider.Workflow {
+	step := func(run string) []provider.Step { return []provider.Step{{Name: run, Run: run}} }
+	return provider.Workflow{
+		Path: "ci.yml",
+		Name: "CI",
+		Jobs: []provider.Job{
+			{Name: "build", RawID: "build", Steps: step("make")},
+			{Name: "deploy", RawID: "deploy", Needs: []string{"test"}, Steps: step("make deploy")},
+			{Name: "lint", RawID: "lint", Steps: step("make lint")},
+			{Name: "test", RawID: "test", Needs: []string{"build"}, Steps: step("make test")},
+		},
+	}
+}
+
+func TestStreamingPrettyNeeds(t *testing.T) {
+	wf := needsWorkflow()
+	id := func(name string) string { return fmt.Sprintf("ci.yml#%s", name) }
+	buf := &bytes.Buffer{}
+	renderer := NewStreamingPretty(buf)
+	if err := renderer.InitializeAllJobs([]provider.Workflow{wf}); err != nil {
+		t.Fatalf("initialize jobs: %v", err)
+	}
+	pending := Style("pending").Emoji
+	for _, want := range []string{
+		Style("running").Emoji + " build (",
+		"    " + pending + " deploy (waiting on: test)\n",
+		"\n" + pending + " lint\n",
+		"  " + pending + " test (waiting on: build)\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %q in the initial block:\n%s", want, buf.String())
+		}
+	}
+
+	run := func(name, status string) string {
+		t.Helper()
+		start := buf.Len()
+		renderer.StartJob(id(name))
+		renderer.CompleteStep(id(name), "step", report.StepResult{Status: status, Duration: time.Millisecond, StepRun: name})
+		if err := renderer.CompleteJob(id(name)); err != nil {
+			t.Fatalf("complete %s: %v", name, err)
+		}
+		return buf.String()[start:]
+	}
+	skip := func(name string) string {
+		t.Helper()
+		start := buf.Len()
+		renderer.CompleteStep(id(name), "step", report.StepResult{Status: "skipped", SkipReason: report.ReasonNeedsFailed})
+		if err := renderer.CompleteJob(id(name)); err != nil {
+			t.Fatalf("complete %s: %v", name, err)
+		}
+		return buf.String()[start:]
+	}
+	// redrawn checks that each of n redraws moved up over the whole block
+	// and the details below it, and rewrote each of the four job lines.
+	redrawn := func(out string, n, below int) {
+		t.Helper()
+		if up := strings.Count(out, "\033[1A"); up != n*(4+below) {
+			t.Fatalf("expected the cursor to move up %d lines, got %d:\n%q", n*(4+below), up, out)
+		}
+		if lines := strings.Count(out, "\033[2K\r"); lines != n*4 {
+			t.Fatalf("expected %d job lines redrawn, got %d:\n%q", n*4, lines, out)
+		}
+	}
+
+	// build is shown running from the start, so only completing it redraws
+	// the block; lint redraws it on starting too.
+	out := run("build", "passed")
+	redrawn(out, 1, 0)
+	if !strings.Contains(out, "\033[2K\r  "+pending+" test\n") || !strings.Contains(out, "deploy (waiting on: test)") {
+		t.Fatalf("expected test's wait to end once build passed:\n%q", out)
+	}
+
+	renderer.InitializeAllJobs([]provider.Workflow{wf})
+	run("build", "failed")
+	below := renderer.linesBelow
+	if below == 0 {
+		t.Fatalf("expected failure details under the block")
+	}
+	out = skip("test")
+	redrawn(out, 1, below)
+	if !strings.Contains(out, "\033[2K\r  "+Style("skipped").Emoji+" test skipped (build failed)\n") {
+		t.Fatalf("expected test skipped for build:\n%q", out)
+	}
+	out = skip("deploy")
+	redrawn(out, 1, below)
+	if !strings.Contains(out, "\033[2K\r    "+Style("skipped").Emoji+" deploy skipped (build failed)\n") {
+		t.Fatalf("expected deploy skipped for build:\n%q", out)
+	}
+	out = run("lint", "passed")
+	redrawn(out, 2, below)
+	if !strings.Contains(out, "\033[2K\r"+Style("passed").Emoji+" lint (") {
+		t.Fatalf("expected lint to run regardless:\n%q", out)
+	}
+}
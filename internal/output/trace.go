@@ -0,0 +1,158 @@
+package output
+
+import (
+	"time"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// Trace is a run laid out in the Chrome Trace Event Format, which Perfetto
+// and chrome://tracing load directly.
+type Trace struct {
+	TraceEvents     []TraceEvent `json:"traceEvents"`
+	DisplayTimeUnit string       `json:"displayTimeUnit"`
+}
+
+// TraceEvent is one entry of a trace. Timestamps and durations are in
+// microseconds from the start of the run. Pid numbers the workflow and Tid
+// the job, each from one.
+type TraceEvent struct {
+	Name string `json:"name"`
+	Cat  string `json:"cat,omitempty"`
+	Ph   string `json:"ph"`
+	Ts   int64  `json:"ts"`
+	Dur  int64  `json:"dur"`
+	Pid  int    `json:"pid"`
+	Tid  int    `json:"tid"`
+	// S is the scope of an instant event.
+	S    string         `json:"s,omitempty"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// NewTrace lays out results and the job summaries that go with them: a
+// complete event per job that started and per step that ran, and an
+// instant event per skipped step, placed where the job had got to when the
+// step was passed over. Workflows and jobs are named by metadata events.
+func NewTrace(results []report.StepResult, jobs []report.JobSummary) Trace {
+	origin := traceOrigin(results, jobs)
+	micros := func(t time.Time) int64 {
+		if t.IsZero() {
+			return 0
+		}
+		return t.Sub(origin).Microseconds()
+	}
+
+	trace := Trace{TraceEvents: []TraceEvent{}, DisplayTimeUnit: "ms"}
+	pids := make(map[string]int)
+	tids := make(map[[2]string]int)
+	for _, job := range jobs {
+		pid, ok := pids[job.WorkflowPath]
+		if !ok {
+			pid = len(pids) + 1
+			pids[job.WorkflowPath] = pid
+			trace.TraceEvents = append(trace.TraceEvents, TraceEvent{
+				Name: "process_name", Ph: "M", Pid: pid,
+				Args: map[string]any{"name": workflowLabel(job.WorkflowName, job.WorkflowPath)},
+			})
+		}
+		tid := len(tids) + 1
+		tids[[2]string{job.WorkflowPath, job.JobName}] = tid
+		trace.TraceEvents = append(trace.TraceEvents, TraceEvent{
+			Name: "thread_name", Ph: "M", Pid: pid, Tid: tid,
+			Args: map[string]any{"name": job.JobName},
+		})
+	}
+
+	for _, job := range jobs {
+		key := [2]string{job.WorkflowPath, job.JobName}
+		pid, tid := pids[job.WorkflowPath], tids[key]
+		// cursor is where the job had got to, so skips land between the
+		// steps around them.
+		cursor := micros(job.StartedAt)
+		end := cursor
+		var steps []TraceEvent
+		for _, res := range results {
+			if res.WorkflowPath != job.WorkflowPath || res.JobName != job.JobName {
+				continue
+			}
+			if res.StartedAt.IsZero() {
+				steps = append(steps, TraceEvent{
+					Name: res.StepName, Cat: "skip", Ph: "i", Ts: cursor, Pid: pid, Tid: tid, S: "t",
+					Args: traceArgs("status", res.Status, "reason", res.SkipReason, "detail", res.SkipDetail),
+				})
+				continue
+			}
+			ts := micros(res.StartedAt)
+			steps = append(steps, TraceEvent{
+				Name: res.StepName, Cat: "step", Ph: "X", Ts: ts, Dur: res.Duration.Microseconds(), Pid: pid, Tid: tid,
+				Args: traceArgs("status", res.Status, "exit_code", res.ExitCode, "sequence", res.Sequence),
+			})
+			cursor = ts + res.Duration.Microseconds()
+			if cursor > end {
+				end = cursor
+			}
+		}
+		if !job.StartedAt.IsZero() {
+			start := micros(job.StartedAt)
+			trace.TraceEvents = append(trace.TraceEvents, TraceEvent{
+				Name: job.JobName, Cat: "job", Ph: "X", Ts: start, Dur: end - start, Pid: pid, Tid: tid,
+				Args: traceArgs("status", job.Status, "sequence", job.Sequence),
+			})
+		}
+		trace.TraceEvents = append(trace.TraceEvents, steps...)
+	}
+	return trace
+}
+
+// RenderTrace writes the trace of results and jobs as JSON.
+func (j *JSONRenderer) RenderTrace(results []report.StepResult, jobs []report.JobSummary) error {
+	return j.Encode(NewTrace(results, jobs))
+}
+
+// traceOrigin is the earliest recorded start, which the trace counts from.
+func traceOrigin(results []report.StepResult, jobs []report.JobSummary) time.Time {
+	var origin time.Time
+	consider := func(t time.Time) {
+		if !t.IsZero() && (origin.IsZero() || t.Before(origin)) {
+			origin = t
+		}
+	}
+	for _, job := range jobs {
+		consider(job.StartedAt)
+	}
+	for _, res := range results {
+		consider(res.StartedAt)
+	}
+	return origin
+}
+
+// traceArgs builds an args map from key/value pairs, leaving out empty
+// strings and zero sequence numbers.
+func traceArgs(pairs ...any) map[string]any {
+	args := make(map[string]any)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key := pairs[i].(string)
+		switch v := pairs[i+1].(type) {
+		case string:
+			if v == "" {
+				continue
+			}
+		case int:
+			if v == 0 && key == "sequence" {
+				continue
+			}
+		}
+		args[key] = pairs[i+1]
+	}
+	return args
+}
+
+// workflowLabel names a workflow by its name and path, or just its path when
+// it has no name.
+func workflowLabel(name, path string) string {
+	path = slashPath(path)
+	if name == "" || name == path {
+		return path
+	}
+	return name + " (" + path + ")"
+}
@@ -0,0 +1,72 @@
+package output
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// traceRun is two workflows' jobs running side by side on a fixed clock: lint
+// starts alongside build, build fails its second step and skips its third,
+// and the docs job is cancelled before it starts.
+func traceRun() ([]report.StepResult, []report.JobSummary) {
+	at := func(ms int) time.Time { return time.Unix(1700000000, 0).Add(time.Duration(ms) * time.Millisecond) }
+	step := func(wf, job, name, status string, seq, startMS, durMS int) report.StepResult {
+		res := report.StepResult{WorkflowPath: wf, WorkflowName: "CI", JobName: job, StepName: name, Status: status, Sequence: seq}
+		if seq > 0 {
+			res.StartedAt, res.Duration = at(startMS), time.Duration(durMS)*time.Millisecond
+		}
+		if status == "failed" {
+			res.ExitCode = 1
+		}
+		return res
+	}
+	results := []report.StepResult{
+		step(".github/workflows/ci.yml", "build", "Compile", "passed", 1, 0, 1200),
+		step(".github/workflows/ci.yml", "build", "Test", "failed", 3, 1200, 800),
+		step(".github/workflows/ci.yml", "build", "Upload", "skipped", 0, 0, 0),
+		step(".github/workflows/ci.yml", "lint", "Vet", "passed", 2, 5, 300),
+		step("docs.yml", "docs", "Build docs", "skipped", 0, 0, 0),
+	}
+	results[2].SkipReason, results[2].SkipDetail = "previous_failure", "an earlier step failed"
+	results[4].WorkflowName = ""
+	results[4].SkipReason, results[4].SkipDetail = "cancelled", "cancelled: run interrupted"
+	jobs := report.SummarizeJobs(results)
+	jobs[0].Sequence, jobs[0].StartedAt = 1, at(0)
+	jobs[1].Sequence, jobs[1].StartedAt = 2, at(5)
+	return results, jobs
+}
+
+func TestRenderTraceMatchesGolden(t *testing.T) {
+	results, jobs := traceRun()
+	var buf bytes.Buffer
+	if err := NewJSON(&buf).RenderTrace(results, jobs); err != nil {
+		t.Fatalf("render trace: %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join("..", "..", "testdata", "golden", "run_trace.json"))
+	if err != nil {
+		t.Fatalf("read golden: %v", err)
+	}
+	if buf.String() != string(want) {
+		t.Fatalf("trace mismatch\n--- got ---\n%s\n--- want ---\n%s", buf.String(), want)
+	}
+}
+
+func TestTraceWithoutTimingsHasOnlySkips(t *testing.T) {
+	results := []report.StepResult{{WorkflowPath: "ci.yml", JobName: "test", StepName: "Unit", Status: "skipped", SkipReason: "dry_run"}}
+	trace := NewTrace(results, report.SummarizeJobs(results))
+	var kinds []string
+	for _, ev := range trace.TraceEvents {
+		kinds = append(kinds, ev.Ph)
+		if ev.Ts != 0 {
+			t.Fatalf("untimed events should sit at zero: %+v", ev)
+		}
+	}
+	if len(kinds) != 3 || kinds[0] != "M" || kinds[1] != "M" || kinds[2] != "i" {
+		t.Fatalf("expected names and one skip, got %+v", trace.TraceEvents)
+	}
+}
@@ -0,0 +1,152 @@
+// Package patterns holds the built-in regular expression sets that decide
+// which steps are skipped and which output lines are noise, and merges them
+// with config changes.
+package patterns
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Pattern set names.
+const (
+	// Privileged matches commands that need root or change the host's
+	// installed software.
+	Privileged = "privileged"
+	// Destructive matches commands that delete data or infrastructure.
+	Destructive = "destructive"
+	// Noise matches output lines left out of failure blocks.
+	Noise = "noise"
+)
+
+// Sets returns every pattern set name in display order.
+func Sets() []string {
+	return []string{Privileged, Destructive, Noise}
+}
+
+// Pattern origins.
+const (
+	OriginBuiltin = "builtin"
+	OriginConfig  = "config"
+)
+
+// Pattern is one regular expression in a set.
+type Pattern struct {
+	Expr   string `yaml:"pattern" json:"pattern"`
+	Note   string `yaml:"note" json:"note,omitempty"`
+	Origin string `yaml:"-" json:"origin"`
+}
+
+//go:embed builtin.yml
+var builtinData []byte
+
+// builtin is parsed once at startup; a bad entry is a bug in builtin.yml,
+// which the package tests catch.
+var builtin = mustParse(builtinData)
+
+func mustParse(data []byte) map[string][]Pattern {
+	sets, err := parse(data)
+	if err != nil {
+		panic(err)
+	}
+	return sets
+}
+
+// parse decodes pattern sets, checking that every set is known and every
+// pattern compiles.
+func parse(data []byte) (map[string][]Pattern, error) {
+	var sets map[string][]Pattern
+	if err := yaml.Unmarshal(data, &sets); err != nil {
+		return nil, fmt.Errorf("parse builtin patterns: %w", err)
+	}
+	for set, list := range sets {
+		if !slices.Contains(Sets(), set) {
+			return nil, fmt.Errorf("builtin patterns: unknown set %q", set)
+		}
+		for i := range list {
+			if list[i].Expr == "" {
+				return nil, fmt.Errorf("builtin %s pattern %d is empty", set, i+1)
+			}
+			if _, err := regexp.Compile(list[i].Expr); err != nil {
+				return nil, fmt.Errorf("builtin %s pattern %q: %w", set, list[i].Expr, err)
+			}
+			list[i].Origin = OriginBuiltin
+		}
+	}
+	return sets, nil
+}
+
+// Builtin returns a copy of the built-in patterns of set.
+func Builtin(set string) []Pattern {
+	return slices.Clone(builtin[set])
+}
+
+// Changes is how config alters one built-in set. When Replace is non-empty
+// it stands in for the built-in patterns; Add then appends and Remove drops
+// patterns by their exact expression. Removing a pattern that is not in the
+// set has no effect.
+type Changes struct {
+	Replace []string
+	Add     []string
+	Remove  []string
+}
+
+// Effective returns set after applying changes, in order: built-in (or
+// replacing) patterns first, then added ones.
+func Effective(set string, changes Changes) []Pattern {
+	base := Builtin(set)
+	if len(changes.Replace) > 0 {
+		base = configPatterns(changes.Replace)
+	}
+	out := make([]Pattern, 0, len(base)+len(changes.Add))
+	for _, p := range append(base, configPatterns(changes.Add)...) {
+		if !slices.Contains(changes.Remove, p.Expr) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func configPatterns(exprs []string) []Pattern {
+	out := make([]Pattern, 0, len(exprs))
+	for _, expr := range exprs {
+		out = append(out, Pattern{Expr: expr, Origin: OriginConfig})
+	}
+	return out
+}
+
+// Exprs returns the expression of each pattern.
+func Exprs(list []Pattern) []string {
+	out := make([]string, 0, len(list))
+	for _, p := range list {
+		out = append(out, p.Expr)
+	}
+	return out
+}
+
+// Match returns the first pattern in list matching s. Empty and invalid
+// patterns never match, as when steps are checked at run time.
+func Match(list []Pattern, s string) (Pattern, bool) {
+	for _, p := range list {
+		if p.Expr == "" {
+			continue
+		}
+		if re, err := regexp.Compile(p.Expr); err == nil && re.MatchString(s) {
+			return p, true
+		}
+	}
+	return Pattern{}, false
+}
+
+// Report is one effective set as the patterns command shows it. When Test
+// is set, Match is the first pattern it matched, or nil.
+type Report struct {
+	Set      string    `json:"set"`
+	Patterns []Pattern `json:"patterns"`
+	Test     string    `json:"test,omitempty"`
+	Match    *Pattern  `json:"match,omitempty"`
+}
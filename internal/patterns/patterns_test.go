@@ -0,0 +1,112 @@
+package patterns
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinSetsLoad(t *testing.T) {
+	for _, set := range Sets() {
+		list := Builtin(set)
+		if len(list) == 0 {
+			t.Fatalf("%s: expected built-in patterns", set)
+		}
+		for _, p := range list {
+			if p.Origin != OriginBuiltin || p.Note == "" {
+				t.Fatalf("%s: expected a noted builtin pattern, got %+v", set, p)
+			}
+		}
+	}
+	// Builtin hands out copies.
+	list := Builtin(Privileged)
+	list[0].Expr = "changed"
+	if Builtin(Privileged)[0].Expr == "changed" {
+		t.Fatalf("expected Builtin to return a copy")
+	}
+}
+
+func TestParseRejectsBadData(t *testing.T) {
+	cases := map[string]string{
+		"privileged:\n  - pattern: '('\n": "builtin privileged pattern",
+		"unknown:\n  - pattern: 'x'\n":    `unknown set "unknown"`,
+		"noise:\n  - note: no pattern\n":  "noise pattern 1 is empty",
+		"noise: [":                        "parse builtin patterns",
+	}
+	for data, want := range cases {
+		if _, err := parse([]byte(data)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parse(%q): expected error containing %q, got %v", data, want, err)
+		}
+	}
+}
+
+func TestEffective(t *testing.T) {
+	builtin := Exprs(Builtin(Privileged))
+	cases := []struct {
+		name    string
+		changes Changes
+		want    []string
+	}{
+		{name: "builtin", want: builtin},
+		{
+			name:    "add and remove",
+			changes: Changes{Add: []string{`\bsnap\s+install\b`}, Remove: []string{`(?i)\bbrew\b`, `not there`}},
+			want:    append(without(builtin, `(?i)\bbrew\b`), `\bsnap\s+install\b`),
+		},
+		{
+			name:    "replace",
+			changes: Changes{Replace: []string{`^doas\b`, `^pkexec\b`}, Add: []string{`\bsnap\b`}, Remove: []string{`^pkexec\b`}},
+			want:    []string{`^doas\b`, `\bsnap\b`},
+		},
+		{
+			name:    "remove everything",
+			changes: Changes{Remove: builtin},
+			want:    []string{},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Effective(Privileged, tc.changes)
+			if exprs := Exprs(got); !reflect.DeepEqual(exprs, tc.want) {
+				t.Fatalf("expressions = %q, want %q", exprs, tc.want)
+			}
+			fromConfig := append(append([]string{}, tc.changes.Replace...), tc.changes.Add...)
+			for _, p := range got {
+				want := OriginBuiltin
+				if slices.Contains(fromConfig, p.Expr) {
+					want = OriginConfig
+				}
+				if p.Origin != want {
+					t.Fatalf("%s: origin %q, want %q", p.Expr, p.Origin, want)
+				}
+			}
+		})
+	}
+}
+
+func without(list []string, drop string) []string {
+	var out []string
+	for _, s := range list {
+		if s != drop {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func TestMatch(t *testing.T) {
+	list := []Pattern{{Expr: ""}, {Expr: "("}, {Expr: `\bapt\b`, Note: "apt"}, {Expr: `apt-get`}}
+	if got, ok := Match(list, "sudo apt-get install jq"); !ok || got.Expr != `\bapt\b` {
+		t.Fatalf("expected the first valid match, got %+v, %v", got, ok)
+	}
+	if _, ok := Match(list, "make test"); ok {
+		t.Fatalf("expected no match")
+	}
+	if got, ok := Match(Builtin(Destructive), "terraform -chdir=infra apply"); !ok || got.Note != "terraform apply and destroy" {
+		t.Fatalf("expected the terraform pattern, got %+v, %v", got, ok)
+	}
+	if got, ok := Match(Builtin(Noise), strings.Repeat("*", 72)); !ok || got.Expr != `\*{72}` {
+		t.Fatalf("expected the banner pattern, got %+v, %v", got, ok)
+	}
+}
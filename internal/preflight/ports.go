@@ -0,0 +1,203 @@
+// Package preflight checks the local machine for conditions that would make
+// a job fail partway through, so it can fail before starting instead.
+package preflight
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PortConflict is a TCP port a job needs that something else is listening
+// on. PID and Command name the listener when it could be found.
+type PortConflict struct {
+	Port    int
+	PID     int
+	Command string
+}
+
+// String describes the conflict, naming the owning process when known.
+func (c PortConflict) String() string {
+	switch {
+	case c.PID > 0 && c.Command != "":
+		return fmt.Sprintf("port %d is in use by %s (pid %d)", c.Port, c.Command, c.PID)
+	case c.PID > 0:
+		return fmt.Sprintf("port %d is in use by pid %d", c.Port, c.PID)
+	default:
+		return fmt.Sprintf("port %d is in use", c.Port)
+	}
+}
+
+// BusyPorts probes each port by listening on it and returns the ones that
+// are taken, in the order given. Owners are looked up best effort: through
+// /proc on Linux and lsof elsewhere.
+func BusyPorts(ports []int) []PortConflict {
+	var busy []PortConflict
+	for _, port := range ports {
+		if portFree(port) {
+			continue
+		}
+		conflict := PortConflict{Port: port}
+		conflict.PID, conflict.Command = portOwner(port)
+		busy = append(busy, conflict)
+	}
+	return busy
+}
+
+// portFree reports whether port can be listened on, both on loopback, where
+// dev servers usually bind, and on every interface.
+func portFree(port int) bool {
+	for _, addr := range []string{"127.0.0.1", ""} {
+		ln, err := net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
+		if err != nil {
+			return false
+		}
+		ln.Close()
+	}
+	return true
+}
+
+// portOwner returns the process listening on port, or zero values when it
+// cannot be found.
+func portOwner(port int) (int, string) {
+	if runtime.GOOS == "linux" {
+		if pid := procListener(port); pid > 0 {
+			return pid, procCommand(pid)
+		}
+		return 0, ""
+	}
+	return lsofListener(port)
+}
+
+// procListener finds the pid holding a listening socket on port by matching
+// the socket's inode from /proc/net/tcp{,6} against every process's open
+// file descriptors. Processes we may not inspect are passed over.
+func procListener(port int) int {
+	inodes := make(map[string]bool)
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		for _, inode := range listeningInodes(table, port) {
+			inodes[inode] = true
+		}
+	}
+	if len(inodes) == 0 {
+		return 0
+	}
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		target, err := os.Readlink(fd)
+		if err != nil || !strings.HasPrefix(target, "socket:[") {
+			continue
+		}
+		if inodes[strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]")] {
+			pid, _ := strconv.Atoi(strings.Split(fd, string(filepath.Separator))[2])
+			return pid
+		}
+	}
+	return 0
+}
+
+// tcpListen is the state column /proc/net/tcp uses for listening sockets.
+const tcpListen = "0A"
+
+// listeningInodes returns the inodes of sockets in table listening on port.
+func listeningInodes(table string, port int) []string {
+	f, err := os.Open(table)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	want := fmt.Sprintf(":%04X", port)
+	var inodes []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		if len(fields) < 10 || fields[3] != tcpListen || !strings.HasSuffix(fields[1], want) {
+			continue
+		}
+		inodes = append(inodes, fields[9])
+	}
+	return inodes
+}
+
+func procCommand(pid int) string {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// lsofListener asks lsof, when it is installed, for the process listening
+// on port.
+func lsofListener(port int) (int, string) {
+	out, err := exec.Command("lsof", "-nP", fmt.Sprintf("-iTCP:%d", port), "-sTCP:LISTEN", "-Fpc").Output()
+	if err != nil {
+		return 0, ""
+	}
+	var pid int
+	var command string
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "p") && pid == 0:
+			pid, _ = strconv.Atoi(line[1:])
+		case strings.HasPrefix(line, "c") && command == "":
+			command = line[1:]
+		}
+	}
+	return pid, command
+}
+
+var (
+	// portVar matches variables that name a port to listen on: PORT itself
+	// and names ending in _PORT.
+	portVar = regexp.MustCompile(`^(?:[A-Za-z0-9_]*_)?PORT$`)
+	// portFlags match ports given to servers on the command line:
+	// `PORT=3000 rails s`, `--port 3035`, `--port=3035`, and `-p 3000` or
+	// docker's `-p 3000:3000`, whose first number is the host port.
+	portFlags = []*regexp.Regexp{
+		regexp.MustCompile(`(?:^|\s)(?:[A-Za-z0-9_]*_)?PORT=(\d+)\b`),
+		regexp.MustCompile(`(?:^|\s)--port[= ](\d+)\b`),
+		regexp.MustCompile(`(?:^|\s)-p\s+(\d+)(?::\d+)?(?:\s|$)`),
+	}
+)
+
+// DetectPorts guesses the ports a job's servers will listen on from its
+// scripts and env values. It is a heuristic: a PORT variable may just as
+// well name a service the job expects to find running.
+func DetectPorts(scripts []string, env ...map[string]string) []int {
+	seen := make(map[int]bool)
+	add := func(value string) {
+		if port, err := strconv.Atoi(value); err == nil && port > 0 && port < 65536 {
+			seen[port] = true
+		}
+	}
+	for _, vars := range env {
+		for name, value := range vars {
+			if portVar.MatchString(name) {
+				add(strings.TrimSpace(value))
+			}
+		}
+	}
+	for _, script := range scripts {
+		for _, re := range portFlags {
+			for _, m := range re.FindAllStringSubmatch(script, -1) {
+				add(m[1])
+			}
+		}
+	}
+	ports := make([]int, 0, len(seen))
+	for port := range seen {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return ports
+}
@@ -0,0 +1,98 @@
+package preflight
+
+import (
+	"net"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// listen opens a loopback listener for the test and returns its port.
+func listen(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// freePort returns a port nothing was listening on a moment ago.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func TestBusyPortsFindsListeners(t *testing.T) {
+	busy, free := listen(t), freePort(t)
+	got := BusyPorts([]int{free, busy})
+	if len(got) != 1 || got[0].Port != busy {
+		t.Fatalf("BusyPorts = %+v, want only port %d", got, busy)
+	}
+	if runtime.GOOS == "linux" {
+		if got[0].PID != os.Getpid() || got[0].Command == "" {
+			t.Fatalf("expected the test process to own the port, got %+v", got[0])
+		}
+		if want := "is in use by " + got[0].Command; !strings.Contains(got[0].String(), want) {
+			t.Fatalf("String() = %q, want it to contain %q", got[0].String(), want)
+		}
+	}
+}
+
+func TestPortConflictString(t *testing.T) {
+	cases := []struct {
+		conflict PortConflict
+		want     string
+	}{
+		{PortConflict{Port: 3000, PID: 42, Command: "ruby"}, "port 3000 is in use by ruby (pid 42)"},
+		{PortConflict{Port: 3000, PID: 42}, "port 3000 is in use by pid 42"},
+		{PortConflict{Port: 3035}, "port 3035 is in use"},
+	}
+	for _, tc := range cases {
+		if got := tc.conflict.String(); got != tc.want {
+			t.Errorf("String() = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestListeningInodes(t *testing.T) {
+	table := "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n" +
+		"   0: 0100007F:0BB8 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 4242 1 0 100 0 0 10 0\n" +
+		"   1: 0100007F:0BB8 0100007F:D431 01 00000000:00000000 00:00000000 00000000  1000        0 4343 1 0 20 4 30 10 -1\n" +
+		"   2: 00000000:0BCB 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 4444 1 0 100 0 0 10 0\n"
+	path := t.TempDir() + "/tcp"
+	if err := os.WriteFile(path, []byte(table), 0o644); err != nil {
+		t.Fatalf("write table: %v", err)
+	}
+	if got := listeningInodes(path, 3000); !reflect.DeepEqual(got, []string{"4242"}) {
+		t.Fatalf("port 3000 inodes = %v, want [4242]", got)
+	}
+	if got := listeningInodes(path, 3019); !reflect.DeepEqual(got, []string{"4444"}) {
+		t.Fatalf("port 3019 inodes = %v, want [4444]", got)
+	}
+}
+
+func TestDetectPorts(t *testing.T) {
+	scripts := []string{
+		"bin/rails server -p 3000 &",
+		"bin/webpack-dev-server --port=3035",
+		"docker run -p 6380:6379 redis",
+		"mkdir -p tmp/pids && PORT=4000 yarn start",
+		"curl http://localhost:9999/health",
+	}
+	env := map[string]string{"PORT": "3000", "CAPYBARA_SERVER_PORT": "3001", "REPORT": "7", "DB_PORT": "not-a-port"}
+	got := DetectPorts(scripts, env)
+	want := []int{3000, 3001, 3035, 4000, 6380}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("DetectPorts = %v, want %v", got, want)
+	}
+}
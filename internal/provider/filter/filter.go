@@ -6,6 +6,7 @@ import (
 	"strings"
 
     "github.com/bgricker/testdrive/internal/provider"
+    "github.com/bgricker/testdrive/internal/report"
 )
 
 // Pattern represents a compiled filter condition supporting substring and regex matching.
@@ -48,20 +49,54 @@ func (p Pattern) Match(s string) bool {
 	return strings.Contains(strings.ToLower(s), p.lower)
 }
 
+// String returns the pattern as it was written.
+func (p Pattern) String() string {
+	return p.raw
+}
+
+// Regexp reports whether the pattern is a /regex/.
+func (p Pattern) Regexp() bool {
+	return p.regex != nil
+}
+
 // FilterWorkflows applies job and step filters to workflows, returning a new slice with matches.
 func FilterWorkflows(workflows []provider.Workflow, jobPatterns, onlyPatterns, skipPatterns []Pattern) []provider.Workflow {
+	filtered, _ := FilterWorkflowsWithSkips(workflows, jobPatterns, onlyPatterns, skipPatterns)
+	return filtered
+}
+
+// FilterWorkflowsWithSkips behaves like FilterWorkflows and additionally
+// reports every step it dropped together with the reason.
+func FilterWorkflowsWithSkips(workflows []provider.Workflow, jobPatterns, onlyPatterns, skipPatterns []Pattern) ([]provider.Workflow, []report.SkippedStep) {
 	if len(workflows) == 0 {
-		return nil
+		return nil, nil
 	}
 
+	var dropped []report.SkippedStep
 	result := make([]provider.Workflow, 0, len(workflows))
 	for _, wf := range workflows {
+		record := func(job provider.Job, step provider.Step, reason, detail string) {
+			dropped = append(dropped, report.SkippedStep{
+				WorkflowPath: wf.Path,
+				WorkflowName: wf.Name,
+				JobName:      job.Name,
+				StepName:     step.Name,
+				Reason:       reason,
+				Detail:       detail,
+			})
+		}
+
 		filteredJobs := make([]provider.Job, 0, len(wf.Jobs))
 		for _, job := range wf.Jobs {
 			if len(jobPatterns) > 0 && !matchesJob(job, jobPatterns) {
+				for _, step := range job.Steps {
+					record(job, step, report.ReasonFilteredJob, "job did not match --job")
+				}
 				continue
 			}
-			filteredSteps := filterSteps(job.Steps, onlyPatterns, skipPatterns)
+			filteredSteps := filterSteps(job.Steps, onlyPatterns, skipPatterns, func(step provider.Step, reason, detail string) {
+				record(job, step, reason, detail)
+			})
 			if len(filteredSteps) == 0 {
 				continue
 			}
@@ -76,7 +111,7 @@ func FilterWorkflows(workflows []provider.Workflow, jobPatterns, onlyPatterns, s
 		wfCopy.Jobs = filteredJobs
 		result = append(result, wfCopy)
 	}
-	return result
+	return result, dropped
 }
 
 func matchesJob(job provider.Job, patterns []Pattern) bool {
@@ -91,19 +126,14 @@ func matchesJob(job provider.Job, patterns []Pattern) bool {
 	return false
 }
 
-func filterSteps(steps []provider.Step, onlyPatterns, skipPatterns []Pattern) []provider.Step {
+func filterSteps(steps []provider.Step, onlyPatterns, skipPatterns []Pattern, drop func(step provider.Step, reason, detail string)) []provider.Step {
 	if len(steps) == 0 {
 		return nil
 	}
 	result := make([]provider.Step, 0, len(steps))
 	for _, step := range steps {
-		if step.Run == "" {
-			continue
-		}
-		if len(onlyPatterns) > 0 && !matchesStep(step, onlyPatterns) {
-			continue
-		}
-		if len(skipPatterns) > 0 && matchesStep(step, skipPatterns) {
+		if reason, detail, ok := stepDropReason(step, onlyPatterns, skipPatterns); ok {
+			drop(step, reason, detail)
 			continue
 		}
 		result = append(result, step)
@@ -111,6 +141,50 @@ func filterSteps(steps []provider.Step, onlyPatterns, skipPatterns []Pattern) []
 	return result
 }
 
+// DropReason reports whether the job and step filters would drop step, with
+// the same reason code and detail FilterWorkflowsWithSkips records.
+func DropReason(job provider.Job, step provider.Step, jobPatterns, onlyPatterns, skipPatterns []Pattern) (reason, detail string, dropped bool) {
+	if len(jobPatterns) > 0 && !matchesJob(job, jobPatterns) {
+		return report.ReasonFilteredJob, "job did not match --job", true
+	}
+	return stepDropReason(step, onlyPatterns, skipPatterns)
+}
+
+func stepDropReason(step provider.Step, onlyPatterns, skipPatterns []Pattern) (reason, detail string, dropped bool) {
+	if step.Run == "" {
+		return report.ReasonUsesStep, "uses: " + step.Uses, true
+	}
+	if len(onlyPatterns) > 0 && !matchesStep(step, onlyPatterns) {
+		return report.ReasonFilteredOnly, "did not match --only-step", true
+	}
+	if len(skipPatterns) > 0 {
+		if pattern, ok := matchingPattern(step, skipPatterns); ok {
+			return report.ReasonFilteredSkip, fmt.Sprintf("matched --skip-step %q", pattern.raw), true
+		}
+	}
+	return "", "", false
+}
+
+// MatchJob reports whether any pattern matches the job's name or ID. No
+// patterns match every job.
+func MatchJob(job provider.Job, patterns []Pattern) bool {
+	return matchesJob(job, patterns)
+}
+
+// MatchStep reports whether any pattern matches the step's name or script.
+func MatchStep(step provider.Step, patterns []Pattern) bool {
+	return matchesStep(step, patterns)
+}
+
+func matchingPattern(step provider.Step, patterns []Pattern) (Pattern, bool) {
+	for _, pattern := range patterns {
+		if pattern.Match(step.Name) || pattern.Match(step.Run) {
+			return pattern, true
+		}
+	}
+	return Pattern{}, false
+}
+
 func matchesStep(step provider.Step, patterns []Pattern) bool {
 	if len(patterns) == 0 {
 		return true
@@ -4,6 +4,7 @@ import (
 	"testing"
 
     "github.com/bgricker/testdrive/internal/provider"
+    "github.com/bgricker/testdrive/internal/report"
 )
 
 func TestFilterWorkflowsByJob(t *testing.T) {
@@ -77,6 +78,34 @@ func TestFilterWorkflowsSteps(t *testing.T) {
 	}
 }
 
+func TestDropReason(t *testing.T) {
+	job := provider.Job{Name: "Test", RawID: "test"}
+	jobs, _ := Compile([]string{"lint"})
+	only, _ := Compile([]string{"/go/"})
+	skip, _ := Compile([]string{"unit"})
+
+	cases := []struct {
+		name   string
+		step   provider.Step
+		jobs   []Pattern
+		reason string
+	}{
+		{name: "job", step: provider.Step{Name: "Unit", Run: "go test ./..."}, jobs: jobs, reason: report.ReasonFilteredJob},
+		{name: "uses", step: provider.Step{Name: "Install", Uses: "actions/setup"}, reason: report.ReasonUsesStep},
+		{name: "only", step: provider.Step{Name: "Build", Run: "make"}, reason: report.ReasonFilteredOnly},
+		{name: "skip", step: provider.Step{Name: "Unit", Run: "go test ./..."}, reason: report.ReasonFilteredSkip},
+		{name: "kept", step: provider.Step{Name: "Lint", Run: "go vet ./..."}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason, detail, dropped := DropReason(job, tc.step, tc.jobs, only, skip)
+			if dropped != (tc.reason != "") || reason != tc.reason {
+				t.Fatalf("DropReason = (%q, %q, %v), want %q", reason, detail, dropped, tc.reason)
+			}
+		})
+	}
+}
+
 func TestCompileErrors(t *testing.T) {
 	if _, err := Compile([]string{"/(/"}); err == nil {
 		t.Fatalf("expected compile error")
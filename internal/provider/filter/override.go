@@ -0,0 +1,139 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// Override is a compiled config override. Empty pattern slices match everything.
+type Override struct {
+	Label string
+	Jobs  []Pattern
+	Steps []Pattern
+	Skip  bool
+	Env   map[string]string
+	Run   string
+	Shell string
+}
+
+// CompileOverride builds an Override from raw job/step patterns. At least one
+// pattern must be supplied so an override cannot silently apply to every step.
+func CompileOverride(job, step string) (Override, error) {
+	jobs, err := Compile([]string{job})
+	if err != nil {
+		return Override{}, err
+	}
+	steps, err := Compile([]string{step})
+	if err != nil {
+		return Override{}, err
+	}
+	if len(jobs) == 0 && len(steps) == 0 {
+		return Override{}, fmt.Errorf("override must set a job or step pattern")
+	}
+	return Override{Label: overrideLabel(job, step), Jobs: jobs, Steps: steps}, nil
+}
+
+// Matches reports whether the override applies to step within job.
+func (o Override) Matches(job provider.Job, step provider.Step) bool {
+	return matchesJob(job, o.Jobs) && matchesStep(step, o.Steps)
+}
+
+// ApplyOverrides returns a copy of workflows with each matching override
+// applied in order. Later overrides win when several touch the same step.
+func ApplyOverrides(workflows []provider.Workflow, overrides []Override) []provider.Workflow {
+	if len(overrides) == 0 {
+		return workflows
+	}
+	result := make([]provider.Workflow, 0, len(workflows))
+	for _, wf := range workflows {
+		wfCopy := wf
+		wfCopy.Jobs = make([]provider.Job, 0, len(wf.Jobs))
+		for _, job := range wf.Jobs {
+			jobCopy := job
+			jobCopy.Steps = make([]provider.Step, 0, len(job.Steps))
+			for _, step := range job.Steps {
+				step, _ = ApplyToStep(job, step, overrides)
+				jobCopy.Steps = append(jobCopy.Steps, step)
+			}
+			wfCopy.Jobs = append(wfCopy.Jobs, jobCopy)
+		}
+		result = append(result, wfCopy)
+	}
+	return result
+}
+
+// ApplyToStep applies each matching override to step in order and returns
+// the result with the labels of the overrides that matched.
+func ApplyToStep(job provider.Job, step provider.Step, overrides []Override) (provider.Step, []string) {
+	var labels []string
+	for _, o := range overrides {
+		if o.Matches(job, step) {
+			step = o.apply(step)
+			labels = append(labels, o.Label)
+		}
+	}
+	return step, labels
+}
+
+// UnmatchedOverrides returns the overrides that match no run step in workflows.
+func UnmatchedOverrides(workflows []provider.Workflow, overrides []Override) []Override {
+	var unmatched []Override
+	for _, o := range overrides {
+		if !o.matchesAny(workflows) {
+			unmatched = append(unmatched, o)
+		}
+	}
+	return unmatched
+}
+
+func (o Override) matchesAny(workflows []provider.Workflow) bool {
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			for _, step := range job.Steps {
+				if step.Run != "" && o.Matches(job, step) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (o Override) apply(step provider.Step) provider.Step {
+	if o.Skip {
+		step.Skip = true
+		step.Overridden = true
+	}
+	if o.Run != "" {
+		step.Run = o.Run
+		step.Overridden = true
+	}
+	if o.Shell != "" {
+		step.Shell = o.Shell
+		step.Overridden = true
+	}
+	if len(o.Env) > 0 {
+		env := make(map[string]string, len(step.Env)+len(o.Env))
+		for k, v := range step.Env {
+			env[k] = v
+		}
+		for k, v := range o.Env {
+			env[k] = v
+		}
+		step.Env = env
+		step.Overridden = true
+	}
+	return step
+}
+
+func overrideLabel(job, step string) string {
+	switch {
+	case job != "" && step != "":
+		return fmt.Sprintf("job %q step %q", job, step)
+	case job != "":
+		return fmt.Sprintf("job %q", job)
+	default:
+		return fmt.Sprintf("step %q", step)
+	}
+}
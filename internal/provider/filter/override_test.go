@@ -0,0 +1,133 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func overrideWorkflow() provider.Workflow {
+	return provider.Workflow{
+		Path: "wf.yml",
+		Name: "Example",
+		Jobs: []provider.Job{
+			{
+				Name:  "Test",
+				RawID: "test",
+				Steps: []provider.Step{
+					{Name: "Run specs", Run: "bundle exec rspec", Env: map[string]string{"RAILS_ENV": "test"}},
+					{Name: "Upload coverage", Run: "codecov"},
+				},
+			},
+			{
+				Name:  "Lint",
+				RawID: "lint",
+				Steps: []provider.Step{{Name: "Rubocop", Run: "bundle exec rubocop"}},
+			},
+		},
+	}
+}
+
+func mustOverride(t *testing.T, job, step string) Override {
+	t.Helper()
+	o, err := CompileOverride(job, step)
+	if err != nil {
+		t.Fatalf("CompileOverride(%q, %q): %v", job, step, err)
+	}
+	return o
+}
+
+func TestApplyOverridesSkip(t *testing.T) {
+	o := mustOverride(t, "", "/coverage/")
+	o.Skip = true
+
+	got := ApplyOverrides([]provider.Workflow{overrideWorkflow()}, []Override{o})
+	steps := got[0].Jobs[0].Steps
+	if !steps[1].Skip || !steps[1].Overridden {
+		t.Fatalf("expected coverage step skipped, got %+v", steps[1])
+	}
+	if steps[0].Skip || steps[0].Overridden {
+		t.Fatalf("expected specs step untouched, got %+v", steps[0])
+	}
+}
+
+func TestApplyOverridesRun(t *testing.T) {
+	o := mustOverride(t, "test", "specs")
+	o.Run = "bundle exec rspec --tag ~slow"
+
+	original := overrideWorkflow()
+	got := ApplyOverrides([]provider.Workflow{original}, []Override{o})
+	if run := got[0].Jobs[0].Steps[0].Run; run != "bundle exec rspec --tag ~slow" {
+		t.Fatalf("expected replaced command, got %q", run)
+	}
+	if !got[0].Jobs[0].Steps[0].Overridden {
+		t.Fatalf("expected step marked overridden")
+	}
+	if original.Jobs[0].Steps[0].Run != "bundle exec rspec" {
+		t.Fatalf("expected input workflows left unmodified")
+	}
+}
+
+func TestApplyOverridesEnvByJob(t *testing.T) {
+	o := mustOverride(t, "test", "")
+	o.Env = map[string]string{"RAILS_ENV": "ci", "COVERAGE": "0"}
+
+	original := overrideWorkflow()
+	got := ApplyOverrides([]provider.Workflow{original}, []Override{o})
+	for _, step := range got[0].Jobs[0].Steps {
+		if step.Env["COVERAGE"] != "0" || step.Env["RAILS_ENV"] != "ci" || !step.Overridden {
+			t.Fatalf("expected env merged into %q, got %+v", step.Name, step)
+		}
+	}
+	if got[0].Jobs[1].Steps[0].Overridden {
+		t.Fatalf("expected lint job untouched")
+	}
+	if original.Jobs[0].Steps[0].Env["RAILS_ENV"] != "test" {
+		t.Fatalf("expected original env map left unmodified")
+	}
+}
+
+func TestUnmatchedOverrides(t *testing.T) {
+	matched := mustOverride(t, "lint", "")
+	missing := mustOverride(t, "deploy", "")
+
+	got := UnmatchedOverrides([]provider.Workflow{overrideWorkflow()}, []Override{matched, missing})
+	if len(got) != 1 || got[0].Label != `job "deploy"` {
+		t.Fatalf("expected only deploy override unmatched, got %+v", got)
+	}
+}
+
+func TestCompileOverrideRequiresPattern(t *testing.T) {
+	if _, err := CompileOverride("", ""); err == nil {
+		t.Fatalf("expected error for override without patterns")
+	}
+}
+
+func TestApplyToStepReportsLabels(t *testing.T) {
+	wf := overrideWorkflow()
+	env := mustOverride(t, "test", "")
+	env.Env = map[string]string{"RAILS_ENV": "ci"}
+	skip := mustOverride(t, "", "rubocop")
+	skip.Skip = true
+
+	step, labels := ApplyToStep(wf.Jobs[0], wf.Jobs[0].Steps[0], []Override{env, skip})
+	if step.Env["RAILS_ENV"] != "ci" || step.Skip {
+		t.Fatalf("unexpected step after overrides: %+v", step)
+	}
+	if len(labels) != 1 || labels[0] != env.Label {
+		t.Fatalf("labels = %q, want [%q]", labels, env.Label)
+	}
+}
+
+func TestApplyOverridesShellByJob(t *testing.T) {
+	o := mustOverride(t, "lint", "")
+	o.Shell = "zsh"
+
+	got := ApplyOverrides([]provider.Workflow{overrideWorkflow()}, []Override{o})
+	if step := got[0].Jobs[1].Steps[0]; step.Shell != "zsh" || !step.Overridden {
+		t.Fatalf("expected lint step to use zsh, got %+v", step)
+	}
+	if step := got[0].Jobs[0].Steps[0]; step.Shell != "" {
+		t.Fatalf("expected test job untouched, got %+v", step)
+	}
+}
@@ -0,0 +1,35 @@
+package filter
+
+import "github.com/bgricker/testdrive/internal/provider"
+
+// MarkTeardown returns a copy of workflows with Teardown set on every step
+// whose name or script matches one of patterns.
+func MarkTeardown(workflows []provider.Workflow, patterns []Pattern) []provider.Workflow {
+	return markSteps(workflows, patterns, func(step *provider.Step) { step.Teardown = true })
+}
+
+// markSteps returns a copy of workflows with mark applied to every run step
+// whose name or script matches one of patterns.
+func markSteps(workflows []provider.Workflow, patterns []Pattern, mark func(*provider.Step)) []provider.Workflow {
+	if len(patterns) == 0 {
+		return workflows
+	}
+	result := make([]provider.Workflow, 0, len(workflows))
+	for _, wf := range workflows {
+		wfCopy := wf
+		wfCopy.Jobs = make([]provider.Job, 0, len(wf.Jobs))
+		for _, job := range wf.Jobs {
+			jobCopy := job
+			jobCopy.Steps = make([]provider.Step, 0, len(job.Steps))
+			for _, step := range job.Steps {
+				if step.Run != "" && matchesStep(step, patterns) {
+					mark(&step)
+				}
+				jobCopy.Steps = append(jobCopy.Steps, step)
+			}
+			wfCopy.Jobs = append(wfCopy.Jobs, jobCopy)
+		}
+		result = append(result, wfCopy)
+	}
+	return result
+}
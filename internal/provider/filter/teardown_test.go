@@ -0,0 +1,24 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestMarkTeardown(t *testing.T) {
+	patterns, err := Compile([]string{"codecov", "/^Rubo/"})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	workflows := []provider.Workflow{overrideWorkflow()}
+
+	got := MarkTeardown(workflows, patterns)
+	test, lint := got[0].Jobs[0].Steps, got[0].Jobs[1].Steps
+	if test[0].Teardown || !test[1].Teardown || !lint[0].Teardown {
+		t.Fatalf("expected the coverage upload and rubocop to be marked, got %+v %+v", test, lint)
+	}
+	if workflows[0].Jobs[0].Steps[1].Teardown {
+		t.Fatalf("expected the input workflows to be left unchanged")
+	}
+}
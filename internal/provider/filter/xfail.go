@@ -0,0 +1,9 @@
+package filter
+
+import "github.com/bgricker/testdrive/internal/provider"
+
+// MarkExpectedFailures returns a copy of workflows with ExpectedFailure set
+// on every step whose name or script matches one of patterns.
+func MarkExpectedFailures(workflows []provider.Workflow, patterns []Pattern) []provider.Workflow {
+	return markSteps(workflows, patterns, func(step *provider.Step) { step.ExpectedFailure = true })
+}
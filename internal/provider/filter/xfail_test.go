@@ -0,0 +1,27 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestMarkExpectedFailures(t *testing.T) {
+	patterns, err := Compile([]string{"rspec"})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	workflows := []provider.Workflow{overrideWorkflow()}
+
+	got := MarkExpectedFailures(workflows, patterns)
+	test, lint := got[0].Jobs[0].Steps, got[0].Jobs[1].Steps
+	if !test[0].ExpectedFailure || test[1].ExpectedFailure || lint[0].ExpectedFailure {
+		t.Fatalf("expected only the specs to be marked, got %+v %+v", test, lint)
+	}
+	if test[0].Teardown {
+		t.Fatalf("expected marking failures to leave teardown alone")
+	}
+	if workflows[0].Jobs[0].Steps[0].ExpectedFailure {
+		t.Fatalf("expected the input workflows to be left unchanged")
+	}
+}
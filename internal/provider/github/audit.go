@@ -0,0 +1,168 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// keySchema describes the keys the parser reads from one mapping in a
+// workflow. Each read key maps to the schema of its value, or to nil when the
+// value is free-form, such as an env map. ignored lists keys GitHub Actions
+// defines that have no bearing on a local run.
+type keySchema struct {
+	read    map[string]*keySchema
+	ignored map[string]bool
+	// each, when set, applies to every value of the mapping, as for jobs
+	// keyed by ID.
+	each *keySchema
+}
+
+// leaves maps keys with scalar or free-form values.
+func leaves(keys ...string) map[string]*keySchema {
+	m := make(map[string]*keySchema, len(keys))
+	for _, k := range keys {
+		m[k] = nil
+	}
+	return m
+}
+
+func keySet(keys ...string) map[string]bool {
+	m := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		m[k] = true
+	}
+	return m
+}
+
+// The schemas mirror workflowDocument, jobDocument and stepDocument; a key
+// added to one of those belongs here too.
+var (
+	defaultsKeys = &keySchema{read: map[string]*keySchema{
+		"run": {read: leaves("shell", "working-directory")},
+	}}
+	concurrencyKeys = &keySchema{read: leaves("group", "cancel-in-progress")}
+
+	stepKeys = &keySchema{
+		read:    leaves("name", "run", "uses", "env", "shell", "working-directory", "if"),
+		ignored: keySet("id", "with", "continue-on-error", "timeout-minutes"),
+	}
+
+	jobKeys = &keySchema{
+		read: map[string]*keySchema{
+			"name":            nil,
+			"env":             nil,
+			"defaults":        defaultsKeys,
+			"steps":           stepKeys,
+			"services":        nil,
+			"container":       {read: leaves("image", "env", "options", "volumes"), ignored: keySet("credentials", "ports")},
+			"strategy":        {read: leaves("matrix", "max-parallel", "fail-fast")},
+			"if":              nil,
+			"concurrency":     concurrencyKeys,
+			"environment":     {read: leaves("name"), ignored: keySet("url")},
+			"timeout-minutes": nil,
+			"needs":           nil,
+		},
+		ignored: keySet("runs-on", "permissions", "outputs", "continue-on-error", "uses", "with", "secrets"),
+	}
+
+	workflowKeys = &keySchema{
+		read: map[string]*keySchema{
+			"name":        nil,
+			"env":         nil,
+			"defaults":    defaultsKeys,
+			"jobs":        {each: jobKeys},
+			"concurrency": concurrencyKeys,
+		},
+		ignored: keySet("on", "run-name", "permissions"),
+	}
+)
+
+// keyNotices audits the decoded document against workflowKeys. Keys known
+// to have no local effect and keys the parser does not know at all are each
+// returned as an info notice, in document order.
+func keyNotices(doc *yaml.Node, displayPath string) []provider.Warning {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	var notices []provider.Warning
+	auditKeys(doc, workflowKeys, nil, func(path []string, ignored bool) {
+		notice := provider.Warning{Kind: provider.InfoKeyUnknown, Workflow: displayPath}
+		// Paths run jobs.<id>.steps.<step>.<key>; the notice is scoped to
+		// the innermost job or step it falls under.
+		key, prefix := path, ""
+		if len(path) > 2 && path[0] == "jobs" {
+			notice.Job = path[1]
+			key = path[2:]
+			if len(path) > 4 && path[2] == "steps" {
+				notice.Step = path[3]
+				key = path[4:]
+				prefix = fmt.Sprintf("step %q: ", notice.Step)
+			}
+		}
+		name := strings.Join(key, ".")
+		if ignored {
+			notice.Kind = provider.InfoKeyIgnored
+			notice.Message = fmt.Sprintf("%s%q is not relevant for local execution", prefix, name)
+		} else {
+			notice.Message = fmt.Sprintf("%sunknown key %q is ignored", prefix, name)
+		}
+		notices = append(notices, notice)
+	})
+	return notices
+}
+
+// auditKeys walks the mapping node against schema and calls report with
+// the path of every key it does not read. Items of a sequence are labelled
+// by their name key, or by position as the parser names unnamed steps.
+func auditKeys(node *yaml.Node, schema *keySchema, path []string, report func(path []string, ignored bool)) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			label := fmt.Sprintf("step %d", i+1)
+			if name := mappingValue(item, "name"); name != "" {
+				label = name
+			}
+			auditKeys(item, schema, appendPath(path, label), report)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			keyPath := appendPath(path, key)
+			if schema.each != nil {
+				auditKeys(value, schema.each, keyPath, report)
+				continue
+			}
+			next, read := schema.read[key]
+			switch {
+			case read && next != nil:
+				auditKeys(value, next, keyPath, report)
+			case read:
+			case schema.ignored[key]:
+				report(keyPath, true)
+			default:
+				report(keyPath, false)
+			}
+		}
+	}
+}
+
+// appendPath copies path so sibling keys never share a backing array.
+func appendPath(path []string, key string) []string {
+	return append(append(make([]string, 0, len(path)+1), path...), key)
+}
+
+// mappingValue returns the scalar value of key in a mapping node, or "".
+func mappingValue(node *yaml.Node, key string) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key && node.Content[i+1].Kind == yaml.ScalarNode {
+			return node.Content[i+1].Value
+		}
+	}
+	return ""
+}
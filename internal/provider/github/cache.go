@@ -0,0 +1,78 @@
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// Cache memoizes parsed workflows keyed by path, modification time, and size
+// so unchanged files are not re-read between runs in the same process. It is
+// safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	displayPath string
+	modTime     time.Time
+	size        int64
+	workflows   []provider.Workflow
+	warnings    []provider.Warning
+}
+
+// NewCache returns an empty parse cache.
+func NewCache() *Cache {
+	return &Cache{entries: make(map[string]cacheEntry)}
+}
+
+// Invalidate drops cached results for the given paths so the next Parse
+// re-reads them regardless of their file metadata. Watchers call this for
+// changed files because mtime resolution can hide rapid edits.
+func (c *Cache) Invalidate(paths ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range paths {
+		delete(c.entries, cacheKey(p))
+	}
+}
+
+// Len reports the number of cached workflow files.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *Cache) lookup(fullPath, displayPath string, info os.FileInfo) ([]provider.Workflow, []provider.Warning, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[cacheKey(fullPath)]
+	if !ok || entry.displayPath != displayPath || entry.size != info.Size() || !entry.modTime.Equal(info.ModTime()) {
+		return nil, nil, false
+	}
+	return entry.workflows, entry.warnings, true
+}
+
+func (c *Cache) store(fullPath, displayPath string, info os.FileInfo, wfs []provider.Workflow, warnings []provider.Warning) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[cacheKey(fullPath)] = cacheEntry{
+		displayPath: displayPath,
+		modTime:     info.ModTime(),
+		size:        info.Size(),
+		workflows:   wfs,
+		warnings:    warnings,
+	}
+}
+
+func cacheKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
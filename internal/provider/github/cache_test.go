@@ -0,0 +1,127 @@
+package github
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCachePicksUpEdits(t *testing.T) {
+	root := t.TempDir()
+	writeWorkflow(t, root, "ci.yml", "echo one")
+
+	parser := NewParser(root)
+	parser.Cache = NewCache()
+	if _, err := parser.Parse([]string{"ci.yml"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	writeWorkflow(t, root, "ci.yml", "echo two, now longer")
+	pipeline, err := parser.Parse([]string{"ci.yml"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := pipeline.Workflows[0].Jobs[0].Steps[0].Run; got != "echo two, now longer" {
+		t.Fatalf("expected edited command, got %q", got)
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	root := t.TempDir()
+	full := writeWorkflow(t, root, "ci.yml", "echo aaa")
+
+	parser := NewParser(root)
+	parser.Cache = NewCache()
+	if _, err := parser.Parse([]string{"ci.yml"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	// Same size and restored mtime: only explicit invalidation reveals the edit.
+	info, err := os.Stat(full)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	writeWorkflow(t, root, "ci.yml", "echo bbb")
+	if err := os.Chtimes(full, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	stale, err := parser.Parse([]string{"ci.yml"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := stale.Workflows[0].Jobs[0].Steps[0].Run; got != "echo aaa" {
+		t.Fatalf("expected cached command before invalidation, got %q", got)
+	}
+
+	parser.Cache.Invalidate(full)
+	fresh, err := parser.Parse([]string{"ci.yml"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := fresh.Workflows[0].Jobs[0].Steps[0].Run; got != "echo bbb" {
+		t.Fatalf("expected edit after invalidation, got %q", got)
+	}
+}
+
+func BenchmarkParse50Workflows(b *testing.B) {
+	root := b.TempDir()
+	paths := make([]string, 0, 50)
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("wf%02d.yml", i)
+		writeLargeWorkflow(b, root, name, 20, 15)
+		paths = append(paths, name)
+	}
+
+	b.Run("cold", func(b *testing.B) {
+		parser := NewParser(root)
+		for i := 0; i < b.N; i++ {
+			if _, err := parser.Parse(paths); err != nil {
+				b.Fatalf("Parse: %v", err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		parser := NewParser(root)
+		parser.Cache = NewCache()
+		if _, err := parser.Parse(paths); err != nil {
+			b.Fatalf("Parse: %v", err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := parser.Parse(paths); err != nil {
+				b.Fatalf("Parse: %v", err)
+			}
+		}
+	})
+}
+
+func writeWorkflow(t *testing.T, root, name, run string) string {
+	t.Helper()
+	full := filepath.Join(root, name)
+	doc := fmt.Sprintf("name: CI\njobs:\n  test:\n    steps:\n      - run: %s\n", run)
+	if err := os.WriteFile(full, []byte(doc), 0o644); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	// Nudge mtime forward so coarse filesystem timestamps still register the edit.
+	future := time.Now().Add(time.Duration(len(run)) * time.Second)
+	_ = os.Chtimes(full, future, future)
+	return full
+}
+
+func writeLargeWorkflow(tb testing.TB, root, name string, jobs, steps int) {
+	tb.Helper()
+	doc := "name: Generated\nenv:\n  SHARED: value\njobs:\n"
+	for j := 0; j < jobs; j++ {
+		doc += fmt.Sprintf("  job%02d:\n    env:\n      JOB: \"%d\"\n    steps:\n", j, j)
+		for s := 0; s < steps; s++ {
+			doc += fmt.Sprintf("      - name: Step %d\n        run: echo job %d step %d\n", s, j, s)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, name), []byte(doc), 0o644); err != nil {
+		tb.Fatalf("write workflow: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package github
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// runDirective matches a `# testdrive: skip[: reason]`,
+// `# testdrive: allow-privileged` or `# testdrive: xfail` comment on a line
+// of its own; nameDirective matches one at the end of a quoted step name.
+var (
+	runDirective  = regexp.MustCompile(`^#\s*testdrive:\s*(skip|allow-privileged|xfail)\b\s*(?::\s*(.*?))?\s*$`)
+	nameDirective = regexp.MustCompile(`\s*#\s*testdrive:\s*(skip|allow-privileged|xfail)\b\s*(?::\s*(.*?))?\s*$`)
+)
+
+// applyDirectives sets the step's LocalSkip, AllowPrivileged and
+// ExpectedFailure flags from testdrive comments in its run block, the YAML
+// comment after its name, and its name itself. A directive in the name is
+// removed from it, so output and filters see the plain name.
+func applyDirectives(step *provider.Step, nameComment string) {
+	for _, line := range append(strings.Split(step.Run, "\n"), nameComment) {
+		if m := runDirective.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			applyDirective(step, m[1], m[2])
+		}
+	}
+	if loc := nameDirective.FindStringSubmatchIndex(step.Name); loc != nil {
+		m := nameDirective.FindStringSubmatch(step.Name)
+		applyDirective(step, m[1], m[2])
+		step.Name = strings.TrimSpace(step.Name[:loc[0]])
+	}
+}
+
+func applyDirective(step *provider.Step, name, reason string) {
+	switch name {
+	case "skip":
+		step.LocalSkip = true
+		if step.LocalSkipReason == "" {
+			step.LocalSkipReason = reason
+		}
+	case "allow-privileged":
+		step.AllowPrivileged = true
+	case "xfail":
+		step.ExpectedFailure = true
+	}
+}
@@ -0,0 +1,80 @@
+package github
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDirectives(t *testing.T) {
+	yamlDoc := `name: CI
+jobs:
+  test:
+    steps:
+      - name: Deploy preview
+        run: |
+          ./bin/build
+          # testdrive: skip: needs AWS credentials
+          ./bin/deploy
+      - name: Install packages
+        run: |
+          #testdrive: allow-privileged
+          sudo apt-get install -y jq
+      - name: Bare skip
+        run: |
+          echo one
+            # testdrive: skip
+      - name: Seed data # testdrive: skip: slow
+        run: ./bin/seed
+      - name: "Lint # testdrive: allow-privileged"
+        run: make lint
+      - name: Quoted
+        run: |
+          echo "# testdrive: skip"
+          make test # testdrive: skip
+      - name: Unknown
+        run: |
+          # testdrive: skipped
+          # testdrive: allow-everything
+          make unknown
+      - run: "# testdrive: skip: no name"
+      - name: Flaky upload
+        run: |
+          # testdrive: xfail
+          ./bin/upload
+      - name: Integration # testdrive: xfail
+        run: make integration
+`
+	wf, _, err := decodeWorkflow(strings.NewReader(yamlDoc), "ci.yml", Limits{})
+	if err != nil {
+		t.Fatalf("decodeWorkflow error: %v", err)
+	}
+	type want struct {
+		name            string
+		skip            bool
+		reason          string
+		allowPrivileged bool
+		expectedFailure bool
+	}
+	wants := []want{
+		{name: "Deploy preview", skip: true, reason: "needs AWS credentials"},
+		{name: "Install packages", allowPrivileged: true},
+		{name: "Bare skip", skip: true},
+		{name: "Seed data", skip: true, reason: "slow"},
+		{name: "Lint", allowPrivileged: true},
+		{name: "Quoted"},
+		{name: "Unknown"},
+		{name: "step 8", skip: true, reason: "no name"},
+		{name: "Flaky upload", expectedFailure: true},
+		{name: "Integration", expectedFailure: true},
+	}
+	steps := wf.Jobs[0].Steps
+	if len(steps) != len(wants) {
+		t.Fatalf("got %d steps, want %d", len(steps), len(wants))
+	}
+	for i, w := range wants {
+		got := want{name: steps[i].Name, skip: steps[i].LocalSkip, reason: steps[i].LocalSkipReason, allowPrivileged: steps[i].AllowPrivileged, expectedFailure: steps[i].ExpectedFailure}
+		if got != w {
+			t.Errorf("step %d = %+v, want %+v", i+1, got, w)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package github
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// Limits bounds the workflows a Parser accepts. A zero field means no limit.
+type Limits struct {
+	// MaxBytes is the largest workflow file read.
+	MaxBytes int64
+	// MaxJobs caps the jobs in one workflow.
+	MaxJobs int
+	// MaxSteps caps the steps across all of a workflow's jobs.
+	MaxSteps int
+}
+
+// maxNodes caps the YAML nodes a workflow may expand to once aliases are
+// followed. Real workflows stay far below it; an alias bomb a few hundred
+// bytes long would otherwise expand to billions.
+const maxNodes = 1 << 20
+
+// readLimited reads all of r, failing once it passes max bytes rather than
+// buffering the rest.
+func readLimited(r io.Reader, max int64, displayPath string) ([]byte, error) {
+	if max > 0 {
+		r = io.LimitReader(r, max+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse workflow %q: %w", displayPath, err)
+	}
+	if max > 0 && int64(len(data)) > max {
+		return nil, fmt.Errorf("workflow %q is larger than the %s limit (limits.workflow_bytes)", displayPath, formatBytes(max))
+	}
+	return data, nil
+}
+
+// checkExpansion fails when doc would expand past maxNodes. Sizes are
+// memoized per node, so the walk stays linear in the document however often
+// an anchor is reused.
+func checkExpansion(doc *yaml.Node, displayPath string) error {
+	sizes := make(map[*yaml.Node]int)
+	var size func(n *yaml.Node) int
+	size = func(n *yaml.Node) int {
+		if s, ok := sizes[n]; ok {
+			return s
+		}
+		// An anchor reached again while it is being sized refers to itself.
+		sizes[n] = maxNodes + 1
+		total := 1
+		if n.Kind == yaml.AliasNode && n.Alias != nil {
+			total += size(n.Alias)
+		}
+		for _, child := range n.Content {
+			if total > maxNodes {
+				break
+			}
+			total += size(child)
+		}
+		sizes[n] = total
+		return total
+	}
+	if size(doc) > maxNodes {
+		return fmt.Errorf("workflow %q expands to more than %d YAML nodes through aliases", displayPath, maxNodes)
+	}
+	return nil
+}
+
+// check fails when wf has more jobs or steps than the limits allow.
+func (l Limits) check(wf provider.Workflow) error {
+	if l.MaxJobs > 0 && len(wf.Jobs) > l.MaxJobs {
+		return fmt.Errorf("workflow %q has %d jobs, more than the limit of %d (limits.jobs)", wf.Path, len(wf.Jobs), l.MaxJobs)
+	}
+	if l.MaxSteps <= 0 {
+		return nil
+	}
+	steps := 0
+	for _, job := range wf.Jobs {
+		steps += len(job.Steps)
+	}
+	if steps > l.MaxSteps {
+		return fmt.Errorf("workflow %q has %d steps, more than the limit of %d (limits.steps)", wf.Path, steps, l.MaxSteps)
+	}
+	return nil
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MiB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KiB", n>>10)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
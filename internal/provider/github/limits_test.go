@@ -0,0 +1,114 @@
+package github
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParserRejectsOversizedWorkflow(t *testing.T) {
+	root := t.TempDir()
+	writeLargeWorkflow(t, root, "big.yml", 20, 100)
+
+	parser := NewParser(root)
+	parser.Limits = Limits{MaxBytes: 64 << 10}
+	_, err := parser.Parse([]string{"big.yml"})
+	if err == nil || !strings.Contains(err.Error(), `workflow "big.yml" is larger than the 64 KiB limit (limits.workflow_bytes)`) {
+		t.Fatalf("expected a size limit error, got %v", err)
+	}
+
+	parser.Limits = Limits{}
+	if _, err := parser.Parse([]string{"big.yml"}); err != nil {
+		t.Fatalf("unlimited parse: %v", err)
+	}
+}
+
+func TestParserRejectsTooManyJobsOrSteps(t *testing.T) {
+	root := t.TempDir()
+	writeLargeWorkflow(t, root, "generated.yml", 40, 50)
+
+	cases := []struct {
+		limits Limits
+		want   string
+	}{
+		{Limits{MaxJobs: 39}, `workflow "generated.yml" has 40 jobs, more than the limit of 39 (limits.jobs)`},
+		{Limits{MaxSteps: 1999}, `workflow "generated.yml" has 2000 steps, more than the limit of 1999 (limits.steps)`},
+		{Limits{MaxJobs: 40, MaxSteps: 2000}, ""},
+	}
+	for _, tc := range cases {
+		parser := NewParser(root)
+		parser.Limits = tc.limits
+		_, err := parser.Parse([]string{"generated.yml"})
+		if tc.want == "" {
+			if err != nil {
+				t.Fatalf("limits %+v: unexpected error %v", tc.limits, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tc.want {
+			t.Fatalf("limits %+v: got %v, want %q", tc.limits, err, tc.want)
+		}
+	}
+}
+
+func TestParserChecksLimitsOnCacheHits(t *testing.T) {
+	root := t.TempDir()
+	writeLargeWorkflow(t, root, "generated.yml", 2, 10)
+
+	parser := NewParser(root)
+	parser.Cache = NewCache()
+	if _, err := parser.Parse([]string{"generated.yml"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	parser.Limits = Limits{MaxSteps: 5}
+	if _, err := parser.Parse([]string{"generated.yml"}); err == nil || !strings.Contains(err.Error(), "(limits.steps)") {
+		t.Fatalf("expected the cached workflow to hit the step limit, got %v", err)
+	}
+}
+
+func TestParserRejectsAliasBomb(t *testing.T) {
+	root := projectRoot(t)
+	_, err := NewParser(root).Parse([]string{filepath.Join("testdata", "workflows", "alias_bomb.yml")})
+	if err == nil || !strings.Contains(err.Error(), "expands to more than 1048576 YAML nodes through aliases") {
+		t.Fatalf("expected an alias expansion error, got %v", err)
+	}
+}
+
+func TestDecodeWorkflowAllowsReusedAnchors(t *testing.T) {
+	yamlDoc := `
+jobs:
+  a:
+    env: &env
+      RAILS_ENV: test
+    steps: &steps
+      - run: bin/setup
+      - run: bin/rails test
+  b:
+    env: *env
+    steps: *steps
+`
+	wf, _, err := decodeWorkflow(strings.NewReader(yamlDoc), "anchors.yml", Limits{MaxSteps: 4})
+	if err != nil {
+		t.Fatalf("decodeWorkflow error: %v", err)
+	}
+	if len(wf.Jobs) != 2 || len(wf.Jobs[1].Steps) != 2 || wf.Jobs[1].Env["RAILS_ENV"] != "test" {
+		t.Fatalf("anchors not expanded: %+v", wf.Jobs)
+	}
+	if _, _, err := decodeWorkflow(strings.NewReader(yamlDoc), "anchors.yml", Limits{MaxSteps: 3}); err == nil {
+		t.Fatalf("expected aliased steps to count towards the step limit")
+	}
+}
+
+func BenchmarkParseHugeWorkflow(b *testing.B) {
+	root := b.TempDir()
+	// About 1 MB: 200 jobs of 100 steps each.
+	writeLargeWorkflow(b, root, "huge.yml", 200, 100)
+	parser := NewParser(root)
+	parser.Limits = Limits{MaxBytes: 4 << 20, MaxJobs: 1000, MaxSteps: 100000}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.Parse([]string{"huge.yml"}); err != nil {
+			b.Fatalf("Parse: %v", err)
+		}
+	}
+}
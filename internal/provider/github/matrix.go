@@ -0,0 +1,275 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// matrixRef matches a `${{ matrix.KEY }}` expression that is nothing but a
+// reference to one matrix value.
+var matrixRef = regexp.MustCompile(`\$\{\{\s*matrix\.([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+
+// combination is one set of matrix values, keyed in the order the matrix
+// wrote them, with keys added by include after.
+type combination struct {
+	keys   []string
+	values map[string]string
+}
+
+func (c *combination) set(key, value string) {
+	if c.values == nil {
+		c.values = make(map[string]string)
+	}
+	if _, ok := c.values[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.values[key] = value
+}
+
+func (c combination) clone() combination {
+	out := combination{keys: append([]string{}, c.keys...), values: make(map[string]string, len(c.values))}
+	for k, v := range c.values {
+		out.values[k] = v
+	}
+	return out
+}
+
+// String joins the values as GitHub does after a job's name, e.g.
+// "ubuntu-latest, 1.22".
+func (c combination) String() string {
+	values := make([]string, len(c.keys))
+	for i, k := range c.keys {
+		values[i] = c.values[k]
+	}
+	return strings.Join(values, ", ")
+}
+
+// matches reports whether c holds every key and value of entry.
+func (c combination) matches(entry combination) bool {
+	for _, k := range entry.keys {
+		if v, ok := c.values[k]; !ok || v != entry.values[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// apply replaces the references to c's values in s. As on GitHub, a key c
+// does not have is empty.
+func (c combination) apply(s string) string {
+	return matrixRef.ReplaceAllStringFunc(s, func(ref string) string {
+		return c.values[matrixRef.FindStringSubmatch(ref)[1]]
+	})
+}
+
+func (c combination) applyEnv(env map[string]string) map[string]string {
+	if len(env) == 0 {
+		return env
+	}
+	out := make(map[string]string, len(env))
+	for k, v := range env {
+		out[k] = c.apply(v)
+	}
+	return out
+}
+
+// expandMatrix returns the combinations a strategy.matrix block runs, in
+// the order GitHub starts them: the product of its vectors with the first
+// key varying slowest, less the exclude entries, then the include entries.
+// An include entry extends every original combination it does not
+// contradict and is a combination of its own when there is none. Matrices
+// built by an expression, or holding values other than scalars, cannot be
+// expanded locally and return an error saying why.
+func expandMatrix(node *yaml.Node) ([]combination, error) {
+	node = unalias(node)
+	if node.Kind != yaml.MappingNode {
+		return nil, errors.New("it is computed by an expression")
+	}
+	var vectorKeys []string
+	vectors := make(map[string][]string)
+	var include, exclude []combination
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, unalias(node.Content[i+1])
+		switch key {
+		case "include", "exclude":
+			entries, err := matrixEntries(key, value)
+			if err != nil {
+				return nil, err
+			}
+			if key == "include" {
+				include = entries
+			} else {
+				exclude = entries
+			}
+		default:
+			if value.Kind != yaml.SequenceNode {
+				return nil, fmt.Errorf("%s is computed by an expression", key)
+			}
+			for _, item := range value.Content {
+				item = unalias(item)
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("%s holds values that are not scalars", key)
+				}
+				vectors[key] = append(vectors[key], item.Value)
+			}
+			vectorKeys = append(vectorKeys, key)
+		}
+	}
+
+	var combos []combination
+	if len(vectorKeys) > 0 {
+		combos = []combination{{}}
+		for _, key := range vectorKeys {
+			next := make([]combination, 0, len(combos)*len(vectors[key]))
+			for _, c := range combos {
+				for _, v := range vectors[key] {
+					extended := c.clone()
+					extended.set(key, v)
+					next = append(next, extended)
+				}
+			}
+			combos = next
+		}
+	}
+
+	kept := combos[:0]
+	for _, c := range combos {
+		excluded := false
+		for _, entry := range exclude {
+			if c.matches(entry) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, c)
+		}
+	}
+	combos = kept
+
+	original := len(combos)
+	for _, entry := range include {
+		added := false
+		for i := 0; i < original; i++ {
+			if !compatible(combos[i], entry, vectors) {
+				continue
+			}
+			for _, k := range entry.keys {
+				combos[i].set(k, entry.values[k])
+			}
+			added = true
+		}
+		if !added {
+			combos = append(combos, entry.clone())
+		}
+	}
+	if len(combos) == 0 {
+		return nil, errors.New("it has no combinations")
+	}
+	return combos, nil
+}
+
+// compatible reports whether entry can extend c without changing any of
+// the values c took from the matrix vectors.
+func compatible(c, entry combination, vectors map[string][]string) bool {
+	for _, k := range entry.keys {
+		if _, original := vectors[k]; original && c.values[k] != entry.values[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// matrixEntries reads the include or exclude list of a matrix.
+func matrixEntries(key string, node *yaml.Node) ([]combination, error) {
+	if node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("%s is computed by an expression", key)
+	}
+	entries := make([]combination, 0, len(node.Content))
+	for _, item := range node.Content {
+		item = unalias(item)
+		if item.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s holds entries that are not mappings", key)
+		}
+		var entry combination
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			value := unalias(item.Content[i+1])
+			if value.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("%s holds values that are not scalars", key)
+			}
+			entry.set(item.Content[i].Value, value.Value)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func unalias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// matrixVariants returns the copies of job that run combos. A name that
+// interpolates only some of the matrix keys can come out the same for
+// several combinations; those get their values appended, so each variant
+// is reported on its own.
+func matrixVariants(job provider.Job, combos []combination, displayPath string) []provider.Job {
+	variants := make([]provider.Job, len(combos))
+	names := make(map[string]int, len(combos))
+	for i, c := range combos {
+		variants[i] = matrixVariant(job, c, displayPath)
+		names[variants[i].Name]++
+	}
+	for i := range variants {
+		if names[variants[i].Name] > 1 {
+			variants[i].Name = fmt.Sprintf("%s (%s)", variants[i].Name, variants[i].Variant)
+		}
+	}
+	return variants
+}
+
+// matrixVariant returns the copy of job that runs combination c: named
+// after its values, as on GitHub, unless its name interpolates them, and
+// with `${{ matrix.KEY }}` resolved wherever a step can see it. Step IDs
+// are recomputed for the variant from the scripts as written.
+func matrixVariant(job provider.Job, c combination, displayPath string) provider.Job {
+	job.Variant = c.String()
+	if matrixRef.MatchString(job.Name) {
+		job.Name = c.apply(job.Name)
+	} else {
+		job.Name = fmt.Sprintf("%s (%s)", job.Name, job.Variant)
+	}
+	job.Env = c.applyEnv(job.Env)
+	job.Defaults.RunShell = c.apply(job.Defaults.RunShell)
+	job.Defaults.WorkingDirectory = c.apply(job.Defaults.WorkingDirectory)
+	job.Environment = c.apply(job.Environment)
+	if job.Concurrency != nil {
+		concurrency := *job.Concurrency
+		concurrency.Group = c.apply(concurrency.Group)
+		job.Concurrency = &concurrency
+	}
+	if job.Container != nil {
+		container := *job.Container
+		container.Image = c.apply(container.Image)
+		container.Env = c.applyEnv(container.Env)
+		job.Container = &container
+	}
+	steps := make([]provider.Step, len(job.Steps))
+	for i, step := range job.Steps {
+		step.ID = provider.StepID(displayPath, job, i, step.Run)
+		step.Run = c.apply(step.Run)
+		step.Shell = c.apply(step.Shell)
+		step.WorkingDirectory = c.apply(step.WorkingDirectory)
+		step.Env = c.applyEnv(step.Env)
+		steps[i] = step
+	}
+	job.Steps = steps
+	return job
+}
@@ -1,11 +1,16 @@
 package github
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 
     "github.com/bgricker/testdrive/internal/provider"
 	"gopkg.in/yaml.v3"
@@ -16,6 +21,10 @@ const ProviderName = "github"
 // Parser loads GitHub Actions workflow files from disk.
 type Parser struct {
 	Root string
+	// Cache, when set, reuses results for files whose mtime and size are unchanged.
+	Cache *Cache
+	// Limits bounds the size of the workflows accepted.
+	Limits Limits
 }
 
 // NewParser constructs a Parser that resolves workflow paths relative to root.
@@ -31,31 +40,127 @@ func (p *Parser) Parse(paths []string) (provider.Pipeline, error) {
 		if !filepath.IsAbs(full) {
 			full = filepath.Join(p.Root, relPath)
 		}
-		wf, warnings, err := parseWorkflow(full, relPath)
+		wfs, warnings, err := p.parseCached(full, relPath)
 		if err != nil {
 			return provider.Pipeline{}, err
 		}
-		pipeline.Workflows = append(pipeline.Workflows, wf)
+		pipeline.Workflows = append(pipeline.Workflows, wfs...)
 		pipeline.Warnings = append(pipeline.Warnings, warnings...)
 	}
 	return pipeline, nil
 }
 
-func parseWorkflow(fullPath, displayPath string) (provider.Workflow, []provider.Warning, error) {
+// ParseContent decodes workflow content that does not come from a file under
+// Root, such as a workflow at another git ref. displayPath becomes the
+// workflows' Path, numbered as in Parse when the content holds several YAML
+// documents. Content is never cached.
+func (p *Parser) ParseContent(displayPath string, content []byte) ([]provider.Workflow, []provider.Warning, error) {
+	return decodeWorkflows(bytes.NewReader(content), displayPath, p.Limits)
+}
+
+func (p *Parser) parseCached(fullPath, displayPath string) ([]provider.Workflow, []provider.Warning, error) {
+	if p.Cache == nil {
+		return parseWorkflows(fullPath, displayPath, p.Limits)
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return parseWorkflows(fullPath, displayPath, p.Limits)
+	}
+	if wfs, warnings, ok := p.Cache.lookup(fullPath, displayPath, info); ok {
+		// The entry may have been stored under looser limits.
+		for _, wf := range wfs {
+			if err := p.Limits.check(wf); err != nil {
+				return nil, nil, err
+			}
+		}
+		return wfs, warnings, nil
+	}
+	wfs, warnings, err := parseWorkflows(fullPath, displayPath, p.Limits)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.Cache.store(fullPath, displayPath, info, wfs, warnings)
+	return wfs, warnings, nil
+}
+
+func parseWorkflows(fullPath, displayPath string, limits Limits) ([]provider.Workflow, []provider.Warning, error) {
 	f, err := os.Open(fullPath)
 	if err != nil {
-		return provider.Workflow{}, nil, fmt.Errorf("open workflow %q: %w", displayPath, err)
+		return nil, nil, fmt.Errorf("open workflow %q: %w", displayPath, err)
 	}
 	defer f.Close()
-	return decodeWorkflow(f, displayPath)
+	return decodeWorkflows(f, displayPath, limits)
+}
+
+// decodeWorkflows decodes every YAML document in r as a workflow. A file with
+// one document keeps displayPath as its Path; with several, each workflow's
+// Path is displayPath#N, N counting documents from 1 in file order. Empty
+// documents, such as one after a trailing "---", are skipped.
+func decodeWorkflows(r io.Reader, displayPath string, limits Limits) ([]provider.Workflow, []provider.Warning, error) {
+	data, err := readLimited(r, limits.MaxBytes, displayPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The nodes are kept so keys the documents do not read can be reported.
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var docs []*yaml.Node
+	for {
+		doc := new(yaml.Node)
+		err := dec.Decode(doc)
+		if errors.Is(err, io.EOF) && len(docs) > 0 {
+			break
+		}
+		if err != nil {
+			return nil, nil, parseError(displayPath, err)
+		}
+		docs = append(docs, doc)
+	}
+	var kept []int
+	for i, doc := range docs {
+		if !emptyDocument(doc) {
+			kept = append(kept, i)
+		}
+	}
+	if len(kept) == 0 {
+		// A file of only comments is still one workflow, with no jobs.
+		kept = []int{0}
+	}
+
+	var workflows []provider.Workflow
+	warnings := make([]provider.Warning, 0)
+	for _, i := range kept {
+		path := displayPath
+		if len(kept) > 1 {
+			path = fmt.Sprintf("%s#%d", displayPath, i+1)
+		}
+		wf, docWarnings, err := convertWorkflow(docs[i], path, limits)
+		if err != nil {
+			return nil, nil, err
+		}
+		workflows = append(workflows, wf)
+		warnings = append(warnings, docWarnings...)
+	}
+	return workflows, warnings, nil
 }
 
-func decodeWorkflow(r io.Reader, displayPath string) (provider.Workflow, []provider.Warning, error) {
-	decoder := yaml.NewDecoder(r)
+// emptyDocument reports whether doc holds nothing but comments or null.
+func emptyDocument(doc *yaml.Node) bool {
+	if len(doc.Content) == 0 {
+		return true
+	}
+	node := doc.Content[0]
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
 
+// convertWorkflow builds the workflow in one decoded YAML document.
+func convertWorkflow(doc *yaml.Node, displayPath string, limits Limits) (provider.Workflow, []provider.Warning, error) {
+	if err := checkExpansion(doc, displayPath); err != nil {
+		return provider.Workflow{}, nil, err
+	}
 	var wfDoc workflowDocument
-	if err := decoder.Decode(&wfDoc); err != nil {
-		return provider.Workflow{}, nil, fmt.Errorf("parse workflow %q: %w", displayPath, err)
+	if err := doc.Decode(&wfDoc); err != nil {
+		return provider.Workflow{}, nil, parseError(displayPath, err)
 	}
 
 	wf := provider.Workflow{
@@ -66,6 +171,7 @@ func decodeWorkflow(r io.Reader, displayPath string) (provider.Workflow, []provi
 			RunShell:         wfDoc.Defaults.Run.Shell,
 			WorkingDirectory: wfDoc.Defaults.Run.WorkingDirectory,
 		},
+		Concurrency: wfDoc.Concurrency.convert(),
 	}
 
 	if wf.Name == "" {
@@ -91,6 +197,12 @@ func decodeWorkflow(r io.Reader, displayPath string) (provider.Workflow, []provi
 				RunShell:         jobDoc.Defaults.Run.Shell,
 				WorkingDirectory: jobDoc.Defaults.Run.WorkingDirectory,
 			},
+			Concurrency: jobDoc.Concurrency.convert(),
+			Environment: jobDoc.Environment.Name,
+			Strategy:    jobDoc.Strategy.convert(),
+			Needs:       []string(jobDoc.Needs),
+
+			TimeoutMinutes: float64(jobDoc.TimeoutMinutes),
 		}
 		if job.Name == "" {
 			job.Name = jobID
@@ -98,20 +210,20 @@ func decodeWorkflow(r io.Reader, displayPath string) (provider.Workflow, []provi
 
 		if jobDoc.Services != nil {
 			warnings = append(warnings, provider.Warning{
+				Kind:     provider.WarnServicesUnsupported,
 				Workflow: displayPath,
 				Job:      jobID,
 				Message:  "services are not supported",
 			})
 		}
-		if jobDoc.Strategy.Matrix != nil {
-			warnings = append(warnings, provider.Warning{
-				Workflow: displayPath,
-				Job:      jobID,
-				Message:  "strategy.matrix is not supported",
-			})
+		if jobDoc.Container != nil {
+			job.Container = jobDoc.Container.convert()
+			job.Env = containerEnv(job.Container.Env, job.Env)
+			warnings = append(warnings, containerWarnings(displayPath, jobID, job.Container)...)
 		}
 		if jobDoc.If != "" {
 			warnings = append(warnings, provider.Warning{
+				Kind:     provider.WarnJobIfIgnored,
 				Workflow: displayPath,
 				Job:      jobID,
 				Message:  "job-level if condition is ignored",
@@ -121,6 +233,7 @@ func decodeWorkflow(r io.Reader, displayPath string) (provider.Workflow, []provi
 		job.Steps = make([]provider.Step, 0, len(jobDoc.Steps))
 		for idx, stepDoc := range jobDoc.Steps {
 			step := provider.Step{
+				ID:               provider.StepID(displayPath, job, idx, stepDoc.Run),
 				Name:             stepDoc.Name,
 				Run:              stepDoc.Run,
 				Uses:             stepDoc.Uses,
@@ -128,21 +241,41 @@ func decodeWorkflow(r io.Reader, displayPath string) (provider.Workflow, []provi
 				Shell:            stepDoc.Shell,
 				WorkingDirectory: stepDoc.WorkingDirectory,
 			}
+			applyDirectives(&step, stepDoc.NameComment)
 			if step.Name == "" {
 				step.Name = fmt.Sprintf("step %d", idx+1)
 			}
 			if stepDoc.If != "" {
 				warnings = append(warnings, provider.Warning{
+					Kind:     provider.WarnStepIfUnsupported,
 					Workflow: displayPath,
 					Job:      jobID,
+					Step:     step.Name,
 					Message:  fmt.Sprintf("step %q has unsupported if condition", step.Name),
 				})
 			}
 			job.Steps = append(job.Steps, step)
 		}
 
+		if jobDoc.Strategy != nil && jobDoc.Strategy.Matrix.Kind != 0 {
+			combos, err := expandMatrix(&jobDoc.Strategy.Matrix)
+			if err == nil {
+				wf.Jobs = append(wf.Jobs, matrixVariants(job, combos, displayPath)...)
+				continue
+			}
+			warnings = append(warnings, provider.Warning{
+				Kind:     provider.WarnMatrixUnsupported,
+				Workflow: displayPath,
+				Job:      jobID,
+				Message:  fmt.Sprintf("strategy.matrix is not supported: %v; the job runs once", err),
+			})
+		}
 		wf.Jobs = append(wf.Jobs, job)
 	}
+	if err := limits.check(wf); err != nil {
+		return provider.Workflow{}, nil, err
+	}
+	warnings = append(warnings, keyNotices(doc, displayPath)...)
 
 	return wf, warnings, nil
 }
@@ -152,6 +285,39 @@ type workflowDocument struct {
 	Env      map[string]interface{} `yaml:"env"`
 	Defaults defaultsDocument       `yaml:"defaults"`
 	Jobs     map[string]jobDocument `yaml:"jobs"`
+
+	Concurrency *concurrencyDocument `yaml:"concurrency"`
+}
+
+// concurrencyDocument accepts both `concurrency: <group>` and the mapping
+// form with group and cancel-in-progress.
+type concurrencyDocument struct {
+	Group            string
+	CancelInProgress string
+}
+
+func (c *concurrencyDocument) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		c.Group = node.Value
+		return nil
+	}
+	var doc struct {
+		Group            string `yaml:"group"`
+		CancelInProgress string `yaml:"cancel-in-progress"`
+	}
+	if err := node.Decode(&doc); err != nil {
+		return err
+	}
+	c.Group, c.CancelInProgress = doc.Group, doc.CancelInProgress
+	return nil
+}
+
+func (c *concurrencyDocument) convert() *provider.Concurrency {
+	if c == nil || c.Group == "" {
+		return nil
+	}
+	cancel := c.CancelInProgress == "true" || strings.Contains(c.CancelInProgress, "${{")
+	return &provider.Concurrency{Group: c.Group, CancelInProgress: cancel}
 }
 
 type defaultsDocument struct {
@@ -164,17 +330,175 @@ type runDefaults struct {
 }
 
 type jobDocument struct {
-	Name     string                 `yaml:"name"`
-	Env      map[string]interface{} `yaml:"env"`
-	Defaults defaultsDocument       `yaml:"defaults"`
-	Steps    []stepDocument         `yaml:"steps"`
-	Services interface{}            `yaml:"services"`
-	Strategy strategyDocument       `yaml:"strategy"`
-	If       string                 `yaml:"if"`
+	Name      string                 `yaml:"name"`
+	Env       map[string]interface{} `yaml:"env"`
+	Defaults  defaultsDocument       `yaml:"defaults"`
+	Steps     []stepDocument         `yaml:"steps"`
+	Services  interface{}            `yaml:"services"`
+	Container *containerDocument     `yaml:"container"`
+	Strategy  *strategyDocument      `yaml:"strategy"`
+	If        string                 `yaml:"if"`
+	Needs     needsDocument          `yaml:"needs"`
+
+	Concurrency    *concurrencyDocument `yaml:"concurrency"`
+	Environment    environmentDocument  `yaml:"environment"`
+	TimeoutMinutes timeoutDocument      `yaml:"timeout-minutes"`
+}
+
+// environmentDocument accepts both `environment: <name>` and the mapping
+// form with name and url.
+type environmentDocument struct {
+	Name string
+}
+
+func (e *environmentDocument) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		e.Name = node.Value
+		return nil
+	}
+	var doc struct {
+		Name string `yaml:"name"`
+	}
+	if err := node.Decode(&doc); err != nil {
+		return err
+	}
+	e.Name = doc.Name
+	return nil
+}
+
+// needsDocument accepts both `needs: <job>` and a list of job IDs.
+type needsDocument []string
+
+func (n *needsDocument) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*n = needsDocument{node.Value}
+		return nil
+	}
+	var ids []string
+	if err := node.Decode(&ids); err != nil {
+		return err
+	}
+	*n = needsDocument(ids)
+	return nil
+}
+
+// timeoutDocument reads timeout-minutes. An expression, which cannot be
+// evaluated locally, leaves it zero.
+type timeoutDocument float64
+
+func (t *timeoutDocument) UnmarshalYAML(node *yaml.Node) error {
+	minutes, err := strconv.ParseFloat(strings.TrimSpace(node.Value), 64)
+	if node.Kind != yaml.ScalarNode || err != nil || minutes < 0 {
+		*t = 0
+		return nil
+	}
+	*t = timeoutDocument(minutes)
+	return nil
+}
+
+// containerDocument accepts both `container: <image>` and the mapping form.
+type containerDocument struct {
+	Image   string                 `yaml:"image"`
+	Env     map[string]interface{} `yaml:"env"`
+	Options string                 `yaml:"options"`
+	Volumes []string               `yaml:"volumes"`
+}
+
+func (c *containerDocument) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		c.Image = node.Value
+		return nil
+	}
+	type plain containerDocument
+	var doc plain
+	if err := node.Decode(&doc); err != nil {
+		return err
+	}
+	*c = containerDocument(doc)
+	return nil
+}
+
+func (c *containerDocument) convert() *provider.Container {
+	return &provider.Container{
+		Image:   c.Image,
+		Env:     convertEnv(c.Env),
+		Options: c.Options,
+		Volumes: c.Volumes,
+	}
+}
+
+// containerEnv merges a container's env under the job's own, which wins on
+// conflicts as it does for steps inside the container on CI.
+func containerEnv(container, job map[string]string) map[string]string {
+	if len(container) == 0 {
+		return job
+	}
+	out := make(map[string]string, len(container)+len(job))
+	for k, v := range container {
+		out[k] = v
+	}
+	for k, v := range job {
+		out[k] = v
+	}
+	return out
+}
+
+// containerWarnings names the image the job expects and each part of the
+// container block that has no effect on the host.
+func containerWarnings(displayPath, jobID string, c *provider.Container) []provider.Warning {
+	image := c.Image
+	if image == "" {
+		image = "(no image)"
+	}
+	messages := []string{fmt.Sprintf("container %s is not supported; steps run on the host", image)}
+	if len(c.Env) > 0 {
+		messages = append(messages, fmt.Sprintf("container env (%s) is applied to the job env", strings.Join(sortedKeys(c.Env), ", ")))
+	}
+	if c.Options != "" {
+		messages = append(messages, fmt.Sprintf("container options %q are ignored", c.Options))
+	}
+	if len(c.Volumes) > 0 {
+		messages = append(messages, fmt.Sprintf("container volumes are ignored: %s", strings.Join(c.Volumes, ", ")))
+	}
+	warnings := make([]provider.Warning, 0, len(messages))
+	for _, msg := range messages {
+		warnings = append(warnings, provider.Warning{
+			Kind:     provider.WarnContainerUnsupported,
+			Workflow: displayPath,
+			Job:      jobID,
+			Message:  msg,
+		})
+	}
+	return warnings
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 type strategyDocument struct {
-	Matrix interface{} `yaml:"matrix"`
+	Matrix      yaml.Node `yaml:"matrix"`
+	MaxParallel string    `yaml:"max-parallel"`
+	FailFast    string    `yaml:"fail-fast"`
+}
+
+// convert keeps max-parallel only when it is a literal number; expressions
+// cannot be evaluated locally, so they leave variants uncapped. fail-fast
+// defaults to true and only a literal false turns it off.
+func (s *strategyDocument) convert() *provider.Strategy {
+	if s == nil {
+		return nil
+	}
+	strategy := &provider.Strategy{FailFast: strings.TrimSpace(s.FailFast) != "false"}
+	if n, err := strconv.Atoi(strings.TrimSpace(s.MaxParallel)); err == nil && n > 0 {
+		strategy.MaxParallel = n
+	}
+	return strategy
 }
 
 type stepDocument struct {
@@ -185,6 +509,27 @@ type stepDocument struct {
 	Shell            string                 `yaml:"shell"`
 	WorkingDirectory string                 `yaml:"working-directory"`
 	If               string                 `yaml:"if"`
+	// NameComment is the comment trailing the name, where a testdrive
+	// directive may sit.
+	NameComment string `yaml:"-"`
+}
+
+// UnmarshalYAML decodes the step and keeps the comment after its name.
+func (s *stepDocument) UnmarshalYAML(node *yaml.Node) error {
+	type plain stepDocument
+	if err := node.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "name" {
+			continue
+		}
+		s.NameComment = node.Content[i+1].LineComment
+		if s.NameComment == "" {
+			s.NameComment = node.Content[i].LineComment
+		}
+	}
+	return nil
 }
 
 func convertEnv(input map[string]interface{}) map[string]string {
@@ -202,3 +547,27 @@ func convertEnv(input map[string]interface{}) map[string]string {
 	}
 	return out
 }
+
+// yamlLine matches the line yaml.v3 puts at the front of its messages.
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// parseError turns a yaml.v3 error into a *provider.ParseError, taking the
+// line out of the message. Of several type errors the first is located and
+// the rest are counted.
+func parseError(displayPath string, err error) error {
+	msg := err.Error()
+	var typeErr *yaml.TypeError
+	extra := 0
+	if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+		msg, extra = typeErr.Errors[0], len(typeErr.Errors)-1
+	}
+	parsed := &provider.ParseError{Path: displayPath, Message: strings.TrimPrefix(msg, "yaml: ")}
+	if m := yamlLine.FindStringSubmatch(msg); m != nil {
+		parsed.Line, _ = strconv.Atoi(m[1])
+		parsed.Message = m[2]
+	}
+	if extra > 0 {
+		parsed.Message += fmt.Sprintf(" (and %d more)", extra)
+	}
+	return parsed
+}
@@ -2,10 +2,16 @@ package github
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/resolve"
 )
 
 func TestParserParseBasic(t *testing.T) {
@@ -110,6 +116,52 @@ func TestParserWarnings(t *testing.T) {
 	mustContain(t, messages, "strategy.matrix is not supported")
 	mustContain(t, messages, "job-level if condition is ignored")
 	mustContain(t, messages, "unsupported if condition")
+
+	kinds := make(map[provider.WarningKind]bool)
+	for _, w := range pipeline.Warnings {
+		kinds[w.Kind] = true
+	}
+	for _, kind := range []provider.WarningKind{provider.WarnServicesUnsupported, provider.WarnMatrixUnsupported, provider.WarnJobIfIgnored, provider.WarnStepIfUnsupported} {
+		if !kinds[kind] {
+			t.Fatalf("expected a %s warning, got %+v", kind, pipeline.Warnings)
+		}
+	}
+}
+
+func TestParserReportsUnreadKeys(t *testing.T) {
+	root := projectRoot(t)
+	pipeline, err := NewParser(root).Parse([]string{"testdata/workflows/ci_exotic_keys.yml"})
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	warnings, infos := provider.SplitInfos(pipeline.Warnings)
+	for _, w := range warnings {
+		if w.Kind != provider.WarnContainerUnsupported {
+			t.Fatalf("expected only container warnings, got %+v", w)
+		}
+	}
+
+	var got []string
+	for _, info := range infos {
+		got = append(got, fmt.Sprintf("%s|%s|%s|%s", info.Kind, info.Job, info.Step, info.Message))
+	}
+	want := []string{
+		`key_ignored|||"run-name" is not relevant for local execution`,
+		`key_ignored|||"on" is not relevant for local execution`,
+		`key_ignored|||"permissions" is not relevant for local execution`,
+		`key_ignored|release||"runs-on" is not relevant for local execution`,
+		`key_ignored|release||"environment.url" is not relevant for local execution`,
+		`key_ignored|release||"container.credentials" is not relevant for local execution`,
+		`key_ignored|release||"outputs" is not relevant for local execution`,
+		`key_unknown|release||unknown key "retry" is ignored`,
+		`key_ignored|release|Tag|step "Tag": "id" is not relevant for local execution`,
+		`key_ignored|release|Tag|step "Tag": "continue-on-error" is not relevant for local execution`,
+		`key_ignored|release|step 2|step "step 2": "with" is not relevant for local execution`,
+		`key_unknown|release|Publish|step "Publish": unknown key "retries" is ignored`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected notices:\n%s", strings.Join(got, "\n"))
+	}
 }
 
 func TestParserMissingFile(t *testing.T) {
@@ -130,7 +182,7 @@ jobs:
       - name: Explicit
         run: echo two
 `
-	wf, warnings, err := decodeWorkflow(strings.NewReader(yamlDoc), "temp.yml")
+	wf, warnings, err := decodeWorkflow(strings.NewReader(yamlDoc), "temp.yml", Limits{})
 	if err != nil {
 		t.Fatalf("decodeWorkflow error: %v", err)
 	}
@@ -156,7 +208,7 @@ func TestParseInvalidYAML(t *testing.T) {
 		t.Fatalf("write file: %v", err)
 	}
 
-	if _, _, err := decodeWorkflow(strings.NewReader("::bad yaml"), "broken.yml"); err == nil {
+	if _, _, err := decodeWorkflow(strings.NewReader("::bad yaml"), "broken.yml", Limits{}); err == nil {
 		t.Fatalf("expected parse error for invalid yaml")
 	}
 
@@ -166,6 +218,28 @@ func TestParseInvalidYAML(t *testing.T) {
 	}
 }
 
+func TestParseErrorLocation(t *testing.T) {
+	cases := []struct {
+		doc  string
+		line int
+		msg  string
+	}{
+		{doc: "jobs:\n\tbuild: {}\n", line: 2, msg: "found character that cannot start any token"},
+		{doc: "jobs:\n  build:\n    steps: 3\n", line: 3, msg: "cannot unmarshal !!int `3` into []github.stepDocument"},
+		{doc: "name: [1]\njobs: 5\n", line: 1, msg: "cannot unmarshal !!seq into string (and 1 more)"},
+	}
+	for _, tc := range cases {
+		_, _, err := decodeWorkflow(strings.NewReader(tc.doc), "ci.yml", Limits{})
+		var parseErr *provider.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("%q: expected a *provider.ParseError, got %v", tc.doc, err)
+		}
+		if parseErr.Path != "ci.yml" || parseErr.Line != tc.line || parseErr.Message != tc.msg {
+			t.Fatalf("%q: got %+v, want line %d: %s", tc.doc, parseErr, tc.line, tc.msg)
+		}
+	}
+}
+
 func TestConvertEnv(t *testing.T) {
 	env := map[string]interface{}{"B": 2, "A": "1"}
 	converted := convertEnv(env)
@@ -182,7 +256,7 @@ func TestConvertEnv(t *testing.T) {
 
 func TestParserParseMissingJobs(t *testing.T) {
 	yamlDoc := `name: Empty Jobs`
-	wf, _, err := decodeWorkflow(strings.NewReader(yamlDoc), "empty.yml")
+	wf, _, err := decodeWorkflow(strings.NewReader(yamlDoc), "empty.yml", Limits{})
 	if err != nil {
 		t.Fatalf("decodeWorkflow error: %v", err)
 	}
@@ -191,8 +265,55 @@ func TestParserParseMissingJobs(t *testing.T) {
 	}
 }
 
+func TestParserParseMultiDocument(t *testing.T) {
+	parser := NewParser(projectRoot(t))
+	pipeline, err := parser.Parse([]string{
+		"testdata/workflows/ci_multi_doc.yml",
+		"testdata/workflows/ci_multi_doc_empty.yml",
+	})
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	var got []string
+	for _, wf := range pipeline.Workflows {
+		got = append(got, wf.Path+"="+wf.Name+"/"+wf.Jobs[0].Steps[0].Name)
+	}
+	want := []string{
+		"testdata/workflows/ci_multi_doc.yml#1=Generated Lint/Vet",
+		"testdata/workflows/ci_multi_doc.yml#2=Generated Test/Unit",
+		// The empty trailing document is dropped, so the path is not numbered.
+		"testdata/workflows/ci_multi_doc_empty.yml=Generated Build/Compile",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("workflows = %v, want %v", got, want)
+	}
+	if a, b := pipeline.Workflows[0].Jobs[0].Steps[0].ID, pipeline.Workflows[1].Jobs[0].Steps[0].ID; a == b {
+		t.Fatalf("steps in different documents share ID %q", a)
+	}
+}
+
+func TestDecodeWorkflowsNumbersDocumentsInFileOrder(t *testing.T) {
+	doc := "---\n# leading separator\n---\njobs: {a: {steps: [{run: one}]}}\n---\njobs: {b: {steps: [{run: two}]}}\n"
+	wfs, _, err := decodeWorkflows(strings.NewReader(doc), "gen.yml", Limits{})
+	if err != nil {
+		t.Fatalf("decodeWorkflows error: %v", err)
+	}
+	if len(wfs) != 2 || wfs[0].Path != "gen.yml#2" || wfs[1].Path != "gen.yml#3" {
+		t.Fatalf("workflows = %+v, want gen.yml#2 and gen.yml#3", wfs)
+	}
+	if wfs[0].Name != "gen.yml#2" {
+		t.Fatalf("unnamed document named %q, want its path", wfs[0].Name)
+	}
+
+	_, _, err = decodeWorkflows(strings.NewReader("jobs: {}\n---\njobs: [\n"), "gen.yml", Limits{})
+	var parseErr *provider.ParseError
+	if !errors.As(err, &parseErr) || parseErr.Path != "gen.yml" {
+		t.Fatalf("expected a parse error for the broken second document, got %v", err)
+	}
+}
+
 func TestParseWorkflowFileError(t *testing.T) {
-	_, _, err := decodeWorkflow(&errorReader{}, "bad.yml")
+	_, _, err := decodeWorkflow(&errorReader{}, "bad.yml", Limits{})
 	if err == nil {
 		t.Fatalf("expected error from reader")
 	}
@@ -225,8 +346,433 @@ func mustContain(t *testing.T, list []string, target string) {
 	t.Fatalf("expected to find %q in %v", target, list)
 }
 
+// decodeWorkflow decodes a workflow file of one document.
+func decodeWorkflow(r io.Reader, displayPath string, limits Limits) (provider.Workflow, []provider.Warning, error) {
+	wfs, warnings, err := decodeWorkflows(r, displayPath, limits)
+	if err != nil {
+		return provider.Workflow{}, nil, err
+	}
+	return wfs[0], warnings, nil
+}
+
 type errorReader struct{}
 
 func (errorReader) Read([]byte) (int, error) {
 	return 0, errors.New("boom")
 }
+
+func TestParseConcurrency(t *testing.T) {
+	yamlDoc := `name: Deploy
+concurrency: deploy-${{ github.ref }}
+jobs:
+  migrate:
+    concurrency:
+      group: db
+      cancel-in-progress: true
+    steps:
+      - run: echo migrate
+  preview:
+    concurrency:
+      group: preview-${{ github.ref }}
+      cancel-in-progress: ${{ github.ref != 'refs/heads/main' }}
+    steps:
+      - run: echo preview
+  ship:
+    steps:
+      - run: echo ship
+`
+	wf, _, err := decodeWorkflow(strings.NewReader(yamlDoc), "deploy.yml", Limits{})
+	if err != nil {
+		t.Fatalf("decodeWorkflow error: %v", err)
+	}
+	if wf.Concurrency == nil || wf.Concurrency.Group != "deploy-${{ github.ref }}" || wf.Concurrency.CancelInProgress {
+		t.Fatalf("unexpected workflow concurrency %+v", wf.Concurrency)
+	}
+	want := map[string]*provider.Concurrency{
+		"migrate": {Group: "db", CancelInProgress: true},
+		"preview": {Group: "preview-${{ github.ref }}", CancelInProgress: true},
+		"ship":    nil,
+	}
+	for _, job := range wf.Jobs {
+		if !reflect.DeepEqual(job.Concurrency, want[job.RawID]) {
+			t.Fatalf("job %s concurrency = %+v, want %+v", job.RawID, job.Concurrency, want[job.RawID])
+		}
+	}
+}
+
+func TestParseEnvironment(t *testing.T) {
+	yamlDoc := `name: Deploy
+jobs:
+  production:
+    environment:
+      name: production
+      url: https://example.com
+    steps:
+      - run: echo deploy
+  staging:
+    environment: staging
+    steps:
+      - run: echo deploy
+  test:
+    steps:
+      - run: echo test
+`
+	wf, _, err := decodeWorkflow(strings.NewReader(yamlDoc), "deploy.yml", Limits{})
+	if err != nil {
+		t.Fatalf("decodeWorkflow error: %v", err)
+	}
+	want := map[string]string{"production": "production", "staging": "staging", "test": ""}
+	for _, job := range wf.Jobs {
+		if job.Environment != want[job.RawID] {
+			t.Fatalf("job %s environment = %q, want %q", job.RawID, job.Environment, want[job.RawID])
+		}
+	}
+}
+
+func TestParseTimeoutMinutes(t *testing.T) {
+	yamlDoc := `name: CI
+jobs:
+  test:
+    timeout-minutes: 30
+    steps:
+      - run: echo test
+  quick:
+    timeout-minutes: 2.5
+    steps:
+      - run: echo quick
+  dynamic:
+    timeout-minutes: ${{ inputs.timeout }}
+    steps:
+      - run: echo dynamic
+  none:
+    steps:
+      - run: echo none
+`
+	wf, _, err := decodeWorkflow(strings.NewReader(yamlDoc), "ci.yml", Limits{})
+	if err != nil {
+		t.Fatalf("decodeWorkflow error: %v", err)
+	}
+	want := map[string]float64{"test": 30, "quick": 2.5, "dynamic": 0, "none": 0}
+	for _, job := range wf.Jobs {
+		if job.TimeoutMinutes != want[job.RawID] {
+			t.Fatalf("job %s timeout = %v, want %v", job.RawID, job.TimeoutMinutes, want[job.RawID])
+		}
+	}
+}
+
+func TestParseStepIDs(t *testing.T) {
+	parse := func(doc string) provider.Job {
+		t.Helper()
+		wf, _, err := decodeWorkflow(strings.NewReader(doc), "ci.yml", Limits{})
+		if err != nil {
+			t.Fatalf("decodeWorkflow error: %v", err)
+		}
+		return wf.Jobs[0]
+	}
+	base := parse(`name: CI
+jobs:
+  test:
+    steps:
+      - name: Setup
+        run: bin/setup
+      - name: Specs
+        run: bin/rspec
+`)
+	if base.Steps[0].ID == "" || base.Steps[0].ID == base.Steps[1].ID {
+		t.Fatalf("expected distinct step IDs, got %q and %q", base.Steps[0].ID, base.Steps[1].ID)
+	}
+
+	// Cosmetic changes keep every ID.
+	renamed := parse(`name: Continuous integration
+jobs:
+  test:
+    name: Test suite
+    env:
+      RAILS_ENV: test
+    steps:
+      - name: Prepare  # testdrive: allow-privileged
+        run: bin/setup
+      - name: System specs
+        working-directory: app
+        run: bin/rspec
+`)
+	for i := range base.Steps {
+		if renamed.Steps[i].ID != base.Steps[i].ID {
+			t.Fatalf("step %d: ID changed from %q to %q after a rename", i, base.Steps[i].ID, renamed.Steps[i].ID)
+		}
+	}
+
+	// A new command, a new position, or a new job ID does not.
+	changed := parse(`name: CI
+jobs:
+  test:
+    steps:
+      - name: Setup
+        run: bin/setup --fast
+      - name: Lint
+        run: bin/lint
+      - name: Specs
+        run: bin/rspec
+`)
+	moved := parse(`name: CI
+jobs:
+  specs:
+    steps:
+      - name: Setup
+        run: bin/setup
+`)
+	for _, id := range []string{changed.Steps[0].ID, changed.Steps[2].ID, moved.Steps[0].ID} {
+		if id == base.Steps[0].ID || id == base.Steps[1].ID {
+			t.Fatalf("expected a new ID, got %q again", id)
+		}
+	}
+}
+
+func TestParseContainer(t *testing.T) {
+	yamlDoc := `name: CI
+env:
+  RAILS_ENV: development
+jobs:
+  short:
+    container: node:20-bullseye
+    steps:
+      - run: npm test
+  full:
+    container:
+      image: ruby:3.3
+      env:
+        RAILS_ENV: test
+        DATABASE_URL: postgres://db/test
+      options: --cpus 2
+      volumes:
+        - /tmp/cache:/cache
+    env:
+      DATABASE_URL: postgres://localhost/test
+    steps:
+      - run: bundle exec rspec
+  host:
+    steps:
+      - run: make
+`
+	wf, warnings, err := decodeWorkflow(strings.NewReader(yamlDoc), "ci.yml", Limits{})
+	if err != nil {
+		t.Fatalf("decodeWorkflow error: %v", err)
+	}
+	jobs := make(map[string]provider.Job)
+	for _, job := range wf.Jobs {
+		jobs[job.RawID] = job
+	}
+
+	if c := jobs["short"].Container; c == nil || !reflect.DeepEqual(*c, provider.Container{Image: "node:20-bullseye"}) {
+		t.Fatalf("short container = %+v", c)
+	}
+	if jobs["short"].Env != nil {
+		t.Fatalf("expected no job env from an image-only container, got %v", jobs["short"].Env)
+	}
+	want := provider.Container{
+		Image:   "ruby:3.3",
+		Env:     map[string]string{"RAILS_ENV": "test", "DATABASE_URL": "postgres://db/test"},
+		Options: "--cpus 2",
+		Volumes: []string{"/tmp/cache:/cache"},
+	}
+	if c := jobs["full"].Container; c == nil || !reflect.DeepEqual(*c, want) {
+		t.Fatalf("full container = %+v, want %+v", c, want)
+	}
+	if jobs["host"].Container != nil {
+		t.Fatalf("expected no container for host job")
+	}
+
+	// Container env sits under the job env and above the workflow env.
+	env := resolve.MergeEnv(nil, wf.Env, jobs["full"].Env)
+	if got := resolve.EnvValue(env, "RAILS_ENV"); got != "test" {
+		t.Fatalf("RAILS_ENV = %q, want the container's test", got)
+	}
+	if got := resolve.EnvValue(env, "DATABASE_URL"); got != "postgres://localhost/test" {
+		t.Fatalf("DATABASE_URL = %q, want the job's value", got)
+	}
+
+	var messages []string
+	for _, w := range warnings {
+		if w.Kind != provider.WarnContainerUnsupported {
+			t.Fatalf("unexpected warning %+v", w)
+		}
+		messages = append(messages, w.Job+": "+w.Message)
+	}
+	wantMessages := []string{
+		"full: container ruby:3.3 is not supported; steps run on the host",
+		"full: container env (DATABASE_URL, RAILS_ENV) is applied to the job env",
+		`full: container options "--cpus 2" are ignored`,
+		"full: container volumes are ignored: /tmp/cache:/cache",
+		"short: container node:20-bullseye is not supported; steps run on the host",
+	}
+	if !reflect.DeepEqual(messages, wantMessages) {
+		t.Fatalf("warnings = %q, want %q", messages, wantMessages)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	yamlDoc := `name: Test
+jobs:
+  capped:
+    strategy:
+      max-parallel: 2
+      matrix:
+        go: ['1.22', '1.23']
+    steps:
+      - run: go test ./...
+  keep-going:
+    strategy:
+      fail-fast: false
+      max-parallel: ${{ inputs.parallel }}
+      matrix:
+        os: [ubuntu-latest, macos-latest]
+    steps:
+      - run: make
+  plain:
+    steps:
+      - run: echo plain
+`
+	wf, _, err := decodeWorkflow(strings.NewReader(yamlDoc), "test.yml", Limits{})
+	if err != nil {
+		t.Fatalf("decodeWorkflow error: %v", err)
+	}
+	want := map[string]*provider.Strategy{
+		"capped":     {MaxParallel: 2, FailFast: true},
+		"keep-going": {FailFast: false},
+		"plain":      nil,
+	}
+	for _, job := range wf.Jobs {
+		if !reflect.DeepEqual(job.Strategy, want[job.RawID]) {
+			t.Fatalf("job %s strategy = %+v, want %+v", job.RawID, job.Strategy, want[job.RawID])
+		}
+	}
+}
+
+func TestParseMatrix(t *testing.T) {
+	yamlDoc := `name: Test
+jobs:
+  test:
+    runs-on: ${{ matrix.os }}
+    env:
+      GO_VERSION: ${{ matrix.go }}
+    strategy:
+      matrix:
+        os: [ubuntu-latest, macos-latest]
+        go: ['1.22', '1.23']
+        exclude:
+          - os: macos-latest
+            go: '1.22'
+        include:
+          - go: '1.23'
+            race: -race
+          - os: windows-latest
+            go: '1.23'
+    steps:
+      - name: Test ${{ matrix.go }}
+        run: go test ${{ matrix.race }} ./...
+  lint:
+    name: lint on ${{ matrix.os }}
+    strategy:
+      matrix:
+        os: [ubuntu-latest]
+        node: ['20', '22']
+    steps:
+      - run: make lint
+`
+	wf, warnings, err := decodeWorkflow(strings.NewReader(yamlDoc), "test.yml", Limits{})
+	if err != nil {
+		t.Fatalf("decodeWorkflow error: %v", err)
+	}
+	for _, w := range warnings {
+		if w.Kind == provider.WarnMatrixUnsupported {
+			t.Fatalf("unexpected matrix warning: %s", w.Message)
+		}
+	}
+	var names, variants, runs, envs []string
+	ids := make(map[string]bool)
+	for _, job := range wf.Jobs {
+		names = append(names, job.Name)
+		variants = append(variants, job.Variant)
+		runs = append(runs, job.Steps[0].Run)
+		envs = append(envs, job.Env["GO_VERSION"])
+		if job.Steps[0].Name != "Test ${{ matrix.go }}" && job.RawID == "test" {
+			t.Fatalf("step name = %q, want it as written", job.Steps[0].Name)
+		}
+		if ids[job.Steps[0].ID] {
+			t.Fatalf("step ID %s is shared between variants", job.Steps[0].ID)
+		}
+		ids[job.Steps[0].ID] = true
+	}
+	wantNames := []string{
+		"lint on ubuntu-latest (ubuntu-latest, 20)",
+		"lint on ubuntu-latest (ubuntu-latest, 22)",
+		"test (ubuntu-latest, 1.22)",
+		"test (ubuntu-latest, 1.23, -race)",
+		"test (macos-latest, 1.23, -race)",
+		"test (windows-latest, 1.23)",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("names = %q, want %q", names, wantNames)
+	}
+	wantVariants := []string{"ubuntu-latest, 20", "ubuntu-latest, 22", "ubuntu-latest, 1.22", "ubuntu-latest, 1.23, -race", "macos-latest, 1.23, -race", "windows-latest, 1.23"}
+	if !reflect.DeepEqual(variants, wantVariants) {
+		t.Fatalf("variants = %q, want %q", variants, wantVariants)
+	}
+	wantRuns := []string{"make lint", "make lint", "go test  ./...", "go test -race ./...", "go test -race ./...", "go test  ./..."}
+	if !reflect.DeepEqual(runs, wantRuns) {
+		t.Fatalf("runs = %q, want %q", runs, wantRuns)
+	}
+	wantEnvs := []string{"", "", "1.22", "1.23", "1.23", "1.23"}
+	if !reflect.DeepEqual(envs, wantEnvs) {
+		t.Fatalf("GO_VERSION = %q, want %q", envs, wantEnvs)
+	}
+}
+
+func TestParseMatrixExpression(t *testing.T) {
+	wf, warnings, err := decodeWorkflow(strings.NewReader(`name: Test
+jobs:
+  test:
+    strategy:
+      matrix: ${{ fromJSON(needs.setup.outputs.matrix) }}
+    steps:
+      - run: make test
+`), "test.yml", Limits{})
+	if err != nil {
+		t.Fatalf("decodeWorkflow error: %v", err)
+	}
+	if len(wf.Jobs) != 1 || wf.Jobs[0].Variant != "" {
+		t.Fatalf("jobs = %+v, want the job once", wf.Jobs)
+	}
+	if len(warnings) != 1 || warnings[0].Kind != provider.WarnMatrixUnsupported ||
+		warnings[0].Message != "strategy.matrix is not supported: it is computed by an expression; the job runs once" {
+		t.Fatalf("warnings = %+v", warnings)
+	}
+}
+
+func TestParseNeeds(t *testing.T) {
+	wf, _, err := decodeWorkflow(strings.NewReader(`name: CI
+jobs:
+  build:
+    steps:
+      - run: make
+  test:
+    needs: build
+    steps:
+      - run: make test
+  deploy:
+    needs: [build, test]
+    steps:
+      - run: make deploy
+`), "ci.yml", Limits{})
+	if err != nil {
+		t.Fatalf("decodeWorkflow error: %v", err)
+	}
+	got := make(map[string][]string)
+	for _, job := range wf.Jobs {
+		got[job.RawID] = job.Needs
+	}
+	want := map[string][]string{"build": nil, "test": {"build"}, "deploy": {"build", "test"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("needs = %v, want %v", got, want)
+	}
+}
@@ -1,5 +1,13 @@
 package provider
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
 // Pipeline represents a parsed set of workflows from a provider.
 type Pipeline struct {
 	Provider  string     `json:"provider"`
@@ -7,11 +15,30 @@ type Pipeline struct {
 	Warnings  []Warning  `json:"warnings"`
 }
 
+// ParseError is a workflow file that is not valid YAML or does not have the
+// shape of a workflow. Line is where the parser gave up, or zero when it did
+// not say.
+type ParseError struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("parse workflow %q: %s", e.Path, e.Message)
+	}
+	return fmt.Sprintf("parse workflow %q: line %d: %s", e.Path, e.Line, e.Message)
+}
+
 // Warning captures non-fatal issues encountered while parsing workflows.
 type Warning struct {
-	Workflow string `json:"workflow"`
-	Job      string `json:"job"`
-	Message  string `json:"message"`
+	Kind     WarningKind `json:"kind"`
+	Workflow string      `json:"workflow"`
+	Job      string      `json:"job"`
+	// Step names the step a step-level warning refers to.
+	Step    string `json:"step,omitempty"`
+	Message string `json:"message"`
 }
 
 // Workflow mirrors a GitHub Actions workflow file.
@@ -20,7 +47,18 @@ type Workflow struct {
 	Name     string            `json:"name"`
 	Env      map[string]string `json:"env,omitempty"`
 	Defaults Defaults          `json:"defaults"`
-	Jobs     []Job             `json:"jobs"`
+	// Concurrency is the workflow-level concurrency block, if any.
+	Concurrency *Concurrency `json:"concurrency,omitempty"`
+	Jobs        []Job        `json:"jobs"`
+}
+
+// Concurrency mirrors a `concurrency:` block. Group is the raw, unexpanded
+// expression.
+type Concurrency struct {
+	Group string `json:"group"`
+	// CancelInProgress is true when the flag is set or given as an
+	// expression that may evaluate to true on CI.
+	CancelInProgress bool `json:"cancel_in_progress,omitempty"`
 }
 
 // Defaults capture shared configuration for jobs and steps.
@@ -35,15 +73,94 @@ type Job struct {
 	RawID    string            `json:"id"`
 	Env      map[string]string `json:"env,omitempty"`
 	Defaults Defaults          `json:"defaults"`
-	Steps    []Step            `json:"steps"`
+	// Concurrency is the job-level concurrency block, if any.
+	Concurrency *Concurrency `json:"concurrency,omitempty"`
+	// Environment is the deployment environment the job targets, if any.
+	Environment string `json:"environment,omitempty"`
+	// Strategy holds the job's strategy settings, if it has a strategy block.
+	Strategy *Strategy `json:"strategy,omitempty"`
+	// Container is the job's container block, if any. Steps still run on
+	// the host; only its env is applied, merged into Env.
+	Container *Container `json:"container,omitempty"`
+	// Variant names the matrix combination a job was expanded from, e.g.
+	// "ubuntu-latest, 1.22". Variants of one matrix share RawID; jobs that
+	// are not matrix variants leave it empty.
+	Variant string `json:"variant,omitempty"`
+	// Needs lists the IDs of the jobs in the same workflow that must finish
+	// before this one starts. A need names every matrix variant of a job.
+	Needs []string `json:"needs,omitempty"`
+	// TimeoutMinutes is the job's timeout-minutes on CI, or zero when it
+	// sets none or sets it with an expression.
+	TimeoutMinutes float64 `json:"timeout_minutes,omitempty"`
+	Steps          []Step  `json:"steps"`
+}
+
+// Container mirrors a job's `container:` block.
+type Container struct {
+	Image   string            `json:"image"`
+	Env     map[string]string `json:"env,omitempty"`
+	Options string            `json:"options,omitempty"`
+	Volumes []string          `json:"volumes,omitempty"`
+}
+
+// Strategy mirrors the scheduling keys of a `strategy:` block.
+type Strategy struct {
+	// MaxParallel caps how many variants of the matrix run at once; zero
+	// means no cap beyond the global limit.
+	MaxParallel int `json:"max_parallel,omitempty"`
+	// FailFast cancels queued variants once one fails. GitHub defaults it
+	// to true.
+	FailFast bool `json:"fail_fast"`
 }
 
 // Step represents an individual GitHub Actions workflow step.
 type Step struct {
+	// ID identifies the step across runs; see StepID. Steps that did not
+	// come from a workflow file leave it empty.
+	ID               string            `json:"id,omitempty"`
 	Name             string            `json:"name"`
 	Run              string            `json:"run,omitempty"`
 	Uses             string            `json:"uses,omitempty"`
 	Shell            string            `json:"shell,omitempty"`
 	WorkingDirectory string            `json:"working_directory,omitempty"`
 	Env              map[string]string `json:"env,omitempty"`
+	// Overridden is set when a config override changed the step.
+	Overridden bool `json:"overridden,omitempty"`
+	// Skip is set when a config override disabled the step.
+	Skip bool `json:"skip,omitempty"`
+	// Teardown is set when a teardown_steps pattern matched the step.
+	Teardown bool `json:"teardown,omitempty"`
+	// LocalSkip is set by a `# testdrive: skip` comment in the step's run
+	// block or name; LocalSkipReason is the reason it gave, if any.
+	LocalSkip       bool   `json:"local_skip,omitempty"`
+	LocalSkipReason string `json:"local_skip_reason,omitempty"`
+	// AllowPrivileged is set by a `# testdrive: allow-privileged` comment
+	// and exempts the step from the privileged command patterns.
+	AllowPrivileged bool `json:"allow_privileged,omitempty"`
+	// ExpectedFailure is set by an expected_failures pattern or a
+	// `# testdrive: xfail` comment: the step is known to fail locally, so
+	// its failure does not fail the run and a pass is flagged.
+	ExpectedFailure bool `json:"expected_failure,omitempty"`
+}
+
+// StepID returns the identifier of the step at index, counted from zero, in
+// job of the workflow at workflowPath, whose run script is run. It depends on
+// nothing else, so it survives renaming the step, the job, or the workflow,
+// and editing the step's env or working directory. It changes when the
+// script changes, when steps are inserted or removed before it, or when the
+// job's ID, matrix variant, or the workflow's path change.
+func StepID(workflowPath string, job Job, index int, run string) string {
+	script := sha256.Sum256([]byte(run))
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%d\x00%x", filepath.ToSlash(workflowPath), job.RawID, job.Variant, index, script[:8])))
+	return hex.EncodeToString(sum[:8])
+}
+
+// TeardownPrefix marks a step as teardown by name, e.g. "post: stop services".
+const TeardownPrefix = "post:"
+
+// IsTeardown reports whether the step runs in its job's teardown phase,
+// after every other step and even when the run fails or is cancelled.
+func (s Step) IsTeardown() bool {
+	name := strings.ToLower(strings.TrimSpace(s.Name))
+	return s.Teardown || strings.HasPrefix(name, TeardownPrefix)
 }
@@ -0,0 +1,39 @@
+package provider
+
+import "testing"
+
+func TestStepIsTeardown(t *testing.T) {
+	for _, tc := range []struct {
+		step Step
+		want bool
+	}{
+		{step: Step{Name: "post: stop services"}, want: true},
+		{step: Step{Name: "  Post: docker compose down"}, want: true},
+		{step: Step{Name: "Postgres setup"}, want: false},
+		{step: Step{Name: "Stop services", Teardown: true}, want: true},
+	} {
+		if got := tc.step.IsTeardown(); got != tc.want {
+			t.Errorf("%q: IsTeardown = %v, want %v", tc.step.Name, got, tc.want)
+		}
+	}
+}
+
+func TestStepID(t *testing.T) {
+	job := Job{Name: "test", RawID: "test"}
+	id := StepID(".github/workflows/ci.yml", job, 0, "bin/rspec")
+	if len(id) != 16 || id != StepID(".github/workflows/ci.yml", Job{Name: "Test suite", RawID: "test"}, 0, "bin/rspec") {
+		t.Fatalf("expected a stable 16-character ID that ignores the job name, got %q", id)
+	}
+	variant := job
+	variant.Variant = "ubuntu-latest, 1.22"
+	for _, other := range []string{
+		StepID(".github/workflows/ci.yml", variant, 0, "bin/rspec"),
+		StepID(".github/workflows/ci.yml", job, 1, "bin/rspec"),
+		StepID(".github/workflows/ci.yml", job, 0, "bin/rspec spec/system"),
+		StepID(".github/workflows/specs.yml", job, 0, "bin/rspec"),
+	} {
+		if other == id {
+			t.Fatalf("expected a different ID, got %q again", other)
+		}
+	}
+}
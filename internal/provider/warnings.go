@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WarningKind classifies a Warning so whole categories can be suppressed.
+type WarningKind string
+
+// Warning kinds produced by the parser and the pipeline checks.
+const (
+	WarnServicesUnsupported  WarningKind = "services_unsupported"
+	WarnContainerUnsupported WarningKind = "container_unsupported"
+	WarnMatrixUnsupported    WarningKind = "matrix_unsupported"
+	WarnJobIfIgnored         WarningKind = "job_if_ignored"
+	WarnStepIfUnsupported    WarningKind = "step_if_unsupported"
+	WarnOverrideUnmatched    WarningKind = "override_unmatched"
+	WarnVersionMismatch      WarningKind = "version_mismatch"
+	WarnToolNotFound         WarningKind = "tool_not_found"
+	WarnVersionUndetected    WarningKind = "version_undetected"
+	WarnEnvPossiblyMissing   WarningKind = "env_possibly_missing"
+	WarnVarsMissing          WarningKind = "vars_missing"
+	WarnGitState             WarningKind = "git_state"
+	WarnTimeBudget           WarningKind = "time_budget"
+	WarnUnexpectedPass       WarningKind = "unexpected_pass"
+	WarnShellAudit           WarningKind = "shell_audit"
+
+	// Info kinds note workflow keys the parser read past. They are kept apart
+	// from warnings and only shown on request.
+	InfoKeyIgnored WarningKind = "key_ignored"
+	InfoKeyUnknown WarningKind = "key_unknown"
+)
+
+// WarningKinds lists every known kind in a stable order.
+func WarningKinds() []WarningKind {
+	return []WarningKind{
+		WarnServicesUnsupported,
+		WarnContainerUnsupported,
+		WarnMatrixUnsupported,
+		WarnJobIfIgnored,
+		WarnStepIfUnsupported,
+		WarnOverrideUnmatched,
+		WarnVersionMismatch,
+		WarnToolNotFound,
+		WarnVersionUndetected,
+		WarnEnvPossiblyMissing,
+		WarnVarsMissing,
+		WarnGitState,
+		WarnTimeBudget,
+		WarnUnexpectedPass,
+		WarnShellAudit,
+		InfoKeyIgnored,
+		InfoKeyUnknown,
+	}
+}
+
+// Info reports whether k is an informational notice rather than a warning.
+func (k WarningKind) Info() bool {
+	return k == InfoKeyIgnored || k == InfoKeyUnknown
+}
+
+// ParseWarningKinds validates names against the known kinds. The error for
+// an unknown name lists the valid ones.
+func ParseWarningKinds(names []string) (map[WarningKind]bool, error) {
+	known := make(map[WarningKind]bool)
+	for _, k := range WarningKinds() {
+		known[k] = true
+	}
+	kinds := make(map[WarningKind]bool, len(names))
+	for _, name := range names {
+		k := WarningKind(strings.TrimSpace(name))
+		if !known[k] {
+			valid := make([]string, 0, len(known))
+			for _, k := range WarningKinds() {
+				valid = append(valid, string(k))
+			}
+			return nil, fmt.Errorf("unknown warning kind %q; valid kinds: %s", name, strings.Join(valid, ", "))
+		}
+		kinds[k] = true
+	}
+	return kinds, nil
+}
+
+// SuppressWarnings returns the warnings whose kind is not in suppressed.
+func SuppressWarnings(warnings []Warning, suppressed map[WarningKind]bool) []Warning {
+	if len(suppressed) == 0 {
+		return warnings
+	}
+	out := make([]Warning, 0, len(warnings))
+	for _, w := range warnings {
+		if !suppressed[w.Kind] {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// SplitInfos separates informational notices from warnings, keeping the
+// order of each.
+func SplitInfos(all []Warning) (warnings, infos []Warning) {
+	for _, w := range all {
+		if w.Kind.Info() {
+			infos = append(infos, w)
+		} else {
+			warnings = append(warnings, w)
+		}
+	}
+	return warnings, infos
+}
@@ -0,0 +1,42 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWarningKinds(t *testing.T) {
+	kinds, err := ParseWarningKinds([]string{"matrix_unsupported", " services_unsupported "})
+	if err != nil {
+		t.Fatalf("ParseWarningKinds: %v", err)
+	}
+	if !kinds[WarnMatrixUnsupported] || !kinds[WarnServicesUnsupported] || len(kinds) != 2 {
+		t.Fatalf("unexpected kinds: %v", kinds)
+	}
+
+	_, err = ParseWarningKinds([]string{"matrix"})
+	if err == nil {
+		t.Fatalf("expected error for unknown kind")
+	}
+	for _, k := range WarningKinds() {
+		if !strings.Contains(err.Error(), string(k)) {
+			t.Fatalf("error should list %s: %v", k, err)
+		}
+	}
+}
+
+func TestSuppressWarningsKeepsOtherKinds(t *testing.T) {
+	warnings := []Warning{
+		{Kind: WarnMatrixUnsupported, Message: "matrix"},
+		{Kind: WarnServicesUnsupported, Message: "services"},
+		{Kind: WarnMatrixUnsupported, Message: "matrix again"},
+		{Kind: WarnVersionMismatch, Message: "ruby"},
+	}
+	got := SuppressWarnings(warnings, map[WarningKind]bool{WarnMatrixUnsupported: true})
+	if len(got) != 2 || got[0].Kind != WarnServicesUnsupported || got[1].Kind != WarnVersionMismatch {
+		t.Fatalf("unexpected warnings: %+v", got)
+	}
+	if got := SuppressWarnings(warnings, nil); len(got) != len(warnings) {
+		t.Fatalf("expected nothing suppressed, got %+v", got)
+	}
+}
@@ -0,0 +1,67 @@
+package report
+
+import "time"
+
+// Budget sources name where a job's time limit came from.
+const (
+	// BudgetTimeout is the job's timeout-minutes on CI.
+	BudgetTimeout = "timeout"
+	// BudgetConfig is a time_budgets entry in the config.
+	BudgetConfig = "budget"
+)
+
+// JobBudget holds the time limits of one job. Zero means no limit.
+type JobBudget struct {
+	WorkflowPath string
+	JobName      string
+	Timeout      time.Duration
+	Budget       time.Duration
+}
+
+// BudgetOverrun is a job that ran longer than one of its limits.
+type BudgetOverrun struct {
+	WorkflowPath string        `json:"workflow_path"`
+	JobName      string        `json:"job_name"`
+	Duration     time.Duration `json:"-"`
+	DurationMS   int64         `json:"duration_ms"`
+	Limit        time.Duration `json:"-"`
+	LimitMS      int64         `json:"limit_ms"`
+	// Source is BudgetTimeout or BudgetConfig.
+	Source string `json:"source"`
+}
+
+// CheckBudgets compares each job's duration with its limits and returns an
+// overrun for every limit exceeded, in job order. A job over both its CI
+// timeout and its budget is reported twice. Jobs that did not run are
+// skipped, as their duration is zero.
+func CheckBudgets(jobs []JobSummary, budgets []JobBudget) []BudgetOverrun {
+	limits := make(map[[2]string]JobBudget, len(budgets))
+	for _, b := range budgets {
+		limits[[2]string{b.WorkflowPath, b.JobName}] = b
+	}
+	var overruns []BudgetOverrun
+	for _, job := range jobs {
+		b, ok := limits[[2]string{job.WorkflowPath, job.JobName}]
+		if !ok {
+			continue
+		}
+		for _, limit := range []struct {
+			d      time.Duration
+			source string
+		}{{b.Timeout, BudgetTimeout}, {b.Budget, BudgetConfig}} {
+			if limit.d <= 0 || job.Duration <= limit.d {
+				continue
+			}
+			overruns = append(overruns, BudgetOverrun{
+				WorkflowPath: job.WorkflowPath,
+				JobName:      job.JobName,
+				Duration:     job.Duration,
+				DurationMS:   job.Duration.Milliseconds(),
+				Limit:        limit.d,
+				LimitMS:      limit.d.Milliseconds(),
+				Source:       limit.source,
+			})
+		}
+	}
+	return overruns
+}
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckBudgets(t *testing.T) {
+	jobs := []JobSummary{
+		{WorkflowPath: "ci.yml", JobName: "test", Duration: 48 * time.Minute},
+		{WorkflowPath: "ci.yml", JobName: "lint", Duration: 4 * time.Minute},
+		{WorkflowPath: "ci.yml", JobName: "build", Duration: 12 * time.Minute},
+		{WorkflowPath: "ci.yml", JobName: "skipped"},
+		{WorkflowPath: "other.yml", JobName: "test", Duration: time.Hour},
+	}
+	budgets := []JobBudget{
+		{WorkflowPath: "ci.yml", JobName: "test", Timeout: 30 * time.Minute, Budget: 20 * time.Minute},
+		{WorkflowPath: "ci.yml", JobName: "lint", Timeout: 10 * time.Minute, Budget: 5 * time.Minute},
+		{WorkflowPath: "ci.yml", JobName: "build", Budget: 10 * time.Minute},
+		{WorkflowPath: "ci.yml", JobName: "skipped", Timeout: time.Minute},
+	}
+
+	got := CheckBudgets(jobs, budgets)
+	want := []BudgetOverrun{
+		{WorkflowPath: "ci.yml", JobName: "test", Duration: 48 * time.Minute, Limit: 30 * time.Minute, Source: BudgetTimeout},
+		{WorkflowPath: "ci.yml", JobName: "test", Duration: 48 * time.Minute, Limit: 20 * time.Minute, Source: BudgetConfig},
+		{WorkflowPath: "ci.yml", JobName: "build", Duration: 12 * time.Minute, Limit: 10 * time.Minute, Source: BudgetConfig},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("CheckBudgets = %+v, want %d overruns", got, len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.WorkflowPath != w.WorkflowPath || g.JobName != w.JobName || g.Duration != w.Duration || g.Limit != w.Limit || g.Source != w.Source {
+			t.Fatalf("overrun %d = %+v, want %+v", i, g, w)
+		}
+		if g.DurationMS != w.Duration.Milliseconds() || g.LimitMS != w.Limit.Milliseconds() {
+			t.Fatalf("overrun %d ms = %d/%d, want %d/%d", i, g.DurationMS, g.LimitMS, w.Duration.Milliseconds(), w.Limit.Milliseconds())
+		}
+	}
+}
+
+func TestCheckBudgetsNoLimits(t *testing.T) {
+	jobs := []JobSummary{{WorkflowPath: "ci.yml", JobName: "test", Duration: time.Hour}}
+	if got := CheckBudgets(jobs, nil); len(got) != 0 {
+		t.Fatalf("CheckBudgets without budgets = %+v, want none", got)
+	}
+}
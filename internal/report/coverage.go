@@ -0,0 +1,223 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// Skip reason codes recorded for steps that did not execute.
+const (
+	// ReasonUsesStep marks `uses:` action steps, which are never run locally.
+	ReasonUsesStep = "uses_step"
+	// ReasonFilteredJob marks steps whose job did not match --job.
+	ReasonFilteredJob = "filtered_job"
+	// ReasonFilteredOnly marks steps that did not match --only-step.
+	ReasonFilteredOnly = "filtered_only"
+	// ReasonFilteredSkip marks steps that matched --skip-step.
+	ReasonFilteredSkip = "filtered_skip"
+	// ReasonOverride marks steps disabled by a config override.
+	ReasonOverride = "override"
+	// ReasonComment marks steps disabled by a `# testdrive: skip` comment
+	// in the workflow.
+	ReasonComment = "comment"
+	// ReasonPrivileged marks steps matching a privileged command pattern.
+	ReasonPrivileged = "privileged"
+	// ReasonDestructive marks steps matching a destructive command pattern
+	// or removing a path built from an unset variable.
+	ReasonDestructive = "destructive"
+	// ReasonEnvironment marks steps whose job targets a deployment
+	// environment that was not allowed.
+	ReasonEnvironment = "environment"
+	// ReasonDryRun marks steps skipped because of --dry-run.
+	ReasonDryRun = "dry_run"
+	// ReasonDuplicate marks steps skipped by --dedupe because an identical
+	// step already passed.
+	ReasonDuplicate = "duplicate"
+	// ReasonIfFalse marks steps whose if: condition evaluated to false.
+	ReasonIfFalse = "if_false"
+	// ReasonForeignOS marks steps whose job targets a different runner OS.
+	ReasonForeignOS = "foreign_os"
+	// ReasonCancelled marks steps of matrix variants that never started
+	// because another variant failed with fail-fast set.
+	ReasonCancelled = "cancelled"
+	// ReasonNeedsFailed marks steps of jobs that never started because a
+	// job they need failed.
+	ReasonNeedsFailed = "needs_failed"
+)
+
+// SkippedStep records a workflow step that did not execute and why.
+type SkippedStep struct {
+	WorkflowPath string `json:"workflow_path"`
+	WorkflowName string `json:"workflow_name"`
+	JobName      string `json:"job_name"`
+	StepName     string `json:"step_name"`
+	Reason       string `json:"reason"`
+	Detail       string `json:"detail,omitempty"`
+}
+
+// Coverage accounts for every parsed step: how many executed locally and why
+// the rest did not.
+type Coverage struct {
+	Executed int            `json:"executed"`
+	Skipped  []SkippedStep  `json:"skipped"`
+	Counts   map[string]int `json:"counts"`
+}
+
+// BuildCoverage combines steps dropped before execution (uses steps and
+// filters) with the runner's results.
+func BuildCoverage(dropped []SkippedStep, results []StepResult) Coverage {
+	cov := Coverage{
+		Skipped: append([]SkippedStep{}, dropped...),
+		Counts:  make(map[string]int),
+	}
+	for _, res := range results {
+		if res.Status != "skipped" {
+			cov.Executed++
+			continue
+		}
+		cov.Skipped = append(cov.Skipped, SkippedStep{
+			WorkflowPath: res.WorkflowPath,
+			WorkflowName: res.WorkflowName,
+			JobName:      res.JobName,
+			StepName:     res.StepName,
+			Reason:       res.SkipReason,
+			Detail:       res.SkipDetail,
+		})
+	}
+	for _, s := range cov.Skipped {
+		cov.Counts[s.Reason]++
+	}
+	return cov
+}
+
+// Reasons returns the reason codes present in the coverage, sorted.
+func (c Coverage) Reasons() []string {
+	reasons := make([]string, 0, len(c.Counts))
+	for reason := range c.Counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// unsupportedKinds are the parser warnings that mark a run step as one the
+// local run cannot reproduce faithfully, in the order a step is attributed
+// when several apply.
+var unsupportedKinds = []provider.WarningKind{
+	provider.WarnContainerUnsupported,
+	provider.WarnServicesUnsupported,
+	provider.WarnMatrixUnsupported,
+	provider.WarnJobIfIgnored,
+	provider.WarnStepIfUnsupported,
+}
+
+// LocalCoverage estimates how much of the parsed CI runs locally, before any
+// filters are applied. Local steps are run steps that need no unsupported
+// feature; uses steps and unsupported run steps make up the rest.
+type LocalCoverage struct {
+	TotalSteps       int                `json:"total_steps"`
+	RunSteps         int                `json:"run_steps"`
+	UsesSteps        int                `json:"uses_steps"`
+	UnsupportedSteps int                `json:"unsupported_steps"`
+	LocalSteps       int                `json:"local_steps"`
+	Percent          int                `json:"percent"`
+	Unsupported      map[string]int     `json:"unsupported,omitempty"`
+	Workflows        []WorkflowCoverage `json:"workflows"`
+}
+
+// WorkflowCoverage is the LocalCoverage breakdown for one workflow file.
+type WorkflowCoverage struct {
+	WorkflowPath     string         `json:"workflow_path"`
+	WorkflowName     string         `json:"workflow_name"`
+	TotalSteps       int            `json:"total_steps"`
+	RunSteps         int            `json:"run_steps"`
+	UsesSteps        int            `json:"uses_steps"`
+	UnsupportedSteps int            `json:"unsupported_steps"`
+	LocalSteps       int            `json:"local_steps"`
+	Percent          int            `json:"percent"`
+	Unsupported      map[string]int `json:"unsupported,omitempty"`
+}
+
+// BuildLocalCoverage counts the steps of workflows by whether they can run
+// locally. warnings are the parser warnings for the same workflows; job-level
+// ones cover every run step in the job and step-level ones a single step.
+func BuildLocalCoverage(workflows []provider.Workflow, warnings []provider.Warning) LocalCoverage {
+	type scope struct{ workflow, job, step string }
+	features := make(map[scope]map[provider.WarningKind]bool)
+	for _, w := range warnings {
+		key := scope{w.Workflow, w.Job, w.Step}
+		if features[key] == nil {
+			features[key] = make(map[provider.WarningKind]bool)
+		}
+		features[key][w.Kind] = true
+	}
+	unsupported := func(wf provider.Workflow, job provider.Job, step provider.Step) string {
+		jobFeatures := features[scope{wf.Path, job.RawID, ""}]
+		stepFeatures := features[scope{wf.Path, job.RawID, step.Name}]
+		for _, kind := range unsupportedKinds {
+			if jobFeatures[kind] || stepFeatures[kind] {
+				return string(kind)
+			}
+		}
+		return ""
+	}
+
+	total := LocalCoverage{Workflows: make([]WorkflowCoverage, 0, len(workflows))}
+	for _, wf := range workflows {
+		wc := WorkflowCoverage{WorkflowPath: wf.Path, WorkflowName: wf.Name}
+		for _, job := range wf.Jobs {
+			for _, step := range job.Steps {
+				wc.TotalSteps++
+				if step.Run == "" {
+					wc.UsesSteps++
+					continue
+				}
+				wc.RunSteps++
+				if kind := unsupported(wf, job, step); kind != "" {
+					wc.UnsupportedSteps++
+					if wc.Unsupported == nil {
+						wc.Unsupported = make(map[string]int)
+					}
+					wc.Unsupported[kind]++
+				}
+			}
+		}
+		wc.LocalSteps = wc.RunSteps - wc.UnsupportedSteps
+		wc.Percent = percent(wc.LocalSteps, wc.TotalSteps)
+		total.Workflows = append(total.Workflows, wc)
+
+		total.TotalSteps += wc.TotalSteps
+		total.RunSteps += wc.RunSteps
+		total.UsesSteps += wc.UsesSteps
+		total.UnsupportedSteps += wc.UnsupportedSteps
+		for kind, n := range wc.Unsupported {
+			if total.Unsupported == nil {
+				total.Unsupported = make(map[string]int)
+			}
+			total.Unsupported[kind] += n
+		}
+	}
+	total.LocalSteps = total.RunSteps - total.UnsupportedSteps
+	total.Percent = percent(total.LocalSteps, total.TotalSteps)
+	return total
+}
+
+// UnsupportedKinds returns the feature kinds present in counts, sorted.
+func UnsupportedKinds(counts map[string]int) []string {
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// percent rounds part/whole to the nearest whole percent. An empty pipeline
+// counts as fully covered.
+func percent(part, whole int) int {
+	if whole == 0 {
+		return 100
+	}
+	return (part*100 + whole/2) / whole
+}
@@ -0,0 +1,101 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestBuildCoverage(t *testing.T) {
+	dropped := []SkippedStep{
+		{StepName: "Checkout", Reason: ReasonUsesStep},
+		{StepName: "Upload", Reason: ReasonFilteredSkip},
+	}
+	results := []StepResult{
+		{StepName: "Build", Status: "passed"},
+		{StepName: "Test", Status: "failed"},
+		{StepName: "Install", Status: "skipped", SkipReason: ReasonPrivileged, SkipDetail: "skipped privileged command"},
+	}
+
+	cov := BuildCoverage(dropped, results)
+	if cov.Executed != 2 {
+		t.Fatalf("expected 2 executed steps, got %d", cov.Executed)
+	}
+	if len(cov.Skipped) != 3 {
+		t.Fatalf("expected 3 skipped steps, got %d", len(cov.Skipped))
+	}
+	for _, reason := range []string{ReasonUsesStep, ReasonFilteredSkip, ReasonPrivileged} {
+		if cov.Counts[reason] != 1 {
+			t.Fatalf("expected one %s, got %v", reason, cov.Counts)
+		}
+	}
+	if got := cov.Reasons(); len(got) != 3 || got[0] != ReasonFilteredSkip {
+		t.Fatalf("expected sorted reasons, got %v", got)
+	}
+	if cov.Skipped[2].Detail != "skipped privileged command" {
+		t.Fatalf("expected runner message carried as detail, got %+v", cov.Skipped[2])
+	}
+}
+
+func TestBuildLocalCoverage(t *testing.T) {
+	workflows := []provider.Workflow{
+		{
+			Path: "ci.yml",
+			Name: "CI",
+			Jobs: []provider.Job{
+				{RawID: "build", Steps: []provider.Step{
+					{Name: "Checkout", Uses: "actions/checkout@v4"},
+					{Name: "Build", Run: "make"},
+					{Name: "Publish", Run: "make publish"},
+				}},
+				{RawID: "test", Steps: []provider.Step{
+					{Name: "Checkout", Uses: "actions/checkout@v4"},
+					{Name: "Unit", Run: "make test"},
+					{Name: "E2E", Run: "make e2e"},
+				}},
+			},
+		},
+		{
+			Path: "lint.yml",
+			Name: "Lint",
+			Jobs: []provider.Job{
+				{RawID: "lint", Steps: []provider.Step{{Name: "Vet", Run: "go vet ./..."}}},
+			},
+		},
+	}
+	warnings := []provider.Warning{
+		{Kind: provider.WarnStepIfUnsupported, Workflow: "ci.yml", Job: "build", Step: "Publish"},
+		{Kind: provider.WarnMatrixUnsupported, Workflow: "ci.yml", Job: "test"},
+		// A job with several unsupported features counts each step once.
+		{Kind: provider.WarnServicesUnsupported, Workflow: "ci.yml", Job: "test"},
+		// Warnings that do not describe an unsupported feature are ignored.
+		{Kind: provider.WarnVersionMismatch, Workflow: "lint.yml", Job: "lint"},
+	}
+
+	cov := BuildLocalCoverage(workflows, warnings)
+	if cov.TotalSteps != 7 || cov.RunSteps != 5 || cov.UsesSteps != 2 || cov.UnsupportedSteps != 3 || cov.LocalSteps != 2 {
+		t.Fatalf("unexpected totals: %+v", cov)
+	}
+	if cov.Percent != 29 {
+		t.Fatalf("expected 2/7 rounded to 29%%, got %d", cov.Percent)
+	}
+	if cov.Unsupported["services_unsupported"] != 2 || cov.Unsupported["step_if_unsupported"] != 1 || len(cov.Unsupported) != 2 {
+		t.Fatalf("unexpected unsupported counts: %v", cov.Unsupported)
+	}
+	if len(cov.Workflows) != 2 {
+		t.Fatalf("expected a breakdown per workflow, got %d", len(cov.Workflows))
+	}
+	if ci := cov.Workflows[0]; ci.LocalSteps != 1 || ci.TotalSteps != 6 || ci.Percent != 17 {
+		t.Fatalf("unexpected ci.yml breakdown: %+v", ci)
+	}
+	if lint := cov.Workflows[1]; lint.LocalSteps != 1 || lint.Percent != 100 || lint.Unsupported != nil {
+		t.Fatalf("unexpected lint.yml breakdown: %+v", lint)
+	}
+}
+
+func TestBuildLocalCoverageEmpty(t *testing.T) {
+	cov := BuildLocalCoverage(nil, nil)
+	if cov.TotalSteps != 0 || cov.Percent != 100 {
+		t.Fatalf("expected empty pipeline fully covered, got %+v", cov)
+	}
+}
@@ -0,0 +1,83 @@
+package report
+
+import "sort"
+
+// MaskedValue stands in for the value of every variable an EnvSnapshot does
+// not allow through.
+const MaskedValue = "***"
+
+// EnvSnapshot records the environment a failed step ran in, so it can be
+// compared later with a run that passed elsewhere.
+type EnvSnapshot struct {
+	// Shell is the shell the step's script ran under, as resolved.
+	Shell string `json:"shell,omitempty"`
+	// Env holds every variable the step saw. Values outside the allowlist
+	// are MaskedValue.
+	Env map[string]string `json:"env"`
+	// ToolVersions maps each tool the version check probed to the version
+	// it found.
+	ToolVersions map[string]string `json:"tool_versions,omitempty"`
+}
+
+// Kinds of SnapshotChange.
+const (
+	SnapshotShell = "shell"
+	SnapshotEnv   = "env"
+	SnapshotTool  = "tool"
+)
+
+// SnapshotChange is one difference between two snapshots. A and B are empty
+// when the name is missing from that side; ASet and BSet tell an empty value
+// apart.
+type SnapshotChange struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	A    string `json:"a"`
+	B    string `json:"b"`
+	ASet bool   `json:"a_set"`
+	BSet bool   `json:"b_set"`
+}
+
+// SnapshotDiff lists the differences between one step's snapshots in two
+// runs.
+type SnapshotDiff struct {
+	WorkflowPath string           `json:"workflow_path"`
+	JobName      string           `json:"job_name"`
+	StepName     string           `json:"step_name"`
+	Changes      []SnapshotChange `json:"changes"`
+}
+
+// DiffSnapshots compares a with b: the shell first, then tool versions,
+// then variables, each by name. Masked values compare equal, so only a
+// masked variable's presence can differ.
+func DiffSnapshots(a, b EnvSnapshot) []SnapshotChange {
+	changes := []SnapshotChange{}
+	if a.Shell != b.Shell {
+		changes = append(changes, SnapshotChange{Kind: SnapshotShell, Name: "shell", A: a.Shell, B: b.Shell, ASet: a.Shell != "", BSet: b.Shell != ""})
+	}
+	changes = append(changes, diffMaps(SnapshotTool, a.ToolVersions, b.ToolVersions)...)
+	return append(changes, diffMaps(SnapshotEnv, a.Env, b.Env)...)
+}
+
+func diffMaps(kind string, a, b map[string]string) []SnapshotChange {
+	names := make([]string, 0, len(a)+len(b))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var changes []SnapshotChange
+	for _, name := range names {
+		av, aSet := a[name]
+		bv, bSet := b[name]
+		if aSet == bSet && av == bv {
+			continue
+		}
+		changes = append(changes, SnapshotChange{Kind: kind, Name: name, A: av, B: bv, ASet: aSet, BSet: bSet})
+	}
+	return changes
+}
@@ -0,0 +1,35 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	a := EnvSnapshot{
+		Shell:        "bash",
+		Env:          map[string]string{"RAILS_ENV": "test", "LANG": "C", "DATABASE_URL": MaskedValue, "ONLY_A": MaskedValue, "EMPTY": ""},
+		ToolVersions: map[string]string{"ruby": "3.3.0", "node": "20.11.0"},
+	}
+	b := EnvSnapshot{
+		Shell:        "zsh",
+		Env:          map[string]string{"RAILS_ENV": "development", "LANG": "C", "DATABASE_URL": MaskedValue, "ONLY_B": MaskedValue},
+		ToolVersions: map[string]string{"ruby": "3.2.2", "node": "20.11.0"},
+	}
+	got := DiffSnapshots(a, b)
+	want := []SnapshotChange{
+		{Kind: SnapshotShell, Name: "shell", A: "bash", B: "zsh", ASet: true, BSet: true},
+		{Kind: SnapshotTool, Name: "ruby", A: "3.3.0", B: "3.2.2", ASet: true, BSet: true},
+		{Kind: SnapshotEnv, Name: "EMPTY", A: "", ASet: true},
+		{Kind: SnapshotEnv, Name: "ONLY_A", A: MaskedValue, ASet: true},
+		{Kind: SnapshotEnv, Name: "ONLY_B", B: MaskedValue, BSet: true},
+		{Kind: SnapshotEnv, Name: "RAILS_ENV", A: "test", B: "development", ASet: true, BSet: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("DiffSnapshots =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got := DiffSnapshots(a, a); len(got) != 0 {
+		t.Fatalf("expected no changes between equal snapshots, got %+v", got)
+	}
+}
@@ -0,0 +1,49 @@
+package report
+
+// Explanation describes how one run step would execute locally and which
+// rule, if any, keeps it from running. Sources name the level that supplied
+// a setting: default, workflow, job, or step.
+type Explanation struct {
+	WorkflowPath string `json:"workflow_path"`
+	WorkflowName string `json:"workflow_name"`
+	JobName      string `json:"job_name"`
+	StepName     string `json:"step_name"`
+	// Run is the script that would execute, after any override.
+	Run              string      `json:"run"`
+	Shell            string      `json:"shell"`
+	ShellSource      string      `json:"shell_source"`
+	Argv             []string    `json:"argv,omitempty"`
+	WorkingDir       string      `json:"working_dir,omitempty"`
+	WorkingDirSource string      `json:"working_dir_source"`
+	Error            string      `json:"error,omitempty"`
+	Env              []EnvChange `json:"env"`
+	// Mappings describes each path_mappings rewrite applied to the step's
+	// working directory or env.
+	Mappings []string `json:"mappings,omitempty"`
+	// AutoPath lists the project bin directories auto_path puts at the
+	// front of PATH.
+	AutoPath []string `json:"auto_path,omitempty"`
+	// Overrides lists the labels of the config overrides, and the
+	// --override-shell and --trace-shell flags, that changed the step.
+	Overrides []string  `json:"overrides,omitempty"`
+	Skip      *SkipRule `json:"skip,omitempty"`
+	Warnings  []string  `json:"warnings,omitempty"`
+}
+
+// EnvChange is a variable whose value for the step differs from the host
+// environment, attributed to the last level that set it.
+type EnvChange struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Level string `json:"level"`
+	// Host is the value the variable had in the host environment, if any.
+	Host    string `json:"host,omitempty"`
+	HostSet bool   `json:"host_set"`
+}
+
+// SkipRule is the first rule that stops a step from executing, using the
+// same reason codes as Coverage.
+type SkipRule struct {
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+}
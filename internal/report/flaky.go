@@ -0,0 +1,15 @@
+package report
+
+// FlakyStep is one step's record in `testdrive history flaky`: how many of
+// its recent runs passed right after a failed one.
+type FlakyStep struct {
+	WorkflowPath string  `json:"workflow_path"`
+	JobName      string  `json:"job_name"`
+	StepName     string  `json:"step_name"`
+	Recoveries   int     `json:"recoveries"`
+	Runs         int     `json:"runs"`
+	FlakyScore   float64 `json:"flaky_score"`
+	// Flaky is set once the step recovered often enough to be marked in run
+	// results.
+	Flaky bool `json:"flaky"`
+}
@@ -0,0 +1,38 @@
+package report
+
+import "github.com/bgricker/testdrive/internal/config"
+
+// Invocation records how a run was started, so a saved report says which
+// build, flags, filters and config produced it and `run --from-report` can
+// start the same run again. Values that may be secret are masked with
+// MaskedValue.
+type Invocation struct {
+	// Version is the testdrive build that wrote the report.
+	Version string `json:"version"`
+	// Command is the subcommand that ran. Args are its flags as given, as
+	// --name=value in name order, followed by its positional arguments.
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	// Filters are the workflow, job and step selections in effect.
+	Filters InvocationFilters `json:"filters"`
+	// AllowPrivileged is whether TESTDRIVE_ALLOW_PRIVILEGED=1 let
+	// privileged commands run.
+	AllowPrivileged  bool `json:"allow_privileged"`
+	AllowDestructive bool `json:"allow_destructive"`
+	DryRun           bool `json:"dry_run"`
+	// TailLines is how many lines of a failed step's output were kept.
+	TailLines int `json:"tail_lines"`
+	// Stream is whether the report ended a --stream of JSON events.
+	Stream bool `json:"stream"`
+	// Config is the merged configuration, with override env values and
+	// secret-looking vars masked.
+	Config config.Config `json:"config"`
+}
+
+// InvocationFilters lists the selections a run was narrowed by.
+type InvocationFilters struct {
+	Workflows []string `json:"workflows,omitempty"`
+	Jobs      []string `json:"jobs,omitempty"`
+	OnlySteps []string `json:"only_step,omitempty"`
+	SkipSteps []string `json:"skip_step,omitempty"`
+}
@@ -0,0 +1,87 @@
+package report
+
+import "time"
+
+// JobSummary rolls up the step results of a single job.
+type JobSummary struct {
+	WorkflowPath string        `json:"workflow_path"`
+	WorkflowName string        `json:"workflow_name"`
+	JobName      string        `json:"job_name"`
+	Status       string        `json:"status"`
+	Duration     time.Duration `json:"-"`
+	DurationMS   int64         `json:"duration_ms"`
+	Passed       int           `json:"passed"`
+	Failed       int           `json:"failed"`
+	Skipped      int           `json:"skipped"`
+	// XFailed and XPassed count the job's expected failures that failed and
+	// passed; both count as having run for the job's status.
+	XFailed int `json:"xfailed,omitempty"`
+	XPassed int `json:"xpassed,omitempty"`
+	// Sequence numbers the jobs in the order they started, from one, and
+	// StartedAt is when. Jobs that never started leave both unset; replaying
+	// a report starts its jobs in Sequence order.
+	Sequence  int       `json:"sequence,omitempty"`
+	StartedAt time.Time `json:"started_at,omitzero"`
+	// StepSummary holds the markdown the job's steps wrote to
+	// GITHUB_STEP_SUMMARY.
+	StepSummary string `json:"step_summary,omitempty"`
+}
+
+// JobStatus derives a job's status from its step counts: any failure fails
+// the job, a job with no executed steps is skipped, and anything else passed.
+func JobStatus(passed, failed int) string {
+	switch {
+	case failed > 0:
+		return "failed"
+	case passed > 0:
+		return "passed"
+	default:
+		return "skipped"
+	}
+}
+
+// Add folds a step result into the job's counts, duration, and status.
+// Skipped steps do not contribute to the duration.
+func (j *JobSummary) Add(result StepResult) {
+	switch result.Status {
+	case "passed":
+		j.Passed++
+		j.Duration += result.Duration
+	case "failed":
+		j.Failed++
+		j.Duration += result.Duration
+	case "xfail":
+		j.XFailed++
+		j.Duration += result.Duration
+	case "xpass":
+		j.XPassed++
+		j.Duration += result.Duration
+	case "skipped":
+		j.Skipped++
+	}
+	j.DurationMS = j.Duration.Milliseconds()
+	j.Status = JobStatus(j.Passed+j.XFailed+j.XPassed, j.Failed)
+}
+
+// SummarizeJobs groups results by workflow and job in order of first
+// appearance.
+func SummarizeJobs(results []StepResult) []JobSummary {
+	var jobs []JobSummary
+	index := make(map[[2]string]int)
+	for _, res := range results {
+		k := [2]string{res.WorkflowPath, res.JobName}
+		i, ok := index[k]
+		if !ok {
+			i = len(jobs)
+			index[k] = i
+			jobs = append(jobs, JobSummary{
+				WorkflowPath: res.WorkflowPath,
+				WorkflowName: res.WorkflowName,
+				JobName:      res.JobName,
+				Status:       JobStatus(0, 0),
+			})
+		}
+		jobs[i].Add(res)
+	}
+	return jobs
+}
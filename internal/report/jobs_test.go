@@ -0,0 +1,62 @@
+package report
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJobStatus(t *testing.T) {
+	cases := []struct {
+		name           string
+		passed, failed int
+		want           string
+	}{
+		{"no steps", 0, 0, "skipped"},
+		{"all passed", 3, 0, "passed"},
+		{"any failure", 3, 1, "failed"},
+		{"only failures", 0, 2, "failed"},
+	}
+	for _, tc := range cases {
+		if got := JobStatus(tc.passed, tc.failed); got != tc.want {
+			t.Fatalf("%s: JobStatus(%d, %d) = %q, want %q", tc.name, tc.passed, tc.failed, got, tc.want)
+		}
+	}
+}
+
+func TestSummarizeJobs(t *testing.T) {
+	results := []StepResult{
+		{WorkflowPath: "ci.yml", JobName: "lint", Status: "skipped"},
+		{WorkflowPath: "ci.yml", JobName: "lint", Status: "skipped"},
+		{WorkflowPath: "ci.yml", JobName: "test", Status: "passed", Duration: time.Second},
+		{WorkflowPath: "ci.yml", JobName: "test", Status: "skipped", Duration: time.Minute},
+		{WorkflowPath: "ci.yml", JobName: "build", Status: "passed", Duration: time.Second},
+		{WorkflowPath: "ci.yml", JobName: "build", Status: "failed", Duration: 2 * time.Second},
+		{WorkflowPath: "release.yml", JobName: "lint", Status: "passed"},
+	}
+
+	jobs := SummarizeJobs(results)
+	if len(jobs) != 4 {
+		t.Fatalf("expected 4 jobs, got %+v", jobs)
+	}
+
+	want := []struct {
+		path, name, status string
+		passed, failed     int
+		skipped            int
+		durationMS         int64
+	}{
+		{"ci.yml", "lint", "skipped", 0, 0, 2, 0},
+		{"ci.yml", "test", "passed", 1, 0, 1, 1000},
+		{"ci.yml", "build", "failed", 1, 1, 0, 3000},
+		{"release.yml", "lint", "passed", 1, 0, 0, 0},
+	}
+	for i, w := range want {
+		got := jobs[i]
+		if got.WorkflowPath != w.path || got.JobName != w.name || got.Status != w.status {
+			t.Fatalf("job %d: got %+v, want %s/%s %s", i, got, w.path, w.name, w.status)
+		}
+		if got.Passed != w.passed || got.Failed != w.failed || got.Skipped != w.skipped || got.DurationMS != w.durationMS {
+			t.Fatalf("job %d: unexpected counts %+v", i, got)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package report
+
+import "fmt"
+
+// Meta describes the checkout a report was produced from, so an archived
+// report says what code it ran against. Branch is empty on a detached HEAD.
+type Meta struct {
+	Branch string `json:"branch,omitempty"`
+	SHA    string `json:"sha"`
+	Dirty  bool   `json:"dirty"`
+}
+
+// String formats the checkout as "feature/login@a1b2c3d (dirty)", or just
+// the SHA on a detached HEAD.
+func (m Meta) String() string {
+	s := m.SHA
+	if m.Branch != "" {
+		s = fmt.Sprintf("%s@%s", m.Branch, m.SHA)
+	}
+	if m.Dirty {
+		s += " (dirty)"
+	}
+	return s
+}
@@ -0,0 +1,50 @@
+package report
+
+// Plan actions for a step.
+const (
+	PlanRun  = "run"
+	PlanSkip = "skip"
+	// PlanFail marks a step whose shell or working directory does not
+	// resolve, so a real run would fail it before running anything.
+	PlanFail = "fail"
+)
+
+// PlannedStep is the runner's decision for a step, made without running it.
+type PlannedStep struct {
+	WorkflowPath string `json:"workflow_path"`
+	WorkflowName string `json:"workflow_name"`
+	JobName      string `json:"job_name"`
+	StepName     string `json:"step_name"`
+	StepRun      string `json:"step_run"`
+	Action       string `json:"action"`
+	Overridden   bool   `json:"overridden,omitempty"`
+	SkipReason   string `json:"skip_reason,omitempty"`
+	Detail       string `json:"detail,omitempty"`
+	DuplicateOf  string `json:"duplicate_of,omitempty"`
+}
+
+// Plan is the execution plan for a run: the runner's decision for every
+// selected step and the steps filtering dropped before that.
+type Plan struct {
+	Steps   []PlannedStep `json:"steps"`
+	Dropped []SkippedStep `json:"dropped,omitempty"`
+	Run     int           `json:"run"`
+	Skipped int           `json:"skipped"`
+	Failed  int           `json:"failed"`
+}
+
+// NewPlan counts the actions in steps.
+func NewPlan(steps []PlannedStep, dropped []SkippedStep) Plan {
+	plan := Plan{Steps: steps, Dropped: dropped}
+	for _, s := range steps {
+		switch s.Action {
+		case PlanRun:
+			plan.Run++
+		case PlanFail:
+			plan.Failed++
+		default:
+			plan.Skipped++
+		}
+	}
+	return plan
+}
@@ -1,32 +1,125 @@
 package report
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // StepResult captures the outcome of a single step.
 type StepResult struct {
-	WorkflowPath string        `json:"workflow_path"`
-	WorkflowName string        `json:"workflow_name"`
-	JobName      string        `json:"job_name"`
-	StepName     string        `json:"step_name"`
-	StepRun      string        `json:"step_run"`
-	Status       string        `json:"status"`
-	Duration     time.Duration `json:"-"`
-	DurationMS   int64         `json:"duration_ms"`
-	Stdout       string        `json:"stdout,omitempty"`
-	Stderr       string        `json:"stderr,omitempty"`
-	ExitCode     int           `json:"exit_code"`
-	DryRun       bool          `json:"dry_run"`
+	WorkflowPath string `json:"workflow_path"`
+	WorkflowName string `json:"workflow_name"`
+	JobName      string `json:"job_name"`
+	StepName     string `json:"step_name"`
+	// StepID identifies the step across runs; see provider.StepID.
+	StepID     string        `json:"step_id,omitempty"`
+	StepRun    string        `json:"step_run"`
+	Status     string        `json:"status"`
+	Duration   time.Duration `json:"-"`
+	DurationMS int64         `json:"duration_ms"`
+	// Sequence numbers the steps that ran in the order they started, from
+	// one; StartedAt is when. Steps that never started leave both unset.
+	Sequence  int       `json:"sequence,omitempty"`
+	StartedAt time.Time `json:"started_at,omitzero"`
+	Stdout    string    `json:"stdout,omitempty"`
+	Stderr    string    `json:"stderr,omitempty"`
+	// CombinedOutput is stdout and stderr as one stream, in the order the
+	// step wrote them. It replaces Stdout and Stderr under --combine-output.
+	CombinedOutput string `json:"combined_output,omitempty"`
+	ExitCode       int    `json:"exit_code"`
+	DryRun         bool   `json:"dry_run"`
+	Overridden     bool   `json:"overridden,omitempty"`
+	Teardown       bool   `json:"teardown,omitempty"`
+	// AllowPrivileged is set when a `# testdrive: allow-privileged` comment
+	// exempted the step from the privileged command patterns.
+	AllowPrivileged bool `json:"allow_privileged,omitempty"`
+	// ExpectedFailure is set for steps marked as known to fail locally.
+	// They finish as "xfail" when they fail and "xpass" when they pass, and
+	// neither fails the run.
+	ExpectedFailure bool   `json:"expected_failure,omitempty"`
+	SkipReason      string `json:"skip_reason,omitempty"`
+	SkipDetail      string `json:"skip_detail,omitempty"`
+	DuplicateOf     string `json:"duplicate_of,omitempty"`
+	Hint            string `json:"hint,omitempty"`
+	// FailureClass says whether a failed step failed on the local setup or
+	// on its tests; see the Failure constants.
+	FailureClass string `json:"failure_class,omitempty"`
+	// Annotations point at the source lines a failed step's output blames.
+	Annotations []Annotation `json:"annotations,omitempty"`
+	// EnvSnapshot records, for a failed step, the environment it ran in
+	// with most values masked.
+	EnvSnapshot *EnvSnapshot `json:"env_snapshot,omitempty"`
+	// FlakyScore is the share of the step's recent runs that passed right
+	// after a failure; FlakyRecoveries and FlakyRuns are its parts. Only
+	// steps that history marks as flaky carry them.
+	FlakyScore      float64 `json:"flaky_score,omitempty"`
+	FlakyRecoveries int     `json:"flaky_recoveries,omitempty"`
+	FlakyRuns       int     `json:"flaky_runs,omitempty"`
+}
+
+// Failure classes sort failed steps by what failed.
+const (
+	// FailureEnvironment is a problem with the local setup: a missing tool
+	// or dependency, a version mismatch, a bad working directory, a service
+	// that is not running.
+	FailureEnvironment = "environment"
+	// FailureTest is a test runner reporting failing tests.
+	FailureTest = "test"
+	// FailureUnknown is any other failure.
+	FailureUnknown = "unknown"
+)
+
+// Annotation is a source location named in a failed step's output, such
+// as a failed assertion or the frame a panic came from. Path is relative
+// to the project root when the output's path was inside it.
+type Annotation struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Location is the annotation's path:line, or path:line:column when the
+// column is known.
+func (a Annotation) Location() string {
+	if a.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d", a.Path, a.Line, a.Column)
+	}
+	return fmt.Sprintf("%s:%d", a.Path, a.Line)
 }
 
 // Summary aggregates pipeline execution results.
 type Summary struct {
+	// TotalWorkflows, TotalJobs and TotalSteps count what the discovered
+	// workflows hold; SelectedJobs and SelectedSteps count what is left
+	// after --job and step filters. Steps are run steps only.
 	TotalWorkflows int           `json:"total_workflows"`
 	TotalJobs      int           `json:"total_jobs"`
 	TotalSteps     int           `json:"total_steps"`
+	SelectedJobs   int           `json:"selected_jobs"`
+	SelectedSteps  int           `json:"selected_steps"`
 	Passed         int           `json:"passed"`
 	Failed         int           `json:"failed"`
 	Skipped        int           `json:"skipped"`
 	Duration       time.Duration `json:"-"`
 	DurationMS     int64         `json:"duration_ms"`
 	ExitCode       int           `json:"exit_code"`
+	// Deduped counts steps skipped by --dedupe; DedupeSaved is the runtime
+	// those steps took on their first run.
+	Deduped       int           `json:"deduped,omitempty"`
+	DedupeSaved   time.Duration `json:"-"`
+	DedupeSavedMS int64         `json:"dedupe_saved_ms,omitempty"`
+	// Cancelled counts skipped steps that fail-fast or an interrupted run
+	// stopped before they finished.
+	Cancelled int `json:"cancelled,omitempty"`
+	// FailedEnvironment and FailedTest count the failed steps classed as
+	// FailureEnvironment and FailureTest; the rest are FailureUnknown.
+	FailedEnvironment int `json:"failed_environment,omitempty"`
+	FailedTest        int `json:"failed_test,omitempty"`
+	// XFailed counts expected failures that failed, and XPassed those that
+	// passed. Neither is counted in Passed or Failed.
+	XFailed int `json:"xfailed,omitempty"`
+	XPassed int `json:"xpassed,omitempty"`
+	// Jobs rolls the step results up per job, in execution order.
+	Jobs []JobSummary `json:"jobs,omitempty"`
 }
@@ -0,0 +1,65 @@
+package report
+
+// Repository statuses in a run across a manifest.
+const (
+	RepoPassed = "passed"
+	RepoFailed = "failed"
+	// RepoError marks a repository whose config or workflows could not be
+	// loaded, so nothing in it ran.
+	RepoError = "error"
+	// RepoNotRun marks a repository left out after an earlier one failed
+	// under --fail-fast.
+	RepoNotRun = "not_run"
+)
+
+// RepoRun is one repository's part of a run across a manifest. Its step
+// and job workflow paths carry the repository name as a prefix.
+type RepoRun struct {
+	Name     string       `json:"name"`
+	Path     string       `json:"path"`
+	Status   string       `json:"status"`
+	Error    string       `json:"error,omitempty"`
+	Steps    []StepResult `json:"steps,omitempty"`
+	Summary  Summary      `json:"summary"`
+	Warnings []string     `json:"warnings,omitempty"`
+}
+
+// PrefixPaths puts the repository name in front of every workflow path in
+// the run's steps and job summaries.
+func (r *RepoRun) PrefixPaths() {
+	for i := range r.Steps {
+		r.Steps[i].WorkflowPath = r.Name + "/" + r.Steps[i].WorkflowPath
+	}
+	for i := range r.Summary.Jobs {
+		r.Summary.Jobs[i].WorkflowPath = r.Name + "/" + r.Summary.Jobs[i].WorkflowPath
+	}
+}
+
+// CombineSummaries adds up the summaries of several runs. Jobs are kept in
+// order and the exit code is the first non-zero one.
+func CombineSummaries(summaries ...Summary) Summary {
+	var total Summary
+	for _, s := range summaries {
+		total.TotalWorkflows += s.TotalWorkflows
+		total.TotalJobs += s.TotalJobs
+		total.TotalSteps += s.TotalSteps
+		total.SelectedJobs += s.SelectedJobs
+		total.SelectedSteps += s.SelectedSteps
+		total.Passed += s.Passed
+		total.Failed += s.Failed
+		total.Skipped += s.Skipped
+		total.Duration += s.Duration
+		total.Deduped += s.Deduped
+		total.DedupeSaved += s.DedupeSaved
+		total.Cancelled += s.Cancelled
+		total.FailedEnvironment += s.FailedEnvironment
+		total.FailedTest += s.FailedTest
+		total.Jobs = append(total.Jobs, s.Jobs...)
+		if total.ExitCode == 0 {
+			total.ExitCode = s.ExitCode
+		}
+	}
+	total.DurationMS = total.Duration.Milliseconds()
+	total.DedupeSavedMS = total.DedupeSaved.Milliseconds()
+	return total
+}
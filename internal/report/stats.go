@@ -0,0 +1,229 @@
+package report
+
+import (
+	"sort"
+	"time"
+)
+
+// RunRecord is one recorded run as `testdrive stats` sees it: when it
+// started, how long it took, and what its jobs and steps did.
+type RunRecord struct {
+	StartedAt time.Time
+	Duration  time.Duration
+	ExitCode  int
+	Jobs      []JobRecord
+	Steps     []StepRecord
+}
+
+// JobRecord is a job's outcome within a RunRecord.
+type JobRecord struct {
+	WorkflowPath string
+	JobName      string
+	Status       string
+}
+
+// StepRecord is a step's outcome within a RunRecord.
+type StepRecord struct {
+	WorkflowPath string
+	JobName      string
+	StepName     string
+	StepID       string
+	Status       string
+}
+
+// StatsTop is how many failing steps and jobs Stats lists.
+const StatsTop = 10
+
+// StatsTrendDays is the widest span of runs whose trend is shown per day;
+// wider spans are shown per week.
+const StatsTrendDays = 31
+
+// Stats summarizes local usage over recorded runs.
+type Stats struct {
+	Runs     int       `json:"runs"`
+	Passed   int       `json:"passed"`
+	PassRate float64   `json:"pass_rate"`
+	First    time.Time `json:"first,omitzero"`
+	Last     time.Time `json:"last,omitzero"`
+	// Since is the cutoff runs were filtered by, when one was given.
+	Since         time.Time     `json:"since,omitzero"`
+	AvgDuration   time.Duration `json:"-"`
+	AvgDurationMS int64         `json:"avg_duration_ms"`
+	// Period is "day" or "week", the width of each Trend entry.
+	Period       string         `json:"period"`
+	Trend        []StatsPeriod  `json:"trend"`
+	FailingSteps []StepFailures `json:"failing_steps"`
+	TopJobs      []JobUsage     `json:"top_jobs"`
+}
+
+// StatsPeriod aggregates the runs that started within one day or week.
+type StatsPeriod struct {
+	Start         time.Time     `json:"start"`
+	Runs          int           `json:"runs"`
+	Passed        int           `json:"passed"`
+	PassRate      float64       `json:"pass_rate"`
+	AvgDuration   time.Duration `json:"-"`
+	AvgDurationMS int64         `json:"avg_duration_ms"`
+}
+
+// StepFailures counts how often a step failed out of the runs that
+// executed it.
+type StepFailures struct {
+	WorkflowPath string `json:"workflow_path"`
+	JobName      string `json:"job_name"`
+	StepName     string `json:"step_name"`
+	Failures     int    `json:"failures"`
+	Runs         int    `json:"runs"`
+}
+
+// JobUsage counts the runs that executed a job, and how many of them it
+// failed.
+type JobUsage struct {
+	WorkflowPath string `json:"workflow_path"`
+	JobName      string `json:"job_name"`
+	Runs         int    `json:"runs"`
+	Failed       int    `json:"failed"`
+}
+
+// ComputeStats aggregates runs, in any order. A run passed when it exited
+// zero; jobs and steps count only when they executed, so skips do not inflate
+// how often something is used. Trend buckets are in UTC.
+func ComputeStats(runs []RunRecord) Stats {
+	stats := Stats{
+		Period:       "day",
+		Trend:        []StatsPeriod{},
+		FailingSteps: []StepFailures{},
+		TopJobs:      []JobUsage{},
+	}
+	if len(runs) == 0 {
+		return stats
+	}
+	runs = append([]RunRecord(nil), runs...)
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartedAt.Before(runs[j].StartedAt) })
+	stats.First, stats.Last = runs[0].StartedAt.UTC(), runs[len(runs)-1].StartedAt.UTC()
+	if stats.Last.Sub(stats.First) > StatsTrendDays*24*time.Hour {
+		stats.Period = "week"
+	}
+
+	var total time.Duration
+	var trend []StatsPeriod
+	var trendTotals []time.Duration
+	steps := map[[3]string]*StepFailures{}
+	var stepOrder [][3]string
+	// stepIDs maps a step ID to the key its failures are counted under, and
+	// claimed marks the keys a step ID has taken. A step is first counted by
+	// name, so its runs from before step IDs are folded in.
+	stepIDs := map[string][3]string{}
+	claimed := map[[3]string]bool{}
+	jobs := map[[2]string]*JobUsage{}
+	var jobOrder [][2]string
+	for _, run := range runs {
+		stats.Runs++
+		total += run.Duration
+		passed := run.ExitCode == 0
+		if passed {
+			stats.Passed++
+		}
+
+		start := periodStart(run.StartedAt, stats.Period)
+		if len(trend) == 0 || !trend[len(trend)-1].Start.Equal(start) {
+			trend = append(trend, StatsPeriod{Start: start})
+			trendTotals = append(trendTotals, 0)
+		}
+		period := &trend[len(trend)-1]
+		period.Runs++
+		if passed {
+			period.Passed++
+		}
+		trendTotals[len(trend)-1] += run.Duration
+
+		for _, job := range run.Jobs {
+			if job.Status != "passed" && job.Status != "failed" {
+				continue
+			}
+			k := [2]string{job.WorkflowPath, job.JobName}
+			usage, ok := jobs[k]
+			if !ok {
+				usage = &JobUsage{WorkflowPath: job.WorkflowPath, JobName: job.JobName}
+				jobs[k] = usage
+				jobOrder = append(jobOrder, k)
+			}
+			usage.Runs++
+			if job.Status == "failed" {
+				usage.Failed++
+			}
+		}
+		for _, step := range run.Steps {
+			if step.Status != "passed" && step.Status != "failed" {
+				continue
+			}
+			k := [3]string{step.WorkflowPath, step.JobName, step.StepName}
+			if step.StepID != "" {
+				if prior, ok := stepIDs[step.StepID]; ok {
+					k = prior
+				} else if claimed[k] {
+					k[2] += "\x00" + step.StepID
+				}
+				stepIDs[step.StepID], claimed[k] = k, true
+			}
+			record, ok := steps[k]
+			if !ok {
+				record = &StepFailures{}
+				steps[k] = record
+				stepOrder = append(stepOrder, k)
+			}
+			// Runs are oldest first, so a renamed step shows its newest name.
+			record.WorkflowPath, record.JobName, record.StepName = step.WorkflowPath, step.JobName, step.StepName
+			record.Runs++
+			if step.Status == "failed" {
+				record.Failures++
+			}
+		}
+	}
+
+	stats.PassRate = float64(stats.Passed) / float64(stats.Runs)
+	stats.AvgDuration = total / time.Duration(stats.Runs)
+	stats.AvgDurationMS = stats.AvgDuration.Milliseconds()
+	for i := range trend {
+		trend[i].PassRate = float64(trend[i].Passed) / float64(trend[i].Runs)
+		trend[i].AvgDuration = trendTotals[i] / time.Duration(trend[i].Runs)
+		trend[i].AvgDurationMS = trend[i].AvgDuration.Milliseconds()
+	}
+	stats.Trend = trend
+
+	for _, k := range stepOrder {
+		if steps[k].Failures > 0 {
+			stats.FailingSteps = append(stats.FailingSteps, *steps[k])
+		}
+	}
+	sort.SliceStable(stats.FailingSteps, func(i, j int) bool {
+		a, b := stats.FailingSteps[i], stats.FailingSteps[j]
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+		return a.Runs < b.Runs
+	})
+	if len(stats.FailingSteps) > StatsTop {
+		stats.FailingSteps = stats.FailingSteps[:StatsTop]
+	}
+
+	for _, k := range jobOrder {
+		stats.TopJobs = append(stats.TopJobs, *jobs[k])
+	}
+	sort.SliceStable(stats.TopJobs, func(i, j int) bool { return stats.TopJobs[i].Runs > stats.TopJobs[j].Runs })
+	if len(stats.TopJobs) > StatsTop {
+		stats.TopJobs = stats.TopJobs[:StatsTop]
+	}
+	return stats
+}
+
+// periodStart returns the UTC midnight starting t's day, or the Monday
+// starting its week.
+func periodStart(t time.Time, period string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if period == "week" {
+		day = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return day
+}
@@ -0,0 +1,142 @@
+package report
+
+import (
+	"testing"
+	"time"
+)
+
+// statsFixture builds runs over a few days: lint always passes, while
+// rspec fails on the first day and once more later.
+func statsFixture() []RunRecord {
+	day := func(d, hour int) time.Time { return time.Date(2026, 10, d, hour, 0, 0, 0, time.UTC) }
+	run := func(at time.Time, d time.Duration, rspec string, lintRan bool) RunRecord {
+		exit := 0
+		if rspec == "failed" {
+			exit = 1
+		}
+		r := RunRecord{StartedAt: at, Duration: d, ExitCode: exit}
+		r.Jobs = append(r.Jobs, JobRecord{WorkflowPath: "ci.yml", JobName: "test", Status: rspec})
+		r.Steps = append(r.Steps,
+			StepRecord{WorkflowPath: "ci.yml", JobName: "test", StepName: "setup", Status: "passed"},
+			StepRecord{WorkflowPath: "ci.yml", JobName: "test", StepName: "rspec", Status: rspec},
+		)
+		lint := "skipped"
+		if lintRan {
+			lint = "passed"
+		}
+		r.Jobs = append(r.Jobs, JobRecord{WorkflowPath: "ci.yml", JobName: "lint", Status: lint})
+		r.Steps = append(r.Steps, StepRecord{WorkflowPath: "ci.yml", JobName: "lint", StepName: "rubocop", Status: lint})
+		return r
+	}
+	return []RunRecord{
+		// Out of order on purpose: stats sort by start time.
+		run(day(14, 9), 40*time.Second, "passed", true),
+		run(day(12, 9), 60*time.Second, "failed", true),
+		run(day(12, 15), 120*time.Second, "failed", false),
+		run(day(13, 10), 30*time.Second, "passed", false),
+		run(day(14, 11), 50*time.Second, "failed", true),
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	stats := ComputeStats(statsFixture())
+
+	if stats.Runs != 5 || stats.Passed != 2 || stats.PassRate != 0.4 {
+		t.Fatalf("totals = %d runs, %d passed, rate %v", stats.Runs, stats.Passed, stats.PassRate)
+	}
+	if stats.AvgDuration != 60*time.Second || stats.AvgDurationMS != 60000 {
+		t.Fatalf("average = %s (%dms), want 1m0s", stats.AvgDuration, stats.AvgDurationMS)
+	}
+	if !stats.First.Equal(time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)) || !stats.Last.Equal(time.Date(2026, 10, 14, 11, 0, 0, 0, time.UTC)) {
+		t.Fatalf("span = %s to %s", stats.First, stats.Last)
+	}
+
+	if stats.Period != "day" || len(stats.Trend) != 3 {
+		t.Fatalf("trend = %s %+v, want three days", stats.Period, stats.Trend)
+	}
+	first := stats.Trend[0]
+	if !first.Start.Equal(time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)) || first.Runs != 2 || first.Passed != 0 || first.AvgDuration != 90*time.Second {
+		t.Fatalf("first day = %+v", first)
+	}
+	if last := stats.Trend[2]; last.Runs != 2 || last.PassRate != 0.5 || last.AvgDurationMS != 45000 {
+		t.Fatalf("last day = %+v", last)
+	}
+
+	want := StepFailures{WorkflowPath: "ci.yml", JobName: "test", StepName: "rspec", Failures: 3, Runs: 5}
+	if len(stats.FailingSteps) != 1 || stats.FailingSteps[0] != want {
+		t.Fatalf("failing steps = %+v, want only %+v", stats.FailingSteps, want)
+	}
+
+	wantJobs := []JobUsage{
+		{WorkflowPath: "ci.yml", JobName: "test", Runs: 5, Failed: 3},
+		{WorkflowPath: "ci.yml", JobName: "lint", Runs: 3},
+	}
+	if len(stats.TopJobs) != 2 || stats.TopJobs[0] != wantJobs[0] || stats.TopJobs[1] != wantJobs[1] {
+		t.Fatalf("top jobs = %+v, want %+v (skipped runs not counted)", stats.TopJobs, wantJobs)
+	}
+}
+
+func TestComputeStatsGroupsLongSpansByWeek(t *testing.T) {
+	runs := []RunRecord{
+		{StartedAt: time.Date(2026, 8, 5, 12, 0, 0, 0, time.UTC)}, // Wednesday
+		{StartedAt: time.Date(2026, 8, 9, 23, 0, 0, 0, time.UTC)}, // Sunday, same week
+		{StartedAt: time.Date(2026, 8, 10, 1, 0, 0, 0, time.UTC)}, // Monday
+		{StartedAt: time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)},
+	}
+	stats := ComputeStats(runs)
+	if stats.Period != "week" || len(stats.Trend) != 3 {
+		t.Fatalf("trend = %s %+v, want three weeks", stats.Period, stats.Trend)
+	}
+	if !stats.Trend[0].Start.Equal(time.Date(2026, 8, 3, 0, 0, 0, 0, time.UTC)) || stats.Trend[0].Runs != 2 {
+		t.Fatalf("first week = %+v, want the two runs from Monday 2026-08-03", stats.Trend[0])
+	}
+}
+
+func TestComputeStatsEmpty(t *testing.T) {
+	stats := ComputeStats(nil)
+	if stats.Runs != 0 || stats.Trend == nil || stats.FailingSteps == nil || stats.TopJobs == nil {
+		t.Fatalf("expected zero stats with empty lists, got %+v", stats)
+	}
+}
+
+func TestComputeStatsKeepsTopTen(t *testing.T) {
+	var run RunRecord
+	run.StartedAt = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < StatsTop+5; i++ {
+		name := string(rune('a' + i))
+		run.Jobs = append(run.Jobs, JobRecord{WorkflowPath: "ci.yml", JobName: name, Status: "failed"})
+		run.Steps = append(run.Steps, StepRecord{WorkflowPath: "ci.yml", JobName: name, StepName: "s", Status: "failed"})
+	}
+	stats := ComputeStats([]RunRecord{run})
+	if len(stats.FailingSteps) != StatsTop || len(stats.TopJobs) != StatsTop {
+		t.Fatalf("got %d failing steps and %d jobs, want %d each", len(stats.FailingSteps), len(stats.TopJobs), StatsTop)
+	}
+	if stats.TopJobs[0].JobName != "a" {
+		t.Fatalf("ties should keep first-seen order, got %+v", stats.TopJobs[0])
+	}
+}
+
+func TestComputeStatsMatchesStepIDs(t *testing.T) {
+	at := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	runs := []RunRecord{
+		// Recorded before step IDs.
+		{StartedAt: at, ExitCode: 1, Steps: []StepRecord{{WorkflowPath: "ci.yml", JobName: "test", StepName: "Specs", Status: "failed"}}},
+		{StartedAt: at.Add(time.Hour), ExitCode: 1, Steps: []StepRecord{{WorkflowPath: "ci.yml", JobName: "test", StepName: "Specs", StepID: "a1", Status: "failed"}}},
+		// Renamed, along with its job.
+		{StartedAt: at.Add(2 * time.Hour), ExitCode: 1, Steps: []StepRecord{
+			{WorkflowPath: "ci.yml", JobName: "Test suite", StepName: "System specs", StepID: "a1", Status: "failed"},
+			// A new step took the old name.
+			{WorkflowPath: "ci.yml", JobName: "test", StepName: "Specs", StepID: "b2", Status: "failed"},
+		}},
+	}
+	stats := ComputeStats(runs)
+	if len(stats.FailingSteps) != 2 {
+		t.Fatalf("failing steps = %+v, want two", stats.FailingSteps)
+	}
+	if got := stats.FailingSteps[0]; got.JobName != "Test suite" || got.StepName != "System specs" || got.Failures != 3 || got.Runs != 3 {
+		t.Fatalf("renamed step = %+v, want its three failures under the newest name", got)
+	}
+	if got := stats.FailingSteps[1]; got.StepName != "Specs" || got.Failures != 1 {
+		t.Fatalf("new step = %+v, want one failure of its own", got)
+	}
+}
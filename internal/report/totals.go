@@ -0,0 +1,33 @@
+package report
+
+import "github.com/bgricker/testdrive/internal/provider"
+
+// Totals counts the workflows, jobs, and run steps of a pipeline.
+type Totals struct {
+	Workflows int
+	Jobs      int
+	Steps     int
+}
+
+// CountTotals counts workflows, their jobs, and the steps that have a run
+// script; uses: steps never run locally and are left out.
+func CountTotals(workflows []provider.Workflow) Totals {
+	totals := Totals{Workflows: len(workflows)}
+	for _, wf := range workflows {
+		totals.Jobs += len(wf.Jobs)
+		for _, job := range wf.Jobs {
+			for _, step := range job.Steps {
+				if step.Run != "" {
+					totals.Steps++
+				}
+			}
+		}
+	}
+	return totals
+}
+
+// Apply returns s with its totals set to t.
+func (t Totals) Apply(s Summary) Summary {
+	s.TotalWorkflows, s.TotalJobs, s.TotalSteps = t.Workflows, t.Jobs, t.Steps
+	return s
+}
@@ -0,0 +1,13 @@
+package report
+
+// VersionCheck records one comparison between a pinned tool version and the
+// version installed locally.
+type VersionCheck struct {
+	Tool           string `json:"tool"`
+	Required       string `json:"required"`
+	RequiredSource string `json:"required_source"`
+	Detected       string `json:"detected"`
+	Match          bool   `json:"match"`
+	// Error explains why the installed version could not be detected.
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,286 @@
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// Level names the layer a setting or environment variable came from.
+type Level string
+
+const (
+	LevelDefault  Level = "default"
+	LevelEnvFile  Level = "env_file"
+	LevelRunner   Level = "runner"
+	LevelWorkflow Level = "workflow"
+	LevelJob      Level = "job"
+	LevelStep     Level = "step"
+	LevelOverride Level = "override"
+)
+
+// Shell returns the shell spec for step and the level that set it. An empty
+// spec means the platform default.
+func Shell(wf provider.Workflow, job provider.Job, step provider.Step) (string, Level) {
+	if shell := strings.TrimSpace(step.Shell); shell != "" {
+		return shell, LevelStep
+	}
+	if shell := strings.TrimSpace(job.Defaults.RunShell); shell != "" {
+		return shell, LevelJob
+	}
+	if shell := strings.TrimSpace(wf.Defaults.RunShell); shell != "" {
+		return shell, LevelWorkflow
+	}
+	return "", LevelDefault
+}
+
+// Resolver resolves step commands and working directories, probing each
+// path on disk once and building each asdf init line once. A runner keeps
+// one for the whole run, since neither asdf nor the checkout's directories
+// are expected to change under it. It is safe for concurrent use.
+type Resolver struct {
+	// Paths maps CI paths in working directories to local ones.
+	Paths PathMap
+
+	stat func(name string) (fs.FileInfo, error)
+
+	mu    sync.Mutex
+	stats map[string]statResult
+	inits map[initKey]string
+}
+
+type statResult struct {
+	info fs.FileInfo
+	err  error
+}
+
+// initKey identifies an asdf init line by the shell and the environment
+// values that locate asdf.
+type initKey struct {
+	shell, asdfDir, home string
+}
+
+// NewResolver returns a Resolver that probes the filesystem with stat, or
+// with os.Stat when stat is nil.
+func NewResolver(stat func(name string) (fs.FileInfo, error)) *Resolver {
+	if stat == nil {
+		stat = os.Stat
+	}
+	return &Resolver{stat: stat, stats: map[string]statResult{}, inits: map[initKey]string{}}
+}
+
+// probe stats path, or returns the answer from an earlier probe.
+func (r *Resolver) probe(path string) (fs.FileInfo, error) {
+	r.mu.Lock()
+	res, ok := r.stats[path]
+	r.mu.Unlock()
+	if ok {
+		return res.info, res.err
+	}
+	info, err := r.stat(path)
+	r.mu.Lock()
+	r.stats[path] = statResult{info: info, err: err}
+	r.mu.Unlock()
+	return info, err
+}
+
+// Command returns the argv that runs step with env.
+func Command(wf provider.Workflow, job provider.Job, step provider.Step, env []string) ([]string, error) {
+	return NewResolver(nil).Command(wf, job, step, env)
+}
+
+// Command returns the argv that runs step with env.
+func (r *Resolver) Command(wf provider.Workflow, job provider.Job, step provider.Step, env []string) ([]string, error) {
+	shell, _ := Shell(wf, job, step)
+	return r.CommandArgs(shell, step.Run, env)
+}
+
+// ScriptPlaceholder marks where a shell spec such as `deno run {0}` takes the
+// path of a file holding the script, as on Actions. CommandArgs leaves it in
+// argv; the caller writes the file and puts its path in its place.
+const ScriptPlaceholder = "{0}"
+
+// CommandArgs wraps script for the given shell spec. Login shells source
+// asdf first when it is installed so version-managed tools resolve.
+func CommandArgs(shellSpec string, script string, env []string) ([]string, error) {
+	return NewResolver(nil).CommandArgs(shellSpec, script, env)
+}
+
+// CommandArgs is the package-level CommandArgs with cached probes.
+func (r *Resolver) CommandArgs(shellSpec string, script string, env []string) ([]string, error) {
+	if shellSpec == "" {
+		if runtime.GOOS == "windows" {
+			return []string{"cmd", "/C", script}, nil
+		}
+		// Use bash with login shell and source asdf if available
+		// This ensures tools like asdf, rbenv, etc. work properly
+		init := r.asdfInit(env, "bash")
+		return []string{"bash", "-l", "-c", init + " " + script}, nil
+	}
+
+	fields := strings.Fields(shellSpec)
+	if strings.Contains(shellSpec, ScriptPlaceholder) {
+		// A template names its own program and flags, which need not be a
+		// shell, so it gets neither the asdf line nor -l.
+		return fields, nil
+	}
+	shell := fields[0]
+	args := append([]string{}, fields[1:]...)
+	base := shellBase(shell)
+
+	switch base {
+	case "bash", "zsh", "ksh", "fish":
+		// These shells support login flag, use it for proper environment inheritance
+		init := r.asdfInit(env, base)
+		args = append(args, "-l", "-c", init+" "+script)
+		return append([]string{shell}, args...), nil
+	case "sh":
+		// sh might be dash or another shell that doesn't support -l, use only -c
+		// Also use POSIX-compliant asdf initialization
+		init := r.asdfInit(env, "sh")
+		args = append(args, "-c", init+" "+script)
+		return append([]string{shell}, args...), nil
+	case "cmd":
+		args = append(args, "/C", script)
+		return append([]string{shell}, args...), nil
+	case "pwsh", "powershell":
+		args = append(args, "-Command", script)
+		return append([]string{shell}, args...), nil
+	// Interpreters run the script as written: asdf's init line is shell
+	// syntax they would choke on.
+	case "python", "python3":
+		if strings.Contains(strings.TrimRight(script, "\n"), "\n") {
+			// Multi-line scripts run from a file, as on Actions, so
+			// tracebacks can quote the failing line.
+			args = append(args, ScriptPlaceholder)
+		} else {
+			args = append(args, "-c", script)
+		}
+		return append([]string{shell}, args...), nil
+	case "ruby", "node":
+		args = append(args, "-e", script)
+		return append([]string{shell}, args...), nil
+	default:
+		args = append(args, script)
+		return append([]string{shell}, args...), nil
+	}
+}
+
+// shellBase returns the program name shell runs, lowercased and without an
+// .exe suffix.
+func shellBase(shell string) string {
+	return strings.TrimSuffix(strings.ToLower(filepath.Base(shell)), ".exe")
+}
+
+// WorkingDirectory returns the directory step runs in and the level that
+// set it, checking that it exists. Without any working-directory setting it
+// is root, or the process directory when root is empty.
+func WorkingDirectory(root string, wf provider.Workflow, job provider.Job, step provider.Step) (string, Level, error) {
+	return NewResolver(nil).WorkingDirectory(root, wf, job, step)
+}
+
+// WorkingDirectory is the package-level WorkingDirectory with cached probes.
+// A declared directory under one of r.Paths is rewritten before the check.
+func (r *Resolver) WorkingDirectory(root string, wf provider.Workflow, job provider.Job, step provider.Step) (string, Level, error) {
+	candidate, level := RawWorkingDirectory(wf, job, step)
+	if candidate != "" {
+		if mapped, _, ok := r.Paths.Apply(candidate); ok {
+			candidate = mapped
+		} else if !filepath.IsAbs(candidate) {
+			candidate = filepath.Join(root, candidate)
+		}
+		info, err := r.probe(candidate)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return "", level, fmt.Errorf("working directory %q not found", candidate)
+			}
+			return "", level, fmt.Errorf("stat working directory %q: %w", candidate, err)
+		}
+		if !info.IsDir() {
+			return "", level, fmt.Errorf("working directory %q is not a directory", candidate)
+		}
+		return candidate, level, nil
+	}
+	if root == "" {
+		var err error
+		root, err = os.Getwd()
+		if err != nil {
+			return "", LevelDefault, fmt.Errorf("determine working directory: %w", err)
+		}
+	}
+	return root, LevelDefault, nil
+}
+
+// asdfInit returns the line that sources asdf in shellBase, or "" when
+// asdf is not installed or the shell is not supported.
+func (r *Resolver) asdfInit(env []string, shellBase string) string {
+	key := initKey{shell: shellBase, asdfDir: EnvValue(env, "ASDF_DIR"), home: EnvValue(env, "HOME")}
+	r.mu.Lock()
+	init, ok := r.inits[key]
+	r.mu.Unlock()
+	if ok {
+		return init
+	}
+	init = r.buildAsdfInit(key)
+	r.mu.Lock()
+	r.inits[key] = init
+	r.mu.Unlock()
+	return init
+}
+
+func (r *Resolver) buildAsdfInit(key initKey) string {
+	shellBase := key.shell
+	// Determine asdf script path
+	var asdfPath string
+	// Check ASDF_DIR from environment first
+	if key.asdfDir != "" {
+		// Use filepath.Join for safe path construction and validate the path
+		asdfPath = filepath.Join(key.asdfDir, "asdf.sh")
+		if _, err := r.probe(asdfPath); err != nil {
+			asdfPath = ""
+		}
+	}
+	// Fallback to HOME from environment, then os.UserHomeDir()
+	if asdfPath == "" {
+		home := key.home
+		if home == "" {
+			if homeDir, err := os.UserHomeDir(); err == nil {
+				home = homeDir
+			}
+		}
+		if home != "" {
+			asdfPath = filepath.Join(home, ".asdf", "asdf.sh")
+			if _, err := r.probe(asdfPath); err != nil {
+				asdfPath = ""
+			}
+		}
+	}
+	if asdfPath == "" {
+		return ""
+	}
+	// Return shell-specific initialization string
+	switch shellBase {
+	case "bash", "zsh":
+		return fmt.Sprintf("source %q && ", asdfPath)
+	case "ksh", "sh":
+		return fmt.Sprintf(". %q && ", asdfPath)
+	case "fish":
+		// fish uses different syntax and file extension
+		fishPath := strings.TrimSuffix(asdfPath, ".sh") + ".fish"
+		if _, err := r.probe(fishPath); err == nil {
+			return fmt.Sprintf("source %q; ", fishPath)
+		}
+		// Fallback to bash script if fish version doesn't exist
+		return fmt.Sprintf("source %q; ", asdfPath)
+	default:
+		// For unknown shells, skip asdf initialization to avoid errors
+		return ""
+	}
+}
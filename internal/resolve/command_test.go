@@ -0,0 +1,207 @@
+package resolve
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestShellLevels(t *testing.T) {
+	wf := provider.Workflow{Defaults: provider.Defaults{RunShell: "bash"}}
+	job := provider.Job{Defaults: provider.Defaults{RunShell: "sh"}}
+
+	cases := []struct {
+		name      string
+		wf        provider.Workflow
+		job       provider.Job
+		step      provider.Step
+		wantShell string
+		wantLevel Level
+	}{
+		{name: "step", wf: wf, job: job, step: provider.Step{Shell: "pwsh"}, wantShell: "pwsh", wantLevel: LevelStep},
+		{name: "job", wf: wf, job: job, wantShell: "sh", wantLevel: LevelJob},
+		{name: "workflow", wf: wf, wantShell: "bash", wantLevel: LevelWorkflow},
+		{name: "default", wantShell: "", wantLevel: LevelDefault},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			shell, level := Shell(tc.wf, tc.job, tc.step)
+			if shell != tc.wantShell || level != tc.wantLevel {
+				t.Fatalf("Shell = (%q, %q), want (%q, %q)", shell, level, tc.wantShell, tc.wantLevel)
+			}
+		})
+	}
+}
+
+func TestWorkingDirectoryLevels(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"app", "web"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	wf := provider.Workflow{Defaults: provider.Defaults{WorkingDirectory: "app"}}
+	job := provider.Job{Defaults: provider.Defaults{WorkingDirectory: "web"}}
+
+	dir, level, err := WorkingDirectory(root, wf, job, provider.Step{})
+	if err != nil {
+		t.Fatalf("WorkingDirectory: %v", err)
+	}
+	if dir != filepath.Join(root, "web") || level != LevelJob {
+		t.Fatalf("got (%q, %q), want job-level web", dir, level)
+	}
+
+	dir, level, err = WorkingDirectory(root, provider.Workflow{}, provider.Job{}, provider.Step{})
+	if err != nil || dir != root || level != LevelDefault {
+		t.Fatalf("got (%q, %q, %v), want root at default level", dir, level, err)
+	}
+
+	_, level, err = WorkingDirectory(root, wf, job, provider.Step{WorkingDirectory: "missing"})
+	if err == nil || level != LevelStep {
+		t.Fatalf("expected step-level error for missing directory, got (%q, %v)", level, err)
+	}
+}
+
+func TestCommandArgsSourcesAsdf(t *testing.T) {
+	home := t.TempDir()
+	asdf := filepath.Join(home, ".asdf", "asdf.sh")
+	if err := os.MkdirAll(filepath.Dir(asdf), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(asdf, nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	argv, err := CommandArgs("sh", "make test", []string{"HOME=" + home})
+	if err != nil {
+		t.Fatalf("CommandArgs: %v", err)
+	}
+	want := ". \"" + asdf + "\" &&  make test"
+	if len(argv) != 3 || argv[0] != "sh" || argv[1] != "-c" || argv[2] != want {
+		t.Fatalf("argv = %q, want [sh -c %q]", argv, want)
+	}
+}
+
+func TestResolverProbesEachPathOnce(t *testing.T) {
+	home := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(home, ".asdf"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".asdf", "asdf.sh"), nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "web"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	stats := map[string]int{}
+	r := NewResolver(func(name string) (os.FileInfo, error) {
+		stats[name]++
+		return os.Stat(name)
+	})
+	env := []string{"HOME=" + home}
+
+	for i := 0; i < 3; i++ {
+		for _, shell := range []string{"", "bash", "sh"} {
+			got, err := r.CommandArgs(shell, "make test", env)
+			if err != nil {
+				t.Fatalf("CommandArgs: %v", err)
+			}
+			want, _ := CommandArgs(shell, "make test", env)
+			if strings.Join(got, "\x00") != strings.Join(want, "\x00") {
+				t.Fatalf("cached argv %q differs from %q", got, want)
+			}
+		}
+		if _, _, err := r.WorkingDirectory(root, provider.Workflow{}, provider.Job{}, provider.Step{WorkingDirectory: "web"}); err != nil {
+			t.Fatalf("WorkingDirectory: %v", err)
+		}
+		if _, _, err := r.WorkingDirectory(root, provider.Workflow{}, provider.Job{}, provider.Step{WorkingDirectory: "missing"}); err == nil {
+			t.Fatalf("expected a missing directory to stay an error")
+		}
+	}
+	for name, n := range stats {
+		if n != 1 {
+			t.Fatalf("%s probed %d times, want once", name, n)
+		}
+	}
+	if len(stats) != 3 {
+		t.Fatalf("expected asdf.sh, web, and missing to be probed, got %v", stats)
+	}
+}
+
+func TestCommandArgsInterpreters(t *testing.T) {
+	home := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(home, ".asdf"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".asdf", "asdf.sh"), nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	env := []string{"HOME=" + home}
+	script := "import sys\nprint(sys.version)\n"
+
+	cases := []struct {
+		shell  string
+		script string
+		want   []string
+	}{
+		{shell: "python", script: "print(1)", want: []string{"python", "-c", "print(1)"}},
+		{shell: "python3 -u", script: "print(1)\n", want: []string{"python3", "-u", "-c", "print(1)\n"}},
+		{shell: "python", script: script, want: []string{"python", ScriptPlaceholder}},
+		{shell: "python.exe", script: script, want: []string{"python.exe", ScriptPlaceholder}},
+		{shell: "/usr/bin/python3", script: "print(1)", want: []string{"/usr/bin/python3", "-c", "print(1)"}},
+		{shell: "ruby", script: "puts 1\nputs 2", want: []string{"ruby", "-e", "puts 1\nputs 2"}},
+		{shell: "Ruby.exe -w", script: "puts 1", want: []string{"Ruby.exe", "-w", "-e", "puts 1"}},
+		{shell: "node", script: "console.log(1)", want: []string{"node", "-e", "console.log(1)"}},
+		{shell: "node.exe", script: "console.log(1)", want: []string{"node.exe", "-e", "console.log(1)"}},
+		{shell: "deno run --allow-read {0}", script: "console.log(1)", want: []string{"deno", "run", "--allow-read", ScriptPlaceholder}},
+		{shell: "bash --noprofile --norc -eo pipefail {0}", script: "make", want: []string{"bash", "--noprofile", "--norc", "-eo", "pipefail", ScriptPlaceholder}},
+		{shell: "pwsh.exe", script: "Write-Output 1", want: []string{"pwsh.exe", "-Command", "Write-Output 1"}},
+		{shell: "cmd.exe", script: "echo 1", want: []string{"cmd.exe", "/C", "echo 1"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.shell, func(t *testing.T) {
+			argv, err := CommandArgs(tc.shell, tc.script, env)
+			if err != nil {
+				t.Fatalf("CommandArgs: %v", err)
+			}
+			if strings.Join(argv, "\x00") != strings.Join(tc.want, "\x00") {
+				t.Fatalf("argv = %q, want %q", argv, tc.want)
+			}
+		})
+	}
+
+	argv, err := CommandArgs("bash.exe", "make", env)
+	if err != nil {
+		t.Fatalf("CommandArgs: %v", err)
+	}
+	if len(argv) != 4 || argv[1] != "-l" || argv[2] != "-c" || !strings.HasPrefix(argv[3], "source ") {
+		t.Fatalf("expected bash.exe to run as a login shell with asdf, got %q", argv)
+	}
+}
+
+func TestCommandInterpreterFromAnyLevel(t *testing.T) {
+	env := []string{"HOME=" + t.TempDir()}
+	step := provider.Step{Run: "print('hi')"}
+	want := []string{"python", "-c", "print('hi')"}
+	for name, tc := range map[string]struct {
+		wf   provider.Workflow
+		job  provider.Job
+		step provider.Step
+	}{
+		"workflow": {wf: provider.Workflow{Defaults: provider.Defaults{RunShell: "python"}}, step: step},
+		"job":      {wf: provider.Workflow{Defaults: provider.Defaults{RunShell: "bash"}}, job: provider.Job{Defaults: provider.Defaults{RunShell: "python"}}, step: step},
+		"step":     {job: provider.Job{Defaults: provider.Defaults{RunShell: "bash"}}, step: provider.Step{Run: step.Run, Shell: "python"}},
+	} {
+		argv, err := Command(tc.wf, tc.job, tc.step, env)
+		if err != nil {
+			t.Fatalf("%s: Command: %v", name, err)
+		}
+		if strings.Join(argv, "\x00") != strings.Join(want, "\x00") {
+			t.Fatalf("%s: argv = %q, want %q", name, argv, want)
+		}
+	}
+}
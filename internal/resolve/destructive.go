@@ -0,0 +1,87 @@
+package resolve
+
+import (
+	"regexp"
+	"strings"
+)
+
+// varRefPattern matches $VAR and ${VAR...} references. ${{ }} expressions and
+// $(...) substitutions do not match.
+var varRefPattern = regexp.MustCompile(`^\$(?:\{([A-Za-z_][A-Za-z0-9_]*)([^}]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// nounsetPattern matches `set -u` in any of its spellings; with it the shell
+// stops at the first unset variable instead of expanding it to nothing.
+var nounsetPattern = regexp.MustCompile(`\bset\s+(-[A-Za-z]*u[A-Za-z]*\b|-o\s+nounset\b)`)
+
+// UnguardedRemoval reports the variable that starts the target of a
+// recursive rm in script when that variable is empty in env and nothing in
+// the script guards it. `rm -rf $BUILD_DIR/` with BUILD_DIR unset removes
+// from the filesystem root, which CI never notices because it sets the
+// variable. Guards are the ${VAR:?}, ${VAR:-default} and ${VAR:=default}
+// forms, an assignment or loop variable in the script, and `set -u`.
+func UnguardedRemoval(script string, env []string) (string, bool) {
+	if nounsetPattern.MatchString(script) {
+		return "", false
+	}
+	for _, command := range splitCommands(script) {
+		fields := strings.Fields(command)
+		for i := 0; i < len(fields); i++ {
+			if fields[i] != "rm" && !strings.HasSuffix(fields[i], "/rm") {
+				continue
+			}
+			recursive := false
+			for _, arg := range fields[i+1:] {
+				if arg == "--" {
+					continue
+				}
+				if strings.HasPrefix(arg, "-") {
+					if arg == "--recursive" || !strings.HasPrefix(arg, "--") && strings.ContainsAny(arg, "rR") {
+						recursive = true
+					}
+					continue
+				}
+				if !recursive {
+					break
+				}
+				name, guarded, ok := leadingVariable(arg)
+				if !ok || guarded || assigned(script, name) || EnvValue(env, name) != "" {
+					continue
+				}
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// splitCommands breaks script into simple commands at newlines and the
+// shell's list and pipe operators.
+func splitCommands(script string) []string {
+	return strings.FieldsFunc(script, func(r rune) bool {
+		return r == '\n' || r == ';' || r == '&' || r == '|'
+	})
+}
+
+// leadingVariable returns the variable an rm argument starts with, ignoring
+// quotes, and whether its expansion supplies a default or an error.
+func leadingVariable(arg string) (name string, guarded, ok bool) {
+	arg = strings.TrimLeft(arg, `"'`)
+	match := varRefPattern.FindStringSubmatch(arg)
+	if match == nil {
+		return "", false, false
+	}
+	if match[3] != "" {
+		return match[3], false, true
+	}
+	op := strings.TrimPrefix(match[2], ":")
+	guarded = op != "" && strings.ContainsAny(op[:1], "?-=")
+	return match[1], guarded, true
+}
+
+// assigned reports whether script sets name itself, so its value does not
+// depend on the caller's environment.
+func assigned(script, name string) bool {
+	quoted := regexp.QuoteMeta(name)
+	pattern := regexp.MustCompile(`(^|[\s;&|(])` + quoted + `=|\bfor\s+` + quoted + `\s+in\b|\bread\b[^\n;]*\s` + quoted + `\b`)
+	return pattern.MatchString(script)
+}
@@ -0,0 +1,44 @@
+package resolve
+
+import "testing"
+
+func TestUnguardedRemoval(t *testing.T) {
+	env := []string{"HOME=/home/dev", "EMPTY="}
+	cases := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{name: "braced", script: "rm -rf ${TMP_DIR}/", want: "TMP_DIR"},
+		{name: "bare", script: "rm -rf $BUILD_DIR", want: "BUILD_DIR"},
+		{name: "quoted", script: `rm -r "$CACHE/"*`, want: "CACHE"},
+		{name: "split flags", script: "rm -f -R -- $OUT/dist", want: "OUT"},
+		{name: "long flag", script: "rm --recursive --force $OUT", want: "OUT"},
+		{name: "later command", script: "make clean && rm -rf $OUT/*", want: "OUT"},
+		{name: "empty counts as unset", script: "rm -rf $EMPTY/", want: "EMPTY"},
+		{name: "absolute rm", script: "/bin/rm -rf ${DEST}", want: "DEST"},
+		{name: "set locally", script: "rm -rf $HOME/.cache"},
+		{name: "not recursive", script: "rm -f $LOG_FILE"},
+		{name: "variable not leading", script: "rm -rf build/$TARGET"},
+		{name: "error guard", script: "rm -rf ${TMP_DIR:?}/"},
+		{name: "default guard", script: "rm -rf ${TMP_DIR:-/tmp/build}/"},
+		{name: "assign guard", script: "rm -rf ${TMP_DIR:=/tmp/build}/"},
+		{name: "assigned in script", script: "OUT=$(mktemp -d)\nrm -rf $OUT"},
+		{name: "exported in script", script: "export OUT=dist; rm -rf $OUT"},
+		{name: "loop variable", script: "for d in a b; do rm -rf $d; done"},
+		{name: "read variable", script: "read -r dir < dirs.txt\nrm -rf $dir"},
+		{name: "nounset", script: "set -euo pipefail\nrm -rf $OUT"},
+		{name: "nounset long form", script: "set -o nounset\nrm -rf $OUT"},
+		{name: "expression", script: "rm -rf ${{ runner.temp }}/build"},
+		{name: "command substitution", script: "rm -rf $(pwd)/build"},
+		{name: "rm in a word", script: "npm -rf $OUT"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := UnguardedRemoval(tc.script, env)
+			if got != tc.want || ok != (tc.want != "") {
+				t.Fatalf("UnguardedRemoval(%q) = (%q, %v), want %q", tc.script, got, ok, tc.want)
+			}
+		})
+	}
+}
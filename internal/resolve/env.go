@@ -0,0 +1,260 @@
+package resolve
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// MergeEnv overlays each map onto the KEY=VALUE entries in base, later
+// values winning, and returns the result sorted by key. $VAR and ${VAR} in
+// overlay values expand to the value merged from base and the earlier
+// overlays, so `PATH: $HOME/bin:$PATH` extends the host PATH; see
+// ExpandEnvValue.
+func MergeEnv(base []string, overlays ...map[string]string) []string {
+	envMap := make(map[string]string, len(base)+len(overlays)*4)
+	for _, kv := range base {
+		if idx := strings.Index(kv, "="); idx != -1 {
+			key := kv[:idx]
+			envMap[key] = kv[idx+1:]
+		}
+	}
+	for _, overlay := range overlays {
+		overlayEnv(envMap, overlay)
+	}
+	keys := make([]string, 0, len(envMap))
+	for k := range envMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, fmt.Sprintf("%s=%s", k, envMap[k]))
+	}
+	return out
+}
+
+// overlayEnv expands the values of overlay against env and then stores them
+// in env. Variables set by the same overlay cannot see each other, as in a
+// single workflow env block.
+func overlayEnv(env map[string]string, overlay map[string]string) {
+	expanded := make(map[string]string, len(overlay))
+	for k, v := range overlay {
+		expanded[k] = ExpandEnvValue(v, env)
+	}
+	for k, v := range expanded {
+		env[k] = v
+	}
+}
+
+// ExpandEnvValue replaces $VAR and ${VAR} in value with their values in env;
+// unset variables expand to nothing. $$ is a literal $, and anything else
+// after a $, including ${{ }} expressions, is left alone. On Windows,
+// %VAR% is expanded too when VAR is set.
+func ExpandEnvValue(value string, env map[string]string) string {
+	return expandEnvValue(value, env, runtime.GOOS == "windows")
+}
+
+func expandEnvValue(value string, env map[string]string, percent bool) string {
+	if !strings.ContainsAny(value, "$%") {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '%' && percent {
+			if end := strings.IndexByte(value[i+1:], '%'); end > 0 {
+				if v, ok := env[value[i+1:i+1+end]]; ok {
+					b.WriteString(v)
+					i += end + 1
+					continue
+				}
+			}
+		}
+		if c != '$' || i+1 == len(value) {
+			b.WriteByte(c)
+			continue
+		}
+		next := value[i+1]
+		switch {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(value[i+2:], '}')
+			if end < 0 || !isEnvName(value[i+2:i+2+end]) {
+				b.WriteByte(c)
+				continue
+			}
+			b.WriteString(env[value[i+2:i+2+end]])
+			i += end + 2
+		case isEnvNameStart(next):
+			end := i + 2
+			for end < len(value) && (isEnvNameStart(value[end]) || value[end] >= '0' && value[end] <= '9') {
+				end++
+			}
+			b.WriteString(env[value[i+1:end]])
+			i = end - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isEnvNameStart(c byte) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+func isEnvName(name string) bool {
+	if name == "" || !isEnvNameStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isEnvNameStart(name[i]) && (name[i] < '0' || name[i] > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// EnvValue returns the value of key in a KEY=VALUE list, or "" when unset.
+func EnvValue(env []string, key string) string {
+	for _, kv := range env {
+		if idx := strings.Index(kv, "="); idx != -1 && kv[:idx] == key {
+			return kv[idx+1:]
+		}
+	}
+	return ""
+}
+
+// EnvLayer is a set of variables applied at one level.
+type EnvLayer struct {
+	Level Level
+	Vars  map[string]string
+}
+
+// EnvDiff applies layers over host in order and reports every variable the
+// layers changed, sorted by key, with values expanded as MergeEnv does.
+// Variables set to their host value are not reported.
+func EnvDiff(host []string, layers ...EnvLayer) []report.EnvChange {
+	hostVars := make(map[string]string, len(host))
+	merged := make(map[string]string, len(host))
+	for _, kv := range host {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			hostVars[key] = value
+			merged[key] = value
+		}
+	}
+	final := make(map[string]report.EnvChange)
+	for _, layer := range layers {
+		overlayEnv(merged, layer.Vars)
+		for key := range layer.Vars {
+			final[key] = report.EnvChange{Key: key, Value: merged[key], Level: string(layer.Level)}
+		}
+	}
+	changes := make([]report.EnvChange, 0, len(final))
+	for key, change := range final {
+		prev, ok := hostVars[key]
+		if ok && prev == change.Value {
+			continue
+		}
+		change.Host, change.HostSet = prev, ok
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// ProjectBinDirs returns the node_modules/.bin and bin directories nearest
+// dir, looking in dir and then its parents up to root, in the order they
+// should lead PATH. Only directories that exist are returned. A dir outside
+// root is searched on its own.
+func (r *Resolver) ProjectBinDirs(root, dir string) []string {
+	root, dir = filepath.Clean(root), filepath.Clean(dir)
+	var dirs []string
+	for _, name := range []string{filepath.Join("node_modules", ".bin"), "bin"} {
+		for d := dir; ; d = filepath.Dir(d) {
+			candidate := filepath.Join(d, name)
+			if info, err := r.probe(candidate); err == nil && info.IsDir() {
+				dirs = append(dirs, candidate)
+				break
+			}
+			if rel, err := filepath.Rel(root, d); err != nil || rel == "." || strings.HasPrefix(rel, "..") || d == filepath.Dir(d) {
+				break
+			}
+		}
+	}
+	return dirs
+}
+
+// PrependPath returns env with dirs placed at the front of PATH, in order,
+// and the directories it added. Directories already on PATH are left where
+// they are.
+func PrependPath(env []string, dirs []string) ([]string, []string) {
+	current := EnvValue(env, "PATH")
+	onPath := make(map[string]bool)
+	for _, entry := range filepath.SplitList(current) {
+		onPath[filepath.Clean(entry)] = true
+	}
+	var added []string
+	for _, dir := range dirs {
+		if !onPath[filepath.Clean(dir)] {
+			added = append(added, dir)
+			onPath[filepath.Clean(dir)] = true
+		}
+	}
+	if len(added) == 0 {
+		return env, nil
+	}
+	path := strings.Join(added, string(filepath.ListSeparator))
+	if current != "" {
+		path += string(filepath.ListSeparator) + current
+	}
+	// Replace the entry as is: MergeEnv would expand any $ in PATH.
+	out := make([]string, 0, len(env)+1)
+	replaced := false
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "PATH=") {
+			kv, replaced = "PATH="+path, true
+		}
+		out = append(out, kv)
+	}
+	if !replaced {
+		out = append(out, "PATH="+path)
+		sort.Strings(out)
+	}
+	return out, added
+}
+
+// PathExport returns a statement that puts dirs at the front of PATH, for
+// the start of a script run by shellSpec, or "" when none is needed. Login
+// shells need it because their profile may reset PATH, as Debian's
+// /etc/profile does; other shells keep the PATH they are started with.
+func PathExport(shellSpec string, dirs []string) string {
+	if len(dirs) == 0 {
+		return ""
+	}
+	base := "bash"
+	if fields := strings.Fields(shellSpec); len(fields) > 0 {
+		base = shellBase(fields[0])
+	} else if runtime.GOOS == "windows" {
+		return ""
+	}
+	quoted := make([]string, len(dirs))
+	for i, dir := range dirs {
+		quoted[i] = "'" + strings.ReplaceAll(dir, "'", `'\''`) + "'"
+	}
+	switch base {
+	case "bash", "zsh", "ksh":
+		return "export PATH=" + strings.Join(quoted, ":") + `:"$PATH"; `
+	case "fish":
+		return "set -gx PATH " + strings.Join(quoted, " ") + " $PATH; "
+	default:
+		return ""
+	}
+}
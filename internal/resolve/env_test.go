@@ -0,0 +1,192 @@
+package resolve
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestEnvDiffAttributesLastLevel(t *testing.T) {
+	host := []string{"PATH=/usr/bin", "RAILS_ENV=development", "CI=true"}
+	changes := EnvDiff(host,
+		EnvLayer{Level: LevelWorkflow, Vars: map[string]string{"RAILS_ENV": "test", "CI": "true"}},
+		EnvLayer{Level: LevelJob, Vars: map[string]string{"DATABASE_URL": "postgres://localhost/job"}},
+		EnvLayer{Level: LevelStep, Vars: map[string]string{"RAILS_ENV": "production"}},
+	)
+
+	want := []report.EnvChange{
+		{Key: "DATABASE_URL", Value: "postgres://localhost/job", Level: "job"},
+		{Key: "RAILS_ENV", Value: "production", Level: "step", Host: "development", HostSet: true},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("EnvDiff = %+v, want %+v", changes, want)
+	}
+}
+
+func TestMergeEnvOverlaysInOrder(t *testing.T) {
+	env := MergeEnv([]string{"A=host", "B=host"}, map[string]string{"A": "wf"}, map[string]string{"A": "step", "C": "new"})
+	if got := EnvValue(env, "A"); got != "step" {
+		t.Fatalf("A = %q, want step", got)
+	}
+	if got := EnvValue(env, "B"); got != "host" {
+		t.Fatalf("B = %q, want host", got)
+	}
+	if got := EnvValue(env, "C"); got != "new" {
+		t.Fatalf("C = %q, want new", got)
+	}
+}
+
+func TestMergeEnvExpandsEarlierLevels(t *testing.T) {
+	host := []string{"HOME=/home/dev", "PATH=/usr/bin"}
+	job := map[string]string{"BUNDLE_PATH": "$HOME/vendor", "A": "job", "B": "$A"}
+	step := map[string]string{
+		"GEM_HOME": "${BUNDLE_PATH}/gems",
+		"PATH":     "$HOME/.local/bin:$PATH",
+		"HOME":     "/tmp/home",
+		"CACHE":    "$HOME/.cache",
+	}
+	env := MergeEnv(host, job, step)
+
+	for key, want := range map[string]string{
+		"BUNDLE_PATH": "/home/dev/vendor",
+		"GEM_HOME":    "/home/dev/vendor/gems",
+		"PATH":        "/home/dev/.local/bin:/usr/bin",
+		// Variables set at the same level do not see each other.
+		"B":     "",
+		"CACHE": "/home/dev/.cache",
+		"HOME":  "/tmp/home",
+	} {
+		if got := EnvValue(env, key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestExpandEnvValue(t *testing.T) {
+	env := map[string]string{"HOME": "/home/dev", "USER": "dev", "WIN": `C:\tools`}
+	cases := []struct {
+		value   string
+		percent bool
+		want    string
+	}{
+		{value: "plain", want: "plain"},
+		{value: "$HOME/bin", want: "/home/dev/bin"},
+		{value: "${HOME}bin", want: "/home/devbin"},
+		{value: "$USER_NAME", want: ""},
+		{value: "$$HOME", want: "$HOME"},
+		{value: "cost: 5$", want: "cost: 5$"},
+		{value: "$1 and $-", want: "$1 and $-"},
+		{value: "${HOME:-/root}", want: "${HOME:-/root}"},
+		{value: "${{ github.workspace }}/vendor", want: "${{ github.workspace }}/vendor"},
+		{value: "%WIN%;%PATH%", want: "%WIN%;%PATH%"},
+		{value: "%WIN%;%MISSING%;100%", percent: true, want: `C:\tools;%MISSING%;100%`},
+	}
+	for _, tc := range cases {
+		if got := expandEnvValue(tc.value, env, tc.percent); got != tc.want {
+			t.Errorf("expandEnvValue(%q, percent=%v) = %q, want %q", tc.value, tc.percent, got, tc.want)
+		}
+	}
+}
+
+func TestEnvDiffReportsExpandedValues(t *testing.T) {
+	changes := EnvDiff([]string{"PATH=/usr/bin"},
+		EnvLayer{Level: LevelJob, Vars: map[string]string{"PATH": "/opt/bin:$PATH"}},
+	)
+	want := []report.EnvChange{{Key: "PATH", Value: "/opt/bin:/usr/bin", Level: "job", Host: "/usr/bin", HostSet: true}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("EnvDiff = %+v, want %+v", changes, want)
+	}
+}
+
+func TestProjectBinDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		"bin",
+		"node_modules/.bin",
+		"web/node_modules/.bin",
+		"web/src",
+		"api/bin",
+		"docs",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	// A file named bin is not a directory of binstubs.
+	if err := os.WriteFile(filepath.Join(root, "docs", "bin"), nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	outside := t.TempDir()
+
+	cases := []struct {
+		dir  string
+		want []string
+	}{
+		{dir: root, want: []string{"node_modules/.bin", "bin"}},
+		{dir: "web/src", want: []string{"web/node_modules/.bin", "bin"}},
+		{dir: "api", want: []string{"node_modules/.bin", "api/bin"}},
+		{dir: "docs", want: []string{"node_modules/.bin", "bin"}},
+		{dir: outside, want: nil},
+	}
+	for _, tc := range cases {
+		dir := tc.dir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, filepath.FromSlash(dir))
+		}
+		var want []string
+		for _, w := range tc.want {
+			want = append(want, filepath.Join(root, filepath.FromSlash(w)))
+		}
+		if got := NewResolver(nil).ProjectBinDirs(root, dir); !reflect.DeepEqual(got, want) {
+			t.Errorf("ProjectBinDirs(%s) = %v, want %v", tc.dir, got, want)
+		}
+	}
+
+	empty := t.TempDir()
+	if got := NewResolver(nil).ProjectBinDirs(empty, filepath.Join(empty)); got != nil {
+		t.Fatalf("expected no directories without node_modules or bin, got %v", got)
+	}
+}
+
+func TestPrependPath(t *testing.T) {
+	sep := string(filepath.ListSeparator)
+	env := []string{"HOME=/home/dev", "PATH=/opt/$weird" + sep + "/repo/bin" + sep + "/usr/bin"}
+	got, added := PrependPath(env, []string{"/repo/node_modules/.bin", "/repo/bin"})
+	want := []string{"HOME=/home/dev", "PATH=/repo/node_modules/.bin" + sep + "/opt/$weird" + sep + "/repo/bin" + sep + "/usr/bin"}
+	if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(added, []string{"/repo/node_modules/.bin"}) {
+		t.Fatalf("PrependPath = %v, %v; want %v and the node_modules dir only", got, added, want)
+	}
+
+	if got, added := PrependPath(env, nil); !reflect.DeepEqual(got, env) || added != nil {
+		t.Fatalf("expected env unchanged without dirs, got %v, %v", got, added)
+	}
+	if got, _ := PrependPath([]string{"HOME=/home/dev"}, []string{"/repo/bin"}); !reflect.DeepEqual(got, []string{"HOME=/home/dev", "PATH=/repo/bin"}) {
+		t.Fatalf("expected PATH to be added, got %v", got)
+	}
+}
+
+func TestPathExport(t *testing.T) {
+	dirs := []string{"/repo/web/node_modules/.bin", "/repo/it's/bin"}
+	cases := map[string]string{
+		"":                 `export PATH='/repo/web/node_modules/.bin':'/repo/it'\''s/bin':"$PATH"; `,
+		"zsh --no-rcs {0}": `export PATH='/repo/web/node_modules/.bin':'/repo/it'\''s/bin':"$PATH"; `,
+		"fish":             `set -gx PATH '/repo/web/node_modules/.bin' '/repo/it'\''s/bin' $PATH; `,
+		"sh":               "",
+		"python {0}":       "",
+	}
+	for shell, want := range cases {
+		if runtime.GOOS == "windows" && shell == "" {
+			continue
+		}
+		if got := PathExport(shell, dirs); got != want {
+			t.Errorf("PathExport(%q) = %q, want %q", shell, got, want)
+		}
+	}
+	if got := PathExport("bash", nil); got != "" {
+		t.Errorf("PathExport without dirs = %q, want empty", got)
+	}
+}
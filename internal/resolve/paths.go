@@ -0,0 +1,118 @@
+package resolve
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// PathMapping rewrites paths under From, a directory as CI sees it, to the
+// same place under To locally.
+type PathMapping struct {
+	From string
+	To   string
+}
+
+// PathMap holds the configured mappings, longest From first so the most
+// specific one wins.
+type PathMap []PathMapping
+
+// NewPathMap builds a PathMap from config's from → to entries. Relative
+// targets are resolved against root.
+func NewPathMap(mappings map[string]string, root string) PathMap {
+	m := make(PathMap, 0, len(mappings))
+	for from, to := range mappings {
+		from = strings.TrimRight(slashed(strings.TrimSpace(from)), "/")
+		if from == "" {
+			continue
+		}
+		to = strings.TrimSpace(to)
+		if !isAbsPath(to) && root != "" {
+			to = filepath.Join(root, filepath.FromSlash(to))
+		}
+		m = append(m, PathMapping{From: from, To: to})
+	}
+	sort.Slice(m, func(i, j int) bool {
+		if len(m[i].From) != len(m[j].From) {
+			return len(m[i].From) > len(m[j].From)
+		}
+		return m[i].From < m[j].From
+	})
+	return m
+}
+
+// Apply rewrites path when it lies under one of the mappings, returning the
+// local path and the mapping used. Prefixes only match whole path elements,
+// and either separator is accepted, so C:\work and C:/work are the same.
+func (m PathMap) Apply(path string) (string, PathMapping, bool) {
+	s := slashed(path)
+	for _, mapping := range m {
+		if s != mapping.From && !strings.HasPrefix(s, mapping.From+"/") {
+			continue
+		}
+		rest := strings.TrimPrefix(s[len(mapping.From):], "/")
+		if rest == "" {
+			return mapping.To, mapping, true
+		}
+		return filepath.Join(mapping.To, filepath.FromSlash(rest)), mapping, true
+	}
+	return path, PathMapping{}, false
+}
+
+// MapVars returns vars with every value that is a mapped path rewritten,
+// and the names of the variables that changed. vars itself is not modified.
+func (m PathMap) MapVars(vars map[string]string) (map[string]string, []string) {
+	if len(m) == 0 || len(vars) == 0 {
+		return vars, nil
+	}
+	var out map[string]string
+	var changed []string
+	for key, value := range vars {
+		mapped, _, ok := m.Apply(value)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string, len(vars))
+			for k, v := range vars {
+				out[k] = v
+			}
+		}
+		out[key] = mapped
+		changed = append(changed, key)
+	}
+	if out == nil {
+		return vars, nil
+	}
+	sort.Strings(changed)
+	return out, changed
+}
+
+// RawWorkingDirectory returns the working directory step declares, as
+// written, and the level that set it. It is "" at LevelDefault when no
+// level sets one.
+func RawWorkingDirectory(wf provider.Workflow, job provider.Job, step provider.Step) (string, Level) {
+	candidates := []string{step.WorkingDirectory, job.Defaults.WorkingDirectory, wf.Defaults.WorkingDirectory}
+	levels := []Level{LevelStep, LevelJob, LevelWorkflow}
+	for i, candidate := range candidates {
+		if candidate = strings.TrimSpace(candidate); candidate != "" {
+			return candidate, levels[i]
+		}
+	}
+	return "", LevelDefault
+}
+
+func slashed(path string) string {
+	return strings.ReplaceAll(path, `\`, "/")
+}
+
+// isAbsPath reports whether path is absolute on this platform or is a
+// Windows drive path, which CI may use whatever the local platform.
+func isAbsPath(path string) bool {
+	if filepath.IsAbs(path) {
+		return true
+	}
+	return len(path) >= 3 && path[1] == ':' && (path[2] == '\\' || path[2] == '/')
+}
@@ -0,0 +1,92 @@
+package resolve
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestPathMapApply(t *testing.T) {
+	root := filepath.FromSlash("/src/app")
+	m := NewPathMap(map[string]string{
+		"/home/runner/work/app/app":         ".",
+		"/home/runner/work/app/app/vendor/": "/opt/vendor",
+		`D:\a\app\app`:                      "win",
+	}, root)
+
+	cases := []struct {
+		path string
+		want string
+		from string
+	}{
+		{"/home/runner/work/app/app", root, "/home/runner/work/app/app"},
+		{"/home/runner/work/app/app/backend", filepath.Join(root, "backend"), "/home/runner/work/app/app"},
+		// The longer, nested mapping wins.
+		{"/home/runner/work/app/app/vendor/gems", filepath.Join("/opt/vendor", "gems"), "/home/runner/work/app/app/vendor"},
+		// Either separator matches a Windows prefix.
+		{`D:\a\app\app\web\src`, filepath.Join(root, "win", "web", "src"), "D:/a/app/app"},
+		{"D:/a/app/app/web", filepath.Join(root, "win", "web"), "D:/a/app/app"},
+	}
+	for _, tc := range cases {
+		got, mapping, ok := m.Apply(tc.path)
+		if !ok || got != tc.want || mapping.From != tc.from {
+			t.Errorf("Apply(%q) = %q via %q (%v), want %q via %q", tc.path, got, mapping.From, ok, tc.want, tc.from)
+		}
+	}
+
+	// Prefixes match whole path elements only.
+	for _, path := range []string{"/home/runner/work/app/app2", "/home/runner/work/app", "backend", ""} {
+		if got, _, ok := m.Apply(path); ok || got != path {
+			t.Errorf("Apply(%q) = %q, %v; want it unchanged", path, got, ok)
+		}
+	}
+}
+
+func TestPathMapMapVars(t *testing.T) {
+	m := NewPathMap(map[string]string{"/home/runner/work/app/app": "/src/app"}, "")
+	vars := map[string]string{
+		"APP_DIR":  "/home/runner/work/app/app/tmp",
+		"HOME_DIR": "/home/runner",
+		"MODE":     "test",
+	}
+	got, changed := m.MapVars(vars)
+	if got["APP_DIR"] != filepath.Join("/src/app", "tmp") || got["HOME_DIR"] != "/home/runner" || got["MODE"] != "test" {
+		t.Fatalf("unexpected vars %v", got)
+	}
+	if !reflect.DeepEqual(changed, []string{"APP_DIR"}) {
+		t.Fatalf("changed = %v", changed)
+	}
+	if vars["APP_DIR"] != "/home/runner/work/app/app/tmp" {
+		t.Fatalf("expected the input map left unmodified")
+	}
+}
+
+func TestWorkingDirectoryWithPathMap(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "backend", "api"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	r := NewResolver(nil)
+	r.Paths = NewPathMap(map[string]string{"/home/runner/work/app/app": "."}, root)
+
+	job := provider.Job{Defaults: provider.Defaults{WorkingDirectory: "/home/runner/work/app/app/backend"}}
+	dir, level, err := r.WorkingDirectory(root, provider.Workflow{}, job, provider.Step{})
+	if err != nil || dir != filepath.Join(root, "backend") || level != LevelJob {
+		t.Fatalf("got (%q, %q, %v)", dir, level, err)
+	}
+	dir, _, err = r.WorkingDirectory(root, provider.Workflow{}, job, provider.Step{WorkingDirectory: `/home/runner/work/app/app/backend/api`})
+	if err != nil || dir != filepath.Join(root, "backend", "api") {
+		t.Fatalf("got (%q, %v)", dir, err)
+	}
+	// Relative directories still resolve against root.
+	if dir, _, err = r.WorkingDirectory(root, provider.Workflow{}, provider.Job{}, provider.Step{WorkingDirectory: "backend"}); err != nil || dir != filepath.Join(root, "backend") {
+		t.Fatalf("got (%q, %v)", dir, err)
+	}
+	// Unmapped absolute paths fail as they always have.
+	if _, _, err := r.WorkingDirectory(root, provider.Workflow{}, provider.Job{}, provider.Step{WorkingDirectory: "/home/runner/elsewhere"}); err == nil {
+		t.Fatalf("expected an unmapped directory to fail")
+	}
+}
@@ -0,0 +1,82 @@
+package resolve
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// SkipOptions are the runtime rules that can stop a step from executing.
+type SkipOptions struct {
+	AllowPrivileged     bool
+	PrivilegedPatterns  []string
+	AllowedEnvironments []string
+	// AllowDestructive runs steps matching DestructivePatterns or the
+	// unguarded rm check. It is independent of AllowPrivileged.
+	AllowDestructive    bool
+	DestructivePatterns []string
+	// Env is the environment the step would run with; the unguarded rm
+	// check treats variables missing from it as unset.
+	Env []string
+}
+
+// Skip reports whether step is skipped at run time, with the reason code and
+// a message naming the rule. Skip comments in the workflow win over config
+// overrides, which win over environment rules, which win over privileged
+// command patterns, which win over destructive ones.
+func Skip(job provider.Job, step provider.Step, opts SkipOptions) (reason, msg string, skip bool) {
+	if step.LocalSkip {
+		if step.LocalSkipReason != "" {
+			return report.ReasonComment, "skipped by # testdrive: skip comment: " + step.LocalSkipReason, true
+		}
+		return report.ReasonComment, "skipped by # testdrive: skip comment", true
+	}
+	if step.Skip {
+		return report.ReasonOverride, "skipped by config override", true
+	}
+	if job.Environment != "" && !environmentAllowed(job.Environment, opts.AllowedEnvironments) {
+		return report.ReasonEnvironment, fmt.Sprintf("targets environment '%s'; pass --allow-environment %s to run", job.Environment, job.Environment), true
+	}
+	script := step.Run
+	if !opts.AllowPrivileged && !step.AllowPrivileged {
+		if pattern, ok := matchPattern(script, opts.PrivilegedPatterns); ok {
+			return report.ReasonPrivileged, fmt.Sprintf("skipped privileged command matching pattern %q; set TESTDRIVE_ALLOW_PRIVILEGED=1 to run", pattern), true
+		}
+	}
+	if !opts.AllowDestructive {
+		if pattern, ok := matchPattern(script, opts.DestructivePatterns); ok {
+			return report.ReasonDestructive, fmt.Sprintf("skipped destructive command matching pattern %q; pass --allow-destructive to run", pattern), true
+		}
+		if name, ok := UnguardedRemoval(script, opts.Env); ok {
+			return report.ReasonDestructive, fmt.Sprintf("skipped destructive command: recursive rm of a path starting with $%s, which is unset; pass --allow-destructive to run", name), true
+		}
+	}
+	return "", "", false
+}
+
+// matchPattern returns the first pattern matching script. Empty and invalid
+// patterns never match.
+func matchPattern(script string, patterns []string) (string, bool) {
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		if matched, err := regexp.MatchString(pattern, script); err == nil && matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// environmentAllowed matches names case-insensitively, as GitHub does.
+func environmentAllowed(name string, allowed []string) bool {
+	for _, a := range allowed {
+		if strings.EqualFold(strings.TrimSpace(a), name) {
+			return true
+		}
+	}
+	return false
+}
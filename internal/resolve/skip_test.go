@@ -0,0 +1,63 @@
+package resolve
+
+import (
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestSkipPrecedence(t *testing.T) {
+	opts := SkipOptions{PrivilegedPatterns: []string{`(?i)^sudo\b`}}
+	prod := provider.Job{Environment: "Production"}
+	destructive := SkipOptions{DestructivePatterns: []string{`\bterraform\s+apply\b`}}
+
+	cases := []struct {
+		name   string
+		job    provider.Job
+		step   provider.Step
+		opts   SkipOptions
+		reason string
+	}{
+		{name: "comment beats override", job: prod, step: provider.Step{Run: "sudo deploy", Skip: true, LocalSkip: true}, opts: opts, reason: report.ReasonComment},
+		{name: "override beats environment", job: prod, step: provider.Step{Run: "sudo deploy", Skip: true}, opts: opts, reason: report.ReasonOverride},
+		{name: "environment beats privileged", job: prod, step: provider.Step{Run: "sudo deploy"}, opts: opts, reason: report.ReasonEnvironment},
+		{name: "privileged", step: provider.Step{Run: "sudo apt-get install jq"}, opts: opts, reason: report.ReasonPrivileged},
+		{name: "privileged allowed", step: provider.Step{Run: "sudo apt-get install jq"}, opts: SkipOptions{AllowPrivileged: true, PrivilegedPatterns: opts.PrivilegedPatterns}},
+		{name: "environment allowed", job: prod, step: provider.Step{Run: "make deploy"}, opts: SkipOptions{AllowedEnvironments: []string{"production"}}},
+		{name: "runs", step: provider.Step{Run: "make test"}, opts: opts},
+		{name: "privileged beats destructive", step: provider.Step{Run: "sudo rm -rf $OUT"}, opts: opts, reason: report.ReasonPrivileged},
+		{name: "destructive pattern", step: provider.Step{Run: "terraform apply -auto-approve"}, opts: destructive, reason: report.ReasonDestructive},
+		{name: "destructive variable", step: provider.Step{Run: "rm -rf ${OUT}/"}, opts: destructive, reason: report.ReasonDestructive},
+		{name: "destructive variable set", step: provider.Step{Run: "rm -rf ${OUT}/"}, opts: withEnv(destructive, "OUT=dist")},
+		{name: "allowing privileged keeps destructive", step: provider.Step{Run: "sudo rm -rf $OUT"}, opts: SkipOptions{AllowPrivileged: true, PrivilegedPatterns: opts.PrivilegedPatterns}, reason: report.ReasonDestructive},
+		{name: "allowing destructive keeps privileged", step: provider.Step{Run: "sudo terraform apply"}, opts: SkipOptions{AllowDestructive: true, PrivilegedPatterns: opts.PrivilegedPatterns, DestructivePatterns: destructive.DestructivePatterns}, reason: report.ReasonPrivileged},
+		{name: "privileged allowed by comment", step: provider.Step{Run: "sudo apt-get install jq", AllowPrivileged: true}, opts: opts},
+		{name: "comment keeps destructive", step: provider.Step{Run: "sudo terraform apply", AllowPrivileged: true}, opts: SkipOptions{PrivilegedPatterns: opts.PrivilegedPatterns, DestructivePatterns: destructive.DestructivePatterns}, reason: report.ReasonDestructive},
+		{name: "destructive allowed", step: provider.Step{Run: "terraform apply && rm -rf $OUT"}, opts: SkipOptions{AllowDestructive: true, DestructivePatterns: destructive.DestructivePatterns}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason, msg, skip := Skip(tc.job, tc.step, tc.opts)
+			if skip != (tc.reason != "") || reason != tc.reason {
+				t.Fatalf("Skip = (%q, %q, %v), want reason %q", reason, msg, skip, tc.reason)
+			}
+		})
+	}
+}
+
+func TestSkipCommentReason(t *testing.T) {
+	_, msg, _ := Skip(provider.Job{}, provider.Step{Run: "make deploy", LocalSkip: true, LocalSkipReason: "needs AWS credentials"}, SkipOptions{})
+	if msg != "skipped by # testdrive: skip comment: needs AWS credentials" {
+		t.Fatalf("unexpected message %q", msg)
+	}
+	_, msg, _ = Skip(provider.Job{}, provider.Step{Run: "make deploy", LocalSkip: true}, SkipOptions{})
+	if msg != "skipped by # testdrive: skip comment" {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
+
+func withEnv(opts SkipOptions, env ...string) SkipOptions {
+	opts.Env = env
+	return opts
+}
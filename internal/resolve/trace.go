@@ -0,0 +1,49 @@
+package resolve
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Interpreter reports whether shellSpec runs scripts written in a language
+// other than a shell's: one of the interpreters CommandArgs knows, or a {0}
+// template for a program that is not a shell.
+func Interpreter(shellSpec string) bool {
+	fields := strings.Fields(shellSpec)
+	if len(fields) == 0 {
+		return false
+	}
+	switch shellBase(fields[0]) {
+	case "python", "python3", "ruby", "node":
+		return true
+	case "bash", "zsh", "ksh", "sh", "fish", "cmd", "pwsh", "powershell":
+		return false
+	}
+	return strings.Contains(shellSpec, ScriptPlaceholder)
+}
+
+// TracePrefix returns the line that turns on command tracing for a script
+// run by shellSpec: set -x in POSIX shells, Set-PSDebug -Trace 1 in
+// PowerShell. Tracing starts in the script rather than with a -x flag so
+// the login profile and asdf's init line are not traced too. Other shells
+// cannot be traced.
+func TracePrefix(shellSpec string) (string, error) {
+	base := "bash"
+	if fields := strings.Fields(shellSpec); len(fields) > 0 {
+		base = shellBase(fields[0])
+	} else if runtime.GOOS == "windows" {
+		base = "cmd"
+	}
+	if Interpreter(shellSpec) {
+		return "", fmt.Errorf("%s runs scripts that are not shell commands", shellSpec)
+	}
+	switch base {
+	case "bash", "zsh", "ksh", "sh":
+		return "set -x\n", nil
+	case "pwsh", "powershell":
+		return "Set-PSDebug -Trace 1\n", nil
+	default:
+		return "", fmt.Errorf("%s has no command tracing", base)
+	}
+}
@@ -0,0 +1,59 @@
+package resolve
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestTracePrefix(t *testing.T) {
+	cases := []struct {
+		shell string
+		want  string
+		ok    bool
+	}{
+		{"bash", "set -x\n", true},
+		{"/bin/zsh -e", "set -x\n", true},
+		{"sh", "set -x\n", true},
+		{"bash -eo pipefail {0}", "set -x\n", true},
+		{"pwsh", "Set-PSDebug -Trace 1\n", true},
+		{"/opt/microsoft/powershell/7/pwsh", "Set-PSDebug -Trace 1\n", true},
+		{"python", "", false},
+		{"deno run {0}", "", false},
+		{"cmd", "", false},
+		{"fish", "", false},
+	}
+	if runtime.GOOS != "windows" {
+		cases = append(cases, struct {
+			shell string
+			want  string
+			ok    bool
+		}{"", "set -x\n", true})
+	}
+	for _, tc := range cases {
+		got, err := TracePrefix(tc.shell)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("TracePrefix(%q) = %q, %v; want %q, ok %v", tc.shell, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestInterpreter(t *testing.T) {
+	for shell, want := range map[string]bool{
+		"":                  false,
+		"bash":              false,
+		"pwsh":              false,
+		"cmd":               false,
+		"python3":           true,
+		"ruby -w":           true,
+		"node":              true,
+		"perl {0}":          true,
+		"bash -e {0}":       false,
+		"/usr/bin/python":   true,
+		"node.exe":          true,
+		"/usr/local/bin/sh": false,
+	} {
+		if got := Interpreter(shell); got != want {
+			t.Errorf("Interpreter(%q) = %v, want %v", shell, got, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package resolve
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// varsRef matches a `${{ vars.NAME }}` expression that is nothing but a
+// repository variable reference.
+var varsRef = regexp.MustCompile(`\$\{\{\s*vars\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// InterpolateVars replaces every `${{ vars.NAME }}` in s with its value from
+// vars. Names vars does not hold become the empty string, as they do on CI,
+// and are returned in the order they appear. Other expressions are left
+// alone.
+func InterpolateVars(s string, vars map[string]string) (string, []string) {
+	var missing []string
+	out := varsRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := varsRef.FindStringSubmatch(ref)[1]
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	return out, missing
+}
+
+// ApplyVars returns copies of workflows with `${{ vars.NAME }}` resolved in
+// run scripts, shells, working directories, and env values at every level,
+// and the sorted names of the variables that were referenced but not set.
+// Step names are left as written so filters and step IDs still match.
+func ApplyVars(workflows []provider.Workflow, vars map[string]string) ([]provider.Workflow, []string) {
+	missing := make(map[string]bool)
+	apply := func(s string) string {
+		out, names := InterpolateVars(s, vars)
+		for _, name := range names {
+			missing[name] = true
+		}
+		return out
+	}
+	applyEnv := func(env map[string]string) map[string]string {
+		if len(env) == 0 {
+			return env
+		}
+		out := make(map[string]string, len(env))
+		for key, value := range env {
+			out[key] = apply(value)
+		}
+		return out
+	}
+	applyDefaults := func(d provider.Defaults) provider.Defaults {
+		d.RunShell = apply(d.RunShell)
+		d.WorkingDirectory = apply(d.WorkingDirectory)
+		return d
+	}
+
+	out := make([]provider.Workflow, len(workflows))
+	for i, wf := range workflows {
+		wf.Env = applyEnv(wf.Env)
+		wf.Defaults = applyDefaults(wf.Defaults)
+		jobs := make([]provider.Job, len(wf.Jobs))
+		for j, job := range wf.Jobs {
+			job.Env = applyEnv(job.Env)
+			job.Defaults = applyDefaults(job.Defaults)
+			steps := make([]provider.Step, len(job.Steps))
+			for k, step := range job.Steps {
+				step.Run = apply(step.Run)
+				step.Shell = apply(step.Shell)
+				step.WorkingDirectory = apply(step.WorkingDirectory)
+				step.Env = applyEnv(step.Env)
+				steps[k] = step
+			}
+			job.Steps = steps
+			jobs[j] = job
+		}
+		wf.Jobs = jobs
+		out[i] = wf
+	}
+
+	names := make([]string, 0, len(missing))
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return out, names
+}
@@ -0,0 +1,57 @@
+package resolve
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestInterpolateVars(t *testing.T) {
+	vars := map[string]string{"RAILS_MAX_THREADS": "5", "EMPTY": ""}
+	got, missing := InterpolateVars("threads=${{ vars.RAILS_MAX_THREADS }} ${{vars.EMPTY}}${{ vars.NOPE }} ${{ secrets.TOKEN }}", vars)
+	if want := "threads=5  ${{ secrets.TOKEN }}"; got != want {
+		t.Fatalf("InterpolateVars = %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(missing, []string{"NOPE"}) {
+		t.Fatalf("missing = %v, want [NOPE]", missing)
+	}
+}
+
+func TestApplyVars(t *testing.T) {
+	workflows := []provider.Workflow{{
+		Path:     "ci.yml",
+		Env:      map[string]string{"THREADS": "${{ vars.THREADS }}"},
+		Defaults: provider.Defaults{WorkingDirectory: "${{ vars.APP_DIR }}"},
+		Jobs: []provider.Job{{
+			Name: "test",
+			Env:  map[string]string{"REGION": "${{ vars.REGION }}"},
+			Steps: []provider.Step{{
+				Name:  "Echo ${{ vars.THREADS }}",
+				Run:   "echo ${{ vars.THREADS }} ${{ vars.ZONE }}",
+				Shell: "${{ vars.SHELL }}",
+				Env:   map[string]string{"ZONE": "${{ vars.ZONE }}"},
+			}},
+		}},
+	}}
+	vars := map[string]string{"THREADS": "5", "APP_DIR": "app", "SHELL": "bash"}
+
+	got, missing := ApplyVars(workflows, vars)
+	if !reflect.DeepEqual(missing, []string{"REGION", "ZONE"}) {
+		t.Fatalf("missing = %v, want [REGION ZONE]", missing)
+	}
+	wf := got[0]
+	step := wf.Jobs[0].Steps[0]
+	if wf.Env["THREADS"] != "5" || wf.Defaults.WorkingDirectory != "app" || wf.Jobs[0].Env["REGION"] != "" {
+		t.Fatalf("workflow and job not resolved: %+v", wf)
+	}
+	if step.Run != "echo 5 " || step.Shell != "bash" || step.Env["ZONE"] != "" {
+		t.Fatalf("step not resolved: %+v", step)
+	}
+	if step.Name != "Echo ${{ vars.THREADS }}" {
+		t.Fatalf("step name should be left as written, got %q", step.Name)
+	}
+	if workflows[0].Env["THREADS"] != "${{ vars.THREADS }}" || workflows[0].Jobs[0].Steps[0].Run != "echo ${{ vars.THREADS }} ${{ vars.ZONE }}" {
+		t.Fatalf("ApplyVars modified its input: %+v", workflows[0])
+	}
+}
@@ -0,0 +1,32 @@
+package runner
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// annotations finds the failure locations in a step's output and makes
+// their paths relative to root. Tools print relative paths from the step's
+// working directory, so those gain the directory's place below root.
+// Paths outside root are left as printed.
+func annotations(root, workingDir, out string) []report.Annotation {
+	list := output.Annotations(out)
+	if root == "" {
+		return list
+	}
+	for i, a := range list {
+		path := filepath.FromSlash(a.Path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		list[i].Path = filepath.ToSlash(rel)
+	}
+	return list
+}
@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// cancelWorkflow passes one step, then blocks in a second step until the
+// test cancels the run; a third step never gets to start.
+func cancelWorkflow(t *testing.T) (provider.Workflow, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	started := filepath.Join(t.TempDir(), "started")
+	wf := provider.Workflow{
+		Path: "wf.yml",
+		Name: "workflow",
+		Jobs: []provider.Job{{
+			Name:  "job",
+			RawID: "job",
+			Steps: []provider.Step{
+				{Name: "first", Run: "echo first"},
+				{Name: "block", Run: "touch " + started + " && sleep 30"},
+				{Name: "last", Run: "echo last"},
+			},
+		}},
+	}
+	return wf, started
+}
+
+// cancelWhenStarted cancels the returned context once path exists.
+func cancelWhenStarted(t *testing.T, path string) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		for ctx.Err() == nil {
+			if _, err := os.Stat(path); err == nil {
+				cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	return ctx
+}
+
+func TestRunnerCancelMidRun(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		name := "batch"
+		if streaming {
+			name = "streaming"
+		}
+		t.Run(name, func(t *testing.T) {
+			wf, started := cancelWorkflow(t)
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			buf := &bytes.Buffer{}
+			opts := Options{Root: t.TempDir(), Dedupe: true}
+			if streaming {
+				opts.Streaming = true
+				opts.StreamingRenderer = output.NewStreamingPretty(buf)
+			}
+
+			begin := time.Now()
+			results, summary, err := New(opts).Run(cancelWhenStarted(t, started), []provider.Workflow{wf})
+			if err != nil {
+				t.Fatalf("runner Run: %v", err)
+			}
+			if elapsed := time.Since(begin); elapsed > 20*time.Second {
+				t.Fatalf("expected cancellation to stop the sleeping step, took %s", elapsed)
+			}
+			if len(results) != 3 {
+				t.Fatalf("expected 3 results, got %+v", results)
+			}
+			if results[0].Status != "passed" {
+				t.Fatalf("expected the first step to pass, got %+v", results[0])
+			}
+			want := []string{
+				"cancelled: run interrupted while the step was running",
+				"cancelled: run interrupted",
+			}
+			for i, detail := range want {
+				got := results[i+1]
+				if got.Status != "skipped" || got.SkipReason != report.ReasonCancelled || got.SkipDetail != detail {
+					t.Fatalf("step %q: expected cancelled with %q, got %+v", got.StepName, detail, got)
+				}
+			}
+			if summary.Passed != 1 || summary.Failed != 0 || summary.Skipped != 2 || summary.Cancelled != 2 || summary.ExitCode != 0 {
+				t.Fatalf("unexpected summary: %+v", summary)
+			}
+			if streaming && !strings.Contains(buf.String(), "2 cancelled") {
+				t.Fatalf("expected the streaming summary to count cancelled steps, got:\n%s", buf.String())
+			}
+			if left, _ := os.ReadDir(tmp); runtime.GOOS != "windows" && len(left) > 0 {
+				t.Fatalf("expected the cancelled run to remove its temp files, found %v", left)
+			}
+		})
+	}
+}
+
+func TestRunnerDeadlineExceededBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	workflows := fakeJobs(3, 2)
+
+	results, summary, err := New(Options{Root: t.TempDir(), MaxParallel: 2}).Run(ctx, workflows)
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if len(results) != 6 {
+		t.Fatalf("expected every step to be recorded, got %+v", results)
+	}
+	for _, res := range results {
+		if res.SkipReason != report.ReasonCancelled || res.SkipDetail != "cancelled: deadline exceeded" {
+			t.Fatalf("expected a deadline cancellation, got %+v", res)
+		}
+	}
+	if summary.Cancelled != 6 || summary.Skipped != 6 || len(summary.Jobs) != 3 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
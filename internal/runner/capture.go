@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxCaptureBytes bounds each captured or held-back output stream. Past it
+// the oldest output is dropped, since the end of a log is what explains a
+// failure.
+const maxCaptureBytes = 1 << 20
+
+// cappedBuffer keeps the last limit bytes written to it, cut at a line
+// boundary where one is available. It is safe for concurrent use.
+type cappedBuffer struct {
+	mu      sync.Mutex
+	limit   int
+	buf     []byte
+	dropped int
+}
+
+func newCappedBuffer() *cappedBuffer {
+	return &cappedBuffer{limit: maxCaptureBytes}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.limit; over > 0 {
+		if i := bytes.IndexByte(b.buf[over:], '\n'); i >= 0 {
+			over += i + 1
+		}
+		b.dropped += over
+		b.buf = b.buf[:copy(b.buf, b.buf[over:])]
+	}
+	return len(p), nil
+}
+
+// String returns the kept output.
+func (b *cappedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// Dropped returns how many bytes were discarded to stay within the limit.
+func (b *cappedBuffer) Dropped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// CastHeader is the first line of an asciinema v2 cast. Version and
+// Timestamp are filled in by NewCastRecorder.
+type CastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// CastRecorder writes terminal output as an asciinema v2 cast: the header,
+// then one [seconds, "o", text] event per write, timed from the start of
+// the recording. It is safe for concurrent use.
+type CastRecorder struct {
+	mu    sync.Mutex
+	out   io.Writer
+	clock Clock
+	start time.Time
+	// last is the previous event's time; events never go back before it.
+	last float64
+	// pending holds the start of a UTF-8 sequence the last write cut off.
+	pending []byte
+	err     error
+}
+
+// NewCastRecorder writes header to out and starts timing events. A nil
+// clock uses the wall clock.
+func NewCastRecorder(out io.Writer, header CastHeader, clock Clock) (*CastRecorder, error) {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	c := &CastRecorder{out: out, clock: clock, start: clock.Now()}
+	header.Version = 2
+	header.Timestamp = c.start.Unix()
+	line, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := out.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Writer returns a writer that passes everything to terminal and records
+// what terminal accepted.
+func (c *CastRecorder) Writer(terminal io.Writer) io.Writer {
+	return &castWriter{terminal: terminal, rec: c}
+}
+
+// Close records output still held back and reports the first error
+// writing the cast hit. It does not close the underlying writer.
+func (c *CastRecorder) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) > 0 {
+		pending := c.pending
+		c.pending = nil
+		c.event(pending)
+	}
+	return c.err
+}
+
+// record adds p as an output event. A UTF-8 sequence cut off at the end of
+// p is held back for the next write, since the event text must be valid
+// UTF-8. Newlines become CRLF, as a terminal's line discipline would emit
+// them, so the cast plays back with lines starting at the left margin.
+func (c *CastRecorder) record(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := append(c.pending, p...)
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	c.pending = append([]byte(nil), data[cut:]...)
+	if cut > 0 {
+		c.event(data[:cut])
+	}
+}
+
+// event writes one output event. Callers must hold c.mu.
+func (c *CastRecorder) event(data []byte) {
+	if c.err != nil {
+		return
+	}
+	// Casts time events to the microsecond.
+	at := math.Round(c.clock.Now().Sub(c.start).Seconds()*1e6) / 1e6
+	if at < c.last {
+		at = c.last
+	}
+	c.last = at
+	text := bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+	line, err := json.Marshal([]any{at, "o", string(text)})
+	if err == nil {
+		_, err = c.out.Write(append(line, '\n'))
+	}
+	c.err = err
+}
+
+// castWriter tees writes to a terminal and a CastRecorder.
+type castWriter struct {
+	terminal io.Writer
+	rec      *CastRecorder
+}
+
+func (w *castWriter) Write(p []byte) (int, error) {
+	n, err := w.terminal.Write(p)
+	if n > 0 {
+		w.rec.record(p[:n])
+	}
+	return n, err
+}
@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+type castEvent struct {
+	at   float64
+	kind string
+	text string
+}
+
+// readCast parses a cast, checking that it is one JSON value per line, that
+// the header is asciinema v2, and that events never go back in time.
+func readCast(t *testing.T, data []byte) (CastHeader, []castEvent) {
+	t.Helper()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() {
+		t.Fatalf("empty cast")
+	}
+	var header CastHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatalf("decode header %q: %v", scanner.Text(), err)
+	}
+	if header.Version != 2 || header.Width <= 0 || header.Height <= 0 {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+	var events []castEvent
+	for scanner.Scan() {
+		var raw []any
+		if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil || len(raw) != 3 {
+			t.Fatalf("decode event %q: %v", scanner.Text(), err)
+		}
+		at, ok1 := raw[0].(float64)
+		kind, ok2 := raw[1].(string)
+		text, ok3 := raw[2].(string)
+		if !ok1 || !ok2 || !ok3 {
+			t.Fatalf("malformed event %q", scanner.Text())
+		}
+		if len(events) > 0 && at < events[len(events)-1].at {
+			t.Fatalf("event at %v goes back before %v", at, events[len(events)-1].at)
+		}
+		events = append(events, castEvent{at: at, kind: kind, text: text})
+	}
+	return header, events
+}
+
+func TestCastRecorder(t *testing.T) {
+	clock := newFakeClock()
+	cast := &bytes.Buffer{}
+	rec, err := NewCastRecorder(cast, CastHeader{Width: 100, Height: 30, Title: "testdrive run"}, clock)
+	if err != nil {
+		t.Fatalf("NewCastRecorder: %v", err)
+	}
+	terminal := &bytes.Buffer{}
+	w := rec.Writer(terminal)
+
+	io.WriteString(w, "building\n")
+	clock.Advance(1500 * time.Millisecond)
+	// A check mark split across two writes is recorded whole.
+	mark := []byte("✓ done\n")
+	w.Write(mark[:1])
+	clock.Advance(250 * time.Millisecond)
+	w.Write(mark[1:])
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if terminal.String() != "building\n✓ done\n" {
+		t.Fatalf("terminal got %q", terminal.String())
+	}
+	header, events := readCast(t, cast.Bytes())
+	if header.Width != 100 || header.Height != 30 || header.Title != "testdrive run" || header.Timestamp != 0 {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+	want := []castEvent{{0, "o", "building\r\n"}, {1.75, "o", "✓ done\r\n"}}
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v, want %+v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("event %d = %+v, want %+v", i, events[i], want[i])
+		}
+	}
+}
+
+func TestCastRecorderRecordsRuns(t *testing.T) {
+	wf := sampleWorkflow("make build")
+	wf.Jobs[0].Steps = append(wf.Jobs[0].Steps, provider.Step{Name: "test", Run: "make test"})
+	for _, streaming := range []bool{false, true} {
+		clock := newFakeClock()
+		fake := &fakeExecutor{clock: clock, commands: map[string]fakeCommand{
+			"make build": {stdout: "compiled\n", took: 2 * time.Second},
+			"make test":  {stdout: "ok\n", stderr: "FAIL\n", took: time.Second, exitCode: 1},
+		}}
+		cast := &bytes.Buffer{}
+		rec, err := NewCastRecorder(cast, CastHeader{Width: 80, Height: 24}, clock)
+		if err != nil {
+			t.Fatalf("NewCastRecorder: %v", err)
+		}
+		terminal := &bytes.Buffer{}
+		opts := Options{Root: t.TempDir(), Verbose: true, Clock: clock, Executor: fake, Stdout: rec.Writer(terminal), Stderr: rec.Writer(terminal)}
+		if streaming {
+			opts.Streaming = true
+			opts.StreamingRenderer = output.NewPlainStreamingPretty(opts.Stdout)
+		}
+		if _, _, err := New(opts).Run(context.Background(), []provider.Workflow{wf}); err != nil {
+			t.Fatalf("runner Run: %v", err)
+		}
+		if err := rec.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+
+		_, events := readCast(t, cast.Bytes())
+		var played strings.Builder
+		for _, e := range events {
+			played.WriteString(e.text)
+		}
+		if got, want := played.String(), strings.ReplaceAll(terminal.String(), "\n", "\r\n"); got != want {
+			t.Fatalf("streaming %v: cast plays back %q, terminal got %q", streaming, got, want)
+		}
+		for _, want := range []string{"compiled", "ok", "FAIL"} {
+			if !strings.Contains(played.String(), want) {
+				t.Fatalf("streaming %v: expected %q in the cast, got %q", streaming, want, played.String())
+			}
+		}
+		// The second step writes its output once the first has taken 2s.
+		if last := events[len(events)-1].at; last < 2 {
+			t.Fatalf("streaming %v: expected the last event after the first step, at %v", streaming, last)
+		}
+	}
+}
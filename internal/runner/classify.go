@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"regexp"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+var (
+	// environmentOutput matches output from failures of the local setup
+	// rather than the code: tool or runtime versions that differ from what
+	// the project wants, dependencies that were never installed, and
+	// services that are not running.
+	environmentOutput = []*regexp.Regexp{
+		regexp.MustCompile(`(?m)command not found|: not found$`),
+		regexp.MustCompile(`Your \w+ version is .*, but your Gemfile specified`),
+		regexp.MustCompile(`requires (?:Ruby|ruby|Python|python|rubygems) version`),
+		regexp.MustCompile(`requires a different Python`),
+		regexp.MustCompile(`The engine "\w+" is incompatible`),
+		regexp.MustCompile(`go: go\.mod requires go >=`),
+		regexp.MustCompile(`Bundler::GemNotFound|Could not find .* in (?:locally installed gems|any of the sources)|Run ` + "`bundle install`"),
+		regexp.MustCompile(`Cannot find module '[^./]`),
+		regexp.MustCompile(`ModuleNotFoundError: No module named`),
+		regexp.MustCompile(`ECONNREFUSED|Connection refused|could not connect to server|Can't connect to (?:local )?MySQL server`),
+	}
+
+	// testRunner matches run blocks that invoke a test runner, so their
+	// nonzero exits are test failures.
+	testRunner = regexp.MustCompile(`(?m)(?:^|[\s;&|(])(?:` +
+		`(?:bin/|bundle exec )?(?:rspec|rails test|rake (?:test|spec)|cucumber)` +
+		`|(?:python3? -m )?(?:pytest|unittest|tox|nox)` +
+		`|(?:npx |yarn |pnpm |bun )?(?:jest|vitest|mocha|ava|karma|playwright test|cypress run)` +
+		`|(?:npm|yarn|pnpm|bun)(?: run)? test` +
+		`|(?:go|cargo|mix|dotnet|swift|deno|flutter|dart) test` +
+		`|(?:\./)?(?:gradlew|gradle|mvn)\b.*\b(?:test|check|verify)` +
+		`|phpunit|ctest|make (?:test|check)` +
+		`)\b`)
+)
+
+// classifyFailure sorts a failed step into report.FailureEnvironment,
+// report.FailureTest, or report.FailureUnknown. Anything the runner could
+// explain with a hint (a missing command or script, a taken port, an
+// unresolved expression) and anything the shell could not execute is
+// environmental, as is output naming a version mismatch or missing
+// dependency. An exit of 127 alone is not: scripts and the tools they run
+// exit 127 for their own reasons. Otherwise a step that ran a test runner,
+// or whose output named a failing source location, failed its tests. Steps
+// that could not even be started are classified where they fail.
+func classifyFailure(res report.StepResult) string {
+	if res.ExitCode == exitCannotExecute || res.Hint != "" {
+		return report.FailureEnvironment
+	}
+	out := res.Stderr + "\n" + res.CombinedOutput + "\n" + res.Stdout
+	for _, re := range environmentOutput {
+		if re.MatchString(out) {
+			return report.FailureEnvironment
+		}
+	}
+	if testRunner.MatchString(res.StepRun) || len(res.Annotations) > 0 {
+		return report.FailureTest
+	}
+	return report.FailureUnknown
+}
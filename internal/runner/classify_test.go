@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	cases := []struct {
+		name string
+		res  report.StepResult
+		want string
+	}{
+		{"command not found", report.StepResult{StepRun: "bundle exec rspec", ExitCode: 127, Stderr: "bash: bundle: command not found"}, report.FailureEnvironment},
+		{"not executable", report.StepResult{StepRun: "./bin/test", ExitCode: 126}, report.FailureEnvironment},
+		{"127 from the script", report.StepResult{StepRun: `rm -rf "${TMP_DIR:?}/x"`, ExitCode: 127, Stderr: "rm: cannot remove '/tmp/x': Permission denied"}, report.FailureUnknown},
+		{"hinted", report.StepResult{StepRun: "bin/rails test", ExitCode: 1, Hint: portConflictHint}, report.FailureEnvironment},
+		{"ruby version", report.StepResult{StepRun: "bundle exec rspec", ExitCode: 18, Stderr: "Your Ruby version is 3.1.2, but your Gemfile specified 3.3.0"}, report.FailureEnvironment},
+		{"node engine", report.StepResult{StepRun: "yarn install", ExitCode: 1, Stderr: `error vite@5.0.0: The engine "node" is incompatible with this module.`}, report.FailureEnvironment},
+		{"go toolchain", report.StepResult{StepRun: "go test ./...", ExitCode: 1, Stderr: "go: go.mod requires go >= 1.23 (running go 1.21.5)"}, report.FailureEnvironment},
+		{"missing gems", report.StepResult{StepRun: "bin/rails test", ExitCode: 7, Stderr: "Could not find rails-7.1.3 in locally installed gems"}, report.FailureEnvironment},
+		{"missing python module", report.StepResult{StepRun: "python -m pytest", ExitCode: 1, CombinedOutput: "ModuleNotFoundError: No module named 'django'"}, report.FailureEnvironment},
+		{"database down", report.StepResult{StepRun: "bundle exec rspec", ExitCode: 1, Stdout: "PG::ConnectionBad: could not connect to server: Connection refused"}, report.FailureEnvironment},
+		{"rspec", report.StepResult{StepRun: "bundle exec rspec spec/models", ExitCode: 1, Stdout: "3 examples, 1 failure"}, report.FailureTest},
+		{"go test", report.StepResult{StepRun: "cd api && go test ./...", ExitCode: 1}, report.FailureTest},
+		{"npm test", report.StepResult{StepRun: "npm run test -- --ci", ExitCode: 1}, report.FailureTest},
+		{"gradle", report.StepResult{StepRun: "./gradlew clean test", ExitCode: 1}, report.FailureTest},
+		{"annotated", report.StepResult{StepRun: "make ci", ExitCode: 2, Annotations: []report.Annotation{{Path: "app_test.go", Line: 12}}}, report.FailureTest},
+		{"test-ish words", report.StepResult{StepRun: "echo testing && ./deploy-test-env.sh --latest", ExitCode: 1}, report.FailureUnknown},
+		{"other", report.StepResult{StepRun: "make lint", ExitCode: 2}, report.FailureUnknown},
+	}
+	for _, tc := range cases {
+		if got := classifyFailure(tc.res); got != tc.want {
+			t.Errorf("%s: classifyFailure = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRunnerClassifiesFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	workflows := []provider.Workflow{{Path: "ci.yml", Jobs: []provider.Job{{Name: "test", Steps: []provider.Step{
+		{Name: "Missing tool", Run: "definitely-not-a-testdrive-tool"},
+		{Name: "Specs", Run: "rspec() { exit 1; }; rspec"},
+		{Name: "Other", Run: "exit 3"},
+		{Name: "Script 127", Run: `rm -rf "${TMPDIR:-/tmp}/testdrive-not-there"; exit 127`},
+		{Name: "Fine", Run: "true"},
+	}}}}}
+	results, summary, err := New(Options{Root: t.TempDir()}).Run(context.Background(), workflows)
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	want := []string{report.FailureEnvironment, report.FailureTest, report.FailureUnknown, report.FailureUnknown, ""}
+	for i, res := range results {
+		if res.FailureClass != want[i] {
+			t.Errorf("%s: class %q, want %q", res.StepName, res.FailureClass, want[i])
+		}
+	}
+	if results[3].Hint != "" {
+		t.Errorf("Script 127: hint %q, want none since rm is on PATH", results[3].Hint)
+	}
+	if summary.Failed != 4 || summary.FailedEnvironment != 1 || summary.FailedTest != 1 {
+		t.Fatalf("summary = %d failed, %d environment, %d test", summary.Failed, summary.FailedEnvironment, summary.FailedTest)
+	}
+}
@@ -0,0 +1,157 @@
+package runner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// resultCollector accumulates step results and the run summary. It is safe
+// for concurrent use so jobs executing on separate goroutines can report into
+// a single collector.
+type resultCollector struct {
+	mu      sync.Mutex
+	results []report.StepResult
+	summary report.Summary
+	jobs    []report.JobSummary
+	jobIdx  map[[2]string]int
+	// jobSeq and stepSeq count the jobs and steps started so far.
+	jobSeq  int
+	stepSeq int
+	// totals, when set, replaces the summary's totals.
+	totals report.Totals
+}
+
+func newResultCollector(totalWorkflows int) *resultCollector {
+	return &resultCollector{
+		results: make([]report.StepResult, 0),
+		summary: report.Summary{TotalWorkflows: totalWorkflows},
+		jobIdx:  make(map[[2]string]int),
+	}
+}
+
+// newCollector returns a collector for a run of workflows, reporting the
+// pipeline totals the runner was given.
+func (r *Runner) newCollector(workflows []provider.Workflow) *resultCollector {
+	c := newResultCollector(len(workflows))
+	c.totals = r.opts.Totals
+	return c
+}
+
+// addJob counts job as selected and reserves its rollup, so jobs without
+// any run steps still appear as skipped.
+func (c *resultCollector) addJob(wf provider.Workflow, job provider.Job) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.summary.SelectedJobs++
+	c.job(wf.Path, wf.Name, job.Name)
+}
+
+// job returns the rollup for a job, creating it on first use. Callers must
+// hold c.mu.
+func (c *resultCollector) job(workflowPath, workflowName, jobName string) *report.JobSummary {
+	k := [2]string{workflowPath, jobName}
+	i, ok := c.jobIdx[k]
+	if !ok {
+		i = len(c.jobs)
+		c.jobIdx[k] = i
+		c.jobs = append(c.jobs, report.JobSummary{
+			WorkflowPath: workflowPath,
+			WorkflowName: workflowName,
+			JobName:      jobName,
+			Status:       report.JobStatus(0, 0),
+		})
+	}
+	return &c.jobs[i]
+}
+
+// startJob numbers job as the next one to start, at the given time.
+func (c *resultCollector) startJob(wf provider.Workflow, job provider.Job, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.jobSeq++
+	summary := c.job(wf.Path, wf.Name, job.Name)
+	summary.Sequence, summary.StartedAt = c.jobSeq, at
+}
+
+// startStep numbers result as the next step to start, at the given time.
+func (c *resultCollector) startStep(result *report.StepResult, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stepSeq++
+	result.Sequence, result.StartedAt = c.stepSeq, at
+}
+
+// jobFailed reports whether any step of job has failed so far.
+func (c *resultCollector) jobFailed(wf provider.Workflow, job provider.Job) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.job(wf.Path, wf.Name, job.Name).Failed > 0
+}
+
+// setStepSummary attaches the markdown a job wrote to GITHUB_STEP_SUMMARY.
+func (c *resultCollector) setStepSummary(wf provider.Workflow, job provider.Job, markdown string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.job(wf.Path, wf.Name, job.Name).StepSummary = markdown
+}
+
+// add records result and folds it into the summary totals.
+func (c *resultCollector) add(result report.StepResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.results = append(c.results, result)
+	c.job(result.WorkflowPath, result.WorkflowName, result.JobName).Add(result)
+	c.summary.SelectedSteps++
+	switch result.Status {
+	case "passed":
+		c.summary.Passed++
+		c.summary.Duration += result.Duration
+	case "failed":
+		c.summary.Failed++
+		c.summary.Duration += result.Duration
+		c.summary.ExitCode = 1
+		switch result.FailureClass {
+		case report.FailureEnvironment:
+			c.summary.FailedEnvironment++
+		case report.FailureTest:
+			c.summary.FailedTest++
+		}
+	case "xfail":
+		c.summary.XFailed++
+		c.summary.Duration += result.Duration
+	case "xpass":
+		c.summary.XPassed++
+		c.summary.Duration += result.Duration
+	case "skipped":
+		c.summary.Skipped++
+		if result.SkipReason == report.ReasonCancelled {
+			c.summary.Cancelled++
+		}
+		if result.SkipReason == report.ReasonDuplicate {
+			c.summary.Deduped++
+			c.summary.DedupeSaved += result.Duration
+		}
+	}
+}
+
+// finish returns a copy of the collected results and the summary with its
+// millisecond fields filled in. Without pipeline totals the totals are the
+// selection's.
+func (c *resultCollector) finish() ([]report.StepResult, report.Summary) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	summary := c.summary
+	summary.TotalJobs, summary.TotalSteps = summary.SelectedJobs, summary.SelectedSteps
+	if c.totals != (report.Totals{}) {
+		summary = c.totals.Apply(summary)
+	}
+	summary.DurationMS = summary.Duration.Milliseconds()
+	summary.DedupeSavedMS = summary.DedupeSaved.Milliseconds()
+	summary.Jobs = append([]report.JobSummary{}, c.jobs...)
+	return append([]report.StepResult{}, c.results...), summary
+}
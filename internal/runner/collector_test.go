@@ -0,0 +1,253 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// fakeJobs builds one workflow per job, all sharing the job name "build" so
+// the renderer has to tell them apart by ID.
+func fakeJobs(n, steps int) []provider.Workflow {
+	workflows := make([]provider.Workflow, 0, n)
+	for i := 0; i < n; i++ {
+		job := provider.Job{Name: "build", RawID: "build"}
+		for s := 0; s < steps; s++ {
+			job.Steps = append(job.Steps, provider.Step{
+				Name: fmt.Sprintf("job%d-step%d", i, s),
+				Run:  fmt.Sprintf("echo %d-%d", i, s),
+			})
+		}
+		workflows = append(workflows, provider.Workflow{
+			Path: fmt.Sprintf("wf%d.yml", i),
+			Name: fmt.Sprintf("wf%d", i),
+			Jobs: []provider.Job{job},
+		})
+	}
+	return workflows
+}
+
+// fakeStatus fails the last step of odd jobs, skips the first step of every
+// third job, and passes everything else.
+func fakeStatus(job, step, steps int) string {
+	switch {
+	case job%2 == 1 && step == steps-1:
+		return "failed"
+	case job%3 == 0 && step == 0:
+		return "skipped"
+	default:
+		return "passed"
+	}
+}
+
+func TestResultCollectorAndRendererConcurrentJobs(t *testing.T) {
+	const jobs, steps = 8, 5
+	workflows := fakeJobs(jobs, steps)
+
+	buf := &bytes.Buffer{}
+	renderer := output.NewStreamingPretty(buf)
+	if err := renderer.InitializeAllJobs(workflows); err != nil {
+		t.Fatalf("initialize jobs: %v", err)
+	}
+	collector := newResultCollector(len(workflows))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, jobs)
+	for i, wf := range workflows {
+		wg.Add(1)
+		go func(i int, wf provider.Workflow) {
+			defer wg.Done()
+			job := wf.Jobs[0]
+			jobID := output.JobID(wf, job)
+			collector.addJob(wf, job)
+			if err := renderer.StartJob(jobID); err != nil {
+				errs <- err
+				return
+			}
+			for s, step := range job.Steps {
+				if err := renderer.StartStep(jobID, step.Name); err != nil {
+					errs <- err
+					return
+				}
+				status := fakeStatus(i, s, steps)
+				result := report.StepResult{
+					WorkflowPath: wf.Path,
+					JobName:      job.Name,
+					StepName:     step.Name,
+					StepRun:      step.Run,
+					Status:       status,
+					Duration:     time.Millisecond,
+					Stderr:       "boom",
+				}
+				collector.add(result)
+				if err := renderer.CompleteStep(jobID, step.Name, result); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- renderer.CompleteJob(jobID)
+		}(i, wf)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent job: %v", err)
+		}
+	}
+
+	results, summary := collector.finish()
+	if err := renderer.RenderSummary(summary); err != nil {
+		t.Fatalf("render summary: %v", err)
+	}
+
+	var wantPassed, wantFailed, wantSkipped int
+	for i := 0; i < jobs; i++ {
+		for s := 0; s < steps; s++ {
+			switch fakeStatus(i, s, steps) {
+			case "passed":
+				wantPassed++
+			case "failed":
+				wantFailed++
+			case "skipped":
+				wantSkipped++
+			}
+		}
+	}
+	if len(results) != jobs*steps {
+		t.Fatalf("expected %d results, got %d", jobs*steps, len(results))
+	}
+	if summary.TotalWorkflows != jobs || summary.TotalJobs != jobs || summary.TotalSteps != jobs*steps {
+		t.Fatalf("unexpected totals: %+v", summary)
+	}
+	if summary.Passed != wantPassed || summary.Failed != wantFailed || summary.Skipped != wantSkipped {
+		t.Fatalf("expected %d/%d/%d passed/failed/skipped, got %+v", wantPassed, wantFailed, wantSkipped, summary)
+	}
+	if summary.ExitCode != 1 {
+		t.Fatalf("expected exit code 1, got %d", summary.ExitCode)
+	}
+	if want := time.Duration(wantPassed+wantFailed) * time.Millisecond; summary.Duration != want {
+		t.Fatalf("expected duration %s, got %s", want, summary.Duration)
+	}
+
+	out := buf.String()
+	if strings.Count(out, "SUMMARY:") != 1 || !strings.HasSuffix(out, summaryLineFor(summary)+"\n") {
+		t.Fatalf("expected a single trailing summary line, got:\n%s", out)
+	}
+	// Failed jobs print their step breakdown; each block must stay contiguous
+	// even though the jobs completed concurrently.
+	lines := strings.Split(out, "\n")
+	for i := 1; i < jobs; i += 2 {
+		first := indexOfLine(lines, fmt.Sprintf("job%d-step0 ", i))
+		if first == -1 {
+			t.Fatalf("missing details for failed job %d:\n%s", i, out)
+		}
+		for s := 1; s < steps; s++ {
+			if !strings.Contains(lines[first+s], fmt.Sprintf("job%d-step%d ", i, s)) {
+				t.Fatalf("details for job %d interleaved at line %d:\n%s", i, first+s, out)
+			}
+		}
+		if want := fmt.Sprintf("Command: echo %d-%d", i, steps-1); !strings.Contains(lines[first+steps], want) {
+			t.Fatalf("expected %q after job %d steps, got %q", want, i, lines[first+steps])
+		}
+	}
+	for i := 0; i < jobs; i += 2 {
+		if indexOfLine(lines, fmt.Sprintf("job%d-step0 ", i)) != -1 {
+			t.Fatalf("unexpected details for passing job %d:\n%s", i, out)
+		}
+	}
+}
+
+func TestResultCollectorConcurrentAdds(t *testing.T) {
+	const jobs, steps = 8, 50
+	c := newResultCollector(jobs)
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			path := fmt.Sprintf("wf%d.yml", i)
+			c.addJob(provider.Workflow{Path: path}, provider.Job{Name: "build"})
+			for s := 0; s < steps; s++ {
+				c.add(report.StepResult{WorkflowPath: path, JobName: "build", Status: fakeStatus(i, s, steps), Duration: time.Millisecond})
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	results, summary := c.finish()
+	if len(results) != jobs*steps || summary.TotalSteps != jobs*steps || summary.TotalJobs != jobs {
+		t.Fatalf("lost updates: %d results, summary %+v", len(results), summary)
+	}
+	if summary.Passed+summary.Failed+summary.Skipped != jobs*steps {
+		t.Fatalf("status totals do not add up: %+v", summary)
+	}
+	if len(summary.Jobs) != jobs {
+		t.Fatalf("expected %d job rollups, got %d", jobs, len(summary.Jobs))
+	}
+	for _, job := range summary.Jobs {
+		if job.Passed+job.Failed+job.Skipped != steps {
+			t.Fatalf("%s: expected %d steps, got %+v", job.WorkflowPath, steps, job)
+		}
+	}
+}
+
+func TestResultCollectorCountsDedupeSavings(t *testing.T) {
+	c := newResultCollector(1)
+	c.add(report.StepResult{Status: "passed", Duration: 2 * time.Second})
+	c.add(report.StepResult{Status: "skipped", SkipReason: report.ReasonDuplicate, Duration: 2 * time.Second})
+	c.add(report.StepResult{Status: "skipped", SkipReason: report.ReasonDryRun})
+
+	_, summary := c.finish()
+	if summary.Duration != 2*time.Second || summary.DurationMS != 2000 {
+		t.Fatalf("expected duplicate time excluded from duration, got %+v", summary)
+	}
+	if summary.Deduped != 1 || summary.DedupeSavedMS != 2000 || summary.Skipped != 2 {
+		t.Fatalf("unexpected dedupe totals: %+v", summary)
+	}
+}
+
+func summaryLineFor(summary report.Summary) string {
+	buf := &bytes.Buffer{}
+	_ = output.NewStreamingPretty(buf).RenderSummary(summary)
+	return strings.TrimSpace(buf.String())
+}
+
+func indexOfLine(lines []string, substr string) int {
+	for i, line := range lines {
+		if strings.Contains(line, substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestResultCollectorJobsWithoutRunSteps(t *testing.T) {
+	c := newResultCollector(1)
+	wf := provider.Workflow{Path: "ci.yml", Name: "CI"}
+	c.addJob(wf, provider.Job{Name: "setup"})
+	c.addJob(wf, provider.Job{Name: "test"})
+	c.add(report.StepResult{WorkflowPath: "ci.yml", WorkflowName: "CI", JobName: "test", Status: "failed"})
+
+	_, summary := c.finish()
+	if len(summary.Jobs) != 2 {
+		t.Fatalf("expected 2 job rollups, got %+v", summary.Jobs)
+	}
+	if got := summary.Jobs[0]; got.JobName != "setup" || got.Status != "skipped" {
+		t.Fatalf("expected job without run steps to be skipped, got %+v", got)
+	}
+	if got := summary.Jobs[1]; got.JobName != "test" || got.Status != "failed" || got.Failed != 1 {
+		t.Fatalf("expected failed job rollup, got %+v", got)
+	}
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+var groupExpression = regexp.MustCompile(`\$\{\{\s*(.*?)\s*\}\}`)
+
+// expandGroup interpolates the github context values a local run can
+// supply into a concurrency group. Operands of || fall through to the next
+// one when empty, as on Actions. Unknown expressions are kept verbatim so
+// two workflows using the same one still share a group.
+func expandGroup(group string, wf provider.Workflow, job provider.Job, ref string) string {
+	return groupExpression.ReplaceAllStringFunc(group, func(expr string) string {
+		inner := groupExpression.FindStringSubmatch(expr)[1]
+		for _, operand := range strings.Split(inner, "||") {
+			value, known := groupContextValue(strings.TrimSpace(operand), wf, job, ref)
+			if !known {
+				return expr
+			}
+			if value != "" {
+				return value
+			}
+		}
+		return ""
+	})
+}
+
+func groupContextValue(operand string, wf provider.Workflow, job provider.Job, ref string) (string, bool) {
+	if len(operand) >= 2 && operand[0] == '\'' && operand[len(operand)-1] == '\'' {
+		return operand[1 : len(operand)-1], true
+	}
+	switch operand {
+	case "github.ref":
+		return ref, true
+	case "github.ref_name":
+		return strings.TrimPrefix(strings.TrimPrefix(ref, "refs/heads/"), "refs/tags/"), true
+	case "github.head_ref", "github.base_ref":
+		// Only set for pull_request events, which a local run never is.
+		return "", true
+	case "github.workflow":
+		return wf.Name, true
+	case "github.job":
+		return job.RawID, true
+	case "github.run_id", "github.run_number":
+		// A local run is a single run, so every job shares one id.
+		return "local", true
+	case "github.event_name":
+		return "push", true
+	}
+	return "", false
+}
+
+// concurrencyClaim is a group a job must hold while it runs. Workflow-level
+// groups are owned by the whole workflow until its last job finishes, so two
+// workflows sharing a group never overlap.
+type concurrencyClaim struct {
+	group string
+	owner string
+}
+
+func concurrencyClaims(wf provider.Workflow, job provider.Job, ref string) []concurrencyClaim {
+	var claims []concurrencyClaim
+	var wfGroup string
+	if wf.Concurrency != nil {
+		wfGroup = expandGroup(wf.Concurrency.Group, wf, job, ref)
+		claims = append(claims, concurrencyClaim{group: wfGroup, owner: "workflow:" + wf.Path})
+	}
+	if job.Concurrency != nil {
+		// A job sharing its workflow's group is already covered; claiming it
+		// again would wait on its own workflow.
+		if group := expandGroup(job.Concurrency.Group, wf, job, ref); group != wfGroup {
+			claims = append(claims, concurrencyClaim{group: group, owner: "job:" + output.JobID(wf, job)})
+		}
+	}
+	return claims
+}
+
+// CancelInProgressGroups returns the expanded groups that set
+// cancel-in-progress. Nothing is ever cancelled locally; callers use this
+// to tell the user so.
+func CancelInProgressGroups(workflows []provider.Workflow, ref string) []string {
+	var groups []string
+	seen := make(map[string]bool)
+	add := func(c *provider.Concurrency, wf provider.Workflow, job provider.Job) {
+		if c == nil || !c.CancelInProgress {
+			return
+		}
+		group := expandGroup(c.Group, wf, job, ref)
+		if !seen[group] {
+			seen[group] = true
+			groups = append(groups, group)
+		}
+	}
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			add(wf.Concurrency, wf, job)
+			add(job.Concurrency, wf, job)
+		}
+	}
+	return groups
+}
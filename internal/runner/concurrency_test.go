@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestExpandGroup(t *testing.T) {
+	wf := provider.Workflow{Path: "deploy.yml", Name: "Deploy"}
+	job := provider.Job{RawID: "ship"}
+	cases := []struct {
+		group string
+		want  string
+	}{
+		{"deploy-${{ github.ref }}", "deploy-refs/heads/main"},
+		{"${{ github.workflow }}-${{ github.ref_name }}", "Deploy-main"},
+		{"${{ github.head_ref || github.run_id }}", "local"},
+		{"${{ github.head_ref || 'fallback' }}-${{github.job}}", "fallback-ship"},
+		{"pages-${{ inputs.env }}", "pages-${{ inputs.env }}"},
+		{"static", "static"},
+	}
+	for _, tc := range cases {
+		if got := expandGroup(tc.group, wf, job, "refs/heads/main"); got != tc.want {
+			t.Fatalf("expandGroup(%q) = %q, want %q", tc.group, got, tc.want)
+		}
+	}
+}
+
+func TestConcurrencyClaims(t *testing.T) {
+	wf := provider.Workflow{Path: "a.yml", Concurrency: &provider.Concurrency{Group: "deploy-${{ github.ref }}"}}
+	job := provider.Job{RawID: "db", Concurrency: &provider.Concurrency{Group: "db"}}
+	got := concurrencyClaims(wf, job, "refs/heads/main")
+	want := []concurrencyClaim{
+		{group: "deploy-refs/heads/main", owner: "workflow:a.yml"},
+		{group: "db", owner: "job:a.yml#db"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("claims = %+v, want %+v", got, want)
+	}
+
+	job.Concurrency.Group = "deploy-${{ github.ref }}"
+	if got := concurrencyClaims(wf, job, "refs/heads/main"); len(got) != 1 {
+		t.Fatalf("job repeating its workflow group should not claim it twice, got %+v", got)
+	}
+}
+
+func TestCancelInProgressGroups(t *testing.T) {
+	workflows := []provider.Workflow{
+		{Path: "a.yml", Concurrency: &provider.Concurrency{Group: "deploy-${{ github.ref }}", CancelInProgress: true}, Jobs: []provider.Job{{RawID: "one"}, {RawID: "two"}}},
+		{Path: "b.yml", Concurrency: &provider.Concurrency{Group: "docs"}, Jobs: []provider.Job{{RawID: "build"}}},
+	}
+	got := CancelInProgressGroups(workflows, "refs/heads/main")
+	if !reflect.DeepEqual(got, []string{"deploy-refs/heads/main"}) {
+		t.Fatalf("CancelInProgressGroups = %v", got)
+	}
+}
@@ -0,0 +1,187 @@
+package runner
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/bgricker/testdrive/internal/resolve"
+)
+
+// dedupeTracker remembers passed steps by key so identical later steps can
+// reuse their outcome instead of running again. A nil tracker disables
+// deduplication. It is safe for concurrent use.
+type dedupeTracker struct {
+	mu     sync.Mutex
+	passed map[string]report.StepResult
+}
+
+func (r *Runner) newDedupeTracker() *dedupeTracker {
+	if !r.opts.Dedupe {
+		return nil
+	}
+	return &dedupeTracker{passed: make(map[string]report.StepResult)}
+}
+
+func (d *dedupeTracker) key(root string, wf provider.Workflow, job provider.Job, step provider.Step) string {
+	if d == nil {
+		return ""
+	}
+	return stepKey(root, wf, job, step)
+}
+
+func (d *dedupeTracker) lookup(key string) (report.StepResult, bool) {
+	if d == nil {
+		return report.StepResult{}, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res, ok := d.passed[key]
+	return res, ok
+}
+
+// record stores result under key. Only passed steps are remembered so a
+// failure is always retried by later duplicates.
+func (d *dedupeTracker) record(key string, result report.StepResult) {
+	if d == nil || result.Status != "passed" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.passed[key]; ok {
+		return
+	}
+	d.passed[key] = result
+}
+
+// markDuplicate turns result into a skipped step that mirrors prior's exit
+// code and duration.
+func markDuplicate(result *report.StepResult, prior report.StepResult) {
+	origin := dedupeOrigin(prior)
+	result.Status = "skipped"
+	result.SkipReason = report.ReasonDuplicate
+	result.DuplicateOf = origin
+	result.SkipDetail = "duplicate of " + origin
+	result.ExitCode = prior.ExitCode
+	result.Duration = prior.Duration
+	result.DurationMS = prior.DurationMS
+}
+
+func dedupeOrigin(res report.StepResult) string {
+	workflow := res.WorkflowName
+	if workflow == "" {
+		workflow = res.WorkflowPath
+	}
+	return fmt.Sprintf("%s/%s/%s", workflow, res.JobName, res.StepName)
+}
+
+// stepKey identifies what a step would execute: the shell, the normalized
+// script, the working directory, and the workflow/job/step env. The host
+// environment is shared by every step and is left out.
+func stepKey(root string, wf provider.Workflow, job provider.Job, step provider.Step) string {
+	shell, _ := resolve.Shell(wf, job, step)
+	parts := []string{
+		strings.Join(strings.Fields(shell), " "),
+		stepWorkingDir(root, wf, job, step),
+		normalizeScript(step.Run),
+	}
+	parts = append(parts, literalEnv(wf.Env, job.Env, step.Env)...)
+	return strings.Join(parts, "\x00")
+}
+
+// literalEnv merges the env layers without expanding $VAR references, sorted
+// so map ordering never affects the key. Expanding them here, without the
+// host environment, would make `$HOME/cache` and `/cache` look identical.
+func literalEnv(layers ...map[string]string) []string {
+	merged := make(map[string]string)
+	for _, layer := range layers {
+		for k, v := range layer {
+			merged[k] = v
+		}
+	}
+	out := make([]string, 0, len(merged))
+	for k, v := range merged {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// stepWorkingDir mirrors resolve.WorkingDirectory without touching the
+// filesystem, so keys can be computed for directories that do not exist yet.
+func stepWorkingDir(root string, wf provider.Workflow, job provider.Job, step provider.Step) string {
+	for _, candidate := range []string{step.WorkingDirectory, job.Defaults.WorkingDirectory, wf.Defaults.WorkingDirectory} {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		if !filepath.IsAbs(candidate) {
+			candidate = filepath.Join(root, candidate)
+		}
+		return filepath.Clean(candidate)
+	}
+	if root == "" {
+		return ""
+	}
+	return filepath.Clean(root)
+}
+
+// normalizeScript collapses insignificant whitespace: runs of blanks become a
+// single space, blank lines and surrounding whitespace are dropped, and quoted
+// strings are left untouched.
+func normalizeScript(script string) string {
+	var b strings.Builder
+	var quote rune
+	escaped := false
+	pendingSpace, pendingNewline := false, false
+
+	for _, r := range script {
+		if quote != 0 {
+			b.WriteRune(r)
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\' && quote == '"':
+				escaped = true
+			case r == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch r {
+		case '\n':
+			pendingNewline = true
+			continue
+		case ' ', '\t', '\r':
+			pendingSpace = true
+			continue
+		}
+
+		if b.Len() > 0 {
+			if pendingNewline {
+				b.WriteByte('\n')
+			} else if pendingSpace {
+				b.WriteByte(' ')
+			}
+		}
+		pendingSpace, pendingNewline = false, false
+
+		b.WriteRune(r)
+		if escaped {
+			escaped = false
+			continue
+		}
+		switch r {
+		case '\\':
+			escaped = true
+		case '\'', '"':
+			quote = r
+		}
+	}
+	return b.String()
+}
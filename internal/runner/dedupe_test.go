@@ -0,0 +1,185 @@
+package runner
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestNormalizeScript(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"surrounding whitespace", "  bundle exec rubocop\n", "bundle exec rubocop", true},
+		{"inner blanks and tabs", "bundle   exec\trubocop", "bundle exec rubocop", true},
+		{"blank lines and indentation", "\n  make deps\n\n\tmake test  \n", "make deps\nmake test", true},
+		{"crlf line endings", "make deps\r\nmake test\r\n", "make deps\nmake test", true},
+		{"line boundaries matter", "make deps\nmake test", "make deps make test", false},
+		{"double quoted blanks kept", `echo "a  b"`, `echo "a b"`, false},
+		{"single quoted blanks kept", "echo 'a  b'", "echo 'a b'", false},
+		{"escaped quote stays quoted", `echo "say \"hi\"  there"`, `echo "say \"hi\" there"`, false},
+		{"blanks after closing quote", `echo "a"   b`, `echo "a" b`, true},
+		{"different commands", "npm test", "npm run test", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := normalizeScript(tc.a) == normalizeScript(tc.b)
+			if got != tc.same {
+				t.Fatalf("normalizeScript(%q)=%q, normalizeScript(%q)=%q; want same=%v", tc.a, normalizeScript(tc.a), tc.b, normalizeScript(tc.b), tc.same)
+			}
+		})
+	}
+}
+
+func TestStepKey(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	base := func() (provider.Workflow, provider.Job, provider.Step) {
+		wf := provider.Workflow{Name: "wf", Env: map[string]string{"CI": "true"}}
+		job := provider.Job{Name: "lint"}
+		step := provider.Step{Name: "Rubocop", Run: "bundle exec rubocop", Env: map[string]string{"A": "1", "B": "2"}}
+		return wf, job, step
+	}
+	wf, job, step := base()
+	want := stepKey(root, wf, job, step)
+
+	t.Run("names do not matter", func(t *testing.T) {
+		wf, job, step := base()
+		wf.Name, job.Name, step.Name = "other", "test", "Lint Ruby"
+		if stepKey(root, wf, job, step) != want {
+			t.Fatalf("expected key to ignore names")
+		}
+	})
+	t.Run("env ordering does not matter", func(t *testing.T) {
+		wf, job, step := base()
+		step.Env = map[string]string{"B": "2", "A": "1"}
+		if stepKey(root, wf, job, step) != want {
+			t.Fatalf("expected key to ignore env ordering")
+		}
+	})
+	t.Run("env level does not matter", func(t *testing.T) {
+		wf, job, step := base()
+		wf.Env = nil
+		job.Env = map[string]string{"CI": "true", "A": "1"}
+		step.Env = map[string]string{"B": "2"}
+		if stepKey(root, wf, job, step) != want {
+			t.Fatalf("expected key to use the merged env")
+		}
+	})
+	t.Run("env value matters", func(t *testing.T) {
+		wf, job, step := base()
+		step.Env = map[string]string{"A": "1", "B": "3"}
+		if stepKey(root, wf, job, step) == want {
+			t.Fatalf("expected env change to alter key")
+		}
+	})
+	t.Run("env references are not expanded", func(t *testing.T) {
+		wf, job, step := base()
+		step.Env = map[string]string{"A": "$HOME/1", "B": "2"}
+		other := step
+		other.Env = map[string]string{"A": "/1", "B": "2"}
+		if stepKey(root, wf, job, step) == stepKey(root, wf, job, other) {
+			t.Fatalf("expected $HOME/1 and /1 to have different keys")
+		}
+	})
+	t.Run("working directory matters", func(t *testing.T) {
+		wf, job, step := base()
+		step.WorkingDirectory = "api"
+		if stepKey(root, wf, job, step) == want {
+			t.Fatalf("expected working directory to alter key")
+		}
+	})
+	t.Run("equivalent working directories match", func(t *testing.T) {
+		wf, job, step := base()
+		step.WorkingDirectory = "api/"
+		other := step
+		other.WorkingDirectory = "./api"
+		job.Defaults.WorkingDirectory = ""
+		if stepKey(root, wf, job, step) != stepKey(root, wf, job, other) {
+			t.Fatalf("expected cleaned working directories to match")
+		}
+	})
+	t.Run("shell matters", func(t *testing.T) {
+		wf, job, step := base()
+		step.Shell = "sh"
+		if stepKey(root, wf, job, step) == want {
+			t.Fatalf("expected shell to alter key")
+		}
+	})
+	t.Run("script whitespace does not matter", func(t *testing.T) {
+		wf, job, step := base()
+		step.Run = "bundle  exec rubocop\n"
+		if stepKey(root, wf, job, step) != want {
+			t.Fatalf("expected key to normalize script whitespace")
+		}
+	})
+}
+
+func TestRunnerDedupeSkipsPassedDuplicates(t *testing.T) {
+	root := t.TempDir()
+	r := New(Options{Root: root, Dedupe: true})
+	first := sampleWorkflow(echoCommand("hi"))
+	second := sampleWorkflow(echoCommand("hi") + "\n")
+	second.Name = "other"
+
+	results, summary, err := r.Run(context.Background(), []provider.Workflow{first, second})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	dup := results[1]
+	if dup.Status != "skipped" || dup.SkipReason != report.ReasonDuplicate {
+		t.Fatalf("expected duplicate skip, got %+v", dup)
+	}
+	if dup.DuplicateOf != "workflow/job/step" || dup.SkipDetail != "duplicate of workflow/job/step" {
+		t.Fatalf("unexpected duplicate origin: %+v", dup)
+	}
+	if dup.Duration != results[0].Duration || dup.ExitCode != results[0].ExitCode {
+		t.Fatalf("expected duplicate to reuse first result, got %+v vs %+v", dup, results[0])
+	}
+	if summary.Passed != 1 || summary.Skipped != 1 || summary.Deduped != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if summary.DedupeSaved != results[0].Duration {
+		t.Fatalf("expected savings %s, got %s", results[0].Duration, summary.DedupeSaved)
+	}
+}
+
+func TestRunnerDedupeRerunsFailures(t *testing.T) {
+	root := t.TempDir()
+	r := New(Options{Root: root, Dedupe: true})
+	wf := sampleWorkflow("exit 3")
+
+	results, summary, err := r.Run(context.Background(), []provider.Workflow{wf, wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	for i, res := range results {
+		if res.Status != "failed" {
+			t.Fatalf("result %d: expected failed step to re-run, got %+v", i, res)
+		}
+	}
+	if summary.Failed != 2 || summary.Deduped != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestRunnerDedupeDisabledByDefault(t *testing.T) {
+	root := t.TempDir()
+	r := New(Options{Root: root})
+	wf := sampleWorkflow(echoCommand("hi"))
+
+	_, summary, err := r.Run(context.Background(), []provider.Workflow{wf, wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if summary.Passed != 2 || summary.Deduped != 0 {
+		t.Fatalf("expected both steps to run, got %+v", summary)
+	}
+}
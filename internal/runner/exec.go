@@ -5,18 +5,25 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
-	"runtime"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
+    "github.com/bgricker/testdrive/internal/history"
     "github.com/bgricker/testdrive/internal/output"
+    "github.com/bgricker/testdrive/internal/patterns"
+    "github.com/bgricker/testdrive/internal/preflight"
     "github.com/bgricker/testdrive/internal/provider"
     "github.com/bgricker/testdrive/internal/report"
+    "github.com/bgricker/testdrive/internal/resolve"
 )
 
 // Options configure how the runner executes steps.
@@ -28,16 +35,120 @@ type Options struct {
 	DryRun             bool
 	TailLines          int
 	Env                []string
-	Now                func() time.Time
+	// Clock times steps and the teardown grace period. Nil uses the wall
+	// clock.
+	Clock              Clock
 	AllowPrivileged    bool
 	PrivilegedPatterns []string
+	// AllowDestructive runs steps that DestructivePatterns or the unguarded
+	// rm check would skip. Nil PrivilegedPatterns or DestructivePatterns use
+	// the defaults; an empty list matches nothing.
+	AllowDestructive    bool
+	DestructivePatterns []string
+	// ConfirmDestructive, when set, is asked before skipping a destructive
+	// step; returning true runs it anyway. Calls are serialized.
+	ConfirmDestructive func(wf provider.Workflow, job provider.Job, step provider.Step, detail string) bool
 	Streaming          bool
 	StreamingRenderer  output.StreamingRenderer
+	// Dedupe skips steps identical to one that already passed in this run.
+	Dedupe bool
+	// AllowedEnvironments lists the deployment environments whose jobs may
+	// run. Jobs targeting any other environment are skipped.
+	AllowedEnvironments []string
+	// MaxParallel is how many jobs batch runs may execute at once. Jobs
+	// sharing a concurrency group never overlap. Streaming runs are always
+	// sequential.
+	MaxParallel int
+	// GitRef expands ${{ github.ref }} in concurrency groups, e.g.
+	// "refs/heads/main".
+	GitRef string
+	// JobDurations holds each job's last recorded duration. When set, batch
+	// runs with MaxParallel above one start the longest jobs first; jobs
+	// without a recorded duration follow in declared order.
+	JobDurations map[history.JobKey]time.Duration
+	// JobOrder, when set, replays a recorded start order: listed jobs start
+	// in that order, ahead of the rest in declared order, and no job starts
+	// before the ones listed ahead of it. It overrides JobDurations.
+	JobOrder []history.JobKey
+	// CombineOutput captures each step's stdout and stderr as one stream in
+	// CombinedOutput instead of separately, keeping their interleaving.
+	// Verbose output then goes to Stdout only.
+	CombineOutput bool
+	// FollowJob picks the job whose verbose output is shown live when
+	// parallel jobs hold theirs back until they finish. Only one followed
+	// job streams at a time; nil follows none.
+	FollowJob func(provider.Job) bool
+	// AllowUnresolvedExpressions runs steps whose script, env, or working
+	// directory still holds a ${{ }} expression. Otherwise they fail before
+	// starting.
+	AllowUnresolvedExpressions bool
+	// Flaky scores steps against recorded runs; flaky steps are marked in
+	// their results. Nil marks nothing.
+	Flaky *history.FlakyIndex
+	// TeardownGrace is how long teardown steps may keep running once the
+	// run is cancelled. Zero means DefaultTeardownGrace.
+	TeardownGrace time.Duration
+	// StallWarningAfter warns once a running step has written no output
+	// for this long, through the streaming renderer when it is an
+	// output.StallReporter and on Stderr otherwise. StallTimeout kills a
+	// step that has written none for that long, failing it. Both are
+	// measured on Clock; zero turns them off.
+	StallWarningAfter time.Duration
+	StallTimeout      time.Duration
+	// RedactEnvPatterns are glob patterns matched, ignoring case, against
+	// the names in each step's merged environment. The values of matching
+	// variables, when at least four characters long, are replaced with
+	// report.MaskedValue in the step's output, hint, env snapshot, and step
+	// summary before anything shows them. Nil masks nothing.
+	RedactEnvPatterns []string
+	// Logger receives debug events for each skip decision and resolved
+	// command. Nil discards them.
+	Logger *slog.Logger
+	// Executor runs each step's command. Nil starts a child process.
+	Executor Executor
+	// PathMap rewrites CI paths in working directories and workflow, job,
+	// and step env values to local ones.
+	PathMap resolve.PathMap
+	// CheckPorts lists TCP ports every job needs free; a job starts only
+	// once nothing else listens on them. DetectPorts adds the ports each
+	// job's scripts and env values appear to serve on.
+	CheckPorts  []int
+	DetectPorts bool
+	// AutoPath puts the node_modules/.bin and bin directories nearest each
+	// step's working directory at the front of its PATH.
+	AutoPath bool
+	// ToolVersions maps tools to the versions detected before the run. They
+	// are recorded in the environment snapshot of each failed step.
+	ToolVersions map[string]string
+	// Stat probes for asdf and working directories while resolving steps.
+	// Each path is probed once per runner. Nil uses os.Stat.
+	Stat func(name string) (fs.FileInfo, error)
+	// LookPath finds each step's shell program when a dry run or plan
+	// checks that the step can start. Nil uses exec.LookPath.
+	LookPath func(file string) (string, error)
+	// FoldStart and FoldEnd are the lines verbose output puts around each
+	// job and step so an editor can fold them; {name} in them becomes the
+	// job or step name. An empty template writes no line.
+	FoldStart string
+	FoldEnd   string
+	// Totals counts the whole pipeline the workflows to run were selected
+	// from, for the summary's totals. The zero value counts the selection.
+	Totals report.Totals
 }
 
 // Runner executes workflow steps sequentially.
 type Runner struct {
 	opts Options
+	// confirmMu keeps parallel jobs from prompting at the same time.
+	confirmMu sync.Mutex
+	// mux keeps verbose output of parallel jobs from interleaving.
+	mux *outputMux
+	// resolver caches filesystem probes across the run's steps.
+	resolver *resolve.Resolver
+	// tempDir holds the run's script and step summary files. Run removes
+	// it when it returns, whether the run passed, failed, or was
+	// cancelled.
+	tempDir string
 }
 
 // New creates a runner with the supplied options.
@@ -54,360 +165,801 @@ func New(opts Options) *Runner {
 	if opts.Env == nil {
 		opts.Env = os.Environ()
 	}
-	if opts.Now == nil {
-		opts.Now = time.Now
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
 	}
-	if opts.PrivilegedPatterns == nil || len(opts.PrivilegedPatterns) == 0 {
+	if opts.Executor == nil {
+		opts.Executor = processExecutor{}
+	}
+	if opts.LookPath == nil {
+		opts.LookPath = exec.LookPath
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.DiscardHandler)
+	}
+	if opts.TeardownGrace <= 0 {
+		opts.TeardownGrace = DefaultTeardownGrace
+	}
+	if opts.PrivilegedPatterns == nil {
 		opts.PrivilegedPatterns = DefaultPrivilegedPatterns()
 	}
 	opts.PrivilegedPatterns = append([]string{}, opts.PrivilegedPatterns...)
+	if opts.DestructivePatterns == nil {
+		opts.DestructivePatterns = DefaultDestructivePatterns()
+	}
+	opts.DestructivePatterns = append([]string{}, opts.DestructivePatterns...)
 	
     // Streaming requires a renderer; callers should set both together.
     // Validation is handled by `cmd` layer; avoid duplicating checks here.
 	
-	return &Runner{opts: opts}
+	resolver := resolve.NewResolver(opts.Stat)
+	resolver.Paths = opts.PathMap
+	return &Runner{opts: opts, resolver: resolver, mux: &outputMux{
+		stdout:  opts.Stdout,
+		stderr:  opts.Stderr,
+		grouped: opts.Verbose && !opts.Streaming && opts.MaxParallel > 1,
+		follow:  opts.FollowJob,
+	}}
 }
 
 // Run executes the provided workflows returning step results and a summary.
-func (r *Runner) Run(workflows []provider.Workflow) ([]report.StepResult, report.Summary, error) {
+// Once ctx is done, running steps are killed and every step that has not
+// finished is recorded as skipped with reason "cancelled"; the partial
+// results are returned without an error so callers can still render them.
+func (r *Runner) Run(ctx context.Context, workflows []provider.Workflow) ([]report.StepResult, report.Summary, error) {
+	if !r.opts.DryRun {
+		dir, err := os.MkdirTemp("", "testdrive-run-")
+		if err != nil {
+			return nil, report.Summary{}, fmt.Errorf("create run temp directory: %w", err)
+		}
+		r.tempDir = dir
+		defer func() {
+			os.RemoveAll(dir)
+			r.tempDir = ""
+		}()
+	}
 	if r.opts.Streaming {
-		return r.runStreaming(workflows)
+		return r.runStreaming(ctx, workflows)
 	}
-	return r.runBatch(workflows)
+	return r.runBatch(ctx, workflows)
 }
 
 // runStreaming executes workflows with real-time streaming updates.
-func (r *Runner) runStreaming(workflows []provider.Workflow) ([]report.StepResult, report.Summary, error) {
-	summary := report.Summary{TotalWorkflows: len(workflows)}
-	results := make([]report.StepResult, 0)
+func (r *Runner) runStreaming(ctx context.Context, workflows []provider.Workflow) ([]report.StepResult, report.Summary, error) {
+	collector := r.newCollector(workflows)
+	dedupe := r.newDedupeTracker()
 
     // Initialize all jobs upfront via the renderer interface
     if r.opts.StreamingRenderer != nil {
-        _ = r.opts.StreamingRenderer.InitializeAllJobs(workflows)
-        // Optionally start a live timer if supported
-        if timer, ok := r.opts.StreamingRenderer.(output.TimerController); ok {
+        _ = r.opts.StreamingRenderer.InitializeAllJobs(RedactWorkflows(r.opts.RedactEnvPatterns, workflows))
+        // Optionally start a live timer if supported; a dry run has
+        // nothing running to time.
+        if timer, ok := r.opts.StreamingRenderer.(output.TimerController); ok && !r.opts.DryRun {
             timer.StartTimer()
             defer timer.StopTimer()
         }
     }
 
+	var jobs []scheduledJob
 	for _, wf := range workflows {
-		summary.TotalJobs += len(wf.Jobs)
 		for _, job := range wf.Jobs {
-            // Start the job via the interface
-            if r.opts.StreamingRenderer != nil {
-                _ = r.opts.StreamingRenderer.StartJob(job.Name)
-            }
-            // All jobs have already been registered with the renderer at the start; no need to register again here
-
-			for _, step := range job.Steps {
-				if step.Run == "" || step.Uses != "" {
-					continue
-				}
-				summary.TotalSteps++
-
-				result := report.StepResult{
-					WorkflowPath: wf.Path,
-					WorkflowName: wf.Name,
-					JobName:      job.Name,
-					StepName:     step.Name,
-					StepRun:      step.Run,
-					DryRun:       r.opts.DryRun,
-				}
-
-				// Start step
-				if err := r.opts.StreamingRenderer.StartStep(step.Name); err != nil {
-					return nil, summary, err
-				}
-
-				if msg, skip := shouldSkipStep(step.Run, r.opts); skip {
-					result.Status = "skipped"
-					result.Stderr = msg
-					summary.Skipped++
-					results = append(results, result)
-					if err := r.opts.StreamingRenderer.CompleteStep(step.Name, "skipped", 0, "", msg, step.Run); err != nil {
-						return nil, summary, err
-					}
-					continue
-				}
-
-				if r.opts.DryRun {
-					result.Status = "skipped"
-					summary.Skipped++
-					results = append(results, result)
-					if err := r.opts.StreamingRenderer.CompleteStep(step.Name, "skipped", 0, "", "", step.Run); err != nil {
-						return nil, summary, err
-					}
-					continue
-				}
-
-				start := r.opts.Now()
-				err := r.runStep(context.Background(), wf, job, step, &result)
-				result.Duration = r.opts.Now().Sub(start)
-				result.DurationMS = result.Duration.Milliseconds()
-
-				if err != nil {
-					result.Status = "failed"
-					result.Stderr = tailLines(result.Stderr, r.opts.TailLines)
-					result.Stdout = tailLines(result.Stdout, r.opts.TailLines)
-					summary.Failed++
-				} else {
-					result.Status = "passed"
-					summary.Passed++
-				}
-
-				summary.Duration += result.Duration
-				if result.Status == "failed" {
-					summary.ExitCode = 1
-				}
-
-				results = append(results, result)
-				
-				// Complete step with streaming update
-				if err := r.opts.StreamingRenderer.CompleteStep(step.Name, result.Status, result.Duration, result.Stdout, result.Stderr, step.Run); err != nil {
-					return nil, summary, err
-				}
-			}
-			
-			// Complete job with streaming update (after all steps in the job are done)
-			if err := r.opts.StreamingRenderer.CompleteJob(); err != nil {
-				return nil, summary, err
-			}
+			collector.addJob(wf, job)
+			jobs = append(jobs, scheduledJob{wf: wf, job: job})
 		}
 	}
+	if len(r.opts.JobOrder) > 0 {
+		jobs = replayOrder(jobs, r.opts.JobOrder)
+	}
+
+	jobs = orderByNeeds(jobs)
+	needs := newNeedTracker(jobs)
+
+	// Jobs run one at a time here, so max-parallel is moot; fail-fast still
+	// cancels the variants after a failing one, and a failed job skips the
+	// jobs that need it.
+	failedMatrices := make(map[string]bool)
+	for _, j := range jobs {
+		wf, job := j.wf, j.job
+		jobID := output.JobID(wf, job)
+		cause := needs.blocked(j)
+		// All jobs have already been registered with the renderer at the start; just mark this one running
+		if r.opts.StreamingRenderer != nil && cause == "" {
+			_ = r.opts.StreamingRenderer.StartJob(jobID)
+		}
+
+		matrix := matrixKey(wf, job)
+		var err error
+		switch {
+		case cause != "":
+			err = r.skipNeeds(wf, job, jobID, cause, collector)
+		case failedMatrices[matrix] && job.Strategy.FailFast:
+			err = r.cancelJob(wf, job, jobID, collector)
+		default:
+			r.startJob(wf, job, collector)
+			err = r.runJob(ctx, wf, job, jobID, collector, dedupe)
+		}
+		if err != nil {
+			_, summary := collector.finish()
+			return nil, summary, err
+		}
+		failed := collector.jobFailed(wf, job)
+		if matrix != "" && failed {
+			failedMatrices[matrix] = true
+		}
+		if failed && cause == "" {
+			cause = job.Name
+		}
+		needs.finish(j, cause)
+
+		// Complete job with streaming update (after all steps in the job are done)
+		if err := r.opts.StreamingRenderer.CompleteJob(jobID); err != nil {
+			_, summary := collector.finish()
+			return nil, summary, err
+		}
+	}
+
+	results, summary := collector.finish()
+	sortDeclared(results, workflows)
 
-	summary.DurationMS = summary.Duration.Milliseconds()
-	
 	// Render final summary
 	if err := r.opts.StreamingRenderer.RenderSummary(summary); err != nil {
 		return nil, summary, err
 	}
-	
+
 	return results, summary, nil
 }
 
-// runBatch executes workflows in batch mode (original behavior).
-func (r *Runner) runBatch(workflows []provider.Workflow) ([]report.StepResult, report.Summary, error) {
-	summary := report.Summary{TotalWorkflows: len(workflows)}
-	results := make([]report.StepResult, 0)
+// runBatch executes workflows in batch mode, running up to MaxParallel jobs
+// at once. Results are reported in workflow and job order regardless.
+func (r *Runner) runBatch(ctx context.Context, workflows []provider.Workflow) ([]report.StepResult, report.Summary, error) {
+	collector := r.newCollector(workflows)
+	dedupe := r.newDedupeTracker()
 
+	var jobs []scheduledJob
 	for _, wf := range workflows {
-		summary.TotalJobs += len(wf.Jobs)
 		for _, job := range wf.Jobs {
-			for _, step := range job.Steps {
-				if step.Run == "" || step.Uses != "" {
-					continue
-				}
-				summary.TotalSteps++
-
-				result := report.StepResult{
-					WorkflowPath: wf.Path,
-					WorkflowName: wf.Name,
-					JobName:      job.Name,
-					StepName:     step.Name,
-					StepRun:      step.Run,
-					DryRun:       r.opts.DryRun,
-				}
-
-				if msg, skip := shouldSkipStep(step.Run, r.opts); skip {
-					result.Status = "skipped"
-					result.Stderr = msg
-					summary.Skipped++
-					results = append(results, result)
-					continue
-				}
-
-				if r.opts.DryRun {
-					result.Status = "skipped"
-					summary.Skipped++
-					results = append(results, result)
-					continue
-				}
-
-				start := r.opts.Now()
-				err := r.runStep(context.Background(), wf, job, step, &result)
-				result.Duration = r.opts.Now().Sub(start)
-				result.DurationMS = result.Duration.Milliseconds()
-
-				if err != nil {
-					result.Status = "failed"
-					result.Stderr = tailLines(result.Stderr, r.opts.TailLines)
-					result.Stdout = tailLines(result.Stdout, r.opts.TailLines)
-					summary.Failed++
-				} else {
-					result.Status = "passed"
-					summary.Passed++
-				}
-
-				summary.Duration += result.Duration
-				if result.Status == "failed" {
-					summary.ExitCode = 1
-				}
-
-				results = append(results, result)
-			}
+			collector.addJob(wf, job)
+			jobs = append(jobs, newScheduledJob(wf, job, r.opts.GitRef))
 		}
 	}
 
-	summary.DurationMS = summary.Duration.Milliseconds()
+	if len(r.opts.JobOrder) > 0 {
+		jobs = replayOrder(jobs, r.opts.JobOrder)
+		if r.opts.Verbose {
+			fmt.Fprintf(r.opts.Stderr, "info: replaying job order: %s\n", describeOrder(jobs, nil))
+		}
+	} else if len(r.opts.JobDurations) > 0 && r.opts.MaxParallel > 1 {
+		jobs = longestFirst(jobs, r.opts.JobDurations)
+		if r.opts.Verbose {
+			fmt.Fprintf(r.opts.Stderr, "info: starting jobs longest-first: %s\n", describeOrder(jobs, r.opts.JobDurations))
+		}
+	}
+
+	s := newScheduler(r.opts.MaxParallel, jobs)
+	s.ordered = len(r.opts.JobOrder) > 0
+	s.failed = func(j scheduledJob) bool { return collector.jobFailed(j.wf, j.job) }
+	s.cancel = func(j scheduledJob) { _ = r.cancelJob(j.wf, j.job, "", collector) }
+	s.skip = func(j scheduledJob, cause string) { _ = r.skipNeeds(j.wf, j.job, "", cause, collector) }
+	s.started = func(j scheduledJob) { r.startJob(j.wf, j.job, collector) }
+	err := s.run(jobs, func(j scheduledJob) error {
+		return r.runJob(ctx, j.wf, j.job, "", collector, dedupe)
+	})
+	results, summary := collector.finish()
+	if err != nil {
+		return nil, summary, err
+	}
+	sortDeclared(results, workflows)
 	return results, summary, nil
 }
 
-func (r *Runner) runStep(ctx context.Context, wf provider.Workflow, job provider.Job, step provider.Step, result *report.StepResult) error {
-	env := mergeEnv(r.opts.Env, wf.Env, job.Env, step.Env)
-	cmdArgs, err := buildCommand(step, job, wf, env)
+// sortDeclared puts results back in workflow and job order, whatever order
+// the jobs ran in. Steps within a job keep their order.
+func sortDeclared(results []report.StepResult, workflows []provider.Workflow) {
+	order := make(map[[2]string]int)
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			order[[2]string{wf.Path, job.Name}] = len(order)
+		}
+	}
+	sort.SliceStable(results, func(a, b int) bool {
+		return order[[2]string{results[a].WorkflowPath, results[a].JobName}] < order[[2]string{results[b].WorkflowPath, results[b].JobName}]
+	})
+}
+
+// startJob records job as the next one started. Dry runs start nothing.
+func (r *Runner) startJob(wf provider.Workflow, job provider.Job, collector *resultCollector) {
+	if !r.opts.DryRun {
+		collector.startJob(wf, job, r.opts.Clock.Now())
+	}
+}
+
+// runJob executes the run: steps of job, handing every result to collector.
+// When streaming, progress is also reported to the renderer under jobID.
+// Steps left when ctx is done are recorded as cancelled. Teardown steps run
+// last, and still run after a failure or cancellation as long as one of the
+// job's other steps was started.
+func (r *Runner) runJob(ctx context.Context, wf provider.Workflow, job provider.Job, jobID string, collector *resultCollector, dedupe *dedupeTracker) error {
+	if conflicts := r.busyPorts(wf, job); len(conflicts) > 0 {
+		return r.failPorts(wf, job, jobID, conflicts, collector)
+	}
+	stepSummary, err := r.newStepSummaryFile()
 	if err != nil {
-		result.Stderr = err.Error()
-		result.ExitCode = 127
 		return err
 	}
+	defer stepSummary.remove()
+	out, flush := r.mux.job(wf, job)
+	defer flush()
+	if r.opts.Verbose {
+		var end func()
+		out, end = r.foldJob(out, job)
+		defer end()
+	}
 
-	workingDir, err := resolveWorkingDirectory(r.opts.Root, wf, job, step)
+	var steps, teardown []provider.Step
+	for _, step := range job.Steps {
+		if step.IsTeardown() {
+			teardown = append(teardown, step)
+		} else {
+			steps = append(steps, step)
+		}
+	}
+	started, err := r.runSteps(ctx, wf, job, steps, jobID, collector, dedupe, stepSummary, out)
 	if err != nil {
-		result.Stderr = err.Error()
-		result.ExitCode = 127
 		return err
 	}
+	if len(teardown) > 0 {
+		if ctx.Err() != nil && !started {
+			// Nothing ran, so there is nothing to clean up.
+			if err := r.cancelSteps(wf, job, teardown, jobID, cancelDetail(ctx), collector); err != nil {
+				return err
+			}
+		} else {
+			teardownCtx, stop := teardownContext(ctx, r.opts.TeardownGrace, r.opts.Clock)
+			_, err := r.runSteps(teardownCtx, wf, job, teardown, jobID, collector, dedupe, stepSummary, out)
+			stop()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	collector.setStepSummary(wf, job, stepSummary.contents())
+	return nil
+}
 
-	cmd := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...)
-	cmd.Dir = workingDir
-	cmd.Env = env
+// runSteps executes the run: steps in steps in order and reports whether any
+// of them was started.
+func (r *Runner) runSteps(ctx context.Context, wf provider.Workflow, job provider.Job, steps []provider.Step, jobID string, collector *resultCollector, dedupe *dedupeTracker, stepSummary *stepSummaryFile, out jobOutput) (bool, error) {
+	started := false
+	for i, step := range steps {
+		if step.Run == "" || step.Uses != "" {
+			continue
+		}
+		if ctx.Err() != nil {
+			if err := r.cancelSteps(wf, job, steps[i:], jobID, cancelDetail(ctx), collector); err != nil {
+				return started, err
+			}
+			break
+		}
+		started = true
+		label := output.StepLabel(step.Name, step.Overridden)
 
-	var stdoutBuf, stderrBuf strings.Builder
-	if r.opts.Verbose {
-		cmd.Stdout = io.MultiWriter(r.opts.Stdout, &stdoutBuf)
-		cmd.Stderr = io.MultiWriter(r.opts.Stderr, &stderrBuf)
-	} else {
-		cmd.Stdout = &stdoutBuf
-		cmd.Stderr = &stderrBuf
+		if r.opts.Streaming {
+			if err := r.opts.StreamingRenderer.StartStep(jobID, label); err != nil {
+				return started, err
+			}
+		}
+
+		result := r.executeStep(ctx, wf, job, step, jobID, collector, dedupe, stepSummary, out)
+		collector.add(result)
+
+		if r.opts.Streaming {
+			shown := result
+			if shown.Status == "skipped" {
+				// Duplicates carry their original's duration for the
+				// summary, but nothing ran here.
+				shown.Duration = 0
+			}
+			if err := r.opts.StreamingRenderer.CompleteStep(jobID, label, shown); err != nil {
+				return started, err
+			}
+		}
 	}
+	return started, nil
+}
 
-	err = cmd.Run()
-	result.Stdout = stdoutBuf.String()
-	result.Stderr = simplifyError(stderrBuf.String())
-	result.ExitCode = exitCode(err)
+// markFlaky copies the step's flakiness onto result when history marks it as
+// flaky.
+func (r *Runner) markFlaky(wf provider.Workflow, job provider.Job, step provider.Step, result *report.StepResult) {
+	key := history.StepKey{Workflow: filepath.ToSlash(wf.Path), Job: job.Name, Step: step.Name}
+	flakiness := r.opts.Flaky.Lookup(key, step.ID, history.CommandHash(step.Run))
+	if !flakiness.Flaky() {
+		return
+	}
+	result.FlakyScore = flakiness.Score()
+	result.FlakyRecoveries = flakiness.Recoveries
+	result.FlakyRuns = flakiness.Runs
+}
 
-	if err != nil {
-		// ensure stderr populated for messaging when verbose life.
-		if !r.opts.Verbose {
-			// nothing extra needed, tail applied by caller
+// teardownContext returns the context teardown steps run under. It ignores
+// ctx's cancellation for grace, so cleanup can finish after Ctrl-C, and is
+// then cancelled with errTeardownGrace. The grace period is measured on
+// clock.
+func teardownContext(ctx context.Context, grace time.Duration, clock Clock) (context.Context, func()) {
+	teardownCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		select {
+		case <-clock.After(grace):
+			cancel(errTeardownGrace)
+		case <-teardownCtx.Done():
+		}
+	})
+	return teardownCtx, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+// cancelJob records every run: step of a matrix variant that fail-fast
+// dropped before it started. When streaming, the steps are also reported to
+// the renderer under jobID.
+func (r *Runner) cancelJob(wf provider.Workflow, job provider.Job, jobID string, collector *resultCollector) error {
+	return r.cancelSteps(wf, job, job.Steps, jobID, "cancelled by fail-fast", collector)
+}
+
+// skipNeeds records every run: step of a job that never started because
+// cause, a job it needs, failed. When streaming, the steps are also
+// reported to the renderer under jobID.
+func (r *Runner) skipNeeds(wf provider.Workflow, job provider.Job, jobID, cause string, collector *resultCollector) error {
+	return r.skipSteps(wf, job, job.Steps, jobID, report.ReasonNeedsFailed, fmt.Sprintf("needs %s, which failed", cause), collector)
+}
+
+// cancelSteps records each run: step in steps as skipped with reason
+// "cancelled" and detail msg.
+func (r *Runner) cancelSteps(wf provider.Workflow, job provider.Job, steps []provider.Step, jobID, msg string, collector *resultCollector) error {
+	return r.skipSteps(wf, job, steps, jobID, report.ReasonCancelled, msg, collector)
+}
+
+// skipSteps records each run: step in steps as skipped with reason and
+// detail msg.
+func (r *Runner) skipSteps(wf provider.Workflow, job provider.Job, steps []provider.Step, jobID, reason, msg string, collector *resultCollector) error {
+	for _, step := range steps {
+		if step.Run == "" || step.Uses != "" {
+			continue
+		}
+		label := output.StepLabel(step.Name, step.Overridden)
+		result := report.StepResult{
+			WorkflowPath: wf.Path,
+			WorkflowName: wf.Name,
+			JobName:      job.Name,
+			StepName:     step.Name,
+			StepID:       step.ID,
+			StepRun:      step.Run,
+			Status:       "skipped",
+			DryRun:       r.opts.DryRun,
+			Overridden:   step.Overridden,
+			Teardown:     step.IsTeardown(),
+			SkipReason:   reason,
+			SkipDetail:   msg,
+		}
+		collector.add(result)
+		if r.opts.Streaming {
+			if err := r.opts.StreamingRenderer.StartStep(jobID, label); err != nil {
+				return err
+			}
+			if err := r.opts.StreamingRenderer.CompleteStep(jobID, label, result); err != nil {
+				return err
+			}
 		}
-		return err
 	}
 	return nil
 }
 
-func buildCommand(step provider.Step, job provider.Job, wf provider.Workflow, env []string) ([]string, error) {
-	shell := strings.TrimSpace(step.Shell)
-	if shell == "" {
-		shell = strings.TrimSpace(job.Defaults.RunShell)
-	}
-	if shell == "" {
-		shell = strings.TrimSpace(wf.Defaults.RunShell)
-	}
-
-	return commandArgs(shell, step.Run, env)
-}
-
-func commandArgs(shellSpec string, script string, env []string) ([]string, error) {
-	if shellSpec == "" {
-		if runtime.GOOS == "windows" {
-			return []string{"cmd", "/C", script}, nil
-		}
-		// Use bash with login shell and source asdf if available
-		// This ensures tools like asdf, rbenv, etc. work properly
-		asdfInit := getAsdfInit(env, "bash")
-		return []string{"bash", "-l", "-c", asdfInit + " " + script}, nil
-	}
-
-	fields := strings.Fields(shellSpec)
-	shell := fields[0]
-	args := append([]string{}, fields[1:]...)
-	base := strings.ToLower(filepath.Base(shell))
-
-	switch base {
-	case "bash", "zsh", "ksh", "fish":
-		// These shells support login flag, use it for proper environment inheritance
-		asdfInit := getAsdfInit(env, base)
-		args = append(args, "-l", "-c", asdfInit + " " + script)
-		return append([]string{shell}, args...), nil
-	case "sh":
-		// sh might be dash or another shell that doesn't support -l, use only -c
-		// Also use POSIX-compliant asdf initialization
-		asdfInit := getAsdfInit(env, "sh")
-		args = append(args, "-c", asdfInit + " " + script)
-		return append([]string{shell}, args...), nil
-	case "cmd", "cmd.exe":
-		args = append(args, "/C", script)
-		return append([]string{shell}, args...), nil
-	case "pwsh", "powershell", "powershell.exe":
-		args = append(args, "-Command", script)
-		return append([]string{shell}, args...), nil
-	case "python", "python3", "python.exe":
-		args = append(args, "-c", script)
-		return append([]string{shell}, args...), nil
+// busyPorts returns the ports job needs that something is already
+// listening on. Dry runs check nothing.
+func (r *Runner) busyPorts(wf provider.Workflow, job provider.Job) []preflight.PortConflict {
+	if r.opts.DryRun || (len(r.opts.CheckPorts) == 0 && !r.opts.DetectPorts) {
+		return nil
+	}
+	ports := append([]int{}, r.opts.CheckPorts...)
+	if r.opts.DetectPorts {
+		var scripts []string
+		envs := []map[string]string{wf.Env, job.Env}
+		for _, step := range job.Steps {
+			scripts = append(scripts, step.Run)
+			envs = append(envs, step.Env)
+		}
+		for _, port := range preflight.DetectPorts(scripts, envs...) {
+			if !slices.Contains(ports, port) {
+				ports = append(ports, port)
+			}
+		}
+	}
+	return preflight.BusyPorts(ports)
+}
+
+// portConflictHint explains how to clear the conflicts failPorts reports.
+const portConflictHint = "stop the process listening on the port, or drop the port from check_ports (or turn off detect_ports if it was guessed)"
+
+// failPorts records job as failed before it starts because ports it needs
+// are taken: its first run: step other than teardown fails with the
+// conflicts and the rest are cancelled.
+func (r *Runner) failPorts(wf provider.Workflow, job provider.Job, jobID string, conflicts []preflight.PortConflict, collector *resultCollector) error {
+	lines := make([]string, len(conflicts))
+	for i, c := range conflicts {
+		lines[i] = c.String()
+	}
+	first := -1
+	for i, step := range job.Steps {
+		if step.Run != "" && step.Uses == "" && !step.IsTeardown() {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return r.cancelSteps(wf, job, job.Steps, jobID, "cancelled: "+lines[0], collector)
+	}
+
+	step := job.Steps[first]
+	label := output.StepLabel(step.Name, step.Overridden)
+	result := report.StepResult{
+		WorkflowPath: wf.Path,
+		WorkflowName: wf.Name,
+		JobName:      job.Name,
+		StepName:     step.Name,
+		StepID:       step.ID,
+		StepRun:      step.Run,
+		Status:       "failed",
+		Stderr:       strings.Join(lines, "\n"),
+		ExitCode:     1,
+		Overridden:   step.Overridden,
+		Hint:         portConflictHint,
+		FailureClass: report.FailureEnvironment,
+	}
+	collector.add(result)
+	if r.opts.Streaming {
+		if err := r.opts.StreamingRenderer.StartStep(jobID, label); err != nil {
+			return err
+		}
+		if err := r.opts.StreamingRenderer.CompleteStep(jobID, label, result); err != nil {
+			return err
+		}
+	}
+	rest := append(append([]provider.Step{}, job.Steps[:first]...), job.Steps[first+1:]...)
+	return r.cancelSteps(wf, job, rest, jobID, "cancelled: "+lines[0], collector)
+}
+
+// DefaultTeardownGrace is how long teardown steps may run after the run is
+// cancelled when Options.TeardownGrace is unset.
+const DefaultTeardownGrace = 30 * time.Second
+
+// errTeardownGrace cancels teardown steps still running once the grace
+// period after a cancellation is over.
+var errTeardownGrace = errors.New("teardown grace period expired")
+
+// cancelWaitDelay bounds how long a cancelled step may hold its output open.
+const cancelWaitDelay = 500 * time.Millisecond
+
+// cancelDetail explains why ctx ended the run.
+func cancelDetail(ctx context.Context) string {
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, context.DeadlineExceeded):
+		return "cancelled: deadline exceeded"
+	case errors.Is(cause, errTeardownGrace):
+		return "cancelled: teardown grace period expired"
 	default:
-		args = append(args, script)
-		return append([]string{shell}, args...), nil
+		return "cancelled: run interrupted"
 	}
 }
 
-func resolveWorkingDirectory(root string, wf provider.Workflow, job provider.Job, step provider.Step) (string, error) {
-	candidates := []string{step.WorkingDirectory, job.Defaults.WorkingDirectory, wf.Defaults.WorkingDirectory}
-	for _, candidate := range candidates {
-		candidate = strings.TrimSpace(candidate)
-		if candidate == "" {
-			continue
+// executeStep runs a single step, or records why it was skipped. Stall
+// warnings are reported to the streaming renderer under jobID.
+func (r *Runner) executeStep(ctx context.Context, wf provider.Workflow, job provider.Job, step provider.Step, jobID string, collector *resultCollector, dedupe *dedupeTracker, stepSummary *stepSummaryFile, out jobOutput) report.StepResult {
+	result := report.StepResult{
+		WorkflowPath: wf.Path,
+		WorkflowName: wf.Name,
+		JobName:      job.Name,
+		StepName:     step.Name,
+		StepID:       step.ID,
+		StepRun:      step.Run,
+		DryRun:       r.opts.DryRun,
+		Overridden:   step.Overridden,
+		Teardown:     step.IsTeardown(),
+
+		AllowPrivileged: step.AllowPrivileged,
+		ExpectedFailure: step.ExpectedFailure,
+	}
+	r.markFlaky(wf, job, step, &result)
+
+	if reason, msg, skip := resolve.Skip(job, step, r.skipOptions(wf, job, step)); skip && !r.confirmed(wf, job, step, reason, msg) {
+		r.opts.Logger.Debug("step skipped", "workflow", wf.Path, "job", job.Name, "step", step.Name, "reason", reason, "detail", msg)
+		result.Status = "skipped"
+		result.SkipReason = reason
+		result.SkipDetail = msg
+		return result
+	}
+
+	if r.opts.DryRun {
+		if err := r.resolveStep(wf, job, step); err != nil {
+			// The real run would fail here before running anything.
+			result.Status = "failed"
+			result.Stderr = err.Error()
+			result.ExitCode = exitCommandNotFound
+			result.FailureClass = report.FailureEnvironment
+			return result
 		}
+		result.Status = "skipped"
+		result.SkipReason = report.ReasonDryRun
+		return result
+	}
+
+	key := dedupe.key(r.opts.Root, wf, job, step)
+	if prior, ok := dedupe.lookup(key); ok {
+		r.opts.Logger.Debug("step skipped", "workflow", wf.Path, "job", job.Name, "step", step.Name, "reason", report.ReasonDuplicate, "duplicate_of", prior.JobName+"/"+prior.StepName)
+		markDuplicate(&result, prior)
+		return result
+	}
+
+	start := r.opts.Clock.Now()
+	collector.startStep(&result, start)
+	err := r.runStep(ctx, wf, job, step, jobID, stepSummary, out, &result)
+	result.Duration = r.opts.Clock.Now().Sub(start)
+	result.DurationMS = result.Duration.Milliseconds()
 
-		if !filepath.IsAbs(candidate) {
-			candidate = filepath.Join(root, candidate)
+	if err != nil && ctx.Err() != nil {
+		// The step was killed rather than failing on its own, so it says
+		// nothing about the code and must not be replayed by --dedupe.
+		result.Status = "skipped"
+		result.SkipReason = report.ReasonCancelled
+		result.SkipDetail = cancelDetail(ctx) + " while the step was running"
+		return result
+	}
+	if err != nil {
+		result.Status = "failed"
+		result.Stderr = tailLines(result.Stderr, r.opts.TailLines)
+		result.Stdout = tailLines(result.Stdout, r.opts.TailLines)
+		result.CombinedOutput = tailLines(result.CombinedOutput, r.opts.TailLines)
+		if result.FailureClass == "" {
+			result.FailureClass = classifyFailure(result)
 		}
-		info, err := os.Stat(candidate)
+	} else {
+		result.Status = "passed"
+	}
+	if step.ExpectedFailure {
+		// The step is known to fail here, so its failure does not fail the
+		// run and a pass is news.
+		result.Status = "xpass"
 		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				return "", fmt.Errorf("working directory %q not found", candidate)
+			result.Status = "xfail"
+		}
+	}
+
+	dedupe.record(key, result)
+	return result
+}
+
+// notStarted records err as the failure of a step that failed before its
+// shell started. Nothing of the project's ran, so the local setup is to
+// blame.
+func notStarted(result *report.StepResult, err error) error {
+	result.Stderr = err.Error()
+	result.ExitCode = exitCommandNotFound
+	result.FailureClass = report.FailureEnvironment
+	return err
+}
+
+func (r *Runner) runStep(ctx context.Context, wf provider.Workflow, job provider.Job, step provider.Step, jobID string, stepSummary *stepSummaryFile, out jobOutput, result *report.StepResult) (err error) {
+	wfEnv, wfMapped := r.opts.PathMap.MapVars(wf.Env)
+	jobEnv, jobMapped := r.opts.PathMap.MapVars(job.Env)
+	stepEnv, stepMapped := r.opts.PathMap.MapVars(step.Env)
+	env := resolve.MergeEnv(r.opts.Env, r.workspaceEnv(), stepSummary.env(), wfEnv, jobEnv, stepEnv)
+	redact := newRedactor(r.opts.RedactEnvPatterns, env)
+	stepSummary.redact(redact)
+	defer func() {
+		// Output is masked as it is written; this catches what testdrive
+		// adds itself.
+		result.Stdout = redact.String(result.Stdout)
+		result.Stderr = redact.String(result.Stderr)
+		result.CombinedOutput = redact.String(result.CombinedOutput)
+		result.Hint = redact.String(result.Hint)
+		if result.EnvSnapshot != nil {
+			for name, value := range result.EnvSnapshot.Env {
+				result.EnvSnapshot.Env[name] = redact.String(value)
 			}
-			return "", fmt.Errorf("stat working directory %q: %w", candidate, err)
 		}
-		if !info.IsDir() {
-			return "", fmt.Errorf("working directory %q is not a directory", candidate)
+	}()
+	var shell string
+	defer func() {
+		// Killed steps did not fail on their own, so their environment
+		// explains nothing.
+		if err != nil && ctx.Err() == nil {
+			result.EnvSnapshot = snapshotEnv(env, shell, r.opts.ToolVersions)
 		}
-		return candidate, nil
+	}()
+	cmdArgs, err := r.resolver.Command(wf, job, step, env)
+	if err != nil {
+		return notStarted(result, err)
 	}
-	if root == "" {
-		var err error
-		root, err = os.Getwd()
-		if err != nil {
-			return "", fmt.Errorf("determine working directory: %w", err)
+	if !r.opts.AllowUnresolvedExpressions {
+		if err := unresolvedExpression(wf, job, step, cmdArgs, env); err != nil {
+			result.Stderr = err.Error()
+			result.Hint = unresolvedExpressionHint
+			result.ExitCode = 127
+			return err
 		}
 	}
-	return root, nil
-}
 
-func mergeEnv(base []string, overlays ...map[string]string) []string {
-	envMap := make(map[string]string, len(base)+len(overlays)*4)
-	for _, kv := range base {
-		if idx := strings.Index(kv, "="); idx != -1 {
-			key := kv[:idx]
-			envMap[key] = kv[idx+1:]
+	shell = cmdArgs[0]
+
+	workingDir, _, err := r.resolver.WorkingDirectory(r.opts.Root, wf, job, step)
+	if err != nil {
+		return notStarted(result, err)
+	}
+
+	script := step.Run
+	var autoPath []string
+	if r.opts.AutoPath {
+		env, autoPath = resolve.PrependPath(env, r.resolver.ProjectBinDirs(r.opts.Root, workingDir))
+		shellSpec, _ := resolve.Shell(wf, job, step)
+		if export := resolve.PathExport(shellSpec, autoPath); export != "" {
+			withPath := step
+			withPath.Run = export + step.Run
+			script = withPath.Run
+			if cmdArgs, err = r.resolver.Command(wf, job, withPath, env); err != nil {
+				return notStarted(result, err)
+			}
+		}
+	}
+
+	if problem := checkLocalScript(cmdArgs[0], step.Run, workingDir); problem != nil {
+		result.Stderr = problem.Error()
+		result.Hint = problem.hint
+		result.ExitCode = problem.exitCode
+		return problem
+	}
+
+	if r.opts.Verbose {
+		var end func()
+		out, end = r.foldStep(out, wf, job, step)
+		defer end()
+		if raw, _ := resolve.RawWorkingDirectory(wf, job, step); raw != "" {
+			if _, mapping, ok := r.opts.PathMap.Apply(raw); ok {
+				fmt.Fprintf(out.stderr, "info: path mapping %s -> %s applied to working-directory %s\n", mapping.From, mapping.To, raw)
+			}
+		}
+		for _, key := range append(append(wfMapped, jobMapped...), stepMapped...) {
+			fmt.Fprintf(out.stderr, "info: path mapping applied to env %s\n", key)
+		}
+		for _, dir := range autoPath {
+			fmt.Fprintf(out.stderr, "info: auto path added %s to PATH\n", r.displayPath(dir))
+		}
+	}
+	cmdArgs, removeScript, err := writeScriptFile(r.tempDir, cmdArgs, script)
+	if err != nil {
+		return notStarted(result, err)
+	}
+	defer removeScript()
+	r.opts.Logger.Debug("step command resolved", "workflow", wf.Path, "job", job.Name, "step", step.Name, "shell", cmdArgs[0], "cwd", workingDir, "env", len(env))
+	spec := ExecSpec{Args: cmdArgs, Dir: workingDir, Env: env}
+
+	stdoutBuf, stderrBuf, combinedBuf := newCappedBuffer(), newCappedBuffer(), newCappedBuffer()
+	switch {
+	case r.opts.CombineOutput:
+		// Handing the executor the same writer for both streams gives the child one
+		// pipe, so the transcript keeps the order it wrote in.
+		var w io.Writer = combinedBuf
+		if r.opts.Verbose {
+			w = io.MultiWriter(out.stdout, combinedBuf)
+		}
+		spec.Stdout = w
+		spec.Stderr = w
+	case r.opts.Verbose:
+		spec.Stdout = io.MultiWriter(out.stdout, stdoutBuf)
+		spec.Stderr = io.MultiWriter(out.stderr, stderrBuf)
+	default:
+		spec.Stdout = stdoutBuf
+		spec.Stderr = stderrBuf
+	}
+
+	if spec.Stdout == spec.Stderr {
+		spec.Stdout = redact.writer(spec.Stdout)
+		spec.Stderr = spec.Stdout
+	} else {
+		spec.Stdout, spec.Stderr = redact.writer(spec.Stdout), redact.writer(spec.Stderr)
+	}
+	redacted := []io.Writer{spec.Stdout, spec.Stderr}
+
+	act := newActivity(r.opts.Clock)
+	if spec.Stdout == spec.Stderr {
+		spec.Stdout = act.wrap(spec.Stdout)
+		spec.Stderr = spec.Stdout
+	} else {
+		spec.Stdout, spec.Stderr = act.wrap(spec.Stdout), act.wrap(spec.Stderr)
+	}
+	stepCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stopWatch := r.watchStall(act, func(silent time.Duration) {
+		r.warnStall(wf, job, step, jobID, silent)
+	}, func(silent time.Duration) {
+		cancel(&stallError{silent: silent})
+	})
+	ran, err := r.opts.Executor.Execute(stepCtx, spec)
+	stopWatch()
+	for _, w := range redacted {
+		flushRedacted(w)
+	}
+	result.Stdout = stdoutBuf.String()
+	result.Stderr = simplifyError(stderrBuf.String())
+	result.CombinedOutput = simplifyError(combinedBuf.String())
+	result.ExitCode = ran.ExitCode
+	if result.ExitCode == exitCommandNotFound {
+		loc := hintLocationsFromEnv(env, workingDir)
+		result.Hint = commandNotFoundHint(missingCommand(step.Run, result.Stderr+result.CombinedOutput, loc), loc)
+	}
+
+	var stalled *stallError
+	if err != nil && ctx.Err() == nil && errors.As(context.Cause(stepCtx), &stalled) {
+		result.Stderr = strings.TrimRight(result.Stderr, "\n")
+		if result.Stderr != "" {
+			result.Stderr += "\n"
 		}
+		result.Stderr += fmt.Sprintf("testdrive: killed after %s", stalled)
+		result.Hint = stallHint
+	}
+
+	if err != nil && ctx.Err() == nil {
+		result.Annotations = annotations(r.opts.Root, workingDir, result.Stdout+"\n"+result.Stderr+"\n"+result.CombinedOutput)
 	}
-	for _, overlay := range overlays {
-		for k, v := range overlay {
-			envMap[k] = v
+
+	if err != nil {
+		// ensure stderr populated for messaging when verbose life.
+		if !r.opts.Verbose {
+			// nothing extra needed, tail applied by caller
 		}
+		return err
+	}
+	return nil
+}
+
+// skipOptions returns the runtime skip rules for step from the runner
+// options.
+func (r *Runner) skipOptions(wf provider.Workflow, job provider.Job, step provider.Step) resolve.SkipOptions {
+	return resolve.SkipOptions{
+		AllowPrivileged:     r.opts.AllowPrivileged,
+		PrivilegedPatterns:  r.opts.PrivilegedPatterns,
+		AllowedEnvironments: r.opts.AllowedEnvironments,
+		AllowDestructive:    r.opts.AllowDestructive,
+		DestructivePatterns: r.opts.DestructivePatterns,
+		Env:                 resolve.MergeEnv(r.opts.Env, r.workspaceEnv(), wf.Env, job.Env, step.Env),
 	}
-	keys := make([]string, 0, len(envMap))
-	for k := range envMap {
-		keys = append(keys, k)
+}
+
+// confirmed reports whether a step skipped for reason should run anyway
+// because the user confirmed it. Only destructive skips are offered, and
+// never in a dry run.
+func (r *Runner) confirmed(wf provider.Workflow, job provider.Job, step provider.Step, reason, msg string) bool {
+	if reason != report.ReasonDestructive || r.opts.DryRun || r.opts.ConfirmDestructive == nil {
+		return false
 	}
-	sort.Strings(keys)
-	out := make([]string, 0, len(keys))
-	for _, k := range keys {
-		out = append(out, fmt.Sprintf("%s=%s", k, envMap[k]))
+	r.confirmMu.Lock()
+	defer r.confirmMu.Unlock()
+	return r.opts.ConfirmDestructive(wf, job, step, msg)
+}
+
+// displayPath returns path relative to the runner root when it is inside it.
+func (r *Runner) displayPath(path string) string {
+	if rel, err := filepath.Rel(r.opts.Root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// workspaceEnv points GITHUB_WORKSPACE at the runner root, as Actions does
+// for the checked-out repository.
+func (r *Runner) workspaceEnv() map[string]string {
+	if r.opts.Root == "" {
+		return nil
 	}
-	return out
+	return map[string]string{"GITHUB_WORKSPACE": r.opts.Root}
 }
 
 func exitCode(err error) int {
@@ -435,25 +987,6 @@ func tailLines(input string, maxLines int) string {
 	return strings.Join(lines[len(lines)-maxLines:], "\n")
 }
 
-func shouldSkipStep(script string, opts Options) (string, bool) {
-	if opts.AllowPrivileged {
-		return "", false
-	}
-	for _, pattern := range opts.PrivilegedPatterns {
-		if pattern == "" {
-			continue
-		}
-		matched, err := regexp.MatchString(pattern, script)
-		if err != nil {
-			continue
-		}
-		if matched {
-            return fmt.Sprintf("skipped privileged command matching pattern %q; set TESTDRIVE_ALLOW_PRIVILEGED=1 to run", pattern), true
-		}
-	}
-	return "", false
-}
-
 var bundlerVersionRegex = regexp.MustCompile(`bundler' \((\d+\.\d+(?:\.\d+)?)\)`)
 
 func simplifyError(stderr string) string {
@@ -476,78 +1009,16 @@ func parseBundlerVersion(stderr string) string {
 	return match[1]
 }
 
-func getEnvValue(env []string, key string) string {
-	for _, kv := range env {
-		if idx := strings.Index(kv, "="); idx != -1 && kv[:idx] == key {
-			return kv[idx+1:]
-		}
-	}
-	return ""
-}
-
-func getAsdfInit(env []string, shellBase string) string {
-	// Determine asdf script path
-	var asdfPath string
-	// Check ASDF_DIR from environment first
-	if asdfDir := getEnvValue(env, "ASDF_DIR"); asdfDir != "" {
-		// Use filepath.Join for safe path construction and validate the path
-		asdfPath = filepath.Join(asdfDir, "asdf.sh")
-		if _, err := os.Stat(asdfPath); err != nil {
-			asdfPath = ""
-		}
-	}
-	// Fallback to HOME from environment, then os.UserHomeDir()
-	if asdfPath == "" {
-		home := getEnvValue(env, "HOME")
-		if home == "" {
-			if homeDir, err := os.UserHomeDir(); err == nil {
-				home = homeDir
-			}
-		}
-		if home != "" {
-			asdfPath = filepath.Join(home, ".asdf", "asdf.sh")
-			if _, err := os.Stat(asdfPath); err != nil {
-				asdfPath = ""
-			}
-		}
-	}
-	if asdfPath == "" {
-		return ""
-	}
-	// Return shell-specific initialization string
-	switch shellBase {
-	case "bash", "zsh":
-		return fmt.Sprintf("source %q && ", asdfPath)
-	case "ksh", "sh":
-		return fmt.Sprintf(". %q && ", asdfPath)
-	case "fish":
-		// fish uses different syntax and file extension
-		fishPath := strings.TrimSuffix(asdfPath, ".sh") + ".fish"
-		if _, err := os.Stat(fishPath); err == nil {
-			return fmt.Sprintf("source %q; ", fishPath)
-		}
-		// Fallback to bash script if fish version doesn't exist
-		return fmt.Sprintf("source %q; ", asdfPath)
-	default:
-		// For unknown shells, skip asdf initialization to avoid errors
-		return ""
-	}
+// DefaultDestructivePatterns returns the built-in expressions for commands
+// that delete data or infrastructure. Recursive removals of paths built from
+// unset variables are caught separately, since a pattern cannot see the
+// environment.
+func DefaultDestructivePatterns() []string {
+	return patterns.Exprs(patterns.Builtin(patterns.Destructive))
 }
 
+// DefaultPrivilegedPatterns returns the built-in expressions for commands
+// that need root or change the host's installed software.
 func DefaultPrivilegedPatterns() []string {
-	return []string{
-		`(?i)^sudo\b`,           // sudo commands
-		`(?i)\bapt-get\b`,       // Debian/Ubuntu package manager
-		`(?i)\bapt\b`,           // Modern apt command
-		`(?i)\byum\b`,           // Red Hat package manager
-		`(?i)\bdnf\b`,           // Fedora package manager
-		`(?i)\bzypper\b`,        // SUSE package manager
-		`(?i)\bpacman\b`,        // Arch package manager
-		`(?i)\bbrew\b`,          // macOS package manager (can require sudo)
-		`(?i)\bchoco\b`,         // Windows package manager
-		`(?i)\bwinget\b`,        // Windows package manager
-		`(?i)\bpip\s+install\s+--user`, // pip install --user (can require sudo)
-		`(?i)\bnpm\s+install\s+-g`,     // npm install -g (can require sudo)
-		`(?i)\byarn\s+global`,          // yarn global (can require sudo)
-	}
+	return patterns.Exprs(patterns.Builtin(patterns.Privileged))
 }
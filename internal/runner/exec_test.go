@@ -1,7 +1,9 @@
 package runner
 
 import (
+	"context"
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -9,6 +11,8 @@ import (
 	"testing"
 
     "github.com/bgricker/testdrive/internal/provider"
+    "github.com/bgricker/testdrive/internal/report"
+    "github.com/bgricker/testdrive/internal/resolve"
 )
 
 func TestRunnerDryRun(t *testing.T) {
@@ -16,7 +20,7 @@ func TestRunnerDryRun(t *testing.T) {
 	r := New(opts)
 	wf := sampleWorkflow("echo hi")
 
-	results, summary, err := r.Run([]provider.Workflow{wf})
+	results, summary, err := r.Run(context.Background(), []provider.Workflow{wf})
 	if err != nil {
 		t.Fatalf("runner Run: %v", err)
 	}
@@ -31,13 +35,34 @@ func TestRunnerDryRun(t *testing.T) {
 	}
 }
 
+func TestRunnerReportsPipelineTotals(t *testing.T) {
+	wf := sampleWorkflow("echo hi")
+	_, summary, err := New(Options{DryRun: true}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if summary.TotalWorkflows != 1 || summary.TotalJobs != 1 || summary.TotalSteps != 1 || summary.SelectedJobs != 1 || summary.SelectedSteps != 1 {
+		t.Fatalf("expected the selection as the totals, got %+v", summary)
+	}
+
+	totals := report.Totals{Workflows: 2, Jobs: 5, Steps: 12}
+	_, summary, err = New(Options{DryRun: true, Totals: totals}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if summary.TotalWorkflows != 2 || summary.TotalJobs != 5 || summary.TotalSteps != 12 || summary.SelectedJobs != 1 || summary.SelectedSteps != 1 {
+		t.Fatalf("expected the pipeline totals beside the selection, got %+v", summary)
+	}
+}
+
 func TestRunnerExecSuccess(t *testing.T) {
 	root := t.TempDir()
 	stdout := &bytes.Buffer{}
-	r := New(Options{Root: root, Stdout: stdout})
+	fake := &fakeExecutor{commands: map[string]fakeCommand{"echo hi": {stdout: "hi\n"}}}
+	r := New(Options{Root: root, Stdout: stdout, Executor: fake})
 	wf := sampleWorkflow("echo hi")
 
-	results, summary, err := r.Run([]provider.Workflow{wf})
+	results, summary, err := r.Run(context.Background(), []provider.Workflow{wf})
 	if err != nil {
 		t.Fatalf("runner Run: %v", err)
 	}
@@ -51,17 +76,18 @@ func TestRunnerExecSuccess(t *testing.T) {
 
 func TestRunnerExecFailure(t *testing.T) {
 	root := t.TempDir()
-	r := New(Options{Root: root})
+	fake := &fakeExecutor{commands: map[string]fakeCommand{"exit 3": {exitCode: 3}}}
+	r := New(Options{Root: root, Executor: fake})
 	wf := sampleWorkflow("exit 3")
 
-	results, summary, err := r.Run([]provider.Workflow{wf})
+	results, summary, err := r.Run(context.Background(), []provider.Workflow{wf})
 	if err != nil {
 		t.Fatalf("runner Run: %v", err)
 	}
 	if summary.Failed != 1 || summary.ExitCode != 1 {
 		t.Fatalf("expected failure summary, got %+v", summary)
 	}
-	if results[0].Status != "failed" || results[0].ExitCode == 0 {
+	if results[0].Status != "failed" || results[0].ExitCode != 3 {
 		t.Fatalf("unexpected result: %+v", results[0])
 	}
 }
@@ -92,7 +118,7 @@ func TestRunnerEnvMerge(t *testing.T) {
 		},
 	}
 
-	results, _, err := r.Run([]provider.Workflow{wf})
+	results, _, err := r.Run(context.Background(), []provider.Workflow{wf})
 	if err != nil {
 		t.Fatalf("runner Run: %v", err)
 	}
@@ -101,6 +127,26 @@ func TestRunnerEnvMerge(t *testing.T) {
 	}
 }
 
+func TestRunnerExpandsEnvReferences(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	root := t.TempDir()
+	r := New(Options{Root: root, Env: []string{"PATH=" + os.Getenv("PATH"), "HOME=/home/dev"}})
+	wf := sampleWorkflow(`printf '%s|%s' "$BUNDLE_PATH" "$GEM_HOME"`)
+	wf.Jobs[0].Env = map[string]string{"BUNDLE_PATH": "${GITHUB_WORKSPACE}/vendor/bundle"}
+	wf.Jobs[0].Steps[0].Env = map[string]string{"GEM_HOME": "$BUNDLE_PATH/gems", "PATH": "$HOME/.local/bin:$PATH"}
+
+	results, _, err := r.Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	want := root + "/vendor/bundle|" + root + "/vendor/bundle/gems"
+	if results[0].Status != "passed" || results[0].Stdout != want {
+		t.Fatalf("expected %q, got %+v", want, results[0])
+	}
+}
+
 func TestRunnerWorkingDirectory(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("working directory test uses POSIX commands")
@@ -128,7 +174,7 @@ func TestRunnerWorkingDirectory(t *testing.T) {
 		},
 	}
 
-	results, _, err := r.Run([]provider.Workflow{wf})
+	results, _, err := r.Run(context.Background(), []provider.Workflow{wf})
 	if err != nil {
 		t.Fatalf("runner Run: %v", err)
 	}
@@ -137,15 +183,55 @@ func TestRunnerWorkingDirectory(t *testing.T) {
 	}
 }
 
-func TestRunnerTailCapture(t *testing.T) {
+func TestRunnerAutoPath(t *testing.T) {
 	if runtime.GOOS == "windows" {
-		t.Skip("tail capture test requires POSIX tools")
+		t.Skip("requires a POSIX shell")
+	}
+	root := t.TempDir()
+	bin := filepath.Join(root, "web", "node_modules", ".bin")
+	if err := os.MkdirAll(filepath.Join(root, "web", "src"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "testdrive-lint"), []byte("#!/bin/sh\necho linted\n"), 0o755); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	workflows := []provider.Workflow{{Path: "ci.yml", Jobs: []provider.Job{{
+		Name:     "lint",
+		Defaults: provider.Defaults{WorkingDirectory: "web/src"},
+		Steps:    []provider.Step{{Name: "Lint", Run: "testdrive-lint"}},
+	}}}}
+
+	stderr := &bytes.Buffer{}
+	results, _, err := New(Options{Root: root, AutoPath: true, Verbose: true, Stderr: stderr}).Run(context.Background(), workflows)
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if results[0].Status != "passed" || !strings.Contains(results[0].Stdout, "linted") {
+		t.Fatalf("expected the project binary to run, got %+v", results[0])
+	}
+	if !strings.Contains(stderr.String(), "info: auto path added web/node_modules/.bin to PATH\n") {
+		t.Fatalf("expected a verbose note, got %q", stderr.String())
+	}
+
+	results, _, err = New(Options{Root: root}).Run(context.Background(), workflows)
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if results[0].Status != "failed" || results[0].ExitCode != 127 {
+		t.Fatalf("expected command not found without auto path, got %+v", results[0])
 	}
+}
+
+func TestRunnerTailCapture(t *testing.T) {
 	root := t.TempDir()
-	r := New(Options{Root: root, TailLines: 2})
-	wf := sampleWorkflow("printf '1\n2\n3\n'; exit 1")
+	fake := &fakeExecutor{commands: map[string]fakeCommand{"make test": {stdout: "1\n2\n3\n", exitCode: 1}}}
+	r := New(Options{Root: root, TailLines: 2, Executor: fake})
+	wf := sampleWorkflow("make test")
 
-	results, _, err := r.Run([]provider.Workflow{wf})
+	results, _, err := r.Run(context.Background(), []provider.Workflow{wf})
 	if err != nil {
 		t.Fatalf("runner Run: %v", err)
 	}
@@ -159,7 +245,7 @@ func TestRunnerSkipsPrivilegedCommands(t *testing.T) {
 	r := New(Options{Root: root})
 	wf := sampleWorkflow("sudo apt-get update")
 
-	results, summary, err := r.Run([]provider.Workflow{wf})
+	results, summary, err := r.Run(context.Background(), []provider.Workflow{wf})
 	if err != nil {
 		t.Fatalf("runner Run: %v", err)
 	}
@@ -169,8 +255,8 @@ func TestRunnerSkipsPrivilegedCommands(t *testing.T) {
 	if results[0].Status != "skipped" {
 		t.Fatalf("expected step skipped, got %+v", results[0])
 	}
-	if !strings.Contains(results[0].Stderr, "pattern") {
-		t.Fatalf("expected skip message referencing pattern, got %q", results[0].Stderr)
+	if results[0].SkipReason != report.ReasonPrivileged || !strings.Contains(results[0].SkipDetail, "pattern") || results[0].Stderr != "" {
+		t.Fatalf("expected a privileged skip detail referencing the pattern, got %+v", results[0])
 	}
 }
 
@@ -179,7 +265,7 @@ func TestRunnerAllowsPrivilegedCommands(t *testing.T) {
 	r := New(Options{Root: root, AllowPrivileged: true})
 	wf := sampleWorkflow("sudo apt-get update")
 
-	results, summary, err := r.Run([]provider.Workflow{wf})
+	results, summary, err := r.Run(context.Background(), []provider.Workflow{wf})
 	if err != nil {
 		t.Fatalf("runner Run: %v", err)
 	}
@@ -191,6 +277,90 @@ func TestRunnerAllowsPrivilegedCommands(t *testing.T) {
 	}
 }
 
+func TestDefaultDestructivePatterns(t *testing.T) {
+	cases := map[string]bool{
+		"rm -rf /":                               true,
+		"rm -rf /*":                              true,
+		"sudo rm -rf --no-preserve-root $DIR":    true,
+		"psql -c 'DROP DATABASE app'":            true,
+		"bin/rails db:drop db:create":            true,
+		"terraform apply -auto-approve":          true,
+		"terraform -chdir=infra destroy":         true,
+		"kubectl -n prod delete pod web":         true,
+		"aws s3 rm s3://bucket/logs --recursive": true,
+		"rm -rf /tmp/build":                      false,
+		"rm -rf ./dist":                          false,
+		"terraform plan -out tf.plan":            false,
+		"kubectl get pods":                       false,
+		"aws s3 rm s3://bucket/logs/today.txt":   false,
+		"echo 'drop the database later' > notes": false,
+	}
+	for script, want := range cases {
+		_, _, skip := resolve.Skip(provider.Job{}, provider.Step{Run: script}, resolve.SkipOptions{DestructivePatterns: DefaultDestructivePatterns(), Env: []string{"DIR=x"}})
+		if skip != want {
+			t.Errorf("%q: skipped = %v, want %v", script, skip, want)
+		}
+	}
+}
+
+func TestRunnerSkipsDestructiveCommands(t *testing.T) {
+	wf := sampleWorkflow("echo wipe")
+	opts := Options{Root: t.TempDir(), DestructivePatterns: []string{`\bwipe\b`}}
+
+	results, _, err := New(opts).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if results[0].Status != "skipped" || results[0].SkipReason != report.ReasonDestructive || !strings.Contains(results[0].SkipDetail, "--allow-destructive") {
+		t.Fatalf("expected a destructive skip, got %+v", results[0])
+	}
+
+	var asked []string
+	opts.ConfirmDestructive = func(_ provider.Workflow, _ provider.Job, step provider.Step, detail string) bool {
+		asked = append(asked, step.Run)
+		return true
+	}
+	results, _, err = New(opts).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if results[0].Status != "passed" || len(asked) != 1 {
+		t.Fatalf("expected the confirmed step to run once asked, got %+v (asked %q)", results[0], asked)
+	}
+
+	opts.ConfirmDestructive = nil
+	opts.AllowDestructive = true
+	if results, _, err = New(opts).Run(context.Background(), []provider.Workflow{wf}); err != nil || results[0].Status != "passed" {
+		t.Fatalf("expected AllowDestructive to run the step, got %+v, %v", results, err)
+	}
+}
+
+func TestRunnerSkipsProtectedEnvironments(t *testing.T) {
+	root := t.TempDir()
+	wf := sampleWorkflow("echo deploy")
+	wf.Jobs[0].Environment = "production"
+
+	results, summary, err := New(Options{Root: root, AllowedEnvironments: []string{"staging"}}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if summary.Skipped != 1 || results[0].SkipReason != report.ReasonEnvironment {
+		t.Fatalf("expected environment skip, got %+v", results[0])
+	}
+	want := "targets environment 'production'; pass --allow-environment production to run"
+	if results[0].SkipDetail != want {
+		t.Fatalf("unexpected skip detail %q", results[0].SkipDetail)
+	}
+
+	results, _, err = New(Options{Root: root, AllowedEnvironments: []string{"Production"}}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if results[0].Status != "passed" {
+		t.Fatalf("allowed environments should match case-insensitively, got %+v", results[0])
+	}
+}
+
 func TestSimplifyErrorBundler(t *testing.T) {
 	msg := "Could not find 'bundler' (2.6.9) required by your Gemfile.lock"
 	simplified := simplifyError(msg)
@@ -199,6 +369,55 @@ func TestSimplifyErrorBundler(t *testing.T) {
 	}
 }
 
+// TestRunnerSkipSitesSetReason runs a workflow that reaches every runtime
+// skip and checks each skipped result carries a reason code and a detail in
+// their own fields rather than in Stderr.
+func TestRunnerSkipSitesSetReason(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	wf := matrixWorkflow(provider.Strategy{FailFast: true})
+	wf.Jobs[1].Steps[0].Run = "exit 1"
+	wf.Jobs = append(wf.Jobs,
+		provider.Job{Name: "deploy", RawID: "deploy", Environment: "production", Steps: []provider.Step{{Name: "ship", Run: "echo ship"}}},
+		provider.Job{Name: "misc", RawID: "misc", Steps: []provider.Step{
+			{Name: "override", Run: "echo off", Skip: true},
+			{Name: "privileged", Run: "sudo true"},
+			{Name: "destructive", Run: "terraform apply"},
+			{Name: "first", Run: "echo same"},
+			{Name: "again", Run: "echo same"},
+		}},
+	)
+
+	results, _, err := New(Options{Root: t.TempDir(), Dedupe: true, Env: []string{"PATH=" + os.Getenv("PATH")}}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, res := range results {
+		if res.Status != "skipped" {
+			continue
+		}
+		if res.SkipReason == "" || res.SkipDetail == "" || res.Stderr != "" {
+			t.Fatalf("expected a reason and detail without stderr, got %+v", res)
+		}
+		seen[res.SkipReason] = true
+	}
+	for _, reason := range []string{report.ReasonCancelled, report.ReasonEnvironment, report.ReasonOverride, report.ReasonPrivileged, report.ReasonDestructive, report.ReasonDuplicate} {
+		if !seen[reason] {
+			t.Errorf("no skipped step with reason %s in %+v", reason, results)
+		}
+	}
+
+	results, _, err = New(Options{Root: t.TempDir(), DryRun: true}).Run(context.Background(), []provider.Workflow{sampleWorkflow("echo hi")})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if results[0].SkipReason != report.ReasonDryRun || results[0].Stderr != "" {
+		t.Fatalf("expected a dry-run reason, got %+v", results[0])
+	}
+}
+
 func sampleWorkflow(script string) provider.Workflow {
 	return provider.Workflow{
 		Path: "wf.yml",
@@ -226,3 +445,172 @@ func pwdCommand() string {
 	}
 	return "pwd"
 }
+
+func TestRunnerSkipsOverriddenSteps(t *testing.T) {
+	root := t.TempDir()
+	r := New(Options{Root: root})
+	wf := sampleWorkflow("exit 1")
+	wf.Jobs[0].Steps[0].Skip = true
+	wf.Jobs[0].Steps[0].Overridden = true
+
+	results, summary, err := r.Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if summary.Skipped != 1 || summary.ExitCode != 0 {
+		t.Fatalf("expected override skip, got %+v", summary)
+	}
+	if !results[0].Overridden || results[0].SkipReason != report.ReasonOverride {
+		t.Fatalf("expected overridden skip result, got %+v", results[0])
+	}
+}
+
+func TestRunnerCombineOutputKeepsOrder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	script := "echo out1; echo err1 >&2; echo out2; echo err2 >&2; exit 1"
+	for _, verbose := range []bool{false, true} {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		r := New(Options{Root: t.TempDir(), CombineOutput: true, Verbose: verbose, Stdout: stdout, Stderr: stderr})
+
+		results, _, err := r.Run(context.Background(), []provider.Workflow{sampleWorkflow(script)})
+		if err != nil {
+			t.Fatalf("runner Run: %v", err)
+		}
+		// The shell's startup can print first, so only the tail is checked.
+		want := "out1\nerr1\nout2\nerr2"
+		got := results[0]
+		if !strings.HasSuffix(strings.TrimSpace(got.CombinedOutput), want) || got.Stdout != "" || got.Stderr != "" {
+			t.Fatalf("verbose=%v: expected one ordered transcript, got %+v", verbose, got)
+		}
+		if verbose && (!strings.HasSuffix(strings.TrimSpace(stdout.String()), want) || stderr.Len() != 0) {
+			t.Fatalf("expected verbose output in order on stdout, got %q and %q", stdout.String(), stderr.String())
+		}
+	}
+
+	results, _, err := New(Options{Root: t.TempDir()}).Run(context.Background(), []provider.Workflow{sampleWorkflow(script)})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if got := results[0]; got.CombinedOutput != "" || strings.TrimSpace(got.Stdout) != "out1\nout2" || !strings.HasSuffix(strings.TrimSpace(got.Stderr), "err1\nerr2") {
+		t.Fatalf("expected separate streams by default, got %+v", got)
+	}
+}
+
+func TestRunnerFailsUnresolvedExpressions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	root := t.TempDir()
+	for _, tc := range []struct {
+		name string
+		edit func(*provider.Workflow)
+		want string
+	}{
+		{
+			name: "run",
+			edit: func(wf *provider.Workflow) { wf.Jobs[0].Steps[0].Run = "npm publish --token ${{ secrets.NPM_TOKEN }}" },
+			want: "unresolved expression `${{ secrets.NPM_TOKEN }}` in run",
+		},
+		{
+			name: "env",
+			edit: func(wf *provider.Workflow) { wf.Jobs[0].Env = map[string]string{"SHA": "${{ github.sha }}"} },
+			want: "unresolved expression `${{ github.sha }}` in env SHA",
+		},
+		{
+			name: "working directory",
+			edit: func(wf *provider.Workflow) { wf.Jobs[0].Steps[0].WorkingDirectory = "${{ inputs.dir }}/app" },
+			want: "unresolved expression `${{ inputs.dir }}` in working-directory",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			wf := sampleWorkflow("echo ran")
+			tc.edit(&wf)
+
+			results, _, err := New(Options{Root: root}).Run(context.Background(), []provider.Workflow{wf})
+			if err != nil {
+				t.Fatalf("runner Run: %v", err)
+			}
+			got := results[0]
+			if got.Status != "failed" || got.Stderr != tc.want || got.Hint != unresolvedExpressionHint || got.Stdout != "" {
+				t.Fatalf("expected the step to fail before starting, got %+v", got)
+			}
+		})
+	}
+
+	wf := sampleWorkflow("echo '${{ github.sha }}'")
+	results, _, err := New(Options{Root: root, AllowUnresolvedExpressions: true}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if got := results[0]; got.Status != "passed" || !strings.HasSuffix(strings.TrimSpace(got.Stdout), "${{ github.sha }}") {
+		t.Fatalf("expected the expression to pass through, got %+v", got)
+	}
+}
+
+// recordingRenderer logs each streaming call as one line.
+type recordingRenderer struct {
+	calls []string
+}
+
+func (r *recordingRenderer) InitializeAllJobs(workflows []provider.Workflow) error {
+	r.calls = append(r.calls, fmt.Sprintf("InitializeAllJobs %d", len(workflows)))
+	return nil
+}
+
+func (r *recordingRenderer) StartJob(jobID string) error {
+	r.calls = append(r.calls, "StartJob "+jobID)
+	return nil
+}
+
+func (r *recordingRenderer) InitializeWorkflow(workflowName, jobName string, stepCount int) error {
+	return nil
+}
+
+func (r *recordingRenderer) StartStep(jobID, stepName string) error {
+	r.calls = append(r.calls, "StartStep "+stepName)
+	return nil
+}
+
+func (r *recordingRenderer) CompleteStep(jobID, stepName string, result report.StepResult) error {
+	r.calls = append(r.calls, fmt.Sprintf("CompleteStep %s %s %s %q %s", stepName, result.Status, result.SkipReason, result.StepRun, result.Duration))
+	return nil
+}
+
+func (r *recordingRenderer) CompleteJob(jobID string) error {
+	r.calls = append(r.calls, "CompleteJob "+jobID)
+	return nil
+}
+
+func (r *recordingRenderer) RenderSummary(summary report.Summary) error {
+	r.calls = append(r.calls, fmt.Sprintf("RenderSummary %d skipped", summary.Skipped))
+	return nil
+}
+
+func (r *recordingRenderer) StartTimer() { r.calls = append(r.calls, "StartTimer") }
+func (r *recordingRenderer) StopTimer()  { r.calls = append(r.calls, "StopTimer") }
+
+func TestRunnerStreamsDryRun(t *testing.T) {
+	wf := sampleWorkflow("make test")
+	wf.Jobs[0].Steps = append(wf.Jobs[0].Steps, provider.Step{Name: "lint", Run: "make lint"})
+	rec := &recordingRenderer{}
+	r := New(Options{Root: t.TempDir(), DryRun: true, Streaming: true, StreamingRenderer: rec})
+
+	if _, _, err := r.Run(context.Background(), []provider.Workflow{wf}); err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	want := []string{
+		"InitializeAllJobs 1",
+		"StartJob wf.yml#job",
+		"StartStep step",
+		`CompleteStep step skipped dry_run "make test" 0s`,
+		"StartStep lint",
+		`CompleteStep lint skipped dry_run "make lint" 0s`,
+		"CompleteJob wf.yml#job",
+		"RenderSummary 2 skipped",
+	}
+	if strings.Join(rec.calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected renderer calls:\n%s", strings.Join(rec.calls, "\n"))
+	}
+}
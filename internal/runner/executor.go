@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// Clock is the runner's source of time: step durations are measured with
+// Now and the teardown grace period waits on After. Tests supply a clock
+// they step by hand.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the wall clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// ExecSpec is a resolved step command ready to run.
+type ExecSpec struct {
+	// Args is the shell and its arguments, ending with the script.
+	Args []string
+	Dir  string
+	Env  []string
+	// Stdout and Stderr receive the command's output. They are the same
+	// writer when output is combined, so the command gets a single pipe.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// ExecResult is how an executed command ended.
+type ExecResult struct {
+	ExitCode int
+}
+
+// Executor runs step commands. Execute returns a non-nil error when the
+// command could not start, exited non-zero, or was killed because ctx was
+// done; ExitCode is set either way.
+type Executor interface {
+	Execute(ctx context.Context, spec ExecSpec) (ExecResult, error)
+}
+
+// processExecutor runs each command as a child process.
+type processExecutor struct{}
+
+func (processExecutor) Execute(ctx context.Context, spec ExecSpec) (ExecResult, error) {
+	cmd := exec.CommandContext(ctx, spec.Args[0], spec.Args[1:]...)
+	cmd.Dir = spec.Dir
+	cmd.Env = spec.Env
+	cmd.Stdout = spec.Stdout
+	cmd.Stderr = spec.Stderr
+	// Cancelling kills the shell, but a child it started can keep the
+	// output pipes open; stop waiting for them shortly after.
+	cmd.WaitDelay = cancelWaitDelay
+	err := cmd.Run()
+	return ExecResult{ExitCode: exitCode(err)}, err
+}
@@ -0,0 +1,304 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/bgricker/testdrive/internal/resolve"
+)
+
+func TestProcessExecutor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	out := &bytes.Buffer{}
+	spec := ExecSpec{Args: []string{"sh", "-c", "echo out; echo err >&2; exit 3"}, Dir: t.TempDir(), Stdout: out, Stderr: out}
+	res, err := processExecutor{}.Execute(context.Background(), spec)
+	if err == nil || res.ExitCode != 3 {
+		t.Fatalf("expected exit 3, got %+v, %v", res, err)
+	}
+	if out.String() != "out\nerr\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	spec.Args = []string{"sh", "-c", "sleep 30"}
+	if _, err := (processExecutor{}).Execute(ctx, spec); err == nil {
+		t.Fatalf("expected a cancelled command to fail")
+	}
+}
+
+func TestRunnerMeasuresStepsOnClock(t *testing.T) {
+	clock := newFakeClock()
+	fake := &fakeExecutor{clock: clock, commands: map[string]fakeCommand{
+		"make build": {took: 3*time.Minute + 12*time.Second},
+		"make test":  {took: 840 * time.Millisecond, exitCode: 2},
+	}}
+	wf := sampleWorkflow("make build")
+	wf.Jobs[0].Steps = append(wf.Jobs[0].Steps, provider.Step{Name: "test", Run: "make test"})
+
+	results, summary, err := New(Options{Root: t.TempDir(), Clock: clock, Executor: fake}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if results[0].Duration != 3*time.Minute+12*time.Second || results[0].DurationMS != 192000 {
+		t.Fatalf("unexpected first duration: %+v", results[0])
+	}
+	if results[1].Duration != 840*time.Millisecond || results[1].ExitCode != 2 {
+		t.Fatalf("unexpected second result: %+v", results[1])
+	}
+	if summary.Passed != 1 || summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestRunnerDedupeExecutesOnce(t *testing.T) {
+	fake := &fakeExecutor{commands: map[string]fakeCommand{"make lint": {}, "exit 1": {exitCode: 1}}}
+	passing := sampleWorkflow("make lint")
+	failing := sampleWorkflow("exit 1")
+	failing.Path = "other.yml"
+
+	_, summary, err := New(Options{Root: t.TempDir(), Dedupe: true, Executor: fake}).Run(context.Background(), []provider.Workflow{passing, passing, failing, failing})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	// A passed step is replayed; a failed one runs again every time.
+	if got := fake.executed(); !reflect.DeepEqual(got, []string{"make lint", "exit 1", "exit 1"}) {
+		t.Fatalf("executed %v", got)
+	}
+	if summary.Deduped != 1 || summary.Failed != 2 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestRunnerCancellationOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fake := &fakeExecutor{commands: map[string]fakeCommand{
+		"first": {},
+		"block": {run: func(ctx context.Context, spec ExecSpec) (ExecResult, error) {
+			cancel()
+			<-ctx.Done()
+			return ExecResult{ExitCode: -1}, ctx.Err()
+		}},
+		"cleanup": {},
+	}}
+	wf := provider.Workflow{Path: "wf.yml", Name: "workflow", Jobs: []provider.Job{{Name: "job", RawID: "job", Steps: []provider.Step{
+		{Name: "first", Run: "first"},
+		{Name: "block", Run: "block"},
+		{Name: "last", Run: "last"},
+		{Name: "post: cleanup", Run: "cleanup"},
+	}}}}
+	later := sampleWorkflow("later")
+
+	results, summary, err := New(Options{Root: t.TempDir(), Executor: fake}).Run(ctx, []provider.Workflow{wf, later})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	// The blocked step is killed, the rest of its job and every later job
+	// are cancelled unstarted, and teardown still runs.
+	if got := fake.executed(); !reflect.DeepEqual(got, []string{"first", "block", "cleanup"}) {
+		t.Fatalf("executed %v", got)
+	}
+	want := [][2]string{{"first", "passed"}, {"block", "skipped"}, {"last", "skipped"}, {"post: cleanup", "passed"}, {"step", "skipped"}}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("results = %v, want %v", got, want)
+	}
+	if results[1].SkipDetail != "cancelled: run interrupted while the step was running" || results[4].SkipReason != report.ReasonCancelled {
+		t.Fatalf("unexpected cancellation details: %+v", results)
+	}
+	if summary.Cancelled != 3 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+// syntheticWorkflow has one job of n steps spread over three working
+// directories under root, each running "make step".
+func syntheticWorkflow(t testing.TB, root string, n int) provider.Workflow {
+	t.Helper()
+	dirs := []string{"api", "web", "docs"}
+	for _, dir := range dirs {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	job := provider.Job{Name: "job", RawID: "job"}
+	for i := 0; i < n; i++ {
+		job.Steps = append(job.Steps, provider.Step{Name: fmt.Sprintf("step %d", i), Run: "make step", WorkingDirectory: dirs[i%len(dirs)]})
+	}
+	return provider.Workflow{Path: "wf.yml", Name: "workflow", Jobs: []provider.Job{job}}
+}
+
+// countingStat is os.Stat that counts its calls.
+func countingStat(n *atomic.Int64) func(string) (os.FileInfo, error) {
+	return func(name string) (os.FileInfo, error) {
+		n.Add(1)
+		return os.Stat(name)
+	}
+}
+
+func TestRunnerProbesPathsOnce(t *testing.T) {
+	root := t.TempDir()
+	wf := syntheticWorkflow(t, root, 30)
+	var stats atomic.Int64
+	fake := &fakeExecutor{commands: map[string]fakeCommand{"make step": {}}}
+
+	_, summary, err := New(Options{Root: root, Env: []string{"HOME=" + t.TempDir()}, Executor: fake, Stat: countingStat(&stats)}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if summary.Passed != 30 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	// Three working directories and one asdf.sh lookup under HOME.
+	if got := stats.Load(); got != 4 {
+		t.Fatalf("expected 4 stats for 30 steps, got %d", got)
+	}
+}
+
+// BenchmarkRunnerDryRun resolves 500 synthetic steps per run without
+// starting a process: the fake executor stands in for the shell, so the
+// cost measured is the runner's own, including filesystem probes. A
+// --dry-run proper skips resolution altogether.
+func BenchmarkRunnerDryRun(b *testing.B) {
+	root := b.TempDir()
+	wf := syntheticWorkflow(b, root, 500)
+	env := []string{"HOME=" + b.TempDir()}
+	fake := &fakeExecutor{commands: map[string]fakeCommand{"make step": {}}}
+	var stats atomic.Int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fake.calls = nil
+		runner := New(Options{Root: root, Env: env, Executor: fake, Stat: countingStat(&stats)})
+		if _, _, err := runner.Run(context.Background(), []provider.Workflow{wf}); err != nil {
+			b.Fatalf("runner Run: %v", err)
+		}
+	}
+	b.ReportMetric(float64(stats.Load())/float64(b.N), "stats/op")
+}
+
+func TestRunnerAnnotatesFailures(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "web"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	trace := "  ● sum › adds\n\n      at Object.<anonymous> (src/sum.test.ts:4:21)\n"
+	panicked := "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t" + filepath.Join(root, "cmd", "main.go") + ":8 +0x1d\n"
+	fake := &fakeExecutor{commands: map[string]fakeCommand{
+		"npx jest": {stdout: trace, exitCode: 1},
+		"go run .": {stderr: panicked, exitCode: 2},
+	}}
+	wf := provider.Workflow{Path: "wf.yml", Name: "workflow", Jobs: []provider.Job{{Name: "job", RawID: "job", Steps: []provider.Step{
+		{Name: "jest", Run: "npx jest", WorkingDirectory: "web"},
+		{Name: "go", Run: "go run ."},
+	}}}}
+
+	results, _, err := New(Options{Root: root, Executor: fake}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	// Relative paths are read from the step's working directory and
+	// absolute ones inside the root are made relative to it.
+	want := []report.Annotation{{Path: "web/src/sum.test.ts", Line: 4, Column: 21, Message: "sum › adds"}}
+	if !reflect.DeepEqual(results[0].Annotations, want) {
+		t.Fatalf("jest annotations = %+v, want %+v", results[0].Annotations, want)
+	}
+	want = []report.Annotation{{Path: "cmd/main.go", Line: 8, Message: "panic: boom"}}
+	if !reflect.DeepEqual(results[1].Annotations, want) {
+		t.Fatalf("panic annotations = %+v, want %+v", results[1].Annotations, want)
+	}
+}
+
+func TestRunnerAppliesPathMap(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "backend"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	var got ExecSpec
+	fake := &fakeExecutor{commands: map[string]fakeCommand{"make": {run: func(ctx context.Context, spec ExecSpec) (ExecResult, error) {
+		got = spec
+		return ExecResult{}, nil
+	}}}}
+	wf := sampleWorkflow("make")
+	wf.Jobs[0].Defaults.WorkingDirectory = "/home/runner/work/app/app/backend"
+	wf.Jobs[0].Steps[0].Env = map[string]string{"CACHE_DIR": "/home/runner/work/app/app/tmp"}
+	stderr := &bytes.Buffer{}
+
+	opts := Options{Root: root, Env: []string{}, Verbose: true, Stderr: stderr, Executor: fake, PathMap: resolve.NewPathMap(map[string]string{"/home/runner/work/app/app": "."}, root)}
+	results, _, err := New(opts).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if results[0].Status != "passed" || got.Dir != filepath.Join(root, "backend") {
+		t.Fatalf("expected the step to run in the mapped directory, got %q: %+v", got.Dir, results[0])
+	}
+	if !slices.Contains(got.Env, "CACHE_DIR="+filepath.Join(root, "tmp")) {
+		t.Fatalf("expected the env value mapped, got %q", got.Env)
+	}
+	for _, want := range []string{"path mapping /home/runner/work/app/app -> " + root + " applied to working-directory", "path mapping applied to env CACHE_DIR"} {
+		if !bytes.Contains(stderr.Bytes(), []byte(want)) {
+			t.Fatalf("expected %q in verbose output:\n%s", want, stderr.String())
+		}
+	}
+}
+
+func TestRunnerFailsJobsWhosePortsAreTaken(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	root := t.TempDir()
+	steps := []provider.Step{
+		{Name: "Boot", Run: fmt.Sprintf("touch booted && echo serving on --port %d", port)},
+		{Name: "Test", Run: "touch tested"},
+	}
+	workflows := []provider.Workflow{{Path: "ci.yml", Jobs: []provider.Job{{Name: "system", Steps: steps}}}}
+
+	results, summary, err := New(Options{Root: root}).Run(context.Background(), workflows)
+	if err != nil || summary.Failed != 0 {
+		t.Fatalf("without port checks the job should run: %v %+v", err, results)
+	}
+	os.Remove(filepath.Join(root, "booted"))
+	os.Remove(filepath.Join(root, "tested"))
+
+	for _, opts := range []Options{{Root: root, CheckPorts: []int{port}}, {Root: root, DetectPorts: true}} {
+		results, _, err := New(opts).Run(context.Background(), workflows)
+		if err != nil {
+			t.Fatalf("runner Run: %v", err)
+		}
+		want := fmt.Sprintf("port %d is in use", port)
+		if results[0].Status != "failed" || !strings.HasPrefix(results[0].Stderr, want) || results[0].Hint == "" {
+			t.Fatalf("expected the first step to fail on the port, got %+v", results[0])
+		}
+		if results[1].Status != "skipped" || results[1].SkipReason != report.ReasonCancelled || !strings.Contains(results[1].SkipDetail, want) {
+			t.Fatalf("expected the rest of the job to be cancelled, got %+v", results[1])
+		}
+		for _, name := range []string{"booted", "tested"} {
+			if _, err := os.Stat(filepath.Join(root, name)); !os.IsNotExist(err) {
+				t.Fatalf("no step should have run, found %s", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// expression matches a ${{ }} expression as written in a workflow.
+var expression = regexp.MustCompile(`\$\{\{.*?\}\}`)
+
+// unresolvedExpressionHint explains the failure unresolvedExpression reports.
+const unresolvedExpressionHint = "testdrive cannot evaluate ${{ }} expressions; replace it with an override or an env value, or pass --allow-unresolved-expressions to run it as written"
+
+// unresolvedExpression returns an error naming the first ${{ }} expression
+// left in what is about to be executed: the script, an env value the
+// workflow sets, or the working directory. env is the merged environment.
+// The working directory is checked as configured, since a path holding an
+// expression would otherwise only fail as not found.
+func unresolvedExpression(wf provider.Workflow, job provider.Job, step provider.Step, cmdArgs, env []string) error {
+	// A script run from a file is not in cmdArgs, so check it as written.
+	for _, arg := range append(cmdArgs[1:len(cmdArgs):len(cmdArgs)], step.Run) {
+		if expr := expression.FindString(arg); expr != "" {
+			return fmt.Errorf("unresolved expression `%s` in run", expr)
+		}
+	}
+
+	values := make(map[string]string, len(env))
+	for _, kv := range env {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			values[name] = value
+		}
+	}
+	var names []string
+	for _, level := range []map[string]string{wf.Env, job.Env, step.Env} {
+		for name := range level {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if expr := expression.FindString(values[name]); expr != "" {
+			return fmt.Errorf("unresolved expression `%s` in env %s", expr, name)
+		}
+	}
+
+	for _, dir := range []string{step.WorkingDirectory, job.Defaults.WorkingDirectory, wf.Defaults.WorkingDirectory} {
+		if strings.TrimSpace(dir) == "" {
+			continue
+		}
+		if expr := expression.FindString(dir); expr != "" {
+			return fmt.Errorf("unresolved expression `%s` in working-directory", expr)
+		}
+		break
+	}
+	return nil
+}
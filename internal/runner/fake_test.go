@@ -0,0 +1,148 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a virtual clock stepped by hand. Channels from After fire
+// once Advance moves the clock to or past their deadline.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d and fires every timer now due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.ch <- c.now
+	}
+	c.timers = pending
+}
+
+// waitForTimers blocks until n timers are pending, for code that starts
+// waiting on the clock from another goroutine.
+func (c *fakeClock) waitForTimers(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		pending := len(c.timers)
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d pending timers, have %d", n, pending)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// fakeCommand scripts how fakeExecutor answers one step script.
+type fakeCommand struct {
+	stdout   string
+	stderr   string
+	exitCode int
+	// took is how far the clock moves while the command runs.
+	took time.Duration
+	// run, when set, is called instead, and may block on ctx, cancel the
+	// run, or step the clock.
+	run func(ctx context.Context, spec ExecSpec) (ExecResult, error)
+}
+
+// fakeExecutor answers commands from a script keyed by each step's run:
+// text, so runner tests need no child processes.
+type fakeExecutor struct {
+	clock    *fakeClock
+	commands map[string]fakeCommand
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (f *fakeExecutor) Execute(ctx context.Context, spec ExecSpec) (ExecResult, error) {
+	script := f.script(spec.Args[len(spec.Args)-1])
+	f.mu.Lock()
+	f.calls = append(f.calls, script)
+	f.mu.Unlock()
+
+	command, ok := f.commands[script]
+	if !ok {
+		fmt.Fprintf(spec.Stderr, "fake: no command scripted for %q\n", spec.Args[len(spec.Args)-1])
+		return ExecResult{ExitCode: 127}, fmt.Errorf("exit status 127")
+	}
+	if command.run != nil {
+		return command.run(ctx, spec)
+	}
+	io.WriteString(spec.Stdout, command.stdout)
+	io.WriteString(spec.Stderr, command.stderr)
+	if f.clock != nil {
+		f.clock.Advance(command.took)
+	}
+	if command.exitCode != 0 {
+		return ExecResult{ExitCode: command.exitCode}, fmt.Errorf("exit status %d", command.exitCode)
+	}
+	return ExecResult{}, nil
+}
+
+// script finds the scripted command that arg, the shell's last argument,
+// runs. Shells prefix the step's script with their init code.
+func (f *fakeExecutor) script(arg string) string {
+	match := arg
+	best := -1
+	for script := range f.commands {
+		if (arg == script || strings.HasSuffix(arg, " "+script)) && len(script) > best {
+			match, best = script, len(script)
+		}
+	}
+	return match
+}
+
+// executed returns the scripts run so far, in order.
+func (f *fakeExecutor) executed() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// foldWriter passes writes to w and remembers whether the last one ended
+// its line, so the markers around a job or step always start a line of
+// their own.
+type foldWriter struct {
+	w io.Writer
+	// open is set when the last write did not end with a newline.
+	open bool
+}
+
+func (f *foldWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if n > 0 {
+		f.open = p[n-1] != '\n'
+	}
+	return n, err
+}
+
+// line writes s as a line of its own. An empty s writes nothing.
+func (f *foldWriter) line(s string) {
+	if s == "" {
+		return
+	}
+	if f.open {
+		s = "\n" + s
+	}
+	f.Write([]byte(s + "\n"))
+}
+
+// foldMarker fills {name} in a fold marker template.
+func foldMarker(template, name string) string {
+	if template == "" {
+		return ""
+	}
+	return strings.ReplaceAll(template, "{name}", name)
+}
+
+// foldJob opens the fold around a job's verbose output. The returned
+// function closes it.
+func (r *Runner) foldJob(out jobOutput, job provider.Job) (jobOutput, func()) {
+	fold := &foldWriter{w: out.stdout}
+	fold.line(foldMarker(r.opts.FoldStart, job.Name))
+	out.stdout = fold
+	return out, func() { fold.line(foldMarker(r.opts.FoldEnd, job.Name)) }
+}
+
+// foldStep writes the header line for a step's verbose output and opens
+// its fold. The returned function closes it. Only the verbose stream gets
+// them; captured output is left alone.
+func (r *Runner) foldStep(out jobOutput, wf provider.Workflow, job provider.Job, step provider.Step) (jobOutput, func()) {
+	fold := &foldWriter{w: out.stdout}
+	fold.line(stepHeader(wf, job, step))
+	fold.line(foldMarker(r.opts.FoldStart, step.Name))
+	out.stdout = fold
+	return out, func() { fold.line(foldMarker(r.opts.FoldEnd, step.Name)) }
+}
+
+// stepHeader returns the line that starts a step's verbose output, e.g.
+// `=== STEP ci.yml/test/3 "Run rspec" ===`, where 3 is the step's place in
+// its job, counted from one. A matrix variant follows the job ID with its
+// values, as in `test (ubuntu-latest)`.
+func stepHeader(wf provider.Workflow, job provider.Job, step provider.Step) string {
+	id := job.RawID
+	if id == "" {
+		id = job.Name
+	}
+	if job.Variant != "" {
+		id += " (" + job.Variant + ")"
+	}
+	return fmt.Sprintf("=== STEP %s/%s/%d %q ===", filepath.Base(wf.Path), id, stepNumber(job, step), step.Name)
+}
+
+// stepNumber returns the place of step in job, counted from one.
+func stepNumber(job provider.Job, step provider.Step) int {
+	for i, s := range job.Steps {
+		if s.ID == step.ID && s.Name == step.Name && s.Run == step.Run {
+			return i + 1
+		}
+	}
+	return 0
+}
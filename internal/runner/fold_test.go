@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestRunnerFoldsVerboseOutput(t *testing.T) {
+	wf := provider.Workflow{
+		Path: ".github/workflows/ci.yml",
+		Name: "CI",
+		Jobs: []provider.Job{{Name: "Test", RawID: "test", Steps: []provider.Step{
+			{Name: "Checkout", Uses: "actions/checkout@v4"},
+			{Name: "Build", Run: "make build"},
+			{Name: "Run rspec", Run: "bundle exec rspec"},
+		}}},
+	}
+	fake := &fakeExecutor{commands: map[string]fakeCommand{
+		"make build": {stdout: "compiled\n", stderr: "note\n"},
+		// No trailing newline: the closing marker still gets a line.
+		"bundle exec rspec": {stdout: "3 examples"},
+	}}
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	r := New(Options{Root: t.TempDir(), Verbose: true, Executor: fake, Stdout: stdout, Stderr: stderr, FoldStart: "##[group]{name}", FoldEnd: "##[endgroup]"})
+
+	results, _, err := r.Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	want := strings.Join([]string{
+		"##[group]Test",
+		`=== STEP ci.yml/test/2 "Build" ===`,
+		"##[group]Build",
+		"compiled",
+		"##[endgroup]",
+		`=== STEP ci.yml/test/3 "Run rspec" ===`,
+		"##[group]Run rspec",
+		"3 examples",
+		"##[endgroup]",
+		"##[endgroup]",
+		"",
+	}, "\n")
+	if stdout.String() != want {
+		t.Fatalf("verbose stdout:\n%s\nwant:\n%s", stdout.String(), want)
+	}
+	if stderr.String() != "note\n" {
+		t.Fatalf("verbose stderr = %q, want the step's stderr alone", stderr.String())
+	}
+	if results[0].Stdout != "compiled\n" || results[1].Stdout != "3 examples" {
+		t.Fatalf("captured stdout = %q, %q; want it free of markers", results[0].Stdout, results[1].Stdout)
+	}
+}
+
+func TestRunnerFoldMarkersOff(t *testing.T) {
+	wf := sampleWorkflow("make build")
+	fake := &fakeExecutor{commands: map[string]fakeCommand{"make build": {stdout: "compiled\n"}}}
+	stdout := &bytes.Buffer{}
+	r := New(Options{Root: t.TempDir(), Verbose: true, Executor: fake, Stdout: stdout, Stderr: &bytes.Buffer{}})
+
+	if _, _, err := r.Run(context.Background(), []provider.Workflow{wf}); err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if want := "=== STEP wf.yml/job/1 \"step\" ===\ncompiled\n"; stdout.String() != want {
+		t.Fatalf("verbose stdout = %q, want %q", stdout.String(), want)
+	}
+}
@@ -0,0 +1,169 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/resolve"
+)
+
+// exitCommandNotFound is the shell's exit status for an unknown command.
+const exitCommandNotFound = 127
+
+// notFoundPatterns extract the missing command from shell diagnostics:
+// bash ("bash: line 1: yarn: command not found"), dash ("sh: 1: yarn: not
+// found"), and zsh ("zsh:1: command not found: yarn").
+var notFoundPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?m)([^\s:]+): command not found\s*$`),
+	regexp.MustCompile(`(?m)command not found: (\S+)`),
+	regexp.MustCompile(`(?m)^[^:\n]+: \d+: ([^\s:]+): not found\s*$`),
+}
+
+var envAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// shellBuiltins are commands a shell runs itself, so they are never missing
+// from PATH.
+var shellBuiltins = map[string]bool{
+	".": true, ":": true, "[": true, "[[": true, "alias": true, "break": true,
+	"case": true, "cd": true, "command": true, "continue": true, "echo": true,
+	"eval": true, "exec": true, "exit": true, "export": true, "false": true,
+	"for": true, "if": true, "local": true, "printf": true, "pwd": true,
+	"read": true, "readonly": true, "return": true, "set": true, "shift": true,
+	"source": true, "test": true, "trap": true, "true": true, "type": true,
+	"ulimit": true, "umask": true, "unset": true, "until": true, "wait": true,
+	"while": true, "{": true, "(": true, "!": true,
+}
+
+// hintLocations describes where to look for a command that was not on PATH.
+type hintLocations struct {
+	WorkDir   string
+	Home      string
+	AsdfData  string
+	SystemBin string
+	Path      string
+}
+
+func hintLocationsFromEnv(env []string, workDir string) hintLocations {
+	home := resolve.EnvValue(env, "HOME")
+	asdfData := resolve.EnvValue(env, "ASDF_DATA_DIR")
+	if asdfData == "" && home != "" {
+		asdfData = filepath.Join(home, ".asdf")
+	}
+	return hintLocations{
+		WorkDir:   workDir,
+		Home:      home,
+		AsdfData:  asdfData,
+		SystemBin: "/usr/local/bin",
+		Path:      resolve.EnvValue(env, "PATH"),
+	}
+}
+
+// missingCommand names the executable a failed step could not find, preferring
+// the shell's own message. Without one it falls back to the script's first
+// command, but only if that command is not on the step's PATH either: a 127
+// can come from deeper in the script, and a command that is there was not
+// what went missing.
+func missingCommand(script, stderr string, loc hintLocations) string {
+	for _, re := range notFoundPatterns {
+		if m := re.FindStringSubmatch(stderr); len(m) == 2 {
+			return m[1]
+		}
+	}
+	for _, line := range strings.Split(script, "\n") {
+		for _, field := range strings.Fields(line) {
+			if envAssignment.MatchString(field) {
+				continue
+			}
+			if strings.HasPrefix(field, "#") {
+				break
+			}
+			if shellBuiltins[field] || findCommand(field, loc) {
+				return ""
+			}
+			return field
+		}
+	}
+	return ""
+}
+
+// findCommand reports whether name resolves to an executable the way the
+// step's shell would look it up: relative to the working directory when it
+// has a slash, otherwise along the step's PATH.
+func findCommand(name string, loc hintLocations) bool {
+	if strings.ContainsRune(name, '/') {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(loc.WorkDir, name)
+		}
+		return isExecutable(name)
+	}
+	for _, dir := range filepath.SplitList(loc.Path) {
+		if dir == "" {
+			dir = loc.WorkDir
+		}
+		if isExecutable(filepath.Join(dir, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandNotFoundHint explains where name can be found locally and why the
+// step may not have seen it, or reports the PATH that was searched.
+func commandNotFoundHint(name string, loc hintLocations) string {
+	if name == "" {
+		return ""
+	}
+	if strings.ContainsRune(name, '/') {
+		return fmt.Sprintf("`%s` does not exist or is not executable relative to %s", name, loc.WorkDir)
+	}
+
+	if loc.WorkDir != "" {
+		candidate := filepath.Join(loc.WorkDir, "node_modules", ".bin", name)
+		if isExecutable(candidate) {
+			return fmt.Sprintf("`%s` exists at node_modules/.bin/%s — did CI rely on setup-node adding it to PATH? try `npm ci` or add node_modules/.bin to PATH", name, name)
+		}
+	}
+	if loc.AsdfData != "" {
+		shim := filepath.Join(loc.AsdfData, "shims", name)
+		if isExecutable(shim) {
+			return fmt.Sprintf("`%s` is an asdf shim at %s but the shims directory is not on PATH; source asdf in your shell profile", name, shim)
+		}
+	}
+	if loc.Home != "" {
+		shim := filepath.Join(loc.Home, ".rbenv", "shims", name)
+		if isExecutable(shim) {
+			return fmt.Sprintf("`%s` is an rbenv shim at %s but rbenv is not initialized; add `eval \"$(rbenv init -)\"` to your shell profile", name, shim)
+		}
+		if matches, _ := filepath.Glob(filepath.Join(loc.Home, ".rbenv", "versions", "*", "bin", name)); len(matches) > 0 {
+			return fmt.Sprintf("`%s` is installed at %s but rbenv is not initialized; add `eval \"$(rbenv init -)\"` to your shell profile", name, matches[0])
+		}
+	}
+	if loc.SystemBin != "" {
+		candidate := filepath.Join(loc.SystemBin, name)
+		if isExecutable(candidate) && !onPath(loc.SystemBin, loc.Path) {
+			return fmt.Sprintf("`%s` exists at %s but %s is not on PATH", name, candidate, loc.SystemBin)
+		}
+	}
+
+	return fmt.Sprintf("`%s` was not found on PATH or in common tool locations; install it locally or check the workflow's setup steps (PATH=%s)", name, loc.Path)
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return info.Mode()&0o111 != 0
+}
+
+func onPath(dir, pathList string) bool {
+	for _, entry := range filepath.SplitList(pathList) {
+		if filepath.Clean(entry) == dir {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestMissingCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("executable bits are not tracked on windows")
+	}
+	root := t.TempDir()
+	for _, file := range []string{"bin/rm", "work/bin/setup"} {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatalf("write %s: %v", file, err)
+		}
+	}
+	loc := hintLocations{WorkDir: filepath.Join(root, "work"), Path: filepath.Join(root, "bin")}
+
+	cases := []struct {
+		name   string
+		script string
+		stderr string
+		want   string
+	}{
+		{"bash message", "make && yarn build", "bash: line 1: yarn: command not found\n", "yarn"},
+		{"message beats a present first command", "rm -rf dist && yarn build", "bash: line 1: yarn: command not found\n", "yarn"},
+		{"dash message", "rake spec", "sh: 1: rake: not found\n", "rake"},
+		{"zsh message", "rake spec", "zsh:1: command not found: rake\n", "rake"},
+		{"first token fallback", "bundle exec rspec", "", "bundle"},
+		{"skips env assignments", "RAILS_ENV=test CI=1 bin/rails test", "", "bin/rails"},
+		{"skips comments and blank lines", "\n# set up\n  npx jest\n", "", "npx"},
+		{"first command on PATH", `rm -rf "${TMP_DIR:?}/x"`, "", ""},
+		{"relative command present", "bin/setup --ci", "", ""},
+		{"shell builtin", "cd api && make", "", ""},
+		{"empty script", "", "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := missingCommand(tc.script, tc.stderr, loc); got != tc.want {
+				t.Fatalf("missingCommand() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCommandNotFoundHint(t *testing.T) {
+	cases := []struct {
+		name    string
+		command string
+		files   []string
+		path    string
+		want    string
+	}{
+		{
+			name:    "node_modules bin",
+			command: "yarn",
+			files:   []string{"work/node_modules/.bin/yarn"},
+			want:    "`yarn` exists at node_modules/.bin/yarn — did CI rely on setup-node adding it to PATH?",
+		},
+		{
+			name:    "asdf shim",
+			command: "ruby",
+			files:   []string{"home/.asdf/shims/ruby"},
+			want:    "is an asdf shim at",
+		},
+		{
+			name:    "rbenv shim",
+			command: "bundle",
+			files:   []string{"home/.rbenv/shims/bundle"},
+			want:    "is an rbenv shim at",
+		},
+		{
+			name:    "rbenv version bin",
+			command: "rake",
+			files:   []string{"home/.rbenv/versions/3.3.0/bin/rake"},
+			want:    "versions/3.3.0/bin/rake but rbenv is not initialized",
+		},
+		{
+			name:    "system bin off PATH",
+			command: "terraform",
+			files:   []string{"usr/local/bin/terraform"},
+			path:    "/usr/bin:/bin",
+			want:    "is not on PATH",
+		},
+		{
+			name:    "node_modules wins over system bin",
+			command: "eslint",
+			files:   []string{"usr/local/bin/eslint", "work/node_modules/.bin/eslint"},
+			want:    "exists at node_modules/.bin/eslint",
+		},
+		{
+			name:    "not executable is ignored",
+			command: "jest",
+			files:   []string{"-work/node_modules/.bin/jest"},
+			path:    "/usr/bin",
+			want:    "`jest` was not found on PATH or in common tool locations; install it locally or check the workflow's setup steps (PATH=/usr/bin)",
+		},
+		{
+			name:    "relative path",
+			command: "bin/setup",
+			want:    "`bin/setup` does not exist or is not executable",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" {
+				t.Skip("executable bits are not tracked on windows")
+			}
+			root := t.TempDir()
+			for _, file := range tc.files {
+				mode := os.FileMode(0o755)
+				if strings.HasPrefix(file, "-") {
+					file, mode = file[1:], 0o644
+				}
+				path := filepath.Join(root, filepath.FromSlash(file))
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatalf("mkdir: %v", err)
+				}
+				if err := os.WriteFile(path, []byte("#!/bin/sh\n"), mode); err != nil {
+					t.Fatalf("write %s: %v", file, err)
+				}
+			}
+			systemBin := filepath.Join(root, "usr", "local", "bin")
+			path := tc.path
+			if path == "" {
+				path = systemBin
+			}
+			loc := hintLocations{
+				WorkDir:   filepath.Join(root, "work"),
+				Home:      filepath.Join(root, "home"),
+				AsdfData:  filepath.Join(root, "home", ".asdf"),
+				SystemBin: systemBin,
+				Path:      strings.ReplaceAll(path, "/usr/local/bin", systemBin),
+			}
+
+			got := commandNotFoundHint(tc.command, loc)
+			if !strings.Contains(got, tc.want) {
+				t.Fatalf("hint = %q, want it to contain %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunnerCommandNotFoundHint(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	root := t.TempDir()
+	bin := filepath.Join(root, "node_modules", ".bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "testdrive-missing-tool"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("write tool: %v", err)
+	}
+
+	r := New(Options{Root: root})
+	results, _, err := r.Run(context.Background(), []provider.Workflow{sampleWorkflow("testdrive-missing-tool build")})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	res := results[0]
+	if res.ExitCode != exitCommandNotFound {
+		t.Fatalf("expected exit code 127, got %+v", res)
+	}
+	if !strings.Contains(res.Hint, "exists at node_modules/.bin/testdrive-missing-tool") {
+		t.Fatalf("expected node_modules hint, got %q", res.Hint)
+	}
+}
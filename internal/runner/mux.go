@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// jobOutput is where one job's verbose step output goes.
+type jobOutput struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// outputMux keeps the verbose output of jobs running in parallel apart.
+// When grouped, each job's output is held in capped buffers and written as
+// one block once the job finishes, except for a job picked by follow, which
+// writes straight through while no other followed job is running.
+type outputMux struct {
+	// mu serializes every write to stdout and stderr, so a flushed block
+	// never lands in the middle of a followed job's line.
+	mu      sync.Mutex
+	stdout  io.Writer
+	stderr  io.Writer
+	grouped bool
+	follow  func(provider.Job) bool
+	// live is set while a followed job is writing straight through.
+	live bool
+}
+
+// job returns the writers for a job's verbose output and a function that
+// writes out whatever was held back, to be called once the job finishes.
+func (m *outputMux) job(wf provider.Workflow, job provider.Job) (jobOutput, func()) {
+	if !m.grouped {
+		return jobOutput{stdout: m.stdout, stderr: m.stderr}, func() {}
+	}
+	header := fmt.Sprintf("==> %s / %s\n", wf.Name, job.Name)
+
+	m.mu.Lock()
+	if m.follow != nil && !m.live && m.follow(job) {
+		m.live = true
+		fmt.Fprint(m.stdout, header)
+		m.mu.Unlock()
+		out := jobOutput{stdout: &lockedWriter{mu: &m.mu, w: m.stdout}, stderr: &lockedWriter{mu: &m.mu, w: m.stderr}}
+		return out, func() {
+			m.mu.Lock()
+			m.live = false
+			m.mu.Unlock()
+		}
+	}
+	m.mu.Unlock()
+
+	stdout, stderr := newCappedBuffer(), newCappedBuffer()
+	return jobOutput{stdout: stdout, stderr: stderr}, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		fmt.Fprint(m.stdout, header)
+		writeHeld(m.stdout, stdout)
+		writeHeld(m.stderr, stderr)
+	}
+}
+
+// writeHeld writes a job's held-back stream, noting any output the cap
+// dropped.
+func writeHeld(w io.Writer, held *cappedBuffer) {
+	if dropped := held.Dropped(); dropped > 0 {
+		fmt.Fprintf(w, "... %d bytes of earlier output dropped ...\n", dropped)
+	}
+	io.WriteString(w, held.String())
+}
+
+// lockedWriter writes to w while holding mu.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// warn writes msg to stderr straight away, even while the job it is about
+// holds its output back.
+func (m *outputMux) warn(msg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	io.WriteString(m.stderr, msg)
+}
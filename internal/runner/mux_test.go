@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// muxWorkflow runs two jobs whose steps print in turns: slow prints a line
+// every 0.2s, fast every 0.1s and finishes first.
+func muxWorkflow(t *testing.T) provider.Workflow {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	return provider.Workflow{
+		Path: "wf.yml",
+		Name: "workflow",
+		Jobs: []provider.Job{
+			{Name: "slow", RawID: "slow", Steps: []provider.Step{
+				{Name: "one", Run: "echo slow-1; sleep 0.2; echo slow-2 >&2; sleep 0.2; echo slow-3"},
+				{Name: "two", Run: "echo slow-4"},
+			}},
+			{Name: "fast", RawID: "fast", Steps: []provider.Step{
+				{Name: "one", Run: "sleep 0.1; echo fast-1; sleep 0.1; echo fast-2"},
+			}},
+		},
+	}
+}
+
+// assertBlock checks that lines appear in order in out with nothing else
+// from the run between them.
+func assertBlock(t *testing.T, out string, lines ...string) {
+	t.Helper()
+	start := strings.Index(out, lines[0]+"\n")
+	if start < 0 {
+		t.Fatalf("missing %q in:\n%s", lines[0], out)
+	}
+	var got []string
+	for _, line := range strings.Split(out[start:], "\n") {
+		if strings.HasPrefix(line, "slow-") || strings.HasPrefix(line, "fast-") || strings.HasPrefix(line, "==> ") {
+			got = append(got, line)
+		}
+		if len(got) == len(lines) {
+			break
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(lines, "\n") {
+		t.Fatalf("expected a contiguous block %q, got %q in:\n%s", lines, got, out)
+	}
+}
+
+func TestRunnerGroupsParallelVerboseOutput(t *testing.T) {
+	wf := muxWorkflow(t)
+	out := &bytes.Buffer{}
+	r := New(Options{Root: t.TempDir(), Verbose: true, MaxParallel: 2, CombineOutput: true, Stdout: out, Stderr: out})
+
+	if _, _, err := r.Run(context.Background(), []provider.Workflow{wf}); err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	got := out.String()
+	assertBlock(t, got, "==> workflow / fast", "fast-1", "fast-2")
+	assertBlock(t, got, "==> workflow / slow", "slow-1", "slow-2", "slow-3", "slow-4")
+	if strings.Index(got, "==> workflow / fast") > strings.Index(got, "==> workflow / slow") {
+		t.Fatalf("expected jobs to flush as they complete, got:\n%s", got)
+	}
+}
+
+func TestRunnerFollowsOneJobLive(t *testing.T) {
+	wf := muxWorkflow(t)
+	out := &bytes.Buffer{}
+	follow := func(job provider.Job) bool { return job.Name == "slow" }
+	r := New(Options{Root: t.TempDir(), Verbose: true, MaxParallel: 2, CombineOutput: true, FollowJob: follow, Stdout: out, Stderr: out})
+
+	if _, _, err := r.Run(context.Background(), []provider.Workflow{wf}); err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	got := out.String()
+	assertBlock(t, got, "==> workflow / fast", "fast-1", "fast-2")
+	// The followed job printed its first line before the other job's block
+	// was flushed, and its last after.
+	fast := strings.Index(got, "==> workflow / fast")
+	if !(strings.Index(got, "slow-1") < fast && fast < strings.Index(got, "slow-3")) {
+		t.Fatalf("expected the followed job to stream around the buffered block, got:\n%s", got)
+	}
+}
+
+func TestRunnerSequentialVerboseOutputIsNotHeld(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	out := &bytes.Buffer{}
+	r := New(Options{Root: t.TempDir(), Verbose: true, Stdout: out})
+
+	if _, _, err := r.Run(context.Background(), []provider.Workflow{sampleWorkflow("echo hi")}); err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if strings.Contains(out.String(), "==> ") || !strings.Contains(out.String(), "hi\n") {
+		t.Fatalf("expected plain verbose output, got %q", out.String())
+	}
+}
+
+func TestCappedBufferKeepsTail(t *testing.T) {
+	b := &cappedBuffer{limit: 10}
+	b.Write([]byte("line1\nline2\n"))
+	b.Write([]byte("line3\n"))
+	if got := b.String(); got != "line3\n" {
+		t.Fatalf("expected the tail cut at a line boundary, got %q", got)
+	}
+	if b.Dropped() != 12 {
+		t.Fatalf("expected 12 dropped bytes, got %d", b.Dropped())
+	}
+
+	held := &bytes.Buffer{}
+	writeHeld(held, b)
+	if held.String() != "... 12 bytes of earlier output dropped ...\nline3\n" {
+		t.Fatalf("unexpected held output %q", held.String())
+	}
+}
@@ -0,0 +1,120 @@
+package runner
+
+import "sync"
+
+// needKey identifies the jobs a need names: every variant of the job with
+// that ID in the workflow at path.
+func needKey(path, rawID string) string {
+	return path + "#" + rawID
+}
+
+func (j scheduledJob) needKey() string {
+	return needKey(j.wf.Path, j.job.RawID)
+}
+
+// needKeys returns the keys of the jobs j needs that are part of the run.
+// Needs on jobs that were not selected are ignored, so --job can run a job
+// on its own.
+func needKeys(j scheduledJob, present map[string]bool) []string {
+	var keys []string
+	for _, id := range j.job.Needs {
+		key := needKey(j.wf.Path, id)
+		if present[key] && key != j.needKey() {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// orderByNeeds moves each job after the jobs it needs and otherwise keeps
+// the order given. Jobs caught in a cycle, which GitHub rejects, keep their
+// place rather than being dropped.
+func orderByNeeds(jobs []scheduledJob) []scheduledJob {
+	present := make(map[string]bool, len(jobs))
+	left := make(map[string]int, len(jobs))
+	for _, j := range jobs {
+		present[j.needKey()] = true
+		left[j.needKey()]++
+	}
+	ordered := make([]scheduledJob, 0, len(jobs))
+	pending := append([]scheduledJob{}, jobs...)
+	for len(pending) > 0 {
+		next := 0
+		for i, j := range pending {
+			ready := true
+			for _, key := range needKeys(j, present) {
+				if left[key] > 0 {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		j := pending[next]
+		pending = append(pending[:next], pending[next+1:]...)
+		left[j.needKey()]--
+		ordered = append(ordered, j)
+	}
+	return ordered
+}
+
+// needTracker follows which needed jobs have finished, and whether any of
+// them failed, so dependents start only once their needs are met and are
+// skipped when one was not. It is safe for concurrent use.
+type needTracker struct {
+	mu      sync.Mutex
+	present map[string]bool
+	// left counts the variants of each job that have not finished.
+	left map[string]int
+	// failed maps a job whose variant failed, or that was skipped for a
+	// failed need, to the name of the job that failed.
+	failed map[string]string
+}
+
+func newNeedTracker(jobs []scheduledJob) *needTracker {
+	t := &needTracker{present: make(map[string]bool), left: make(map[string]int), failed: make(map[string]string)}
+	for _, j := range jobs {
+		t.present[j.needKey()] = true
+		t.left[j.needKey()]++
+	}
+	return t
+}
+
+// ready reports whether every job j needs has finished.
+func (t *needTracker) ready(j scheduledJob) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range needKeys(j, t.present) {
+		if t.left[key] > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// blocked returns the name of the failed job that keeps j from running, or
+// "" when none of its needs failed.
+func (t *needTracker) blocked(j scheduledJob) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range needKeys(j, t.present) {
+		if name, ok := t.failed[key]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// finish records that j finished. cause names the failed job when j failed
+// or was skipped because of one; it is "" when j passed.
+func (t *needTracker) finish(j scheduledJob, cause string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.left[j.needKey()]--
+	if _, ok := t.failed[j.needKey()]; cause != "" && !ok {
+		t.failed[j.needKey()] = cause
+	}
+}
@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// needsWorkflow is a three-level DAG listed by job ID, as the parser orders
+// jobs: build, then test, then deploy, with lint needing nothing.
+func needsWorkflow() provider.Workflow {
+	job := func(id string, needs ...string) provider.Job {
+		return provider.Job{Name: id, RawID: id, Needs: needs, Steps: []provider.Step{{Name: id, Run: "make " + id}}}
+	}
+	return provider.Workflow{Path: "ci.yml", Name: "CI", Jobs: []provider.Job{
+		job("build"),
+		job("deploy", "test"),
+		job("lint"),
+		job("test", "build"),
+	}}
+}
+
+func TestOrderByNeeds(t *testing.T) {
+	wf := needsWorkflow()
+	var jobs []scheduledJob
+	for i := len(wf.Jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, scheduledJob{wf: wf, job: wf.Jobs[i]})
+	}
+	names := func(jobs []scheduledJob) []string {
+		var out []string
+		for _, j := range jobs {
+			out = append(out, j.job.Name)
+		}
+		return out
+	}
+	if got, want := names(orderByNeeds(jobs)), []string{"lint", "build", "test", "deploy"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("orderByNeeds = %v, want %v", got, want)
+	}
+
+	// A need on a job that is not part of the run is ignored.
+	if got, want := names(orderByNeeds(jobs[:2])), []string{"test", "lint"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("orderByNeeds without build = %v, want %v", got, want)
+	}
+
+	cycle := provider.Workflow{Path: "ci.yml", Jobs: []provider.Job{
+		{Name: "a", RawID: "a", Needs: []string{"b"}},
+		{Name: "b", RawID: "b", Needs: []string{"a"}},
+	}}
+	jobs = []scheduledJob{{wf: cycle, job: cycle.Jobs[0]}, {wf: cycle, job: cycle.Jobs[1]}}
+	if got, want := names(orderByNeeds(jobs)), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("orderByNeeds with a cycle = %v, want %v", got, want)
+	}
+}
+
+func TestRunnerNeeds(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts Options
+	}{
+		{name: "streaming", opts: Options{Streaming: true, StreamingRenderer: &recordingRenderer{}}},
+		{name: "sequential", opts: Options{MaxParallel: 1}},
+		{name: "parallel", opts: Options{MaxParallel: 4}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			exec := &fakeExecutor{commands: map[string]fakeCommand{
+				"make build": {}, "make test": {}, "make deploy": {}, "make lint": {},
+			}}
+			opts := tc.opts
+			opts.Root, opts.Executor = t.TempDir(), exec
+			if _, _, err := New(opts).Run(context.Background(), []provider.Workflow{needsWorkflow()}); err != nil {
+				t.Fatalf("runner Run: %v", err)
+			}
+			order := strings.Join(exec.executed(), ",")
+			if strings.Index(order, "make build") > strings.Index(order, "make test") || strings.Index(order, "make test") > strings.Index(order, "make deploy") {
+				t.Fatalf("jobs ran before their needs: %s", order)
+			}
+
+			exec = &fakeExecutor{commands: map[string]fakeCommand{
+				"make build": {exitCode: 1}, "make lint": {},
+			}}
+			opts.Executor = exec
+			if rec, ok := opts.StreamingRenderer.(*recordingRenderer); ok {
+				rec.calls = nil
+			}
+			results, summary, err := New(opts).Run(context.Background(), []provider.Workflow{needsWorkflow()})
+			if err != nil {
+				t.Fatalf("runner Run: %v", err)
+			}
+			if got := strings.Join(exec.executed(), ","); got != "make build,make lint" && got != "make lint,make build" {
+				t.Fatalf("expected only build and lint to run, got %s", got)
+			}
+			want := map[string]string{"build": "failed", "deploy": "skipped", "lint": "passed", "test": "skipped"}
+			for _, res := range results {
+				if res.Status != want[res.JobName] {
+					t.Fatalf("%s was %s, want %s", res.JobName, res.Status, want[res.JobName])
+				}
+				if res.Status == "skipped" && (res.SkipReason != report.ReasonNeedsFailed || res.SkipDetail != "needs build, which failed") {
+					t.Fatalf("expected %s skipped for build, got %+v", res.JobName, res)
+				}
+			}
+			if summary.Failed != 1 || summary.Skipped != 2 {
+				t.Fatalf("unexpected summary %+v", summary)
+			}
+			if rec, ok := opts.StreamingRenderer.(*recordingRenderer); ok {
+				calls := strings.Join(rec.calls, "\n")
+				if strings.Contains(calls, "StartJob ci.yml#test") || !strings.Contains(calls, "CompleteJob ci.yml#test") {
+					t.Fatalf("expected test completed without starting:\n%s", calls)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+	"github.com/bgricker/testdrive/internal/resolve"
+)
+
+// Plan returns the decision Run would make for every run step in workflows
+// without executing anything. DryRun is ignored so the plan shows what a real
+// run would do. With Dedupe, a step identical to an earlier one that is
+// planned to run is marked as its duplicate; the real run only skips it if
+// that earlier step passes. A step whose shell or working directory does not
+// resolve is planned to fail with the error the real run would report.
+func (r *Runner) Plan(workflows []provider.Workflow) []report.PlannedStep {
+	var steps []report.PlannedStep
+	firstRun := make(map[string]report.PlannedStep)
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			for _, step := range job.Steps {
+				if step.Run == "" || step.Uses != "" {
+					continue
+				}
+				planned := report.PlannedStep{
+					WorkflowPath: wf.Path,
+					WorkflowName: wf.Name,
+					JobName:      job.Name,
+					StepName:     step.Name,
+					StepRun:      step.Run,
+					Action:       report.PlanRun,
+					Overridden:   step.Overridden,
+				}
+				if reason, msg, skip := resolve.Skip(job, step, r.skipOptions(wf, job, step)); skip {
+					planned.Action = report.PlanSkip
+					planned.SkipReason = reason
+					planned.Detail = msg
+					steps = append(steps, planned)
+					continue
+				}
+				if err := r.resolveStep(wf, job, step); err != nil {
+					planned.Action = report.PlanFail
+					planned.Detail = err.Error()
+					steps = append(steps, planned)
+					continue
+				}
+				if r.opts.Dedupe {
+					key := stepKey(r.opts.Root, wf, job, step)
+					if prior, ok := firstRun[key]; ok {
+						origin := dedupeOrigin(report.StepResult{WorkflowPath: prior.WorkflowPath, WorkflowName: prior.WorkflowName, JobName: prior.JobName, StepName: prior.StepName})
+						planned.Action = report.PlanSkip
+						planned.SkipReason = report.ReasonDuplicate
+						planned.DuplicateOf = origin
+						planned.Detail = "duplicate of " + origin + " if it passes"
+					} else {
+						firstRun[key] = planned
+					}
+				}
+				steps = append(steps, planned)
+			}
+		}
+	}
+	return steps
+}
@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestPlanDecisions(t *testing.T) {
+	wf := provider.Workflow{
+		Path: "wf.yml",
+		Name: "workflow",
+		Jobs: []provider.Job{
+			{Name: "build", RawID: "build", Steps: []provider.Step{
+				{Name: "checkout", Uses: "actions/checkout@v4"},
+				{Name: "install", Run: "sudo apt-get install -y jq"},
+				{Name: "build", Run: "make build"},
+				{Name: "build again", Run: "make   build"},
+				{Name: "disabled", Run: "make lint", Skip: true},
+			}},
+			{Name: "deploy", RawID: "deploy", Environment: "production", Steps: []provider.Step{
+				{Name: "ship", Run: "make ship"},
+			}},
+		},
+	}
+
+	// DryRun does not change the plan: it previews a real run.
+	r := New(Options{Root: t.TempDir(), Dedupe: true, DryRun: true})
+	steps := r.Plan([]provider.Workflow{wf})
+
+	want := []struct{ name, action, reason string }{
+		{"install", report.PlanSkip, report.ReasonPrivileged},
+		{"build", report.PlanRun, ""},
+		{"build again", report.PlanSkip, report.ReasonDuplicate},
+		{"disabled", report.PlanSkip, report.ReasonOverride},
+		{"ship", report.PlanSkip, report.ReasonEnvironment},
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("expected %d planned steps, got %+v", len(want), steps)
+	}
+	for i, w := range want {
+		got := steps[i]
+		if got.StepName != w.name || got.Action != w.action || got.SkipReason != w.reason {
+			t.Errorf("step %d = %s/%s/%s, want %s/%s/%s", i, got.StepName, got.Action, got.SkipReason, w.name, w.action, w.reason)
+		}
+	}
+	if steps[2].DuplicateOf != "workflow/build/build" {
+		t.Errorf("expected duplicate origin, got %q", steps[2].DuplicateOf)
+	}
+
+	r = New(Options{Root: t.TempDir(), AllowPrivileged: true, AllowedEnvironments: []string{"production"}})
+	for _, step := range r.Plan([]provider.Workflow{wf}) {
+		if step.StepName != "disabled" && step.Action != report.PlanRun {
+			t.Errorf("expected %s to run without dedupe and with allowances, got %+v", step.StepName, step)
+		}
+	}
+}
+
+func TestPlanAndDryRunFailUnresolvableSteps(t *testing.T) {
+	wf := provider.Workflow{
+		Path: "wf.yml",
+		Name: "workflow",
+		Jobs: []provider.Job{{Name: "build", RawID: "build", Steps: []provider.Step{
+			{Name: "missing dir", Run: "make", WorkingDirectory: "missing"},
+			{Name: "missing shell", Run: "make", Shell: "testdrive-no-such-shell {0}"},
+			{Name: "fine", Run: "make"},
+		}}},
+	}
+	root := t.TempDir()
+	wantDir := fmt.Sprintf("working directory %q not found", filepath.Join(root, "missing"))
+
+	steps := New(Options{Root: root}).Plan([]provider.Workflow{wf})
+	if steps[0].Action != report.PlanFail || steps[0].Detail != wantDir {
+		t.Errorf("missing dir planned as %s: %q, want %s: %q", steps[0].Action, steps[0].Detail, report.PlanFail, wantDir)
+	}
+	if steps[1].Action != report.PlanFail || !strings.Contains(steps[1].Detail, `shell "testdrive-no-such-shell" not found`) {
+		t.Errorf("missing shell planned as %s: %q", steps[1].Action, steps[1].Detail)
+	}
+	if steps[2].Action != report.PlanRun {
+		t.Errorf("fine planned as %s", steps[2].Action)
+	}
+	if plan := report.NewPlan(steps, nil); plan.Failed != 2 || plan.Run != 1 {
+		t.Errorf("plan counts run=%d failed=%d, want 1 and 2", plan.Run, plan.Failed)
+	}
+
+	results, summary, err := New(Options{Root: root, DryRun: true}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if results[0].Status != "failed" || results[0].Stderr != wantDir || results[0].ExitCode != exitCommandNotFound {
+		t.Errorf("missing dir dry run = %s/%d %q, want failed with %q", results[0].Status, results[0].ExitCode, results[0].Stderr, wantDir)
+	}
+	if results[2].Status != "skipped" || results[2].SkipReason != report.ReasonDryRun {
+		t.Errorf("fine dry run = %s/%s", results[2].Status, results[2].SkipReason)
+	}
+	if summary.ExitCode == 0 {
+		t.Error("a dry run with unresolvable steps exited 0")
+	}
+
+	// The real run fails the step with the error the dry run predicted.
+	results, _, err = New(Options{Root: root}).Run(context.Background(), []provider.Workflow{{
+		Path: wf.Path, Name: wf.Name,
+		Jobs: []provider.Job{{Name: "build", RawID: "build", Steps: []provider.Step{wf.Jobs[0].Steps[0]}}},
+	}})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if results[0].Status != "failed" || !strings.Contains(results[0].Stderr, wantDir) {
+		t.Errorf("missing dir real run = %s %q, want failed with %q", results[0].Status, results[0].Stderr, wantDir)
+	}
+}
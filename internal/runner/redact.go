@@ -0,0 +1,210 @@
+package runner
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// minRedactLen is the shortest value a redactor masks; masking "1" or
+// "true" everywhere would hide more than it protects.
+const minRedactLen = 4
+
+// redactHold is how much of a line without a newline a redactWriter holds
+// back before writing some of it anyway.
+const redactHold = 64 << 10
+
+// redactor masks the values of the environment variables whose names match
+// the runner's RedactEnvPatterns. A nil *redactor masks nothing.
+type redactor struct {
+	// values are the values to mask, longest first, so a value that
+	// contains another is masked whole.
+	values   []string
+	replacer *strings.Replacer
+}
+
+// newRedactor collects the values in env of the variables whose names match
+// one of patterns, glob patterns compared case-insensitively. Each line of a
+// value that spans lines is masked on its own, since output is masked line
+// by line. It returns nil when there is nothing to mask.
+func newRedactor(patterns, env []string) *redactor {
+	seen := make(map[string]bool)
+	var values []string
+	for _, kv := range env {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !RedactName(patterns, name) {
+			continue
+		}
+		for _, line := range strings.FieldsFunc(value, func(r rune) bool { return r == '\n' || r == '\r' }) {
+			if len(line) >= minRedactLen && !seen[line] {
+				seen[line] = true
+				values = append(values, line)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, report.MaskedValue)
+	}
+	return &redactor{values: values, replacer: strings.NewReplacer(pairs...)}
+}
+
+// RedactName reports whether name matches one of patterns, glob patterns
+// compared case-insensitively, as RedactEnvPatterns are.
+func RedactName(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToUpper(pattern), strings.ToUpper(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactWorkflows returns a copy of workflows for reports, with the env
+// values of workflows, jobs, containers and steps masked where the
+// variable's name matches one of patterns. Values too short for a redactor
+// to mask are kept, as they are in output.
+func RedactWorkflows(patterns []string, workflows []provider.Workflow) []provider.Workflow {
+	if len(patterns) == 0 || workflows == nil {
+		return workflows
+	}
+	out := make([]provider.Workflow, len(workflows))
+	for i, wf := range workflows {
+		wf.Env = redactEnvMap(patterns, wf.Env)
+		jobs := make([]provider.Job, len(wf.Jobs))
+		for j, job := range wf.Jobs {
+			job.Env = redactEnvMap(patterns, job.Env)
+			if job.Container != nil {
+				container := *job.Container
+				container.Env = redactEnvMap(patterns, container.Env)
+				job.Container = &container
+			}
+			steps := make([]provider.Step, len(job.Steps))
+			for k, step := range job.Steps {
+				step.Env = redactEnvMap(patterns, step.Env)
+				steps[k] = step
+			}
+			job.Steps = steps
+			jobs[j] = job
+		}
+		wf.Jobs = jobs
+		out[i] = wf
+	}
+	return out
+}
+
+// redactEnvMap returns env with the values RedactWorkflows masks replaced,
+// copying it only when something is masked.
+func redactEnvMap(patterns []string, env map[string]string) map[string]string {
+	var out map[string]string
+	for name, value := range env {
+		if len(value) < minRedactLen || !RedactName(patterns, name) {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string, len(env))
+			for k, v := range env {
+				out[k] = v
+			}
+		}
+		out[name] = report.MaskedValue
+	}
+	if out == nil {
+		return env
+	}
+	return out
+}
+
+// String returns s with every value masked.
+func (r *redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// writer returns w behind a redactWriter, or w itself when r masks nothing.
+// Callers must flush the returned writer once the output ends.
+func (r *redactor) writer(w io.Writer) io.Writer {
+	if r == nil {
+		return w
+	}
+	return &redactWriter{r: r, w: w}
+}
+
+// flushRedacted flushes w if it is a redactWriter.
+func flushRedacted(w io.Writer) error {
+	if rw, ok := w.(*redactWriter); ok {
+		return rw.Flush()
+	}
+	return nil
+}
+
+// redactWriter masks a redactor's values in what is written through it.
+// It writes whole lines, so a value split across writes is still masked;
+// the rest of the output is held until a line ends or Flush is called.
+type redactWriter struct {
+	r   *redactor
+	w   io.Writer
+	buf []byte
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	cut := bytes.LastIndexAny(w.buf, "\r\n") + 1
+	if cut == 0 && len(w.buf) > redactHold {
+		cut = w.safeCut(len(w.buf) - len(w.r.values[0]) + 1)
+	}
+	if cut == 0 {
+		return len(p), nil
+	}
+	out := w.r.String(string(w.buf[:cut]))
+	w.buf = append(w.buf[:0], w.buf[cut:]...)
+	if _, err := io.WriteString(w.w, out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// safeCut moves cut back until no value starts before it and ends after
+// it, so writing the held output up to cut cannot leave part of a value
+// unmasked.
+func (w *redactWriter) safeCut(cut int) int {
+	for moved := true; moved && cut > 0; {
+		moved = false
+		for _, v := range w.r.values {
+			for start := max(cut-len(v)+1, 0); start < cut; start++ {
+				if bytes.HasPrefix(w.buf[start:], []byte(v)) {
+					cut, moved = start, true
+					break
+				}
+			}
+		}
+	}
+	return cut
+}
+
+// Flush writes whatever is held back.
+func (w *redactWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	out := w.r.String(string(w.buf))
+	w.buf = w.buf[:0]
+	_, err := io.WriteString(w.w, out)
+	return err
+}
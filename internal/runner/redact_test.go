@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/resolve"
+)
+
+func TestRedactorMasksMatchingValues(t *testing.T) {
+	env := []string{
+		"DEPLOY_TOKEN=tok-123456",
+		"aws_secret=multi\nline-secret",
+		"PIN_KEY=abc",
+		"HOME=/home/dev",
+		"DB_PASSWORD=tok-123456-longer",
+	}
+	r := newRedactor([]string{"*_TOKEN", "*_SECRET", "*_KEY", "*PASSWORD"}, env)
+	got := r.String("token tok-123456, password tok-123456-longer, secret multi / line-secret, pin abc, home /home/dev")
+	want := "token ***, password ***, secret *** / ***, pin abc, home /home/dev"
+	if got != want {
+		t.Fatalf("String = %q, want %q", got, want)
+	}
+	if r := newRedactor(nil, env); r != nil || r.String("tok-123456") != "tok-123456" {
+		t.Fatalf("no patterns should mask nothing")
+	}
+}
+
+func TestRedactWriterMasksValuesSplitAcrossWrites(t *testing.T) {
+	r := newRedactor([]string{"*_TOKEN"}, []string{"GH_TOKEN=ghp_abcdef"})
+	var out bytes.Buffer
+	w := r.writer(&out)
+	for _, chunk := range []string{"token: ghp_", "abc", "def\nnext ghp_ab", "cdef"} {
+		io.WriteString(w, chunk)
+	}
+	if out.String() != "token: ***\n" {
+		t.Fatalf("wrote %q before the line ended", out.String())
+	}
+	flushRedacted(w)
+	if out.String() != "token: ***\nnext ***" {
+		t.Fatalf("output = %q", out.String())
+	}
+}
+
+func TestRedactWriterCutsLongLinesOutsideValues(t *testing.T) {
+	r := newRedactor([]string{"*_TOKEN"}, []string{"GH_TOKEN=ghp_abcdef"})
+	var out bytes.Buffer
+	w := r.writer(&out)
+	io.WriteString(w, strings.Repeat("x", redactHold-4)+"ghp_abc")
+	io.WriteString(w, "def")
+	flushRedacted(w)
+	if want := strings.Repeat("x", redactHold-4) + "***"; out.String() != want {
+		t.Fatalf("long line masked as %q...", out.String()[len(out.String())-10:])
+	}
+}
+
+func TestRunnerRedactsStepOutputAndReports(t *testing.T) {
+	fake := &fakeExecutor{commands: map[string]fakeCommand{"deploy": {
+		run: func(ctx context.Context, spec ExecSpec) (ExecResult, error) {
+			io.WriteString(spec.Stdout, "using s3cr3t-token\n")
+			if path := resolve.EnvValue(spec.Env, StepSummaryEnv); path != "" {
+				os.WriteFile(path, []byte("deployed with s3cr3t-token\n"), 0o644)
+			}
+			io.WriteString(spec.Stderr, "fatal: s3cr3t-token rejected")
+			return ExecResult{ExitCode: 1}, io.ErrUnexpectedEOF
+		},
+	}}}
+	wf := sampleWorkflow("deploy")
+	wf.Jobs[0].Steps[0].Env = map[string]string{"DEPLOY_TOKEN": "s3cr3t-token"}
+	verbose := &bytes.Buffer{}
+	r := New(Options{Root: t.TempDir(), Executor: fake, Verbose: true, Stdout: verbose, Stderr: verbose, RedactEnvPatterns: []string{"*_TOKEN"}})
+	results, summary, err := r.Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := results[0]
+	if got.Stdout != "using ***" || got.Stderr != "fatal: *** rejected" {
+		t.Fatalf("output not masked: stdout %q, stderr %q", got.Stdout, got.Stderr)
+	}
+	if got.EnvSnapshot == nil || got.EnvSnapshot.Env["DEPLOY_TOKEN"] != "***" {
+		t.Fatalf("env snapshot = %+v", got.EnvSnapshot)
+	}
+	if strings.Contains(verbose.String(), "s3cr3t") {
+		t.Fatalf("live output shows the secret:\n%s", verbose)
+	}
+	if got := summary.Jobs[0].StepSummary; got != "deployed with ***\n" {
+		t.Fatalf("StepSummary = %q", got)
+	}
+}
+
+func TestRedactWorkflowsMasksEnvCopies(t *testing.T) {
+	workflows := []provider.Workflow{{
+		Env: map[string]string{"API_TOKEN": "supersecret", "REGION": "eu-west-1"},
+		Jobs: []provider.Job{{
+			Env:       map[string]string{"DB_PASSWORD": "hunter2hunter2"},
+			Container: &provider.Container{Image: "ruby", Env: map[string]string{"SIGNING_KEY": "signingkey"}},
+			Steps:     []provider.Step{{Env: map[string]string{"PIN_KEY": "abc", "DEPLOY_SECRET": "deploysecret"}}},
+		}},
+	}}
+	got := RedactWorkflows([]string{"*_TOKEN", "*_KEY", "*_SECRET", "*PASSWORD"}, workflows)
+
+	job := got[0].Jobs[0]
+	masked := []string{got[0].Env["API_TOKEN"], job.Env["DB_PASSWORD"], job.Container.Env["SIGNING_KEY"], job.Steps[0].Env["DEPLOY_SECRET"]}
+	for _, value := range masked {
+		if value != "***" {
+			t.Fatalf("expected masked values, got %q in %+v", value, got)
+		}
+	}
+	if got[0].Env["REGION"] != "eu-west-1" || job.Steps[0].Env["PIN_KEY"] != "abc" {
+		t.Fatalf("expected other and short values kept, got %+v", got)
+	}
+	if workflows[0].Env["API_TOKEN"] != "supersecret" || workflows[0].Jobs[0].Container.Env["SIGNING_KEY"] != "signingkey" || workflows[0].Jobs[0].Steps[0].Env["DEPLOY_SECRET"] != "deploysecret" {
+		t.Fatalf("the workflows the runner executes must keep their values, got %+v", workflows)
+	}
+}
@@ -0,0 +1,25 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// resolveStep checks what step needs before it can start: a shell program
+// on PATH and an existing working directory. Dry runs and plans call it so
+// they fail a step a real run would fail before running anything. The
+// working directory error is the one runStep fails with.
+func (r *Runner) resolveStep(wf provider.Workflow, job provider.Job, step provider.Step) error {
+	cmdArgs, err := r.resolver.Command(wf, job, step, r.opts.Env)
+	if err != nil {
+		return err
+	}
+	if _, err := r.opts.LookPath(cmdArgs[0]); err != nil {
+		return fmt.Errorf("shell %q not found: %w", cmdArgs[0], err)
+	}
+	if _, _, err := r.resolver.WorkingDirectory(r.opts.Root, wf, job, step); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package runner
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/history"
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// scheduledJob is one job queued for execution along with the concurrency
+// groups it must hold.
+type scheduledJob struct {
+	wf     provider.Workflow
+	job    provider.Job
+	claims []concurrencyClaim
+	// matrix identifies the matrix a variant was expanded from; it is empty
+	// for jobs that are not matrix variants.
+	matrix string
+}
+
+func newScheduledJob(wf provider.Workflow, job provider.Job, ref string) scheduledJob {
+	return scheduledJob{wf: wf, job: job, claims: concurrencyClaims(wf, job, ref), matrix: matrixKey(wf, job)}
+}
+
+// matrixKey identifies the matrix a variant belongs to, or returns "" for
+// jobs that are not matrix variants.
+func matrixKey(wf provider.Workflow, job provider.Job) string {
+	if job.Variant == "" || job.Strategy == nil {
+		return ""
+	}
+	return wf.Path + "#" + job.RawID
+}
+
+// scheduler starts jobs in order, up to a parallelism limit, while keeping
+// jobs that share a concurrency group from overlapping. With a limit of one
+// it runs jobs strictly in the order given. Matrix variants additionally
+// honor their strategy's max-parallel and fail-fast settings.
+type scheduler struct {
+	limit int
+	// failed reports whether a finished job had a failing step. Its
+	// dependents are skipped, and for matrix variants fail-fast may cancel
+	// the rest; nil means jobs never fail.
+	failed func(scheduledJob) bool
+	// cancel is called for each queued variant dropped by fail-fast.
+	cancel func(scheduledJob)
+	// skip is called for each queued job dropped because a job it needs
+	// failed, with the name of that job.
+	skip func(scheduledJob, string)
+	// ordered keeps jobs starting strictly in the order given: a job whose
+	// groups are busy holds back the ones behind it rather than being
+	// overtaken.
+	ordered bool
+	// started, when set, is called as each job is dispatched, in start
+	// order, before the job's goroutine runs.
+	started func(scheduledJob)
+	// needs holds jobs back until the jobs they need have finished.
+	needs *needTracker
+
+	mu            sync.Mutex
+	cond          *sync.Cond
+	running       int
+	owners        map[string]string
+	remaining     map[string]int
+	matrixRunning map[string]int
+	matrixFailed  map[string]bool
+}
+
+func newScheduler(limit int, jobs []scheduledJob) *scheduler {
+	if limit < 1 {
+		limit = 1
+	}
+	s := &scheduler{
+		limit:         limit,
+		owners:        make(map[string]string),
+		remaining:     make(map[string]int),
+		matrixRunning: make(map[string]int),
+		matrixFailed:  make(map[string]bool),
+		needs:         newNeedTracker(jobs),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	for _, j := range jobs {
+		for _, c := range j.claims {
+			s.remaining[c.owner]++
+		}
+	}
+	return s
+}
+
+// run executes every job with fn and returns the first error. Once a job
+// fails no further jobs are started, but running ones are waited for. Jobs
+// start after the jobs they need, whatever order they are given in.
+func (s *scheduler) run(jobs []scheduledJob, fn func(scheduledJob) error) error {
+	pending := orderByNeeds(jobs)
+	var wg sync.WaitGroup
+	var firstErr error
+
+	s.mu.Lock()
+	for len(pending) > 0 && firstErr == nil {
+		pending = s.dropCancelled(pending)
+		if len(pending) == 0 {
+			break
+		}
+		next := -1
+		if s.running < s.limit {
+			for i, j := range pending {
+				if s.available(j) {
+					next = i
+					break
+				}
+				if s.ordered {
+					break
+				}
+			}
+			// Crossed group claims could otherwise wait on each other forever;
+			// with nothing running, the oldest job is always safe to start.
+			if next < 0 && s.running == 0 {
+				next = 0
+			}
+		}
+		if next < 0 {
+			s.cond.Wait()
+			continue
+		}
+
+		j := pending[next]
+		pending = append(pending[:next], pending[next+1:]...)
+		s.claim(j)
+		if s.started != nil {
+			s.started(j)
+		}
+		s.running++
+		s.matrixRunning[j.matrix]++
+		wg.Add(1)
+		go func(j scheduledJob) {
+			defer wg.Done()
+			err := fn(j)
+			failed := s.failed != nil && s.failed(j)
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.release(j)
+			s.running--
+			s.matrixRunning[j.matrix]--
+			if failed && j.matrix != "" {
+				s.matrixFailed[j.matrix] = true
+			}
+			cause := ""
+			if failed {
+				cause = j.job.Name
+			}
+			s.needs.finish(j, cause)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			s.cond.Broadcast()
+		}(j)
+	}
+	s.mu.Unlock()
+
+	wg.Wait()
+	return firstErr
+}
+
+// dropCancelled removes queued jobs that a failed job they need blocks,
+// reporting each to s.skip, and queued variants of matrices that failed with
+// fail-fast set, reporting each to s.cancel. Callers must hold s.mu.
+func (s *scheduler) dropCancelled(pending []scheduledJob) []scheduledJob {
+	kept := pending[:0]
+	for _, j := range pending {
+		if cause := s.needs.blocked(j); cause != "" {
+			s.release(j)
+			if s.skip != nil {
+				s.skip(j, cause)
+			}
+			s.needs.finish(j, cause)
+			continue
+		}
+		if j.matrix != "" && s.matrixFailed[j.matrix] && j.job.Strategy.FailFast {
+			s.release(j)
+			if s.cancel != nil {
+				s.cancel(j)
+			}
+			s.needs.finish(j, "")
+			continue
+		}
+		kept = append(kept, j)
+	}
+	return kept
+}
+
+// available reports whether every job j needs has finished, whether every
+// group j needs is free or already owned by j's workflow or job, and whether
+// j's matrix is below its max-parallel cap. Callers must hold s.mu.
+func (s *scheduler) available(j scheduledJob) bool {
+	if !s.needs.ready(j) {
+		return false
+	}
+	if j.matrix != "" && j.job.Strategy.MaxParallel > 0 && s.matrixRunning[j.matrix] >= j.job.Strategy.MaxParallel {
+		return false
+	}
+	for _, c := range j.claims {
+		if owner, ok := s.owners[c.group]; ok && owner != c.owner {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *scheduler) claim(j scheduledJob) {
+	for _, c := range j.claims {
+		s.owners[c.group] = c.owner
+	}
+}
+
+func (s *scheduler) release(j scheduledJob) {
+	for _, c := range j.claims {
+		s.remaining[c.owner]--
+		if s.remaining[c.owner] == 0 && s.owners[c.group] == c.owner {
+			delete(s.owners, c.group)
+		}
+	}
+}
+
+// longestFirst orders jobs by their recorded duration, longest first, so the
+// slowest jobs are not left to start last. Jobs without a recorded duration
+// keep their declared order after the rest.
+func longestFirst(jobs []scheduledJob, durations map[history.JobKey]time.Duration) []scheduledJob {
+	ordered := append([]scheduledJob{}, jobs...)
+	sort.SliceStable(ordered, func(a, b int) bool {
+		da, okA := durations[jobKey(ordered[a])]
+		db, okB := durations[jobKey(ordered[b])]
+		if okA != okB {
+			return okA
+		}
+		return da > db
+	})
+	return ordered
+}
+
+// replayOrder moves the jobs listed in order to the front, in that order.
+// The rest keep their declared order after them; listed jobs that are not
+// part of this run are ignored.
+func replayOrder(jobs []scheduledJob, order []history.JobKey) []scheduledJob {
+	rank := make(map[history.JobKey]int, len(order))
+	for i, key := range order {
+		if _, ok := rank[key]; !ok {
+			rank[key] = i
+		}
+	}
+	ordered := append([]scheduledJob{}, jobs...)
+	sort.SliceStable(ordered, func(a, b int) bool {
+		ra, okA := rank[jobKey(ordered[a])]
+		rb, okB := rank[jobKey(ordered[b])]
+		if okA != okB {
+			return okA
+		}
+		return ra < rb
+	})
+	return ordered
+}
+
+func jobKey(j scheduledJob) history.JobKey {
+	return history.JobKey{Workflow: filepath.ToSlash(j.wf.Path), Job: j.job.Name}
+}
+
+// describeOrder lists jobs with their recorded durations for verbose output.
+// With nil durations only the jobs are listed.
+func describeOrder(jobs []scheduledJob, durations map[history.JobKey]time.Duration) string {
+	parts := make([]string, 0, len(jobs))
+	for _, j := range jobs {
+		label := j.wf.Path + "/" + j.job.Name
+		if durations == nil {
+			parts = append(parts, label)
+		} else if d, ok := durations[jobKey(j)]; ok {
+			parts = append(parts, fmt.Sprintf("%s (%s)", label, output.FormatDuration(d)))
+		} else {
+			parts = append(parts, label+" (no history)")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
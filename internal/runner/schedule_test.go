@@ -0,0 +1,400 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/history"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// overlapTracker records how many scheduled jobs ran at once and whether
+// two jobs from different grouped workflows ever overlapped.
+type overlapTracker struct {
+	grouped map[string]bool
+
+	mu       sync.Mutex
+	active   map[string]int
+	total    int
+	maxTotal int
+	overlaps int
+	// starts lists the workflow of each job in start order.
+	starts []string
+}
+
+func (o *overlapTracker) run(j scheduledJob) error {
+	o.mu.Lock()
+	o.active[j.wf.Path]++
+	o.total++
+	if o.total > o.maxTotal {
+		o.maxTotal = o.total
+	}
+	for path, n := range o.active {
+		if n > 0 && path != j.wf.Path && o.grouped[path] && o.grouped[j.wf.Path] {
+			o.overlaps++
+		}
+	}
+	o.starts = append(o.starts, j.wf.Path)
+	o.mu.Unlock()
+
+	time.Sleep(40 * time.Millisecond)
+
+	o.mu.Lock()
+	o.active[j.wf.Path]--
+	o.total--
+	o.mu.Unlock()
+	return nil
+}
+
+func scheduledJobs(workflows []provider.Workflow) []scheduledJob {
+	var jobs []scheduledJob
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			jobs = append(jobs, scheduledJob{wf: wf, job: job, claims: concurrencyClaims(wf, job, "refs/heads/main")})
+		}
+	}
+	return jobs
+}
+
+func TestSchedulerSerializesSharedGroups(t *testing.T) {
+	group := &provider.Concurrency{Group: "deploy-${{ github.ref }}"}
+	workflows := []provider.Workflow{
+		{Path: "deploy-a.yml", Concurrency: group, Jobs: []provider.Job{{RawID: "build"}, {RawID: "push"}}},
+		{Path: "deploy-b.yml", Concurrency: group, Jobs: []provider.Job{{RawID: "migrate"}}},
+		{Path: "lint.yml", Jobs: []provider.Job{{RawID: "lint"}, {RawID: "vet"}}},
+	}
+	jobs := scheduledJobs(workflows)
+	tracker := &overlapTracker{
+		grouped: map[string]bool{"deploy-a.yml": true, "deploy-b.yml": true},
+		active:  map[string]int{},
+	}
+
+	if err := newScheduler(4, jobs).run(jobs, tracker.run); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if tracker.overlaps != 0 {
+		t.Fatalf("workflows sharing a concurrency group overlapped")
+	}
+	if tracker.maxTotal < 3 {
+		t.Fatalf("unrelated jobs should run in parallel, max concurrency was %d", tracker.maxTotal)
+	}
+	// deploy-b must wait for both deploy-a jobs; lint jobs may start first.
+	var seenB bool
+	for _, path := range tracker.starts {
+		if path == "deploy-b.yml" {
+			seenB = true
+		}
+		if path == "deploy-a.yml" && seenB {
+			t.Fatalf("deploy-a started after deploy-b: %v", tracker.starts)
+		}
+	}
+}
+
+func TestSchedulerSequentialKeepsOrder(t *testing.T) {
+	workflows := []provider.Workflow{
+		{Path: "a.yml", Jobs: []provider.Job{{RawID: "one"}, {RawID: "two"}}},
+		{Path: "b.yml", Jobs: []provider.Job{{RawID: "three"}}},
+	}
+	jobs := scheduledJobs(workflows)
+	var order []string
+	err := newScheduler(1, jobs).run(jobs, func(j scheduledJob) error {
+		order = append(order, j.job.RawID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(order) != 3 || order[0] != "one" || order[1] != "two" || order[2] != "three" {
+		t.Fatalf("unexpected order %v", order)
+	}
+}
+
+func TestSchedulerOrderedKeepsStartOrder(t *testing.T) {
+	group := &provider.Concurrency{Group: "deploy"}
+	workflows := []provider.Workflow{
+		{Path: "deploy-a.yml", Concurrency: group, Jobs: []provider.Job{{RawID: "build"}}},
+		{Path: "deploy-b.yml", Concurrency: group, Jobs: []provider.Job{{RawID: "migrate"}}},
+		{Path: "lint.yml", Jobs: []provider.Job{{RawID: "lint"}}},
+	}
+	jobs := scheduledJobs(workflows)
+	tracker := &overlapTracker{
+		grouped: map[string]bool{"deploy-a.yml": true, "deploy-b.yml": true},
+		active:  map[string]int{},
+	}
+
+	var started []string
+	s := newScheduler(4, jobs)
+	s.ordered = true
+	s.started = func(j scheduledJob) { started = append(started, j.wf.Path) }
+	if err := s.run(jobs, tracker.run); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	// lint is free to run, but must not overtake deploy-b waiting on the group.
+	want := []string{"deploy-a.yml", "deploy-b.yml", "lint.yml"}
+	if !reflect.DeepEqual(started, want) {
+		t.Fatalf("starts = %v, want %v", started, want)
+	}
+	if tracker.overlaps != 0 {
+		t.Fatalf("workflows sharing a concurrency group overlapped")
+	}
+}
+
+func TestRunnerReplaysJobOrder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	steps := []provider.Step{{Name: "first", Run: "echo one"}, {Name: "second", Run: "echo two"}}
+	workflows := []provider.Workflow{
+		{Path: "ci.yml", Name: "CI", Jobs: []provider.Job{{Name: "a", Steps: steps}, {Name: "b", Steps: steps}}},
+		{Path: "docs.yml", Name: "Docs", Jobs: []provider.Job{{Name: "c", Steps: steps}}},
+	}
+	order := []history.JobKey{{Workflow: "docs.yml", Job: "c"}, {Workflow: "ci.yml", Job: "b"}, {Workflow: "gone.yml", Job: "x"}}
+
+	for _, parallel := range []int{1, 3} {
+		results, summary, err := New(Options{Root: t.TempDir(), MaxParallel: parallel, JobOrder: order}).Run(context.Background(), workflows)
+		if err != nil {
+			t.Fatalf("runner Run: %v", err)
+		}
+		started := map[int]string{}
+		for _, job := range summary.Jobs {
+			started[job.Sequence] = job.JobName
+			if job.StartedAt.IsZero() {
+				t.Fatalf("job %s has no start time", job.JobName)
+			}
+		}
+		// Jobs the order leaves out start after the listed ones.
+		if got := []string{started[1], started[2], started[3]}; !reflect.DeepEqual(got, []string{"c", "b", "a"}) {
+			t.Fatalf("max-parallel %d: jobs started %v, want [c b a]", parallel, got)
+		}
+		if results[0].JobName != "a" || results[len(results)-1].JobName != "c" {
+			t.Fatalf("results should keep declared order: %+v", results)
+		}
+		seen := map[int]bool{}
+		for _, res := range results {
+			if res.Sequence < 1 || res.Sequence > len(results) || seen[res.Sequence] {
+				t.Fatalf("step sequence numbers should be distinct and 1..%d: %+v", len(results), results)
+			}
+			seen[res.Sequence] = true
+		}
+		if parallel == 1 && (results[4].Sequence != 1 || results[5].Sequence != 2) {
+			t.Fatalf("job c's steps should start first when running sequentially: %+v", results)
+		}
+	}
+}
+
+func TestRunnerMaxParallelHonorsConcurrencyGroups(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	root := t.TempDir()
+	// mkdir is atomic: a second job in the group fails if the first still
+	// holds the lock directory.
+	locked := "mkdir deploy.lock && sleep 0.2 && rmdir deploy.lock"
+	group := &provider.Concurrency{Group: "deploy"}
+	step := func(run string) []provider.Step { return []provider.Step{{Name: "step", Run: run}} }
+	workflows := []provider.Workflow{
+		{Path: "a.yml", Name: "A", Concurrency: group, Jobs: []provider.Job{{Name: "deploy", RawID: "deploy", Steps: step(locked)}}},
+		{Path: "b.yml", Name: "B", Concurrency: group, Jobs: []provider.Job{{Name: "deploy", RawID: "deploy", Steps: step(locked)}}},
+		{Path: "c.yml", Name: "C", Jobs: []provider.Job{{Name: "lint", RawID: "lint", Steps: step("echo lint")}}},
+	}
+
+	results, summary, err := New(Options{Root: root, MaxParallel: 3}).Run(context.Background(), workflows)
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if summary.Failed != 0 || summary.Passed != 3 {
+		t.Fatalf("same-group jobs overlapped: %+v", results)
+	}
+	for i, want := range []string{"a.yml", "b.yml", "c.yml"} {
+		if results[i].WorkflowPath != want {
+			t.Fatalf("results should keep workflow order, got %s at %d", results[i].WorkflowPath, i)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "deploy.lock")); !os.IsNotExist(err) {
+		t.Fatalf("lock directory left behind")
+	}
+}
+
+// matrixWorkflow expands a four-variant matrix whose second variant fails,
+// plus an unrelated job that always passes.
+func matrixWorkflow(strategy provider.Strategy) provider.Workflow {
+	runs := []string{"sleep 0.3", "exit 1", "echo three", "echo four"}
+	wf := provider.Workflow{Path: "ci.yml", Name: "CI"}
+	for i, run := range runs {
+		variant := string(rune('1' + i))
+		wf.Jobs = append(wf.Jobs, provider.Job{
+			Name:     "test (" + variant + ")",
+			RawID:    "test",
+			Variant:  variant,
+			Strategy: &strategy,
+			Steps:    []provider.Step{{Name: "step", Run: run}},
+		})
+	}
+	wf.Jobs = append(wf.Jobs, provider.Job{Name: "lint", RawID: "lint", Steps: []provider.Step{{Name: "step", Run: "echo lint"}}})
+	return wf
+}
+
+func TestRunnerMatrixFailFastCancelsQueuedVariants(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	for _, maxParallel := range []int{1, 4} {
+		wf := matrixWorkflow(provider.Strategy{MaxParallel: 2, FailFast: true})
+		results, summary, err := New(Options{Root: t.TempDir(), MaxParallel: maxParallel}).Run(context.Background(), []provider.Workflow{wf})
+		if err != nil {
+			t.Fatalf("runner Run: %v", err)
+		}
+		want := map[string]string{
+			"test (1)": "passed",
+			"test (2)": "failed",
+			"test (3)": "skipped",
+			"test (4)": "skipped",
+			"lint":     "passed",
+		}
+		if len(results) != len(want) {
+			t.Fatalf("max-parallel %d: expected %d results, got %+v", maxParallel, len(want), results)
+		}
+		for _, res := range results {
+			if res.Status != want[res.JobName] {
+				t.Fatalf("max-parallel %d: %s was %s, want %s", maxParallel, res.JobName, res.Status, want[res.JobName])
+			}
+			if res.Status == "skipped" && (res.SkipReason != report.ReasonCancelled || res.SkipDetail != "cancelled by fail-fast") {
+				t.Fatalf("expected a fail-fast cancellation, got %+v", res)
+			}
+		}
+		if summary.Failed != 1 || summary.Skipped != 2 || summary.ExitCode != 1 {
+			t.Fatalf("max-parallel %d: unexpected summary %+v", maxParallel, summary)
+		}
+	}
+}
+
+func TestRunnerMatrixWithoutFailFastRunsEveryVariant(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	wf := matrixWorkflow(provider.Strategy{FailFast: false})
+	_, summary, err := New(Options{Root: t.TempDir(), MaxParallel: 4}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if summary.Passed != 4 || summary.Failed != 1 || summary.Skipped != 0 {
+		t.Fatalf("expected every variant to run, got %+v", summary)
+	}
+}
+
+func TestSchedulerMatrixMaxParallel(t *testing.T) {
+	strategy := &provider.Strategy{MaxParallel: 2}
+	wf := provider.Workflow{Path: "ci.yml"}
+	for _, variant := range []string{"a", "b", "c", "d"} {
+		wf.Jobs = append(wf.Jobs, provider.Job{Name: "test (" + variant + ")", RawID: "test", Variant: variant, Strategy: strategy})
+	}
+	wf.Jobs = append(wf.Jobs, provider.Job{RawID: "lint"}, provider.Job{RawID: "vet"})
+	var jobs []scheduledJob
+	for _, job := range wf.Jobs {
+		jobs = append(jobs, newScheduledJob(wf, job, "refs/heads/main"))
+	}
+
+	var mu sync.Mutex
+	var variants, maxVariants, total, maxTotal int
+	err := newScheduler(8, jobs).run(jobs, func(j scheduledJob) error {
+		mu.Lock()
+		total++
+		maxTotal = max(maxTotal, total)
+		if j.matrix != "" {
+			variants++
+			maxVariants = max(maxVariants, variants)
+		}
+		mu.Unlock()
+
+		time.Sleep(40 * time.Millisecond)
+
+		mu.Lock()
+		total--
+		if j.matrix != "" {
+			variants--
+		}
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if maxVariants != 2 {
+		t.Fatalf("expected at most two variants at once, got %d", maxVariants)
+	}
+	if maxTotal < 4 {
+		t.Fatalf("the variant cap should not hold back other jobs, max concurrency was %d", maxTotal)
+	}
+}
+
+func TestLongestFirstOrder(t *testing.T) {
+	workflows := []provider.Workflow{
+		{Path: "ci.yml", Jobs: []provider.Job{{Name: "lint"}, {Name: "unit"}, {Name: "new"}, {Name: "system"}}},
+		{Path: "docs.yml", Jobs: []provider.Job{{Name: "build"}, {Name: "links"}}},
+	}
+	durations := map[history.JobKey]time.Duration{
+		{Workflow: "ci.yml", Job: "lint"}:    30 * time.Second,
+		{Workflow: "ci.yml", Job: "unit"}:    2 * time.Minute,
+		{Workflow: "ci.yml", Job: "system"}:  6 * time.Minute,
+		{Workflow: "docs.yml", Job: "build"}: 2 * time.Minute,
+	}
+
+	var got []string
+	for _, j := range longestFirst(scheduledJobs(workflows), durations) {
+		got = append(got, j.wf.Path+"/"+j.job.Name)
+	}
+	// Ties and unknown jobs keep their declared order.
+	want := []string{"ci.yml/system", "ci.yml/unit", "docs.yml/build", "ci.yml/lint", "ci.yml/new", "docs.yml/links"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+}
+
+// makespan replays the scheduler's start rule, the first queued job whenever
+// a slot is free, on clock and returns the simulated wall time.
+func makespan(clock *fakeClock, jobs []scheduledJob, limit int, durations map[history.JobKey]time.Duration) time.Duration {
+	start := clock.Now()
+	var finishing []time.Time
+	for _, j := range jobs {
+		if len(finishing) == limit {
+			sort.Slice(finishing, func(a, b int) bool { return finishing[a].Before(finishing[b]) })
+			clock.Advance(finishing[0].Sub(clock.Now()))
+			finishing = finishing[1:]
+		}
+		finishing = append(finishing, clock.Now().Add(durations[jobKey(j)]))
+	}
+	for _, end := range finishing {
+		if end.After(clock.Now()) {
+			clock.Advance(end.Sub(clock.Now()))
+		}
+	}
+	return clock.Now().Sub(start)
+}
+
+func TestLongestFirstShortensWallTime(t *testing.T) {
+	wf := provider.Workflow{Path: "ci.yml"}
+	durations := map[history.JobKey]time.Duration{}
+	for i, d := range []time.Duration{time.Minute, time.Minute, time.Minute, time.Minute, 6 * time.Minute} {
+		name := string(rune('a' + i))
+		wf.Jobs = append(wf.Jobs, provider.Job{Name: name})
+		durations[history.JobKey{Workflow: "ci.yml", Job: name}] = d
+	}
+	jobs := scheduledJobs([]provider.Workflow{wf})
+
+	declared := makespan(newFakeClock(), jobs, 2, durations)
+	longest := makespan(newFakeClock(), longestFirst(jobs, durations), 2, durations)
+	if declared != 8*time.Minute {
+		t.Fatalf("declared order took %s, want 8m", declared)
+	}
+	if longest != 6*time.Minute {
+		t.Fatalf("longest-first took %s, want 6m", longest)
+	}
+}
@@ -0,0 +1,128 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// exitCannotExecute is the shell's exit status for a command it found but
+// could not run.
+const exitCannotExecute = 126
+
+// scriptProblem is why a repo-relative script a step invokes would fail to
+// start, found before the shell gets to report it less clearly.
+type scriptProblem struct {
+	msg      string
+	hint     string
+	exitCode int
+}
+
+func (p *scriptProblem) Error() string { return p.msg }
+
+// localScript returns the path of the script a run block starts with when it
+// is repo-relative, as in `./bin/ci/lint.sh` or `bin/rails test`, and "" for
+// anything else. Only the first command is considered, and paths built from
+// variables or quotes are left to the shell.
+func localScript(run string) string {
+	for _, line := range strings.Split(run, "\n") {
+		for _, field := range strings.Fields(line) {
+			if envAssignment.MatchString(field) {
+				continue
+			}
+			if strings.HasPrefix(field, "#") {
+				break
+			}
+			field = strings.TrimRight(field, ";&|")
+			if !strings.HasPrefix(field, "./") && !strings.HasPrefix(field, "bin/") {
+				return ""
+			}
+			if strings.ContainsAny(field, "$`'\"*?") {
+				return ""
+			}
+			return field
+		}
+	}
+	return ""
+}
+
+// posixShells run a script's first word as a command; other shells (pwsh,
+// python) read run differently and are not checked.
+var posixShells = map[string]bool{"bash": true, "sh": true, "zsh": true, "ksh": true, "dash": true, "fish": true}
+
+// checkLocalScript looks at the repo-relative script run starts with, if any,
+// and reports whether it is missing, a directory, saved with a byte order
+// mark or CRLF line endings that break its #! line, or not executable.
+// shell is the program the step runs under.
+func checkLocalScript(shell, run, workDir string) *scriptProblem {
+	if !posixShells[strings.TrimSuffix(filepath.Base(shell), ".exe")] {
+		return nil
+	}
+	script := localScript(run)
+	if script == "" {
+		return nil
+	}
+	path := filepath.Join(workDir, filepath.FromSlash(script))
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return &scriptProblem{
+			msg:      fmt.Sprintf("script `%s` does not exist in %s", script, workDir),
+			hint:     "the path is relative to the step's working directory; check the step's working-directory and that the script is committed",
+			exitCode: exitCommandNotFound,
+		}
+	}
+	if err != nil {
+		return nil
+	}
+	if info.IsDir() {
+		return &scriptProblem{
+			msg:      fmt.Sprintf("script `%s` is a directory", script),
+			exitCode: exitCannotExecute,
+		}
+	}
+
+	if head, err := readHead(path, 256); err == nil {
+		if bytes.HasPrefix(head, []byte("\xef\xbb\xbf")) {
+			return &scriptProblem{
+				msg:      fmt.Sprintf("script `%s` starts with a UTF-8 byte order mark, so its #! line is not recognized", script),
+				hint:     "save the file as UTF-8 without a BOM",
+				exitCode: exitCannotExecute,
+			}
+		}
+		if first, _, _ := bytes.Cut(head, []byte("\n")); bytes.HasPrefix(first, []byte("#!")) && bytes.HasSuffix(first, []byte("\r")) {
+			return &scriptProblem{
+				msg:      fmt.Sprintf("script `%s` has CRLF line endings, so its interpreter `%s` cannot be found", script, strings.TrimSpace(string(first[2:]))+`\r`),
+				hint:     fmt.Sprintf("convert it to LF line endings (`sed -i 's/\\r$//' %s`) and add `%s text eol=lf` to .gitattributes", script, strings.TrimPrefix(script, "./")),
+				exitCode: exitCannotExecute,
+			}
+		}
+	}
+
+	if runtime.GOOS != "windows" && info.Mode()&0o111 == 0 {
+		return &scriptProblem{
+			msg:      fmt.Sprintf("script `%s` is not executable", script),
+			hint:     fmt.Sprintf("run `chmod +x %s`; if CI checks it out executable, git may not track the mode locally (`git update-index --chmod=+x %s`)", script, script),
+			exitCode: exitCannotExecute,
+		}
+	}
+	return nil
+}
+
+// readHead returns up to n bytes from the start of path.
+func readHead(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, n)
+	read, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return buf[:read], nil
+}
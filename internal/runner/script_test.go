@@ -0,0 +1,174 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestLocalScript(t *testing.T) {
+	cases := []struct {
+		run  string
+		want string
+	}{
+		{"./bin/ci/lint.sh", "./bin/ci/lint.sh"},
+		{"bin/rails test", "bin/rails"},
+		{"RAILS_ENV=test bin/rails test", "bin/rails"},
+		{"# lint\n./lint.sh --fix", "./lint.sh"},
+		{"./configure; make", "./configure"},
+		{"bash ./lint.sh", ""},
+		{"make && ./lint.sh", ""},
+		{"./$SCRIPT", ""},
+		{"\"./my script.sh\"", ""},
+		{"", ""},
+	}
+	for _, tc := range cases {
+		if got := localScript(tc.run); got != tc.want {
+			t.Errorf("localScript(%q) = %q, want %q", tc.run, got, tc.want)
+		}
+	}
+}
+
+func TestCheckLocalScript(t *testing.T) {
+	cases := []struct {
+		name     string
+		run      string
+		content  string
+		mode     os.FileMode
+		shell    string
+		want     string
+		hint     string
+		exitCode int
+	}{
+		{name: "fine", run: "./ok.sh", content: "#!/bin/sh\necho ok\n", mode: 0o755},
+		{name: "missing", run: "./gone.sh", want: "script `./gone.sh` does not exist in", hint: "working directory", exitCode: 127},
+		{name: "not executable", run: "./lint.sh", content: "#!/bin/sh\n", mode: 0o644, want: "script `./lint.sh` is not executable", hint: "chmod +x ./lint.sh", exitCode: 126},
+		{name: "byte order mark", run: "./lint.sh", content: "\xef\xbb\xbf#!/bin/sh\n", mode: 0o755, want: "byte order mark", hint: "without a BOM", exitCode: 126},
+		{name: "crlf shebang", run: "./lint.sh", content: "#!/bin/bash\r\necho hi\r\n", mode: 0o755, want: "interpreter `/bin/bash\\r`", hint: "lint.sh text eol=lf", exitCode: 126},
+		{name: "crlf body only", run: "./lint.sh", content: "echo hi\r\n", mode: 0o755},
+		{name: "other shells unchecked", run: "./gone.sh", shell: "pwsh"},
+		{name: "bare commands unchecked", run: "make lint"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.mode&0o111 == 0 && tc.content != "" && runtime.GOOS == "windows" {
+				t.Skip("no executable bit on Windows")
+			}
+			dir := t.TempDir()
+			if tc.content != "" {
+				path := filepath.Join(dir, strings.TrimPrefix(tc.run, "./"))
+				if err := os.WriteFile(path, []byte(tc.content), tc.mode); err != nil {
+					t.Fatalf("write script: %v", err)
+				}
+			}
+			shell := tc.shell
+			if shell == "" {
+				shell = "bash"
+			}
+			problem := checkLocalScript(shell, tc.run, dir)
+			if tc.want == "" {
+				if problem != nil {
+					t.Fatalf("unexpected problem: %v", problem)
+				}
+				return
+			}
+			if problem == nil {
+				t.Fatalf("expected a problem containing %q", tc.want)
+			}
+			if !strings.Contains(problem.Error(), tc.want) || !strings.Contains(problem.hint, tc.hint) || problem.exitCode != tc.exitCode {
+				t.Fatalf("got %q (hint %q, exit %d), want %q (hint %q, exit %d)", problem.Error(), problem.hint, problem.exitCode, tc.want, tc.hint, tc.exitCode)
+			}
+		})
+	}
+}
+
+func TestRunnerFailsNonExecutableScriptBeforeRunning(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no executable bit on Windows")
+	}
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "lint.sh"), []byte("#!/bin/sh\necho linted\n"), 0o644); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	workflows := []provider.Workflow{{Path: "ci.yml", Jobs: []provider.Job{{Name: "lint", Steps: []provider.Step{{Name: "Lint", Run: "./lint.sh"}}}}}}
+	results, _, err := New(Options{Root: root}).Run(context.Background(), workflows)
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	res := results[0]
+	if res.Status != "failed" || res.ExitCode != 126 || res.Stderr != "script `./lint.sh` is not executable" || !strings.Contains(res.Hint, "chmod +x ./lint.sh") {
+		t.Fatalf("expected a clear preflight failure, got %+v", res)
+	}
+	if res.Stdout != "" {
+		t.Fatalf("the script should not have run: %q", res.Stdout)
+	}
+}
+
+func TestRunnerInterpreterShells(t *testing.T) {
+	interpreters := map[string]string{
+		"python": "import os\nwith open('python.txt', 'w') as f:\n    f.write('ran')\n",
+		"ruby":   "File.write('ruby.txt',\n  'ran')\n",
+		"node":   "require('fs').writeFileSync('node.txt',\n  'ran')\n",
+	}
+	root := t.TempDir()
+	var workflows []provider.Workflow
+	for name, script := range interpreters {
+		if _, err := exec.LookPath(name); err != nil {
+			continue
+		}
+		workflows = append(workflows, provider.Workflow{
+			Path:     name + ".yml",
+			Defaults: provider.Defaults{RunShell: name},
+			Jobs: []provider.Job{{
+				Name:  name,
+				Steps: []provider.Step{{Name: "script", Run: script}},
+			}},
+		})
+	}
+	if len(workflows) == 0 {
+		t.Skip("no python, ruby or node installed")
+	}
+
+	results, summary, err := New(Options{Root: root}).Run(context.Background(), workflows)
+	if err != nil {
+		t.Fatalf("run: %v (%+v)", err, results)
+	}
+	if summary.Passed != len(workflows) {
+		t.Fatalf("expected %d passed, got %+v", len(workflows), results)
+	}
+	for _, wf := range workflows {
+		name := wf.Jobs[0].Name
+		if data, err := os.ReadFile(filepath.Join(root, name+".txt")); err != nil || string(data) != "ran" {
+			t.Fatalf("%s script did not run: %q, %v", name, data, err)
+		}
+	}
+}
+
+func TestWriteScriptFile(t *testing.T) {
+	args, remove, err := writeScriptFile("", []string{"deno", "run", "{0}"}, "console.log(1)\n")
+	if err != nil {
+		t.Fatalf("writeScriptFile: %v", err)
+	}
+	path := args[2]
+	if filepath.Ext(path) != ".ts" {
+		t.Fatalf("expected a .ts file for deno, got %q", path)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "console.log(1)\n" {
+		t.Fatalf("script file holds %q, %v", data, err)
+	}
+	remove()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the script file to be removed, got %v", err)
+	}
+
+	plain := []string{"python", "-c", "print(1)"}
+	if args, _, err := writeScriptFile("", plain, "print(1)"); err != nil || &args[0] != &plain[0] {
+		t.Fatalf("expected args without a placeholder unchanged, got %q, %v", args, err)
+	}
+}
@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/resolve"
+)
+
+// scriptExtensions names the extension a script file gets for the program
+// that runs it; some, like pwsh and cmd, refuse files without one.
+var scriptExtensions = map[string]string{
+	"bash":       ".sh",
+	"sh":         ".sh",
+	"zsh":        ".sh",
+	"ksh":        ".sh",
+	"python":     ".py",
+	"python3":    ".py",
+	"ruby":       ".rb",
+	"node":       ".js",
+	"deno":       ".ts",
+	"pwsh":       ".ps1",
+	"powershell": ".ps1",
+	"cmd":        ".cmd",
+}
+
+// writeScriptFile writes script to a temp file in dir, or the system temp
+// directory when dir is empty, and puts its path in place of
+// resolve.ScriptPlaceholder in args. It returns args unchanged when they have
+// no placeholder. The returned function removes the file.
+func writeScriptFile(dir string, args []string, script string) ([]string, func(), error) {
+	templated := false
+	for _, arg := range args {
+		if strings.Contains(arg, resolve.ScriptPlaceholder) {
+			templated = true
+			break
+		}
+	}
+	if !templated {
+		return args, func() {}, nil
+	}
+	ext := scriptExtensions[strings.TrimSuffix(strings.ToLower(filepath.Base(args[0])), ".exe")]
+	f, err := os.CreateTemp(dir, "testdrive-script-*"+ext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create script file: %w", err)
+	}
+	_, err = f.WriteString(script)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, nil, fmt.Errorf("write script file: %w", err)
+	}
+	filled := make([]string, len(args))
+	for i, arg := range args {
+		filled[i] = strings.ReplaceAll(arg, resolve.ScriptPlaceholder, f.Name())
+	}
+	return filled, func() { os.Remove(f.Name()) }, nil
+}
@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+var (
+	// snapshotAllowed names the variables whose values a snapshot keeps:
+	// the ones that commonly explain why a step behaves differently on two
+	// machines and do not hold credentials.
+	snapshotAllowed = map[string]bool{
+		"PATH": true, "LANG": true, "LANGUAGE": true, "LC_ALL": true, "LC_CTYPE": true, "TZ": true,
+		"SHELL": true, "CI": true,
+		"RAILS_ENV": true, "RACK_ENV": true, "NODE_ENV": true, "APP_ENV": true, "MIX_ENV": true,
+		"GOOS": true, "GOARCH": true, "GOFLAGS": true, "CGO_ENABLED": true,
+		"GEM_HOME": true, "BUNDLE_GEMFILE": true, "VIRTUAL_ENV": true, "JAVA_HOME": true,
+	}
+	// snapshotVersionVar matches tool version variables such as
+	// RUBY_VERSION, NODE_VERSION, and ASDF_PYTHON_VERSION.
+	snapshotVersionVar = regexp.MustCompile(`^[A-Z][A-Z0-9_]*_VERSION$`)
+	// snapshotSecretName matches names that may hold a credential. Their
+	// values are masked even when another rule would allow them.
+	snapshotSecretName = regexp.MustCompile(`(?i)token|secret|passw|key|credential|auth|cookie|session|private|signature|dsn`)
+)
+
+// snapshotEnv records env for a failed step. Every name is kept; values are
+// masked unless the name is allowlisted or a tool version variable, and
+// never shown for a name that looks like it holds a secret. tools are the
+// versions the run's version check detected.
+func snapshotEnv(env []string, shell string, tools map[string]string) *report.EnvSnapshot {
+	snap := &report.EnvSnapshot{Env: make(map[string]string, len(env))}
+	if shell != "" {
+		snap.Shell = filepath.Base(shell)
+	}
+	for _, kv := range env {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			continue
+		}
+		snap.Env[name] = snapshotValue(name, value)
+	}
+	if len(tools) > 0 {
+		snap.ToolVersions = make(map[string]string, len(tools))
+		for tool, v := range tools {
+			snap.ToolVersions[tool] = v
+		}
+	}
+	return snap
+}
+
+// snapshotValue returns value when name may be shown, and
+// report.MaskedValue otherwise.
+func snapshotValue(name, value string) string {
+	if snapshotSecretName.MatchString(name) {
+		return report.MaskedValue
+	}
+	if snapshotAllowed[name] || snapshotVersionVar.MatchString(name) {
+		return value
+	}
+	return report.MaskedValue
+}
@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func TestSnapshotEnvRedaction(t *testing.T) {
+	env := []string{
+		"PATH=/usr/local/bin:/usr/bin",
+		"LANG=en_US.UTF-8",
+		"RAILS_ENV=test",
+		"NODE_ENV=test",
+		"RUBY_VERSION=3.3.0",
+		"ASDF_NODEJS_VERSION=20.11.0",
+		"DATABASE_URL=postgres://admin:hunter2@db/app",
+		"GITHUB_TOKEN=ghp_secret",
+		"AWS_SECRET_ACCESS_KEY=abc123",
+		"API_KEY_VERSION=2",
+		"TOKEN_VERSION=hunter2",
+		"STRIPE_PASSWORD=pw",
+		"HOME=/home/dev",
+		"EMPTY=",
+		"no-equals-sign",
+	}
+	snap := snapshotEnv(env, "/bin/bash", map[string]string{"ruby": "3.3.0"})
+
+	want := map[string]string{
+		"PATH":                  "/usr/local/bin:/usr/bin",
+		"LANG":                  "en_US.UTF-8",
+		"RAILS_ENV":             "test",
+		"NODE_ENV":              "test",
+		"RUBY_VERSION":          "3.3.0",
+		"ASDF_NODEJS_VERSION":   "20.11.0",
+		"DATABASE_URL":          report.MaskedValue,
+		"GITHUB_TOKEN":          report.MaskedValue,
+		"AWS_SECRET_ACCESS_KEY": report.MaskedValue,
+		"API_KEY_VERSION":       report.MaskedValue,
+		"TOKEN_VERSION":         report.MaskedValue,
+		"STRIPE_PASSWORD":       report.MaskedValue,
+		"HOME":                  report.MaskedValue,
+		"EMPTY":                 report.MaskedValue,
+	}
+	if len(snap.Env) != len(want) {
+		t.Fatalf("snapshot has %d variables, want %d: %v", len(snap.Env), len(want), snap.Env)
+	}
+	for name, value := range want {
+		if got, ok := snap.Env[name]; !ok || got != value {
+			t.Errorf("%s = %q (recorded %v), want %q", name, got, ok, value)
+		}
+	}
+	for _, secret := range []string{"hunter2", "ghp_secret", "abc123", "pw", "/home/dev"} {
+		for name, value := range snap.Env {
+			if strings.Contains(value, secret) {
+				t.Errorf("%s leaks %q", name, secret)
+			}
+		}
+	}
+	if snap.Shell != "bash" || snap.ToolVersions["ruby"] != "3.3.0" {
+		t.Fatalf("shell %q, tools %v", snap.Shell, snap.ToolVersions)
+	}
+}
+
+func TestRunnerSnapshotsFailedSteps(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	workflows := []provider.Workflow{{Path: "ci.yml", Env: map[string]string{"RAILS_ENV": "test", "API_TOKEN": "hunter2"}, Jobs: []provider.Job{{Name: "test", Steps: []provider.Step{
+		{Name: "Fails", Run: "exit 1"},
+		{Name: "Passes", Run: "true"},
+	}}}}}
+	opts := Options{Root: t.TempDir(), Env: []string{"PATH=/usr/bin:/bin"}, ToolVersions: map[string]string{"node": "20.11.0"}}
+	results, _, err := New(opts).Run(context.Background(), workflows)
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	snap := results[0].EnvSnapshot
+	if snap == nil {
+		t.Fatalf("expected a snapshot on the failed step")
+	}
+	if snap.Env["RAILS_ENV"] != "test" || snap.Env["API_TOKEN"] != report.MaskedValue || snap.Env["PATH"] != "/usr/bin:/bin" {
+		t.Fatalf("unexpected snapshot env: %v", snap.Env)
+	}
+	if snap.Shell != "bash" || snap.ToolVersions["node"] != "20.11.0" {
+		t.Fatalf("shell %q, tools %v", snap.Shell, snap.ToolVersions)
+	}
+	if results[1].EnvSnapshot != nil {
+		t.Fatalf("expected no snapshot on a passing step, got %+v", results[1].EnvSnapshot)
+	}
+}
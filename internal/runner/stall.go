@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+// stallError cancels a step that wrote no output for StallTimeout.
+type stallError struct {
+	silent time.Duration
+}
+
+func (e *stallError) Error() string {
+	return fmt.Sprintf("no output for %s", output.FormatDuration(e.silent))
+}
+
+// stallHint is the hint shown on a step killed for going quiet.
+const stallHint = "the step was killed by stall_timeout; raise or unset it if the step is expected to run quietly"
+
+// activity notes when a running step last wrote output.
+type activity struct {
+	clock Clock
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func newActivity(clock Clock) *activity {
+	return &activity{clock: clock, last: clock.Now()}
+}
+
+// wrap returns w, noting the time of every write through it.
+func (a *activity) wrap(w io.Writer) io.Writer {
+	return &activityWriter{w: w, a: a}
+}
+
+func (a *activity) lastWrite() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.last
+}
+
+type activityWriter struct {
+	w io.Writer
+	a *activity
+}
+
+func (w *activityWriter) Write(p []byte) (int, error) {
+	now := w.a.clock.Now()
+	w.a.mu.Lock()
+	w.a.last = now
+	w.a.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// watchStall watches a running step's output on the runner's clock until
+// the returned function is called. Each time the step goes StallWarningAfter
+// without writing, warn is called once; once it goes StallTimeout without
+// writing, kill is called and watching stops.
+func (r *Runner) watchStall(act *activity, warn, kill func(silent time.Duration)) func() {
+	warnAfter, timeout := r.opts.StallWarningAfter, r.opts.StallTimeout
+	if warnAfter <= 0 && timeout <= 0 {
+		return func() {}
+	}
+	done, finished := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(finished)
+		// warned is the last write of the silence already warned about.
+		var warned time.Time
+		for {
+			last := act.lastWrite()
+			silent := r.opts.Clock.Now().Sub(last)
+			if timeout > 0 && silent >= timeout {
+				kill(silent)
+				return
+			}
+			if warnAfter > 0 && silent >= warnAfter && !warned.Equal(last) {
+				warned = last
+				warn(silent)
+			}
+			var wait time.Duration
+			if timeout > 0 {
+				wait = timeout - silent
+			}
+			if warnAfter > 0 {
+				next := warnAfter - silent
+				if warned.Equal(last) {
+					// Look again once output that came since could
+					// have gone quiet for as long.
+					next = warnAfter
+				}
+				if wait <= 0 || next < wait {
+					wait = next
+				}
+			}
+			select {
+			case <-done:
+				return
+			case <-r.opts.Clock.After(wait):
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// warnStall reports that step has written nothing for silent: through the
+// streaming renderer when it shows stalls, or as a warning on stderr
+// otherwise.
+func (r *Runner) warnStall(wf provider.Workflow, job provider.Job, step provider.Step, jobID string, silent time.Duration) {
+	if reporter, ok := r.opts.StreamingRenderer.(output.StallReporter); ok && r.opts.Streaming {
+		if err := reporter.StepStalled(jobID, output.StepLabel(step.Name, step.Overridden), silent); !errors.Is(err, output.ErrStallNotShown) {
+			return
+		}
+	}
+	r.mux.warn(fmt.Sprintf("warning: %s / %s / %s: no output for %s\n", wf.Name, job.Name, step.Name, output.FormatDuration(silent)))
+}
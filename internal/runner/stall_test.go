@@ -0,0 +1,165 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/output"
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+// quietCommand runs until done is closed or ctx ends, writing a line each
+// time write receives and acknowledging it once written.
+func quietCommand(write chan chan struct{}, done chan struct{}) fakeCommand {
+	return fakeCommand{run: func(ctx context.Context, spec ExecSpec) (ExecResult, error) {
+		for {
+			select {
+			case ack := <-write:
+				io.WriteString(spec.Stdout, "tick\n")
+				close(ack)
+			case <-done:
+				return ExecResult{}, nil
+			case <-ctx.Done():
+				return ExecResult{ExitCode: -1}, ctx.Err()
+			}
+		}
+	}}
+}
+
+// runAsync starts r on wf and returns a channel that delivers its results.
+func runAsync(t *testing.T, r *Runner, wf provider.Workflow) <-chan []report.StepResult {
+	t.Helper()
+	ch := make(chan []report.StepResult, 1)
+	go func() {
+		results, _, err := r.Run(context.Background(), []provider.Workflow{wf})
+		if err != nil {
+			t.Errorf("runner Run: %v", err)
+		}
+		ch <- results
+	}()
+	return ch
+}
+
+func TestStallWarningAfterSilence(t *testing.T) {
+	clock := newFakeClock()
+	write, done := make(chan chan struct{}), make(chan struct{})
+	fake := &fakeExecutor{clock: clock, commands: map[string]fakeCommand{"make test": quietCommand(write, done)}}
+	stderr := &bytes.Buffer{}
+	r := New(Options{Root: t.TempDir(), Clock: clock, Executor: fake, Stderr: stderr, StallWarningAfter: 2 * time.Minute})
+	results := runAsync(t, r, sampleWorkflow("make test"))
+
+	clock.waitForTimers(t, 1)
+	clock.Advance(90 * time.Second)
+	ack := make(chan struct{})
+	write <- ack
+	<-ack
+	// Two and a half minutes in, but only one since the step wrote.
+	clock.Advance(time.Minute)
+	clock.waitForTimers(t, 1)
+	if stderr.Len() != 0 {
+		t.Fatalf("warned before the step went quiet for long enough: %q", stderr)
+	}
+
+	clock.Advance(time.Minute)
+	clock.waitForTimers(t, 1)
+	want := "warning: workflow / job / step: no output for 2m00s\n"
+	if stderr.String() != want {
+		t.Fatalf("stderr = %q, want %q", stderr, want)
+	}
+
+	// A silence is warned about once.
+	clock.Advance(2 * time.Minute)
+	clock.waitForTimers(t, 1)
+	close(done)
+	got := <-results
+	if stderr.String() != want {
+		t.Fatalf("stderr = %q, want only %q", stderr, want)
+	}
+	if got[0].Status != "passed" {
+		t.Fatalf("a warned step should still pass, got %+v", got[0])
+	}
+}
+
+func TestStallTimeoutKillsStep(t *testing.T) {
+	clock := newFakeClock()
+	fake := &fakeExecutor{clock: clock, commands: map[string]fakeCommand{"make test": quietCommand(nil, nil)}}
+	r := New(Options{Root: t.TempDir(), Clock: clock, Executor: fake, StallTimeout: 5 * time.Minute})
+	results := runAsync(t, r, sampleWorkflow("make test"))
+
+	clock.waitForTimers(t, 1)
+	clock.Advance(5 * time.Minute)
+	got := (<-results)[0]
+	if got.Status != "failed" {
+		t.Fatalf("Status = %q, want failed", got.Status)
+	}
+	if !strings.Contains(got.Stderr, "testdrive: killed after no output for 5m00s") || got.Hint != stallHint {
+		t.Fatalf("unexpected result: stderr %q, hint %q", got.Stderr, got.Hint)
+	}
+	if got.Duration != 5*time.Minute {
+		t.Fatalf("Duration = %s, want 5m", got.Duration)
+	}
+}
+
+func TestStallWarningShownOnStreamingJobLine(t *testing.T) {
+	clock := newFakeClock()
+	done := make(chan struct{})
+	fake := &fakeExecutor{clock: clock, commands: map[string]fakeCommand{"make test": quietCommand(nil, done)}}
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	r := New(Options{
+		Root:              t.TempDir(),
+		Clock:             clock,
+		Executor:          fake,
+		Stdout:            stdout,
+		Stderr:            stderr,
+		Streaming:         true,
+		StreamingRenderer: output.NewPlainStreamingPretty(stdout),
+		StallWarningAfter: 2 * time.Minute,
+	})
+	results := runAsync(t, r, sampleWorkflow("make test"))
+
+	clock.waitForTimers(t, 1)
+	clock.Advance(2 * time.Minute)
+	clock.waitForTimers(t, 1)
+	close(done)
+	<-results
+
+	if !strings.Contains(stdout.String(), "⚠ no output for 2m00s in step\n") {
+		t.Fatalf("job line does not flag the quiet step:\n%s", stdout)
+	}
+	if stderr.Len() != 0 {
+		t.Fatalf("streaming runs should not warn on stderr, got %q", stderr)
+	}
+}
+
+func TestStallWarningFallsBackToStderr(t *testing.T) {
+	clock := newFakeClock()
+	done := make(chan struct{})
+	fake := &fakeExecutor{clock: clock, commands: map[string]fakeCommand{"make test": quietCommand(nil, done)}}
+	stderr := &bytes.Buffer{}
+	r := New(Options{
+		Root:              t.TempDir(),
+		Clock:             clock,
+		Executor:          fake,
+		Stderr:            stderr,
+		Streaming:         true,
+		StreamingRenderer: output.NewMultiRenderer(output.NewJSONStream(&bytes.Buffer{})),
+		StallWarningAfter: 2 * time.Minute,
+	})
+	results := runAsync(t, r, sampleWorkflow("make test"))
+
+	clock.waitForTimers(t, 1)
+	clock.Advance(2 * time.Minute)
+	clock.waitForTimers(t, 1)
+	close(done)
+	<-results
+
+	// A JSON stream has no place for the warning.
+	if want := "warning: workflow / job / step: no output for 2m00s\n"; stderr.String() != want {
+		t.Fatalf("stderr = %q, want %q", stderr, want)
+	}
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// StepSummaryEnv is the variable steps append markdown to, as on Actions.
+const StepSummaryEnv = "GITHUB_STEP_SUMMARY"
+
+// stepSummaryFile backs GITHUB_STEP_SUMMARY for a single job. A nil
+// *stepSummaryFile is valid and exports nothing, which keeps dry runs from
+// touching the filesystem.
+type stepSummaryFile struct {
+	path string
+	// redactors mask what the job's steps wrote, since any of them may
+	// have written its own secrets.
+	redactors []*redactor
+}
+
+// newStepSummaryFile allocates an empty summary file for the next job.
+func (r *Runner) newStepSummaryFile() (*stepSummaryFile, error) {
+	if r.opts.DryRun {
+		return nil, nil
+	}
+	f, err := os.CreateTemp(r.tempDir, "testdrive-step-summary-*.md")
+	if err != nil {
+		return nil, fmt.Errorf("create step summary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("create step summary file: %w", err)
+	}
+	return &stepSummaryFile{path: f.Name()}, nil
+}
+
+func (f *stepSummaryFile) env() map[string]string {
+	if f == nil {
+		return nil
+	}
+	return map[string]string{StepSummaryEnv: f.path}
+}
+
+// redact masks r's values in the contents.
+func (f *stepSummaryFile) redact(r *redactor) {
+	if f != nil && r != nil {
+		f.redactors = append(f.redactors, r)
+	}
+}
+
+// contents returns the markdown written by the job's steps, masked, or ""
+// when nothing but whitespace was written.
+func (f *stepSummaryFile) contents() string {
+	if f == nil {
+		return ""
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil || strings.TrimSpace(string(data)) == "" {
+		return ""
+	}
+	text := string(data)
+	for _, r := range f.redactors {
+		text = r.String(text)
+	}
+	return strings.TrimRight(text, "\n") + "\n"
+}
+
+func (f *stepSummaryFile) remove() {
+	if f != nil {
+		os.Remove(f.path)
+	}
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestRunnerCollectsStepSummary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell redirection")
+	}
+	wf := sampleWorkflow(`printf '| a | b |\n| - | - |\n| 1 | 2 |\n' >> "$GITHUB_STEP_SUMMARY"`)
+	wf.Jobs[0].Steps = append(wf.Jobs[0].Steps, provider.Step{Name: "path", Run: `echo "$GITHUB_STEP_SUMMARY" > path.txt`})
+	root := t.TempDir()
+
+	_, summary, err := New(Options{Root: root}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if summary.Failed != 0 || len(summary.Jobs) != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	want := "| a | b |\n| - | - |\n| 1 | 2 |\n"
+	if got := summary.Jobs[0].StepSummary; got != want {
+		t.Fatalf("StepSummary = %q, want %q", got, want)
+	}
+
+	path, err := os.ReadFile(filepath.Join(root, "path.txt"))
+	if err != nil {
+		t.Fatalf("read path.txt: %v", err)
+	}
+	if _, err := os.Stat(strings.TrimSpace(string(path))); !os.IsNotExist(err) {
+		t.Fatalf("expected summary file to be removed after the job, got %v", err)
+	}
+}
+
+func TestRunnerRemovesRunTempDirOnFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell redirection")
+	}
+	wf := sampleWorkflow(`echo "$GITHUB_STEP_SUMMARY" > path.txt; exit 1`)
+	root := t.TempDir()
+
+	_, summary, err := New(Options{Root: root}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	path, err := os.ReadFile(filepath.Join(root, "path.txt"))
+	if err != nil {
+		t.Fatalf("read path.txt: %v", err)
+	}
+	dir := filepath.Dir(strings.TrimSpace(string(path)))
+	if !strings.HasPrefix(filepath.Base(dir), "testdrive-run-") {
+		t.Fatalf("step summary file %s is not in a run temp directory", path)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected run temp directory to be removed, got %v", err)
+	}
+}
+
+func TestRunnerEmptyStepSummary(t *testing.T) {
+	_, summary, err := New(Options{Root: t.TempDir()}).Run(context.Background(), []provider.Workflow{sampleWorkflow("echo hi")})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	if got := summary.Jobs[0].StepSummary; got != "" {
+		t.Fatalf("expected no step summary, got %q", got)
+	}
+}
@@ -0,0 +1,173 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/report"
+)
+
+func teardownWorkflow(t *testing.T, steps ...provider.Step) provider.Workflow {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	return provider.Workflow{
+		Path: "wf.yml",
+		Name: "workflow",
+		Jobs: []provider.Job{{Name: "job", RawID: "job", Steps: steps}},
+	}
+}
+
+// statuses returns each result's step name and status, in report order.
+func statuses(results []report.StepResult) [][2]string {
+	out := make([][2]string, 0, len(results))
+	for _, res := range results {
+		out = append(out, [2]string{res.StepName, res.Status})
+	}
+	return out
+}
+
+func TestRunnerTeardownRunsLast(t *testing.T) {
+	wf := teardownWorkflow(t,
+		provider.Step{Name: "post: stop services", Run: "echo stop"},
+		provider.Step{Name: "Start services", Run: "echo start"},
+		provider.Step{Name: "Clean cache", Run: "echo clean", Teardown: true},
+		provider.Step{Name: "Test", Run: "echo test"},
+	)
+
+	results, summary, err := New(Options{Root: t.TempDir()}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	want := [][2]string{{"Start services", "passed"}, {"Test", "passed"}, {"post: stop services", "passed"}, {"Clean cache", "passed"}}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("results = %v, want %v", got, want)
+	}
+	if results[0].Teardown || !results[2].Teardown || !results[3].Teardown {
+		t.Fatalf("expected only the teardown steps to be flagged, got %+v", results)
+	}
+	if summary.Passed != 4 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestRunnerTeardownRunsAfterFailure(t *testing.T) {
+	wf := teardownWorkflow(t,
+		provider.Step{Name: "Start services", Run: "echo start"},
+		provider.Step{Name: "Test", Run: "exit 1"},
+		provider.Step{Name: "post: stop services", Run: "echo stop"},
+	)
+
+	results, summary, err := New(Options{Root: t.TempDir()}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	want := [][2]string{{"Start services", "passed"}, {"Test", "failed"}, {"post: stop services", "passed"}}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("results = %v, want %v", got, want)
+	}
+	if summary.Failed != 1 || summary.ExitCode != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestRunnerTeardownRunsAfterCancellation(t *testing.T) {
+	dir := t.TempDir()
+	started := filepath.Join(dir, "started")
+	stopped := filepath.Join(dir, "stopped")
+	wf := teardownWorkflow(t,
+		provider.Step{Name: "Start services", Run: "touch " + started + " && sleep 30"},
+		provider.Step{Name: "Test", Run: "echo test"},
+		provider.Step{Name: "post: stop services", Run: "touch " + stopped},
+	)
+
+	results, summary, err := New(Options{Root: dir}).Run(cancelWhenStarted(t, started), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	want := [][2]string{{"Start services", "skipped"}, {"Test", "skipped"}, {"post: stop services", "passed"}}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("results = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(stopped); err != nil {
+		t.Fatalf("expected the teardown step to run: %v", err)
+	}
+	if summary.Cancelled != 2 || summary.Passed != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestRunnerTeardownGracePeriod(t *testing.T) {
+	const grace = 30 * time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := newFakeClock()
+	fake := &fakeExecutor{clock: clock, commands: map[string]fakeCommand{
+		"start": {run: func(ctx context.Context, spec ExecSpec) (ExecResult, error) {
+			cancel()
+			<-ctx.Done()
+			return ExecResult{ExitCode: -1}, ctx.Err()
+		}},
+		"drain": {run: func(ctx context.Context, spec ExecSpec) (ExecResult, error) {
+			clock.waitForTimers(t, 1)
+			clock.Advance(grace - time.Second)
+			if ctx.Err() != nil {
+				t.Errorf("teardown cancelled before the grace period was over")
+			}
+			clock.Advance(time.Second)
+			<-ctx.Done()
+			return ExecResult{ExitCode: -1}, ctx.Err()
+		}},
+	}}
+	wf := provider.Workflow{Path: "wf.yml", Name: "workflow", Jobs: []provider.Job{{Name: "job", RawID: "job", Steps: []provider.Step{
+		{Name: "Start services", Run: "start"},
+		{Name: "post: drain", Run: "drain"},
+		{Name: "post: stop services", Run: "stop"},
+	}}}}
+
+	results, _, err := New(Options{Root: t.TempDir(), TeardownGrace: grace, Clock: clock, Executor: fake}).Run(ctx, []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	want := []string{
+		"cancelled: run interrupted while the step was running",
+		"cancelled: teardown grace period expired while the step was running",
+		"cancelled: teardown grace period expired",
+	}
+	for i, detail := range want {
+		if results[i].SkipReason != report.ReasonCancelled || results[i].SkipDetail != detail {
+			t.Fatalf("step %q: expected %q, got %+v", results[i].StepName, detail, results[i])
+		}
+	}
+	if got := fake.executed(); !reflect.DeepEqual(got, []string{"start", "drain"}) {
+		t.Fatalf("executed %v, want start and drain only", got)
+	}
+}
+
+func TestRunnerTeardownSkippedWhenNothingStarted(t *testing.T) {
+	wf := teardownWorkflow(t,
+		provider.Step{Name: "Start services", Run: "echo start"},
+		provider.Step{Name: "post: stop services", Run: "echo stop"},
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, _, err := New(Options{Root: t.TempDir()}).Run(ctx, []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	want := [][2]string{{"Start services", "skipped"}, {"post: stop services", "skipped"}}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("results = %v, want %v", got, want)
+	}
+	if results[1].SkipReason != report.ReasonCancelled || !results[1].Teardown {
+		t.Fatalf("expected the teardown step to be cancelled, got %+v", results[1])
+	}
+}
@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func TestRunnerExpectedFailures(t *testing.T) {
+	wf := teardownWorkflow(t,
+		provider.Step{Name: "Known broken", Run: "exit 1", ExpectedFailure: true},
+		provider.Step{Name: "Fixed", Run: "echo fixed", ExpectedFailure: true},
+		provider.Step{Name: "Build", Run: "echo build"},
+	)
+
+	results, summary, err := New(Options{Root: t.TempDir()}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	want := [][2]string{{"Known broken", "xfail"}, {"Fixed", "xpass"}, {"Build", "passed"}}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("results = %v, want %v", got, want)
+	}
+	if !results[0].ExpectedFailure || !results[1].ExpectedFailure || results[2].ExpectedFailure {
+		t.Fatalf("expected only the marked steps to be flagged, got %+v", results)
+	}
+	if results[0].ExitCode != 1 {
+		t.Fatalf("expected the expected failure to keep its exit code, got %d", results[0].ExitCode)
+	}
+	if summary.XFailed != 1 || summary.XPassed != 1 || summary.Passed != 1 || summary.Failed != 0 || summary.ExitCode != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if len(summary.Jobs) != 1 || summary.Jobs[0].Status != "passed" {
+		t.Fatalf("expected the job to pass, got %+v", summary.Jobs)
+	}
+}
+
+func TestRunnerUnmarkedFailureStillFails(t *testing.T) {
+	wf := teardownWorkflow(t,
+		provider.Step{Name: "Known broken", Run: "exit 1", ExpectedFailure: true},
+		provider.Step{Name: "Test", Run: "exit 2"},
+	)
+
+	results, summary, err := New(Options{Root: t.TempDir()}).Run(context.Background(), []provider.Workflow{wf})
+	if err != nil {
+		t.Fatalf("runner Run: %v", err)
+	}
+	want := [][2]string{{"Known broken", "xfail"}, {"Test", "failed"}}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("results = %v, want %v", got, want)
+	}
+	if summary.XFailed != 1 || summary.Failed != 1 || summary.ExitCode != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if summary.Jobs[0].Status != "failed" {
+		t.Fatalf("expected the job to fail, got %+v", summary.Jobs[0])
+	}
+}
@@ -0,0 +1,210 @@
+// Package shellaudit statically scans run scripts for constructs that the
+// shell running them will not understand, such as bashisms in a step whose
+// shell is sh. It is a handful of regular expressions, not a parser: it
+// catches common mistakes and can be wrong about unusual scripts, so every
+// rule can be turned off.
+package shellaudit
+
+import (
+	_ "embed"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/bgricker/testdrive/internal/provider"
+	"github.com/bgricker/testdrive/internal/resolve"
+	"gopkg.in/yaml.v3"
+)
+
+// Shells a rule can apply to.
+const (
+	// ShellSh is sh, dash, or ash: POSIX shells that are not bash.
+	ShellSh = "sh"
+	// ShellPOSIX is any Bourne-style shell, bash and the default included.
+	ShellPOSIX = "posix"
+	// ShellPython is python or python3.
+	ShellPython = "python"
+)
+
+// Shells returns every shell name a rule accepts.
+func Shells() []string {
+	return []string{ShellSh, ShellPOSIX, ShellPython}
+}
+
+// Rule flags script lines matching Pattern in steps run by Shell.
+type Rule struct {
+	ID      string `yaml:"id" json:"id"`
+	Shell   string `yaml:"shell" json:"shell"`
+	Pattern string `yaml:"pattern" json:"pattern"`
+	Message string `yaml:"message" json:"message"`
+
+	re *regexp.Regexp
+}
+
+//go:embed rules.yml
+var builtinData []byte
+
+// builtin is parsed once at startup; a bad rule is a bug in rules.yml,
+// which the package tests catch.
+var builtin = mustParse(builtinData)
+
+func mustParse(data []byte) []Rule {
+	var rules []Rule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		panic(fmt.Errorf("parse builtin shell audit rules: %w", err))
+	}
+	rules, err := compile(nil, rules)
+	if err != nil {
+		panic(fmt.Errorf("builtin shell audit rules: %w", err))
+	}
+	return rules
+}
+
+// compile checks each of rules and compiles its pattern, appending it to
+// base. IDs must be unique across both.
+func compile(base, rules []Rule) ([]Rule, error) {
+	out := slices.Clone(base)
+	for _, rule := range rules {
+		if rule.ID == "" {
+			return nil, fmt.Errorf("rule for pattern %q has no id", rule.Pattern)
+		}
+		if slices.ContainsFunc(out, func(r Rule) bool { return r.ID == rule.ID }) {
+			return nil, fmt.Errorf("rule %q is defined twice", rule.ID)
+		}
+		if !slices.Contains(Shells(), rule.Shell) {
+			return nil, fmt.Errorf("rule %q: unknown shell %q; use %s", rule.ID, rule.Shell, strings.Join(Shells(), ", "))
+		}
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("rule %q has no pattern", rule.ID)
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.ID, err)
+		}
+		rule.re = re
+		out = append(out, rule)
+	}
+	return out, nil
+}
+
+// Builtin returns a copy of the built-in rules.
+func Builtin() []Rule {
+	return slices.Clone(builtin)
+}
+
+// Effective returns the built-in rules followed by added, less those whose
+// IDs are in disabled. Added rules may not reuse an ID, and disabled must
+// name known rules.
+func Effective(added []Rule, disabled []string) ([]Rule, error) {
+	rules, err := compile(builtin, added)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range disabled {
+		if !slices.ContainsFunc(rules, func(r Rule) bool { return r.ID == id }) {
+			ids := make([]string, 0, len(rules))
+			for _, r := range rules {
+				ids = append(ids, r.ID)
+			}
+			return nil, fmt.Errorf("unknown rule %q; valid rules: %s", id, strings.Join(ids, ", "))
+		}
+	}
+	return slices.DeleteFunc(rules, func(r Rule) bool { return slices.Contains(disabled, r.ID) }), nil
+}
+
+// Finding is one script line a rule flagged. Line counts from one at the
+// first line of the step's run script.
+type Finding struct {
+	Workflow string `json:"workflow"`
+	Job      string `json:"job"`
+	Step     string `json:"step"`
+	Shell    string `json:"shell"`
+	Line     int    `json:"line"`
+	Text     string `json:"text"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// String describes the finding without its workflow and job.
+func (f Finding) String() string {
+	return fmt.Sprintf("step %q line %d: %s (%s): %s", f.Step, f.Line, f.Message, f.Rule, f.Text)
+}
+
+// expression matches ${{ }} expressions, which are resolved before the
+// shell sees the script.
+var expression = regexp.MustCompile(`\$\{\{.*?\}\}`)
+
+// Audit checks the run script of every step in workflows against rules, in
+// workflow order. Skipped steps are left out, and each rule reports a line
+// at most once.
+func Audit(workflows []provider.Workflow, rules []Rule) []Finding {
+	var findings []Finding
+	for _, wf := range workflows {
+		for _, job := range wf.Jobs {
+			for _, step := range job.Steps {
+				if step.Run == "" || step.Skip {
+					continue
+				}
+				shell, _ := resolve.Shell(wf, job, step)
+				shells := shellClasses(shell)
+				if len(shells) == 0 {
+					continue
+				}
+				for i, line := range strings.Split(step.Run, "\n") {
+					text := strings.TrimSpace(line)
+					if text == "" || strings.HasPrefix(text, "#") {
+						continue
+					}
+					stripped := expression.ReplaceAllString(text, "")
+					for _, rule := range rules {
+						if !slices.Contains(shells, rule.Shell) || !rule.re.MatchString(stripped) {
+							continue
+						}
+						findings = append(findings, Finding{
+							Workflow: wf.Path,
+							Job:      job.RawID,
+							Step:     step.Name,
+							Shell:    shellName(shell),
+							Line:     i + 1,
+							Text:     text,
+							Rule:     rule.ID,
+							Message:  rule.Message,
+						})
+					}
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// shellClasses returns the rule shells that apply to a step whose shell
+// spec is spec. An empty spec is the default shell: bash, or cmd on
+// Windows.
+func shellClasses(spec string) []string {
+	switch shellName(spec) {
+	case "sh", "dash", "ash":
+		return []string{ShellSh, ShellPOSIX}
+	case "bash", "zsh", "ksh":
+		return []string{ShellPOSIX}
+	case "python", "python3":
+		return []string{ShellPython}
+	}
+	return nil
+}
+
+// shellName returns the program spec runs, lowercased and without an .exe
+// suffix.
+func shellName(spec string) string {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		if runtime.GOOS == "windows" {
+			return "cmd"
+		}
+		return "bash"
+	}
+	return strings.TrimSuffix(strings.ToLower(filepath.Base(fields[0])), ".exe")
+}
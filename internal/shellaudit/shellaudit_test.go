@@ -0,0 +1,140 @@
+package shellaudit
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bgricker/testdrive/internal/provider"
+)
+
+func auditScript(t *testing.T, shell, script string) []string {
+	t.Helper()
+	workflows := []provider.Workflow{{
+		Path: "ci.yml",
+		Jobs: []provider.Job{{RawID: "test", Steps: []provider.Step{{Name: "step", Shell: shell, Run: script}}}},
+	}}
+	var rules []string
+	for _, f := range Audit(workflows, Builtin()) {
+		rules = append(rules, f.Rule)
+	}
+	return rules
+}
+
+func TestBuiltinRules(t *testing.T) {
+	for _, tc := range []struct {
+		rule, shell string
+		hits        []string
+		misses      []string
+	}{
+		{"double-brackets", "sh", []string{`if [[ -n "$X" ]]; then`}, []string{`if [ -n "$X" ]; then`}},
+		{"test-double-equals", "sh", []string{`[ "$A" == "b" ] && exit 1`}, []string{`[ "$A" = "b" ]`, `[[ $A == b ]]`}},
+		{"arrays", "sh", []string{`files=(a b c)`, `echo "${files[0]}"`, `declare -a files`}, []string{`out=$(ls)`, `f() (cd x)`}},
+		{"pattern-substitution", "sh", []string{`echo "${ref//\//-}"`, `echo ${name^^}`, `echo ${name,,}`}, []string{`echo "${ref%/*}"`, `echo ${#ref}`}},
+		{"substring", "sh", []string{`echo ${sha:0:7}`, `echo ${sha: -3}`}, []string{`echo ${sha:-unknown}`, `echo ${n:-0}`}},
+		{"function-keyword", "sh", []string{`function build {`}, []string{`build() {`, `echo function x`}},
+		{"source", "sh", []string{`source .env`}, []string{`. ./.env`, `echo source x`}},
+		{"process-substitution", "sh", []string{`diff <(sort a) <(sort b)`}, []string{`sort a > out`, `echo $(( 1 + 2 ))`}},
+		{"here-string", "sh", []string{`read x <<< "$line"`}, []string{`cat <<EOF`}},
+		{"ampersand-redirect", "sh", []string{`make &> build.log`}, []string{`make > build.log 2>&1`, `a && b`}},
+		{"arithmetic-command", "sh", []string{`(( count++ ))`}, []string{`count=$((count + 1))`}},
+		{"pipefail", "sh", []string{`set -euo pipefail`, `set -o pipefail`}, []string{`set -eu`}},
+		{"echo-escapes", "sh", []string{`echo -e "a\tb"`, `echo -ne "x"`}, []string{`echo -n x`, `printf 'a\tb\n'`}},
+		{"unquoted-glob-var", "bash", []string{`rm -rf $BUILD_DIR/*`, `cp ${SRC}*.log out/`, `ls $dir/*.txt`}, []string{`rm -rf "$BUILD_DIR"/*`, `echo $HOME`, `ls *.txt`}},
+		{"shell-in-python", "python", []string{`export FOO=1`, `echo hi`, `fi`, `set -e`}, []string{`import os`, `print("echo hi")`, `if x:`}},
+	} {
+		t.Run(tc.rule, func(t *testing.T) {
+			for _, line := range tc.hits {
+				if got := auditScript(t, tc.shell, line); !slices.Contains(got, tc.rule) {
+					t.Errorf("%q under %s: got rules %v, want %s", line, tc.shell, got, tc.rule)
+				}
+			}
+			for _, line := range tc.misses {
+				if got := auditScript(t, tc.shell, line); slices.Contains(got, tc.rule) {
+					t.Errorf("%q under %s: %s should not match", line, tc.shell, tc.rule)
+				}
+			}
+		})
+	}
+}
+
+func TestAuditFollowsShell(t *testing.T) {
+	script := "# [[ in a comment ]]\nif [[ -n \"${{ inputs.x }}\" ]]; then\n  source .env\nfi\n"
+	if got := auditScript(t, "", script); len(got) != 0 {
+		t.Fatalf("the default shell is bash, got %v", got)
+	}
+	if got := auditScript(t, "bash -e {0}", script); len(got) != 0 {
+		t.Fatalf("bash runs bashisms, got %v", got)
+	}
+	if got, want := auditScript(t, "sh", script), []string{"double-brackets", "source"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sh findings = %v, want %v", got, want)
+	}
+	if got, want := auditScript(t, "/usr/bin/dash -e {0}", script), []string{"double-brackets", "source"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("dash findings = %v, want %v", got, want)
+	}
+	if got := auditScript(t, "pwsh", script); len(got) != 0 {
+		t.Fatalf("pwsh scripts are not audited, got %v", got)
+	}
+}
+
+func TestAuditFindings(t *testing.T) {
+	workflows := []provider.Workflow{{
+		Path:     "ci.yml",
+		Defaults: provider.Defaults{RunShell: "sh"},
+		Jobs: []provider.Job{{
+			RawID: "test",
+			Steps: []provider.Step{
+				{Name: "Skipped", Run: "source .env", Skip: true},
+				{Name: "Build", Run: "make\nsource .env"},
+			},
+		}},
+	}}
+	got := Audit(workflows, Builtin())
+	want := []Finding{{Workflow: "ci.yml", Job: "test", Step: "Build", Shell: "sh", Line: 2, Text: "source .env", Rule: "source", Message: "source is a bash builtin; sh has only ."}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Audit = %+v, want %+v", got, want)
+	}
+	if s := got[0].String(); s != `step "Build" line 2: source is a bash builtin; sh has only . (source): source .env` {
+		t.Fatalf("String = %q", s)
+	}
+}
+
+func TestEffective(t *testing.T) {
+	rules, err := Effective([]Rule{{ID: "no-curl-pipe", Shell: "posix", Pattern: `curl .*\|\s*sh`, Message: "pipes a download into a shell"}}, []string{"source", "double-brackets"})
+	if err != nil {
+		t.Fatalf("Effective: %v", err)
+	}
+	ids := ruleIDs(rules)
+	if slices.Contains(ids, "source") || slices.Contains(ids, "double-brackets") || !slices.Contains(ids, "no-curl-pipe") || !slices.Contains(ids, "here-string") {
+		t.Fatalf("unexpected rules: %v", ids)
+	}
+	if len(Builtin()) != len(builtin) || !slices.Contains(ruleIDs(Builtin()), "source") {
+		t.Fatalf("Effective changed the built-in rules")
+	}
+
+	for _, tc := range []struct {
+		added    []Rule
+		disabled []string
+		want     string
+	}{
+		{disabled: []string{"sourcing"}, want: `unknown rule "sourcing"`},
+		{added: []Rule{{Shell: "sh", Pattern: "x"}}, want: "has no id"},
+		{added: []Rule{{ID: "source", Shell: "sh", Pattern: "x"}}, want: `rule "source" is defined twice`},
+		{added: []Rule{{ID: "x", Shell: "fish", Pattern: "x"}}, want: `unknown shell "fish"`},
+		{added: []Rule{{ID: "x", Shell: "sh"}}, want: "has no pattern"},
+		{added: []Rule{{ID: "x", Shell: "sh", Pattern: "("}}, want: `rule "x": error parsing regexp`},
+	} {
+		if _, err := Effective(tc.added, tc.disabled); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Effective(%+v, %v) error = %v, want %q", tc.added, tc.disabled, err, tc.want)
+		}
+	}
+}
+
+func ruleIDs(rules []Rule) []string {
+	ids := make([]string, 0, len(rules))
+	for _, r := range rules {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
@@ -0,0 +1,127 @@
+// Package suggest finds the names a mistyped filter or path was most likely
+// meant to be.
+package suggest
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxSuggestions is how many suggestions Closest returns.
+const maxSuggestions = 3
+
+// Closest returns up to three of names that input is within a few typos of,
+// closest first. Inputs are compared case-insensitively against any part of
+// each name, since filters match substrings: "tset" suggests
+// "integration-test". A name counts when the typos needed are no more than
+// a third of input's length, and at least one is always allowed.
+func Closest(input string, names []string) []string {
+	input = strings.ToLower(strings.TrimSpace(input))
+	if input == "" {
+		return nil
+	}
+	limit := len([]rune(input)) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	type candidate struct {
+		name        string
+		part, whole int
+	}
+	var found []candidate
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		lower := strings.ToLower(name)
+		part := SubstringDistance(input, lower)
+		if part > limit {
+			continue
+		}
+		found = append(found, candidate{name: name, part: part, whole: Distance(input, lower)})
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.part != b.part {
+			return a.part < b.part
+		}
+		if a.whole != b.whole {
+			return a.whole < b.whole
+		}
+		return a.name < b.name
+	})
+	if len(found) > maxSuggestions {
+		found = found[:maxSuggestions]
+	}
+	out := make([]string, len(found))
+	for i, c := range found {
+		out[i] = c.name
+	}
+	return out
+}
+
+// Hint returns "; did you mean "a" or "b"?" naming the names Closest finds
+// for input, or "" when none is close. It is meant to end an error message.
+func Hint(input string, names []string) string {
+	closest := Closest(input, names)
+	if len(closest) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(closest))
+	for i, name := range closest {
+		quoted[i] = strconv.Quote(name)
+	}
+	return "; did you mean " + strings.Join(quoted, " or ") + "?"
+}
+
+// Distance returns how many single-character insertions, deletions,
+// substitutions, and swaps of neighbours turn a into b.
+func Distance(a, b string) int {
+	return distance([]rune(a), []rune(b), false)
+}
+
+// SubstringDistance returns the fewest edits, counted as Distance does,
+// that turn a into some part of b.
+func SubstringDistance(a, b string) int {
+	return distance([]rune(a), []rune(b), true)
+}
+
+// distance is the optimal string alignment distance between a and b. With
+// part set, b may start and end anywhere at no cost.
+func distance(a, b []rune, part bool) int {
+	// rows[i][j] is the distance between a[:i] and b[:j].
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := 1; j <= len(b); j++ {
+		if !part {
+			rows[0][j] = j
+		}
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d := min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d = min(d, rows[i-2][j-2]+1)
+			}
+			rows[i][j] = d
+		}
+	}
+	if !part {
+		return rows[len(a)][len(b)]
+	}
+	best := rows[len(a)][0]
+	for _, d := range rows[len(a)] {
+		best = min(best, d)
+	}
+	return best
+}
@@ -0,0 +1,49 @@
+package suggest
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	cases := []struct {
+		a, b       string
+		whole, sub int
+	}{
+		{"test", "test", 0, 0},
+		{"tset", "test", 1, 1},
+		{"tset", "integration-test", 13, 1},
+		{"lnit", "lint", 1, 1},
+		{"buld", "build", 1, 1},
+		{"deploy", "lint", 6, 5},
+		{"", "abc", 3, 0},
+	}
+	for _, tc := range cases {
+		if got := Distance(tc.a, tc.b); got != tc.whole {
+			t.Errorf("Distance(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.whole)
+		}
+		if got := SubstringDistance(tc.a, tc.b); got != tc.sub {
+			t.Errorf("SubstringDistance(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.sub)
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	names := []string{"build", "lint", "test", "integration-test", "deploy"}
+	cases := []struct {
+		input string
+		want  []string
+	}{
+		{"tset", []string{"test", "integration-test"}},
+		{"TEST", []string{"test", "integration-test"}},
+		{"lnit", []string{"lint"}},
+		{"integraton", []string{"integration-test"}},
+		{"zzz", nil},
+		{"", nil},
+	}
+	for _, tc := range cases {
+		if got := Closest(tc.input, names); !slices.Equal(got, tc.want) {
+			t.Errorf("Closest(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
@@ -15,35 +15,137 @@ type Info struct {
 	Version string
 }
 
-var (
-	rubyRegex = regexp.MustCompile(`(?i)ruby\s+(\d+\.\d+(?:\.\d+)?)`)
-	nodeRegex = regexp.MustCompile(`(?i)v?(\d+\.\d+(?:\.\d+)?)`)
+// Detector reports the installed version of a named tool.
+type Detector interface {
+	Detect(tool string) (Info, error)
+}
+
+// CommandRunner executes a command and returns its trimmed combined output.
+type CommandRunner func(name string, args ...string) (string, error)
+
+// Tool describes how to probe a tool's version: run Command with Args and
+// take the first capture group of Pattern from the output. Alternates are
+// tried in order when Command is not installed.
+type Tool struct {
+	Name       string
+	Command    string
+	Alternates []string
+	Args       []string
+	Pattern    *regexp.Regexp
+	// Compare reports whether an installed version satisfies a required one.
+	// Nil compares major.minor.
+	Compare func(required, actual string) bool
+}
+
+// Tool names understood by the built-in detectors.
+const (
+	ToolRuby   = "ruby"
+	ToolNode   = "node"
+	ToolPython = "python"
+	ToolJava   = "java"
 )
 
-// DetectRuby returns the system Ruby version by calling `ruby -v`.
-func DetectRuby() (Info, error) {
-	out, err := runCommand("ruby", "-v")
-	if err != nil {
-		return Info{}, err
+// tools lists every supported tool. Adding a tool only needs a new entry.
+var tools = []Tool{
+	{
+		Name:    ToolRuby,
+		Command: "ruby",
+		Args:    []string{"-v"},
+		Pattern: regexp.MustCompile(`(?i)ruby\s+(\d+\.\d+(?:\.\d+)?(?:-?[a-z][0-9a-z.]*)?)`),
+	},
+	{
+		Name:    ToolNode,
+		Command: "node",
+		Args:    []string{"-v"},
+		Pattern: regexp.MustCompile(`(?i)^v?(\d+\.\d+(?:\.\d+)?(?:-[0-9a-z.]+)?)`),
+	},
+	{
+		Name:       ToolPython,
+		Command:    "python3",
+		Alternates: []string{"python"},
+		Args:       []string{"--version"},
+		Pattern:    regexp.MustCompile(`(?i)python\s+(\d+\.\d+(?:\.\d+)?(?:[a-z]+\d*)?)`),
+	},
+	{
+		// java -version writes `openjdk version "17.0.8" 2023-07-18` to stderr.
+		Name:    ToolJava,
+		Command: "java",
+		Args:    []string{"-version"},
+		Pattern: regexp.MustCompile(`(?i)version\s+"(\d+(?:\.\d+)*(?:[-_+][0-9a-z.]+)?)"`),
+		Compare: CompareMajor,
+	},
+}
+
+// Tools returns the names of all supported tools.
+func Tools() []string {
+	names := make([]string, 0, len(tools))
+	for _, t := range tools {
+		names = append(names, t.Name)
 	}
-	match := rubyRegex.FindStringSubmatch(out)
-	if len(match) < 2 {
-		return Info{}, fmt.Errorf("unable to parse ruby version from %q", out)
+	return names
+}
+
+func lookupTool(name string) (Tool, bool) {
+	for _, t := range tools {
+		if t.Name == name {
+			return t, true
+		}
 	}
-	return Info{Name: "ruby", Version: match[1]}, nil
+	return Tool{}, false
 }
 
-// DetectNode returns the system Node.js version by calling `node -v`.
-func DetectNode() (Info, error) {
-	out, err := runCommand("node", "-v")
+// ExecDetector probes tools by running their version commands.
+type ExecDetector struct {
+	run CommandRunner
+}
+
+// NewExecDetector returns a detector that runs commands with run. A nil run
+// executes commands directly with os/exec.
+func NewExecDetector(run CommandRunner) *ExecDetector {
+	if run == nil {
+		run = runCommand
+	}
+	return &ExecDetector{run: run}
+}
+
+// Detect runs the version command for tool and parses its output.
+func (d *ExecDetector) Detect(name string) (Info, error) {
+	tool, ok := lookupTool(name)
+	if !ok {
+		return Info{}, fmt.Errorf("unknown tool %q", name)
+	}
+	out, err := d.run(tool.Command, tool.Args...)
+	for _, alt := range tool.Alternates {
+		if !Missing(err) {
+			break
+		}
+		out, err = d.run(alt, tool.Args...)
+	}
 	if err != nil {
 		return Info{}, err
 	}
-	match := nodeRegex.FindStringSubmatch(out)
+	match := tool.Pattern.FindStringSubmatch(out)
 	if len(match) < 2 {
-		return Info{}, fmt.Errorf("unable to parse node version from %q", out)
+		return Info{}, fmt.Errorf("unable to parse %s version from %q", tool.Name, out)
 	}
-	return Info{Name: "node", Version: match[1]}, nil
+	return Info{Name: tool.Name, Version: match[1]}, nil
+}
+
+var defaultDetector = NewExecDetector(nil)
+
+// Detect returns the installed version of tool using the system commands.
+func Detect(tool string) (Info, error) {
+	return defaultDetector.Detect(tool)
+}
+
+// DetectRuby returns the system Ruby version by calling `ruby -v`.
+func DetectRuby() (Info, error) {
+	return Detect(ToolRuby)
+}
+
+// DetectNode returns the system Node.js version by calling `node -v`.
+func DetectNode() (Info, error) {
+	return Detect(ToolNode)
 }
 
 func runCommand(name string, args ...string) (string, error) {
@@ -58,6 +160,33 @@ func runCommand(name string, args ...string) (string, error) {
 	return strings.TrimSpace(buf.String()), nil
 }
 
+// Matches reports whether actual satisfies required using the comparison
+// configured for tool.
+func Matches(tool, required, actual string) bool {
+	if t, ok := lookupTool(tool); ok && t.Compare != nil {
+		return t.Compare(required, actual)
+	}
+	return CompareMajorMinor(required, actual)
+}
+
+// CompareMajor compares only the major version, treating legacy Java
+// "1.x" versions as major x so 1.8.0_392 matches 8.
+func CompareMajor(desired, actual string) bool {
+	d := majorVersion(desired)
+	a := majorVersion(actual)
+	return d != "" && d == a
+}
+
+var leadingDigits = regexp.MustCompile(`^\d+`)
+
+func majorVersion(version string) string {
+	parts := strings.Split(strings.TrimSpace(version), ".")
+	if len(parts) > 1 && parts[0] == "1" {
+		parts = parts[1:]
+	}
+	return leadingDigits.FindString(parts[0])
+}
+
 // CompareMajorMinor compares major.minor portions of two semver-like versions.
 func CompareMajorMinor(desired, actual string) bool {
 	d := semverPrefix(desired)
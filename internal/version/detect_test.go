@@ -1,6 +1,11 @@
 package version
 
-import "testing"
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
 
 func TestSemverPrefix(t *testing.T) {
 	cases := []struct {
@@ -37,3 +42,192 @@ func TestCompareMajorMinor(t *testing.T) {
 		}
 	}
 }
+
+// scriptedRunner answers each command with canned output or an error and
+// records the arguments it was called with.
+type scriptedRunner map[string]struct {
+	out string
+	err error
+}
+
+func (s scriptedRunner) run(name string, args ...string) (string, error) {
+	resp, ok := s[name+" "+strings.Join(args, " ")]
+	if !ok {
+		return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	return resp.out, resp.err
+}
+
+func TestExecDetectorDetect(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+	runner := scriptedRunner{
+		"ruby -v": {out: "ruby 3.2.2 (2023-03-30 revision e51014f9c0) [x86_64-linux]"},
+		"node -v": {out: "v20.11.1"},
+	}
+	cases := []struct {
+		name    string
+		runner  scriptedRunner
+		tool    string
+		want    string
+		wantErr string
+		missing bool
+	}{
+		{name: "ruby", runner: runner, tool: ToolRuby, want: "3.2.2"},
+		{name: "node", runner: runner, tool: ToolNode, want: "20.11.1"},
+		{name: "ruby two part", runner: scriptedRunner{"ruby -v": {out: "ruby 2.7p83"}}, tool: ToolRuby, want: "2.7p83"},
+		{name: "ruby preview", runner: scriptedRunner{"ruby -v": {out: "ruby 3.4.0preview1 (2024-05-16 master 9d69619623) [arm64-darwin23]"}}, tool: ToolRuby, want: "3.4.0preview1"},
+		{name: "ruby dev", runner: scriptedRunner{"ruby -v": {out: "ruby 3.3.0dev (2023-11-01T00:00:00Z master) [x86_64-linux]"}}, tool: ToolRuby, want: "3.3.0dev"},
+		{name: "node release candidate", runner: scriptedRunner{"node -v": {out: "v21.0.0-rc.1"}}, tool: ToolNode, want: "21.0.0-rc.1"},
+		{name: "node nightly", runner: scriptedRunner{"node -v": {out: "v22.0.0-nightly20240101abc"}}, tool: ToolNode, want: "22.0.0-nightly20240101abc"},
+		{name: "not found", runner: scriptedRunner{}, tool: ToolRuby, wantErr: "executable file not found", missing: true},
+		{name: "command fails", runner: scriptedRunner{"node -v": {err: exitErr}}, tool: ToolNode, wantErr: "exit status 1"},
+		{name: "garbage ruby output", runner: scriptedRunner{"ruby -v": {out: "rbenv: version `3.9.9' is not installed"}}, tool: ToolRuby, wantErr: "unable to parse ruby version"},
+		{name: "garbage node output", runner: scriptedRunner{"node -v": {out: "Segmentation fault"}}, tool: ToolNode, wantErr: "unable to parse node version"},
+		{name: "empty output", runner: scriptedRunner{"node -v": {out: ""}}, tool: ToolNode, wantErr: "unable to parse node version"},
+		{name: "unknown tool", runner: runner, tool: "cobol", wantErr: `unknown tool "cobol"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := NewExecDetector(tc.runner.run).Detect(tc.tool)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				if Missing(err) != tc.missing {
+					t.Fatalf("Missing(%v) = %v, want %v", err, Missing(err), tc.missing)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Detect: %v", err)
+			}
+			if info.Name != tc.tool || info.Version != tc.want {
+				t.Fatalf("Detect(%q) = %+v, want version %q", tc.tool, info, tc.want)
+			}
+		})
+	}
+}
+
+func TestToolsTable(t *testing.T) {
+	names := Tools()
+	if len(names) != len(tools) {
+		t.Fatalf("expected %d tools, got %v", len(tools), names)
+	}
+	for _, tool := range tools {
+		if tool.Command == "" || tool.Pattern == nil || tool.Pattern.NumSubexp() < 1 {
+			t.Fatalf("tool %q must define a command and a capturing pattern", tool.Name)
+		}
+	}
+}
+
+func TestPrereleaseComparesMajorMinor(t *testing.T) {
+	if !CompareMajorMinor("3.4", "3.4.0preview1") {
+		t.Fatalf("expected preview build to match its major.minor")
+	}
+	if !CompareMajorMinor("21.0.0", "21.0.0-rc.1") {
+		t.Fatalf("expected release candidate to match its major.minor")
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	out, err := runCommand("go", "env", "GOROOT")
+	if err != nil {
+		t.Fatalf("runCommand: %v", err)
+	}
+	if out == "" || strings.HasSuffix(out, "\n") {
+		t.Fatalf("expected trimmed output, got %q", out)
+	}
+	if _, err := runCommand("testdrive-no-such-command"); !Missing(err) {
+		t.Fatalf("expected not-found error, got %v", err)
+	}
+}
+
+func TestExecDetectorPythonAndJava(t *testing.T) {
+	cases := []struct {
+		name   string
+		runner scriptedRunner
+		tool   string
+		want   string
+	}{
+		{
+			name:   "python3",
+			runner: scriptedRunner{"python3 --version": {out: "Python 3.11.4"}},
+			tool:   ToolPython,
+			want:   "3.11.4",
+		},
+		{
+			name:   "python fallback",
+			runner: scriptedRunner{"python --version": {out: "Python 3.9.18"}},
+			tool:   ToolPython,
+			want:   "3.9.18",
+		},
+		{
+			name:   "python release candidate",
+			runner: scriptedRunner{"python3 --version": {out: "Python 3.13.0rc1"}},
+			tool:   ToolPython,
+			want:   "3.13.0rc1",
+		},
+		{
+			name: "openjdk",
+			runner: scriptedRunner{"java -version": {out: `openjdk version "17.0.8" 2023-07-18
+OpenJDK Runtime Environment Temurin-17.0.8+7 (build 17.0.8+7)
+OpenJDK 64-Bit Server VM Temurin-17.0.8+7 (build 17.0.8+7, mixed mode, sharing)`}},
+			tool: ToolJava,
+			want: "17.0.8",
+		},
+		{
+			name:   "legacy java",
+			runner: scriptedRunner{"java -version": {out: `java version "1.8.0_392"`}},
+			tool:   ToolJava,
+			want:   "1.8.0_392",
+		},
+		{
+			name:   "early access java",
+			runner: scriptedRunner{"java -version": {out: `openjdk version "22-ea" 2024-03-19`}},
+			tool:   ToolJava,
+			want:   "22-ea",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := NewExecDetector(tc.runner.run).Detect(tc.tool)
+			if err != nil {
+				t.Fatalf("Detect: %v", err)
+			}
+			if info.Version != tc.want {
+				t.Fatalf("Detect(%q) version = %q, want %q", tc.tool, info.Version, tc.want)
+			}
+		})
+	}
+
+	if _, err := NewExecDetector(scriptedRunner{}.run).Detect(ToolPython); !Missing(err) {
+		t.Fatalf("expected not-found when neither python3 nor python exist, got %v", err)
+	}
+	failing := scriptedRunner{"python3 --version": {err: errors.New("exit status 1")}, "python --version": {out: "Python 2.7.18"}}
+	if _, err := NewExecDetector(failing.run).Detect(ToolPython); err == nil || Missing(err) {
+		t.Fatalf("expected python3 failure to be reported without falling back, got %v", err)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	cases := []struct {
+		tool, required, actual string
+		want                   bool
+	}{
+		{ToolJava, "17", "17.0.8", true},
+		{ToolJava, "17.0.8-tem", "17.0.2", true},
+		{ToolJava, "17", "21.0.1", false},
+		{ToolJava, "8", "1.8.0_392", true},
+		{ToolJava, "1.8", "8.0.392", true},
+		{ToolJava, "22", "22-ea", true},
+		{ToolPython, "3.11.4", "3.11.9", true},
+		{ToolPython, "3.11", "3.12.0", false},
+		{ToolRuby, "3.2.2", "3.2.0", true},
+		{"unknown", "1.2", "1.2.3", true},
+	}
+	for _, tc := range cases {
+		if got := Matches(tc.tool, tc.required, tc.actual); got != tc.want {
+			t.Fatalf("Matches(%q, %q, %q) = %v, want %v", tc.tool, tc.required, tc.actual, got, tc.want)
+		}
+	}
+}
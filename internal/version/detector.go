@@ -0,0 +1,54 @@
+package version
+
+import "sync"
+
+// CachedDetector memoizes another Detector so each tool is probed at most
+// once for the lifetime of the cache. It is safe for concurrent use.
+type CachedDetector struct {
+	inner Detector
+
+	mu     sync.Mutex
+	probes map[string]*probe
+}
+
+type probe struct {
+	once sync.Once
+	info Info
+	err  error
+}
+
+// NewCachedDetector wraps inner with a per-tool cache.
+func NewCachedDetector(inner Detector) *CachedDetector {
+	return &CachedDetector{inner: inner, probes: make(map[string]*probe)}
+}
+
+// Detect returns the installed version of tool, probing it on first use.
+// Errors are cached along with successful results.
+func (d *CachedDetector) Detect(tool string) (Info, error) {
+	d.mu.Lock()
+	p, ok := d.probes[tool]
+	if !ok {
+		p = &probe{}
+		d.probes[tool] = p
+	}
+	d.mu.Unlock()
+
+	p.once.Do(func() {
+		p.info, p.err = d.inner.Detect(tool)
+	})
+	return p.info, p.err
+}
+
+// Prefetch probes tools concurrently. With a CachedDetector this warms the
+// cache so later Detect calls return immediately; results are discarded.
+func Prefetch(d Detector, tools ...string) {
+	var wg sync.WaitGroup
+	for _, tool := range tools {
+		wg.Add(1)
+		go func(tool string) {
+			defer wg.Done()
+			_, _ = d.Detect(tool)
+		}(tool)
+	}
+	wg.Wait()
+}
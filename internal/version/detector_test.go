@@ -0,0 +1,114 @@
+package version
+
+import (
+	"os/exec"
+	"sync"
+	"testing"
+)
+
+// countingRunner is a fake CommandRunner that returns canned output and
+// records how often each command ran.
+type countingRunner struct {
+	mu     sync.Mutex
+	calls  map[string]int
+	output map[string]string
+	errs   map[string]error
+}
+
+func newCountingRunner() *countingRunner {
+	return &countingRunner{
+		calls: make(map[string]int),
+		output: map[string]string{
+			"ruby": "ruby 3.2.2 (2023-03-30 revision e51014f9c0) [arm64-darwin22]",
+			"node": "v20.11.1",
+		},
+		errs: make(map[string]error),
+	}
+}
+
+func (c *countingRunner) run(name string, args ...string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[name]++
+	if err := c.errs[name]; err != nil {
+		return "", err
+	}
+	return c.output[name], nil
+}
+
+func (c *countingRunner) count(name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[name]
+}
+
+func TestCachedDetectorMemoizes(t *testing.T) {
+	fake := newCountingRunner()
+	d := NewCachedDetector(NewExecDetector(fake.run))
+
+	for i := 0; i < 3; i++ {
+		info, err := d.Detect(ToolRuby)
+		if err != nil {
+			t.Fatalf("Detect ruby: %v", err)
+		}
+		if info.Version != "3.2.2" {
+			t.Fatalf("unexpected ruby version %q", info.Version)
+		}
+	}
+	if got := fake.count("ruby"); got != 1 {
+		t.Fatalf("expected ruby to be probed once, got %d", got)
+	}
+	if got := fake.count("node"); got != 0 {
+		t.Fatalf("expected node not to be probed, got %d", got)
+	}
+}
+
+func TestCachedDetectorCachesErrors(t *testing.T) {
+	fake := newCountingRunner()
+	fake.errs["node"] = &exec.Error{Name: "node", Err: exec.ErrNotFound}
+	d := NewCachedDetector(NewExecDetector(fake.run))
+
+	for i := 0; i < 2; i++ {
+		if _, err := d.Detect(ToolNode); !Missing(err) {
+			t.Fatalf("expected not-found error, got %v", err)
+		}
+	}
+	if got := fake.count("node"); got != 1 {
+		t.Fatalf("expected failed probe to be cached, got %d calls", got)
+	}
+}
+
+func TestCachedDetectorConcurrentProbes(t *testing.T) {
+	fake := newCountingRunner()
+	d := NewCachedDetector(NewExecDetector(fake.run))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Prefetch(d, ToolRuby, ToolNode)
+			if _, err := d.Detect(ToolNode); err != nil {
+				t.Errorf("Detect node: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, tool := range []string{"ruby", "node"} {
+		if got := fake.count(tool); got != 1 {
+			t.Fatalf("expected %s to be probed once, got %d", tool, got)
+		}
+	}
+}
+
+func TestCachedDetectorUnknownTool(t *testing.T) {
+	fake := newCountingRunner()
+	d := NewCachedDetector(NewExecDetector(fake.run))
+	if _, err := d.Detect("cobol"); err == nil {
+		t.Fatalf("expected error for unknown tool")
+	}
+	if len(fake.calls) != 0 {
+		t.Fatalf("expected no probes, got %v", fake.calls)
+	}
+}
@@ -0,0 +1,128 @@
+package version
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Requirement is a tool version pinned by a file in the project.
+type Requirement struct {
+	Tool    string
+	Version string
+	// Source is the file the version was read from, relative to the root.
+	Source string
+}
+
+// pinFiles lists the version files read for each tool. Earlier files take
+// precedence when several pin the same tool.
+var pinFiles = []struct {
+	file  string
+	tool  string
+	parse func(contents string) string
+}{
+	{".ruby-version", ToolRuby, firstLine},
+	{".node-version", ToolNode, firstLine},
+	{".python-version", ToolPython, firstLine},
+	{"runtime.txt", ToolPython, parseRuntimeTxt},
+	{".java-version", ToolJava, firstLine},
+	{".sdkmanrc", ToolJava, parseSdkmanrc},
+}
+
+// Requirements reads the version pin files in root, returning at most one
+// requirement per tool in a stable order.
+func Requirements(root string) []Requirement {
+	return RequirementsIn(root, root)
+}
+
+// RequirementsIn reads the version pin files that apply to commands run in
+// dir. Like rbenv, pyenv, and friends, it searches dir and then each parent up
+// to root, so a subproject's pin shadows the one at the repository root.
+func RequirementsIn(root, dir string) []Requirement {
+	var reqs []Requirement
+	seen := make(map[string]bool)
+	for _, d := range searchDirs(root, dir) {
+		for _, pf := range pinFiles {
+			if seen[pf.tool] {
+				continue
+			}
+			path := filepath.Join(d, pf.file)
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			v := pf.parse(string(contents))
+			if v == "" {
+				continue
+			}
+			seen[pf.tool] = true
+			reqs = append(reqs, Requirement{Tool: pf.tool, Version: v, Source: sourcePath(root, path)})
+		}
+	}
+	return reqs
+}
+
+// searchDirs lists dir and its parents up to and including root. A dir
+// outside root is searched on its own.
+func searchDirs(root, dir string) []string {
+	root, dir = filepath.Clean(root), filepath.Clean(dir)
+	if rel, err := filepath.Rel(root, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return []string{dir}
+	}
+	dirs := []string{dir}
+	for dir != root {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+func sourcePath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// firstLine returns the first non-blank, non-comment line. pyenv allows
+// several versions in .python-version; the first is the active one.
+func firstLine(contents string) string {
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return line
+	}
+	return ""
+}
+
+// parseRuntimeTxt reads Heroku-style runtime.txt ("python-3.11.4").
+func parseRuntimeTxt(contents string) string {
+	v, ok := strings.CutPrefix(firstLine(contents), "python-")
+	if !ok {
+		return ""
+	}
+	return v
+}
+
+// parseSdkmanrc reads the java= entry from an SDKMAN .sdkmanrc
+// ("java=17.0.8-tem").
+func parseSdkmanrc(contents string) string {
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "java" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package version
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRequirements(t *testing.T) {
+	cases := []struct {
+		name  string
+		files map[string]string
+		want  []Requirement
+	}{
+		{
+			name:  "ruby and node",
+			files: map[string]string{".ruby-version": "3.2.2\n", ".node-version": "v20.11.1\n"},
+			want: []Requirement{
+				{Tool: ToolRuby, Version: "3.2.2", Source: ".ruby-version"},
+				{Tool: ToolNode, Version: "v20.11.1", Source: ".node-version"},
+			},
+		},
+		{
+			name:  "python-version first entry",
+			files: map[string]string{".python-version": "# pyenv\n3.11.4\n3.10.12\n"},
+			want:  []Requirement{{Tool: ToolPython, Version: "3.11.4", Source: ".python-version"}},
+		},
+		{
+			name:  "heroku runtime.txt",
+			files: map[string]string{"runtime.txt": "python-3.11.4\n"},
+			want:  []Requirement{{Tool: ToolPython, Version: "3.11.4", Source: "runtime.txt"}},
+		},
+		{
+			name:  "runtime.txt for another language is ignored",
+			files: map[string]string{"runtime.txt": "ruby-3.2.2\n"},
+		},
+		{
+			name:  "python-version wins over runtime.txt",
+			files: map[string]string{".python-version": "3.12.1\n", "runtime.txt": "python-3.11.4\n"},
+			want:  []Requirement{{Tool: ToolPython, Version: "3.12.1", Source: ".python-version"}},
+		},
+		{
+			name:  "sdkmanrc java entry",
+			files: map[string]string{".sdkmanrc": "# Enable auto-env through sdkman_auto_env=true\ngradle=8.4\njava = 17.0.8-tem\n"},
+			want:  []Requirement{{Tool: ToolJava, Version: "17.0.8-tem", Source: ".sdkmanrc"}},
+		},
+		{
+			name:  "sdkmanrc without java",
+			files: map[string]string{".sdkmanrc": "gradle=8.4\n"},
+		},
+		{
+			name:  "java-version",
+			files: map[string]string{".java-version": "17\n"},
+			want:  []Requirement{{Tool: ToolJava, Version: "17", Source: ".java-version"}},
+		},
+		{
+			name:  "blank files are ignored",
+			files: map[string]string{".ruby-version": "\n\n", ".java-version": "  "},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, contents := range tc.files {
+				if err := os.WriteFile(filepath.Join(root, name), []byte(contents), 0o644); err != nil {
+					t.Fatalf("write %s: %v", name, err)
+				}
+			}
+			got := Requirements(root)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Requirements() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequirementsInSubproject(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".ruby-version":         "3.1.4\n",
+		".node-version":         "18.19.0\n",
+		"backend/.ruby-version": "3.3.0\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	got := RequirementsIn(root, filepath.Join(root, "backend"))
+	want := []Requirement{
+		{Tool: ToolRuby, Version: "3.3.0", Source: "backend/.ruby-version"},
+		{Tool: ToolNode, Version: "18.19.0", Source: ".node-version"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("RequirementsIn(backend) = %+v, want %+v", got, want)
+	}
+
+	got = RequirementsIn(root, root)
+	want = []Requirement{
+		{Tool: ToolRuby, Version: "3.1.4", Source: ".ruby-version"},
+		{Tool: ToolNode, Version: "18.19.0", Source: ".node-version"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("RequirementsIn(root) = %+v, want %+v", got, want)
+	}
+}
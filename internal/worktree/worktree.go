@@ -0,0 +1,165 @@
+package worktree
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Worktree is a disposable copy of a project that steps can mutate without
+// touching the user's working tree.
+type Worktree struct {
+	// Root is the directory that corresponds to the original root inside the
+	// copy. Use it as the runner root.
+	Root string
+	// Path is the top of the worktree or copy. It differs from Root when the
+	// source was a subdirectory of a git repository.
+	Path string
+	// Git reports whether the copy is a git worktree of HEAD rather than a
+	// plain directory copy.
+	Git bool
+
+	dir     string // temporary parent removed on cleanup
+	repoDir string // original repository top level for git worktrees
+}
+
+// Create makes a sandbox copy of root. Inside a git repository it adds a
+// detached worktree of HEAD, so uncommitted changes are not included;
+// otherwise root is copied as-is.
+func Create(root string) (*Worktree, error) {
+	root, err := filepath.Abs(root)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolve worktree source: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "testdrive-worktree-")
+	if err != nil {
+		return nil, fmt.Errorf("create worktree directory: %w", err)
+	}
+	wt := &Worktree{dir: dir, Path: filepath.Join(dir, "tree")}
+
+	if top, ok := gitTopLevel(root); ok {
+		rel, err := filepath.Rel(top, root)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("locate %q in repository: %w", root, err)
+		}
+		if _, err := git(top, "worktree", "add", "--detach", wt.Path, "HEAD"); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("create git worktree: %w", err)
+		}
+		wt.Git = true
+		wt.repoDir = top
+		wt.Root = filepath.Join(wt.Path, rel)
+		return wt, nil
+	}
+
+	if err := copyTree(root, wt.Path); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("copy %q to worktree: %w", root, err)
+	}
+	wt.Root = wt.Path
+	return wt, nil
+}
+
+// Remove deletes the sandbox and, for git worktrees, unregisters it from the
+// repository.
+func (w *Worktree) Remove() error {
+	if w.Git {
+		if _, err := git(w.repoDir, "worktree", "remove", "--force", w.Path); err != nil {
+			return fmt.Errorf("remove git worktree %q: %w", w.Path, err)
+		}
+	}
+	if err := os.RemoveAll(w.dir); err != nil {
+		return fmt.Errorf("remove worktree %q: %w", w.dir, err)
+	}
+	return nil
+}
+
+func gitTopLevel(dir string) (string, bool) {
+	out, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil || out == "" {
+		return "", false
+	}
+	// Resolve symlinks on both sides so Rel works on systems where the temp
+	// directory is itself a symlink (macOS /var -> /private/var).
+	top, err := filepath.EvalSymlinks(out)
+	if err != nil {
+		return "", false
+	}
+	return top, true
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+		}
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// copyTree copies src into dst preserving file modes and symlinks.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		default:
+			// Sockets, devices, and pipes have no meaningful copy.
+			return nil
+		}
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("copy %q: %w", src, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package worktree
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCreateGitWorktree(t *testing.T) {
+	repo := initRepo(t)
+	writeFile(t, filepath.Join(repo, "app", "main.go"), "package main\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "init")
+	// Uncommitted edits stay behind; the worktree mirrors HEAD.
+	writeFile(t, filepath.Join(repo, "app", "dirty.txt"), "local\n")
+
+	wt, err := Create(filepath.Join(repo, "app"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !wt.Git {
+		t.Fatalf("expected git worktree")
+	}
+	if filepath.Base(wt.Root) != "app" || !strings.HasPrefix(wt.Root, wt.Path) {
+		t.Fatalf("expected root to map to the app subdirectory, got root=%q path=%q", wt.Root, wt.Path)
+	}
+	if _, err := os.Stat(filepath.Join(wt.Root, "main.go")); err != nil {
+		t.Fatalf("expected committed file in worktree: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(wt.Root, "dirty.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected uncommitted file to be absent, got %v", err)
+	}
+
+	writeFile(t, filepath.Join(wt.Root, "generated.txt"), "artifact\n")
+	if err := wt.Remove(); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Stat(wt.Path); !os.IsNotExist(err) {
+		t.Fatalf("expected worktree to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "app", "generated.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected original tree untouched, got %v", err)
+	}
+	if out := runGit(t, repo, "worktree", "list"); strings.Count(out, "\n") != 0 {
+		t.Fatalf("expected worktree to be unregistered, got:\n%s", out)
+	}
+}
+
+func TestCreateGitWorktreeWithoutCommits(t *testing.T) {
+	repo := initRepo(t)
+
+	_, err := Create(repo)
+	if err == nil || !strings.Contains(err.Error(), "create git worktree") {
+		t.Fatalf("expected a clear worktree error, got %v", err)
+	}
+}
+
+func TestCreateCopy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks and modes are not portable to windows")
+	}
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "bin", "setup"), "#!/bin/sh\n")
+	if err := os.Chmod(filepath.Join(src, "bin", "setup"), 0o755); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	if err := os.Symlink("bin/setup", filepath.Join(src, "setup")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	wt, err := Create(src)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if wt.Git {
+		t.Fatalf("expected plain copy outside a git repository")
+	}
+	info, err := os.Stat(filepath.Join(wt.Root, "bin", "setup"))
+	if err != nil || info.Mode().Perm() != 0o755 {
+		t.Fatalf("expected executable copy, got %v %v", info, err)
+	}
+	if link, err := os.Readlink(filepath.Join(wt.Root, "setup")); err != nil || link != "bin/setup" {
+		t.Fatalf("expected symlink to be preserved, got %q %v", link, err)
+	}
+
+	if err := wt.Remove(); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Stat(wt.Root); !os.IsNotExist(err) {
+		t.Fatalf("expected copy to be removed, got %v", err)
+	}
+}
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	runGit(t, repo, "init", "-q")
+	return repo
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}